@@ -0,0 +1,149 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster
+
+/*
+ * This file contains structs and functions for condensing the output of a
+ * cluster command, so that the few hosts or segments whose output differs
+ * from the rest are not lost among many identical lines.
+ */
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/operating"
+)
+
+/*
+ * An OutputGroup holds all commands that exited with the same code and
+ * produced the same stdout, ignoring leading and trailing whitespace.  Names
+ * holds the host for per-host commands and "seg<content>" for per-segment
+ * commands, in the order the commands were run.
+ */
+type OutputGroup struct {
+	ExitCode int
+	Stdout   string
+	Stderr   string
+	Names    []string
+	Commands []ShellCommand
+}
+
+/*
+ * CommandExitCode returns the exit code of a completed command, or -1 if the
+ * command failed without exiting normally (e.g. it could not be started).  A
+ * command killed by a signal has an exit code of 128 plus the signal number.
+ */
+func CommandExitCode(command ShellCommand) int {
+	return command.ExitStatus().Code
+}
+
+// ExitStatus decodes how the command exited, including any signal that killed it
+func (command ShellCommand) ExitStatus() operating.ExitStatus {
+	return operating.DecodeExitStatus(command.Error)
+}
+
+func commandName(command ShellCommand) string {
+	if scopeIsHosts(command.Scope) {
+		return command.Host
+	}
+	return fmt.Sprintf("seg%d", command.Content)
+}
+
+/*
+ * GroupOutput groups commands by exit code and stdout, returning the groups
+ * from largest to smallest.  Groups of the same size are kept in the order
+ * their first command was run.
+ */
+func (remoteOutput *RemoteOutput) GroupOutput() []OutputGroup {
+	type groupKey struct {
+		exitCode int
+		stdout   string
+	}
+	groups := make([]OutputGroup, 0)
+	indexes := make(map[groupKey]int)
+	for _, command := range remoteOutput.Commands {
+		key := groupKey{CommandExitCode(command), strings.TrimSpace(command.Stdout)}
+		index, ok := indexes[key]
+		if !ok {
+			index = len(groups)
+			indexes[key] = index
+			groups = append(groups, OutputGroup{
+				ExitCode: key.exitCode,
+				Stdout:   key.stdout,
+				Stderr:   strings.TrimSpace(command.Stderr),
+			})
+		}
+		groups[index].Names = append(groups[index].Names, commandName(command))
+		groups[index].Commands = append(groups[index].Commands, command)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i].Names) > len(groups[j].Names)
+	})
+	return groups
+}
+
+func (group OutputGroup) description() string {
+	if group.ExitCode == 0 {
+		if group.Stdout == "" {
+			return "OK"
+		}
+		return group.Stdout
+	}
+	message := group.Stderr
+	if message == "" {
+		message = group.Stdout
+	}
+	if message == "" {
+		message = group.Commands[0].Error.Error()
+	}
+	if group.ExitCode == -1 {
+		return fmt.Sprintf("error %s", message)
+	}
+	return fmt.Sprintf("error (exit code %d) %s", group.ExitCode, message)
+}
+
+/*
+ * Summarize returns a single line describing the output of all commands, e.g.
+ *
+ *   42 hosts: OK; 2 hosts (sdw3, sdw9): error (exit code 1) XYZ
+ *
+ * The largest group is listed first without names, since it is assumed to be
+ * the expected result; every other group lists up to 3 of its hosts or
+ * segments.
+ */
+func (remoteOutput *RemoteOutput) Summarize() string {
+	unit := "segment"
+	if scopeIsHosts(remoteOutput.Scope) {
+		unit = "host"
+	}
+	groups := remoteOutput.GroupOutput()
+	parts := make([]string, len(groups))
+	for i, group := range groups {
+		count := fmt.Sprintf("%d %s", len(group.Names), unit)
+		if len(group.Names) != 1 {
+			count += "s"
+		}
+		if i > 0 {
+			count += fmt.Sprintf(" (%s)", formatExamples(group.Names, 3))
+		}
+		parts[i] = fmt.Sprintf("%s: %s", count, group.description())
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,142 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster_test
+
+import (
+	"os/exec"
+	"syscall"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/aggregate tests", func() {
+	var exitErr error
+	hostCommand := func(host string, stdout string, stderr string, err error) cluster.ShellCommand {
+		return cluster.ShellCommand{Scope: cluster.ON_HOSTS, Content: -2, Host: host, Stdout: stdout, Stderr: stderr, Error: err}
+	}
+
+	BeforeEach(func() {
+		exitErr = exec.Command("bash", "-c", "exit 2").Run()
+	})
+
+	Describe("CommandExitCode", func() {
+		It("returns 0 for a successful command", func() {
+			Expect(cluster.CommandExitCode(hostCommand("sdw1", "", "", nil))).To(Equal(0))
+		})
+		It("returns the exit code for a command that exited with an error", func() {
+			Expect(cluster.CommandExitCode(hostCommand("sdw1", "", "", exitErr))).To(Equal(2))
+		})
+		It("returns -1 for a command that failed without exiting", func() {
+			Expect(cluster.CommandExitCode(hostCommand("sdw1", "", "", errors.New("could not start")))).To(Equal(-1))
+		})
+		It("returns 128 plus the signal number for a command that was killed", func() {
+			killErr := exec.Command("bash", "-c", "kill -9 $$").Run()
+			Expect(cluster.CommandExitCode(hostCommand("sdw1", "", "", killErr))).To(Equal(137))
+		})
+	})
+	Describe("ShellCommand.ExitStatus", func() {
+		It("decodes a signal from a command that was killed", func() {
+			killErr := exec.Command("bash", "-c", "kill -9 $$").Run()
+			status := hostCommand("sdw1", "", "", killErr).ExitStatus()
+			Expect(status.Signal).To(Equal(syscall.SIGKILL))
+			Expect(status.Killed()).To(BeTrue())
+			Expect(status.String()).To(Equal("exit code 137, killed by signal 9 (killed)"))
+		})
+		It("decodes a signal reported by a shell as its exit code", func() {
+			shellErr := exec.Command("bash", "-c", "bash -c 'kill -TERM $$'").Run()
+			status := hostCommand("sdw1", "", "", shellErr).ExitStatus()
+			Expect(status.Code).To(Equal(143))
+			Expect(status.Signal).To(Equal(syscall.SIGTERM))
+			Expect(status.Killed()).To(BeFalse())
+		})
+		It("does not decode a signal from an ordinary exit code", func() {
+			status := hostCommand("sdw1", "", "", exitErr).ExitStatus()
+			Expect(status.Signal).To(Equal(syscall.Signal(0)))
+			Expect(status.String()).To(Equal("exit code 2"))
+		})
+		It("reports a command that could not be run as not having exited", func() {
+			status := hostCommand("sdw1", "", "", errors.New("could not start")).ExitStatus()
+			Expect(status.Exited()).To(BeFalse())
+			Expect(status.String()).To(Equal("did not exit"))
+		})
+	})
+	Describe("GroupOutput", func() {
+		It("groups commands by exit code and stdout, largest group first", func() {
+			remoteOutput := cluster.NewRemoteOutput(cluster.ON_HOSTS, 1, []cluster.ShellCommand{
+				hostCommand("sdw1", "7.0\n", "", nil),
+				hostCommand("sdw2", "6.0", "", nil),
+				hostCommand("sdw3", "7.0", "", nil),
+				hostCommand("sdw4", "7.0", "", exitErr),
+			})
+			groups := remoteOutput.GroupOutput()
+			Expect(groups).To(HaveLen(3))
+			Expect(groups[0].Names).To(Equal([]string{"sdw1", "sdw3"}))
+			Expect(groups[0].Stdout).To(Equal("7.0"))
+			Expect(groups[1].Names).To(Equal([]string{"sdw2"}))
+			Expect(groups[2].Names).To(Equal([]string{"sdw4"}))
+			Expect(groups[2].ExitCode).To(Equal(2))
+			Expect(groups[2].Commands).To(HaveLen(1))
+		})
+	})
+	Describe("Summarize", func() {
+		It("summarizes identical successful output on one line", func() {
+			remoteOutput := cluster.NewRemoteOutput(cluster.ON_HOSTS, 0, []cluster.ShellCommand{
+				hostCommand("sdw1", "", "", nil),
+				hostCommand("sdw2", "", "", nil),
+			})
+			Expect(remoteOutput.Summarize()).To(Equal("2 hosts: OK"))
+		})
+		It("lists the hosts with differing output after the largest group", func() {
+			remoteOutput := cluster.NewRemoteOutput(cluster.ON_HOSTS, 2, []cluster.ShellCommand{
+				hostCommand("sdw1", "", "", nil),
+				hostCommand("sdw3", "", "error XYZ\n", exitErr),
+				hostCommand("sdw2", "", "", nil),
+				hostCommand("sdw9", "", "error XYZ", exitErr),
+				hostCommand("sdw4", "", "", nil),
+				hostCommand("sdw5", "", "", errors.New("could not start")),
+			})
+			Expect(remoteOutput.Summarize()).To(Equal("3 hosts: OK; 2 hosts (sdw3, sdw9): error (exit code 2) error XYZ; 1 host (sdw5): error could not start"))
+		})
+		It("limits the number of hosts listed for a group", func() {
+			remoteOutput := cluster.NewRemoteOutput(cluster.ON_HOSTS, 0, []cluster.ShellCommand{
+				hostCommand("sdw1", "a", "", nil),
+				hostCommand("sdw2", "a", "", nil),
+				hostCommand("sdw3", "a", "", nil),
+				hostCommand("sdw4", "a", "", nil),
+				hostCommand("sdw5", "a", "", nil),
+				hostCommand("sdw6", "b", "", nil),
+				hostCommand("sdw7", "b", "", nil),
+				hostCommand("sdw8", "b", "", nil),
+				hostCommand("sdw9", "b", "", nil),
+			})
+			Expect(remoteOutput.Summarize()).To(Equal("5 hosts: a; 4 hosts (sdw6, sdw7, sdw8, and 1 more): b"))
+		})
+		It("names segments by content for per-segment commands", func() {
+			remoteOutput := cluster.NewRemoteOutput(cluster.ON_SEGMENTS, 0, []cluster.ShellCommand{
+				{Scope: cluster.ON_SEGMENTS, Content: 0, Stdout: "on"},
+				{Scope: cluster.ON_SEGMENTS, Content: 1, Stdout: "on"},
+				{Scope: cluster.ON_SEGMENTS, Content: 2, Stdout: "off"},
+			})
+			Expect(remoteOutput.Summarize()).To(Equal("2 segments: on; 1 segment (seg2): off"))
+		})
+	})
+})
@@ -17,11 +17,14 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/apache/cloudberry-go-libs/dbconn"
 	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/gpversion"
 	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/retry"
 	"github.com/pkg/errors"
 )
 
@@ -32,8 +35,32 @@ type Executor interface {
 	ExecuteClusterCommandWithRetries(scope Scope, commandList []ShellCommand, maxAttempts int, retrySleep time.Duration) *RemoteOutput
 }
 
-// This type only exists to allow us to mock Execute[...]Command functions for testing
-type GPDBExecutor struct{}
+/*
+ * A ContextExecutor is an Executor that can also cancel cluster commands with
+ * a context.  GPDBExecutor implements it; Cluster's ...WithContext methods use
+ * it when the cluster's Executor implements it.
+ */
+type ContextExecutor interface {
+	Executor
+	ExecuteClusterCommandWithContext(scope Scope, commandList []ShellCommand, ctx context.Context) *RemoteOutput
+	ExecuteClusterCommandWithRetriesAndContext(scope Scope, commandList []ShellCommand, maxAttempts int, retrySleep time.Duration, ctx context.Context) *RemoteOutput
+}
+
+/*
+ * This type only exists to allow us to mock Execute[...]Command functions for
+ * testing.  Its limit fields are normally set with Cluster.SetThrottle;
+ * CommandHost is needed to throttle per-segment commands, which have no Host
+ * set.  If OnProgress is set, it is called each time a command in a batch
+ * finishes; see LogProgress.  DeduplicateCommands is normally set with
+ * Cluster.SetCommandDeduplication.
+ */
+type GPDBExecutor struct {
+	MaxConcurrent        int
+	MaxConcurrentPerHost int
+	CommandHost          func(command ShellCommand) string
+	OnProgress           func(progress BatchProgress)
+	DeduplicateCommands  bool
+}
 
 /*
  * A Cluster object stores information about the cluster in three ways:
@@ -52,6 +79,14 @@ type Cluster struct {
 	ByContent  map[int][]*SegConfig
 	ByHost     map[string][]*SegConfig
 	Executor
+	ErrorReporting ErrorReportOptions
+	Throttle       ThrottleOptions
+	SSH            SSHOptions
+	Environment    CommandEnvironment
+
+	// See IsLocalHost in local.go
+	DisableLocalExecution bool
+	localHosts            *sync.Map
 }
 
 type SegConfig struct {
@@ -183,6 +218,7 @@ type ShellCommand struct {
 	Error         error
 	RetryError    error
 	Completed     bool
+	Canceled      bool
 }
 
 func NewShellCommand(scope Scope, content int, host string, command []string) ShellCommand {
@@ -202,6 +238,7 @@ func NewShellCommand(scope Scope, content int, host string, command []string) Sh
 type RemoteOutput struct {
 	Scope           Scope
 	NumErrors       int
+	NumCanceled     int
 	Commands        []ShellCommand
 	FailedCommands  []ShellCommand
 	RetriedCommands []ShellCommand
@@ -210,7 +247,11 @@ type RemoteOutput struct {
 func NewRemoteOutput(scope Scope, numErrors int, commands []ShellCommand) *RemoteOutput {
 	failedCommands := make([]ShellCommand, 0)
 	retriedCommands := make([]ShellCommand, 0)
+	numCanceled := 0
 	for _, command := range commands {
+		if command.Canceled {
+			numCanceled++
+		}
 		if command.Error != nil {
 			failedCommands = append(failedCommands, command)
 		} else if command.RetryError != nil {
@@ -220,6 +261,7 @@ func NewRemoteOutput(scope Scope, numErrors int, commands []ShellCommand) *Remot
 	return &RemoteOutput{
 		Scope:           scope,
 		NumErrors:       numErrors,
+		NumCanceled:     numCanceled,
 		Commands:        commands,
 		FailedCommands:  failedCommands,
 		RetriedCommands: retriedCommands,
@@ -236,6 +278,7 @@ func NewCluster(segConfigs []SegConfig) *Cluster {
 	cluster.ByContent = make(map[int][]*SegConfig, 0)
 	cluster.ByHost = make(map[string][]*SegConfig, 0)
 	cluster.Executor = &GPDBExecutor{}
+	cluster.localHosts = &sync.Map{}
 
 	for i := range cluster.Segments {
 		segment := &cluster.Segments[i]
@@ -271,10 +314,23 @@ func NewCluster(segConfigs []SegConfig) *Cluster {
  * This method makes it easier for the user to pass in whichever function fits
  * the kind of command they're generating, as opposed to having to pass in both
  * content and hostname regardless of scope or using some sort of helper struct.
+ *
+ * A *CommandTemplate may also be passed; see template.go for details.
  */
 func (cluster *Cluster) GenerateCommandList(scope Scope, generator interface{}) []ShellCommand {
 	commands := []ShellCommand{}
 	switch generateCommand := generator.(type) {
+	case *CommandTemplate:
+		if scopeIsHosts(scope) {
+			generateHostCommand := cluster.templateHostFunc(generateCommand)
+			return cluster.GenerateCommandList(scope, func(host string) []string {
+				return []string{"bash", "-c", generateHostCommand(host)}
+			})
+		}
+		generateContentCommand := cluster.templateContentFunc(generateCommand)
+		return cluster.GenerateCommandList(scope, func(content int) []string {
+			return []string{"bash", "-c", generateContentCommand(content)}
+		})
 	case func(content int) []string:
 		for _, content := range cluster.ContentIDs {
 			if content == -1 && scopeExcludesCoordinator(scope) {
@@ -302,33 +358,36 @@ func (cluster *Cluster) GenerateCommandList(scope Scope, generator interface{})
 }
 
 func ConstructSSHCommand(useLocal bool, host string, cmd string) []string {
-	if useLocal {
-		return []string{"bash", "-c", cmd}
-	}
-	currentUser, _ := operating.System.CurrentUser()
-	user := currentUser.Username
-	return []string{"ssh", "-o", "StrictHostKeyChecking=no", fmt.Sprintf("%s@%s", user, host), cmd}
+	return ConstructSSHCommandWithOptions(useLocal, host, cmd, SSHOptions{})
 }
 
 /*
  * This function essentially wraps GenerateCommandList such that commands to be
  * executed on other hosts are sent through SSH and local commands use Bash.
+ * Any nice or ionice settings from cluster.Throttle, environment variables
+ * from cluster.Environment, and ssh options from cluster.SSH are applied here.
  */
 func (cluster *Cluster) GenerateSSHCommandList(scope Scope, generator interface{}) []ShellCommand {
 	var commands []ShellCommand
-	localHost := cluster.GetHostForContent(-1)
+	if commandTemplate, ok := generator.(*CommandTemplate); ok {
+		if scopeIsHosts(scope) {
+			generator = cluster.templateHostFunc(commandTemplate)
+		} else {
+			generator = cluster.templateContentFunc(commandTemplate)
+		}
+	}
 	switch generateCommand := generator.(type) {
 	case func(content int) string:
 		commands = cluster.GenerateCommandList(scope, func(content int) []string {
-			useLocal := (cluster.GetHostForContent(content) == localHost || scopeIsLocal(scope))
-			cmd := generateCommand(content)
-			return ConstructSSHCommand(useLocal, cluster.GetHostForContent(content), cmd)
+			useLocal := (cluster.IsLocalHost(cluster.GetHostForContent(content)) || scopeIsLocal(scope))
+			cmd := cluster.Environment.contentPrefix(content) + cluster.Throttle.WrapCommand(generateCommand(content))
+			return ConstructSSHCommandWithOptions(useLocal, cluster.GetHostForContent(content), cmd, cluster.SSH)
 		})
 	case func(host string) string:
 		commands = cluster.GenerateCommandList(scope, func(host string) []string {
-			useLocal := (host == localHost || scopeIsLocal(scope))
-			cmd := generateCommand(host)
-			return ConstructSSHCommand(useLocal, host, cmd)
+			useLocal := (cluster.IsLocalHost(host) || scopeIsLocal(scope))
+			cmd := cluster.Environment.hostPrefix(host) + cluster.Throttle.WrapCommand(generateCommand(host))
+			return ConstructSSHCommandWithOptions(useLocal, host, cmd, cluster.SSH)
 		})
 	}
 	return commands
@@ -345,16 +404,34 @@ func (executor *GPDBExecutor) ExecuteLocalCommandWithContext(commandStr string,
 }
 
 // Create a new exec.Command object so we can run it again
-func resetCmd(cmd *exec.Cmd) *exec.Cmd {
+func resetCmd(cmd *exec.Cmd, ctx context.Context) *exec.Cmd {
 	args := cmd.Args
-	return exec.Command(args[0], args[1:]...)
+	newCmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	// Don't wait indefinitely for grandchildren holding stdout open after the command is killed
+	newCmd.WaitDelay = time.Second
+	return newCmd
 }
 
 /*
  * ExecuteClusterCommandWithRetries, but only 1 attempt to keep the previous functionality
  */
 func (executor *GPDBExecutor) ExecuteClusterCommand(scope Scope, commandList []ShellCommand) *RemoteOutput {
-	return executor.ExecuteClusterCommandWithRetries(scope, commandList, 1, 0)
+	return executor.ExecuteClusterCommandWithRetriesAndContext(scope, commandList, 1, 0, context.Background())
+}
+
+func (executor *GPDBExecutor) ExecuteClusterCommandWithContext(scope Scope, commandList []ShellCommand, ctx context.Context) *RemoteOutput {
+	return executor.ExecuteClusterCommandWithRetriesAndContext(scope, commandList, 1, 0, ctx)
+}
+
+/*
+ * ExecuteClusterCommandWithRetries runs each command up to maxAttempts times,
+ * waiting retrySleep between attempts, as described for
+ * ExecuteClusterCommandWithRetriesAndContext.  Every command is run at least
+ * once: a maxAttempts below 1 is treated as 1, where earlier versions ran
+ * nothing and reported every command as successful.
+ */
+func (executor *GPDBExecutor) ExecuteClusterCommandWithRetries(scope Scope, commandList []ShellCommand, maxAttempts int, retrySleep time.Duration) *RemoteOutput {
+	return executor.ExecuteClusterCommandWithRetriesAndContext(scope, commandList, maxAttempts, retrySleep, context.Background())
 }
 
 /*
@@ -362,40 +439,87 @@ func (executor *GPDBExecutor) ExecuteClusterCommand(scope Scope, commandList []S
  * doesn't care about the scope of the command except to pass that on to the
  * RemoteOutput after execution.
  *
- * It will retry the command up to maxAttempts times
+ * It will retry the command up to maxAttempts times, and runs it once if
+ * maxAttempts is below 1.
+ *
+ * If ctx is canceled, running commands are killed, commands that have not yet
+ * started are not run, and no further retries are attempted.  Each command
+ * that did not run to completion because of the cancellation has Canceled set,
+ * Completed unset, and ctx.Err() as its Error, and is counted in both
+ * NumErrors and NumCanceled; Completed is only set for commands that ran to
+ * completion, whether or not they succeeded.
+ *
+ * If DeduplicateCommands is set, identical commands for the same host are
+ * only run once; see SetCommandDeduplication.
  * TODO: Add batching to prevent bottlenecks when executing in a huge cluster.
  */
-func (executor *GPDBExecutor) ExecuteClusterCommandWithRetries(scope Scope, commandList []ShellCommand, maxAttempts int, retrySleep time.Duration) *RemoteOutput {
+func (executor *GPDBExecutor) ExecuteClusterCommandWithRetriesAndContext(scope Scope, commandList []ShellCommand, maxAttempts int, retrySleep time.Duration, ctx context.Context) *RemoteOutput {
+	if executor.DeduplicateCommands {
+		uniqueCommands, owners := executor.deduplicateCommands(commandList)
+		if len(uniqueCommands) < len(commandList) {
+			executor.executeCommands(scope, uniqueCommands, maxAttempts, retrySleep, ctx)
+			return copyDeduplicatedResults(scope, commandList, uniqueCommands, owners)
+		}
+	}
+	return executor.executeCommands(scope, commandList, maxAttempts, retrySleep, ctx)
+}
+
+func (executor *GPDBExecutor) executeCommands(scope Scope, commandList []ShellCommand, maxAttempts int, retrySleep time.Duration, ctx context.Context) *RemoteOutput {
 	length := len(commandList)
 	finished := make(chan int)
 	numErrors := 0
+	slots := executor.commandSlots(commandList)
+	durations := make([]time.Duration, length)
+	tracker := executor.progressTracker(commandList)
 	for i := range commandList {
 		go func(index int) {
 			var (
 				out    []byte
-				err    error
 				stderr bytes.Buffer
 			)
 			command := commandList[index]
-			for attempt := 1; attempt <= maxAttempts; attempt++ {
+			var started time.Duration
+			hasStarted := false
+			attempt := 0
+			err := retry.Do(ctx, retry.Constant(max(maxAttempts, 1), retrySleep), func(ctx context.Context) error {
+				attempt++
 				stderr.Reset()
-				cmd := resetCmd(command.Command)
+				cmd := resetCmd(command.Command, ctx)
 				cmd.Stderr = &stderr
+				release, acquired := executor.acquireSlot(ctx, slots, command)
+				if !acquired {
+					return ctx.Err()
+				}
+				if !hasStarted {
+					started, hasStarted = operating.System.MonotonicNow(), true
+				}
+				var err error
 				out, err = cmd.Output()
-				if err == nil {
-					break
-				} else {
+				release()
+				if err != nil && ctx.Err() == nil {
 					newRetryErr := fmt.Errorf("attempt %d: error was %w: %s", attempt, err, stderr.String())
 					command.RetryError = joinerrs.Join(command.RetryError, newRetryErr)
-					if attempt != maxAttempts {
-						time.Sleep(retrySleep)
-					}
+				}
+				return err
+			})
+			if err != nil {
+				if ctx.Err() != nil {
+					err = ctx.Err()
+				} else {
+					err = err.(*retry.Error).Last()
 				}
 			}
 			command.Stdout = string(out)
 			command.Stderr = stderr.String()
 			command.Error = err
-			command.Completed = true
+			command.Canceled = err != nil && err == ctx.Err()
+			command.Completed = !command.Canceled
+			if hasStarted {
+				durations[index] = operating.Since(started)
+			}
+			if attempt > 1 {
+				commandRetries.Add(nil, float64(attempt-1))
+			}
 			commandList[index] = command
 			finished <- index
 		}(i)
@@ -405,10 +529,32 @@ func (executor *GPDBExecutor) ExecuteClusterCommandWithRetries(scope Scope, comm
 		if commandList[index].Error != nil {
 			numErrors++
 		}
+		observeCommand(commandList[index], durations[index])
+		if tracker != nil {
+			progress := tracker.Complete(executor.throttleHost(commandList[index]), durations[index], operating.System.MonotonicNow())
+			executor.OnProgress(progress)
+		}
 	}
 	return NewRemoteOutput(scope, numErrors, commandList)
 }
 
+/*
+ * ExecuteClusterCommandWithContext and ExecuteClusterCommandWithRetriesAndContext
+ * run commandList with the cluster's Executor, canceling the commands when ctx
+ * is canceled if the Executor is a ContextExecutor.  Any other Executor runs
+ * the commands to completion, as ExecuteClusterCommandWithRetries does.
+ */
+func (cluster *Cluster) ExecuteClusterCommandWithContext(scope Scope, commandList []ShellCommand, ctx context.Context) *RemoteOutput {
+	return cluster.ExecuteClusterCommandWithRetriesAndContext(scope, commandList, 1, 0, ctx)
+}
+
+func (cluster *Cluster) ExecuteClusterCommandWithRetriesAndContext(scope Scope, commandList []ShellCommand, maxAttempts int, retrySleep time.Duration, ctx context.Context) *RemoteOutput {
+	if executor, ok := cluster.Executor.(ContextExecutor); ok {
+		return executor.ExecuteClusterCommandWithRetriesAndContext(scope, commandList, maxAttempts, retrySleep, ctx)
+	}
+	return cluster.Executor.ExecuteClusterCommandWithRetries(scope, commandList, maxAttempts, retrySleep)
+}
+
 /*
  * GenerateAndExecuteCommand and CheckClusterError are generic wrapper functions
  * to simplify execution of...
@@ -424,6 +570,17 @@ func (cluster *Cluster) GenerateAndExecuteCommand(verboseMsg string, scope Scope
 }
 
 func (cluster *Cluster) CheckClusterError(remoteOutput *RemoteOutput, finalErrMsg string, messageFunc interface{}, noFatal ...bool) {
+	cluster.CheckClusterErrorWithReport(remoteOutput, finalErrMsg, messageFunc, noFatal...)
+}
+
+/*
+ * CheckClusterErrorWithReport behaves identically to CheckClusterError, but
+ * also returns the path of the JSON error report written for the failures, if
+ * cluster.ErrorReporting.ReportDir is set; see report.go for details.  If the
+ * error is fatal, the report path is included in the fatal error message.
+ */
+func (cluster *Cluster) CheckClusterErrorWithReport(remoteOutput *RemoteOutput, finalErrMsg string, messageFunc interface{}, noFatal ...bool) string {
+	cluster.recordRun(remoteOutput, finalErrMsg)
 	for _, retriedCommand := range remoteOutput.RetriedCommands {
 		switch messageFunc.(type) {
 		case func(content int) string:
@@ -438,7 +595,12 @@ func (cluster *Cluster) CheckClusterError(remoteOutput *RemoteOutput, finalErrMs
 	}
 
 	if remoteOutput.NumErrors == 0 {
-		return
+		return ""
+	}
+	// When summarizing, per-command details only go to the shell at debug verbosity
+	detailShellVerbosity := gplog.LOGVERBOSE
+	if cluster.ErrorReporting.Summarize {
+		detailShellVerbosity = gplog.LOGDEBUG
 	}
 	for _, failedCommand := range remoteOutput.FailedCommands {
 		errStr := fmt.Sprintf("with error %s: %s", failedCommand.Error, failedCommand.Stderr)
@@ -446,22 +608,34 @@ func (cluster *Cluster) CheckClusterError(remoteOutput *RemoteOutput, finalErrMs
 		case func(content int) string:
 			content := failedCommand.Content
 			host := cluster.GetHostForContent(content)
-			gplog.Custom(gplog.LOGERROR, gplog.LOGVERBOSE, "%s on segment %d on host %s %s", getMessage(content), content, host, errStr)
+			gplog.Custom(gplog.LOGERROR, detailShellVerbosity, "%s on segment %d on host %s %s", getMessage(content), content, host, errStr)
 		case func(host string) string:
 			host := failedCommand.Host
-			gplog.Custom(gplog.LOGERROR, gplog.LOGVERBOSE, "%s on host %s %s", getMessage(host), host, errStr)
+			gplog.Custom(gplog.LOGERROR, detailShellVerbosity, "%s on host %s %s", getMessage(host), host, errStr)
 		}
 		gplog.Verbose("Command was: %s", failedCommand.CommandString)
 	}
+	if cluster.ErrorReporting.Summarize {
+		gplog.Custom(gplog.LOGERROR, gplog.LOGERROR, "%s", cluster.summarizeFailures(remoteOutput, finalErrMsg))
+	}
+	reportPath := ""
+	if cluster.ErrorReporting.ReportDir != "" {
+		reportPath = cluster.writeErrorReport(remoteOutput, finalErrMsg)
+	}
 
 	if len(noFatal) == 1 && noFatal[0] == true {
 		gplog.Error("%s", finalErrMsg)
 	} else {
-		LogFatalClusterError(finalErrMsg, remoteOutput.Scope, remoteOutput.NumErrors)
+		logFatalClusterError(finalErrMsg, remoteOutput.Scope, remoteOutput.NumErrors, reportPath)
 	}
+	return reportPath
 }
 
 func LogFatalClusterError(errMessage string, scope Scope, numErrors int) {
+	logFatalClusterError(errMessage, scope, numErrors, "")
+}
+
+func logFatalClusterError(errMessage string, scope Scope, numErrors int, reportPath string) {
 	str := " on"
 	if scopeIsLocal(scope) {
 		str += " coordinator for" // No good way to toggle "coordinator" vs. "master" here based on version, so default to "coordinator"
@@ -475,7 +649,11 @@ func LogFatalClusterError(errMessage string, scope Scope, numErrors int) {
 	if numErrors != 1 {
 		segMsg += "s"
 	}
-	gplog.Fatal(errors.Errorf("%s %d %s. See %s for a complete list of errors.", errMessage, numErrors, segMsg, gplog.GetLogFilePath()), "")
+	reportMsg := ""
+	if reportPath != "" {
+		reportMsg = fmt.Sprintf(" A machine-readable report was written to %s.", reportPath)
+	}
+	gplog.Fatal(errors.Errorf("%s %d %s. See %s for a complete list of errors.%s", errMessage, numErrors, segMsg, gplog.GetLogFilePath(), reportMsg), "")
 }
 
 /*
@@ -577,7 +755,7 @@ func GetSegmentConfiguration(connection *dbconn.DBConn, getMirrors ...bool) ([]S
 	includeMirrors := len(getMirrors) == 1 && getMirrors[0]
 	includeOnlyMirrors := len(getMirrors) == 2 && getMirrors[1]
 	query := ""
-	if connection.Version.IsGPDB() && connection.Version.Before("6") {
+	if connection.Version.Supports(gpversion.FILESPACES) {
 		whereClause := "WHERE%s f.fsname = 'pg_system'"
 		if includeOnlyMirrors {
 			whereClause = fmt.Sprintf(whereClause, " s.role = 'm' AND")
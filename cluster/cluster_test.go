@@ -87,7 +87,7 @@ var _ = Describe("cluster/cluster tests", func() {
 		})
 		It("constructs a remote ssh command", func() {
 			cmd := cluster.ConstructSSHCommand(false, "some-host", "ls")
-			Expect(cmd).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=no", "testUser@some-host", "ls"}))
+			Expect(cmd).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=no", "some-host", "ls"}))
 		})
 	})
 
@@ -355,14 +355,27 @@ var _ = Describe("cluster/cluster tests", func() {
 			Expect(results[1]).To(Equal(localSegTwoValue))
 			Expect(results[2]).To(Equal(remoteSegOneValue))
 		})
+		It("returns the segments from a canned gp_segment_configuration result", func() {
+			testhelper.ExpectSegmentConfigurationQuery(mock, localSegOneValue, remoteSegOneValue)
+			results, err := cluster.GetSegmentConfiguration(connection, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(Equal([]cluster.SegConfig{localSegOneValue, remoteSegOneValue}))
+			testhelper.AssertAllExpectationsMet(mock)
+		})
+		It("returns no segments from an empty canned gp_segment_configuration result", func() {
+			testhelper.ExpectSegmentConfigurationQuery(mock)
+			results, err := cluster.GetSegmentConfiguration(connection)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(BeEmpty())
+		})
 	})
 
 	Describe("GenerateSSHCommandList", func() {
 		coordinatorSegCmd := []string{"bash", "-c", "ls"}
 		localSegCmd := []string{"bash", "-c", "ls"}
-		remoteSegOneCmd := []string{"ssh", "-o", "StrictHostKeyChecking=no", "testUser@remotehost1", "ls"}
-		remoteSegTwoCmd := []string{"ssh", "-o", "StrictHostKeyChecking=no", "testUser@remotehost2", "ls"}
-		standbyCoordinatorCmd := []string{"ssh", "-o", "StrictHostKeyChecking=no", "testUser@standbycoordinatorhost", "ls"}
+		remoteSegOneCmd := []string{"ssh", "-o", "StrictHostKeyChecking=no", "remotehost1", "ls"}
+		remoteSegTwoCmd := []string{"ssh", "-o", "StrictHostKeyChecking=no", "remotehost2", "ls"}
+		standbyCoordinatorCmd := []string{"ssh", "-o", "StrictHostKeyChecking=no", "standbycoordinatorhost", "ls"}
 		DescribeTable("GenerateSSHCommandList with segments", func(scope cluster.Scope, includeCoordinator bool, numLocalSegments int, numRemoteSegments int) {
 			segments := []cluster.SegConfig{coordinatorSeg}
 			expectedCommands := []cluster.ShellCommand{}
@@ -566,6 +579,23 @@ var _ = Describe("cluster/cluster tests", func() {
 		AfterEach(func() {
 			os.RemoveAll(testDir)
 		})
+		It("runs each command once if maxAttempts is less than 1", func() {
+			testCluster := cluster.Cluster{}
+			commandList := []cluster.ShellCommand{
+				cluster.NewShellCommand(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, -1, "", []string{"touch", path.Join(testDir, "foo")}),
+				cluster.NewShellCommand(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, 0, "", []string{"some-non-existent-command"}),
+			}
+			testCluster.Executor = &cluster.GPDBExecutor{}
+			clusterOutput := testCluster.ExecuteClusterCommandWithRetries(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, commandList, 0, 0)
+
+			expectPathToExist(path.Join(testDir, "foo"))
+			Expect(clusterOutput.NumErrors).To(Equal(1))
+			Expect(clusterOutput.FailedCommands[0].RetryError).To(MatchError(ContainSubstring("attempt 1:")))
+			Expect(clusterOutput.FailedCommands[0].RetryError).ToNot(MatchError(ContainSubstring("attempt 2:")))
+			for _, cmd := range clusterOutput.Commands {
+				Expect(cmd.Completed).To(BeTrue())
+			}
+		})
 		It("retries a command until it passes", func() {
 			scriptFile, _ := os.OpenFile(path.Join(testDir, "incr.bash"), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0777)
 			// This script increments a number in a file and returns an error until it reaches 3 and returns success
@@ -608,6 +638,98 @@ fi`
 			Expect(clusterOutput.FailedCommands[0].Error.Error()).To(Equal(expectedErrMsg))
 			Expect(clusterOutput.FailedCommands[0].RetryError.Error()).To(Equal(fmt.Sprintf("attempt 1: error was %s: \nattempt 2: error was %s: \nattempt 3: error was %s: ", expectedErrMsg, expectedErrMsg, expectedErrMsg)))
 		})
+		It("waits between attempts using the system clock", func() {
+			clock := testhelper.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			clock.Install()
+			defer func() { operating.System = operating.InitializeSystemFunctions() }()
+			commandList := []cluster.ShellCommand{
+				cluster.NewShellCommand(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, -1, "", []string{"false"}),
+			}
+			executor := &cluster.GPDBExecutor{}
+			done := make(chan *cluster.RemoteOutput)
+			go func() {
+				done <- executor.ExecuteClusterCommandWithRetries(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, commandList, 2, time.Hour)
+			}()
+			clock.BlockUntil(1)
+			Consistently(done).ShouldNot(Receive())
+			clock.Advance(time.Hour)
+			var clusterOutput *cluster.RemoteOutput
+			Eventually(done).Should(Receive(&clusterOutput))
+			Expect(clusterOutput.FailedCommands[0].RetryError.Error()).To(ContainSubstring("attempt 2: error was exit status 1"))
+		})
+	})
+	Describe("ExecuteClusterCommandWithContext", func() {
+		It("kills running commands when the context is canceled and marks them as canceled", func() {
+			testCluster := cluster.Cluster{}
+			commandList := []cluster.ShellCommand{
+				cluster.NewShellCommand(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, -1, "", []string{"true"}),
+				cluster.NewShellCommand(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, 0, "", []string{"bash", "-c", "echo partial; sleep 30"}),
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			testCluster.Executor = &cluster.GPDBExecutor{}
+			start := time.Now()
+			clusterOutput := testCluster.ExecuteClusterCommandWithContext(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, commandList, ctx)
+			Expect(time.Since(start)).To(BeNumerically("<", 10*time.Second))
+			Expect(clusterOutput.NumErrors).To(Equal(1))
+			Expect(clusterOutput.NumCanceled).To(Equal(1))
+			Expect(clusterOutput.Commands[0].Canceled).To(BeFalse())
+			Expect(clusterOutput.Commands[0].Completed).To(BeTrue())
+			Expect(clusterOutput.Commands[1].Canceled).To(BeTrue())
+			Expect(clusterOutput.Commands[1].Completed).To(BeFalse())
+			Expect(clusterOutput.Commands[1].Error).To(Equal(context.DeadlineExceeded))
+			Expect(clusterOutput.Commands[1].Stdout).To(Equal("partial\n"))
+		})
+		It("does not run commands if the context is already canceled", func() {
+			testDir := GinkgoT().TempDir()
+			testCluster := cluster.Cluster{}
+			commandList := []cluster.ShellCommand{
+				cluster.NewShellCommand(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, -1, "", []string{"touch", path.Join(testDir, "foo")}),
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			testCluster.Executor = &cluster.GPDBExecutor{}
+			clusterOutput := testCluster.ExecuteClusterCommandWithContext(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, commandList, ctx)
+			Expect(clusterOutput.NumCanceled).To(Equal(1))
+			Expect(clusterOutput.FailedCommands[0].Error).To(Equal(context.Canceled))
+			Expect(path.Join(testDir, "foo")).ToNot(BeAnExistingFile())
+		})
+		It("stops retrying when the context is canceled", func() {
+			testCluster := cluster.Cluster{}
+			commandList := []cluster.ShellCommand{
+				cluster.NewShellCommand(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, -1, "", []string{"false"}),
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			testCluster.Executor = &cluster.GPDBExecutor{}
+			start := time.Now()
+			clusterOutput := testCluster.ExecuteClusterCommandWithRetriesAndContext(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, commandList, 100, time.Second, ctx)
+			Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+			Expect(clusterOutput.NumCanceled).To(Equal(1))
+			Expect(clusterOutput.Commands[0].RetryError.Error()).To(HavePrefix("attempt 1: error was exit status 1"))
+		})
+	})
+	Describe("Cluster.ExecuteClusterCommandWithContext", func() {
+		It("passes the context to an executor that implements ContextExecutor", func() {
+			testCluster := cluster.Cluster{}
+			executor := &testhelper.TestExecutor{ClusterOutput: &cluster.RemoteOutput{}}
+			testCluster.Executor = executor
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			testCluster.ExecuteClusterCommandWithContext(cluster.ON_SEGMENTS, []cluster.ShellCommand{}, ctx)
+			Expect(executor.NumClusterExecutions).To(Equal(1))
+			Expect(executor.ClusterContexts).To(Equal([]context.Context{ctx}))
+		})
+		It("runs the commands without the context on an executor that does not implement ContextExecutor", func() {
+			testCluster := cluster.Cluster{}
+			executor := &testhelper.TestExecutor{ClusterOutput: &cluster.RemoteOutput{}}
+			testCluster.Executor = struct{ cluster.Executor }{executor}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			testCluster.ExecuteClusterCommandWithRetriesAndContext(cluster.ON_SEGMENTS, []cluster.ShellCommand{}, 3, time.Second, ctx)
+			Expect(executor.NumClusterExecutions).To(Equal(1))
+			Expect(executor.ClusterContexts).To(BeEmpty())
+		})
 	})
 	Describe("CheckClusterError", func() {
 		Context("FailedCommands", func() {
@@ -893,6 +1015,108 @@ fi`
 			Expect(newCluster.GetDirForContent(-1)).To(Equal("/new/dir"))
 		})
 	})
+	Describe("FakeExecutor", func() {
+		var fakeExecutor *testhelper.FakeExecutor
+		lsOnSegments := func() []cluster.ShellCommand {
+			return testCluster.GenerateSSHCommandList(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, func(contentID int) string { return "ls" })
+		}
+		BeforeEach(func() {
+			fakeExecutor = testhelper.NewFakeExecutor()
+			fakeExecutor.CommandHost = func(command cluster.ShellCommand) string {
+				return testCluster.GetHostForContent(command.Content)
+			}
+			testCluster.Executor = fakeExecutor
+		})
+		AfterEach(func() {
+			operating.System = operating.InitializeSystemFunctions()
+		})
+		It("returns scripted output for each host and records every call", func() {
+			fakeExecutor.Add(testhelper.FakeResponse{Command: "ls", Host: "remotehost1", Stdout: "remote\n"})
+			fakeExecutor.Add(testhelper.FakeResponse{Command: "ls", Stdout: "local\n"})
+
+			remoteOutput := testCluster.ExecuteClusterCommand(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, lsOnSegments())
+			Expect(remoteOutput.NumErrors).To(Equal(0))
+			Expect(remoteOutput.Commands).To(HaveLen(3))
+			Expect(remoteOutput.Commands[0].Stdout).To(Equal("local\n"))
+			Expect(remoteOutput.Commands[1].Stdout).To(Equal("local\n"))
+			Expect(remoteOutput.Commands[2].Stdout).To(Equal("remote\n"))
+			for _, command := range remoteOutput.Commands {
+				Expect(command.Completed).To(BeTrue())
+			}
+			Expect(fakeExecutor.Calls()).To(HaveLen(3))
+			Expect(fakeExecutor.CallsForHost("localhost")).To(HaveLen(2))
+			remoteCalls := fakeExecutor.CallsForHost("remotehost1")
+			Expect(remoteCalls).To(HaveLen(1))
+			Expect(remoteCalls[0].Content).To(Equal(1))
+			Expect(remoteCalls[0].Command).To(ContainSubstring("remotehost1 ls"))
+		})
+		It("fails commands for a given host or content", func() {
+			fakeExecutor.FailContent(0, testhelper.FakeExitError(2))
+			fakeExecutor.FailHost("remotehost1", errors.New("host unreachable"))
+
+			remoteOutput := testCluster.ExecuteClusterCommand(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, lsOnSegments())
+			Expect(remoteOutput.NumErrors).To(Equal(2))
+			Expect(remoteOutput.Commands[0].Error).ToNot(HaveOccurred())
+			Expect(remoteOutput.Commands[1].ExitStatus().Code).To(Equal(2))
+			Expect(remoteOutput.Commands[2].Error).To(MatchError("host unreachable"))
+		})
+		It("uses a response only as many times as requested, so a command can succeed on retry", func() {
+			fakeExecutor.Add(testhelper.FakeResponse{Contents: []int{1}, Stderr: "busy", Err: errors.New("exit status 1"), Times: 1})
+			fakeExecutor.Add(testhelper.FakeResponse{Contents: []int{1}, Stdout: "ok"})
+
+			remoteOutput := testCluster.ExecuteClusterCommandWithRetries(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, lsOnSegments(), 3, 0)
+			Expect(remoteOutput.NumErrors).To(Equal(0))
+			Expect(remoteOutput.RetriedCommands).To(HaveLen(1))
+			Expect(remoteOutput.RetriedCommands[0].Stdout).To(Equal("ok"))
+			Expect(remoteOutput.RetriedCommands[0].RetryError.Error()).To(Equal("attempt 1: error was exit status 1: busy"))
+			calls := fakeExecutor.CallsForContent(1)
+			Expect(calls).To(HaveLen(2))
+			Expect(calls[1].Attempt).To(Equal(2))
+		})
+		It("waits for the scripted latency using the system clock", func() {
+			clock := testhelper.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			clock.Install()
+			fakeExecutor.Add(testhelper.FakeResponse{Host: "remotehost1", Latency: time.Minute})
+
+			done := make(chan *cluster.RemoteOutput)
+			go func() {
+				done <- testCluster.ExecuteClusterCommand(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, lsOnSegments())
+			}()
+			clock.BlockUntil(1)
+			Consistently(done).ShouldNot(Receive())
+			clock.Advance(time.Minute)
+			Eventually(done).Should(Receive())
+		})
+		It("cancels commands that are waiting when the context is canceled", func() {
+			clock := testhelper.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			clock.Install()
+			fakeExecutor.Add(testhelper.FakeResponse{Host: "remotehost1", Latency: time.Hour})
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			done := make(chan *cluster.RemoteOutput)
+			go func() {
+				done <- testCluster.ExecuteClusterCommandWithContext(cluster.ON_SEGMENTS, lsOnSegments()[2:], ctx)
+			}()
+			clock.BlockUntil(1)
+			cancel()
+			var remoteOutput *cluster.RemoteOutput
+			Eventually(done).Should(Receive(&remoteOutput))
+			Expect(remoteOutput.NumCanceled).To(Equal(1))
+			Expect(remoteOutput.Commands[0].Canceled).To(BeTrue())
+			Expect(remoteOutput.Commands[0].Completed).To(BeFalse())
+			Expect(remoteOutput.Commands[0].Error).To(MatchError(context.Canceled))
+		})
+		It("returns combined output for local commands and ignores host-specific responses", func() {
+			fakeExecutor.Add(testhelper.FakeResponse{Command: "^hostname$", Host: "remotehost1", Stdout: "remotehost1"})
+			fakeExecutor.Add(testhelper.FakeResponse{Command: "^hostname$", Stdout: "coordinator\n", Stderr: "warning\n"})
+
+			output, err := testCluster.ExecuteLocalCommand("hostname")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(output).To(Equal("coordinator\nwarning\n"))
+			Expect(fakeExecutor.LocalCalls()).To(Equal([]testhelper.FakeCall{{Command: "hostname", Attempt: 1, Local: true}}))
+		})
+	})
 	Describe("Accessor functions", func() {
 		var mirrorCluster *cluster.Cluster
 		BeforeEach(func() {
@@ -926,3 +1150,7 @@ fi`
 		})
 	})
 })
+
+var _ = testhelper.ExecutorContractTests("GPDBExecutor", "localhost", func() cluster.Executor {
+	return &cluster.GPDBExecutor{}
+})
@@ -0,0 +1,91 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster
+
+/*
+ * This file contains functions for running identical commands only once per
+ * host, for host-level work such as creating a directory or running rsync
+ * that is generated once for each segment on the host.
+ */
+
+import (
+	"github.com/apache/cloudberry-go-libs/gplog"
+)
+
+/*
+ * SetCommandDeduplication controls whether the GPDBExecutor runs identical
+ * commands for the same host only once.  Commands are identical if their
+ * CommandStrings are equal, and per-segment commands belong to the host of
+ * their segment.  Each duplicate command is given the output and error of the
+ * command that was run, so results are still reported for every segment.
+ *
+ * This should only be enabled if running a command once has the same effect
+ * as running it once per segment, as with "mkdir -p".
+ */
+func (cluster *Cluster) SetCommandDeduplication(enabled bool) {
+	if executor, ok := cluster.Executor.(*GPDBExecutor); ok {
+		executor.DeduplicateCommands = enabled
+		executor.CommandHost = cluster.commandHost
+	}
+}
+
+type commandKey struct {
+	host    string
+	command string
+}
+
+/*
+ * deduplicateCommands returns the first command for each distinct host and
+ * command string, and the index in that list of the command run for each
+ * command in commandList.
+ */
+func (executor *GPDBExecutor) deduplicateCommands(commandList []ShellCommand) ([]ShellCommand, []int) {
+	uniqueCommands := make([]ShellCommand, 0)
+	owners := make([]int, len(commandList))
+	seen := make(map[commandKey]int)
+	for i, command := range commandList {
+		key := commandKey{host: executor.throttleHost(command), command: command.CommandString}
+		if index, ok := seen[key]; ok {
+			owners[i] = index
+			continue
+		}
+		seen[key] = len(uniqueCommands)
+		owners[i] = len(uniqueCommands)
+		uniqueCommands = append(uniqueCommands, command)
+	}
+	return uniqueCommands, owners
+}
+
+func copyDeduplicatedResults(scope Scope, commandList []ShellCommand, uniqueCommands []ShellCommand, owners []int) *RemoteOutput {
+	gplog.Debug("Ran %d unique commands for %d commands", len(uniqueCommands), len(commandList))
+	numErrors := 0
+	for i := range commandList {
+		result := uniqueCommands[owners[i]]
+		commandList[i].Command = result.Command
+		commandList[i].Stdout = result.Stdout
+		commandList[i].Stderr = result.Stderr
+		commandList[i].Error = result.Error
+		commandList[i].RetryError = result.RetryError
+		commandList[i].Completed = result.Completed
+		commandList[i].Canceled = result.Canceled
+		if result.Error != nil {
+			numErrors++
+		}
+	}
+	return NewRemoteOutput(scope, numErrors, commandList)
+}
@@ -0,0 +1,115 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/dedup tests", func() {
+	segOne := cluster.SegConfig{DbID: 2, ContentID: 0, Port: 20000, Hostname: "sdw1", DataDir: "/data/gpseg0", Role: "p"}
+	segTwo := cluster.SegConfig{DbID: 3, ContentID: 1, Port: 20001, Hostname: "sdw1", DataDir: "/data/gpseg1", Role: "p"}
+	segThree := cluster.SegConfig{DbID: 4, ContentID: 2, Port: 20000, Hostname: "sdw2", DataDir: "/data/gpseg2", Role: "p"}
+	var (
+		testCluster *cluster.Cluster
+		counterFile string
+	)
+
+	// Each command appends a line to counterFile, so the number of lines is the number of commands run
+	perSegmentCommands := func(generator func(contentID int) string) []cluster.ShellCommand {
+		commandList := make([]cluster.ShellCommand, 0)
+		for _, contentID := range []int{0, 1, 2} {
+			commandList = append(commandList, cluster.NewShellCommand(cluster.ON_SEGMENTS, contentID, "", []string{"bash", "-c", generator(contentID)}))
+		}
+		return commandList
+	}
+	countRuns := func() int {
+		contents, err := os.ReadFile(counterFile)
+		Expect(err).ToNot(HaveOccurred())
+		return strings.Count(string(contents), "\n")
+	}
+
+	BeforeEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		testCluster = cluster.NewCluster([]cluster.SegConfig{segOne, segTwo, segThree})
+		counterFile = filepath.Join(GinkgoT().TempDir(), "runs")
+	})
+
+	Describe("SetCommandDeduplication", func() {
+		It("runs identical commands once per host and reports a result for every segment", func() {
+			testCluster.SetCommandDeduplication(true)
+			commandList := perSegmentCommands(func(_ int) string { return fmt.Sprintf("echo ran >> %s; echo done", counterFile) })
+
+			remoteOutput := testCluster.ExecuteClusterCommand(cluster.ON_SEGMENTS, commandList)
+			Expect(remoteOutput.NumErrors).To(Equal(0))
+			Expect(countRuns()).To(Equal(2))
+			Expect(remoteOutput.Commands).To(HaveLen(3))
+			for i, command := range remoteOutput.Commands {
+				Expect(command.Content).To(Equal(i))
+				Expect(command.Stdout).To(Equal("done\n"))
+				Expect(command.Completed).To(BeTrue())
+			}
+		})
+		It("runs commands that differ between segments on the same host", func() {
+			testCluster.SetCommandDeduplication(true)
+			commandList := perSegmentCommands(func(contentID int) string { return fmt.Sprintf("echo %d >> %s", contentID, counterFile) })
+
+			remoteOutput := testCluster.ExecuteClusterCommand(cluster.ON_SEGMENTS, commandList)
+			Expect(remoteOutput.NumErrors).To(Equal(0))
+			Expect(countRuns()).To(Equal(3))
+		})
+		It("reports the error from a deduplicated command for every segment", func() {
+			testCluster.SetCommandDeduplication(true)
+			commandList := perSegmentCommands(func(_ int) string { return fmt.Sprintf("echo ran >> %s; echo failed >&2; exit 1", counterFile) })
+
+			remoteOutput := testCluster.ExecuteClusterCommand(cluster.ON_SEGMENTS, commandList)
+			Expect(countRuns()).To(Equal(2))
+			Expect(remoteOutput.NumErrors).To(Equal(3))
+			Expect(remoteOutput.FailedCommands).To(HaveLen(3))
+			for _, command := range remoteOutput.FailedCommands {
+				Expect(command.Stderr).To(Equal("failed\n"))
+				Expect(command.Error).To(HaveOccurred())
+			}
+		})
+		It("runs every command if deduplication is disabled", func() {
+			testCluster.SetCommandDeduplication(true)
+			testCluster.SetCommandDeduplication(false)
+			commandList := perSegmentCommands(func(_ int) string { return fmt.Sprintf("echo ran >> %s", counterFile) })
+
+			remoteOutput := testCluster.ExecuteClusterCommand(cluster.ON_SEGMENTS, commandList)
+			Expect(remoteOutput.NumErrors).To(Equal(0))
+			Expect(countRuns()).To(Equal(3))
+		})
+		It("does not modify an executor that is not a GPDBExecutor", func() {
+			testExecutor := &testhelper.TestExecutor{}
+			testCluster.Executor = testExecutor
+			testCluster.SetCommandDeduplication(true)
+			Expect(testCluster.Executor).To(BeIdenticalTo(testExecutor))
+		})
+	})
+})
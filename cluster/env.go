@@ -0,0 +1,121 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster
+
+/*
+ * This file contains structs and functions for setting environment variables
+ * in the commands generated by GenerateSSHCommandList.
+ */
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/pkg/errors"
+)
+
+/*
+ * CommandEnvironment holds environment variables to be set for each command
+ * generated by GenerateSSHCommandList.
+ * - Vars are set for every command.
+ * - ForContent, if set, is called for each per-segment command.
+ * - ForHost, if set, is called for each per-host command.
+ * Variables returned by ForContent or ForHost override those in Vars.  Values
+ * are set literally, so e.g. "$HOME" will not be expanded by the shell.
+ */
+type CommandEnvironment struct {
+	Vars       map[string]string
+	ForContent func(content int) map[string]string
+	ForHost    func(host string) map[string]string
+}
+
+var envNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+/*
+ * EnvironmentPrefix returns a string of the form "export A='1' B='2'; " that
+ * sets the given variables when prepended to a shell command, or an empty
+ * string if there are none.  Variables are sorted by name so that generated
+ * commands are deterministic.
+ */
+func EnvironmentPrefix(vars map[string]string) string {
+	if len(vars) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		if !envNameRegex.MatchString(name) {
+			gplog.Fatal(errors.Errorf("Invalid environment variable name %q", name), "")
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	assignments := make([]string, len(names))
+	for i, name := range names {
+		assignments[i] = fmt.Sprintf("%s=%s", name, quoteShellArg(vars[name]))
+	}
+	return fmt.Sprintf("export %s; ", strings.Join(assignments, " "))
+}
+
+func mergeEnvironment(base map[string]string, overrides map[string]string) map[string]string {
+	merged := make(map[string]string, len(base)+len(overrides))
+	for name, value := range base {
+		merged[name] = value
+	}
+	for name, value := range overrides {
+		merged[name] = value
+	}
+	return merged
+}
+
+func (env CommandEnvironment) contentPrefix(content int) string {
+	vars := env.Vars
+	if env.ForContent != nil {
+		vars = mergeEnvironment(vars, env.ForContent(content))
+	}
+	return EnvironmentPrefix(vars)
+}
+
+func (env CommandEnvironment) hostPrefix(host string) string {
+	vars := env.Vars
+	if env.ForHost != nil {
+		vars = mergeEnvironment(vars, env.ForHost(host))
+	}
+	return EnvironmentPrefix(vars)
+}
+
+/*
+ * StandardEnvironment returns a CommandEnvironment that sets GPHOME and
+ * LD_LIBRARY_PATH for every command and PGPORT to the segment's port for
+ * per-segment commands, which covers what most utilities need to run a
+ * database binary on a segment host.
+ */
+func (cluster *Cluster) StandardEnvironment(gphome string) CommandEnvironment {
+	return CommandEnvironment{
+		Vars: map[string]string{
+			"GPHOME":          gphome,
+			"LD_LIBRARY_PATH": filepath.Join(gphome, "lib"),
+		},
+		ForContent: func(content int) map[string]string {
+			return map[string]string{"PGPORT": fmt.Sprintf("%d", cluster.GetPortForContent(content))}
+		},
+	}
+}
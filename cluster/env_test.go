@@ -0,0 +1,87 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster_test
+
+import (
+	"os/user"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/env tests", func() {
+	coordinatorSeg := cluster.SegConfig{DbID: 1, ContentID: -1, Port: 5432, Hostname: "localhost", DataDir: "/data/gpseg-1", Role: "p"}
+	remoteSegOne := cluster.SegConfig{DbID: 2, ContentID: 0, Port: 20000, Hostname: "remotehost1", DataDir: "/data/gpseg0", Role: "p"}
+	var testCluster *cluster.Cluster
+
+	BeforeEach(func() {
+		operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "testUser", HomeDir: "testDir"}, nil }
+		testCluster = cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, remoteSegOne})
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("EnvironmentPrefix", func() {
+		It("returns an empty string if there are no variables", func() {
+			Expect(cluster.EnvironmentPrefix(nil)).To(Equal(""))
+		})
+		It("exports the variables in sorted order", func() {
+			prefix := cluster.EnvironmentPrefix(map[string]string{"PGPORT": "5432", "GPHOME": "/usr/local/gpdb"})
+			Expect(prefix).To(Equal("export GPHOME='/usr/local/gpdb' PGPORT='5432'; "))
+		})
+		It("quotes values containing shell metacharacters", func() {
+			prefix := cluster.EnvironmentPrefix(map[string]string{"GPHOME": "/opt/it's $HOME; rm -rf"})
+			Expect(prefix).To(Equal(`export GPHOME='/opt/it'\''s $HOME; rm -rf'; `))
+		})
+		It("panics on an invalid variable name", func() {
+			defer testhelper.ShouldPanicWithMessage(`Invalid environment variable name "BAD NAME"`)
+			cluster.EnvironmentPrefix(map[string]string{"BAD NAME": "x"})
+		})
+	})
+	Describe("GenerateSSHCommandList", func() {
+		It("does not modify commands by default", func() {
+			commandList := testCluster.GenerateSSHCommandList(cluster.ON_SEGMENTS, func(_ int) string { return "ls" })
+			Expect(commandList[0].Command.Args).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=no", "remotehost1", "ls"}))
+		})
+		It("prepends per-segment variables to segment commands", func() {
+			testCluster.Environment = testCluster.StandardEnvironment("/usr/local/gpdb")
+			commandList := testCluster.GenerateSSHCommandList(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, func(_ int) string { return "ls" })
+			Expect(commandList[0].Command.Args).To(Equal([]string{"bash", "-c", "export GPHOME='/usr/local/gpdb' LD_LIBRARY_PATH='/usr/local/gpdb/lib' PGPORT='5432'; ls"}))
+			Expect(commandList[1].Command.Args).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=no", "remotehost1", "export GPHOME='/usr/local/gpdb' LD_LIBRARY_PATH='/usr/local/gpdb/lib' PGPORT='20000'; ls"}))
+		})
+		It("prepends per-host variables to host commands", func() {
+			testCluster.Environment = cluster.CommandEnvironment{
+				Vars:    map[string]string{"GPHOME": "/usr/local/gpdb"},
+				ForHost: func(host string) map[string]string { return map[string]string{"GPHOME": "/opt/" + host} },
+			}
+			commandList := testCluster.GenerateSSHCommandList(cluster.ON_HOSTS, func(_ string) string { return "ls" })
+			Expect(commandList[0].Command.Args).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=no", "remotehost1", "export GPHOME='/opt/remotehost1'; ls"}))
+		})
+		It("sets the variables outside of any nice wrapper", func() {
+			testCluster.Environment = cluster.CommandEnvironment{Vars: map[string]string{"PGPORT": "5432"}}
+			testCluster.SetThrottle(cluster.ThrottleOptions{Nice: 5})
+			commandList := testCluster.GenerateSSHCommandList(cluster.ON_SEGMENTS, func(_ int) string { return "ls" })
+			Expect(commandList[0].Command.Args[4]).To(Equal("export PGPORT='5432'; nice -n 5 bash -c 'ls'"))
+		})
+	})
+})
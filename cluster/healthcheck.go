@@ -0,0 +1,394 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster
+
+/*
+ * This file contains structs and functions for running pre-flight health
+ * checks against every host in the cluster.
+ */
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/gpversion"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+/*
+ * A HealthCheck describes a single check to be run on each host in a cluster.
+ *
+ * Command generates the shell command to run on the given host; if it returns
+ * an empty string, the check is skipped for that host.  If RunLocally is set,
+ * the command is executed on the coordinator host instead of on the target
+ * host, for checks such as port reachability that must be tested from the
+ * coordinator's point of view.
+ *
+ * Evaluate inspects the completed command and returns nil if the check passed
+ * or an error describing the problem if it did not.
+ */
+type HealthCheck struct {
+	Name       string
+	RunLocally bool
+	Command    func(host string) string
+	Evaluate   func(result ShellCommand) error
+}
+
+type CheckResult struct {
+	Check   string
+	Host    string
+	Skipped bool
+	Err     error
+}
+
+func (result CheckResult) Passed() bool {
+	return result.Err == nil
+}
+
+/*
+ * A HealthReport stores the results of a CheckHosts call, both as a flat list
+ * ordered by check and then by host and as a map of hostname to the results
+ * for that host, for display to the user in whichever form is more suitable.
+ */
+type HealthReport struct {
+	Results   []CheckResult
+	Hostnames []string
+	ByHost    map[string][]CheckResult
+}
+
+func (report *HealthReport) Passed() bool {
+	return len(report.Failures()) == 0
+}
+
+func (report *HealthReport) Failures() []CheckResult {
+	failures := make([]CheckResult, 0)
+	for _, result := range report.Results {
+		if !result.Passed() {
+			failures = append(failures, result)
+		}
+	}
+	return failures
+}
+
+func (report *HealthReport) String() string {
+	var builder strings.Builder
+	for _, host := range report.Hostnames {
+		for _, result := range report.ByHost[host] {
+			status := "PASSED"
+			if result.Skipped {
+				status = "SKIPPED"
+			} else if !result.Passed() {
+				status = fmt.Sprintf("FAILED: %v", result.Err)
+			}
+			fmt.Fprintf(&builder, "%s: %s: %s\n", host, result.Check, status)
+		}
+	}
+	return builder.String()
+}
+
+/*
+ * CheckHosts runs each of the given checks on every host in the cluster,
+ * including the coordinator and standby hosts, and returns a report of the
+ * results.  The commands for each check are executed in parallel across all
+ * hosts, and the checks themselves are run in the order given.
+ */
+func (cluster *Cluster) CheckHosts(checks ...HealthCheck) *HealthReport {
+	report := &HealthReport{
+		Results:   make([]CheckResult, 0),
+		Hostnames: cluster.Hostnames,
+		ByHost:    make(map[string][]CheckResult, len(cluster.Hostnames)),
+	}
+	scope := ON_HOSTS | INCLUDE_COORDINATOR | INCLUDE_MIRRORS
+	for _, check := range checks {
+		commandList := make([]ShellCommand, 0)
+		skipped := make(map[string]bool)
+		for _, host := range cluster.Hostnames {
+			cmd := check.Command(host)
+			if cmd == "" {
+				skipped[host] = true
+				continue
+			}
+			useLocal := check.RunLocally || cluster.IsLocalHost(host)
+			commandList = append(commandList, NewShellCommand(scope, -2, host, ConstructSSHCommandWithOptions(useLocal, host, cmd, cluster.SSH)))
+		}
+		completed := make(map[string]ShellCommand, len(commandList))
+		if len(commandList) > 0 {
+			output := cluster.ExecuteClusterCommand(scope, commandList)
+			for _, command := range output.Commands {
+				completed[command.Host] = command
+			}
+		}
+		for _, host := range cluster.Hostnames {
+			result := CheckResult{Check: check.Name, Host: host}
+			if skipped[host] {
+				result.Skipped = true
+			} else if command, ok := completed[host]; !ok {
+				result.Err = errors.New("check was not executed")
+			} else {
+				result.Err = check.Evaluate(command)
+			}
+			report.Results = append(report.Results, result)
+			report.ByHost[host] = append(report.ByHost[host], result)
+		}
+	}
+	return report
+}
+
+/*
+ * Built-in health checks
+ */
+
+func commandError(result ShellCommand) error {
+	if result.Error == nil {
+		return nil
+	}
+	stderr := strings.TrimSpace(result.Stderr)
+	if stderr == "" {
+		return result.Error
+	}
+	return errors.Errorf("%v: %s", result.Error, stderr)
+}
+
+func quoteShellArg(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// DiskSpaceCheck verifies that the filesystem containing path has at least minFreeBytes available
+func DiskSpaceCheck(path string, minFreeBytes uint64) HealthCheck {
+	return HealthCheck{
+		Name: fmt.Sprintf("free disk space at %s", path),
+		Command: func(_ string) string {
+			return fmt.Sprintf("df -Pk %s | tail -n 1 | awk '{print $4}'", quoteShellArg(path))
+		},
+		Evaluate: func(result ShellCommand) error {
+			if err := commandError(result); err != nil {
+				return err
+			}
+			availableKB, err := strconv.ParseUint(strings.TrimSpace(result.Stdout), 10, 64)
+			if err != nil {
+				return errors.Errorf("could not parse available space %q", strings.TrimSpace(result.Stdout))
+			}
+			if availableKB*1024 < minFreeBytes {
+				return errors.Errorf("%d bytes available at %s, need at least %d", availableKB*1024, path, minFreeBytes)
+			}
+			return nil
+		},
+	}
+}
+
+// PortCheck verifies that a TCP connection can be opened from the coordinator host to the given port on each host
+func PortCheck(port int, timeoutSeconds int) HealthCheck {
+	return HealthCheck{
+		Name:       fmt.Sprintf("port %d reachable", port),
+		RunLocally: true,
+		Command: func(host string) string {
+			return fmt.Sprintf("timeout %d bash -c %s", timeoutSeconds, quoteShellArg(fmt.Sprintf("</dev/tcp/%s/%d", host, port)))
+		},
+		Evaluate: func(result ShellCommand) error {
+			if result.Error != nil {
+				return errors.Errorf("port %d is not reachable: %v", port, commandError(result))
+			}
+			return nil
+		},
+	}
+}
+
+// PostgresProcessCheck verifies that a postgres process is running for the given content, on that content's host only
+func (cluster *Cluster) PostgresProcessCheck(contentID int, role ...string) HealthCheck {
+	contentHost := cluster.GetHostForContent(contentID, role...)
+	dataDir := cluster.GetDirForContent(contentID, role...)
+	return HealthCheck{
+		Name: fmt.Sprintf("postgres running for content %d", contentID),
+		Command: func(host string) string {
+			if host != contentHost {
+				return ""
+			}
+			pattern := fmt.Sprintf("postgres.* -D %s( |$)", regexp.QuoteMeta(dataDir))
+			return fmt.Sprintf("pgrep -f -- %s", quoteShellArg(pattern))
+		},
+		Evaluate: func(result ShellCommand) error {
+			if result.Error != nil {
+				return errors.Errorf("no postgres process found for data directory %s", dataDir)
+			}
+			return nil
+		},
+	}
+}
+
+/*
+ * UlimitCheck verifies that the soft limit for the given ulimit resource flag
+ * (e.g. "-n" for open files or "-u" for user processes) is at least minimum.
+ */
+func UlimitCheck(flag string, minimum uint64) HealthCheck {
+	return HealthCheck{
+		Name: fmt.Sprintf("ulimit %s", flag),
+		Command: func(_ string) string {
+			return fmt.Sprintf("ulimit -S %s", flag)
+		},
+		Evaluate: func(result ShellCommand) error {
+			if err := commandError(result); err != nil {
+				return err
+			}
+			value := strings.TrimSpace(result.Stdout)
+			if value == "unlimited" {
+				return nil
+			}
+			limit, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return errors.Errorf("could not parse ulimit value %q", value)
+			}
+			if limit < minimum {
+				return errors.Errorf("ulimit %s is %d, need at least %d", flag, limit, minimum)
+			}
+			return nil
+		},
+	}
+}
+
+/*
+ * VersionCheck verifies that running binary with versionFlag (e.g. "postgres
+ * --version") on each host produces output containing expectedVersion, so
+ * that mismatched installations are caught before they cause problems.
+ */
+func VersionCheck(binary string, versionFlag string, expectedVersion string) HealthCheck {
+	return HealthCheck{
+		Name: fmt.Sprintf("%s version", binary),
+		Command: func(_ string) string {
+			return fmt.Sprintf("%s %s", quoteShellArg(binary), versionFlag)
+		},
+		Evaluate: func(result ShellCommand) error {
+			if err := commandError(result); err != nil {
+				return err
+			}
+			actual := strings.TrimSpace(result.Stdout)
+			if !strings.Contains(actual, expectedVersion) {
+				return errors.Errorf("expected version %s, found %q", expectedVersion, actual)
+			}
+			return nil
+		},
+	}
+}
+
+/*
+ * VersionRangeCheck is like VersionCheck, but parses the output with
+ * gpversion.Parse and verifies that the version is within versionRange, e.g.
+ * ">=6.20 <7", so that hosts need not all run exactly the same build.
+ */
+func VersionRangeCheck(binary string, versionFlag string, versionRange string) HealthCheck {
+	return HealthCheck{
+		Name: fmt.Sprintf("%s version", binary),
+		Command: func(_ string) string {
+			return fmt.Sprintf("%s %s", quoteShellArg(binary), versionFlag)
+		},
+		Evaluate: func(result ShellCommand) error {
+			if err := commandError(result); err != nil {
+				return err
+			}
+			expected, err := gpversion.ParseRange(versionRange)
+			if err != nil {
+				return err
+			}
+			actual, err := gpversion.Parse(strings.TrimSpace(result.Stdout))
+			if err != nil {
+				return err
+			}
+			if !expected.Contains(actual) {
+				return errors.Errorf("expected version %s, found %s", versionRange, actual)
+			}
+			return nil
+		},
+	}
+}
+
+/*
+ * TimeSyncCheck verifies that each host's clock is synchronized by NTP, as
+ * operating.CheckNTPSync checks locally, and is within maxSkew of the
+ * coordinator's clock, for operations such as backups whose timestamps must
+ * agree across hosts.  It is meant to be run with CheckHosts as a preflight
+ * check, on its own or with other checks.
+ *
+ * A host's clock is compared with the coordinator's by checking that the time
+ * the host reports is within maxSkew of the period from just before the
+ * commands were started to when their results are evaluated.  The check is
+ * therefore conservative: with slow SSH connections, a skew a little larger
+ * than maxSkew may go unnoticed, but a host is never wrongly failed.
+ */
+func TimeSyncCheck(maxSkew time.Duration) HealthCheck {
+	var started time.Time
+	return HealthCheck{
+		Name: "clock synchronization",
+		Command: func(_ string) string {
+			started = operating.System.Now()
+			return fmt.Sprintf("date +%%s.%%N; %s", operating.NTP_STATUS_COMMAND)
+		},
+		Evaluate: func(result ShellCommand) error {
+			evaluated := operating.System.Now()
+			timeLine, ntpOutput, _ := strings.Cut(result.Stdout, "\n")
+			hostTime, err := parseUnixTime(strings.TrimSpace(timeLine))
+			if err != nil {
+				if commandErr := commandError(result); commandErr != nil {
+					return commandErr
+				}
+				return err
+			}
+			if behind := started.Sub(hostTime); behind > maxSkew {
+				return errors.Errorf("clock is at least %s behind the coordinator's, more than the %s allowed", behind.Round(time.Millisecond), maxSkew)
+			} else if ahead := hostTime.Sub(evaluated); ahead > maxSkew {
+				return errors.Errorf("clock is at least %s ahead of the coordinator's, more than the %s allowed", ahead.Round(time.Millisecond), maxSkew)
+			}
+
+			status, err := operating.ParseNTPStatus(ntpOutput)
+			if err != nil {
+				if commandErr := commandError(result); commandErr != nil {
+					return errors.Errorf("could not determine NTP status: %v", commandErr)
+				}
+				return err
+			}
+			if !status.Synchronized {
+				return errors.Errorf("clock is not synchronized by NTP (%s)", status.Source)
+			} else if status.HasOffset && (status.Offset > maxSkew || status.Offset < -maxSkew) {
+				return errors.Errorf("clock is %s from NTP time, more than the %s allowed", status.Offset, maxSkew)
+			}
+			return nil
+		},
+	}
+}
+
+// parseUnixTime parses the output of "date +%s.%N"
+func parseUnixTime(value string) (time.Time, error) {
+	secondsStr, fractionStr, _ := strings.Cut(value, ".")
+	seconds, err := strconv.ParseInt(secondsStr, 10, 64)
+	if err != nil {
+		return time.Time{}, errors.Errorf("could not parse host time %q", value)
+	}
+	nanoseconds := int64(0)
+	if fractionStr != "" {
+		if len(fractionStr) > 9 {
+			fractionStr = fractionStr[:9]
+		}
+		nanoseconds, err = strconv.ParseInt(fractionStr+strings.Repeat("0", 9-len(fractionStr)), 10, 64)
+		if err != nil {
+			return time.Time{}, errors.Errorf("could not parse host time %q", value)
+		}
+	}
+	return time.Unix(seconds, nanoseconds), nil
+}
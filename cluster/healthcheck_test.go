@@ -0,0 +1,211 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster_test
+
+import (
+	"errors"
+	"fmt"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/healthcheck tests", func() {
+	coordinatorSeg := cluster.SegConfig{DbID: 1, ContentID: -1, Port: 5432, Hostname: "localhost", DataDir: "/data/gpseg-1", Role: "p"}
+	localSegOne := cluster.SegConfig{DbID: 2, ContentID: 0, Port: 20000, Hostname: "localhost", DataDir: "/data/gpseg0", Role: "p"}
+	remoteSegOne := cluster.SegConfig{DbID: 3, ContentID: 1, Port: 20001, Hostname: "remotehost1", DataDir: "/data/gpseg1", Role: "p"}
+	echoCheck := cluster.HealthCheck{
+		Name:    "echo",
+		Command: func(host string) string { return "echo " + host },
+		Evaluate: func(result cluster.ShellCommand) error {
+			if result.Error != nil {
+				return result.Error
+			}
+			return nil
+		},
+	}
+
+	BeforeEach(func() {
+		operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "testUser", HomeDir: "testDir"}, nil }
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("CheckHosts", func() {
+		It("runs each check on the local host and reports the results", func() {
+			testCluster := cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, localSegOne})
+			report := testCluster.CheckHosts(echoCheck, cluster.DiskSpaceCheck("/tmp", 1), cluster.UlimitCheck("-n", 1))
+			Expect(report.Hostnames).To(Equal([]string{"localhost"}))
+			Expect(report.Results).To(HaveLen(3))
+			Expect(report.ByHost["localhost"]).To(HaveLen(3))
+			Expect(report.Passed()).To(BeTrue())
+			Expect(report.String()).To(ContainSubstring("localhost: free disk space at /tmp: PASSED"))
+		})
+		It("reports failures per host", func() {
+			testExecutor := &testhelper.TestExecutor{}
+			testCluster := cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, remoteSegOne})
+			testCluster.Executor = testExecutor
+			commands := []cluster.ShellCommand{
+				{Host: "localhost", Stdout: "localhost\n"},
+				{Host: "remotehost1", Error: errors.New("exit status 255")},
+			}
+			testExecutor.ClusterOutput = cluster.NewRemoteOutput(cluster.ON_HOSTS, 1, commands)
+
+			report := testCluster.CheckHosts(echoCheck)
+			Expect(testExecutor.NumClusterExecutions).To(Equal(1))
+			Expect(testExecutor.ClusterCommands[0]).To(HaveLen(2))
+			Expect(testExecutor.ClusterCommands[0][1].CommandString).To(Equal("ssh -o StrictHostKeyChecking=no remotehost1 echo remotehost1"))
+			Expect(report.Passed()).To(BeFalse())
+			Expect(report.ByHost["localhost"][0].Passed()).To(BeTrue())
+			Expect(report.Failures()).To(Equal([]cluster.CheckResult{{Check: "echo", Host: "remotehost1", Err: errors.New("exit status 255")}}))
+		})
+		It("skips hosts for which a check generates no command", func() {
+			testExecutor := &testhelper.TestExecutor{}
+			testCluster := cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, remoteSegOne})
+			testCluster.Executor = testExecutor
+			testExecutor.ClusterOutput = cluster.NewRemoteOutput(cluster.ON_HOSTS, 1, []cluster.ShellCommand{{Host: "remotehost1", Error: errors.New("exit status 1")}})
+
+			report := testCluster.CheckHosts(testCluster.PostgresProcessCheck(1))
+			Expect(testExecutor.ClusterCommands[0]).To(HaveLen(1))
+			Expect(testExecutor.ClusterCommands[0][0].CommandString).To(ContainSubstring("pgrep -f -- 'postgres.* -D /data/gpseg1( |$)'"))
+			Expect(report.ByHost["localhost"][0].Skipped).To(BeTrue())
+			Expect(report.ByHost["localhost"][0].Passed()).To(BeTrue())
+			Expect(report.ByHost["remotehost1"][0].Err).To(MatchError("no postgres process found for data directory /data/gpseg1"))
+		})
+		It("runs local checks on the coordinator host", func() {
+			testExecutor := &testhelper.TestExecutor{ClusterOutput: cluster.NewRemoteOutput(cluster.ON_HOSTS, 0, nil)}
+			testCluster := cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, remoteSegOne})
+			testCluster.Executor = testExecutor
+
+			testCluster.CheckHosts(cluster.PortCheck(20001, 5))
+			Expect(testExecutor.ClusterCommands[0][1].CommandString).To(Equal("bash -c timeout 5 bash -c '</dev/tcp/remotehost1/20001'"))
+		})
+	})
+	Describe("Built-in checks", func() {
+		It("fails a disk space check if there is not enough space", func() {
+			check := cluster.DiskSpaceCheck("/data", 2048)
+			Expect(check.Evaluate(cluster.ShellCommand{Stdout: "1\n"})).To(MatchError("1024 bytes available at /data, need at least 2048"))
+			Expect(check.Evaluate(cluster.ShellCommand{Stdout: "2\n"})).To(Succeed())
+			Expect(check.Evaluate(cluster.ShellCommand{Stdout: "foo\n"})).To(MatchError(`could not parse available space "foo"`))
+		})
+		It("passes an unlimited ulimit check", func() {
+			check := cluster.UlimitCheck("-n", 65536)
+			Expect(check.Evaluate(cluster.ShellCommand{Stdout: "unlimited\n"})).To(Succeed())
+			Expect(check.Evaluate(cluster.ShellCommand{Stdout: "1024\n"})).To(MatchError("ulimit -n is 1024, need at least 65536"))
+		})
+		It("compares binary versions", func() {
+			check := cluster.VersionCheck("/usr/local/cloudberry/bin/postgres", "--version", "14.4")
+			Expect(check.Command("sdw1")).To(Equal("'/usr/local/cloudberry/bin/postgres' --version"))
+			Expect(check.Evaluate(cluster.ShellCommand{Stdout: "postgres (Apache Cloudberry) 14.4\n"})).To(Succeed())
+			Expect(check.Evaluate(cluster.ShellCommand{Stdout: "postgres (Apache Cloudberry) 12.12\n"})).To(MatchError(`expected version 14.4, found "postgres (Apache Cloudberry) 12.12"`))
+			Expect(check.Evaluate(cluster.ShellCommand{Error: errors.New("exit status 127"), Stderr: "not found\n"})).To(MatchError("exit status 127: not found"))
+		})
+		It("compares binary versions with a range", func() {
+			check := cluster.VersionRangeCheck("/usr/local/greenplum-db/bin/postgres", "--version", ">=6.20 <7")
+			Expect(check.Command("sdw1")).To(Equal("'/usr/local/greenplum-db/bin/postgres' --version"))
+			Expect(check.Evaluate(cluster.ShellCommand{Stdout: "postgres (Greenplum Database) 6.26.1 build commit:a1b2c3d\n"})).To(Succeed())
+			Expect(check.Evaluate(cluster.ShellCommand{Stdout: "postgres (Greenplum Database) 7.0.0-beta.1 build dev\n"})).To(MatchError("expected version >=6.20 <7, found Greenplum Database 7.0.0-beta.1"))
+			Expect(check.Evaluate(cluster.ShellCommand{Stdout: "command not recognized\n"})).To(MatchError(ContainSubstring(`Could not parse version string "command not recognized"`)))
+		})
+	})
+	Describe("TimeSyncCheck", func() {
+		chronySynced := `Reference ID    : C0A80101 (ntp1.example.com)
+Stratum         : 3
+System time     : 0.000250000 seconds slow of NTP time
+Last offset     : -0.000012345 seconds
+Leap status     : Normal
+`
+		chronyUnsynced := `Reference ID    : 00000000 ()
+Stratum         : 0
+System time     : 0.000000000 seconds fast of NTP time
+Leap status     : Not synchronised
+`
+		timedatectlSynced := `               Local time: Fri 2026-10-16 12:00:00 UTC
+                Time zone: Etc/UTC (UTC, +0000)
+System clock synchronized: yes
+              NTP service: active
+`
+		var (
+			now   time.Time
+			check cluster.HealthCheck
+		)
+		hostOutput := func(hostTime time.Time, ntpOutput string) cluster.ShellCommand {
+			return cluster.ShellCommand{Stdout: fmt.Sprintf("%d.%09d\n%s", hostTime.Unix(), hostTime.Nanosecond(), ntpOutput)}
+		}
+
+		BeforeEach(func() {
+			now = time.Date(2026, time.October, 16, 12, 0, 0, 0, time.UTC)
+			operating.System.Now = func() time.Time { return now }
+			check = cluster.TimeSyncCheck(time.Second)
+			Expect(check.Command("sdw1")).To(Equal("date +%s.%N; chronyc tracking 2>/dev/null || ntpq -pn 2>/dev/null || timedatectl status"))
+		})
+
+		It("passes a synchronized host within the allowed skew", func() {
+			Expect(check.Evaluate(hostOutput(now.Add(500*time.Millisecond), chronySynced))).To(Succeed())
+			Expect(check.Evaluate(hostOutput(now.Add(-500*time.Millisecond), timedatectlSynced))).To(Succeed())
+		})
+		It("allows for the time taken to run the commands", func() {
+			now = now.Add(10 * time.Second)
+			Expect(check.Evaluate(hostOutput(now.Add(-5*time.Second), chronySynced))).To(Succeed())
+		})
+		It("fails a host whose clock is too far from the coordinator's", func() {
+			Expect(check.Evaluate(hostOutput(now.Add(-3*time.Second), chronySynced))).To(MatchError("clock is at least 3s behind the coordinator's, more than the 1s allowed"))
+			Expect(check.Evaluate(hostOutput(now.Add(2500*time.Millisecond), chronySynced))).To(MatchError("clock is at least 2.5s ahead of the coordinator's, more than the 1s allowed"))
+		})
+		It("fails a host whose clock is not synchronized by NTP", func() {
+			Expect(check.Evaluate(hostOutput(now, chronyUnsynced))).To(MatchError("clock is not synchronized by NTP (chrony)"))
+			Expect(check.Evaluate(hostOutput(now, "NTP synchronized: no\n"))).To(MatchError("clock is not synchronized by NTP (timedatectl)"))
+		})
+		It("fails a host whose clock is too far from NTP time", func() {
+			chronyOffset := strings.Replace(chronySynced, "0.000250000 seconds slow", "1.500000000 seconds fast", 1)
+			Expect(check.Evaluate(hostOutput(now, chronyOffset))).To(MatchError("clock is 1.5s from NTP time, more than the 1s allowed"))
+		})
+		It("fails a host where the NTP status cannot be determined", func() {
+			result := hostOutput(now, "bash: timedatectl: command not found\n")
+			result.Error = errors.New("exit status 127")
+			Expect(check.Evaluate(result)).To(MatchError("could not determine NTP status: exit status 127"))
+		})
+		It("fails a host where the time cannot be determined", func() {
+			Expect(check.Evaluate(cluster.ShellCommand{Error: errors.New("exit status 255"), Stderr: "ssh: connect to host sdw1 port 22: Connection refused\n"})).To(MatchError("exit status 255: ssh: connect to host sdw1 port 22: Connection refused"))
+			Expect(check.Evaluate(cluster.ShellCommand{Stdout: "%s.%N\n"})).To(MatchError(`could not parse host time "%s.%N"`))
+		})
+		It("reports a single verdict for the cluster with CheckHosts", func() {
+			testExecutor := &testhelper.TestExecutor{}
+			testCluster := cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, remoteSegOne})
+			testCluster.Executor = testExecutor
+			localOutput := hostOutput(now, chronySynced)
+			localOutput.Host = "localhost"
+			remoteOutput := hostOutput(now.Add(-time.Minute), chronySynced)
+			remoteOutput.Host = "remotehost1"
+			testExecutor.ClusterOutput = cluster.NewRemoteOutput(cluster.ON_HOSTS, 0, []cluster.ShellCommand{localOutput, remoteOutput})
+
+			report := testCluster.CheckHosts(check)
+			Expect(report.Passed()).To(BeFalse())
+			Expect(report.String()).To(Equal("localhost: clock synchronization: PASSED\n" +
+				"remotehost1: clock synchronization: FAILED: clock is at least 1m0s behind the coordinator's, more than the 1s allowed\n"))
+		})
+	})
+})
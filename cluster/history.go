@@ -0,0 +1,280 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster
+
+/*
+ * This file contains structs and functions for keeping a history of cluster
+ * command results in a local file, so that hosts that fail repeatedly across
+ * many runs of a maintenance operation can be found.
+ */
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/iohelper"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+// HostRunResult counts the commands run on one host in one run
+type HostRunResult struct {
+	Host     string `json:"host"`
+	Commands int    `json:"commands"`
+	Failed   int    `json:"failed"`
+	Retried  int    `json:"retried"`
+	Canceled int    `json:"canceled"`
+}
+
+/*
+ * A RunRecord summarizes one RemoteOutput.  Operation describes what the
+ * commands did; CheckClusterError uses the error message it was given.
+ */
+type RunRecord struct {
+	Time        time.Time       `json:"time"`
+	Operation   string          `json:"operation"`
+	Scope       Scope           `json:"scope"`
+	NumCommands int             `json:"num_commands"`
+	NumErrors   int             `json:"num_errors"`
+	Hosts       []HostRunResult `json:"hosts"`
+}
+
+// NewRunRecord summarizes remoteOutput by host, timestamped with operating.System.Now
+func (cluster *Cluster) NewRunRecord(operation string, remoteOutput *RemoteOutput) RunRecord {
+	record := RunRecord{
+		Time:        operating.System.Now(),
+		Operation:   operation,
+		Scope:       remoteOutput.Scope,
+		NumCommands: len(remoteOutput.Commands),
+		NumErrors:   remoteOutput.NumErrors,
+		Hosts:       make([]HostRunResult, 0),
+	}
+	byHost := make(map[string]*HostRunResult)
+	for _, command := range remoteOutput.Commands {
+		host := cluster.commandHost(command)
+		result, ok := byHost[host]
+		if !ok {
+			result = &HostRunResult{Host: host}
+			byHost[host] = result
+		}
+		result.Commands++
+		if command.Error != nil {
+			result.Failed++
+		} else if command.RetryError != nil {
+			result.Retried++
+		}
+		if command.Canceled {
+			result.Canceled++
+		}
+	}
+	for _, result := range byHost {
+		record.Hosts = append(record.Hosts, *result)
+	}
+	sort.Slice(record.Hosts, func(i, j int) bool {
+		return record.Hosts[i].Host < record.Hosts[j].Host
+	})
+	return record
+}
+
+/*
+ * A ResultsStore keeps RunRecords in a file with one JSON record per line.
+ * Records are appended with a single write each, so several utilities may
+ * record runs in the same file; a partly written line left by a crash is
+ * skipped with a warning when the file is read.
+ */
+type ResultsStore struct {
+	Path  string
+	mutex sync.Mutex
+}
+
+func NewResultsStore(path string) *ResultsStore {
+	return &ResultsStore{Path: path}
+}
+
+func (store *ResultsStore) Record(record RunRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "Unable to record cluster command results")
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	historyFile, err := iohelper.OpenFileForAppending(store.Path)
+	if err != nil {
+		return errors.Wrap(err, "Unable to record cluster command results")
+	}
+	_, err = historyFile.Write(append(line, '\n'))
+	closeErr := historyFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return errors.Wrapf(err, "Unable to record cluster command results in %s", store.Path)
+}
+
+// Runs returns the records of the runs at or after since, oldest first; a missing file has no runs
+func (store *ResultsStore) Runs(since time.Time) ([]RunRecord, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return store.readRuns(since)
+}
+
+func (store *ResultsStore) readRuns(since time.Time) ([]RunRecord, error) {
+	contents, err := operating.System.ReadFile(store.Path)
+	if operating.System.IsNotExist(err) {
+		return []RunRecord{}, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read cluster command results from %s", store.Path)
+	}
+	records := make([]RunRecord, 0)
+	for i, line := range bytes.Split(contents, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var record RunRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			gplog.Warn("Skipping invalid record on line %d of %s: %v", i+1, store.Path, err)
+			continue
+		}
+		if !record.Time.Before(since) {
+			records = append(records, record)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+	return records, nil
+}
+
+/*
+ * Prune removes the records of runs before the given time, rewriting the file
+ * and replacing the original with it so that the history is never lost if
+ * the utility is interrupted.
+ *
+ * Calls through the same ResultsStore are serialized, but nothing stops
+ * another process from appending to the file while Prune rewrites it, and a
+ * record appended after Prune reads the file is lost when the rewritten file
+ * replaces it.  Prune must therefore not run while any other utility may be
+ * recording runs in the same file.
+ */
+func (store *ResultsStore) Prune(before time.Time) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	records, err := store.readRuns(before)
+	if err != nil {
+		return err
+	}
+	var contents bytes.Buffer
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return errors.Wrap(err, "Unable to prune cluster command results")
+		}
+		contents.Write(append(line, '\n'))
+	}
+	tempPath := store.Path + ".tmp"
+	tempFile, err := iohelper.OpenFileForWriting(tempPath)
+	if err != nil {
+		return errors.Wrap(err, "Unable to prune cluster command results")
+	}
+	_, err = tempFile.Write(contents.Bytes())
+	closeErr := tempFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = operating.System.Rename(tempPath, store.Path)
+	}
+	if err != nil {
+		_ = operating.System.Remove(tempPath)
+		return errors.Wrapf(err, "Unable to prune cluster command results in %s", store.Path)
+	}
+	return nil
+}
+
+// HostHistory summarizes the results of every recorded run on one host
+type HostHistory struct {
+	Host           string
+	Runs           int
+	FailedRuns     int
+	FailedCommands int
+	LastFailure    time.Time
+}
+
+/*
+ * HostFailures summarizes each host's results in the runs at or after since,
+ * with the hosts that failed in the most runs first, e.g.
+ *
+ *   store.HostFailures(operating.System.Now().AddDate(0, 0, -30))
+ *
+ * for the hosts that failed most often in the last 30 days.  Hosts that never
+ * failed are listed last.
+ */
+func (store *ResultsStore) HostFailures(since time.Time) ([]HostHistory, error) {
+	records, err := store.Runs(since)
+	if err != nil {
+		return nil, err
+	}
+	byHost := make(map[string]*HostHistory)
+	for _, record := range records {
+		for _, result := range record.Hosts {
+			history, ok := byHost[result.Host]
+			if !ok {
+				history = &HostHistory{Host: result.Host}
+				byHost[result.Host] = history
+			}
+			history.Runs++
+			if result.Failed > 0 {
+				history.FailedRuns++
+				history.FailedCommands += result.Failed
+				history.LastFailure = record.Time
+			}
+		}
+	}
+	histories := make([]HostHistory, 0, len(byHost))
+	for _, history := range byHost {
+		histories = append(histories, *history)
+	}
+	sort.Slice(histories, func(i, j int) bool {
+		if histories[i].FailedRuns != histories[j].FailedRuns {
+			return histories[i].FailedRuns > histories[j].FailedRuns
+		}
+		if histories[i].FailedCommands != histories[j].FailedCommands {
+			return histories[i].FailedCommands > histories[j].FailedCommands
+		}
+		return histories[i].Host < histories[j].Host
+	})
+	return histories, nil
+}
+
+/*
+ * recordRun records remoteOutput in ErrorReporting.HistoryFile, if set.  A
+ * failure to record it is logged as a warning, as the results of the commands
+ * matter more than their history.
+ */
+func (cluster *Cluster) recordRun(remoteOutput *RemoteOutput, operation string) {
+	if cluster.ErrorReporting.HistoryFile == "" {
+		return
+	}
+	store := NewResultsStore(cluster.ErrorReporting.HistoryFile)
+	if err := store.Record(cluster.NewRunRecord(operation, remoteOutput)); err != nil {
+		gplog.Warn("%v", err)
+	}
+}
@@ -0,0 +1,215 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/history tests", func() {
+	var (
+		testCluster *cluster.Cluster
+		savedLogger *gplog.GpLogger
+		testLogfile *gbytes.Buffer
+		historyDir  string
+		historyFile string
+		store       *cluster.ResultsStore
+		now         time.Time
+	)
+	runOn := func(failedContents ...int) *cluster.RemoteOutput {
+		commands := make([]cluster.ShellCommand, 0)
+		for content := 0; content < 4; content++ {
+			command := cluster.ShellCommand{Content: content, CommandString: "ls"}
+			for _, failed := range failedContents {
+				if failed == content {
+					command.Error = fmt.Errorf("exit status 1")
+				}
+			}
+			commands = append(commands, command)
+		}
+		numErrors := len(failedContents)
+		return cluster.NewRemoteOutput(cluster.ON_SEGMENTS, numErrors, commands)
+	}
+	recordAt := func(at time.Time, remoteOutput *cluster.RemoteOutput) {
+		operating.System.Now = func() time.Time { return at }
+		Expect(store.Record(testCluster.NewRunRecord("Failed to list", remoteOutput))).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		savedLogger = gplog.GetLogger()
+		_, _, testLogfile = testhelper.SetupTestLogger()
+		now = time.Date(2017, time.January, 31, 12, 0, 0, 0, time.UTC)
+		operating.System.Now = func() time.Time { return now }
+		testCluster = cluster.NewCluster([]cluster.SegConfig{
+			{DbID: 1, ContentID: -1, Port: 5432, Hostname: "cdw", DataDir: "/data/gpseg-1", Role: "p"},
+			{DbID: 2, ContentID: 0, Port: 20000, Hostname: "sdw1", DataDir: "/data/gpseg0", Role: "p"},
+			{DbID: 3, ContentID: 1, Port: 20001, Hostname: "sdw1", DataDir: "/data/gpseg1", Role: "p"},
+			{DbID: 4, ContentID: 2, Port: 20000, Hostname: "sdw2", DataDir: "/data/gpseg2", Role: "p"},
+			{DbID: 5, ContentID: 3, Port: 20000, Hostname: "sdw3", DataDir: "/data/gpseg3", Role: "p"},
+		})
+		var err error
+		historyDir, err = os.MkdirTemp("", "cluster_history")
+		Expect(err).ToNot(HaveOccurred())
+		historyFile = filepath.Join(historyDir, "history.jsonl")
+		store = cluster.NewResultsStore(historyFile)
+	})
+	AfterEach(func() {
+		gplog.SetLogger(savedLogger)
+		operating.System = operating.InitializeSystemFunctions()
+		os.RemoveAll(historyDir)
+	})
+
+	Describe("NewRunRecord", func() {
+		It("summarizes the commands by host", func() {
+			remoteOutput := runOn(0, 2)
+			remoteOutput.Commands[3].RetryError = fmt.Errorf("attempt 1: exit status 1")
+			record := testCluster.NewRunRecord("Failed to list", remoteOutput)
+			Expect(record.Time).To(Equal(now))
+			Expect(record.Operation).To(Equal("Failed to list"))
+			Expect(record.Scope).To(Equal(cluster.ON_SEGMENTS))
+			Expect(record.NumCommands).To(Equal(4))
+			Expect(record.NumErrors).To(Equal(2))
+			Expect(record.Hosts).To(Equal([]cluster.HostRunResult{
+				{Host: "sdw1", Commands: 2, Failed: 1},
+				{Host: "sdw2", Commands: 1, Failed: 1},
+				{Host: "sdw3", Commands: 1, Retried: 1},
+			}))
+		})
+		It("counts every command on a host when its commands are interleaved with other hosts", func() {
+			commands := []cluster.ShellCommand{
+				{Content: 0, CommandString: "ls"},
+				{Content: 2, CommandString: "ls"},
+				{Content: 1, CommandString: "ls", Error: fmt.Errorf("exit status 1")},
+				{Content: 2, CommandString: "ls"},
+				{Content: 3, CommandString: "ls"},
+				{Content: 0, CommandString: "ls", Error: fmt.Errorf("exit status 1")},
+			}
+			record := testCluster.NewRunRecord("Failed to list", cluster.NewRemoteOutput(cluster.ON_SEGMENTS, 2, commands))
+			Expect(record.Hosts).To(Equal([]cluster.HostRunResult{
+				{Host: "sdw1", Commands: 3, Failed: 2},
+				{Host: "sdw2", Commands: 2},
+				{Host: "sdw3", Commands: 1},
+			}))
+		})
+	})
+	Describe("ResultsStore", func() {
+		It("returns no runs if the file does not exist", func() {
+			runs, err := store.Runs(time.Time{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(runs).To(BeEmpty())
+		})
+		It("returns the recorded runs since the given time", func() {
+			recordAt(now.AddDate(0, 0, -40), runOn(0))
+			recordAt(now.AddDate(0, 0, -10), runOn(2))
+			recordAt(now, runOn())
+
+			runs, err := store.Runs(now.AddDate(0, 0, -30))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(runs).To(HaveLen(2))
+			Expect(runs[0].Time.Equal(now.AddDate(0, 0, -10))).To(BeTrue())
+			Expect(runs[0].Hosts[1]).To(Equal(cluster.HostRunResult{Host: "sdw2", Commands: 1, Failed: 1}))
+			Expect(runs[1].NumErrors).To(Equal(0))
+		})
+		It("skips invalid lines with a warning", func() {
+			recordAt(now, runOn(0))
+			appendFile, err := os.OpenFile(historyFile, os.O_APPEND|os.O_WRONLY, 0644)
+			Expect(err).ToNot(HaveOccurred())
+			_, _ = appendFile.WriteString("{\"time\": \"2017-01\n")
+			appendFile.Close()
+			recordAt(now, runOn(1))
+
+			runs, err := store.Runs(time.Time{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(runs).To(HaveLen(2))
+			Expect(testLogfile).To(gbytes.Say(`Skipping invalid record on line 2 of .*history.jsonl`))
+		})
+		It("prunes runs before the given time", func() {
+			recordAt(now.AddDate(0, 0, -40), runOn(0))
+			recordAt(now.AddDate(0, 0, -10), runOn(2))
+
+			Expect(store.Prune(now.AddDate(0, 0, -30))).To(Succeed())
+			runs, err := store.Runs(time.Time{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(runs).To(HaveLen(1))
+			Expect(runs[0].Time.Equal(now.AddDate(0, 0, -10))).To(BeTrue())
+			Expect(historyFile + ".tmp").ToNot(BeAnExistingFile())
+		})
+		It("returns an error if the file cannot be written", func() {
+			store = cluster.NewResultsStore("/nonexistent/directory/history.jsonl")
+			err := store.Record(testCluster.NewRunRecord("Failed to list", runOn()))
+			Expect(err).To(MatchError(ContainSubstring("Unable to record cluster command results")))
+		})
+	})
+	Describe("HostFailures", func() {
+		It("lists the hosts that failed in the most runs first", func() {
+			recordAt(now.AddDate(0, 0, -40), runOn(3))
+			recordAt(now.AddDate(0, 0, -40), runOn(3))
+			recordAt(now.AddDate(0, 0, -20), runOn(0, 1))
+			recordAt(now.AddDate(0, 0, -10), runOn(2))
+			recordAt(now.AddDate(0, 0, -5), runOn(2))
+
+			histories, err := store.HostFailures(now.AddDate(0, 0, -30))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(histories).To(HaveLen(3))
+			Expect(histories[0].Host).To(Equal("sdw2"))
+			Expect(histories[0].Runs).To(Equal(3))
+			Expect(histories[0].FailedRuns).To(Equal(2))
+			Expect(histories[0].FailedCommands).To(Equal(2))
+			Expect(histories[0].LastFailure.Equal(now.AddDate(0, 0, -5))).To(BeTrue())
+			Expect(histories[1].Host).To(Equal("sdw1"))
+			Expect(histories[1].FailedRuns).To(Equal(1))
+			Expect(histories[1].FailedCommands).To(Equal(2))
+			Expect(histories[2].Host).To(Equal("sdw3"))
+			Expect(histories[2].FailedRuns).To(Equal(0))
+			Expect(histories[2].LastFailure.IsZero()).To(BeTrue())
+		})
+	})
+	Describe("CheckClusterErrorWithReport", func() {
+		messageFunc := func(contentID int) string { return "Unable to list" }
+
+		It("records successful and failed runs if HistoryFile is set", func() {
+			testCluster.ErrorReporting = cluster.ErrorReportOptions{HistoryFile: historyFile}
+			testCluster.CheckClusterErrorWithReport(runOn(), "Failed to list", messageFunc, true)
+			testCluster.CheckClusterErrorWithReport(runOn(1), "Failed to list", messageFunc, true)
+
+			runs, err := store.Runs(time.Time{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(runs).To(HaveLen(2))
+			Expect(runs[0].NumErrors).To(Equal(0))
+			Expect(runs[1].NumErrors).To(Equal(1))
+			Expect(runs[1].Operation).To(Equal("Failed to list"))
+		})
+		It("warns rather than failing if the run cannot be recorded", func() {
+			testCluster.ErrorReporting = cluster.ErrorReportOptions{HistoryFile: "/nonexistent/directory/history.jsonl"}
+			testCluster.CheckClusterErrorWithReport(runOn(), "Failed to list", messageFunc, true)
+			Expect(testLogfile).To(gbytes.Say(`\[WARNING\]:-Unable to record cluster command results`))
+		})
+	})
+})
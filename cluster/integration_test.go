@@ -0,0 +1,62 @@
+//go:build integration
+
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster_test
+
+import (
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/dbconn"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster integration tests", Ordered, Label("integration"), func() {
+	var integration *testhelper.IntegrationCluster
+
+	BeforeAll(func() {
+		integration = testhelper.SetupIntegrationCluster(testhelper.IntegrationOptions{})
+	})
+
+	It("reads the segment configuration of the running cluster", func() {
+		Expect(integration.Cluster.ContentIDs).To(ContainElement(-1))
+		Expect(integration.Cluster.GetHostForContent(-1)).ToNot(BeEmpty())
+		Expect(len(integration.Cluster.ContentIDs)).To(BeNumerically(">", 1))
+	})
+	It("runs a command in each segment's data directory", func() {
+		remoteOutput := integration.Cluster.GenerateAndExecuteCommand("Checking data directories", cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, func(contentID int) string {
+			return "test -f " + integration.Cluster.GetDirForContent(contentID) + "/PG_VERSION"
+		})
+		integration.Cluster.CheckClusterError(remoteOutput, "Unable to find data directories", func(contentID int) string {
+			return "Missing PG_VERSION for segment " + integration.Cluster.GetDirForContent(contentID)
+		})
+	})
+	It("measures a heap table", func() {
+		connection := integration.Connection
+		connection.MustExec("DROP TABLE IF EXISTS public.integration_size; CREATE TABLE public.integration_size AS SELECT generate_series(1, 1000) AS i")
+		defer connection.MustExec("DROP TABLE public.integration_size")
+		connection.MustExec("ANALYZE public.integration_size")
+
+		size := dbconn.MustGetTableSize(connection, "public.integration_size")
+		Expect(size.Storage).To(Equal(dbconn.HeapStorage))
+		Expect(size.Tuples).To(Equal(int64(1000)))
+		Expect(size.DataBytes).To(BeNumerically(">", 0))
+	})
+})
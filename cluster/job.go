@@ -0,0 +1,216 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster
+
+/*
+ * This file contains structs and functions for managing long-running commands
+ * on remote hosts, such as helper agents, that need to keep running after the
+ * ssh session that started them ends.
+ */
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+/*
+ * A RemoteJob identifies a detached command on a host.  The job's process id,
+ * combined stdout and stderr, and exit code are written to files named after
+ * the job in Dir on that host, so a job can be polled, attached to, or killed
+ * by a different process than the one that started it, as long as it uses the
+ * same name and directory.
+ */
+type RemoteJob struct {
+	Name string
+	Host string
+	Dir  string
+}
+
+type JobState int
+
+const (
+	JOB_NOT_RUNNING JobState = iota // The job was killed, or never started
+	JOB_RUNNING
+	JOB_EXITED
+)
+
+type JobStatus struct {
+	Job      *RemoteJob
+	State    JobState
+	PID      int
+	ExitCode int
+	Err      error
+}
+
+func NewRemoteJobs(name string, dir string, hosts []string) []*RemoteJob {
+	jobs := make([]*RemoteJob, len(hosts))
+	for i, host := range hosts {
+		jobs[i] = &RemoteJob{Name: name, Host: host, Dir: dir}
+	}
+	return jobs
+}
+
+func (job *RemoteJob) PIDFile() string {
+	return filepath.Join(job.Dir, job.Name+".pid")
+}
+
+func (job *RemoteJob) OutputFile() string {
+	return filepath.Join(job.Dir, job.Name+".out")
+}
+
+func (job *RemoteJob) ExitFile() string {
+	return filepath.Join(job.Dir, job.Name+".exit")
+}
+
+// runJobCommands runs one command per job, returning the completed commands in the same order as jobs
+func (cluster *Cluster) runJobCommands(jobs []*RemoteJob, generator func(job *RemoteJob) string) []ShellCommand {
+	scope := ON_HOSTS | INCLUDE_COORDINATOR | INCLUDE_MIRRORS
+	commandList := make([]ShellCommand, len(jobs))
+	for i, job := range jobs {
+		sshCommand := ConstructSSHCommandWithOptions(cluster.IsLocalHost(job.Host), job.Host, generator(job), cluster.SSH)
+		commandList[i] = NewShellCommand(scope, -2, job.Host, sshCommand)
+	}
+	return cluster.ExecuteClusterCommand(scope, commandList).Commands
+}
+
+/*
+ * StartRemoteJobs starts the command returned by generator for each job in a
+ * new session with nohup, so that it survives the end of the ssh session, and
+ * returns after all jobs have been started.  Any files left by a previous run
+ * of a job with the same name are removed.  The returned map contains an
+ * error for each job that could not be started.
+ */
+func (cluster *Cluster) StartRemoteJobs(jobs []*RemoteJob, generator func(job *RemoteJob) string) map[*RemoteJob]error {
+	commands := cluster.runJobCommands(jobs, func(job *RemoteJob) string {
+		wrapper := fmt.Sprintf("bash -c %s; echo $? > %s", quoteShellArg(generator(job)), quoteShellArg(job.ExitFile()))
+		return fmt.Sprintf("mkdir -p %[1]s && rm -f %[2]s %[3]s && (setsid nohup bash -c %[4]s > %[5]s 2>&1 < /dev/null & echo $! > %[3]s)",
+			quoteShellArg(job.Dir), quoteShellArg(job.ExitFile()), quoteShellArg(job.PIDFile()), quoteShellArg(wrapper), quoteShellArg(job.OutputFile()))
+	})
+	errs := make(map[*RemoteJob]error)
+	for i, job := range jobs {
+		if err := commandError(commands[i]); err != nil {
+			errs[job] = errors.Wrapf(err, "Unable to start job %s on host %s", job.Name, job.Host)
+		}
+	}
+	return errs
+}
+
+func (cluster *Cluster) PollRemoteJobs(jobs []*RemoteJob) []JobStatus {
+	commands := cluster.runJobCommands(jobs, func(job *RemoteJob) string {
+		return fmt.Sprintf(`if [ -f %[1]s ]; then echo "exited $(cat %[1]s)"; elif [ -f %[2]s ] && kill -0 "$(cat %[2]s)" 2>/dev/null; then echo "running $(cat %[2]s)"; else echo "stopped"; fi`,
+			quoteShellArg(job.ExitFile()), quoteShellArg(job.PIDFile()))
+	})
+	statuses := make([]JobStatus, len(jobs))
+	for i, job := range jobs {
+		statuses[i] = parseJobStatus(job, commands[i])
+	}
+	return statuses
+}
+
+func parseJobStatus(job *RemoteJob, command ShellCommand) JobStatus {
+	status := JobStatus{Job: job, ExitCode: -1}
+	if err := commandError(command); err != nil {
+		status.Err = errors.Wrapf(err, "Unable to check status of job %s on host %s", job.Name, job.Host)
+		return status
+	}
+	fields := strings.Fields(command.Stdout)
+	var err error
+	switch {
+	case len(fields) == 2 && fields[0] == "exited":
+		status.State = JOB_EXITED
+		status.ExitCode, err = strconv.Atoi(fields[1])
+	case len(fields) == 2 && fields[0] == "running":
+		status.State = JOB_RUNNING
+		status.PID, err = strconv.Atoi(fields[1])
+	case len(fields) == 1 && fields[0] == "stopped":
+		status.State = JOB_NOT_RUNNING
+	default:
+		err = errors.New("unexpected output")
+	}
+	if err != nil {
+		status.Err = errors.Errorf("Unable to parse status of job %s on host %s: %q", job.Name, job.Host, command.Stdout)
+	}
+	return status
+}
+
+/*
+ * ReadRemoteJobOutput returns the job's output starting at byte offset, along
+ * with the offset to pass to the next call to read only new output.
+ */
+func (cluster *Cluster) ReadRemoteJobOutput(job *RemoteJob, offset int64) (string, int64, error) {
+	commands := cluster.runJobCommands([]*RemoteJob{job}, func(job *RemoteJob) string {
+		return fmt.Sprintf("tail -c +%d %s", offset+1, quoteShellArg(job.OutputFile()))
+	})
+	if err := commandError(commands[0]); err != nil {
+		return "", offset, errors.Wrapf(err, "Unable to read output of job %s on host %s", job.Name, job.Host)
+	}
+	return commands[0].Stdout, offset + int64(len(commands[0].Stdout)), nil
+}
+
+/*
+ * AttachRemoteJob copies the job's output to writer as it is produced, polling
+ * every pollInterval, and returns the job's status once it is no longer
+ * running.
+ */
+func (cluster *Cluster) AttachRemoteJob(job *RemoteJob, writer io.Writer, pollInterval time.Duration) (JobStatus, error) {
+	var offset int64
+	for {
+		// Check the status before reading, so that all output is read once the job has finished
+		status := cluster.PollRemoteJobs([]*RemoteJob{job})[0]
+		if status.Err != nil {
+			return status, status.Err
+		}
+		output, newOffset, err := cluster.ReadRemoteJobOutput(job, offset)
+		if err != nil {
+			return status, err
+		}
+		offset = newOffset
+		if _, err = io.WriteString(writer, output); err != nil {
+			return status, err
+		}
+		if status.State != JOB_RUNNING {
+			return status, nil
+		}
+		operating.System.Sleep(pollInterval)
+	}
+}
+
+/*
+ * KillRemoteJobs sends signal (e.g. "TERM" or "KILL") to every process in each
+ * job's session.  Jobs that are not running are ignored.
+ */
+func (cluster *Cluster) KillRemoteJobs(jobs []*RemoteJob, signal string) map[*RemoteJob]error {
+	commands := cluster.runJobCommands(jobs, func(job *RemoteJob) string {
+		return fmt.Sprintf(`if [ -f %[1]s ] && kill -0 "$(cat %[1]s)" 2>/dev/null; then kill -s %[2]s -- -"$(cat %[1]s)"; fi`,
+			quoteShellArg(job.PIDFile()), quoteShellArg(signal))
+	})
+	errs := make(map[*RemoteJob]error)
+	for i, job := range jobs {
+		if err := commandError(commands[i]); err != nil {
+			errs[job] = errors.Wrapf(err, "Unable to kill job %s on host %s", job.Name, job.Host)
+		}
+	}
+	return errs
+}
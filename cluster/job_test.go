@@ -0,0 +1,131 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster_test
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/job tests", func() {
+	var (
+		testCluster *cluster.Cluster
+		jobDir      string
+		job         *cluster.RemoteJob
+	)
+
+	BeforeEach(func() {
+		operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "testUser", HomeDir: "testDir"}, nil }
+		// All jobs run on the coordinator host, so they are executed locally
+		testCluster = cluster.NewCluster([]cluster.SegConfig{{DbID: 1, ContentID: -1, Port: 5432, Hostname: "localhost", DataDir: "/data/gpseg-1", Role: "p"}})
+		jobDir = filepath.Join(GinkgoT().TempDir(), "jobs")
+		job = cluster.NewRemoteJobs("helper", jobDir, []string{"localhost"})[0]
+	})
+	AfterEach(func() {
+		testCluster.KillRemoteJobs([]*cluster.RemoteJob{job}, "KILL")
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("NewRemoteJobs", func() {
+		It("creates one job per host with files in the job directory", func() {
+			jobs := cluster.NewRemoteJobs("helper", "/tmp/jobs", []string{"sdw1", "sdw2"})
+			Expect(jobs).To(HaveLen(2))
+			Expect(jobs[1].Host).To(Equal("sdw2"))
+			Expect(jobs[0].PIDFile()).To(Equal("/tmp/jobs/helper.pid"))
+			Expect(jobs[0].OutputFile()).To(Equal("/tmp/jobs/helper.out"))
+			Expect(jobs[0].ExitFile()).To(Equal("/tmp/jobs/helper.exit"))
+		})
+	})
+	Describe("StartRemoteJobs and PollRemoteJobs", func() {
+		It("starts a job and reports its exit code once it finishes", func() {
+			errs := testCluster.StartRemoteJobs([]*cluster.RemoteJob{job}, func(_ *cluster.RemoteJob) string { return "echo 'started'; exit 3" })
+			Expect(errs).To(BeEmpty())
+			testhelper.WaitForFile(job.ExitFile(), 5*time.Second)
+
+			statuses := testCluster.PollRemoteJobs([]*cluster.RemoteJob{job})
+			Expect(statuses).To(HaveLen(1))
+			Expect(statuses[0].Err).ToNot(HaveOccurred())
+			Expect(statuses[0].State).To(Equal(cluster.JOB_EXITED))
+			Expect(statuses[0].ExitCode).To(Equal(3))
+			output, err := os.ReadFile(job.OutputFile())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(output)).To(Equal("started\n"))
+		})
+		It("reports a running job's pid", func() {
+			testCluster.StartRemoteJobs([]*cluster.RemoteJob{job}, func(_ *cluster.RemoteJob) string { return "sleep 30" })
+			status := testCluster.PollRemoteJobs([]*cluster.RemoteJob{job})[0]
+			Expect(status.State).To(Equal(cluster.JOB_RUNNING))
+			Expect(status.PID).To(BeNumerically(">", 0))
+		})
+		It("reports a job that was never started as not running", func() {
+			status := testCluster.PollRemoteJobs([]*cluster.RemoteJob{job})[0]
+			Expect(status.Err).ToNot(HaveOccurred())
+			Expect(status.State).To(Equal(cluster.JOB_NOT_RUNNING))
+		})
+		It("returns an error for a job that could not be started", func() {
+			Expect(os.WriteFile(filepath.Join(filepath.Dir(jobDir), "jobs"), []byte{}, 0644)).To(Succeed())
+			errs := testCluster.StartRemoteJobs([]*cluster.RemoteJob{job}, func(_ *cluster.RemoteJob) string { return "true" })
+			Expect(errs).To(HaveKey(job))
+			Expect(errs[job].Error()).To(ContainSubstring("Unable to start job helper on host localhost"))
+		})
+	})
+	Describe("KillRemoteJobs", func() {
+		It("kills a running job", func() {
+			testCluster.StartRemoteJobs([]*cluster.RemoteJob{job}, func(_ *cluster.RemoteJob) string { return "sleep 30" })
+			Expect(testCluster.KillRemoteJobs([]*cluster.RemoteJob{job}, "TERM")).To(BeEmpty())
+			testhelper.WaitForCondition(func() bool {
+				return testCluster.PollRemoteJobs([]*cluster.RemoteJob{job})[0].State == cluster.JOB_NOT_RUNNING
+			}, 5*time.Second, "job to stop")
+		})
+		It("ignores a job that is not running", func() {
+			Expect(testCluster.KillRemoteJobs([]*cluster.RemoteJob{job}, "TERM")).To(BeEmpty())
+		})
+	})
+	Describe("ReadRemoteJobOutput and AttachRemoteJob", func() {
+		It("reads output starting from an offset", func() {
+			testCluster.StartRemoteJobs([]*cluster.RemoteJob{job}, func(_ *cluster.RemoteJob) string { return "echo first; echo second" })
+			testhelper.WaitForFile(job.ExitFile(), 5*time.Second)
+			output, offset, err := testCluster.ReadRemoteJobOutput(job, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(output).To(Equal("first\nsecond\n"))
+			Expect(offset).To(Equal(int64(13)))
+			output, _, err = testCluster.ReadRemoteJobOutput(job, 6)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(output).To(Equal("second\n"))
+		})
+		It("streams output until the job finishes", func() {
+			testCluster.StartRemoteJobs([]*cluster.RemoteJob{job}, func(_ *cluster.RemoteJob) string { return "echo first; sleep 0.3; echo second" })
+			buffer := gbytes.NewBuffer()
+			status, err := testCluster.AttachRemoteJob(job, buffer, 50*time.Millisecond)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(status.State).To(Equal(cluster.JOB_EXITED))
+			Expect(status.ExitCode).To(Equal(0))
+			Expect(string(buffer.Contents())).To(Equal("first\nsecond\n"))
+		})
+	})
+})
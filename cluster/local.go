@@ -0,0 +1,119 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster
+
+/*
+ * This file contains functions for detecting whether a host is the machine
+ * the utility is running on, so that commands for that host can be executed
+ * directly instead of through ssh.
+ */
+
+import (
+	"net"
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/operating"
+)
+
+/*
+ * IsLocalHost returns true if commands for host should be run directly with
+ * bash instead of through ssh.  The coordinator host is always treated as
+ * local, since utilities are expected to run there.  Unless
+ * cluster.DisableLocalExecution is set, any other host is also treated as
+ * local if it is "localhost", matches this machine's hostname, or resolves to
+ * an address of one of this machine's network interfaces, so that e.g. a
+ * single-host development cluster whose segments use a different name for
+ * the host does not need ssh access to itself.  No host, not even the
+ * coordinator host, is treated as local if cluster.SSH logs in to it as a
+ * user other than the current user, since running the command with bash
+ * would run it as the wrong user.
+ *
+ * Results are cached for the lifetime of the cluster, since resolving each
+ * host can be slow in a large cluster.
+ */
+func (cluster *Cluster) IsLocalHost(host string) bool {
+	if cluster.logsInAsOtherUser(host) {
+		return false
+	}
+	if host == cluster.GetHostForContent(-1) {
+		return true
+	}
+	if cluster.DisableLocalExecution {
+		return false
+	}
+	if cluster.localHosts != nil {
+		if isLocal, ok := cluster.localHosts.Load(host); ok {
+			return isLocal.(bool)
+		}
+	}
+	isLocal := resolvesToLocalMachine(host)
+	if cluster.localHosts != nil {
+		cluster.localHosts.Store(host, isLocal)
+	}
+	return isLocal
+}
+
+func (cluster *Cluster) logsInAsOtherUser(host string) bool {
+	if cluster.SSH.User == "" && len(cluster.SSH.HostUsers) == 0 {
+		return false
+	}
+	currentUser, err := operating.System.CurrentUser()
+	if err != nil {
+		return true
+	}
+	return cluster.SSH.UserForHost(host) != currentUser.Username
+}
+
+func resolvesToLocalMachine(host string) bool {
+	if host == "" {
+		return false
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	if hostname, err := operating.System.Hostname(); err == nil && strings.EqualFold(host, hostname) {
+		return true
+	}
+	hostAddrs := []string{host}
+	if net.ParseIP(host) == nil {
+		var err error
+		hostAddrs, err = operating.System.LookupHost(host)
+		if err != nil {
+			return false
+		}
+	}
+	interfaceAddrs, err := operating.System.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	localIPs := make([]net.IP, 0, len(interfaceAddrs))
+	for _, addr := range interfaceAddrs {
+		if ip, _, parseErr := net.ParseCIDR(addr.String()); parseErr == nil {
+			localIPs = append(localIPs, ip)
+		}
+	}
+	for _, hostAddr := range hostAddrs {
+		hostIP := net.ParseIP(hostAddr)
+		for _, localIP := range localIPs {
+			if hostIP != nil && hostIP.Equal(localIP) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster_test
+
+import (
+	"errors"
+	"os/user"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/local tests", func() {
+	coordinatorSeg := cluster.SegConfig{DbID: 1, ContentID: -1, Port: 5432, Hostname: "cdw", DataDir: "/data/gpseg-1", Role: "p"}
+	aliasSeg := cluster.SegConfig{DbID: 2, ContentID: 0, Port: 20000, Hostname: "cdw-alias", DataDir: "/data/gpseg0", Role: "p"}
+	remoteSeg := cluster.SegConfig{DbID: 3, ContentID: 1, Port: 20001, Hostname: "sdw1", DataDir: "/data/gpseg1", Role: "p"}
+	var (
+		testCluster *cluster.Cluster
+		resolver    *testhelper.FakeResolver
+	)
+
+	BeforeEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "testUser", HomeDir: "testDir"}, nil }
+		resolver = testhelper.NewFakeResolver().
+			SetHostname("devbox").
+			SetInterfaceAddrs("127.0.0.1/8", "10.0.0.5/24").
+			AddHostsFile(`
+10.0.0.5  cdw-alias  # the coordinator's name on the interconnect
+10.0.0.6  sdw1
+`)
+		resolver.Install()
+		testCluster = cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, aliasSeg, remoteSeg})
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("IsLocalHost", func() {
+		It("treats the coordinator host as local", func() {
+			Expect(testCluster.IsLocalHost("cdw")).To(BeTrue())
+		})
+		It("treats localhost and this machine's hostname as local", func() {
+			Expect(testCluster.IsLocalHost("localhost")).To(BeTrue())
+			Expect(testCluster.IsLocalHost("DEVBOX")).To(BeTrue())
+		})
+		It("treats a host resolving to a local interface address as local", func() {
+			Expect(testCluster.IsLocalHost("cdw-alias")).To(BeTrue())
+			Expect(testCluster.IsLocalHost("10.0.0.5")).To(BeTrue())
+		})
+		It("does not treat other hosts as local", func() {
+			Expect(testCluster.IsLocalHost("sdw1")).To(BeFalse())
+			Expect(testCluster.IsLocalHost("10.0.0.6")).To(BeFalse())
+			Expect(testCluster.IsLocalHost("unresolvable")).To(BeFalse())
+		})
+		It("only resolves each host once", func() {
+			testCluster.IsLocalHost("sdw1")
+			testCluster.IsLocalHost("sdw1")
+			Expect(resolver.Lookups("sdw1")).To(Equal(1))
+		})
+		It("does not treat a host as local if resolving it times out", func() {
+			resolver.FailHost("cdw-alias", testhelper.DNSTimeout("cdw-alias"))
+			Expect(testCluster.IsLocalHost("cdw-alias")).To(BeFalse())
+		})
+		It("treats a host as local if any of its addresses is local", func() {
+			resolver.AddHost("cdw-multi", "10.0.1.9", "fd00::5")
+			resolver.SetInterfaceAddrs("127.0.0.1/8", "fd00::5/64")
+			Expect(testCluster.IsLocalHost("cdw-multi")).To(BeTrue())
+			Expect(testCluster.IsLocalHost("fd00::5")).To(BeTrue())
+		})
+		It("falls back to resolving a host if this machine's hostname is unknown", func() {
+			resolver.FailHostname(errors.New("hostname unavailable"))
+			Expect(testCluster.IsLocalHost("devbox")).To(BeFalse())
+			Expect(testCluster.IsLocalHost("cdw-alias")).To(BeTrue())
+		})
+		It("does not treat any other host as local if this machine's addresses are unknown", func() {
+			resolver.FailInterfaceAddrs(errors.New("permission denied"))
+			Expect(testCluster.IsLocalHost("cdw-alias")).To(BeFalse())
+			Expect(testCluster.IsLocalHost("localhost")).To(BeTrue())
+		})
+		It("only treats the coordinator host as local if local execution is disabled", func() {
+			testCluster.DisableLocalExecution = true
+			Expect(testCluster.IsLocalHost("cdw")).To(BeTrue())
+			Expect(testCluster.IsLocalHost("cdw-alias")).To(BeFalse())
+			Expect(testCluster.IsLocalHost("localhost")).To(BeFalse())
+		})
+		It("does not treat a host as local if it is logged in to as another user", func() {
+			testCluster.SSH = cluster.SSHOptions{HostUsers: map[string]string{"cdw": "gpadmin", "cdw-alias": "testUser"}}
+			Expect(testCluster.IsLocalHost("cdw")).To(BeFalse())
+			Expect(testCluster.IsLocalHost("cdw-alias")).To(BeTrue())
+			testCluster.SSH = cluster.SSHOptions{User: "gpadmin"}
+			Expect(testCluster.IsLocalHost("cdw-alias")).To(BeFalse())
+			Expect(testCluster.IsLocalHost("localhost")).To(BeFalse())
+		})
+	})
+	Describe("GenerateSSHCommandList", func() {
+		It("runs commands for hosts that resolve to this machine without ssh", func() {
+			commandList := testCluster.GenerateSSHCommandList(cluster.ON_SEGMENTS, func(_ int) string { return "ls" })
+			Expect(commandList[0].Command.Args).To(Equal([]string{"bash", "-c", "ls"}))
+			Expect(commandList[1].Command.Args).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=no", "sdw1", "ls"}))
+		})
+		It("uses ssh for those hosts if local execution is disabled", func() {
+			testCluster.DisableLocalExecution = true
+			commandList := testCluster.GenerateSSHCommandList(cluster.ON_SEGMENTS, func(_ int) string { return "ls" })
+			Expect(commandList[0].Command.Args).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=no", "cdw-alias", "ls"}))
+		})
+		It("uses ssh for those hosts if they are logged in to as another user", func() {
+			testCluster.SSH = cluster.SSHOptions{HostUsers: map[string]string{"cdw-alias": "gpsegment"}}
+			commandList := testCluster.GenerateSSHCommandList(cluster.ON_SEGMENTS, func(_ int) string { return "ls" })
+			Expect(commandList[0].Command.Args).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=no", "gpsegment@cdw-alias", "ls"}))
+		})
+	})
+})
@@ -0,0 +1,45 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster
+
+/*
+ * This file contains the metrics recorded for cluster commands, which are
+ * published by the exporter set with metrics.SetExporter.
+ */
+
+import (
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/metrics"
+)
+
+var (
+	commandDuration = metrics.NewTimer("cluster_command_duration_seconds", "Time taken to run each cluster command, including retries, by result.")
+	commandRetries  = metrics.NewCounter("cluster_command_retries", "Cluster command attempts that failed and were retried.")
+)
+
+// observeCommand records how long a finished command took, by whether it succeeded, failed, or was canceled
+func observeCommand(command ShellCommand, duration time.Duration) {
+	result := "success"
+	if command.Canceled {
+		result = "canceled"
+	} else if command.Error != nil {
+		result = "failure"
+	}
+	commandDuration.Observe(metrics.Labels{"result": result}, duration)
+}
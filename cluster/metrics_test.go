@@ -0,0 +1,60 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster_test
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/metrics"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/metrics tests", func() {
+	var exporter *metrics.PrometheusExporter
+
+	currentMetrics := func() string {
+		var buffer bytes.Buffer
+		Expect(exporter.WriteMetrics(&buffer)).To(Succeed())
+		return buffer.String()
+	}
+
+	BeforeEach(func() {
+		exporter = metrics.NewPrometheusExporter()
+		metrics.SetExporter(exporter)
+	})
+	AfterEach(func() {
+		metrics.SetExporter(nil)
+	})
+
+	It("times each command by its result and counts retries", func() {
+		testCluster := cluster.Cluster{Executor: &cluster.GPDBExecutor{}}
+		commandList := []cluster.ShellCommand{
+			cluster.NewShellCommand(cluster.ON_SEGMENTS, 0, "", []string{"true"}),
+			cluster.NewShellCommand(cluster.ON_SEGMENTS, 1, "", []string{"true"}),
+			cluster.NewShellCommand(cluster.ON_SEGMENTS, 2, "", []string{"false"}),
+		}
+		testCluster.ExecuteClusterCommandWithRetries(cluster.ON_SEGMENTS, commandList, 3, time.Millisecond)
+		Expect(currentMetrics()).To(ContainSubstring(`cluster_command_duration_seconds_count{result="success"} 2`))
+		Expect(currentMetrics()).To(ContainSubstring(`cluster_command_duration_seconds_count{result="failure"} 1`))
+		Expect(currentMetrics()).To(ContainSubstring("cluster_command_retries_total 2\n"))
+	})
+})
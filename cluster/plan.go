@@ -0,0 +1,183 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster
+
+/*
+ * This file contains structs and functions for generating the commands for a
+ * cluster operation ahead of time, so that they can be reviewed before they
+ * are run.
+ */
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+ * A PlannedCommand is one command in an ExecutionPlan.  Args holds the command
+ * exactly as it will be run, and CommandString is the same command joined
+ * with spaces for display; ExecutePlan refuses to run a command whose Args
+ * and CommandString disagree.  Order
+ * is the position in which the command will be started; commands run in
+ * parallel, so later commands may start before earlier ones finish unless
+ * the cluster is throttled.  Content is -2 for per-host commands.
+ */
+type PlannedCommand struct {
+	Order         int      `json:"order"`
+	Content       int      `json:"content"`
+	Host          string   `json:"host"`
+	CommandString string   `json:"command"`
+	Args          []string `json:"args"`
+}
+
+type HostPlan struct {
+	Host     string           `json:"host"`
+	Commands []PlannedCommand `json:"commands"`
+}
+
+/*
+ * An ExecutionPlan describes the commands a cluster operation would run on
+ * each host, in the order in which they would be started, and how many could
+ * run at once given the cluster's throttle settings.  It can be serialized to
+ * JSON for review and is executed with ExecutePlan; since ExecutePlan only
+ * uses the contents of the plan, and checks that each command's Args match
+ * the CommandString shown by String, a plan read back from JSON runs exactly
+ * the commands that were reviewed.
+ */
+type ExecutionPlan struct {
+	Scope                Scope      `json:"scope"`
+	NumCommands          int        `json:"num_commands"`
+	MaxConcurrent        int        `json:"max_concurrent"`
+	MaxConcurrentPerHost int        `json:"max_concurrent_per_host"`
+	EstimatedConcurrency int        `json:"estimated_concurrency"`
+	Hosts                []HostPlan `json:"hosts"`
+}
+
+/*
+ * PlanCommands generates the same commands as GenerateAndExecuteCommand would
+ * run for the given scope and generator, as described for
+ * GenerateSSHCommandList, but returns them as an ExecutionPlan instead of
+ * running them.  Hosts appear in the order in which they are first targeted.
+ */
+func (cluster *Cluster) PlanCommands(scope Scope, generator interface{}) *ExecutionPlan {
+	commandList := cluster.GenerateSSHCommandList(scope, generator)
+	plan := &ExecutionPlan{
+		Scope:                scope,
+		NumCommands:          len(commandList),
+		MaxConcurrent:        cluster.Throttle.MaxConcurrent,
+		MaxConcurrentPerHost: cluster.Throttle.MaxConcurrentPerHost,
+		Hosts:                make([]HostPlan, 0),
+	}
+	hostIndexes := make(map[string]int)
+	for i, command := range commandList {
+		host := cluster.commandHost(command)
+		index, ok := hostIndexes[host]
+		if !ok {
+			index = len(plan.Hosts)
+			hostIndexes[host] = index
+			plan.Hosts = append(plan.Hosts, HostPlan{Host: host, Commands: make([]PlannedCommand, 0)})
+		}
+		plan.Hosts[index].Commands = append(plan.Hosts[index].Commands, PlannedCommand{
+			Order:         i + 1,
+			Content:       command.Content,
+			Host:          command.Host,
+			CommandString: command.CommandString,
+			Args:          command.Command.Args,
+		})
+	}
+	plan.EstimatedConcurrency = plan.estimateConcurrency()
+	return plan
+}
+
+func (plan *ExecutionPlan) estimateConcurrency() int {
+	concurrency := 0
+	for _, hostPlan := range plan.Hosts {
+		if plan.MaxConcurrentPerHost > 0 && len(hostPlan.Commands) > plan.MaxConcurrentPerHost {
+			concurrency += plan.MaxConcurrentPerHost
+		} else {
+			concurrency += len(hostPlan.Commands)
+		}
+	}
+	if plan.MaxConcurrent > 0 && concurrency > plan.MaxConcurrent {
+		concurrency = plan.MaxConcurrent
+	}
+	return concurrency
+}
+
+// Commands returns the planned commands for all hosts in the order in which they will be started
+func (plan *ExecutionPlan) Commands() []PlannedCommand {
+	commands := make([]PlannedCommand, 0, plan.NumCommands)
+	for _, hostPlan := range plan.Hosts {
+		commands = append(commands, hostPlan.Commands...)
+	}
+	sort.SliceStable(commands, func(i, j int) bool {
+		return commands[i].Order < commands[j].Order
+	})
+	return commands
+}
+
+// String formats the plan for logging, with each host's commands indented beneath it
+func (plan *ExecutionPlan) String() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "%d commands on %d hosts, up to %d at a time", plan.NumCommands, len(plan.Hosts), plan.EstimatedConcurrency)
+	for _, hostPlan := range plan.Hosts {
+		fmt.Fprintf(&builder, "\n%s:", hostPlan.Host)
+		for _, command := range hostPlan.Commands {
+			if command.Content == -2 {
+				fmt.Fprintf(&builder, "\n  %d. %s", command.Order, command.CommandString)
+			} else {
+				fmt.Fprintf(&builder, "\n  %d. [content %d] %s", command.Order, command.Content, command.CommandString)
+			}
+		}
+	}
+	return builder.String()
+}
+
+/*
+ * ExecutePlan runs the commands in the plan with the cluster's executor, with
+ * the same retries as GenerateAndExecuteCommand, and returns their results in
+ * plan order.  The cluster's current throttle settings apply, not those
+ * recorded in the plan.  It returns an error without running anything if any
+ * planned command is empty or its Args do not match its CommandString, as in
+ * a plan edited after it was reviewed.
+ */
+func (cluster *Cluster) ExecutePlan(plan *ExecutionPlan) (*RemoteOutput, error) {
+	planned := plan.Commands()
+	commandList := make([]ShellCommand, len(planned))
+	for i, command := range planned {
+		if len(command.Args) == 0 {
+			return nil, errors.Errorf("Planned command %d has no arguments", command.Order)
+		}
+		if strings.Join(command.Args, " ") != command.CommandString {
+			return nil, errors.Errorf("Planned command %d runs %q, which does not match the reviewed command %q", command.Order, strings.Join(command.Args, " "), command.CommandString)
+		}
+		commandList[i] = ShellCommand{
+			Scope:         plan.Scope,
+			Content:       command.Content,
+			Host:          command.Host,
+			Command:       exec.Command(command.Args[0], command.Args[1:]...),
+			CommandString: command.CommandString,
+		}
+	}
+	return cluster.ExecuteClusterCommandWithRetries(plan.Scope, commandList, 5, 1*time.Second), nil
+}
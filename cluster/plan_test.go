@@ -0,0 +1,123 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster_test
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/plan tests", func() {
+	coordinatorSeg := cluster.SegConfig{DbID: 1, ContentID: -1, Port: 5432, Hostname: "cdw", DataDir: "/data/gpseg-1", Role: "p"}
+	segOne := cluster.SegConfig{DbID: 2, ContentID: 0, Port: 20000, Hostname: "sdw1", DataDir: "/data/gpseg0", Role: "p"}
+	segTwo := cluster.SegConfig{DbID: 3, ContentID: 1, Port: 20001, Hostname: "sdw1", DataDir: "/data/gpseg1", Role: "p"}
+	segThree := cluster.SegConfig{DbID: 4, ContentID: 2, Port: 20000, Hostname: "sdw2", DataDir: "/data/gpseg2", Role: "p"}
+	var testCluster *cluster.Cluster
+
+	BeforeEach(func() {
+		testhelper.FreezeSystem(GinkgoT(), testhelper.FreezeOptions{})
+		testCluster = cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, segOne, segTwo, segThree})
+		testCluster.DisableLocalExecution = true
+	})
+
+	Describe("PlanCommands", func() {
+		It("groups commands by host in the order they will be started", func() {
+			plan := testCluster.PlanCommands(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, func(contentID int) string {
+				return fmt.Sprintf("ls /data/gpseg%d", contentID)
+			})
+
+			Expect(plan.NumCommands).To(Equal(4))
+			Expect(plan.EstimatedConcurrency).To(Equal(4))
+			Expect(plan.Hosts).To(HaveLen(3))
+			Expect(plan.Hosts[0].Host).To(Equal("cdw"))
+			Expect(plan.Hosts[0].Commands).To(Equal([]cluster.PlannedCommand{
+				{Order: 1, Content: -1, CommandString: "bash -c ls /data/gpseg-1", Args: []string{"bash", "-c", "ls /data/gpseg-1"}},
+			}))
+			Expect(plan.Hosts[1].Host).To(Equal("sdw1"))
+			Expect(plan.Hosts[1].Commands).To(HaveLen(2))
+			Expect(plan.Hosts[1].Commands[1]).To(Equal(cluster.PlannedCommand{Order: 3, Content: 1,
+				CommandString: "ssh -o StrictHostKeyChecking=no sdw1 ls /data/gpseg1",
+				Args:          []string{"ssh", "-o", "StrictHostKeyChecking=no", "sdw1", "ls /data/gpseg1"}}))
+			Expect(plan.Hosts[2].Host).To(Equal("sdw2"))
+		})
+		It("estimates concurrency from the cluster's throttle settings", func() {
+			testCluster.SetThrottle(cluster.ThrottleOptions{MaxConcurrentPerHost: 1})
+			plan := testCluster.PlanCommands(cluster.ON_SEGMENTS, func(contentID int) string { return "true" })
+			Expect(plan.EstimatedConcurrency).To(Equal(2))
+
+			testCluster.SetThrottle(cluster.ThrottleOptions{MaxConcurrent: 1})
+			plan = testCluster.PlanCommands(cluster.ON_SEGMENTS, func(contentID int) string { return "true" })
+			Expect(plan.EstimatedConcurrency).To(Equal(1))
+			Expect(plan.MaxConcurrent).To(Equal(1))
+		})
+		It("formats the plan for logging", func() {
+			plan := testCluster.PlanCommands(cluster.ON_HOSTS, func(host string) string { return "hostname" })
+			Expect(plan.String()).To(Equal(`2 commands on 2 hosts, up to 2 at a time
+sdw1:
+  1. ssh -o StrictHostKeyChecking=no sdw1 hostname
+sdw2:
+  2. ssh -o StrictHostKeyChecking=no sdw2 hostname`))
+		})
+	})
+	Describe("ExecutePlan", func() {
+		It("runs the commands from a plan read back from JSON in plan order", func() {
+			plan := testCluster.PlanCommands(cluster.ON_SEGMENTS|cluster.ON_LOCAL, func(contentID int) string {
+				return fmt.Sprintf("echo content %d", contentID)
+			})
+			planJSON, err := json.Marshal(plan)
+			Expect(err).ToNot(HaveOccurred())
+			var reviewedPlan cluster.ExecutionPlan
+			Expect(json.Unmarshal(planJSON, &reviewedPlan)).To(Succeed())
+
+			remoteOutput, err := testCluster.ExecutePlan(&reviewedPlan)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(remoteOutput.NumErrors).To(Equal(0))
+			Expect(remoteOutput.Commands).To(HaveLen(3))
+			for i, command := range remoteOutput.Commands {
+				Expect(command.Content).To(Equal(i))
+				Expect(command.Stdout).To(Equal(fmt.Sprintf("content %d\n", i)))
+			}
+		})
+		It("does not run anything if a planned command is empty", func() {
+			testExecutor := &testhelper.TestExecutor{}
+			testCluster.Executor = testExecutor
+			plan := testCluster.PlanCommands(cluster.ON_HOSTS, func(host string) string { return "hostname" })
+			plan.Hosts[1].Commands[0].Args = nil
+
+			_, err := testCluster.ExecutePlan(plan)
+			Expect(err).To(MatchError("Planned command 2 has no arguments"))
+			Expect(testExecutor.NumExecutions).To(Equal(0))
+		})
+		It("does not run anything if a planned command's arguments differ from the command shown for review", func() {
+			testExecutor := &testhelper.TestExecutor{}
+			testCluster.Executor = testExecutor
+			plan := testCluster.PlanCommands(cluster.ON_HOSTS, func(host string) string { return "hostname" })
+			plan.Hosts[1].Commands[0].Args = []string{"ssh", "-o", "StrictHostKeyChecking=no", "sdw2", "rm -rf /data"}
+
+			_, err := testCluster.ExecutePlan(plan)
+			Expect(err).To(MatchError(`Planned command 2 runs "ssh -o StrictHostKeyChecking=no sdw2 rm -rf /data", which does not match the reviewed command "ssh -o StrictHostKeyChecking=no sdw2 hostname"`))
+			Expect(testExecutor.NumExecutions).To(Equal(0))
+		})
+	})
+})
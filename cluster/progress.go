@@ -0,0 +1,169 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster
+
+/*
+ * This file contains structs and functions for estimating how long the rest
+ * of a batch of cluster commands will take while the batch is running.
+ */
+
+import (
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+)
+
+/*
+ * A BatchProgress is passed to GPDBExecutor.OnProgress each time a command in
+ * a batch finishes.  Throughput is in commands per second; Remaining is zero
+ * until at least one command has finished.
+ */
+type BatchProgress struct {
+	Completed  int
+	Total      int
+	Elapsed    time.Duration
+	Throughput float64
+	Remaining  time.Duration
+}
+
+/*
+ * A ProgressTracker records how long each command in a batch takes on each
+ * host and estimates the time remaining from those durations.
+ *
+ * Commands on different hosts run in parallel, so the batch finishes when
+ * its slowest host does.  Each host's remaining commands are assumed to take
+ * as long as that host's completed commands have so far, or as long as the
+ * batch average for hosts with none completed yet, and to run
+ * maxConcurrentPerHost at a time.  A limit of 0 means no limit, as in
+ * ThrottleOptions.
+ */
+type ProgressTracker struct {
+	start                time.Duration
+	total                int
+	completed            int
+	totalDuration        time.Duration
+	maxConcurrent        int
+	maxConcurrentPerHost int
+	pending              map[string]int
+	done                 map[string]int
+	durations            map[string]time.Duration
+}
+
+func NewProgressTracker(hosts []string, maxConcurrent int, maxConcurrentPerHost int, start time.Duration) *ProgressTracker {
+	tracker := &ProgressTracker{
+		start:                start,
+		total:                len(hosts),
+		maxConcurrent:        maxConcurrent,
+		maxConcurrentPerHost: maxConcurrentPerHost,
+		pending:              make(map[string]int),
+		done:                 make(map[string]int),
+		durations:            make(map[string]time.Duration),
+	}
+	for _, host := range hosts {
+		tracker.pending[host]++
+	}
+	return tracker
+}
+
+/*
+ * Complete records that a command on host finished at now after running for
+ * duration, and returns the progress of the batch as of now.  Like start,
+ * now is a reading of operating.System.MonotonicNow.
+ */
+func (tracker *ProgressTracker) Complete(host string, duration time.Duration, now time.Duration) BatchProgress {
+	tracker.completed++
+	tracker.totalDuration += duration
+	tracker.pending[host]--
+	tracker.done[host]++
+	tracker.durations[host] += duration
+
+	progress := BatchProgress{
+		Completed: tracker.completed,
+		Total:     tracker.total,
+		Elapsed:   now - tracker.start,
+	}
+	if progress.Elapsed > 0 {
+		progress.Throughput = float64(tracker.completed) / progress.Elapsed.Seconds()
+	}
+	progress.Remaining = tracker.estimateRemaining()
+	return progress
+}
+
+func batchesNeeded(commands int, limit int) int {
+	if limit <= 0 || commands <= limit {
+		return 1
+	}
+	return (commands + limit - 1) / limit
+}
+
+func (tracker *ProgressTracker) estimateRemaining() time.Duration {
+	numPending := tracker.total - tracker.completed
+	if numPending == 0 {
+		return 0
+	}
+	average := tracker.totalDuration / time.Duration(tracker.completed)
+	var remaining time.Duration
+	for host, hostPending := range tracker.pending {
+		if hostPending == 0 {
+			continue
+		}
+		hostAverage := average
+		if tracker.done[host] > 0 {
+			hostAverage = tracker.durations[host] / time.Duration(tracker.done[host])
+		}
+		hostRemaining := time.Duration(batchesNeeded(hostPending, tracker.maxConcurrentPerHost)) * hostAverage
+		if hostRemaining > remaining {
+			remaining = hostRemaining
+		}
+	}
+	// A total limit can make the batch slower than its slowest host
+	if totalRemaining := time.Duration(batchesNeeded(numPending, tracker.maxConcurrent)) * average; totalRemaining > remaining {
+		remaining = totalRemaining
+	}
+	return remaining
+}
+
+func (executor *GPDBExecutor) progressTracker(commandList []ShellCommand) *ProgressTracker {
+	if executor.OnProgress == nil {
+		return nil
+	}
+	hosts := make([]string, len(commandList))
+	for i, command := range commandList {
+		hosts[i] = executor.throttleHost(command)
+	}
+	return NewProgressTracker(hosts, executor.MaxConcurrent, executor.MaxConcurrentPerHost, operating.System.MonotonicNow())
+}
+
+/*
+ * LogProgress returns an OnProgress function that logs the progress of a
+ * batch with gplog.Progress, at most once per interval and once more when the
+ * batch finishes, so large clusters do not log a line per segment.
+ */
+func LogProgress(description string, interval time.Duration) func(BatchProgress) {
+	var lastLogged time.Duration
+	hasLogged := false
+	return func(progress BatchProgress) {
+		now := operating.System.MonotonicNow()
+		if hasLogged && progress.Completed < progress.Total && now-lastLogged < interval {
+			return
+		}
+		lastLogged, hasLogged = now, true
+		gplog.Progress(progress.Completed, progress.Total, progress.Remaining, "%s", description)
+	}
+}
@@ -0,0 +1,123 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster_test
+
+import (
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/progress tests", func() {
+	start := 10 * time.Second
+
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("ProgressTracker", func() {
+		It("reports the completed count, elapsed time, and throughput", func() {
+			tracker := cluster.NewProgressTracker([]string{"host1", "host1", "host2", "host2"}, 0, 0, start)
+			progress := tracker.Complete("host1", 2*time.Second, start+2*time.Second)
+			Expect(progress.Completed).To(Equal(1))
+			Expect(progress.Total).To(Equal(4))
+			Expect(progress.Elapsed).To(Equal(2 * time.Second))
+			Expect(progress.Throughput).To(Equal(0.5))
+		})
+		It("estimates from the slowest host", func() {
+			tracker := cluster.NewProgressTracker([]string{"fast", "fast", "fast", "slow", "slow", "slow"}, 0, 1, start)
+			tracker.Complete("fast", time.Second, start+time.Second)
+			progress := tracker.Complete("slow", 10*time.Second, start+10*time.Second)
+			Expect(progress.Remaining).To(Equal(20 * time.Second))
+		})
+		It("uses the batch average for a host with no completed commands", func() {
+			tracker := cluster.NewProgressTracker([]string{"host1", "host1", "host2", "host2", "host2"}, 0, 1, start)
+			progress := tracker.Complete("host1", 4*time.Second, start+4*time.Second)
+			Expect(progress.Remaining).To(Equal(12 * time.Second))
+		})
+		It("assumes commands on a host without a per-host limit run at once", func() {
+			tracker := cluster.NewProgressTracker([]string{"host1", "host1", "host1", "host1"}, 0, 0, start)
+			progress := tracker.Complete("host1", 3*time.Second, start+3*time.Second)
+			Expect(progress.Remaining).To(Equal(3 * time.Second))
+		})
+		It("accounts for a total limit across hosts", func() {
+			tracker := cluster.NewProgressTracker([]string{"host1", "host2", "host3", "host4", "host5"}, 2, 0, start)
+			progress := tracker.Complete("host1", time.Second, start+time.Second)
+			Expect(progress.Remaining).To(Equal(2 * time.Second))
+		})
+		It("reports no time remaining once every command has finished", func() {
+			tracker := cluster.NewProgressTracker([]string{"host1"}, 0, 0, start)
+			progress := tracker.Complete("host1", time.Second, start+time.Second)
+			Expect(progress.Completed).To(Equal(1))
+			Expect(progress.Remaining).To(Equal(time.Duration(0)))
+		})
+	})
+	Describe("LogProgress", func() {
+		var (
+			now         time.Duration
+			stdout      *gbytes.Buffer
+			savedLogger *gplog.GpLogger
+		)
+
+		BeforeEach(func() {
+			savedLogger = gplog.GetLogger()
+			stdout, _, _ = testhelper.SetupTestLogger()
+			now = start
+			operating.System.MonotonicNow = func() time.Duration { return now }
+		})
+		AfterEach(func() {
+			gplog.SetLogger(savedLogger)
+		})
+
+		It("logs at most once per interval until the batch finishes", func() {
+			logProgress := cluster.LogProgress("Starting segments", time.Minute)
+			logProgress(cluster.BatchProgress{Completed: 1, Total: 3, Remaining: 2 * time.Minute})
+			now += 30 * time.Second
+			logProgress(cluster.BatchProgress{Completed: 2, Total: 3, Remaining: time.Minute})
+			logProgress(cluster.BatchProgress{Completed: 3, Total: 3})
+			Expect(string(stdout.Contents())).To(ContainSubstring("Starting segments: 1 of 3 complete (33%), about 2m0s remaining"))
+			Expect(string(stdout.Contents())).ToNot(ContainSubstring("2 of 3"))
+			Expect(string(stdout.Contents())).To(ContainSubstring("Starting segments: 3 of 3 complete (100%)"))
+		})
+	})
+	Describe("ExecuteClusterCommand with OnProgress", func() {
+		It("reports progress once for each finished command", func() {
+			reports := make([]cluster.BatchProgress, 0)
+			executor := &cluster.GPDBExecutor{OnProgress: func(progress cluster.BatchProgress) {
+				reports = append(reports, progress)
+			}}
+			commandList := []cluster.ShellCommand{
+				cluster.NewShellCommand(cluster.ON_HOSTS, -2, "host1", []string{"bash", "-c", "true"}),
+				cluster.NewShellCommand(cluster.ON_HOSTS, -2, "host2", []string{"bash", "-c", "false"}),
+			}
+			executor.ExecuteClusterCommand(cluster.ON_HOSTS, commandList)
+			Expect(reports).To(HaveLen(2))
+			Expect(reports[0].Completed).To(Equal(1))
+			Expect(reports[1].Completed).To(Equal(2))
+			Expect(reports[1].Total).To(Equal(2))
+			Expect(reports[1].Remaining).To(Equal(time.Duration(0)))
+		})
+	})
+})
@@ -0,0 +1,179 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster
+
+/*
+ * This file contains structs and functions for reporting failed cluster
+ * commands in summarized and machine-readable forms.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/iohelper"
+	"github.com/apache/cloudberry-go-libs/operating"
+)
+
+/*
+ * ErrorReportOptions controls how CheckClusterError reports failed commands.
+ * The zero value preserves the original behavior of printing every failure.
+ *
+ * Summarize:       Print a one-line summary of the failures (counts and a few
+ *                  example hosts) to stderr, and send the per-command details
+ *                  only to the logfile.
+ * MaxExampleHosts: The number of hosts to name in the summary; defaults to 3.
+ * ReportDir:       If set, write a JSON report of every failed and retried
+ *                  command to a timestamped file in this directory.
+ * HistoryFile:     If set, record a summary of every run checked, including
+ *                  successful ones, in this file; see history.go.
+ */
+type ErrorReportOptions struct {
+	Summarize       bool
+	MaxExampleHosts int
+	ReportDir       string
+	HistoryFile     string
+}
+
+type CommandReport struct {
+	Content       int    `json:"content"`
+	Host          string `json:"host"`
+	CommandString string `json:"command"`
+	Error         string `json:"error"`
+	Stderr        string `json:"stderr,omitempty"`
+}
+
+type ClusterErrorReport struct {
+	Message     string          `json:"message"`
+	Scope       Scope           `json:"scope"`
+	NumCommands int             `json:"num_commands"`
+	NumErrors   int             `json:"num_errors"`
+	Failures    []CommandReport `json:"failures"`
+	Retried     []CommandReport `json:"retried"`
+}
+
+// commandHost returns the host a command targeted, regardless of its scope
+func (cluster *Cluster) commandHost(command ShellCommand) string {
+	if scopeIsHosts(command.Scope) || command.Host != "" {
+		return command.Host
+	}
+	return cluster.GetHostForContent(command.Content)
+}
+
+func (cluster *Cluster) summarizeFailures(remoteOutput *RemoteOutput, finalErrMsg string) string {
+	maxExamples := cluster.ErrorReporting.MaxExampleHosts
+	if maxExamples <= 0 {
+		maxExamples = 3
+	}
+	hosts := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, command := range remoteOutput.FailedCommands {
+		host := cluster.commandHost(command)
+		if !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+
+	unit := "segments"
+	if scopeIsHosts(remoteOutput.Scope) {
+		unit = "hosts"
+	}
+	exampleStr := formatExamples(hosts, maxExamples)
+	hostStr := ""
+	if !scopeIsHosts(remoteOutput.Scope) {
+		hostStr = fmt.Sprintf(" on %d host", len(hosts))
+		if len(hosts) != 1 {
+			hostStr += "s"
+		}
+	}
+	return fmt.Sprintf("%s: %d of %d %s failed%s (%s)", finalErrMsg, remoteOutput.NumErrors, len(remoteOutput.Commands), unit, hostStr, exampleStr)
+}
+
+// formatExamples lists up to maxExamples names, noting how many were left out
+func formatExamples(names []string, maxExamples int) string {
+	if len(names) <= maxExamples {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s, and %d more", strings.Join(names[:maxExamples], ", "), len(names)-maxExamples)
+}
+
+func (cluster *Cluster) newCommandReport(command ShellCommand, err error) CommandReport {
+	errStr := ""
+	if err != nil {
+		errStr = err.Error()
+	}
+	return CommandReport{
+		Content:       command.Content,
+		Host:          cluster.commandHost(command),
+		CommandString: command.CommandString,
+		Error:         errStr,
+		Stderr:        command.Stderr,
+	}
+}
+
+func (cluster *Cluster) NewClusterErrorReport(remoteOutput *RemoteOutput, finalErrMsg string) ClusterErrorReport {
+	report := ClusterErrorReport{
+		Message:     finalErrMsg,
+		Scope:       remoteOutput.Scope,
+		NumCommands: len(remoteOutput.Commands),
+		NumErrors:   remoteOutput.NumErrors,
+		Failures:    make([]CommandReport, 0),
+		Retried:     make([]CommandReport, 0),
+	}
+	for _, command := range remoteOutput.FailedCommands {
+		report.Failures = append(report.Failures, cluster.newCommandReport(command, command.Error))
+	}
+	for _, command := range remoteOutput.RetriedCommands {
+		report.Retried = append(report.Retried, cluster.newCommandReport(command, command.RetryError))
+	}
+	return report
+}
+
+/*
+ * writeErrorReport writes the report and returns its path.  A failure to write
+ * the report is logged as a warning rather than masking the original errors.
+ */
+func (cluster *Cluster) writeErrorReport(remoteOutput *RemoteOutput, finalErrMsg string) string {
+	report := cluster.NewClusterErrorReport(remoteOutput, finalErrMsg)
+	contents, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		gplog.Warn("Unable to generate cluster error report: %v", err)
+		return ""
+	}
+	timestamp := operating.System.Now().Format("20060102_150405")
+	reportPath := filepath.Join(cluster.ErrorReporting.ReportDir, fmt.Sprintf("cluster_error_report_%s.json", timestamp))
+	reportFile, err := iohelper.OpenFileForWriting(reportPath)
+	if err != nil {
+		gplog.Warn("Unable to write cluster error report: %v", err)
+		return ""
+	}
+	_, err = reportFile.Write(append(contents, '\n'))
+	closeErr := reportFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		gplog.Warn("Unable to write cluster error report %s: %v", reportPath, err)
+		return ""
+	}
+	return reportPath
+}
@@ -0,0 +1,121 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/report tests", func() {
+	var (
+		testCluster  *cluster.Cluster
+		remoteOutput *cluster.RemoteOutput
+		savedLogger  *gplog.GpLogger
+		stdout       *gbytes.Buffer
+		stderr       *gbytes.Buffer
+		testLogfile  *gbytes.Buffer
+		reportDir    string
+	)
+	messageFunc := func(contentID int) string { return "Unable to create directory" }
+
+	BeforeEach(func() {
+		savedLogger = gplog.GetLogger()
+		stdout, stderr, testLogfile = testhelper.SetupTestLogger()
+		operating.System.Now = func() time.Time { return time.Date(2017, time.January, 1, 1, 1, 1, 1, time.Local) }
+		testCluster = cluster.NewCluster([]cluster.SegConfig{
+			{DbID: 1, ContentID: -1, Port: 5432, Hostname: "cdw", DataDir: "/data/gpseg-1", Role: "p"},
+			{DbID: 2, ContentID: 0, Port: 20000, Hostname: "sdw1", DataDir: "/data/gpseg0", Role: "p"},
+			{DbID: 3, ContentID: 1, Port: 20001, Hostname: "sdw1", DataDir: "/data/gpseg1", Role: "p"},
+			{DbID: 4, ContentID: 2, Port: 20000, Hostname: "sdw2", DataDir: "/data/gpseg2", Role: "p"},
+			{DbID: 5, ContentID: 3, Port: 20000, Hostname: "sdw3", DataDir: "/data/gpseg3", Role: "p"},
+		})
+		commands := []cluster.ShellCommand{
+			{Content: 0, CommandString: "mkdir a", Error: fmt.Errorf("exit status 1"), Stderr: "permission denied"},
+			{Content: 1, CommandString: "mkdir b", Error: fmt.Errorf("exit status 1"), Stderr: "permission denied"},
+			{Content: 2, CommandString: "mkdir c", Error: fmt.Errorf("exit status 1"), Stderr: "disk full"},
+			{Content: 3, CommandString: "mkdir d", RetryError: fmt.Errorf("attempt 1: exit status 1")},
+		}
+		remoteOutput = cluster.NewRemoteOutput(cluster.ON_SEGMENTS, 3, commands)
+		var err error
+		reportDir, err = os.MkdirTemp("", "cluster_report")
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		gplog.SetLogger(savedLogger)
+		operating.System = operating.InitializeSystemFunctions()
+		os.RemoveAll(reportDir)
+	})
+
+	Describe("CheckClusterErrorWithReport", func() {
+		It("prints every failure to the shell by default", func() {
+			gplog.SetVerbosity(gplog.LOGVERBOSE)
+			path := testCluster.CheckClusterErrorWithReport(remoteOutput, "Failed to create directories", messageFunc, true)
+			Expect(path).To(Equal(""))
+			Expect(stdout).To(gbytes.Say("Unable to create directory on segment 0 on host sdw1"))
+			Expect(stderr).To(gbytes.Say("Failed to create directories"))
+		})
+		It("prints a summary to stderr and details only to the logfile when summarizing", func() {
+			testCluster.ErrorReporting = cluster.ErrorReportOptions{Summarize: true, MaxExampleHosts: 1}
+			gplog.SetVerbosity(gplog.LOGVERBOSE)
+			testCluster.CheckClusterErrorWithReport(remoteOutput, "Failed to create directories", messageFunc, true)
+			Expect(stdout).ToNot(gbytes.Say("Unable to create directory on segment"))
+			Expect(stderr).To(gbytes.Say(`Failed to create directories: 3 of 4 segments failed on 2 hosts \(sdw1, and 1 more\)`))
+			Expect(testLogfile).To(gbytes.Say(`Unable to create directory on segment 0 on host sdw1 with error exit status 1: permission denied`))
+			Expect(testLogfile).To(gbytes.Say(`Unable to create directory on segment 2 on host sdw2 with error exit status 1: disk full`))
+		})
+		It("writes a JSON report and returns its path", func() {
+			testCluster.ErrorReporting = cluster.ErrorReportOptions{ReportDir: reportDir}
+			path := testCluster.CheckClusterErrorWithReport(remoteOutput, "Failed to create directories", messageFunc, true)
+			Expect(path).To(Equal(reportDir + "/cluster_error_report_20170101_010101.json"))
+
+			contents, err := os.ReadFile(path)
+			Expect(err).ToNot(HaveOccurred())
+			var report cluster.ClusterErrorReport
+			Expect(json.Unmarshal(contents, &report)).To(Succeed())
+			Expect(report.Message).To(Equal("Failed to create directories"))
+			Expect(report.NumCommands).To(Equal(4))
+			Expect(report.NumErrors).To(Equal(3))
+			Expect(report.Failures).To(HaveLen(3))
+			Expect(report.Failures[2]).To(Equal(cluster.CommandReport{Content: 2, Host: "sdw2", CommandString: "mkdir c", Error: "exit status 1", Stderr: "disk full"}))
+			Expect(report.Retried).To(Equal([]cluster.CommandReport{{Content: 3, Host: "sdw3", CommandString: "mkdir d", Error: "attempt 1: exit status 1"}}))
+		})
+		It("includes the report path in the fatal error message", func() {
+			testCluster.ErrorReporting = cluster.ErrorReportOptions{ReportDir: reportDir}
+			defer testhelper.ShouldPanicWithMessage(fmt.Sprintf("A machine-readable report was written to %s/cluster_error_report_20170101_010101.json.", reportDir))
+			testCluster.CheckClusterErrorWithReport(remoteOutput, "Failed to create directories", messageFunc)
+		})
+		It("warns and continues if the report cannot be written", func() {
+			testCluster.ErrorReporting = cluster.ErrorReportOptions{ReportDir: "/nonexistent/directory"}
+			path := testCluster.CheckClusterErrorWithReport(remoteOutput, "Failed to create directories", messageFunc, true)
+			Expect(path).To(Equal(""))
+			Expect(stdout).To(gbytes.Say("Unable to write cluster error report"))
+		})
+	})
+})
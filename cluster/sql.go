@@ -0,0 +1,148 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster
+
+/*
+ * This file contains structs and functions for running SQL directly against
+ * individual segments using utility-mode connections.
+ */
+
+import (
+	"sync"
+
+	"github.com/apache/cloudberry-go-libs/dbconn"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+/*
+ * DBName is required.  User defaults to $PGUSER or the current user, as in
+ * NewDBConnFromEnvironment, and Role selects whether to connect to the
+ * primary ("p", the default) or the mirror ("m") of each content.  Driver is
+ * only set in tests.
+ */
+type SegmentQueryOptions struct {
+	DBName string
+	User   string
+	Role   string
+	Driver dbconn.DBDriver
+}
+
+type SegmentQueryResult struct {
+	ContentID int
+	Hostname  string
+	Port      int
+	Rows      []map[string]interface{}
+	Err       error
+}
+
+/*
+ * ExecuteSQLOnSegments opens a utility-mode connection to each of the given
+ * contents in parallel, runs query on each, and returns the rows or error for
+ * each content keyed by content id.  If no content ids are given, the query
+ * is run on every segment, excluding the coordinator.
+ *
+ * Each row is returned as a map of column name to value; text values are
+ * returned as strings rather than byte slices.  An error is returned, and no
+ * connections are made, if no database is given or no user can be determined.
+ */
+func (cluster *Cluster) ExecuteSQLOnSegments(contentIDs []int, query string, opts SegmentQueryOptions) (map[int]*SegmentQueryResult, error) {
+	if opts.DBName == "" {
+		return nil, errors.New("No database provided")
+	}
+	if len(contentIDs) == 0 {
+		for _, content := range cluster.ContentIDs {
+			if content != -1 {
+				contentIDs = append(contentIDs, content)
+			}
+		}
+	}
+	if opts.User == "" {
+		env, _ := operating.Environment()
+		opts.User = env.PGUser
+		if opts.User == "" {
+			currentUser, err := operating.System.CurrentUser()
+			if err != nil {
+				return nil, errors.Wrap(err, "Unable to determine the current user")
+			}
+			if currentUser == nil || currentUser.Username == "" {
+				return nil, errors.New("No username provided")
+			}
+			opts.User = currentUser.Username
+		}
+	}
+	role := "p"
+	if opts.Role != "" {
+		role = opts.Role
+	}
+
+	results := make(map[int]*SegmentQueryResult, len(contentIDs))
+	var wg sync.WaitGroup
+	for _, content := range contentIDs {
+		result := &SegmentQueryResult{
+			ContentID: content,
+			Hostname:  cluster.GetHostForContent(content, role),
+			Port:      cluster.GetPortForContent(content, role),
+		}
+		results[content] = result
+		if result.Hostname == "" {
+			result.Err = errors.Errorf("No segment found for content %d with role %s", content, role)
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result.Rows, result.Err = querySegment(result.Hostname, result.Port, query, opts)
+		}()
+	}
+	wg.Wait()
+	return results, nil
+}
+
+func querySegment(host string, port int, query string, opts SegmentQueryOptions) ([]map[string]interface{}, error) {
+	connection := dbconn.NewDBConn(opts.DBName, opts.User, host, port)
+	if opts.Driver != nil {
+		connection.Driver = opts.Driver
+	}
+	err := connection.ConnectInUtilityMode(1)
+	if err != nil {
+		return nil, err
+	}
+	defer connection.Close()
+
+	rows, err := connection.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	results := make([]map[string]interface{}, 0)
+	for rows.Next() {
+		row := make(map[string]interface{})
+		err = rows.MapScan(row)
+		if err != nil {
+			return nil, err
+		}
+		for column, value := range row {
+			if bytes, ok := value.([]byte); ok {
+				row[column] = string(bytes)
+			}
+		}
+		results = append(results, row)
+	}
+	return results, rows.Err()
+}
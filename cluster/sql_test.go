@@ -0,0 +1,147 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster_test
+
+import (
+	"errors"
+	"fmt"
+	"os/user"
+	"regexp"
+	"strings"
+	"sync"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+	"github.com/jmoiron/sqlx"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+/*
+ * segmentDriver hands out a separate mock database per segment port.  The
+ * first connection to each port is the utility mode probe made by Connect,
+ * which is closed immediately, so it returns a nil database instead.
+ */
+type segmentDriver struct {
+	mutex sync.Mutex
+	dbs   map[int]*sqlx.DB
+	errs  map[int]error
+	calls map[int]int
+	dsns  []string
+}
+
+func (driver *segmentDriver) Connect(driverName string, dataSourceName string) (*sqlx.DB, error) {
+	driver.mutex.Lock()
+	defer driver.mutex.Unlock()
+	driver.dsns = append(driver.dsns, dataSourceName)
+	var port int
+	_, _ = fmt.Sscanf(dataSourceName[strings.Index(dataSourceName, "port="):], "port=%d", &port)
+	if err, ok := driver.errs[port]; ok {
+		return nil, err
+	}
+	driver.calls[port]++
+	if driver.calls[port] == 1 {
+		return nil, nil
+	}
+	return driver.dbs[port], nil
+}
+
+var _ = Describe("cluster/sql tests", func() {
+	coordinatorSeg := cluster.SegConfig{DbID: 1, ContentID: -1, Port: 5432, Hostname: "cdw", DataDir: "/data/gpseg-1", Role: "p"}
+	segOne := cluster.SegConfig{DbID: 2, ContentID: 0, Port: 20000, Hostname: "sdw1", DataDir: "/data/gpseg0", Role: "p"}
+	segTwo := cluster.SegConfig{DbID: 3, ContentID: 1, Port: 20001, Hostname: "sdw2", DataDir: "/data/gpseg1", Role: "p"}
+	var (
+		testCluster *cluster.Cluster
+		driver      *segmentDriver
+		mocks       map[int]sqlmock.Sqlmock
+	)
+
+	BeforeEach(func() {
+		testCluster = cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, segOne, segTwo})
+		driver = &segmentDriver{dbs: make(map[int]*sqlx.DB), errs: make(map[int]error), calls: make(map[int]int)}
+		mocks = make(map[int]sqlmock.Sqlmock)
+		for _, port := range []int{5432, 20000, 20001} {
+			db, segMock := testhelper.CreateMockDB()
+			driver.dbs[port] = db
+			mocks[port] = segMock
+		}
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("ExecuteSQLOnSegments", func() {
+		It("runs the query on every segment in utility mode by default", func() {
+			for _, port := range []int{20000, 20001} {
+				testhelper.ExpectVersionQuery(mocks[port], "6.0.0")
+				rows := sqlmock.NewRows([]string{"setting"}).AddRow([]byte(fmt.Sprintf("%d", port)))
+				mocks[port].ExpectQuery(regexp.QuoteMeta("SELECT setting FROM pg_settings")).WillReturnRows(rows)
+			}
+
+			results, err := testCluster.ExecuteSQLOnSegments(nil, "SELECT setting FROM pg_settings", cluster.SegmentQueryOptions{DBName: "postgres", User: "gpadmin", Driver: driver})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(HaveLen(2))
+			Expect(results[0].Err).ToNot(HaveOccurred())
+			Expect(results[0].Hostname).To(Equal("sdw1"))
+			Expect(results[0].Rows).To(Equal([]map[string]interface{}{{"setting": "20000"}}))
+			Expect(results[1].Rows).To(Equal([]map[string]interface{}{{"setting": "20001"}}))
+			Expect(driver.dsns).To(HaveLen(4))
+			for _, dsn := range driver.dsns {
+				Expect(dsn).To(ContainSubstring("gp_session_role=utility"))
+				Expect(dsn).To(ContainSubstring("user='gpadmin' dbname='postgres'"))
+			}
+		})
+		It("runs the query only on the given contents", func() {
+			testhelper.ExpectVersionQuery(mocks[5432], "6.0.0")
+			mocks[5432].ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(int64(1)))
+
+			results, err := testCluster.ExecuteSQLOnSegments([]int{-1}, "SELECT 1", cluster.SegmentQueryOptions{DBName: "postgres", User: "gpadmin", Driver: driver})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results).To(HaveLen(1))
+			Expect(results[-1].Rows).To(Equal([]map[string]interface{}{{"?column?": int64(1)}}))
+		})
+		It("returns per-content errors without affecting other contents", func() {
+			driver.errs[20001] = errors.New("connection refused")
+			testhelper.ExpectVersionQuery(mocks[20000], "6.0.0")
+			mocks[20000].ExpectQuery("SELECT 1").WillReturnError(errors.New("relation does not exist"))
+
+			results, err := testCluster.ExecuteSQLOnSegments([]int{0, 1, 7}, "SELECT 1", cluster.SegmentQueryOptions{DBName: "postgres", User: "gpadmin", Driver: driver})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(results[0].Err).To(MatchError("relation does not exist"))
+			Expect(results[1].Err.Error()).To(ContainSubstring(`Is the server running on host "sdw2"`))
+			Expect(results[7].Err).To(MatchError("No segment found for content 7 with role p"))
+		})
+		It("returns an error without connecting if no database is given", func() {
+			results, err := testCluster.ExecuteSQLOnSegments(nil, "SELECT 1", cluster.SegmentQueryOptions{User: "gpadmin", Driver: driver})
+			Expect(err).To(MatchError("No database provided"))
+			Expect(results).To(BeNil())
+			Expect(driver.dsns).To(BeEmpty())
+		})
+		It("returns an error if no user is given and the current user cannot be determined", func() {
+			operating.System.Getenv = func(key string) string { return "" }
+			operating.System.CurrentUser = func() (*user.User, error) { return nil, errors.New("user: unknown userid 1234") }
+			results, err := testCluster.ExecuteSQLOnSegments(nil, "SELECT 1", cluster.SegmentQueryOptions{DBName: "postgres", Driver: driver})
+			Expect(err).To(MatchError("Unable to determine the current user: user: unknown userid 1234"))
+			Expect(results).To(BeNil())
+			Expect(driver.dsns).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,150 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster
+
+/*
+ * This file contains structs and functions for customizing the ssh commands
+ * used to run commands on remote hosts.
+ */
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/operating"
+)
+
+/*
+ * SSHOptions are added to the ssh command line for each remote host when set
+ * in cluster.SSH; the zero value produces the same command line as
+ * ConstructSSHCommand.  ssh already reads ~/.ssh/config, so settings there,
+ * including ProxyJump and per-host ports, are honored without any options;
+ * these fields are for clusters that cannot rely on the user's ssh config.
+ * - ConfigFile is passed with -F in place of ~/.ssh/config.
+ * - ProxyJump is passed with -J, to reach hosts through a bastion host.
+ * - Port is the port to connect to on every host, and HostPorts overrides it
+ *   for individual hosts.
+ * - User is the user to log in as on every host, and HostUsers overrides it
+ *   for individual hosts, for clusters whose hosts are provisioned under
+ *   different service accounts.  If neither is set for a host, no user is
+ *   given, so ssh uses the User from its config or else the current user.
+ * - IdentityFile is passed with -i, and HostIdentityFiles overrides it for
+ *   individual hosts.
+ * - StrictHostKeyChecking is passed with -o StrictHostKeyChecking, e.g.
+ *   "yes" or "accept-new"; it defaults to "no", since hosts are often added
+ *   to a cluster before their keys are known.
+ * - ConnectTimeout, in seconds, is passed with -o ConnectTimeout.
+ * - ExtraOptions are each passed with -o, e.g. "ServerAliveInterval=30".
+ */
+type SSHOptions struct {
+	ConfigFile            string
+	ProxyJump             string
+	Port                  int
+	HostPorts             map[string]int
+	User                  string
+	HostUsers             map[string]string
+	IdentityFile          string
+	HostIdentityFiles     map[string]string
+	StrictHostKeyChecking string
+	ConnectTimeout        int
+	ExtraOptions          []string
+}
+
+// Args returns the ssh arguments for the given host, not including the destination
+func (options SSHOptions) Args(host string) []string {
+	hostKeyChecking := options.StrictHostKeyChecking
+	if hostKeyChecking == "" {
+		hostKeyChecking = "no"
+	}
+	args := []string{"-o", "StrictHostKeyChecking=" + hostKeyChecking}
+	if options.ConfigFile != "" {
+		args = append(args, "-F", options.ConfigFile)
+	}
+	if options.ProxyJump != "" {
+		args = append(args, "-J", options.ProxyJump)
+	}
+	port := options.Port
+	if hostPort, ok := options.HostPorts[host]; ok {
+		port = hostPort
+	}
+	if port != 0 {
+		args = append(args, "-p", strconv.Itoa(port))
+	}
+	identityFile := options.IdentityFile
+	if hostIdentityFile, ok := options.HostIdentityFiles[host]; ok {
+		identityFile = hostIdentityFile
+	}
+	if identityFile != "" {
+		args = append(args, "-i", identityFile)
+	}
+	if options.ConnectTimeout > 0 {
+		args = append(args, "-o", fmt.Sprintf("ConnectTimeout=%d", options.ConnectTimeout))
+	}
+	for _, option := range options.ExtraOptions {
+		args = append(args, "-o", option)
+	}
+	return args
+}
+
+// UserForHost returns the user to log in to the given host as
+func (options SSHOptions) UserForHost(host string) string {
+	if user, ok := options.HostUsers[host]; ok && user != "" {
+		return user
+	}
+	if options.User != "" {
+		return options.User
+	}
+	currentUser, _ := operating.System.CurrentUser()
+	return currentUser.Username
+}
+
+/*
+ * Destination returns the destination for ssh, or the prefix of a remote path
+ * for rsync and scp: user@host if a user is set for the host, or just host,
+ * so that ssh chooses the user, if not.
+ */
+func (options SSHOptions) Destination(host string) string {
+	if options.HostUsers[host] == "" && options.User == "" {
+		return host
+	}
+	return fmt.Sprintf("%s@%s", options.UserForHost(host), host)
+}
+
+/*
+ * RsyncShell returns the remote shell command to pass to rsync with -e when
+ * copying files to or from the given host, so that file transfers use the
+ * same port, identity file, and other options as commands run on that host;
+ * the remote path should be prefixed with Destination(host) + ":".
+ */
+func (options SSHOptions) RsyncShell(host string) string {
+	command := []string{"ssh"}
+	for _, arg := range options.Args(host) {
+		command = append(command, quoteShellArg(arg))
+	}
+	return strings.Join(command, " ")
+}
+
+func ConstructSSHCommandWithOptions(useLocal bool, host string, cmd string, options SSHOptions) []string {
+	if useLocal {
+		return []string{"bash", "-c", cmd}
+	}
+	command := []string{"ssh"}
+	command = append(command, options.Args(host)...)
+	return append(command, options.Destination(host), cmd)
+}
@@ -0,0 +1,127 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster_test
+
+import (
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/ssh tests", func() {
+	BeforeEach(func() {
+		testhelper.FreezeSystem(GinkgoT(), testhelper.FreezeOptions{})
+	})
+
+	Describe("SSHOptions.Args", func() {
+		It("only disables host key checking by default", func() {
+			Expect(cluster.SSHOptions{}.Args("sdw1")).To(Equal([]string{"-o", "StrictHostKeyChecking=no"}))
+		})
+		It("includes all options that are set", func() {
+			options := cluster.SSHOptions{
+				ConfigFile:     "/home/gpadmin/cluster_ssh_config",
+				ProxyJump:      "gpadmin@bastion:2222",
+				Port:           2200,
+				IdentityFile:   "/home/gpadmin/.ssh/cluster_key",
+				ConnectTimeout: 10,
+				ExtraOptions:   []string{"ServerAliveInterval=30", "BatchMode=yes"},
+			}
+			Expect(options.Args("sdw1")).To(Equal([]string{
+				"-o", "StrictHostKeyChecking=no",
+				"-F", "/home/gpadmin/cluster_ssh_config",
+				"-J", "gpadmin@bastion:2222",
+				"-p", "2200",
+				"-i", "/home/gpadmin/.ssh/cluster_key",
+				"-o", "ConnectTimeout=10",
+				"-o", "ServerAliveInterval=30",
+				"-o", "BatchMode=yes",
+			}))
+		})
+		It("passes the host key checking setting if it is set", func() {
+			options := cluster.SSHOptions{StrictHostKeyChecking: "accept-new"}
+			Expect(options.Args("sdw1")).To(Equal([]string{"-o", "StrictHostKeyChecking=accept-new"}))
+		})
+		It("uses a per-host port in place of the default port", func() {
+			options := cluster.SSHOptions{Port: 2200, HostPorts: map[string]int{"sdw2": 2201}}
+			Expect(options.Args("sdw1")).To(ContainElements("-p", "2200"))
+			Expect(options.Args("sdw2")).To(ContainElements("-p", "2201"))
+		})
+		It("uses a per-host identity file in place of the default identity file", func() {
+			options := cluster.SSHOptions{IdentityFile: "/home/gpadmin/.ssh/id_rsa", HostIdentityFiles: map[string]string{"sdw2": "/home/gpadmin/.ssh/segment_key"}}
+			Expect(options.Args("sdw1")).To(ContainElements("-i", "/home/gpadmin/.ssh/id_rsa"))
+			Expect(options.Args("sdw2")).To(ContainElements("-i", "/home/gpadmin/.ssh/segment_key"))
+		})
+	})
+	Describe("SSHOptions.UserForHost", func() {
+		It("uses the current user by default", func() {
+			Expect(cluster.SSHOptions{}.UserForHost("sdw1")).To(Equal("testUser"))
+		})
+		It("uses a per-host user in place of the default user", func() {
+			options := cluster.SSHOptions{User: "gpadmin", HostUsers: map[string]string{"sdw2": "gpsegment"}}
+			Expect(options.UserForHost("sdw1")).To(Equal("gpadmin"))
+			Expect(options.UserForHost("sdw2")).To(Equal("gpsegment"))
+			Expect(options.Destination("sdw2")).To(Equal("gpsegment@sdw2"))
+		})
+	})
+	Describe("SSHOptions.Destination", func() {
+		It("leaves the user to ssh if no user is set for the host", func() {
+			Expect(cluster.SSHOptions{}.Destination("sdw1")).To(Equal("sdw1"))
+			options := cluster.SSHOptions{HostUsers: map[string]string{"sdw2": "gpsegment"}}
+			Expect(options.Destination("sdw1")).To(Equal("sdw1"))
+			Expect(options.Destination("sdw2")).To(Equal("gpsegment@sdw2"))
+		})
+		It("gives the default user for every host if it is set", func() {
+			Expect(cluster.SSHOptions{User: "gpadmin"}.Destination("sdw1")).To(Equal("gpadmin@sdw1"))
+		})
+	})
+	Describe("SSHOptions.RsyncShell", func() {
+		It("quotes the ssh arguments for the host", func() {
+			options := cluster.SSHOptions{HostPorts: map[string]int{"sdw2": 2201}, HostIdentityFiles: map[string]string{"sdw2": "/home/gp admin/key"}}
+			Expect(options.RsyncShell("sdw2")).To(Equal(`ssh '-o' 'StrictHostKeyChecking=no' '-p' '2201' '-i' '/home/gp admin/key'`))
+		})
+	})
+	Describe("ConstructSSHCommandWithOptions", func() {
+		It("ignores options for a local command", func() {
+			cmd := cluster.ConstructSSHCommandWithOptions(true, "sdw1", "ls", cluster.SSHOptions{Port: 2200})
+			Expect(cmd).To(Equal([]string{"bash", "-c", "ls"}))
+		})
+		It("constructs a remote ssh command with options", func() {
+			cmd := cluster.ConstructSSHCommandWithOptions(false, "sdw1", "ls", cluster.SSHOptions{ProxyJump: "bastion"})
+			Expect(cmd).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=no", "-J", "bastion", "sdw1", "ls"}))
+		})
+		It("logs in to each host as its own user", func() {
+			options := cluster.SSHOptions{HostUsers: map[string]string{"sdw1": "gpsegment"}}
+			cmd := cluster.ConstructSSHCommandWithOptions(false, "sdw1", "ls", options)
+			Expect(cmd).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=no", "gpsegment@sdw1", "ls"}))
+		})
+	})
+	Describe("GenerateSSHCommandList", func() {
+		It("uses the cluster's ssh options for remote hosts", func() {
+			testCluster := cluster.NewCluster([]cluster.SegConfig{
+				{DbID: 1, ContentID: -1, Port: 5432, Hostname: "localhost", DataDir: "/data/gpseg-1", Role: "p"},
+				{DbID: 2, ContentID: 0, Port: 20000, Hostname: "remotehost1", DataDir: "/data/gpseg0", Role: "p"},
+			})
+			testCluster.SSH = cluster.SSHOptions{HostPorts: map[string]int{"remotehost1": 2222}}
+			commandList := testCluster.GenerateSSHCommandList(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, func(_ int) string { return "ls" })
+			Expect(commandList[0].Command.Args).To(Equal([]string{"bash", "-c", "ls"}))
+			Expect(commandList[1].Command.Args).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=no", "-p", "2222", "remotehost1", "ls"}))
+		})
+	})
+})
@@ -0,0 +1,171 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster
+
+/*
+ * This file contains structs and functions for generating cluster commands
+ * from Go text/template strings instead of generator functions.
+ */
+
+import (
+	"bytes"
+	"text/template"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/pkg/errors"
+)
+
+/*
+ * A CommandTemplate can be passed to GenerateCommandList or
+ * GenerateSSHCommandList in place of a generator function.  The template is
+ * executed once per segment or per host, depending on the scope, with a
+ * TemplateData value as its data, so a per-segment command might look like
+ *
+ *   ls {{.DataDir}} > /tmp/{{.Vars.prefix}}_{{.ContentID}}.txt
+ *
+ * Referencing a field or user variable that does not exist is an error.
+ * Rendering errors during command generation are fatal, so callers taking
+ * templates from configuration files should call ValidateCommandTemplate
+ * first to report any problems gracefully.
+ *
+ * When passed to GenerateCommandList, the rendered string is run with bash.
+ * CommandTemplates must be created with NewCommandTemplate.
+ */
+type CommandTemplate struct {
+	Vars map[string]interface{}
+	tmpl *template.Template
+}
+
+/*
+ * For per-segment commands, the fields describing the segment are filled in
+ * from the primary segment for each content.  For per-host commands, only
+ * Hostname and Segments (all segments on the host) are set, and ContentID is
+ * set to -2 to match the Content of per-host ShellCommands.
+ */
+type TemplateData struct {
+	ContentID int
+	DbID      int
+	Role      string
+	Port      int
+	Hostname  string
+	Address   string
+	DataDir   string
+	Segments  []*SegConfig
+	Vars      map[string]interface{}
+}
+
+func NewCommandTemplate(text string, vars map[string]interface{}) (*CommandTemplate, error) {
+	tmpl, err := template.New("command").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid command template")
+	}
+	if vars == nil {
+		vars = make(map[string]interface{})
+	}
+	return &CommandTemplate{Vars: vars, tmpl: tmpl}, nil
+}
+
+func MustNewCommandTemplate(text string, vars map[string]interface{}) *CommandTemplate {
+	commandTemplate, err := NewCommandTemplate(text, vars)
+	gplog.FatalOnError(err)
+	return commandTemplate
+}
+
+func (commandTemplate *CommandTemplate) render(data TemplateData) (string, error) {
+	data.Vars = commandTemplate.Vars
+	var buffer bytes.Buffer
+	err := commandTemplate.tmpl.Execute(&buffer, data)
+	if err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+func (cluster *Cluster) RenderTemplateForContent(commandTemplate *CommandTemplate, content int) (string, error) {
+	segment := getSegmentByRole(cluster.ByContent[content])
+	if segment == nil {
+		return "", errors.Errorf("No segment found for content %d", content)
+	}
+	command, err := commandTemplate.render(TemplateData{
+		ContentID: segment.ContentID,
+		DbID:      segment.DbID,
+		Role:      segment.Role,
+		Port:      segment.Port,
+		Hostname:  segment.Hostname,
+		Address:   segment.Address,
+		DataDir:   segment.DataDir,
+		Segments:  []*SegConfig{segment},
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "Unable to render command template for segment %d", content)
+	}
+	return command, nil
+}
+
+func (cluster *Cluster) RenderTemplateForHost(commandTemplate *CommandTemplate, host string) (string, error) {
+	command, err := commandTemplate.render(TemplateData{
+		ContentID: -2,
+		Hostname:  host,
+		Segments:  cluster.ByHost[host],
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "Unable to render command template for host %s", host)
+	}
+	return command, nil
+}
+
+/*
+ * ValidateCommandTemplate renders the template for every segment or host that
+ * the scope would include, returning the first error encountered, so that
+ * problems are found before any commands are executed.
+ */
+func (cluster *Cluster) ValidateCommandTemplate(scope Scope, commandTemplate *CommandTemplate) error {
+	var err error
+	if scopeIsHosts(scope) {
+		cluster.GenerateCommandList(scope, func(host string) []string {
+			if err == nil {
+				_, err = cluster.RenderTemplateForHost(commandTemplate, host)
+			}
+			return []string{"true"}
+		})
+	} else {
+		cluster.GenerateCommandList(scope, func(content int) []string {
+			if err == nil {
+				_, err = cluster.RenderTemplateForContent(commandTemplate, content)
+			}
+			return []string{"true"}
+		})
+	}
+	return err
+}
+
+func (cluster *Cluster) templateContentFunc(commandTemplate *CommandTemplate) func(content int) string {
+	return func(content int) string {
+		command, err := cluster.RenderTemplateForContent(commandTemplate, content)
+		gplog.FatalOnError(err)
+		return command
+	}
+}
+
+func (cluster *Cluster) templateHostFunc(commandTemplate *CommandTemplate) func(host string) string {
+	return func(host string) string {
+		command, err := cluster.RenderTemplateForHost(commandTemplate, host)
+		gplog.FatalOnError(err)
+		return command
+	}
+}
@@ -0,0 +1,127 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster_test
+
+import (
+	"os/user"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/template tests", func() {
+	coordinatorSeg := cluster.SegConfig{DbID: 1, ContentID: -1, Port: 5432, Hostname: "localhost", DataDir: "/data/gpseg-1", Role: "p"}
+	localSegOne := cluster.SegConfig{DbID: 2, ContentID: 0, Port: 20000, Hostname: "localhost", DataDir: "/data/gpseg0", Role: "p"}
+	remoteSegOne := cluster.SegConfig{DbID: 3, ContentID: 1, Port: 20001, Hostname: "remotehost1", DataDir: "/data/gpseg1", Role: "p"}
+	var testCluster *cluster.Cluster
+
+	BeforeEach(func() {
+		operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "testUser", HomeDir: "testDir"}, nil }
+		testCluster = cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, localSegOne, remoteSegOne})
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("NewCommandTemplate", func() {
+		It("returns an error for a template that does not parse", func() {
+			_, err := cluster.NewCommandTemplate("ls {{.DataDir", nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid command template"))
+		})
+		It("panics in the Must variant for a template that does not parse", func() {
+			defer testhelper.ShouldPanicWithMessage("Invalid command template")
+			cluster.MustNewCommandTemplate("ls {{.DataDir", nil)
+		})
+	})
+	Describe("RenderTemplateForContent", func() {
+		It("fills in segment fields and user variables", func() {
+			commandTemplate := cluster.MustNewCommandTemplate("ls {{.DataDir}} > /tmp/{{.Vars.prefix}}_{{.ContentID}}_{{.DbID}}_{{.Port}}_{{.Hostname}}", map[string]interface{}{"prefix": "out"})
+			command, err := testCluster.RenderTemplateForContent(commandTemplate, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(command).To(Equal("ls /data/gpseg1 > /tmp/out_1_3_20001_remotehost1"))
+		})
+		It("returns an error for an unknown field", func() {
+			commandTemplate := cluster.MustNewCommandTemplate("ls {{.DataDirectory}}", nil)
+			_, err := testCluster.RenderTemplateForContent(commandTemplate, 0)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unable to render command template for segment 0"))
+		})
+		It("returns an error for an unknown user variable", func() {
+			commandTemplate := cluster.MustNewCommandTemplate("ls {{.Vars.missing}}", map[string]interface{}{"prefix": "out"})
+			_, err := testCluster.RenderTemplateForContent(commandTemplate, 0)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(`map has no entry for key "missing"`))
+		})
+		It("returns an error for a content that is not in the cluster", func() {
+			commandTemplate := cluster.MustNewCommandTemplate("ls {{.DataDir}}", nil)
+			_, err := testCluster.RenderTemplateForContent(commandTemplate, 7)
+			Expect(err).To(MatchError("No segment found for content 7"))
+		})
+	})
+	Describe("RenderTemplateForHost", func() {
+		It("fills in the hostname and the segments on that host", func() {
+			commandTemplate := cluster.MustNewCommandTemplate("{{.ContentID}} {{.Hostname}}:{{range .Segments}} {{.DataDir}}{{end}}", nil)
+			command, err := testCluster.RenderTemplateForHost(commandTemplate, "localhost")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(command).To(Equal("-2 localhost: /data/gpseg-1 /data/gpseg0"))
+		})
+	})
+	Describe("ValidateCommandTemplate", func() {
+		It("returns nil if the template renders for every segment", func() {
+			commandTemplate := cluster.MustNewCommandTemplate("ls {{.DataDir}}", nil)
+			Expect(testCluster.ValidateCommandTemplate(cluster.ON_SEGMENTS, commandTemplate)).To(Succeed())
+		})
+		It("returns an error if the template fails to render for a host", func() {
+			commandTemplate := cluster.MustNewCommandTemplate("ls {{.Vars.dir}}", nil)
+			err := testCluster.ValidateCommandTemplate(cluster.ON_HOSTS, commandTemplate)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unable to render command template for host"))
+		})
+	})
+	Describe("GenerateCommandList", func() {
+		It("runs a rendered template for each segment with bash", func() {
+			commandTemplate := cluster.MustNewCommandTemplate("ls {{.DataDir}}", nil)
+			commandList := testCluster.GenerateCommandList(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, commandTemplate)
+			Expect(commandList).To(Equal([]cluster.ShellCommand{
+				cluster.NewShellCommand(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, -1, "", []string{"bash", "-c", "ls /data/gpseg-1"}),
+				cluster.NewShellCommand(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, 0, "", []string{"bash", "-c", "ls /data/gpseg0"}),
+				cluster.NewShellCommand(cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR, 1, "", []string{"bash", "-c", "ls /data/gpseg1"}),
+			}))
+		})
+		It("panics if the template fails to render", func() {
+			defer testhelper.ShouldPanicWithMessage("Unable to render command template for segment 0")
+			commandTemplate := cluster.MustNewCommandTemplate("ls {{.Vars.dir}}", nil)
+			testCluster.GenerateCommandList(cluster.ON_SEGMENTS, commandTemplate)
+		})
+	})
+	Describe("GenerateSSHCommandList", func() {
+		It("wraps a rendered template for each host in ssh", func() {
+			commandTemplate := cluster.MustNewCommandTemplate("ls {{.Hostname}}", nil)
+			commandList := testCluster.GenerateSSHCommandList(cluster.ON_HOSTS, commandTemplate)
+			Expect(commandList).To(Equal([]cluster.ShellCommand{
+				cluster.NewShellCommand(cluster.ON_HOSTS, -2, "localhost", []string{"bash", "-c", "ls localhost"}),
+				cluster.NewShellCommand(cluster.ON_HOSTS, -2, "remotehost1", []string{"ssh", "-o", "StrictHostKeyChecking=no", "remotehost1", "ls remotehost1"}),
+			}))
+		})
+	})
+})
@@ -0,0 +1,169 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster
+
+/*
+ * This file contains structs and functions for limiting the load that cluster
+ * commands place on segment hosts, so that mass operations such as file
+ * distribution do not starve production query traffic.
+ */
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+/*
+ * ThrottleOptions are applied to a cluster with SetThrottle.
+ * - MaxConcurrent limits how many commands the GPDBExecutor will run at once
+ *   in total.  Zero means no limit.
+ * - MaxConcurrentPerHost limits how many commands the GPDBExecutor will run at
+ *   once against any one host, independently of MaxConcurrent, so that e.g. a
+ *   disk-heavy command run for each of 8 primaries on a host can be limited to
+ *   2 at a time on that host while other hosts proceed.  Zero means no limit.
+ * - Nice, if nonzero, runs commands generated by GenerateSSHCommandList under
+ *   nice with that adjustment.
+ * - IONiceClass and IONiceLevel, if the class is nonzero, run those commands
+ *   under ionice with that scheduling class (1-3) and, for classes 1 and 2,
+ *   that priority level (0-7).
+ * - RsyncBandwidthLimit, in KiB per second, is not applied automatically since
+ *   rsync commands are built by the caller; pass RsyncArgs() to rsync instead.
+ */
+type ThrottleOptions struct {
+	MaxConcurrent        int
+	MaxConcurrentPerHost int
+	Nice                 int
+	IONiceClass          int
+	IONiceLevel          int
+	RsyncBandwidthLimit  int
+}
+
+func (cluster *Cluster) SetThrottle(options ThrottleOptions) {
+	cluster.Throttle = options
+	if executor, ok := cluster.Executor.(*GPDBExecutor); ok {
+		executor.MaxConcurrent = options.MaxConcurrent
+		executor.MaxConcurrentPerHost = options.MaxConcurrentPerHost
+		executor.CommandHost = cluster.commandHost
+	}
+}
+
+func (options ThrottleOptions) RsyncArgs() []string {
+	if options.RsyncBandwidthLimit <= 0 {
+		return []string{}
+	}
+	return []string{fmt.Sprintf("--bwlimit=%d", options.RsyncBandwidthLimit)}
+}
+
+// WrapCommand prefixes a shell command string with any nice and ionice settings
+func (options ThrottleOptions) WrapCommand(cmd string) string {
+	prefix := make([]string, 0)
+	if options.Nice != 0 {
+		prefix = append(prefix, fmt.Sprintf("nice -n %d", options.Nice))
+	}
+	if options.IONiceClass != 0 {
+		ionice := fmt.Sprintf("ionice -c %d", options.IONiceClass)
+		if options.IONiceClass != 3 {
+			ionice += fmt.Sprintf(" -n %d", options.IONiceLevel)
+		}
+		prefix = append(prefix, ionice)
+	}
+	if len(prefix) == 0 {
+		return cmd
+	}
+	return fmt.Sprintf("%s bash -c %s", strings.Join(prefix, " "), quoteShellArg(cmd))
+}
+
+/*
+ * commandSlots holds the semaphores used to limit how many commands run at
+ * once, in total and on each host; a nil channel or map means no limit.
+ */
+type commandSlots struct {
+	total  chan struct{}
+	byHost map[string]chan struct{}
+}
+
+func (executor *GPDBExecutor) commandSlots(commandList []ShellCommand) *commandSlots {
+	slots := &commandSlots{}
+	if executor.MaxConcurrent > 0 {
+		slots.total = make(chan struct{}, executor.MaxConcurrent)
+	}
+	if executor.MaxConcurrentPerHost > 0 {
+		slots.byHost = make(map[string]chan struct{})
+		for _, command := range commandList {
+			host := executor.throttleHost(command)
+			if _, ok := slots.byHost[host]; !ok {
+				slots.byHost[host] = make(chan struct{}, executor.MaxConcurrentPerHost)
+			}
+		}
+	}
+	return slots
+}
+
+func (executor *GPDBExecutor) throttleHost(command ShellCommand) string {
+	if executor.CommandHost != nil {
+		return executor.CommandHost(command)
+	}
+	return command.Host
+}
+
+func acquire(ctx context.Context, slot chan struct{}) bool {
+	if slot == nil {
+		return true
+	}
+	select {
+	case slot <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func release(slot chan struct{}) {
+	if slot != nil {
+		<-slot
+	}
+}
+
+/*
+ * acquireSlot blocks until the command may run and returns a function to
+ * release its slots, or returns false if ctx is canceled while waiting.
+ *
+ * The host slot is acquired before the total slot, so that commands waiting
+ * on a busy host do not hold total slots that commands for idle hosts could
+ * use; with 8 segments on each host and a per-host limit of 2, the total
+ * slots are shared across hosts instead of going to whichever host's
+ * commands happened to start first.
+ */
+func (executor *GPDBExecutor) acquireSlot(ctx context.Context, slots *commandSlots, command ShellCommand) (func(), bool) {
+	if ctx.Err() != nil {
+		return nil, false
+	}
+	hostSlot := slots.byHost[executor.throttleHost(command)]
+	if !acquire(ctx, hostSlot) {
+		return nil, false
+	}
+	if !acquire(ctx, slots.total) {
+		release(hostSlot)
+		return nil, false
+	}
+	return func() {
+		release(slots.total)
+		release(hostSlot)
+	}, true
+}
@@ -0,0 +1,154 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/throttle tests", func() {
+	coordinatorSeg := cluster.SegConfig{DbID: 1, ContentID: -1, Port: 5432, Hostname: "localhost", DataDir: "/data/gpseg-1", Role: "p"}
+	localSegOne := cluster.SegConfig{DbID: 2, ContentID: 0, Port: 20000, Hostname: "localhost", DataDir: "/data/gpseg0", Role: "p"}
+	remoteSegOne := cluster.SegConfig{DbID: 3, ContentID: 1, Port: 20001, Hostname: "remotehost1", DataDir: "/data/gpseg1", Role: "p"}
+	var testCluster *cluster.Cluster
+
+	BeforeEach(func() {
+		operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "testUser", HomeDir: "testDir"}, nil }
+		testCluster = cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, localSegOne, remoteSegOne})
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("WrapCommand", func() {
+		It("leaves the command unchanged by default", func() {
+			Expect(cluster.ThrottleOptions{}.WrapCommand("ls")).To(Equal("ls"))
+		})
+		It("runs the command under nice", func() {
+			Expect(cluster.ThrottleOptions{Nice: 10}.WrapCommand("ls 'a b'")).To(Equal(`nice -n 10 bash -c 'ls '\''a b'\'''`))
+		})
+		It("runs the command under ionice with a level", func() {
+			Expect(cluster.ThrottleOptions{IONiceClass: 2, IONiceLevel: 7}.WrapCommand("ls")).To(Equal("ionice -c 2 -n 7 bash -c 'ls'"))
+		})
+		It("runs the command under ionice without a level for the idle class", func() {
+			Expect(cluster.ThrottleOptions{IONiceClass: 3, IONiceLevel: 7}.WrapCommand("ls")).To(Equal("ionice -c 3 bash -c 'ls'"))
+		})
+		It("runs the command under both nice and ionice", func() {
+			Expect(cluster.ThrottleOptions{Nice: 19, IONiceClass: 3}.WrapCommand("ls")).To(Equal("nice -n 19 ionice -c 3 bash -c 'ls'"))
+		})
+	})
+	Describe("RsyncArgs", func() {
+		It("returns no arguments if there is no bandwidth limit", func() {
+			Expect(cluster.ThrottleOptions{}.RsyncArgs()).To(BeEmpty())
+		})
+		It("returns a --bwlimit argument", func() {
+			Expect(cluster.ThrottleOptions{RsyncBandwidthLimit: 5000}.RsyncArgs()).To(Equal([]string{"--bwlimit=5000"}))
+		})
+	})
+	Describe("SetThrottle", func() {
+		It("applies nice settings to generated ssh commands", func() {
+			testCluster.SetThrottle(cluster.ThrottleOptions{Nice: 5})
+			commandList := testCluster.GenerateSSHCommandList(cluster.ON_SEGMENTS, func(_ int) string { return "ls" })
+			Expect(commandList[0].Command.Args).To(Equal([]string{"bash", "-c", "nice -n 5 bash -c 'ls'"}))
+			Expect(commandList[1].Command.Args).To(Equal([]string{"ssh", "-o", "StrictHostKeyChecking=no", "remotehost1", "nice -n 5 bash -c 'ls'"}))
+		})
+		It("sets the limits on the executor", func() {
+			testCluster.SetThrottle(cluster.ThrottleOptions{MaxConcurrent: 4, MaxConcurrentPerHost: 2})
+			executor := testCluster.Executor.(*cluster.GPDBExecutor)
+			Expect(executor.MaxConcurrent).To(Equal(4))
+			Expect(executor.MaxConcurrentPerHost).To(Equal(2))
+			Expect(executor.CommandHost(cluster.ShellCommand{Scope: cluster.ON_SEGMENTS, Content: 1})).To(Equal("remotehost1"))
+		})
+		It("does not modify an executor that is not a GPDBExecutor", func() {
+			testCluster.Executor = &testhelper.TestExecutor{}
+			testCluster.SetThrottle(cluster.ThrottleOptions{MaxConcurrentPerHost: 2})
+			Expect(testCluster.Throttle.MaxConcurrentPerHost).To(Equal(2))
+		})
+	})
+	Describe("ExecuteClusterCommand with a per-host limit", func() {
+		It("does not run more commands at once on a host than the limit", func() {
+			lockDir := GinkgoT().TempDir()
+			commandList := make([]cluster.ShellCommand, 0)
+			for i := 0; i < 5; i++ {
+				// mkdir fails if another command on the same host holds the lock
+				cmd := fmt.Sprintf("mkdir %[1]s/lock && sleep 0.05 && rmdir %[1]s/lock", lockDir)
+				commandList = append(commandList, cluster.NewShellCommand(cluster.ON_HOSTS, -2, "samehost", []string{"bash", "-c", cmd}))
+			}
+			executor := &cluster.GPDBExecutor{MaxConcurrentPerHost: 1}
+			remoteOutput := executor.ExecuteClusterCommand(cluster.ON_HOSTS, commandList)
+			Expect(remoteOutput.NumErrors).To(Equal(0))
+		})
+	})
+	Describe("ExecuteClusterCommand with a total limit", func() {
+		// mkdir fails if another command holds the lock
+		lockCommand := func(lockDir string) string {
+			return fmt.Sprintf("mkdir %[1]s/lock && sleep 0.05 && rmdir %[1]s/lock", lockDir)
+		}
+		It("does not run more commands at once across all hosts than the limit", func() {
+			lockDir := GinkgoT().TempDir()
+			commandList := make([]cluster.ShellCommand, 0)
+			for i := 0; i < 4; i++ {
+				commandList = append(commandList, cluster.NewShellCommand(cluster.ON_HOSTS, -2, fmt.Sprintf("host%d", i), []string{"bash", "-c", lockCommand(lockDir)}))
+			}
+			executor := &cluster.GPDBExecutor{MaxConcurrent: 1}
+			remoteOutput := executor.ExecuteClusterCommand(cluster.ON_HOSTS, commandList)
+			Expect(remoteOutput.NumErrors).To(Equal(0))
+		})
+		It("applies the per-host limit independently of the total limit", func() {
+			tempDir := GinkgoT().TempDir()
+			commandList := make([]cluster.ShellCommand, 0)
+			for _, host := range []string{"host1", "host2"} {
+				hostDir := filepath.Join(tempDir, host)
+				Expect(os.Mkdir(hostDir, 0755)).To(Succeed())
+				for i := 0; i < 3; i++ {
+					commandList = append(commandList, cluster.NewShellCommand(cluster.ON_HOSTS, -2, host, []string{"bash", "-c", lockCommand(hostDir)}))
+				}
+			}
+			executor := &cluster.GPDBExecutor{MaxConcurrent: 2, MaxConcurrentPerHost: 1}
+			remoteOutput := executor.ExecuteClusterCommand(cluster.ON_HOSTS, commandList)
+			Expect(remoteOutput.NumErrors).To(Equal(0))
+			Expect(remoteOutput.Commands).To(HaveLen(6))
+		})
+		It("does not start waiting commands once the context is canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			commandList := []cluster.ShellCommand{
+				cluster.NewShellCommand(cluster.ON_HOSTS, -2, "host1", []string{"bash", "-c", "sleep 5"}),
+				cluster.NewShellCommand(cluster.ON_HOSTS, -2, "host2", []string{"bash", "-c", "sleep 5"}),
+			}
+			executor := &cluster.GPDBExecutor{MaxConcurrent: 1}
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				cancel()
+			}()
+			remoteOutput := executor.ExecuteClusterCommandWithContext(cluster.ON_HOSTS, commandList, ctx)
+			Expect(remoteOutput.NumCanceled).To(Equal(2))
+		})
+	})
+})
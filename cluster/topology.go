@@ -0,0 +1,95 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster
+
+/*
+ * This file contains functions for saving a cluster's topology and
+ * reconstructing a Cluster from it later without a database connection, e.g.
+ * to plan a restore against the topology that existed at backup time.
+ */
+
+import (
+	"encoding/json"
+
+	"github.com/apache/cloudberry-go-libs/iohelper"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+const TOPOLOGY_FORMAT_VERSION = 1
+
+type topologyJSON struct {
+	FormatVersion int         `json:"format_version"`
+	Segments      []SegConfig `json:"segments"`
+}
+
+/*
+ * Only the segment configuration is serialized; ByContent, ByHost, and the
+ * other derived fields are rebuilt on unmarshaling, and the Executor and other
+ * options are runtime settings that are not part of the topology.
+ */
+func (cluster *Cluster) MarshalJSON() ([]byte, error) {
+	return json.Marshal(topologyJSON{FormatVersion: TOPOLOGY_FORMAT_VERSION, Segments: cluster.Segments})
+}
+
+/*
+ * UnmarshalJSON replaces the cluster's topology and resets all other fields
+ * to the values set by NewCluster.
+ */
+func (cluster *Cluster) UnmarshalJSON(data []byte) error {
+	var topology topologyJSON
+	err := json.Unmarshal(data, &topology)
+	if err != nil {
+		return err
+	}
+	if topology.FormatVersion > TOPOLOGY_FORMAT_VERSION {
+		return errors.Errorf("Unsupported cluster topology format version %d", topology.FormatVersion)
+	}
+	*cluster = *NewCluster(topology.Segments)
+	return nil
+}
+
+func (cluster *Cluster) WriteToFile(filename string) error {
+	contents, err := json.MarshalIndent(cluster, "", "  ")
+	if err != nil {
+		return err
+	}
+	fileHandle, err := iohelper.OpenFileForWriting(filename)
+	if err != nil {
+		return err
+	}
+	_, err = fileHandle.Write(contents)
+	closeErr := fileHandle.Close()
+	if err != nil {
+		return errors.Errorf("Unable to write cluster topology to %s: %s", filename, err)
+	}
+	return closeErr
+}
+
+func NewClusterFromFile(filename string) (*Cluster, error) {
+	contents, err := operating.System.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Errorf("Unable to read cluster topology from %s: %s", filename, err)
+	}
+	cluster := &Cluster{}
+	err = json.Unmarshal(contents, cluster)
+	if err != nil {
+		return nil, errors.Errorf("Unable to parse cluster topology from %s: %s", filename, err)
+	}
+	return cluster, nil
+}
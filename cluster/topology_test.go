@@ -0,0 +1,86 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cluster_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cluster/topology tests", func() {
+	coordinatorSeg := cluster.SegConfig{DbID: 1, ContentID: -1, Role: "p", PreferredRole: "p", Mode: "n", Status: "u", Port: 5432, Hostname: "cdw", Address: "cdw", DataDir: "/data/gpseg-1"}
+	primarySeg := cluster.SegConfig{DbID: 2, ContentID: 0, Role: "p", PreferredRole: "p", Mode: "s", Status: "u", Port: 6000, Hostname: "sdw1", Address: "sdw1", DataDir: "/data/primary/gpseg0"}
+	mirrorSeg := cluster.SegConfig{DbID: 3, ContentID: 0, Role: "m", PreferredRole: "m", Mode: "s", Status: "u", Port: 7000, Hostname: "sdw2", Address: "sdw2", DataDir: "/data/mirror/gpseg0"}
+	var testCluster *cluster.Cluster
+
+	BeforeEach(func() {
+		testCluster = cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, primarySeg, mirrorSeg})
+	})
+
+	Describe("MarshalJSON and UnmarshalJSON", func() {
+		It("round-trips the cluster topology", func() {
+			data, err := json.Marshal(testCluster)
+			Expect(err).ToNot(HaveOccurred())
+			var result cluster.Cluster
+			Expect(json.Unmarshal(data, &result)).To(Succeed())
+			Expect(result.Segments).To(Equal(testCluster.Segments))
+			Expect(result.ContentIDs).To(Equal([]int{-1, 0}))
+			Expect(result.Hostnames).To(Equal([]string{"cdw", "sdw1", "sdw2"}))
+			Expect(result.GetDirForContent(0, "m")).To(Equal("/data/mirror/gpseg0"))
+			Expect(result.Executor).To(Equal(&cluster.GPDBExecutor{}))
+		})
+		It("includes the format version", func() {
+			data, err := json.Marshal(testCluster)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(HavePrefix(`{"format_version":1,"segments":[{"DbID":1,`))
+		})
+		It("returns an error for an unsupported format version", func() {
+			var result cluster.Cluster
+			err := json.Unmarshal([]byte(`{"format_version":2,"segments":[]}`), &result)
+			Expect(err).To(MatchError("Unsupported cluster topology format version 2"))
+		})
+	})
+	Describe("WriteToFile and NewClusterFromFile", func() {
+		It("reconstructs a cluster from a file", func() {
+			filename := filepath.Join(GinkgoT().TempDir(), "topology.json")
+			Expect(testCluster.WriteToFile(filename)).To(Succeed())
+			result, err := cluster.NewClusterFromFile(filename)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Segments).To(Equal(testCluster.Segments))
+			Expect(result.GetHostForContent(0)).To(Equal("sdw1"))
+		})
+		It("returns an error if the file does not exist", func() {
+			_, err := cluster.NewClusterFromFile("/nonexistent/topology.json")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("Unable to read cluster topology from /nonexistent/topology.json"))
+		})
+		It("returns an error if the file is not valid", func() {
+			filename := filepath.Join(GinkgoT().TempDir(), "topology.json")
+			Expect(os.WriteFile(filename, []byte("not json"), 0644)).To(Succeed())
+			_, err := cluster.NewClusterFromFile(filename)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("Unable to parse cluster topology from"))
+		})
+	})
+})
@@ -0,0 +1,502 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+/*
+ * This file contains functions for loading a utility's configuration into a
+ * struct from, in increasing order of precedence, defaults given in struct
+ * tags, a YAML configuration file, GP_ environment variables, and command-line
+ * flags, so that every utility layers its settings the same way.
+ */
+
+import (
+	"encoding"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const DEFAULT_ENV_PREFIX = "GP_"
+
+/*
+ * Options controls where Load looks for settings.
+ *
+ * File:              A YAML configuration file; JSON is also accepted, as it
+ *                    is a subset of YAML.  If empty, no file is read.
+ * IgnoreMissingFile: If set, a File that does not exist is skipped instead of
+ *                    being an error, for utilities with a default file path.
+ * EnvPrefix:         The prefix of environment variable names;
+ *                    DEFAULT_ENV_PREFIX if empty.
+ * Flags:             The command-line flags to read, if any.
+ */
+type Options struct {
+	File              string
+	IgnoreMissingFile bool
+	EnvPrefix         string
+	Flags             FlagSource
+}
+
+/*
+ * A FlagSource returns the value of a command-line flag and whether it was
+ * set on the command line; flags left at their defaults must not override
+ * settings from the file or environment.  StdFlags adapts a flag.FlagSet, and
+ * a pflag.FlagSet, as used with cobra, can be adapted with FlagSourceFunc:
+ *
+ *   config.FlagSourceFunc(func(name string) (string, bool) {
+ *       f := flags.Lookup(name)
+ *       if f == nil || !f.Changed {
+ *           return "", false
+ *       }
+ *       return f.Value.String(), true
+ *   })
+ */
+type FlagSource interface {
+	LookupFlag(name string) (string, bool)
+}
+
+type FlagSourceFunc func(name string) (string, bool)
+
+func (f FlagSourceFunc) LookupFlag(name string) (string, bool) {
+	return f(name)
+}
+
+// StdFlags returns a FlagSource for the flags set on the command line in flags, which must already be parsed
+func StdFlags(flags *flag.FlagSet) FlagSource {
+	return FlagSourceFunc(func(name string) (string, bool) {
+		value, found := "", false
+		flags.Visit(func(f *flag.Flag) {
+			if f.Name == name {
+				value, found = f.Value.String(), true
+			}
+		})
+		return value, found
+	})
+}
+
+/*
+ * A Validator is called by Load once all settings have been loaded.  The
+ * configuration struct and any struct within it may implement Validator, to
+ * check e.g. that a port is in range or that two settings are not both set.
+ * Nested structs are validated before the structs containing them.
+ */
+type Validator interface {
+	Validate() error
+}
+
+type Source int
+
+const (
+	DEFAULT Source = iota
+	FILE
+	ENV
+	FLAG
+)
+
+func (source Source) String() string {
+	switch source {
+	case FILE:
+		return "file"
+	case ENV:
+		return "environment"
+	case FLAG:
+		return "flag"
+	default:
+		return "default"
+	}
+}
+
+/*
+ * A Setting records the value a field ended up with and where that value came
+ * from.  Origin names the file, environment variable, or flag that set it, and
+ * Value is masked for fields tagged `secret:"true"`.
+ */
+type Setting struct {
+	Key    string
+	Value  string
+	Source Source
+	Origin string
+}
+
+func (setting Setting) String() string {
+	if setting.Origin == "" {
+		return fmt.Sprintf("%s = %s (%s)", setting.Key, setting.Value, setting.Source)
+	}
+	return fmt.Sprintf("%s = %s (%s %s)", setting.Key, setting.Value, setting.Source, setting.Origin)
+}
+
+// Effective holds the Setting for every field Load filled in, in the order the fields are declared
+type Effective struct {
+	Settings []Setting
+}
+
+func (effective *Effective) Get(key string) (Setting, bool) {
+	for _, setting := range effective.Settings {
+		if setting.Key == key {
+			return setting, true
+		}
+	}
+	return Setting{}, false
+}
+
+func (effective *Effective) String() string {
+	lines := make([]string, len(effective.Settings))
+	for i, setting := range effective.Settings {
+		lines[i] = setting.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Log writes the effective configuration to the log at verbose level, for debugging how a setting was chosen
+func (effective *Effective) Log() {
+	gplog.Verbose("Effective configuration:")
+	for _, setting := range effective.Settings {
+		gplog.Verbose("  %s", setting)
+	}
+}
+
+/*
+ * A field is one setting in the configuration struct.  Structs that do not
+ * implement encoding.TextUnmarshaler, as time.Time does, are not settings
+ * themselves, but group the settings within them under their own key.
+ */
+type field struct {
+	value        reflect.Value
+	env          string
+	flag         string
+	secret       bool
+	defaultValue *string
+	setting      Setting
+}
+
+/*
+ * Load fills in target, which must be a pointer to a struct, and returns where
+ * each setting came from.  Each exported field is a setting named by its
+ * `config` tag, or by its name in snake_case if it has none; `config:"-"`
+ * skips the field.  The name of a field within a nested struct is prefixed
+ * with that struct's name.  For a field max_connections within a struct field
+ * named backup, Load uses, in increasing order of precedence:
+ *
+ *   1. The field's `default` tag, if any, or else the value already in target
+ *   2. The key "max_connections" within the mapping "backup" in the file
+ *   3. The environment variable GP_BACKUP_MAX_CONNECTIONS
+ *   4. The flag --backup.max-connections
+ *
+ * The `env` and `flag` tags override the environment variable and flag names,
+ * and may be "-" to ignore the environment or flags for that field.  Values
+ * from tags, the environment, and flags are parsed as their field's type;
+ * slices are comma-separated lists.  Keys in the file that do not match any
+ * field are an error, to catch misspellings.  Finally, every Validator in
+ * target is called.
+ */
+func Load(target interface{}, options Options) (*Effective, error) {
+	targetValue := reflect.ValueOf(target)
+	if targetValue.Kind() != reflect.Pointer || targetValue.IsNil() || targetValue.Elem().Kind() != reflect.Struct {
+		return nil, errors.Errorf("Unable to load configuration into %T: target must be a pointer to a struct", target)
+	}
+	prefix := options.EnvPrefix
+	if prefix == "" {
+		prefix = DEFAULT_ENV_PREFIX
+	}
+	fields, err := collectFields(targetValue.Elem(), nil, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, field := range fields {
+		if err := field.applyDefault(); err != nil {
+			return nil, err
+		}
+	}
+	if options.File != "" {
+		if err := applyFile(fields, options.File, options.IgnoreMissingFile); err != nil {
+			return nil, err
+		}
+	}
+	for _, field := range fields {
+		if field.env == "" {
+			continue
+		}
+		if value, ok := operating.System.LookupEnv(field.env); ok {
+			if err := field.set(value, ENV, field.env); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if options.Flags != nil {
+		for _, field := range fields {
+			if field.flag == "" {
+				continue
+			}
+			if value, ok := options.Flags.LookupFlag(field.flag); ok {
+				if err := field.set(value, FLAG, "--"+field.flag); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if err := validate(targetValue.Elem()); err != nil {
+		return nil, errors.Wrap(err, "Invalid configuration")
+	}
+
+	effective := &Effective{Settings: make([]Setting, len(fields))}
+	for i, field := range fields {
+		effective.Settings[i] = field.setting
+		effective.Settings[i].Value = field.displayValue()
+	}
+	return effective, nil
+}
+
+func MustLoad(target interface{}, options Options) *Effective {
+	effective, err := Load(target, options)
+	gplog.FatalOnError(err)
+	return effective
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+func isGroup(value reflect.Value) bool {
+	return value.Kind() == reflect.Struct && !reflect.PointerTo(value.Type()).Implements(textUnmarshalerType)
+}
+
+func collectFields(structValue reflect.Value, path []string, envPrefix string) ([]*field, error) {
+	fields := make([]*field, 0)
+	structType := structValue.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		structField := structType.Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+		name := structField.Tag.Get("config")
+		if name == "-" {
+			continue
+		} else if name == "" {
+			name = snakeCase(structField.Name)
+		}
+		fieldPath := append(append([]string{}, path...), name)
+		value := structValue.Field(i)
+		if isGroup(value) {
+			nested, err := collectFields(value, fieldPath, envPrefix)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, nested...)
+			continue
+		}
+		if !isSupported(value.Type()) {
+			return nil, errors.Errorf("Unable to load configuration: field %s has unsupported type %s", structField.Name, value.Type())
+		}
+
+		key := strings.Join(fieldPath, ".")
+		newField := &field{value: value, setting: Setting{Key: key, Source: DEFAULT}}
+		newField.secret = structField.Tag.Get("secret") == "true"
+		switch env := structField.Tag.Get("env"); env {
+		case "-":
+		case "":
+			newField.env = envPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+		default:
+			newField.env = env
+		}
+		switch flagName := structField.Tag.Get("flag"); flagName {
+		case "-":
+		case "":
+			newField.flag = strings.ReplaceAll(key, "_", "-")
+		default:
+			newField.flag = flagName
+		}
+		if defaultValue, ok := structField.Tag.Lookup("default"); ok {
+			newField.defaultValue = &defaultValue
+		}
+		fields = append(fields, newField)
+	}
+	return fields, nil
+}
+
+/*
+ * snakeCase converts a field name to a setting name, keeping acronyms
+ * together, e.g. "MaxConnections" to "max_connections" and "PGPort" to
+ * "pg_port".
+ */
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var builder strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			previous := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextIsLower) {
+				builder.WriteRune('_')
+			}
+		}
+		builder.WriteRune(unicode.ToLower(r))
+	}
+	return builder.String()
+}
+
+func (field *field) applyDefault() error {
+	if field.defaultValue == nil {
+		return nil
+	}
+	if err := setFromString(field.value, *field.defaultValue); err != nil {
+		return errors.Wrapf(err, "Invalid default value %q for %s", *field.defaultValue, field.setting.Key)
+	}
+	return nil
+}
+
+func (field *field) set(value string, source Source, origin string) error {
+	if err := setFromString(field.value, value); err != nil {
+		return errors.Wrapf(err, "Invalid value %q for %s from %s %s", value, field.setting.Key, source, origin)
+	}
+	field.setting.Source = source
+	field.setting.Origin = origin
+	return nil
+}
+
+func (field *field) displayValue() string {
+	if field.secret {
+		if field.value.IsZero() {
+			return ""
+		}
+		return "********"
+	}
+	return formatValue(field.value)
+}
+
+func applyFile(fields []*field, path string, ignoreMissing bool) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json", ".conf", "":
+	default:
+		return errors.Errorf("Unable to load configuration file %s: only YAML and JSON files are supported", path)
+	}
+	contents, err := operating.System.ReadFile(path)
+	if operating.System.IsNotExist(err) && ignoreMissing {
+		gplog.Verbose("Configuration file %s does not exist, skipping", path)
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "Unable to read configuration file %s", path)
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(contents, &document); err != nil {
+		return errors.Wrapf(err, "Unable to parse configuration file %s", path)
+	}
+	if len(document.Content) == 0 {
+		return nil
+	}
+	values := make(map[string]*yaml.Node)
+	if err := flattenNode(document.Content[0], nil, values); err != nil {
+		return errors.Wrapf(err, "Unable to parse configuration file %s", path)
+	}
+
+	for _, field := range fields {
+		key := field.setting.Key
+		node, ok := values[key]
+		if !ok {
+			continue
+		}
+		delete(values, key)
+		if err := decodeNode(node, field.value); err != nil {
+			return errors.Wrapf(err, "Invalid value for %s in configuration file %s", key, path)
+		}
+		field.setting.Source = FILE
+		field.setting.Origin = path
+	}
+	if len(values) > 0 {
+		unknown := make([]string, 0, len(values))
+		for key := range values {
+			unknown = append(unknown, key)
+		}
+		sort.Strings(unknown)
+		return errors.Errorf("Unknown settings in configuration file %s: %s", path, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+/*
+ * flattenNode maps the dotted key of each value in a YAML mapping to its node,
+ * descending into nested mappings; a mapping is never itself a setting.
+ */
+func flattenNode(node *yaml.Node, path []string, values map[string]*yaml.Node) error {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind != yaml.MappingNode {
+		if len(path) == 0 {
+			return errors.Errorf("line %d: expected a mapping of settings", node.Line)
+		}
+		values[strings.Join(path, ".")] = node
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyPath := append(append([]string{}, path...), node.Content[i].Value)
+		if err := flattenNode(node.Content[i+1], keyPath, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ * decodeNode decodes a YAML value into a field.  Scalars are parsed the same
+ * way as environment variables and flags, so that e.g. durations and
+ * TextUnmarshalers behave the same in every layer.
+ */
+func decodeNode(node *yaml.Node, value reflect.Value) error {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		value.SetZero()
+		return nil
+	} else if node.Kind == yaml.ScalarNode {
+		return setFromString(value, node.Value)
+	} else if node.Kind == yaml.SequenceNode && value.Kind() == reflect.Slice {
+		slice := reflect.MakeSlice(value.Type(), len(node.Content), len(node.Content))
+		for i, element := range node.Content {
+			if err := decodeNode(element, slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		value.Set(slice)
+		return nil
+	}
+	return errors.Errorf("line %d: expected a value of type %s", node.Line, value.Type())
+}
+
+func validate(value reflect.Value) error {
+	structType := value.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		if !structType.Field(i).IsExported() || structType.Field(i).Tag.Get("config") == "-" {
+			continue
+		}
+		if fieldValue := value.Field(i); isGroup(fieldValue) {
+			if err := validate(fieldValue); err != nil {
+				return err
+			}
+		}
+	}
+	if validator, ok := value.Addr().Interface().(Validator); ok {
+		return validator.Validate()
+	}
+	return nil
+}
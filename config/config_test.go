@@ -0,0 +1,271 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/config"
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type backupConfig struct {
+	Dir         string `default:"/backups"`
+	Jobs        int    `default:"1"`
+	Compression bool
+}
+
+func (backup *backupConfig) Validate() error {
+	if backup.Jobs < 1 {
+		return errors.Errorf("backup.jobs must be at least 1, got %d", backup.Jobs)
+	}
+	return nil
+}
+
+type testConfig struct {
+	Host           string        `default:"localhost"`
+	PGPort         int           `default:"5432"`
+	DBName         string        `config:"dbname"`
+	Password       string        `secret:"true"`
+	Timeout        time.Duration `default:"30s"`
+	IncludeTables  []string
+	MaxConnections uint16
+	Since          time.Time
+	Backup         backupConfig
+	Internal       string `config:"-"`
+	Verbose        bool   `env:"-" flag:"v"`
+	unexported     string
+}
+
+func (cfg *testConfig) Validate() error {
+	if cfg.PGPort < 1 || cfg.PGPort > 65535 {
+		return errors.Errorf("pg_port must be between 1 and 65535, got %d", cfg.PGPort)
+	}
+	return nil
+}
+
+var _ = Describe("config tests", func() {
+	var (
+		cfg         testConfig
+		configDir   string
+		configFile  string
+		flags       *flag.FlagSet
+		environment map[string]string
+	)
+	writeConfig := func(contents string) {
+		Expect(os.WriteFile(configFile, []byte(contents), 0644)).To(Succeed())
+	}
+	load := func(options config.Options) (*config.Effective, error) {
+		var effective *config.Effective
+		var err error
+		operating.WithEnv(environment, func() {
+			effective, err = config.Load(&cfg, options)
+		})
+		return effective, err
+	}
+
+	BeforeEach(func() {
+		cfg = testConfig{}
+		var err error
+		configDir, err = os.MkdirTemp("", "config")
+		Expect(err).ToNot(HaveOccurred())
+		configFile = filepath.Join(configDir, "gpbackup.yaml")
+		flags = flag.NewFlagSet("gpbackup", flag.ContinueOnError)
+		flags.String("host", "", "")
+		flags.Int("pg-port", 0, "")
+		flags.String("backup.dir", "", "")
+		flags.Bool("v", false, "")
+		environment = map[string]string{
+			"GP_HOST": "", "GP_PG_PORT": "", "GP_DBNAME": "", "GP_PASSWORD": "", "GP_BACKUP_JOBS": "", "GP_VERBOSE": "",
+		}
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		os.RemoveAll(configDir)
+	})
+
+	Describe("Load", func() {
+		It("uses the defaults if nothing else is set", func() {
+			cfg.DBName = "postgres"
+			effective, err := load(config.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Host).To(Equal("localhost"))
+			Expect(cfg.PGPort).To(Equal(5432))
+			Expect(cfg.DBName).To(Equal("postgres"))
+			Expect(cfg.Timeout).To(Equal(30 * time.Second))
+			Expect(cfg.Backup).To(Equal(backupConfig{Dir: "/backups", Jobs: 1}))
+			Expect(effective.Settings[0]).To(Equal(config.Setting{Key: "host", Value: "localhost", Source: config.DEFAULT}))
+		})
+		It("names settings after their fields in snake_case", func() {
+			effective, err := load(config.Options{})
+			Expect(err).ToNot(HaveOccurred())
+			keys := make([]string, 0)
+			for _, setting := range effective.Settings {
+				keys = append(keys, setting.Key)
+			}
+			Expect(keys).To(Equal([]string{"host", "pg_port", "dbname", "password", "timeout", "include_tables",
+				"max_connections", "since", "backup.dir", "backup.jobs", "backup.compression", "verbose"}))
+		})
+		It("overrides the defaults with the configuration file", func() {
+			writeConfig(`
+host: cdw
+pg_port: 6000
+timeout: 1m
+include_tables: [public.sales, public.orders]
+since: 2017-01-01T01:01:01Z
+backup:
+  dir: /data/backups
+  compression: true
+`)
+			_, err := load(config.Options{File: configFile})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Host).To(Equal("cdw"))
+			Expect(cfg.PGPort).To(Equal(6000))
+			Expect(cfg.Timeout).To(Equal(time.Minute))
+			Expect(cfg.IncludeTables).To(Equal([]string{"public.sales", "public.orders"}))
+			Expect(cfg.Since).To(Equal(time.Date(2017, time.January, 1, 1, 1, 1, 0, time.UTC)))
+			Expect(cfg.Backup).To(Equal(backupConfig{Dir: "/data/backups", Jobs: 1, Compression: true}))
+		})
+		It("accepts JSON configuration files", func() {
+			configFile = filepath.Join(configDir, "gpbackup.json")
+			writeConfig(`{"host": "cdw", "backup": {"jobs": 4}}`)
+			_, err := load(config.Options{File: configFile})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Host).To(Equal("cdw"))
+			Expect(cfg.Backup.Jobs).To(Equal(4))
+		})
+		It("overrides the configuration file with the environment", func() {
+			writeConfig("host: cdw\nbackup:\n  jobs: 4\n")
+			environment["GP_HOST"] = "scdw"
+			environment["GP_BACKUP_JOBS"] = "8"
+			environment["GP_VERBOSE"] = "true"
+			effective, err := load(config.Options{File: configFile})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Host).To(Equal("scdw"))
+			Expect(cfg.Backup.Jobs).To(Equal(8))
+			Expect(cfg.Verbose).To(BeFalse())
+			setting, _ := effective.Get("backup.jobs")
+			Expect(setting).To(Equal(config.Setting{Key: "backup.jobs", Value: "8", Source: config.ENV, Origin: "GP_BACKUP_JOBS"}))
+		})
+		It("uses the given environment variable prefix", func() {
+			environment["GPBACKUP_HOST"] = "scdw"
+			_, err := load(config.Options{EnvPrefix: "GPBACKUP_"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Host).To(Equal("scdw"))
+		})
+		It("overrides the environment with flags set on the command line", func() {
+			environment["GP_HOST"] = "scdw"
+			environment["GP_PG_PORT"] = "6000"
+			Expect(flags.Parse([]string{"--host", "sdw1", "--backup.dir=/tmp/backups", "-v"})).To(Succeed())
+			effective, err := load(config.Options{Flags: config.StdFlags(flags)})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Host).To(Equal("sdw1"))
+			Expect(cfg.PGPort).To(Equal(6000))
+			Expect(cfg.Backup.Dir).To(Equal("/tmp/backups"))
+			Expect(cfg.Verbose).To(BeTrue())
+			setting, _ := effective.Get("host")
+			Expect(setting.String()).To(Equal("host = sdw1 (flag --host)"))
+		})
+		It("accepts any flag library through FlagSourceFunc", func() {
+			lookup := config.FlagSourceFunc(func(name string) (string, bool) {
+				if name == "include-tables" {
+					return "public.sales, public.orders", true
+				}
+				return "", false
+			})
+			_, err := load(config.Options{Flags: lookup})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.IncludeTables).To(Equal([]string{"public.sales", "public.orders"}))
+		})
+		It("skips a missing configuration file only if asked to", func() {
+			_, err := load(config.Options{File: configFile})
+			Expect(err).To(MatchError(ContainSubstring("Unable to read configuration file " + configFile)))
+
+			_, err = load(config.Options{File: configFile, IgnoreMissingFile: true})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Host).To(Equal("localhost"))
+		})
+		It("returns an error for unknown settings in the configuration file", func() {
+			writeConfig("hots: cdw\nbackup:\n  dir: /backups\n  job: 4\n")
+			_, err := load(config.Options{File: configFile})
+			Expect(err).To(MatchError("Unknown settings in configuration file " + configFile + ": backup.job, hots"))
+		})
+		It("returns an error for unsupported configuration file formats", func() {
+			_, err := load(config.Options{File: filepath.Join(configDir, "gpbackup.toml")})
+			Expect(err).To(MatchError(ContainSubstring("only YAML and JSON files are supported")))
+		})
+		It("returns an error naming the source of an invalid value", func() {
+			writeConfig("pg_port: many\n")
+			_, err := load(config.Options{File: configFile})
+			Expect(err).To(MatchError(ContainSubstring("Invalid value for pg_port in configuration file " + configFile)))
+
+			environment["GP_MAX_CONNECTIONS"] = "70000"
+			_, err = load(config.Options{})
+			Expect(err).To(MatchError(`Invalid value "70000" for max_connections from environment GP_MAX_CONNECTIONS: "70000" is not a non-negative integer of at most 16 bits`))
+		})
+		It("runs every validator, nested structs first", func() {
+			environment["GP_PG_PORT"] = "70000"
+			environment["GP_BACKUP_JOBS"] = "0"
+			_, err := load(config.Options{})
+			Expect(err).To(MatchError("Invalid configuration: backup.jobs must be at least 1, got 0"))
+
+			environment["GP_BACKUP_JOBS"] = "2"
+			_, err = load(config.Options{})
+			Expect(err).To(MatchError("Invalid configuration: pg_port must be between 1 and 65535, got 70000"))
+		})
+		It("requires a pointer to a struct", func() {
+			_, err := config.Load(cfg, config.Options{})
+			Expect(err).To(MatchError("Unable to load configuration into config_test.testConfig: target must be a pointer to a struct"))
+		})
+		It("rejects fields of unsupported types", func() {
+			var unsupported struct{ Hosts map[string]int }
+			_, err := config.Load(&unsupported, config.Options{})
+			Expect(err).To(MatchError("Unable to load configuration: field Hosts has unsupported type map[string]int"))
+		})
+	})
+	Describe("Effective", func() {
+		It("logs every setting and where it came from, masking secrets", func() {
+			writeConfig("password: hunter2\nmax_connections: 10\n")
+			environment["GP_DBNAME"] = "sales"
+			effective, err := load(config.Options{File: configFile})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Password).To(Equal("hunter2"))
+
+			_, _, testLogfile := testhelper.SetupTestLogger()
+			gplog.SetLogFileVerbosity(gplog.LOGVERBOSE)
+			effective.Log()
+			Expect(testLogfile).To(gbytes.Say("Effective configuration:"))
+			Expect(testLogfile).To(gbytes.Say(`  host = localhost \(default\)`))
+			Expect(testLogfile).To(gbytes.Say(`  dbname = sales \(environment GP_DBNAME\)`))
+			Expect(testLogfile).To(gbytes.Say(`  password = \*\*\*\*\*\*\*\* \(file ` + configFile + `\)`))
+			Expect(testLogfile).To(gbytes.Say(`  timeout = 30s \(default\)`))
+			Expect(testLogfile).To(gbytes.Say(`  max_connections = 10 \(file`))
+			Expect(testLogfile).ToNot(gbytes.Say("hunter2"))
+		})
+	})
+})
@@ -0,0 +1,137 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+/*
+ * This file contains functions for converting settings between strings and
+ * the types of the fields they are loaded into.
+ */
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func isSupported(fieldType reflect.Type) bool {
+	if reflect.PointerTo(fieldType).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch fieldType.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return fieldType.Elem().Kind() != reflect.Slice && isSupported(fieldType.Elem())
+	}
+	return false
+}
+
+/*
+ * setFromString parses value as the type of target.  Durations are in
+ * time.ParseDuration format, times are in RFC 3339 format, as parsed by
+ * time.Time.UnmarshalText, and slices are comma-separated, with an empty
+ * string giving an empty slice.
+ */
+func setFromString(target reflect.Value, value string) error {
+	if unmarshaler, ok := target.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(value))
+	}
+	if target.Type() == durationType {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		target.SetInt(int64(parsed))
+		return nil
+	}
+
+	switch target.Kind() {
+	case reflect.String:
+		target.SetString(value)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.Errorf("%q is not a boolean", value)
+		}
+		target.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, target.Type().Bits())
+		if err != nil {
+			return errors.Errorf("%q is not an integer between %d and %d", value, int64(-1)<<(target.Type().Bits()-1), uint64(1)<<(target.Type().Bits()-1)-1)
+		}
+		target.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(value, 10, target.Type().Bits())
+		if err != nil {
+			return errors.Errorf("%q is not a non-negative integer of at most %d bits", value, target.Type().Bits())
+		}
+		target.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, target.Type().Bits())
+		if err != nil {
+			return errors.Errorf("%q is not a number", value)
+		}
+		target.SetFloat(parsed)
+	case reflect.Slice:
+		elements := make([]string, 0)
+		if strings.TrimSpace(value) != "" {
+			elements = strings.Split(value, ",")
+		}
+		slice := reflect.MakeSlice(target.Type(), len(elements), len(elements))
+		for i, element := range elements {
+			if err := setFromString(slice.Index(i), strings.TrimSpace(element)); err != nil {
+				return err
+			}
+		}
+		target.Set(slice)
+	default:
+		return errors.Errorf("unsupported type %s", target.Type())
+	}
+	return nil
+}
+
+// formatValue formats value for display, in the format setFromString parses
+func formatValue(value reflect.Value) string {
+	if marshaler, ok := value.Interface().(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		if err == nil {
+			return string(text)
+		}
+	}
+	switch {
+	case value.Type() == durationType:
+		return time.Duration(value.Int()).String()
+	case value.Kind() == reflect.Slice:
+		elements := make([]string, value.Len())
+		for i := range elements {
+			elements[i] = formatValue(value.Index(i))
+		}
+		return strings.Join(elements, ",")
+	}
+	return fmt.Sprint(value.Interface())
+}
@@ -0,0 +1,138 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dbconn
+
+/*
+ * This file contains functions for running statements in the background, so
+ * that independent queries, such as the catalog queries for different kinds
+ * of objects, can run at the same time on different connections of the pool.
+ */
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+/*
+ * An AsyncQuery is a handle for a statement started by ExecAsync or
+ * SelectAsync.  Done is closed when the statement finishes, after which Wait
+ * returns its error immediately.
+ *
+ * A background statement runs on the pool connection it is started on, not
+ * on a connection of its own, so that it sees that connection's session
+ * settings and transaction.  A connection runs one statement at a time, so
+ * a background statement waits for any other statement on the same
+ * connection, background or not, and statements only overlap if they are
+ * started on different connections; Connect with as many connections as
+ * statements that should run at once.
+ */
+type AsyncQuery struct {
+	done   chan struct{}
+	cancel context.CancelFunc
+	result sql.Result
+	err    error
+}
+
+/*
+ * startAsync runs statement in a new goroutine.  Background statements on the
+ * same connection run one after another in no particular order.  The caller
+ * is found before the goroutine starts and passed in ctx for the statement
+ * hooks, since the goroutine's stack does not lead back to it.
+ */
+func (dbconn *DBConn) startAsync(connNum int, statement func(ctx context.Context) (sql.Result, error)) *AsyncQuery {
+	ctx, cancel := context.WithCancel(context.Background())
+	if len(dbconn.StatementHooks) > 0 {
+		ctx = context.WithValue(ctx, statementCallerKey{}, statementCaller())
+	}
+	query := &AsyncQuery{done: make(chan struct{}), cancel: cancel}
+	var lock *sync.Mutex
+	if connNum < len(dbconn.asyncLocks) {
+		lock = &dbconn.asyncLocks[connNum]
+	}
+	go func() {
+		defer close(query.done)
+		defer cancel()
+		if lock != nil {
+			lock.Lock()
+			defer lock.Unlock()
+		}
+		query.result, query.err = statement(ctx)
+	}()
+	return query
+}
+
+// ExecAsync is ExecContext run in the background; the result is available from Result once the statement finishes
+func (dbconn *DBConn) ExecAsync(query string, whichConn ...int) *AsyncQuery {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	return dbconn.startAsync(connNum, func(ctx context.Context) (sql.Result, error) {
+		return dbconn.ExecContext(ctx, query, connNum)
+	})
+}
+
+// SelectAsync is SelectContext run in the background; destination must not be used until the statement finishes
+func (dbconn *DBConn) SelectAsync(destination interface{}, query string, whichConn ...int) *AsyncQuery {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	return dbconn.startAsync(connNum, func(ctx context.Context) (sql.Result, error) {
+		return nil, dbconn.SelectContext(ctx, destination, query, connNum)
+	})
+}
+
+func (query *AsyncQuery) Done() <-chan struct{} {
+	return query.done
+}
+
+// Cancel cancels the statement if it has not finished, in which case Wait returns the driver's cancellation error
+func (query *AsyncQuery) Cancel() {
+	query.cancel()
+}
+
+/*
+ * Wait waits for the statement to finish and returns its error.  If ctx is
+ * done first, Wait returns ctx's error instead, and the statement keeps
+ * running unless it is cancelled.
+ */
+func (query *AsyncQuery) Wait(ctx context.Context) error {
+	select {
+	case <-query.done:
+		return query.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Result returns the result of a statement started by ExecAsync, or nil if it has not finished or failed
+func (query *AsyncQuery) Result() sql.Result {
+	select {
+	case <-query.done:
+		return query.result
+	default:
+		return nil
+	}
+}
+
+// WaitAll waits for every query to finish, or for ctx to be done, and returns the first error in the order given
+func WaitAll(ctx context.Context, queries ...*AsyncQuery) error {
+	var firstErr error
+	for _, query := range queries {
+		if err := query.Wait(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
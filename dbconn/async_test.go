@@ -0,0 +1,140 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dbconn_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/apache/cloudberry-go-libs/dbconn"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/async tests", func() {
+	Describe("DBConn.ExecAsync", func() {
+		It("runs a statement in the background and makes its result available", func() {
+			mock.ExpectExec("INSERT (.*)").WillReturnResult(sqlmock.NewResult(0, 3))
+			query := connection.ExecAsync("INSERT INTO pg_tables VALUES ('schema', 'table')")
+			Expect(query.Wait(context.Background())).To(Succeed())
+			Eventually(query.Done()).Should(BeClosed())
+			rowsAffected, err := query.Result().RowsAffected()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rowsAffected).To(Equal(int64(3)))
+		})
+		It("passes the code that started the statement to the statement hooks", func() {
+			callers := make(chan string, 1)
+			connection.StatementHooks = []dbconn.StatementHook{dbconn.StatementHookFunc(func(statement *dbconn.Statement) error {
+				callers <- statement.Caller
+				return nil
+			})}
+			defer func() { connection.StatementHooks = nil }()
+			mock.ExpectExec("INSERT (.*)").WillReturnResult(sqlmock.NewResult(0, 1))
+			Expect(connection.ExecAsync("INSERT INTO pg_tables VALUES ('schema', 'table')").Wait(context.Background())).To(Succeed())
+			Expect(<-callers).To(MatchRegexp(`^github.com/apache/cloudberry-go-libs/dbconn_test\..* \(async_test.go:\d+\)$`))
+		})
+		It("returns the statement's error from Wait", func() {
+			mock.ExpectExec("INSERT (.*)").WillReturnError(errors.New("relation does not exist"))
+			query := connection.ExecAsync("INSERT INTO missing VALUES (1)")
+			Expect(query.Wait(context.Background())).To(MatchError("relation does not exist"))
+			Expect(query.Result()).To(BeNil())
+		})
+		It("runs within the connection's transaction", func() {
+			ExpectBegin(mock)
+			mock.ExpectExec("INSERT (.*)").WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+			connection.MustBegin()
+			Expect(connection.ExecAsync("INSERT INTO pg_tables VALUES ('schema', 'table')").Wait(context.Background())).To(Succeed())
+			connection.MustCommit()
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("stops the statement when it is cancelled", func() {
+			mock.ExpectExec("SELECT pg_sleep(.*)").WillDelayFor(time.Minute).WillReturnResult(sqlmock.NewResult(0, 0))
+			query := connection.ExecAsync("SELECT pg_sleep(60)")
+			Consistently(query.Done(), 50*time.Millisecond).ShouldNot(BeClosed())
+			query.Cancel()
+			Expect(query.Wait(context.Background())).To(MatchError(sqlmock.ErrCancelled))
+		})
+		It("returns from Wait when the context is done, leaving the statement running", func() {
+			mock.ExpectExec("SELECT pg_sleep(.*)").WillDelayFor(200 * time.Millisecond).WillReturnResult(sqlmock.NewResult(0, 0))
+			query := connection.ExecAsync("SELECT pg_sleep(1)")
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			Expect(query.Wait(ctx)).To(MatchError(context.DeadlineExceeded))
+			Expect(query.Wait(context.Background())).To(Succeed())
+		})
+	})
+	Describe("DBConn.SelectAsync", func() {
+		It("fills in the destination once the statement finishes", func() {
+			rows := sqlmock.NewRows([]string{"schemaname", "tablename"}).
+				AddRow("schema1", "table1").
+				AddRow("schema2", "table2")
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(rows)
+			testSlice := make([]struct {
+				Schemaname string
+				Tablename  string
+			}, 0)
+
+			query := connection.SelectAsync(&testSlice, "SELECT schemaname, tablename FROM two_columns ORDER BY schemaname LIMIT 2")
+			Expect(query.Wait(context.Background())).To(Succeed())
+			Expect(testSlice).To(HaveLen(2))
+			Expect(testSlice[1].Tablename).To(Equal("table2"))
+			Expect(query.Result()).To(BeNil())
+		})
+	})
+	Describe("WaitAll", func() {
+		It("overlaps statements on different connections", func() {
+			connection, mock = testhelper.CreateAndConnectMockDB(2)
+			mock.MatchExpectationsInOrder(false)
+			mock.ExpectQuery("SELECT (.*) FROM pg_class").WillDelayFor(50 * time.Millisecond).
+				WillReturnRows(sqlmock.NewRows([]string{"relname"}).AddRow("table1"))
+			mock.ExpectQuery("SELECT (.*) FROM pg_proc").WillDelayFor(50 * time.Millisecond).
+				WillReturnRows(sqlmock.NewRows([]string{"proname"}).AddRow("function1"))
+			var relnames, pronames []string
+
+			queries := []*dbconn.AsyncQuery{
+				connection.SelectAsync(&relnames, "SELECT relname FROM pg_class", 0),
+				connection.SelectAsync(&pronames, "SELECT proname FROM pg_proc", 1),
+			}
+			Expect(dbconn.WaitAll(context.Background(), queries...)).To(Succeed())
+			Expect(relnames).To(Equal([]string{"table1"}))
+			Expect(pronames).To(Equal([]string{"function1"}))
+		})
+		It("returns the first error in the order given after every statement finishes", func() {
+			connection, mock = testhelper.CreateAndConnectMockDB(2)
+			mock.MatchExpectationsInOrder(false)
+			mock.ExpectExec("SELECT 1").WillReturnError(errors.New("first error"))
+			mock.ExpectExec("SELECT 2").WillDelayFor(50 * time.Millisecond).WillReturnError(errors.New("second error"))
+			first := connection.ExecAsync("SELECT 1", 0)
+			second := connection.ExecAsync("SELECT 2", 1)
+			Expect(dbconn.WaitAll(context.Background(), second, first)).To(MatchError("second error"))
+			Expect(first.Done()).To(BeClosed())
+		})
+		It("runs statements on the same connection one at a time", func() {
+			mock.MatchExpectationsInOrder(false)
+			mock.ExpectExec("SELECT 1").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("SELECT 2").WillReturnResult(sqlmock.NewResult(0, 0))
+			Expect(dbconn.WaitAll(context.Background(), connection.ExecAsync("SELECT 1"), connection.ExecAsync("SELECT 2"))).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+})
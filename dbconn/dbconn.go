@@ -9,11 +9,12 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/apache/cloudberry-go-libs/gplog"
 	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/retry"
 
 	/*
 	 * We previously used github.com/lib/pq as our Postgres driver,
@@ -51,6 +52,17 @@ type DBConn struct {
 	Port     int
 	Tx       []*sqlx.Tx
 	Version  GPDBVersion
+	// How to retry replacing a connection that fails validation; by default, it is not retried
+	ReconnectPolicy retry.Policy
+	// Called in order before each statement is run; see StatementHook
+	StatementHooks []StatementHook
+	// Whether to avoid session state, for connecting through a transaction-pooling proxy; see PoolerMode
+	PoolerMode     PoolerMode
+	notices        *noticeTracker
+	connStr        string
+	poolerDetected bool
+	// Held while an AsyncQuery runs on the corresponding connection
+	asyncLocks []sync.Mutex
 }
 
 /*
@@ -77,17 +89,19 @@ func NewDBConnFromEnvironment(dbname string) *DBConn {
 		gplog.Fatal(errors.New("No database provided"), "")
 	}
 
-	username := operating.System.Getenv("PGUSER")
+	// Each variable has a default, so invalid values are treated as unset
+	env, _ := operating.Environment()
+	username := env.PGUser
 	if username == "" {
 		currentUser, _ := operating.System.CurrentUser()
 		username = currentUser.Username
 	}
-	host := operating.System.Getenv("PGHOST")
+	host := env.PGHost
 	if host == "" {
 		host, _ = operating.System.Hostname()
 	}
-	port, err := strconv.Atoi(operating.System.Getenv("PGPORT"))
-	if err != nil {
+	port := env.PGPort
+	if port == 0 {
 		port = 5432
 	}
 
@@ -149,6 +163,9 @@ func (dbconn *DBConn) Close() {
 		dbconn.ConnPool = nil
 		dbconn.Tx = nil
 		dbconn.NumConns = 0
+		dbconn.notices = nil
+		dbconn.poolerDetected = false
+		dbconn.asyncLocks = nil
 	}
 }
 
@@ -197,11 +214,12 @@ func (dbconn *DBConn) Connect(numConns int, utilityMode ...bool) error {
 
 	dbname := EscapeConnectionParam(dbconn.DBName)
 	user := EscapeConnectionParam(dbconn.User)
-	krbsrvname := operating.System.Getenv("PGKRBSRVNAME")
+	env, _ := operating.Environment()
+	krbsrvname := env.PGKrbSrvName
 	if krbsrvname == "" {
 		krbsrvname = "postgres"
 	}
-	sslmode := operating.System.Getenv("PGSSLMODE")
+	sslmode := env.PGSSLMode
 	if sslmode == "" {
 		sslmode = "prefer"
 	}
@@ -209,13 +227,23 @@ func (dbconn *DBConn) Connect(numConns int, utilityMode ...bool) error {
 	// default_query_exec_mode=exec disables pgx's automatic prepared statement
 	// caching, which on GPDB4 caused cache lookup failures when an object was
 	// dropped and recreated within a single connection.
-	connStr := fmt.Sprintf(`user='%s' dbname='%s' krbsrvname='%s' host=%s port=%d sslmode='%s' default_query_exec_mode=exec`,
-		user, dbname, krbsrvname, dbconn.Host, dbconn.Port, sslmode)
+	// In pooler mode, the simple protocol avoids prepared statements entirely.
+	execMode := "exec"
+	if dbconn.PoolerMode == POOLER_MODE_ON {
+		execMode = "simple_protocol"
+	}
+	connStr := fmt.Sprintf(`user='%s' dbname='%s' krbsrvname='%s' host=%s port=%d sslmode='%s' default_query_exec_mode=%s`,
+		user, dbname, krbsrvname, dbconn.Host, dbconn.Port, sslmode, execMode)
 
-	dbconn.ConnPool = make([]*sqlx.DB, numConns)
 	if len(utilityMode) > 1 {
 		return errors.Errorf("The utility mode parameter accepts exactly one boolean value")
-	} else if len(utilityMode) == 1 && utilityMode[0] {
+	}
+	isUtilityMode := len(utilityMode) == 1 && utilityMode[0]
+	if isUtilityMode && dbconn.PoolerMode == POOLER_MODE_ON {
+		return errors.New("Cannot connect in utility mode through a connection pooler")
+	}
+	dbconn.ConnPool = make([]*sqlx.DB, numConns)
+	if isUtilityMode {
 		// The utility mode GUC differs between GPDB 7 and later (gp_role)
 		// and GPDB 6 and earlier (gp_session_role), and we don't get the
 		// database version until after the connection is established, so
@@ -237,26 +265,59 @@ func (dbconn *DBConn) Connect(numConns int, utilityMode ...bool) error {
 		}
 	}
 
+	_, capturesNotices := dbconn.Driver.(NoticeDriver)
+	notices := newNoticeTracker(numConns)
 	for i := 0; i < numConns; i++ {
-		conn, err := dbconn.Driver.Connect("pgx", connStr)
-		err = dbconn.handleConnectionError(err)
+		conn, err := dbconn.connectOne(connStr, notices, i)
 		if err != nil {
 			return err
 		}
-		conn.SetMaxOpenConns(1)
-		conn.SetMaxIdleConns(1)
 		dbconn.ConnPool[i] = conn
 	}
 	dbconn.Tx = make([]*sqlx.Tx, numConns)
+	dbconn.asyncLocks = make([]sync.Mutex, numConns)
 	dbconn.NumConns = numConns
+	dbconn.connStr = connStr
+	if capturesNotices {
+		dbconn.notices = notices
+	}
 	version, err := InitializeVersion(dbconn)
 	if err != nil {
 		return errors.Wrap(err, "Failed to determine database version")
 	}
 	dbconn.Version = version
+	if dbconn.PoolerMode == POOLER_MODE_DETECT && !isUtilityMode {
+		detected, err := dbconn.DetectTransactionPooling()
+		if err != nil {
+			return errors.Wrap(err, "Failed to detect a connection pooler")
+		}
+		dbconn.poolerDetected = detected
+		if detected {
+			gplog.Verbose("Detected a transaction-pooling proxy in front of %s:%d", dbconn.Host, dbconn.Port)
+		}
+	}
 	return nil
 }
 
+// connectOne opens the connection to use as connection connNum in the pool
+func (dbconn *DBConn) connectOne(connStr string, notices *noticeTracker, connNum int) (*sqlx.DB, error) {
+	var conn *sqlx.DB
+	var err error
+	if noticeDriver, capturesNotices := dbconn.Driver.(NoticeDriver); capturesNotices && notices != nil {
+		conn, err = noticeDriver.ConnectWithNoticeHandler("pgx", connStr, notices.handler(connNum))
+	} else {
+		conn, err = dbconn.Driver.Connect("pgx", connStr)
+	}
+	err = dbconn.handleConnectionError(err)
+	countConnection(err)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetMaxOpenConns(1)
+	conn.SetMaxIdleConns(1)
+	return conn, nil
+}
+
 func (dbconn *DBConn) MustConnectInUtilityMode(numConns int) {
 	err := dbconn.Connect(numConns, true)
 	gplog.FatalOnError(err)
@@ -290,15 +351,22 @@ func (dbconn *DBConn) handleConnectionError(err error) error {
  * Wrapper functions for built-in sqlx and database/sql functionality; they will
  * automatically execute the query as part of an existing transaction if one is
  * in progress, to ensure that successive queries occur in one transaction without
- * requiring that to be ensured at the call site.
+ * requiring that to be ensured at the call site.  Any notices the server sends
+ * while a statement is executing are logged and made available via LastNotices.
+ * Each statement is first passed to the StatementHooks, which may reject it.
  */
 
 func (dbconn *DBConn) Exec(query string, whichConn ...int) (sql.Result, error) {
 	connNum := dbconn.ValidateConnNum(whichConn...)
+	statement, err := dbconn.beginStatement(connNum, query)
+	if err != nil {
+		return nil, err
+	}
+	defer dbconn.endStatement(connNum, statement)
 	if dbconn.Tx[connNum] != nil {
-		return dbconn.Tx[connNum].Exec(query)
+		return dbconn.Tx[connNum].Exec(statement.SQL)
 	}
-	return dbconn.ConnPool[connNum].Exec(query)
+	return dbconn.ConnPool[connNum].Exec(statement.SQL)
 }
 
 func (dbconn *DBConn) MustExec(query string, whichConn ...int) {
@@ -308,10 +376,15 @@ func (dbconn *DBConn) MustExec(query string, whichConn ...int) {
 
 func (dbconn *DBConn) ExecContext(queryContext context.Context, query string, whichConn ...int) (sql.Result, error) {
 	connNum := dbconn.ValidateConnNum(whichConn...)
+	statement, err := dbconn.beginStatementContext(queryContext, connNum, query)
+	if err != nil {
+		return nil, err
+	}
+	defer dbconn.endStatement(connNum, statement)
 	if dbconn.Tx[connNum] != nil {
-		return dbconn.Tx[connNum].ExecContext(queryContext, query)
+		return dbconn.Tx[connNum].ExecContext(queryContext, statement.SQL)
 	}
-	return dbconn.ConnPool[connNum].ExecContext(queryContext, query)
+	return dbconn.ConnPool[connNum].ExecContext(queryContext, statement.SQL)
 }
 
 func (dbconn *DBConn) MustExecContext(queryContext context.Context, query string, whichConn ...int) {
@@ -320,64 +393,104 @@ func (dbconn *DBConn) MustExecContext(queryContext context.Context, query string
 }
 
 func (dbconn *DBConn) GetWithArgs(destination interface{}, query string, args ...interface{}) error {
+	statement, err := dbconn.beginStatement(0, query, args...)
+	if err != nil {
+		return err
+	}
+	defer dbconn.endStatement(0, statement)
 	if dbconn.Tx[0] != nil {
-		return dbconn.Tx[0].Get(destination, query, args...)
+		return dbconn.Tx[0].Get(destination, statement.SQL, statement.Params...)
 	}
-	return dbconn.ConnPool[0].Get(destination, query, args...)
+	return dbconn.ConnPool[0].Get(destination, statement.SQL, statement.Params...)
 }
 
 func (dbconn *DBConn) Get(destination interface{}, query string, whichConn ...int) error {
 	connNum := dbconn.ValidateConnNum(whichConn...)
+	statement, err := dbconn.beginStatement(connNum, query)
+	if err != nil {
+		return err
+	}
+	defer dbconn.endStatement(connNum, statement)
 	if dbconn.Tx[connNum] != nil {
-		return dbconn.Tx[connNum].Get(destination, query)
+		return dbconn.Tx[connNum].Get(destination, statement.SQL)
 	}
-	return dbconn.ConnPool[connNum].Get(destination, query)
+	return dbconn.ConnPool[connNum].Get(destination, statement.SQL)
 }
 
 func (dbconn *DBConn) SelectWithArgs(destination interface{}, query string, args ...interface{}) error {
+	statement, err := dbconn.beginStatement(0, query, args...)
+	if err != nil {
+		return err
+	}
+	defer dbconn.endStatement(0, statement)
 	if dbconn.Tx[0] != nil {
-		return dbconn.Tx[0].Select(destination, query, args...)
+		return dbconn.Tx[0].Select(destination, statement.SQL, statement.Params...)
 	}
-	return dbconn.ConnPool[0].Select(destination, query, args...)
+	return dbconn.ConnPool[0].Select(destination, statement.SQL, statement.Params...)
 }
 
 func (dbconn *DBConn) Select(destination interface{}, query string, whichConn ...int) error {
 	connNum := dbconn.ValidateConnNum(whichConn...)
+	statement, err := dbconn.beginStatement(connNum, query)
+	if err != nil {
+		return err
+	}
+	defer dbconn.endStatement(connNum, statement)
 	if dbconn.Tx[connNum] != nil {
-		return dbconn.Tx[connNum].Select(destination, query)
+		return dbconn.Tx[connNum].Select(destination, statement.SQL)
 	}
-	return dbconn.ConnPool[connNum].Select(destination, query)
+	return dbconn.ConnPool[connNum].Select(destination, statement.SQL)
 }
 
 func (dbconn *DBConn) SelectContext(ctx context.Context, destination interface{}, query string, whichConn ...int) error {
 	connNum := dbconn.ValidateConnNum(whichConn...)
+	statement, err := dbconn.beginStatementContext(ctx, connNum, query)
+	if err != nil {
+		return err
+	}
+	defer dbconn.endStatement(connNum, statement)
 	if dbconn.Tx[connNum] != nil {
-		return dbconn.Tx[connNum].SelectContext(ctx, destination, query)
+		return dbconn.Tx[connNum].SelectContext(ctx, destination, statement.SQL)
 	}
-	return dbconn.ConnPool[connNum].SelectContext(ctx, destination, query)
+	return dbconn.ConnPool[connNum].SelectContext(ctx, destination, statement.SQL)
 }
 
 func (dbconn *DBConn) QueryWithArgs(query string, args ...interface{}) (*sqlx.Rows, error) {
+	statement, err := dbconn.beginStatement(0, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer dbconn.endStatement(0, statement)
 	if dbconn.Tx[0] != nil {
-		return dbconn.Tx[0].Queryx(query, args...)
+		return dbconn.Tx[0].Queryx(statement.SQL, statement.Params...)
 	}
-	return dbconn.ConnPool[0].Queryx(query, args...)
+	return dbconn.ConnPool[0].Queryx(statement.SQL, statement.Params...)
 }
 
 func (dbconn *DBConn) Query(query string, whichConn ...int) (*sqlx.Rows, error) {
 	connNum := dbconn.ValidateConnNum(whichConn...)
+	statement, err := dbconn.beginStatement(connNum, query)
+	if err != nil {
+		return nil, err
+	}
+	defer dbconn.endStatement(connNum, statement)
 	if dbconn.Tx[connNum] != nil {
-		return dbconn.Tx[connNum].Queryx(query)
+		return dbconn.Tx[connNum].Queryx(statement.SQL)
 	}
-	return dbconn.ConnPool[connNum].Queryx(query)
+	return dbconn.ConnPool[connNum].Queryx(statement.SQL)
 }
 
 func (dbconn *DBConn) QueryContext(ctx context.Context, query string, whichConn ...int) (*sqlx.Rows, error) {
 	connNum := dbconn.ValidateConnNum(whichConn...)
+	statement, err := dbconn.beginStatementContext(ctx, connNum, query)
+	if err != nil {
+		return nil, err
+	}
+	defer dbconn.endStatement(connNum, statement)
 	if dbconn.Tx[connNum] != nil {
-		return dbconn.Tx[connNum].QueryxContext(ctx, query)
+		return dbconn.Tx[connNum].QueryxContext(ctx, statement.SQL)
 	}
-	return dbconn.ConnPool[connNum].QueryxContext(ctx, query)
+	return dbconn.ConnPool[connNum].QueryxContext(ctx, statement.SQL)
 }
 
 /*
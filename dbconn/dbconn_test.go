@@ -13,6 +13,7 @@ import (
 	"github.com/apache/cloudberry-go-libs/operating"
 	"github.com/apache/cloudberry-go-libs/testhelper"
 	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -57,6 +58,20 @@ var _ = Describe("dbconn/dbconn tests", func() {
 			connection = dbconn.NewDBConnFromEnvironment("testdb")
 			Expect(connection.DBName).To(Equal("testdb"))
 		})
+		It("gets the connection info from the environment", func() {
+			operating.WithEnv(map[string]string{"PGUSER": "gpadmin", "PGHOST": "cdw", "PGPORT": "6000"}, func() {
+				connection = dbconn.NewDBConnFromEnvironment("testdb")
+			})
+			Expect(connection.User).To(Equal("gpadmin"))
+			Expect(connection.Host).To(Equal("cdw"))
+			Expect(connection.Port).To(Equal(6000))
+		})
+		It("uses the default port if PGPORT is not a valid port", func() {
+			operating.WithEnv(map[string]string{"PGPORT": "70000"}, func() {
+				connection = dbconn.NewDBConnFromEnvironment("testdb")
+			})
+			Expect(connection.Port).To(Equal(5432))
+		})
 		It("gets the DB info", func() {
 			connection = dbconn.NewDBConn("testdb", "testuser", "mars", 1234)
 			Expect(connection.DBName).To(Equal("testdb"))
@@ -102,6 +117,14 @@ var _ = Describe("dbconn/dbconn tests", func() {
 			defer testhelper.ShouldPanicWithMessage(`could not connect to server: Connection refused`)
 			connection.MustConnect(1)
 		})
+		It("detects the type of database from its version string", func() {
+			connection, mock = testhelper.CreateMockDBConn()
+			testhelper.ExpectVersionQueryForType(mock, dbconn.CBDB, "2.0.0")
+			connection.MustConnect(1)
+			Expect(connection.Version.IsCBDB()).To(BeTrue())
+			Expect(connection.Version.SemVer.String()).To(Equal("2.0.0"))
+			testhelper.AssertAllExpectationsMet(mock)
+		})
 		It("fails if an invalid number of connections is given", func() {
 			defer testhelper.ShouldPanicWithMessage("Must specify a connection pool size that is a positive integer")
 			connection.MustConnect(0)
@@ -313,6 +336,35 @@ var _ = Describe("dbconn/dbconn tests", func() {
 			Expect(testSlice[1].Schemaname).To(Equal("schema2"))
 			Expect(testSlice[1].Tablename).To(Equal("table2"))
 		})
+		It("executes a SELECT returning rows built from structs", func() {
+			type table struct {
+				Schema string `db:"schemaname"`
+				Name   string `db:"tablename"`
+				Oid    uint32 `db:"-"`
+			}
+			testhelper.ExpectQueryReturning(mock, "SELECT (.*) FROM two_columns", table{"schema1", "table1", 0}, &table{"schema2", "table2", 0})
+
+			testSlice := make([]table, 0)
+			err := connection.Select(&testSlice, "SELECT schemaname, tablename FROM two_columns ORDER BY schemaname LIMIT 2")
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(testSlice).To(Equal([]table{{"schema1", "table1", 0}, {"schema2", "table2", 0}}))
+			testhelper.AssertAllExpectationsMet(mock)
+		})
+		It("executes a SELECT returning rows built from single values", func() {
+			testhelper.ExpectQueryReturning(mock, "SELECT count", 42)
+			Expect(dbconn.MustSelectInt(connection, "SELECT count(*) FROM foo")).To(Equal(42))
+		})
+		It("fails the test if an expected query is not executed", func() {
+			testhelper.ExpectQueryFailing(mock, "SELECT (.*)", errors.New("relation does not exist"))
+			failures := InterceptGomegaFailures(func() {
+				testhelper.AssertAllExpectationsMet(mock)
+			})
+			Expect(failures).To(HaveLen(1))
+			Expect(failures[0]).To(ContainSubstring("Not all expected database statements were executed"))
+			_, err := connection.Exec("SELECT 1")
+			Expect(err).To(HaveOccurred())
+		})
 		It("executes a SELECT with argument outside of a transaction", func() {
 			arg1 := "table1"
 			arg2 := "table2"
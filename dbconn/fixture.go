@@ -0,0 +1,505 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dbconn
+
+/*
+ * This file contains structs and functions for recording the statements a
+ * program executes, along with their results, to a fixture file, and for
+ * replaying those results later without a database.
+ *
+ * Both modes work at the database/sql driver level, so every DBConn wrapper,
+ * transaction, and sqlx scan behaves exactly as it does against a live server.
+ */
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+const (
+	FIXTURE_QUERY = "query"
+	FIXTURE_EXEC  = "exec"
+)
+
+/*
+ * A FixtureValue is a single driver.Value, stored with its type so that it
+ * round-trips through JSON without losing precision or turning into a float.
+ */
+type FixtureValue struct {
+	Type  string `json:"type"`
+	Value string `json:"value,omitempty"`
+}
+
+/*
+ * A FixtureEntry is one statement sent to the server and the response it got.
+ * Kind is FIXTURE_QUERY for statements that return rows and FIXTURE_EXEC for
+ * statements that only report the number of rows affected.
+ */
+type FixtureEntry struct {
+	Kind         string           `json:"kind"`
+	Query        string           `json:"query"`
+	Args         []FixtureValue   `json:"args,omitempty"`
+	Columns      []string         `json:"columns,omitempty"`
+	Rows         [][]FixtureValue `json:"rows,omitempty"`
+	RowsAffected int64            `json:"rows_affected,omitempty"`
+	Error        string           `json:"error,omitempty"`
+	ErrorCode    string           `json:"error_code,omitempty"`
+}
+
+type Fixture struct {
+	Entries []FixtureEntry `json:"entries"`
+	mutex   sync.Mutex
+}
+
+func (fixture *Fixture) add(entry FixtureEntry) {
+	fixture.mutex.Lock()
+	defer fixture.mutex.Unlock()
+	fixture.Entries = append(fixture.Entries, entry)
+}
+
+func (fixture *Fixture) Write(writer io.Writer) error {
+	fixture.mutex.Lock()
+	defer fixture.mutex.Unlock()
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(fixture)
+}
+
+func (fixture *Fixture) WriteToFile(filename string) error {
+	fileHandle, err := operating.System.OpenFileWrite(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to create fixture file %s", filename)
+	}
+	err = fixture.Write(fileHandle)
+	if closeErr := fileHandle.Close(); err == nil {
+		err = closeErr
+	}
+	return errors.Wrapf(err, "Unable to write fixture file %s", filename)
+}
+
+func LoadFixture(filename string) (*Fixture, error) {
+	contents, err := operating.System.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read fixture file %s", filename)
+	}
+	fixture := &Fixture{}
+	if err = json.Unmarshal(contents, fixture); err != nil {
+		return nil, errors.Wrapf(err, "Unable to parse fixture file %s", filename)
+	}
+	return fixture, nil
+}
+
+func encodeFixtureValue(value interface{}) FixtureValue {
+	switch v := value.(type) {
+	case nil:
+		return FixtureValue{Type: "null"}
+	case int64:
+		return FixtureValue{Type: "int64", Value: strconv.FormatInt(v, 10)}
+	case int:
+		return FixtureValue{Type: "int64", Value: strconv.Itoa(v)}
+	case float64:
+		return FixtureValue{Type: "float64", Value: strconv.FormatFloat(v, 'g', -1, 64)}
+	case bool:
+		return FixtureValue{Type: "bool", Value: strconv.FormatBool(v)}
+	case []byte:
+		return FixtureValue{Type: "bytes", Value: base64.StdEncoding.EncodeToString(v)}
+	case string:
+		return FixtureValue{Type: "string", Value: v}
+	case time.Time:
+		return FixtureValue{Type: "time", Value: v.Format(time.RFC3339Nano)}
+	default:
+		// Driver-specific argument types are only used for matching on replay,
+		// so their printed form is enough
+		return FixtureValue{Type: "string", Value: fmt.Sprintf("%v", v)}
+	}
+}
+
+func decodeFixtureValue(value FixtureValue) (driver.Value, error) {
+	switch value.Type {
+	case "null":
+		return nil, nil
+	case "int64":
+		return strconv.ParseInt(value.Value, 10, 64)
+	case "float64":
+		return strconv.ParseFloat(value.Value, 64)
+	case "bool":
+		return strconv.ParseBool(value.Value)
+	case "bytes":
+		return base64.StdEncoding.DecodeString(value.Value)
+	case "string":
+		return value.Value, nil
+	case "time":
+		return time.Parse(time.RFC3339Nano, value.Value)
+	}
+	return nil, errors.Errorf("Unknown fixture value type %q", value.Type)
+}
+
+func encodeFixtureArgs(args []driver.NamedValue) []FixtureValue {
+	if len(args) == 0 {
+		// Match what a statement without arguments loads back as
+		return nil
+	}
+	values := make([]FixtureValue, 0, len(args))
+	for _, arg := range args {
+		values = append(values, encodeFixtureValue(arg.Value))
+	}
+	return values
+}
+
+func fixtureKey(kind string, query string, args []FixtureValue) string {
+	encodedArgs, _ := json.Marshal(args)
+	return kind + "\x00" + query + "\x00" + string(encodedArgs)
+}
+
+func fixtureError(entry FixtureEntry) error {
+	if entry.Error == "" {
+		return nil
+	}
+	if entry.ErrorCode != "" {
+		return &pgconn.PgError{Severity: "ERROR", Code: entry.ErrorCode, Message: entry.Error}
+	}
+	return errors.New(entry.Error)
+}
+
+func recordError(entry *FixtureEntry, err error) {
+	entry.Error = err.Error()
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		entry.Error = pgErr.Message
+		entry.ErrorCode = pgErr.Code
+	}
+}
+
+/*
+ * A RecordingDriver is a DBDriver that executes statements against a real
+ * server and records each statement and its result.  Set it as the Driver of
+ * a DBConn before connecting, then call Save once the run is finished.
+ * Underlying defaults to the pgx driver.
+ */
+type RecordingDriver struct {
+	Underlying driver.Driver
+	fixture    *Fixture
+}
+
+func NewRecordingDriver() *RecordingDriver {
+	return &RecordingDriver{fixture: &Fixture{}}
+}
+
+func (recorder *RecordingDriver) Connect(driverName string, dataSourceName string) (*sqlx.DB, error) {
+	underlying := recorder.Underlying
+	if underlying == nil {
+		underlying = stdlib.GetDefaultDriver()
+	}
+	connector := &fixtureConnector{open: func() (driver.Conn, error) {
+		conn, err := underlying.Open(dataSourceName)
+		if err != nil {
+			return nil, err
+		}
+		return &recordingConn{Conn: conn, fixture: recorder.fixture}, nil
+	}}
+	db := sqlx.NewDb(sql.OpenDB(connector), driverName)
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func (recorder *RecordingDriver) Fixture() *Fixture {
+	return recorder.fixture
+}
+
+func (recorder *RecordingDriver) Save(filename string) error {
+	return recorder.fixture.WriteToFile(filename)
+}
+
+/*
+ * A ReplayDriver is a DBDriver that never contacts a server, and instead
+ * answers each statement with the response recorded for the same statement
+ * and arguments.  Repeated statements get their recorded responses in order,
+ * and the last response is reused once they run out, so polling loops replay
+ * correctly.  A statement that was never recorded returns an error.
+ */
+type ReplayDriver struct {
+	responses map[string][]FixtureEntry
+	mutex     sync.Mutex
+}
+
+func NewReplayDriver(fixture *Fixture) *ReplayDriver {
+	replayer := &ReplayDriver{responses: make(map[string][]FixtureEntry)}
+	for _, entry := range fixture.Entries {
+		key := fixtureKey(entry.Kind, entry.Query, entry.Args)
+		replayer.responses[key] = append(replayer.responses[key], entry)
+	}
+	return replayer
+}
+
+func (replayer *ReplayDriver) Connect(driverName string, dataSourceName string) (*sqlx.DB, error) {
+	connector := &fixtureConnector{open: func() (driver.Conn, error) {
+		return &replayConn{replayer: replayer}, nil
+	}}
+	return sqlx.NewDb(sql.OpenDB(connector), driverName), nil
+}
+
+func (replayer *ReplayDriver) next(kind string, query string, args []driver.NamedValue) (FixtureEntry, error) {
+	replayer.mutex.Lock()
+	defer replayer.mutex.Unlock()
+	key := fixtureKey(kind, query, encodeFixtureArgs(args))
+	entries := replayer.responses[key]
+	if len(entries) == 0 {
+		return FixtureEntry{}, errors.Errorf("No response recorded in fixture for %s: %s", kind, query)
+	}
+	if len(entries) > 1 {
+		replayer.responses[key] = entries[1:]
+	}
+	return entries[0], nil
+}
+
+type fixtureConnector struct {
+	open func() (driver.Conn, error)
+}
+
+func (connector *fixtureConnector) Connect(_ context.Context) (driver.Conn, error) {
+	return connector.open()
+}
+
+func (connector *fixtureConnector) Driver() driver.Driver {
+	return fixtureDriver{connector: connector}
+}
+
+type fixtureDriver struct {
+	connector *fixtureConnector
+}
+
+func (d fixtureDriver) Open(_ string) (driver.Conn, error) {
+	return d.connector.open()
+}
+
+/*
+ * recordingConn wraps a driver connection and records every statement run
+ * through it.  It implements QueryerContext and ExecerContext so that
+ * database/sql sends statements here rather than preparing them.
+ */
+type recordingConn struct {
+	driver.Conn
+	fixture *Fixture
+}
+
+func (conn *recordingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := conn.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	entry := FixtureEntry{Kind: FIXTURE_QUERY, Query: query, Args: encodeFixtureArgs(args)}
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		recordError(&entry, err)
+		conn.fixture.add(entry)
+		return nil, err
+	}
+	defer rows.Close()
+	entry.Columns = rows.Columns()
+	bufferedRows := &fixtureRows{columns: entry.Columns}
+	for {
+		values := make([]driver.Value, len(entry.Columns))
+		err = rows.Next(values)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			recordError(&entry, err)
+			entry.Columns, entry.Rows = nil, nil
+			conn.fixture.add(entry)
+			return nil, err
+		}
+		encodedRow := make([]FixtureValue, len(values))
+		for i, value := range values {
+			encodedRow[i] = encodeFixtureValue(value)
+		}
+		entry.Rows = append(entry.Rows, encodedRow)
+		bufferedRows.rows = append(bufferedRows.rows, values)
+	}
+	conn.fixture.add(entry)
+	return bufferedRows, nil
+}
+
+func (conn *recordingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := conn.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	entry := FixtureEntry{Kind: FIXTURE_EXEC, Query: query, Args: encodeFixtureArgs(args)}
+	result, err := execer.ExecContext(ctx, query, args)
+	if err == nil {
+		entry.RowsAffected, err = result.RowsAffected()
+	}
+	if err != nil {
+		recordError(&entry, err)
+	}
+	conn.fixture.add(entry)
+	return result, err
+}
+
+func (conn *recordingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := conn.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return conn.Conn.Begin()
+}
+
+func (conn *recordingConn) Ping(ctx context.Context) error {
+	if pinger, ok := conn.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (conn *recordingConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := conn.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+type replayConn struct {
+	replayer *ReplayDriver
+}
+
+func (conn *replayConn) Prepare(query string) (driver.Stmt, error) {
+	return &replayStmt{conn: conn, query: query}, nil
+}
+
+func (conn *replayConn) Close() error {
+	return nil
+}
+
+func (conn *replayConn) Begin() (driver.Tx, error) {
+	return replayTx{}, nil
+}
+
+func (conn *replayConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	entry, err := conn.replayer.next(FIXTURE_QUERY, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if err = fixtureError(entry); err != nil {
+		return nil, err
+	}
+	rows := &fixtureRows{columns: entry.Columns}
+	for _, encodedRow := range entry.Rows {
+		values := make([]driver.Value, len(encodedRow))
+		for i, encodedValue := range encodedRow {
+			if values[i], err = decodeFixtureValue(encodedValue); err != nil {
+				return nil, err
+			}
+		}
+		rows.rows = append(rows.rows, values)
+	}
+	return rows, nil
+}
+
+func (conn *replayConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	entry, err := conn.replayer.next(FIXTURE_EXEC, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if err = fixtureError(entry); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(entry.RowsAffected), nil
+}
+
+func (conn *replayConn) CheckNamedValue(_ *driver.NamedValue) error {
+	// Arguments are only compared against the fixture, so accept any type
+	return nil
+}
+
+type replayStmt struct {
+	conn  *replayConn
+	query string
+}
+
+func (stmt *replayStmt) Close() error {
+	return nil
+}
+
+func (stmt *replayStmt) NumInput() int {
+	return -1
+}
+
+func (stmt *replayStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return stmt.conn.ExecContext(context.Background(), stmt.query, namedValues(args))
+}
+
+func (stmt *replayStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return stmt.conn.QueryContext(context.Background(), stmt.query, namedValues(args))
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+type replayTx struct{}
+
+func (tx replayTx) Commit() error {
+	return nil
+}
+
+func (tx replayTx) Rollback() error {
+	return nil
+}
+
+type fixtureRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (rows *fixtureRows) Columns() []string {
+	return rows.columns
+}
+
+func (rows *fixtureRows) Close() error {
+	return nil
+}
+
+func (rows *fixtureRows) Next(dest []driver.Value) error {
+	if len(rows.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, rows.rows[0])
+	rows.rows = rows.rows[1:]
+	return nil
+}
@@ -0,0 +1,181 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dbconn_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/apache/cloudberry-go-libs/dbconn"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// sqlmock only hands out its mock connection for the DSN it was created with
+type dsnRecordingDriver struct {
+	*dbconn.RecordingDriver
+	dsn string
+}
+
+func (driver *dsnRecordingDriver) Connect(driverName string, _ string) (*sqlx.DB, error) {
+	return driver.RecordingDriver.Connect(driverName, driver.dsn)
+}
+
+var _ = Describe("dbconn/fixture tests", func() {
+	type relation struct {
+		Name    string
+		Size    int64
+		Created time.Time
+	}
+	created := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	var (
+		recorder   *dbconn.RecordingDriver
+		recordMock sqlmock.Sqlmock
+		recordConn *dbconn.DBConn
+		dsnCount   int
+	)
+
+	BeforeEach(func() {
+		dsnCount++
+		dsn := fmt.Sprintf("fixture_test_%d", dsnCount)
+		db, sqlMock, err := sqlmock.NewWithDSN(dsn)
+		Expect(err).ToNot(HaveOccurred())
+		recordMock = sqlMock
+		recorder = dbconn.NewRecordingDriver()
+		recorder.Underlying = db.Driver()
+		recordConn = dbconn.NewDBConnFromEnvironment("testdb")
+		recordConn.Driver = &dsnRecordingDriver{RecordingDriver: recorder, dsn: dsn}
+	})
+
+	record := func() {
+		testhelper.ExpectVersionQuery(recordMock, "6.20.0")
+		recordConn.MustConnect(1)
+		rows := sqlmock.NewRows([]string{"name", "size", "created"}).AddRow("public.foo", int64(8192), created).AddRow("public.bar", int64(0), created)
+		recordMock.ExpectQuery(regexp.QuoteMeta("SELECT name, size, created FROM sizes WHERE schema = $1")).WithArgs("public").WillReturnRows(rows)
+		ExpectBegin(recordMock)
+		recordMock.ExpectExec("ANALYZE public.foo").WillReturnResult(sqlmock.NewResult(0, 0))
+		recordMock.ExpectCommit()
+		recordMock.ExpectExec("DROP TABLE public.missing").WillReturnError(&pgconn.PgError{Code: "42P01", Message: `table "missing" does not exist`})
+
+		var results []relation
+		Expect(recordConn.SelectWithArgs(&results, "SELECT name, size, created FROM sizes WHERE schema = $1", "public")).To(Succeed())
+		Expect(results).To(HaveLen(2))
+		recordConn.MustBegin()
+		recordConn.MustExec("ANALYZE public.foo")
+		recordConn.MustCommit()
+		_, err := recordConn.Exec("DROP TABLE public.missing")
+		Expect(err).To(HaveOccurred())
+		recordConn.Close()
+		Expect(recordMock.ExpectationsWereMet()).To(Succeed())
+	}
+
+	Describe("RecordingDriver", func() {
+		It("records each statement along with its result", func() {
+			record()
+			entries := recorder.Fixture().Entries
+			Expect(entries).To(HaveLen(5))
+			Expect(entries[0].Query).To(Equal("SELECT pg_catalog.version() AS versionstring"))
+			Expect(entries[1]).To(Equal(dbconn.FixtureEntry{
+				Kind:    dbconn.FIXTURE_QUERY,
+				Query:   "SELECT name, size, created FROM sizes WHERE schema = $1",
+				Args:    []dbconn.FixtureValue{{Type: "string", Value: "public"}},
+				Columns: []string{"name", "size", "created"},
+				Rows: [][]dbconn.FixtureValue{
+					{{Type: "string", Value: "public.foo"}, {Type: "int64", Value: "8192"}, {Type: "time", Value: "2024-03-01T12:30:00Z"}},
+					{{Type: "string", Value: "public.bar"}, {Type: "int64", Value: "0"}, {Type: "time", Value: "2024-03-01T12:30:00Z"}},
+				},
+			}))
+			Expect(entries[2].Query).To(HavePrefix("SET TRANSACTION"))
+			Expect(entries[3]).To(Equal(dbconn.FixtureEntry{Kind: dbconn.FIXTURE_EXEC, Query: "ANALYZE public.foo"}))
+			Expect(entries[4].Error).To(Equal(`table "missing" does not exist`))
+			Expect(entries[4].ErrorCode).To(Equal("42P01"))
+		})
+	})
+	Describe("ReplayDriver", func() {
+		var replayConn *dbconn.DBConn
+
+		BeforeEach(func() {
+			record()
+			filename := filepath.Join(GinkgoT().TempDir(), "fixture.json")
+			Expect(recorder.Save(filename)).To(Succeed())
+			fixture, err := dbconn.LoadFixture(filename)
+			Expect(err).ToNot(HaveOccurred())
+			replayConn = dbconn.NewDBConnFromEnvironment("testdb")
+			replayConn.Driver = dbconn.NewReplayDriver(fixture)
+			replayConn.MustConnect(1)
+		})
+		AfterEach(func() {
+			replayConn.Close()
+		})
+
+		It("replays the recorded version", func() {
+			Expect(replayConn.Version.SemVer.String()).To(Equal("6.20.0"))
+		})
+		It("replays recorded rows into the destination", func() {
+			var results []relation
+			Expect(replayConn.SelectWithArgs(&results, "SELECT name, size, created FROM sizes WHERE schema = $1", "public")).To(Succeed())
+			Expect(results).To(Equal([]relation{{"public.foo", 8192, created}, {"public.bar", 0, created}}))
+		})
+		It("replays a recorded exec inside a transaction", func() {
+			replayConn.MustBegin()
+			replayConn.MustExec("ANALYZE public.foo")
+			replayConn.MustCommit()
+		})
+		It("replays recorded server errors with their SQLSTATE", func() {
+			_, err := replayConn.Exec("DROP TABLE public.missing")
+			var pgErr *pgconn.PgError
+			Expect(errors.As(err, &pgErr)).To(BeTrue())
+			Expect(pgErr.Code).To(Equal("42P01"))
+		})
+		It("reuses the last response for a repeated statement", func() {
+			for i := 0; i < 2; i++ {
+				_, err := replayConn.Exec("ANALYZE public.foo")
+				Expect(err).ToNot(HaveOccurred())
+			}
+		})
+		It("returns an error for a statement that was not recorded", func() {
+			var results []relation
+			err := replayConn.SelectWithArgs(&results, "SELECT name, size, created FROM sizes WHERE schema = $1", "pg_catalog")
+			Expect(err).To(MatchError(ContainSubstring("No response recorded in fixture for query: SELECT name")))
+		})
+	})
+	Describe("LoadFixture", func() {
+		It("loads an empty fixture", func() {
+			filename := filepath.Join(GinkgoT().TempDir(), "fixture.json")
+			Expect(dbconn.NewRecordingDriver().Save(filename)).To(Succeed())
+			fixture, err := dbconn.LoadFixture(filename)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fixture.Entries).To(BeEmpty())
+		})
+		It("returns an error for a file that is not a fixture", func() {
+			filename := filepath.Join(GinkgoT().TempDir(), "fixture.json")
+			Expect(os.WriteFile(filename, []byte("not json"), 0644)).To(Succeed())
+			_, err := dbconn.LoadFixture(filename)
+			Expect(err).To(MatchError(ContainSubstring("Unable to parse fixture file")))
+		})
+	})
+})
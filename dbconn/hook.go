@@ -0,0 +1,115 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dbconn
+
+/*
+ * This file contains the hooks through which a deployment can inspect every
+ * statement before a DBConn runs it, to forward it to an external audit
+ * system, annotate it, or veto it, for example to block DDL during a freeze
+ * window.
+ */
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+ * A Statement describes a statement that a DBConn is about to run.  Params
+ * holds the arguments of the WithArgs functions, and Caller describes the
+ * code outside this package that called the DBConn, as "function
+ * (file:line)".  A hook may change SQL or Params, for example to add a
+ * comment identifying the utility, and the changed statement is run instead.
+ */
+type Statement struct {
+	SQL     string
+	Params  []interface{}
+	ConnNum int
+	Caller  string
+	// When the statement started running, for metrics.go
+	started time.Duration
+}
+
+/*
+ * A StatementHook is called before each statement run with Exec, Get, Select,
+ * Query, or their variants.  If it returns an error, the statement is not run
+ * and the DBConn function returns the error, wrapped so that errors.Is and
+ * errors.As still find it.  Hooks may be called by several goroutines at
+ * once, one for each connection.
+ */
+type StatementHook interface {
+	BeforeStatement(statement *Statement) error
+}
+
+// StatementHookFunc allows an ordinary function to be used as a StatementHook
+type StatementHookFunc func(statement *Statement) error
+
+func (hook StatementHookFunc) BeforeStatement(statement *Statement) error {
+	return hook(statement)
+}
+
+/*
+ * runStatementHooks calls each of StatementHooks in order, passing each the
+ * statement as changed by the hooks before it.
+ */
+func (dbconn *DBConn) runStatementHooks(ctx context.Context, connNum int, query string, args []interface{}) (*Statement, error) {
+	statement := &Statement{SQL: query, Params: args, ConnNum: connNum}
+	if len(dbconn.StatementHooks) == 0 {
+		return statement, nil
+	}
+	if caller, ok := ctx.Value(statementCallerKey{}).(string); ok {
+		statement.Caller = caller
+	} else {
+		statement.Caller = statementCaller()
+	}
+	for _, hook := range dbconn.StatementHooks {
+		if err := hook.BeforeStatement(statement); err != nil {
+			return nil, errors.Wrapf(err, "Statement rejected on connection %d", connNum)
+		}
+	}
+	return statement, nil
+}
+
+/*
+ * statementCallerKey is the context key for the caller of a statement run in
+ * another goroutine, such as by ExecAsync, whose own stack does not lead back
+ * to the code that started the statement.
+ */
+type statementCallerKey struct{}
+
+// statementCaller describes the first function on the stack outside this package
+func statementCaller() string {
+	callers := make([]uintptr, 32)
+	numCallers := runtime.Callers(2, callers)
+	frames := runtime.CallersFrames(callers[:numCallers])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "github.com/apache/cloudberry-go-libs/dbconn.") {
+			return fmt.Sprintf("%s (%s:%d)", frame.Function, filepath.Base(frame.File), frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
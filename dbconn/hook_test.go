@@ -0,0 +1,92 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dbconn_test
+
+import (
+	"regexp"
+	"strings"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/apache/cloudberry-go-libs/dbconn"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/hook tests", func() {
+	errFrozen := errors.New("DDL is not allowed during the freeze window")
+	blockDDL := dbconn.StatementHookFunc(func(statement *dbconn.Statement) error {
+		if strings.HasPrefix(strings.ToUpper(statement.SQL), "CREATE") {
+			return errFrozen
+		}
+		return nil
+	})
+
+	It("passes each statement to the hooks before running it", func() {
+		statements := make([]dbconn.Statement, 0)
+		connection.StatementHooks = []dbconn.StatementHook{dbconn.StatementHookFunc(func(statement *dbconn.Statement) error {
+			statements = append(statements, *statement)
+			return nil
+		})}
+		mock.ExpectExec("INSERT (.*)").WillReturnResult(testhelper.TestResult{Rows: 1})
+		mock.ExpectQuery("SELECT (.*)").WithArgs("foo").WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(1))
+
+		_, err := connection.Exec("INSERT INTO foo VALUES (1)")
+		Expect(err).ToNot(HaveOccurred())
+		var n int
+		Expect(connection.GetWithArgs(&n, "SELECT count(*) FROM pg_class WHERE relname = $1", "foo")).To(Succeed())
+
+		Expect(statements).To(HaveLen(2))
+		Expect(statements[0].SQL).To(Equal("INSERT INTO foo VALUES (1)"))
+		Expect(statements[0].Params).To(BeEmpty())
+		Expect(statements[0].ConnNum).To(Equal(0))
+		Expect(statements[0].Caller).To(MatchRegexp(`^github.com/apache/cloudberry-go-libs/dbconn_test\..* \(hook_test.go:\d+\)$`))
+		Expect(statements[1].Params).To(Equal([]interface{}{"foo"}))
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+	It("does not run a statement that a hook rejects", func() {
+		connection.StatementHooks = []dbconn.StatementHook{blockDDL}
+
+		_, err := connection.Exec("CREATE TABLE foo (i int)")
+		Expect(err).To(MatchError("Statement rejected on connection 0: DDL is not allowed during the freeze window"))
+		Expect(errors.Is(err, errFrozen)).To(BeTrue())
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+	It("runs the statement as changed by the hooks, in order", func() {
+		annotate := dbconn.StatementHookFunc(func(statement *dbconn.Statement) error {
+			statement.SQL = "/* gpbackup */ " + statement.SQL
+			return nil
+		})
+		seen := ""
+		record := dbconn.StatementHookFunc(func(statement *dbconn.Statement) error {
+			seen = statement.SQL
+			return nil
+		})
+		connection.StatementHooks = []dbconn.StatementHook{blockDDL, annotate, record}
+		mock.ExpectQuery(regexp.QuoteMeta("/* gpbackup */ SELECT 1")).WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(1))
+
+		var n int
+		Expect(connection.Get(&n, "SELECT 1")).To(Succeed())
+		Expect(seen).To(Equal("/* gpbackup */ SELECT 1"))
+		_, err := connection.Exec("CREATE TABLE foo (i int)")
+		Expect(err).To(MatchError(errFrozen))
+		Expect(mock.ExpectationsWereMet()).To(Succeed())
+	})
+})
@@ -0,0 +1,148 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dbconn
+
+/*
+ * This file contains structs and functions related to acquiring table locks
+ * in a consistent order across utilities.
+ */
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/gpversion"
+	"github.com/pkg/errors"
+)
+
+type LockMode string
+
+const (
+	AccessShare          LockMode = "ACCESS SHARE"
+	RowShare             LockMode = "ROW SHARE"
+	RowExclusive         LockMode = "ROW EXCLUSIVE"
+	ShareUpdateExclusive LockMode = "SHARE UPDATE EXCLUSIVE"
+	Share                LockMode = "SHARE"
+	ShareRowExclusive    LockMode = "SHARE ROW EXCLUSIVE"
+	Exclusive            LockMode = "EXCLUSIVE"
+	AccessExclusive      LockMode = "ACCESS EXCLUSIVE"
+)
+
+func (mode LockMode) isValid() bool {
+	switch mode {
+	case AccessShare, RowShare, RowExclusive, ShareUpdateExclusive, Share, ShareRowExclusive, Exclusive, AccessExclusive:
+		return true
+	}
+	return false
+}
+
+/*
+ * NoWait causes each LOCK TABLE statement to fail immediately if the lock
+ * cannot be acquired, and Timeout (if nonzero) sets lock_timeout for the
+ * remainder of the transaction so that a blocked lock fails after that long.
+ * lock_timeout has millisecond precision, so Timeout is rounded up to a whole
+ * number of milliseconds; it is not available before GPDB 6, so Timeout is an
+ * error there.
+ */
+type LockOptions struct {
+	NoWait  bool
+	Timeout time.Duration
+}
+
+type lockTarget struct {
+	Oid  uint32
+	Name string
+}
+
+/*
+ * LockTablesInOrder locks each of the given tables in the given mode, in
+ * ascending order of table oid rather than the order in which they were
+ * passed in.  When every utility acquires its locks this way, two utilities
+ * locking overlapping sets of tables cannot deadlock against one another.
+ *
+ * Table names may be schema-qualified and are resolved with regclass, so they
+ * follow the usual identifier quoting rules; duplicates are locked only once.
+ * Locks only last until the end of a transaction, so a transaction must
+ * already be in progress on the given connection.
+ */
+func LockTablesInOrder(connection *DBConn, tables []string, mode LockMode, opts LockOptions, whichConn ...int) error {
+	connNum := connection.ValidateConnNum(whichConn...)
+	if !mode.isValid() {
+		return errors.Errorf("Invalid lock mode: %s", mode)
+	}
+	if connection.Tx[connNum] == nil {
+		return errors.New("Cannot lock tables; there is no transaction in progress")
+	}
+	if opts.Timeout > 0 {
+		if err := connection.Version.GPVersion().CheckSupports(gpversion.LOCK_TIMEOUT); err != nil {
+			return errors.Wrap(err, "Cannot set a lock timeout")
+		}
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+
+	targets, err := getLockTargets(connection, tables, connNum)
+	if err != nil {
+		return err
+	}
+
+	if opts.Timeout > 0 {
+		// Round up, since a lock_timeout of 0 would disable the timeout entirely
+		timeoutMillis := (opts.Timeout + time.Millisecond - 1) / time.Millisecond
+		_, err = connection.Exec(fmt.Sprintf("SET LOCAL lock_timeout = %d", timeoutMillis), connNum)
+		if err != nil {
+			return err
+		}
+	}
+
+	noWait := ""
+	if opts.NoWait {
+		noWait = " NOWAIT"
+	}
+	for _, target := range targets {
+		_, err = connection.Exec(fmt.Sprintf("LOCK TABLE %s IN %s MODE%s", target.Name, mode, noWait), connNum)
+		if err != nil {
+			return errors.Wrapf(err, "Could not acquire %s lock on table %s", mode, target.Name)
+		}
+	}
+	return nil
+}
+
+func getLockTargets(connection *DBConn, tables []string, connNum int) ([]lockTarget, error) {
+	oidList := make([]string, len(tables))
+	for i, table := range tables {
+		oidList[i] = fmt.Sprintf("'%s'::regclass", strings.ReplaceAll(table, "'", "''"))
+	}
+	query := fmt.Sprintf(`
+SELECT
+	c.oid,
+	quote_ident(n.nspname) || '.' || quote_ident(c.relname) AS name
+FROM pg_class c
+JOIN pg_namespace n ON c.relnamespace = n.oid
+WHERE c.oid IN (%s)
+ORDER BY c.oid;`, strings.Join(oidList, ", "))
+
+	targets := make([]lockTarget, 0)
+	err := connection.Select(&targets, query, connNum)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not resolve tables to lock")
+	}
+	return targets, nil
+}
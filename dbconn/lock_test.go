@@ -0,0 +1,116 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dbconn_test
+
+import (
+	"errors"
+	"regexp"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/apache/cloudberry-go-libs/dbconn"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/lock tests", func() {
+	fakeResult := testhelper.TestResult{Rows: 0}
+	var targetRows *sqlmock.Rows
+
+	BeforeEach(func() {
+		targetRows = sqlmock.NewRows([]string{"oid", "name"}).
+			AddRow(16384, "public.bar").
+			AddRow(16390, `"Schema".foo`)
+	})
+
+	Describe("LockTablesInOrder", func() {
+		It("locks the tables in oid order", func() {
+			ExpectBegin(mock)
+			mock.ExpectQuery(regexp.QuoteMeta(`WHERE c.oid IN ('"Schema".foo'::regclass, 'public.bar'::regclass)`)).WillReturnRows(targetRows)
+			mock.ExpectExec(regexp.QuoteMeta("LOCK TABLE public.bar IN ACCESS SHARE MODE")).WillReturnResult(fakeResult)
+			mock.ExpectExec(regexp.QuoteMeta(`LOCK TABLE "Schema".foo IN ACCESS SHARE MODE`)).WillReturnResult(fakeResult)
+
+			connection.MustBegin()
+			err := dbconn.LockTablesInOrder(connection, []string{`"Schema".foo`, "public.bar"}, dbconn.AccessShare, dbconn.LockOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("sets a lock timeout and uses NOWAIT if requested", func() {
+			testhelper.SetDBVersion(connection, "6.0.0")
+			ExpectBegin(mock)
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(targetRows)
+			mock.ExpectExec(regexp.QuoteMeta("SET LOCAL lock_timeout = 2500")).WillReturnResult(fakeResult)
+			mock.ExpectExec(regexp.QuoteMeta("LOCK TABLE public.bar IN EXCLUSIVE MODE NOWAIT")).WillReturnResult(fakeResult)
+			mock.ExpectExec(regexp.QuoteMeta(`LOCK TABLE "Schema".foo IN EXCLUSIVE MODE NOWAIT`)).WillReturnResult(fakeResult)
+
+			connection.MustBegin()
+			err := dbconn.LockTablesInOrder(connection, []string{`"Schema".foo`, "public.bar"}, dbconn.Exclusive, dbconn.LockOptions{NoWait: true, Timeout: 2500 * time.Millisecond})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("rounds a lock timeout up to a whole millisecond rather than disabling it", func() {
+			testhelper.SetDBVersion(connection, "6.0.0")
+			ExpectBegin(mock)
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(targetRows)
+			mock.ExpectExec(regexp.QuoteMeta("SET LOCAL lock_timeout = 1")).WillReturnResult(fakeResult)
+			mock.ExpectExec(regexp.QuoteMeta("LOCK TABLE public.bar IN SHARE MODE")).WillReturnResult(fakeResult)
+			mock.ExpectExec(regexp.QuoteMeta(`LOCK TABLE "Schema".foo IN SHARE MODE`)).WillReturnResult(fakeResult)
+
+			connection.MustBegin()
+			err := dbconn.LockTablesInOrder(connection, []string{`"Schema".foo`, "public.bar"}, dbconn.Share, dbconn.LockOptions{Timeout: 500 * time.Microsecond})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("escapes single quotes in table names", func() {
+			ExpectBegin(mock)
+			mock.ExpectQuery(regexp.QuoteMeta(`WHERE c.oid IN ('public."it''s"'::regclass)`)).WillReturnRows(sqlmock.NewRows([]string{"oid", "name"}))
+
+			connection.MustBegin()
+			err := dbconn.LockTablesInOrder(connection, []string{`public."it's"`}, dbconn.Share, dbconn.LockOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("returns an error identifying the table that could not be locked", func() {
+			ExpectBegin(mock)
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(targetRows)
+			mock.ExpectExec("LOCK TABLE public.bar (.*)").WillReturnError(errors.New(`could not obtain lock on relation "bar"`))
+
+			connection.MustBegin()
+			err := dbconn.LockTablesInOrder(connection, []string{`"Schema".foo`, "public.bar"}, dbconn.AccessExclusive, dbconn.LockOptions{NoWait: true})
+			Expect(err).To(MatchError(`Could not acquire ACCESS EXCLUSIVE lock on table public.bar: could not obtain lock on relation "bar"`))
+		})
+		It("returns an error if no transaction is in progress", func() {
+			err := dbconn.LockTablesInOrder(connection, []string{"public.bar"}, dbconn.AccessShare, dbconn.LockOptions{})
+			Expect(err).To(MatchError("Cannot lock tables; there is no transaction in progress"))
+		})
+		It("returns an error for an invalid lock mode", func() {
+			err := dbconn.LockTablesInOrder(connection, []string{"public.bar"}, dbconn.LockMode("SHARE; DROP TABLE bar"), dbconn.LockOptions{})
+			Expect(err).To(MatchError("Invalid lock mode: SHARE; DROP TABLE bar"))
+		})
+		It("returns an error when a lock timeout is requested before GPDB 6", func() {
+			testhelper.SetDBVersion(connection, "5.1.0")
+			ExpectBegin(mock)
+
+			connection.MustBegin()
+			err := dbconn.LockTablesInOrder(connection, []string{"public.bar"}, dbconn.AccessShare, dbconn.LockOptions{Timeout: time.Second})
+			Expect(err).To(MatchError("Cannot set a lock timeout: lock_timeout is not supported in Greenplum Database 5.1.0"))
+		})
+	})
+})
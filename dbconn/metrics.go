@@ -0,0 +1,80 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dbconn
+
+/*
+ * This file contains the metrics recorded for connections and statements,
+ * which are published by the exporter set with metrics.SetExporter.
+ */
+
+import (
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/metrics"
+	"github.com/apache/cloudberry-go-libs/operating"
+)
+
+var (
+	connectionAttempts = metrics.NewCounter("dbconn_connections", "Attempts to open a database connection, by result.")
+	statementDuration  = metrics.NewTimer("dbconn_statement_duration_seconds", "Time taken to run each statement, by SQL command.")
+)
+
+func countConnection(err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	connectionAttempts.Inc(metrics.Labels{"result": result})
+}
+
+/*
+ * statementCommand returns the SQL command that query starts with, such as
+ * "select", ignoring leading comments, so that statements are timed by what
+ * they do without a label for every distinct query.
+ */
+func statementCommand(query string) string {
+	query = strings.TrimSpace(query)
+	for strings.HasPrefix(query, "--") || strings.HasPrefix(query, "/*") {
+		var found bool
+		if strings.HasPrefix(query, "--") {
+			_, query, found = strings.Cut(query, "\n")
+		} else {
+			_, query, found = strings.Cut(query, "*/")
+		}
+		if !found {
+			return "other"
+		}
+		query = strings.TrimSpace(query)
+	}
+	end := strings.IndexFunc(query, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	})
+	if end >= 0 {
+		query = query[:end]
+	}
+	if query == "" {
+		return "other"
+	}
+	return strings.ToLower(query)
+}
+
+func observeStatement(statement *Statement) {
+	if metrics.Enabled() {
+		statementDuration.Observe(metrics.Labels{"command": statementCommand(statement.SQL)}, operating.Since(statement.started))
+	}
+}
@@ -0,0 +1,69 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dbconn_test
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/apache/cloudberry-go-libs/dbconn"
+	"github.com/apache/cloudberry-go-libs/metrics"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/metrics tests", func() {
+	var exporter *metrics.PrometheusExporter
+	fakeResult := testhelper.TestResult{Rows: 0}
+
+	currentMetrics := func() string {
+		var buffer bytes.Buffer
+		Expect(exporter.WriteMetrics(&buffer)).To(Succeed())
+		return buffer.String()
+	}
+
+	BeforeEach(func() {
+		exporter = metrics.NewPrometheusExporter()
+		metrics.SetExporter(exporter)
+	})
+	AfterEach(func() {
+		metrics.SetExporter(nil)
+	})
+
+	It("times each statement by its SQL command", func() {
+		mock.ExpectExec("INSERT INTO foo").WillReturnResult(fakeResult)
+		mock.ExpectExec("INSERT INTO foo").WillReturnResult(fakeResult)
+		mock.ExpectQuery("SELECT 1").WillReturnRows(testhelper.StructRows(1))
+		connection.MustExec("INSERT INTO foo VALUES (1)")
+		connection.MustExec("-- add a row\n/* second */ INSERT INTO foo VALUES (2)")
+		_, err := dbconn.SelectInt(connection, "SELECT 1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(currentMetrics()).To(ContainSubstring(`dbconn_statement_duration_seconds_count{command="insert"} 2`))
+		Expect(currentMetrics()).To(ContainSubstring(`dbconn_statement_duration_seconds_count{command="select"} 1`))
+	})
+	It("counts connection attempts by result", func() {
+		connection.Close()
+		connection, mock = testhelper.CreateAndConnectMockDB(2)
+		failing, _ := testhelper.CreateMockDBConn(errors.New("connection refused"))
+		Expect(failing.Connect(1)).ToNot(Succeed())
+		Expect(currentMetrics()).To(ContainSubstring(`dbconn_connections_total{result="failure"} 1`))
+		Expect(currentMetrics()).To(ContainSubstring(`dbconn_connections_total{result="success"} 2`))
+	})
+})
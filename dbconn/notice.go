@@ -0,0 +1,227 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dbconn
+
+/*
+ * This file contains structs and functions for capturing the NOTICE, WARNING,
+ * and other non-error messages the server sends while executing a statement.
+ */
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+)
+
+/*
+ * A Notice is a single message sent by the server, along with the statement
+ * that was being executed on the connection when the message arrived.
+ */
+type Notice struct {
+	Severity  string
+	Code      string
+	Message   string
+	Detail    string
+	Hint      string
+	Statement string
+}
+
+/*
+ * A NoticeDriver is a DBDriver that can report server notices.  Connect uses
+ * ConnectWithNoticeHandler instead of Connect when the driver supports it, so
+ * test drivers that only implement DBDriver continue to work unchanged.
+ */
+type NoticeDriver interface {
+	DBDriver
+	ConnectWithNoticeHandler(driverName string, dataSourceName string, handler func(Notice)) (*sqlx.DB, error)
+}
+
+func (driver *GPDBDriver) ConnectWithNoticeHandler(driverName string, dataSourceName string, handler func(Notice)) (*sqlx.DB, error) {
+	config, err := pgx.ParseConfig(dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	config.OnNotice = func(_ *pgconn.PgConn, notice *pgconn.Notice) {
+		handler(Notice{
+			Severity: notice.Severity,
+			Code:     notice.Code,
+			Message:  notice.Message,
+			Detail:   notice.Detail,
+			Hint:     notice.Hint,
+		})
+	}
+	db := sqlx.NewDb(stdlib.OpenDB(*config), driverName)
+	// Match sqlx.Connect, which verifies the connection before returning it
+	if err = db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// The maximum length of a statement included in a logged notice
+const noticeStatementMaxLength = 200
+
+/*
+ * noticeTracker stores, for each connection, the statement currently being
+ * executed, the notices received since that statement was last checked, and
+ * the notices from the most recent statement.  Notices are received on the
+ * goroutine running the statement, but LastNotices may be called from any
+ * goroutine, so access is guarded by a mutex.
+ */
+type noticeTracker struct {
+	mutex      sync.Mutex
+	statements []string
+	pending    [][]Notice
+	last       [][]Notice
+}
+
+func newNoticeTracker(numConns int) *noticeTracker {
+	return &noticeTracker{
+		statements: make([]string, numConns),
+		pending:    make([][]Notice, numConns),
+		last:       make([][]Notice, numConns),
+	}
+}
+
+func (tracker *noticeTracker) handler(connNum int) func(Notice) {
+	return func(notice Notice) {
+		tracker.mutex.Lock()
+		defer tracker.mutex.Unlock()
+		tracker.pending[connNum] = append(tracker.pending[connNum], notice)
+	}
+}
+
+/*
+ * flush attributes any pending notices to the current statement, logs them,
+ * and adds them to that statement's notices.  The caller must hold the mutex.
+ */
+func (tracker *noticeTracker) flush(connNum int) {
+	for _, notice := range tracker.pending[connNum] {
+		notice.Statement = tracker.statements[connNum]
+		logNotice(notice)
+		tracker.last[connNum] = append(tracker.last[connNum], notice)
+	}
+	tracker.pending[connNum] = nil
+}
+
+/*
+ * begin is called before a statement is executed.  Any notices still pending
+ * belong to the previous statement (e.g. ones received while iterating over
+ * the rows returned by Query), so they are flushed before the new statement
+ * replaces it.
+ */
+func (tracker *noticeTracker) begin(connNum int, query string) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	tracker.flush(connNum)
+	tracker.statements[connNum] = query
+	tracker.last[connNum] = nil
+}
+
+func (tracker *noticeTracker) end(connNum int) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	tracker.flush(connNum)
+}
+
+func (tracker *noticeTracker) notices(connNum int) []Notice {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	tracker.flush(connNum)
+	notices := make([]Notice, len(tracker.last[connNum]))
+	copy(notices, tracker.last[connNum])
+	return notices
+}
+
+/*
+ * WARNINGs are shown to the user, while NOTICE, INFO, and LOG messages are
+ * only of interest when troubleshooting and DEBUG messages even less so.
+ */
+func logNotice(notice Notice) {
+	statement := strings.Join(strings.Fields(notice.Statement), " ")
+	if len(statement) > noticeStatementMaxLength {
+		statement = statement[:noticeStatementMaxLength] + "..."
+	}
+	message := notice.Message
+	if notice.Detail != "" {
+		message += "; DETAIL: " + notice.Detail
+	}
+	if notice.Hint != "" {
+		message += "; HINT: " + notice.Hint
+	}
+	switch {
+	case notice.Severity == "WARNING":
+		gplog.Warn("Server %s: %s (statement: %s)", notice.Severity, message, statement)
+	case strings.HasPrefix(notice.Severity, "DEBUG"):
+		gplog.Debug("Server %s: %s (statement: %s)", notice.Severity, message, statement)
+	default:
+		gplog.Verbose("Server %s: %s (statement: %s)", notice.Severity, message, statement)
+	}
+}
+
+/*
+ * beginStatement runs the statement hooks and starts collecting notices for
+ * the statement they return, which should be run in place of query.  If a
+ * hook rejects the statement, endStatement should not be called.
+ */
+func (dbconn *DBConn) beginStatement(connNum int, query string, args ...interface{}) (*Statement, error) {
+	return dbconn.beginStatementContext(context.Background(), connNum, query, args...)
+}
+
+// beginStatementContext is beginStatement for a statement run with ctx, which may carry the statement's caller
+func (dbconn *DBConn) beginStatementContext(ctx context.Context, connNum int, query string, args ...interface{}) (*Statement, error) {
+	statement, err := dbconn.runStatementHooks(ctx, connNum, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if dbconn.notices != nil {
+		dbconn.notices.begin(connNum, statement.SQL)
+	}
+	statement.started = operating.System.MonotonicNow()
+	return statement, nil
+}
+
+func (dbconn *DBConn) endStatement(connNum int, statement *Statement) {
+	observeStatement(statement)
+	if dbconn.notices != nil {
+		dbconn.notices.end(connNum)
+	}
+}
+
+/*
+ * LastNotices returns the notices the server sent while executing the most
+ * recent statement on the given connection.  For statements run with Query,
+ * notices may arrive while the rows are being read, so this should be called
+ * after the rows have been closed.  If the connection's driver does not
+ * support notices, it always returns an empty list.
+ */
+func (dbconn *DBConn) LastNotices(whichConn ...int) []Notice {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	if dbconn.notices == nil {
+		return []Notice{}
+	}
+	return dbconn.notices.notices(connNum)
+}
@@ -0,0 +1,130 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dbconn_test
+
+import (
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/dbconn"
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+	"github.com/jmoiron/sqlx"
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type noticeTestDriver struct {
+	*testhelper.TestDriver
+	handlers []func(dbconn.Notice)
+}
+
+func (driver *noticeTestDriver) ConnectWithNoticeHandler(driverName string, dataSourceName string, handler func(dbconn.Notice)) (*sqlx.DB, error) {
+	driver.handlers = append(driver.handlers, handler)
+	return driver.Connect(driverName, dataSourceName)
+}
+
+var _ = Describe("dbconn/notice tests", func() {
+	var (
+		driver  *noticeTestDriver
+		stdout  *gbytes.Buffer
+		logfile *gbytes.Buffer
+	)
+	fakeResult := testhelper.TestResult{Rows: 0}
+	warning := dbconn.Notice{Severity: "WARNING", Code: "01000", Message: "table is bloated", Hint: "Run VACUUM."}
+	notice := dbconn.Notice{Severity: "NOTICE", Code: "00000", Message: "table does not exist, skipping"}
+
+	BeforeEach(func() {
+		stdout, _, logfile = testhelper.SetupTestLogger()
+		connection, mock = testhelper.CreateMockDBConn()
+		driver = &noticeTestDriver{TestDriver: connection.Driver.(*testhelper.TestDriver)}
+		connection.Driver = driver
+		testhelper.ExpectVersionQuery(mock, "5.1.0")
+		connection.MustConnect(2)
+	})
+	It("registers a separate notice handler for each connection", func() {
+		Expect(driver.handlers).To(HaveLen(2))
+	})
+	It("attaches notices to the statement that was executing and logs them", func() {
+		mock.ExpectExec("DROP TABLE IF EXISTS foo").WillReturnResult(fakeResult)
+		connection.MustExec("DROP TABLE IF EXISTS foo")
+		driver.handlers[0](notice)
+
+		expected := notice
+		expected.Statement = "DROP TABLE IF EXISTS foo"
+		Expect(connection.LastNotices()).To(Equal([]dbconn.Notice{expected}))
+		testhelper.ExpectRegexp(logfile, "[DEBUG]:-Server NOTICE: table does not exist, skipping (statement: DROP TABLE IF EXISTS foo)")
+	})
+	It("logs warnings to the user with their hint", func() {
+		mock.ExpectExec("VACUUM foo").WillReturnResult(fakeResult)
+		connection.MustExec("VACUUM foo")
+		driver.handlers[0](warning)
+		Expect(connection.LastNotices()).To(HaveLen(1))
+		testhelper.ExpectRegexp(stdout, "[WARNING]:-Server WARNING: table is bloated; HINT: Run VACUUM. (statement: VACUUM foo)")
+	})
+	It("does not log NOTICE messages to stdout at the default verbosity", func() {
+		mock.ExpectExec("DROP TABLE IF EXISTS foo").WillReturnResult(fakeResult)
+		connection.MustExec("DROP TABLE IF EXISTS foo")
+		driver.handlers[0](notice)
+		connection.LastNotices()
+		testhelper.NotExpectRegexp(stdout, "table does not exist")
+	})
+	It("attributes notices still pending when the next statement begins to the previous statement", func() {
+		mock.ExpectExec("DROP TABLE IF EXISTS foo").WillReturnResult(fakeResult)
+		mock.ExpectExec("DROP TABLE IF EXISTS bar").WillReturnResult(fakeResult)
+		connection.MustExec("DROP TABLE IF EXISTS foo")
+		driver.handlers[0](notice)
+		connection.MustExec("DROP TABLE IF EXISTS bar")
+
+		Expect(connection.LastNotices()).To(BeEmpty())
+		testhelper.ExpectRegexp(logfile, "(statement: DROP TABLE IF EXISTS foo)")
+	})
+	It("keeps the notices for each connection separate", func() {
+		mock.ExpectExec("VACUUM foo").WillReturnResult(fakeResult)
+		mock.ExpectExec("DROP TABLE IF EXISTS bar").WillReturnResult(fakeResult)
+		connection.MustExec("VACUUM foo", 0)
+		driver.handlers[0](warning)
+		connection.MustExec("DROP TABLE IF EXISTS bar", 1)
+		driver.handlers[1](notice)
+
+		notices0 := connection.LastNotices(0)
+		notices1 := connection.LastNotices(1)
+		Expect(notices0).To(HaveLen(1))
+		Expect(notices0[0].Statement).To(Equal("VACUUM foo"))
+		Expect(notices1).To(HaveLen(1))
+		Expect(notices1[0].Statement).To(Equal("DROP TABLE IF EXISTS bar"))
+	})
+	It("returns no notices when the driver does not support them", func() {
+		connection.Close()
+		connection, mock = testhelper.CreateAndConnectMockDB(1)
+		mock.ExpectExec("VACUUM foo").WillReturnResult(fakeResult)
+		connection.MustExec("VACUUM foo")
+		Expect(connection.LastNotices()).To(BeEmpty())
+	})
+	It("shortens long statements in the log message", func() {
+		gplog.SetVerbosity(gplog.LOGVERBOSE)
+		mock.ExpectExec("SELECT").WillReturnResult(fakeResult)
+		longQuery := "SELECT '" + strings.Repeat("x", 300) + "'"
+		connection.MustExec(longQuery)
+		driver.handlers[0](notice)
+		connection.LastNotices()
+		Expect(string(stdout.Contents())).To(ContainSubstring("..."))
+		Expect(len(stdout.Contents())).To(BeNumerically("<", 400))
+	})
+})
@@ -0,0 +1,110 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dbconn
+
+/*
+ * This file contains structs and functions for connecting through a
+ * transaction-pooling proxy such as pgbouncer, which may run each transaction,
+ * or each statement outside a transaction, in a different server session.
+ */
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+/*
+ * A PoolerMode controls whether a DBConn avoids relying on session state:
+ *
+ * POOLER_MODE_OFF:    Each connection in the pool is assumed to be a single
+ *                     server session (the default).
+ * POOLER_MODE_ON:     Queries use the simple protocol so that no prepared
+ *                     statements are created, utility mode connections are
+ *                     refused, and SetGUC only sets parameters for the
+ *                     current transaction.
+ * POOLER_MODE_DETECT: Connect as usual, then check whether statements on
+ *                     the first connection run in different server sessions,
+ *                     and if so behave as POOLER_MODE_ON does for SetGUC.
+ *
+ * Whatever the mode, work that depends on session state (temporary tables,
+ * advisory locks, SET) must be done within a single transaction to work
+ * through a transaction-pooling proxy.
+ */
+type PoolerMode int
+
+const (
+	POOLER_MODE_OFF PoolerMode = iota
+	POOLER_MODE_ON
+	POOLER_MODE_DETECT
+)
+
+// The number of times DetectTransactionPooling asks for the backend pid
+const POOLER_DETECTION_QUERIES = 3
+
+// BehindPooler returns whether the connection is known to go through a transaction-pooling proxy
+func (dbconn *DBConn) BehindPooler() bool {
+	return dbconn.PoolerMode == POOLER_MODE_ON || dbconn.poolerDetected
+}
+
+/*
+ * DetectTransactionPooling runs several statements outside a transaction and
+ * returns true if they were run by more than one server backend, which only
+ * happens through a proxy that pools sessions by transaction or statement.
+ * A proxy with an otherwise idle pool may hand the same backend back each
+ * time, so a false result means that no pooling was observed rather than
+ * that there is none.
+ */
+func (dbconn *DBConn) DetectTransactionPooling(whichConn ...int) (bool, error) {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	if dbconn.Tx[connNum] != nil {
+		return false, errors.New("Cannot detect a connection pooler; there is a transaction in progress")
+	}
+	var firstPid int
+	for i := 0; i < POOLER_DETECTION_QUERIES; i++ {
+		var pid int
+		if err := dbconn.Get(&pid, "SELECT pg_backend_pid()", connNum); err != nil {
+			return false, err
+		}
+		if i == 0 {
+			firstPid = pid
+		} else if pid != firstPid {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+/*
+ * SetGUC sets a configuration parameter on the given connection.  Through a
+ * connection pooler a session-level SET would leak into whichever client is
+ * given that server session next, so if BehindPooler is true the parameter is
+ * set with SET LOCAL and a transaction must be in progress.
+ */
+func (dbconn *DBConn) SetGUC(name string, value string, whichConn ...int) error {
+	connNum := dbconn.ValidateConnNum(whichConn...)
+	scope := ""
+	if dbconn.BehindPooler() {
+		if dbconn.Tx[connNum] == nil {
+			return errors.Errorf("Cannot set %s through a connection pooler; there is no transaction in progress", name)
+		}
+		scope = "LOCAL "
+	}
+	_, err := dbconn.Exec(fmt.Sprintf("SET %s%s = %s", scope, name, value), connNum)
+	return err
+}
@@ -0,0 +1,154 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dbconn_test
+
+import (
+	"regexp"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/apache/cloudberry-go-libs/dbconn"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+	"github.com/jmoiron/sqlx"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// recordingDriver records the connection strings it is given
+type recordingDriver struct {
+	dbconn.DBDriver
+	connStrs []string
+}
+
+func (driver *recordingDriver) Connect(driverName string, dataSourceName string) (*sqlx.DB, error) {
+	driver.connStrs = append(driver.connStrs, dataSourceName)
+	return driver.DBDriver.Connect(driverName, dataSourceName)
+}
+
+var _ = Describe("dbconn/pooler tests", func() {
+	fakeResult := testhelper.TestResult{Rows: 0}
+	pidQuery := regexp.QuoteMeta("SELECT pg_backend_pid()")
+	pidRows := func(pid int) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"pg_backend_pid"}).AddRow(pid)
+	}
+
+	Describe("DBConn.Connect", func() {
+		var driver *recordingDriver
+		BeforeEach(func() {
+			connection.Close()
+			connection, mock = testhelper.CreateMockDBConn()
+			driver = &recordingDriver{DBDriver: connection.Driver}
+			connection.Driver = driver
+		})
+		It("uses the simple protocol in pooler mode", func() {
+			connection.PoolerMode = dbconn.POOLER_MODE_ON
+			testhelper.ExpectVersionQuery(mock, "6.0.0")
+
+			Expect(connection.Connect(1)).To(Succeed())
+			Expect(driver.connStrs).To(HaveLen(1))
+			Expect(driver.connStrs[0]).To(HaveSuffix("default_query_exec_mode=simple_protocol"))
+			Expect(connection.BehindPooler()).To(BeTrue())
+		})
+		It("uses unnamed prepared statements by default", func() {
+			testhelper.ExpectVersionQuery(mock, "6.0.0")
+
+			Expect(connection.Connect(1)).To(Succeed())
+			Expect(driver.connStrs[0]).To(HaveSuffix("default_query_exec_mode=exec"))
+			Expect(connection.BehindPooler()).To(BeFalse())
+		})
+		It("refuses to connect in utility mode in pooler mode", func() {
+			connection.PoolerMode = dbconn.POOLER_MODE_ON
+
+			err := connection.Connect(1, true)
+			Expect(err).To(MatchError("Cannot connect in utility mode through a connection pooler"))
+			Expect(driver.connStrs).To(BeEmpty())
+		})
+		It("detects a transaction-pooling proxy after connecting", func() {
+			connection.PoolerMode = dbconn.POOLER_MODE_DETECT
+			testhelper.ExpectVersionQuery(mock, "6.0.0")
+			mock.ExpectQuery(pidQuery).WillReturnRows(pidRows(100))
+			mock.ExpectQuery(pidQuery).WillReturnRows(pidRows(101))
+
+			Expect(connection.Connect(1)).To(Succeed())
+			Expect(driver.connStrs[0]).To(HaveSuffix("default_query_exec_mode=exec"))
+			Expect(connection.BehindPooler()).To(BeTrue())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("forgets a detected proxy when the connection is closed", func() {
+			connection.PoolerMode = dbconn.POOLER_MODE_DETECT
+			testhelper.ExpectVersionQuery(mock, "6.0.0")
+			mock.ExpectQuery(pidQuery).WillReturnRows(pidRows(100))
+			mock.ExpectQuery(pidQuery).WillReturnRows(pidRows(101))
+
+			Expect(connection.Connect(1)).To(Succeed())
+			connection.Close()
+			Expect(connection.BehindPooler()).To(BeFalse())
+		})
+	})
+	Describe("DBConn.DetectTransactionPooling", func() {
+		It("returns false if every statement runs in the same backend", func() {
+			for i := 0; i < dbconn.POOLER_DETECTION_QUERIES; i++ {
+				mock.ExpectQuery(pidQuery).WillReturnRows(pidRows(100))
+			}
+
+			detected, err := connection.DetectTransactionPooling()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(detected).To(BeFalse())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("returns true as soon as a statement runs in a different backend", func() {
+			mock.ExpectQuery(pidQuery).WillReturnRows(pidRows(100))
+			mock.ExpectQuery(pidQuery).WillReturnRows(pidRows(101))
+
+			detected, err := connection.DetectTransactionPooling()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(detected).To(BeTrue())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("returns an error if a transaction is in progress", func() {
+			ExpectBegin(mock)
+			connection.MustBegin()
+
+			_, err := connection.DetectTransactionPooling()
+			Expect(err).To(MatchError("Cannot detect a connection pooler; there is a transaction in progress"))
+		})
+	})
+	Describe("DBConn.SetGUC", func() {
+		It("sets a session-level parameter by default", func() {
+			mock.ExpectExec(regexp.QuoteMeta("SET statement_timeout = 0")).WillReturnResult(fakeResult)
+
+			Expect(connection.SetGUC("statement_timeout", "0")).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("sets a transaction-level parameter in pooler mode", func() {
+			connection.PoolerMode = dbconn.POOLER_MODE_ON
+			ExpectBegin(mock)
+			mock.ExpectExec(regexp.QuoteMeta("SET LOCAL statement_timeout = 0")).WillReturnResult(fakeResult)
+
+			connection.MustBegin()
+			Expect(connection.SetGUC("statement_timeout", "0")).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("returns an error in pooler mode if there is no transaction in progress", func() {
+			connection.PoolerMode = dbconn.POOLER_MODE_ON
+
+			err := connection.SetGUC("statement_timeout", "0")
+			Expect(err).To(MatchError("Cannot set statement_timeout through a connection pooler; there is no transaction in progress"))
+		})
+	})
+})
@@ -0,0 +1,214 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dbconn
+
+/*
+ * This file contains structs and functions for measuring the size and row
+ * count of tables in a way that accounts for append-optimized storage.
+ */
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/gpversion"
+	"github.com/pkg/errors"
+)
+
+type TableStorage string
+
+const (
+	HeapStorage     TableStorage = "heap"
+	AORowStorage    TableStorage = "ao_row"
+	AOColumnStorage TableStorage = "ao_column"
+)
+
+func (storage TableStorage) IsAppendOptimized() bool {
+	return storage == AORowStorage || storage == AOColumnStorage
+}
+
+/*
+ * TableSize describes how much space a table uses across all segments.
+ *
+ * DataBytes is the size of the table's data files alone.  For append-optimized
+ * tables this is the logical end of each segment file as recorded in the
+ * table's aoseg metadata, and UncompressedBytes is the corresponding size
+ * before compression; for heap tables the two are the same.  TotalBytes also
+ * includes indexes, TOAST data, and, for append-optimized tables, the
+ * auxiliary segment, block directory, and visibility map tables.
+ *
+ * For append-optimized tables Tuples is exact, being the number of tuples
+ * recorded in the aoseg metadata less those hidden by the visibility map
+ * after a DELETE or UPDATE.  Heap tables keep no such count, so for them
+ * Tuples is the planner's estimate from the last ANALYZE or VACUUM and
+ * TuplesEstimated is true.
+ */
+type TableSize struct {
+	Name              string
+	Storage           TableStorage
+	DataBytes         int64
+	UncompressedBytes int64
+	TotalBytes        int64
+	Tuples            int64
+	TuplesEstimated   bool
+}
+
+type tableSizeInfo struct {
+	Oid        uint32
+	Name       string
+	Storage    string
+	DataBytes  int64
+	TotalBytes int64
+	RelTuples  int64
+}
+
+type aoTableSizeInfo struct {
+	DataBytes         int64
+	UncompressedBytes int64
+	Tuples            int64
+	HiddenTuples      int64
+}
+
+func MustGetTableSize(connection *DBConn, table string, whichConn ...int) TableSize {
+	size, err := GetTableSize(connection, table, whichConn...)
+	gplog.FatalOnError(err)
+	return size
+}
+
+/*
+ * GetTableSize returns the size and row count of the given table, which may
+ * be schema-qualified and is resolved with regclass.  Measuring
+ * append-optimized tables relies on gp_toolkit functions that are not
+ * available before GPDB 6, so that is an error there.
+ */
+func GetTableSize(connection *DBConn, table string, whichConn ...int) (TableSize, error) {
+	connNum := connection.ValidateConnNum(whichConn...)
+	info, err := getTableSizeInfo(connection, table, connNum)
+	if err != nil {
+		return TableSize{}, errors.Wrapf(err, "Could not get size of table %s", table)
+	}
+
+	size := TableSize{
+		Name:              info.Name,
+		DataBytes:         info.DataBytes,
+		UncompressedBytes: info.DataBytes,
+		TotalBytes:        info.TotalBytes,
+		Tuples:            info.RelTuples,
+		TuplesEstimated:   true,
+	}
+	switch info.Storage {
+	case "h", "heap":
+		size.Storage = HeapStorage
+		return size, nil
+	case "a", "ao_row":
+		size.Storage = AORowStorage
+	case "c", "ao_column":
+		size.Storage = AOColumnStorage
+	default:
+		return TableSize{}, errors.Errorf("Could not get size of table %s: unsupported storage type %s", info.Name, info.Storage)
+	}
+
+	if connection.Version.IsGPDB() && connection.Version.Before("6") {
+		return TableSize{}, errors.New("Append-optimized table sizes are not supported before GPDB 6")
+	}
+	aoInfo, err := getAOTableSizeInfo(connection, info.Oid, size.Storage, connNum)
+	if err != nil {
+		return TableSize{}, errors.Wrapf(err, "Could not get size of table %s", info.Name)
+	}
+	size.DataBytes = aoInfo.DataBytes
+	size.UncompressedBytes = aoInfo.UncompressedBytes
+	size.Tuples = aoInfo.Tuples - aoInfo.HiddenTuples
+	size.TuplesEstimated = false
+	return size, nil
+}
+
+func MustGetTableSizes(connection *DBConn, tables []string, whichConn ...int) []TableSize {
+	sizes, err := GetTableSizes(connection, tables, whichConn...)
+	gplog.FatalOnError(err)
+	return sizes
+}
+
+// GetTableSizes calls GetTableSize for each table and returns the sizes in the same order
+func GetTableSizes(connection *DBConn, tables []string, whichConn ...int) ([]TableSize, error) {
+	connNum := connection.ValidateConnNum(whichConn...)
+	sizes := make([]TableSize, 0, len(tables))
+	for _, table := range tables {
+		size, err := GetTableSize(connection, table, connNum)
+		if err != nil {
+			return nil, err
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+/*
+ * Before GPDB 7 the storage type of a table is recorded in pg_class.relstorage;
+ * from GPDB 7 on, and in Cloudberry, it is the name of the table's access
+ * method.  Both pg_relation_size and pg_total_relation_size are summed across
+ * all segments when called on the coordinator.
+ */
+func getTableSizeInfo(connection *DBConn, table string, connNum int) (tableSizeInfo, error) {
+	storageColumn := "coalesce(a.amname, '')"
+	amJoin := "\nLEFT JOIN pg_am a ON c.relam = a.oid"
+	if connection.Version.Supports(gpversion.RELSTORAGE) {
+		storageColumn = "c.relstorage"
+		amJoin = ""
+	}
+	query := fmt.Sprintf(`
+SELECT
+	c.oid,
+	quote_ident(n.nspname) || '.' || quote_ident(c.relname) AS name,
+	%s AS storage,
+	pg_relation_size(c.oid) AS databytes,
+	pg_total_relation_size(c.oid) AS totalbytes,
+	greatest(c.reltuples, 0)::bigint AS reltuples
+FROM pg_class c
+JOIN pg_namespace n ON c.relnamespace = n.oid%s
+WHERE c.oid = '%s'::regclass;`, storageColumn, amJoin, strings.ReplaceAll(table, "'", "''"))
+
+	var info tableSizeInfo
+	err := connection.Get(&info, query, connNum)
+	return info, err
+}
+
+/*
+ * Column-oriented tables have one aocsseg entry per column for each segment
+ * file, each recording the same tuple count, so only the first column's
+ * count is used.
+ */
+func getAOTableSizeInfo(connection *DBConn, oid uint32, storage TableStorage, connNum int) (aoTableSizeInfo, error) {
+	segFunction := "__gp_aoseg"
+	tupleColumn := "tupcount"
+	if storage == AOColumnStorage {
+		segFunction = "__gp_aocsseg"
+		tupleColumn = "CASE WHEN column_num = 0 THEN tupcount ELSE 0 END"
+	}
+	query := fmt.Sprintf(`
+SELECT
+	coalesce(sum(eof), 0)::bigint AS databytes,
+	coalesce(sum(eof_uncompressed), 0)::bigint AS uncompressedbytes,
+	coalesce(sum(%s), 0)::bigint AS tuples,
+	(SELECT coalesce(sum(hidden_tupcount), 0)::bigint FROM gp_toolkit.__gp_aovisimap_hidden_info(%d)) AS hiddentuples
+FROM gp_toolkit.%s('%d'::regclass);`, tupleColumn, oid, segFunction, oid)
+
+	var info aoTableSizeInfo
+	err := connection.Get(&info, query, connNum)
+	return info, err
+}
@@ -0,0 +1,128 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dbconn_test
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/apache/cloudberry-go-libs/dbconn"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/size tests", func() {
+	type tableRow struct {
+		Oid        uint32
+		Name       string
+		Storage    string
+		DataBytes  int64
+		TotalBytes int64
+		RelTuples  int64
+	}
+	type aoRow struct {
+		DataBytes         int64
+		UncompressedBytes int64
+		Tuples            int64
+		HiddenTuples      int64
+	}
+
+	Describe("GetTableSize", func() {
+		It("estimates the row count of a heap table", func() {
+			testhelper.ExpectQueryReturning(mock, regexp.QuoteMeta("c.relstorage AS storage"),
+				tableRow{Oid: 16384, Name: "public.foo", Storage: "h", DataBytes: 32768, TotalBytes: 65536, RelTuples: 1000})
+
+			size, err := dbconn.GetTableSize(connection, "public.foo")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(size).To(Equal(dbconn.TableSize{Name: "public.foo", Storage: dbconn.HeapStorage, DataBytes: 32768,
+				UncompressedBytes: 32768, TotalBytes: 65536, Tuples: 1000, TuplesEstimated: true}))
+			testhelper.AssertAllExpectationsMet(mock)
+		})
+		It("counts the visible rows of an append-optimized row table from its segment metadata", func() {
+			testhelper.SetDBVersion(connection, "6.20.0")
+			testhelper.ExpectQueryReturning(mock, regexp.QuoteMeta(`WHERE c.oid = 'public."it''s"'::regclass`),
+				tableRow{Oid: 16390, Name: `public."it's"`, Storage: "a", DataBytes: 4096, TotalBytes: 200000, RelTuples: 0})
+			testhelper.ExpectQueryReturning(mock, regexp.QuoteMeta("FROM gp_toolkit.__gp_aoseg('16390'::regclass)"),
+				aoRow{DataBytes: 120000, UncompressedBytes: 480000, Tuples: 5000, HiddenTuples: 200})
+
+			size, err := dbconn.GetTableSize(connection, `public."it's"`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(size).To(Equal(dbconn.TableSize{Name: `public."it's"`, Storage: dbconn.AORowStorage, DataBytes: 120000,
+				UncompressedBytes: 480000, TotalBytes: 200000, Tuples: 4800}))
+			Expect(size.Storage.IsAppendOptimized()).To(BeTrue())
+			testhelper.AssertAllExpectationsMet(mock)
+		})
+		It("uses the access method and the first column's tuple counts for a column-oriented table", func() {
+			connection.Version = dbconn.NewVersion("2.0.0")
+			connection.Version.Type = dbconn.CBDB
+			testhelper.ExpectQueryReturning(mock, regexp.QuoteMeta("LEFT JOIN pg_am a ON c.relam = a.oid"),
+				tableRow{Oid: 16400, Name: "public.bar", Storage: "ao_column", DataBytes: 4096, TotalBytes: 300000})
+			testhelper.ExpectQueryReturning(mock, regexp.QuoteMeta("sum(CASE WHEN column_num = 0 THEN tupcount ELSE 0 END)")+
+				"(.*)"+regexp.QuoteMeta("FROM gp_toolkit.__gp_aocsseg('16400'::regclass)"),
+				aoRow{DataBytes: 250000, UncompressedBytes: 900000, Tuples: 10000})
+
+			size, err := dbconn.GetTableSize(connection, "public.bar")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(size.Storage).To(Equal(dbconn.AOColumnStorage))
+			Expect(size.DataBytes).To(Equal(int64(250000)))
+			Expect(size.Tuples).To(Equal(int64(10000)))
+			Expect(size.TuplesEstimated).To(BeFalse())
+			testhelper.AssertAllExpectationsMet(mock)
+		})
+		It("returns an error for an append-optimized table before GPDB 6", func() {
+			testhelper.ExpectQueryReturning(mock, "SELECT (.*)", tableRow{Oid: 16390, Name: "public.foo", Storage: "c"})
+
+			_, err := dbconn.GetTableSize(connection, "public.foo")
+			Expect(err).To(MatchError("Append-optimized table sizes are not supported before GPDB 6"))
+		})
+		It("returns an error for a relation that is not a heap or append-optimized table", func() {
+			testhelper.ExpectQueryReturning(mock, "SELECT (.*)", tableRow{Oid: 16390, Name: "public.ext", Storage: "x"})
+
+			_, err := dbconn.GetTableSize(connection, "public.ext")
+			Expect(err).To(MatchError("Could not get size of table public.ext: unsupported storage type x"))
+		})
+		It("returns an error if the table cannot be found", func() {
+			testhelper.ExpectQueryFailing(mock, "SELECT (.*)", errors.New(`relation "public.missing" does not exist`))
+
+			_, err := dbconn.GetTableSize(connection, "public.missing")
+			Expect(err).To(MatchError(`Could not get size of table public.missing: relation "public.missing" does not exist`))
+		})
+		It("panics in the Must variant if the segment metadata cannot be read", func() {
+			testhelper.SetDBVersion(connection, "6.20.0")
+			testhelper.ExpectQueryReturning(mock, "SELECT (.*)", tableRow{Oid: 16390, Name: "public.foo", Storage: "a"})
+			testhelper.ExpectQueryFailing(mock, "SELECT (.*)", errors.New("permission denied for schema gp_toolkit"))
+
+			defer testhelper.ShouldPanicWithMessage("Could not get size of table public.foo: permission denied for schema gp_toolkit")
+			dbconn.MustGetTableSize(connection, "public.foo")
+		})
+	})
+	Describe("GetTableSizes", func() {
+		It("returns sizes in the order the tables were given", func() {
+			testhelper.ExpectQueryReturning(mock, regexp.QuoteMeta("'public.foo'::regclass"), tableRow{Oid: 16384, Name: "public.foo", Storage: "h", RelTuples: 10})
+			testhelper.ExpectQueryReturning(mock, regexp.QuoteMeta("'public.bar'::regclass"), tableRow{Oid: 16380, Name: "public.bar", Storage: "h", RelTuples: 20})
+
+			sizes, err := dbconn.GetTableSizes(connection, []string{"public.foo", "public.bar"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sizes).To(HaveLen(2))
+			Expect(sizes[0].Name).To(Equal("public.foo"))
+			Expect(sizes[1].Tuples).To(Equal(int64(20)))
+		})
+	})
+})
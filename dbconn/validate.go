@@ -0,0 +1,139 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dbconn
+
+/*
+ * This file contains functions for checking that every connection in the
+ * pool works before a utility starts using them, so that problems such as a
+ * missing pg_hba.conf entry or an SSL misconfiguration are reported at
+ * startup rather than when a worker first uses its connection.
+ */
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/retry"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// How long ValidateConnections waits for each connection to answer a query
+var ConnectionValidationTimeout = 30 * time.Second
+
+func validateConnection(conn *sqlx.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ConnectionValidationTimeout)
+	defer cancel()
+	var result int
+	return conn.QueryRowxContext(ctx, "SELECT 1").Scan(&result)
+}
+
+/*
+ * ValidateConnections runs a trivial query on every connection in the pool in
+ * parallel.  Each connection that fails is closed and replaced with a new
+ * connection, which is validated in turn, and an error is returned listing
+ * each connection that could not be replaced.  Connecting is retried as
+ * ReconnectPolicy says, unless it fails with an error that retrying cannot
+ * fix, such as a missing pg_hba.conf entry.  Connections with a transaction
+ * in progress are skipped, since replacing them would lose the transaction.
+ */
+func (dbconn *DBConn) ValidateConnections() error {
+	if dbconn.ConnPool == nil {
+		return errors.New("Cannot validate connections; the database connection is not open")
+	}
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	failures := make(map[int]error)
+	for i := 0; i < dbconn.NumConns; i++ {
+		if dbconn.Tx[i] != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(connNum int) {
+			defer wg.Done()
+			if err := dbconn.validateOrReplace(connNum); err != nil {
+				mutex.Lock()
+				failures[connNum] = err
+				mutex.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(failures) == 0 {
+		return nil
+	}
+	connNums := make([]int, 0, len(failures))
+	for connNum := range failures {
+		connNums = append(connNums, connNum)
+	}
+	sort.Ints(connNums)
+	messages := make([]string, len(connNums))
+	for i, connNum := range connNums {
+		messages[i] = fmt.Sprintf("connection %d: %s", connNum, failures[connNum])
+	}
+	return errors.Errorf("%d of %d database connections are not usable:\n%s", len(failures), dbconn.NumConns, strings.Join(messages, "\n"))
+}
+
+func (dbconn *DBConn) MustValidateConnections() {
+	err := dbconn.ValidateConnections()
+	gplog.FatalOnError(err)
+}
+
+// Connection errors that connecting again will not fix
+var permanentConnectionErrors = []string{
+	"does not exist",
+	"no pg_hba.conf entry",
+	"password authentication failed",
+}
+
+func isTransientConnectionError(err error) bool {
+	for _, message := range permanentConnectionErrors {
+		if strings.Contains(err.Error(), message) {
+			return false
+		}
+	}
+	return true
+}
+
+// validateOrReplace only touches ConnPool[connNum], so it can run in parallel for different connections
+func (dbconn *DBConn) validateOrReplace(connNum int) error {
+	err := validateConnection(dbconn.ConnPool[connNum])
+	if err == nil {
+		return nil
+	}
+	gplog.Verbose("Database connection %d failed validation, reconnecting: %v", connNum, err)
+	_ = dbconn.ConnPool[connNum].Close()
+	policy := dbconn.ReconnectPolicy
+	if policy.Retryable == nil {
+		policy.Retryable = isTransientConnectionError
+	}
+	conn, err := retry.DoWithResult(context.Background(), policy, func(context.Context) (*sqlx.DB, error) {
+		return dbconn.connectOne(dbconn.connStr, dbconn.notices, connNum)
+	})
+	if err != nil {
+		return err
+	}
+	dbconn.ConnPool[connNum] = conn
+	return validateConnection(conn)
+}
@@ -0,0 +1,108 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dbconn_test
+
+import (
+	"errors"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/apache/cloudberry-go-libs/dbconn"
+	"github.com/apache/cloudberry-go-libs/retry"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dbconn/validate tests", func() {
+	oneRow := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"?column?"}).AddRow(1)
+	}
+
+	Describe("ValidateConnections", func() {
+		It("runs a query on each connection", func() {
+			mock.ExpectQuery("SELECT 1").WillReturnRows(oneRow())
+			Expect(connection.ValidateConnections()).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("replaces a connection that fails the query", func() {
+			mock.ExpectQuery("SELECT 1").WillReturnError(errors.New("SSL SYSCALL error: EOF detected"))
+			newDB, newMock := testhelper.CreateMockDB()
+			newMock.ExpectQuery("SELECT 1").WillReturnRows(oneRow())
+			connection.Driver = &testhelper.TestDriver{DB: newDB}
+
+			Expect(connection.ValidateConnections()).To(Succeed())
+			Expect(connection.ConnPool[0]).To(BeIdenticalTo(newDB))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+			Expect(newMock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("returns an error for each connection that cannot be replaced", func() {
+			mock.ExpectQuery("SELECT 1").WillReturnError(errors.New("SSL SYSCALL error: EOF detected"))
+			connection.Driver = &testhelper.TestDriver{ErrToReturn: errors.New(`pq: no pg_hba.conf entry for host "10.0.0.5"`)}
+
+			err := connection.ValidateConnections()
+			Expect(err).To(MatchError(`1 of 1 database connections are not usable:
+connection 0: pq: no pg_hba.conf entry for host "10.0.0.5" (testhost:5432)`))
+		})
+		It("retries replacing a connection as the reconnect policy says", func() {
+			mock.ExpectQuery("SELECT 1").WillReturnError(errors.New("SSL SYSCALL error: EOF detected"))
+			newDB, newMock := testhelper.CreateMockDB()
+			newMock.ExpectQuery("SELECT 1").WillReturnRows(oneRow())
+			connection.Driver = &testhelper.TestDriver{DB: newDB, ErrsToReturn: []error{errors.New("pq: the database system is starting up")}}
+			connection.ReconnectPolicy = retry.Constant(3, time.Millisecond)
+
+			Expect(connection.ValidateConnections()).To(Succeed())
+			Expect(connection.ConnPool[0]).To(BeIdenticalTo(newDB))
+			Expect(newMock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("does not retry replacing a connection after an error that retrying cannot fix", func() {
+			mock.ExpectQuery("SELECT 1").WillReturnError(errors.New("SSL SYSCALL error: EOF detected"))
+			newDB, _ := testhelper.CreateMockDB()
+			driver := &testhelper.TestDriver{DB: newDB, ErrsToReturn: []error{errors.New(`pq: no pg_hba.conf entry for host "10.0.0.5"`)}}
+			connection.Driver = driver
+			connection.ReconnectPolicy = retry.Constant(3, time.Millisecond)
+
+			Expect(connection.ValidateConnections()).To(MatchError(ContainSubstring("no pg_hba.conf entry")))
+			Expect(driver.CallNumber).To(Equal(1))
+		})
+		It("returns an error if the replacement connection also fails the query", func() {
+			mock.ExpectQuery("SELECT 1").WillReturnError(errors.New("SSL SYSCALL error: EOF detected"))
+			newDB, newMock := testhelper.CreateMockDB()
+			newMock.ExpectQuery("SELECT 1").WillReturnError(errors.New("SSL SYSCALL error: EOF detected"))
+			connection.Driver = &testhelper.TestDriver{DB: newDB}
+
+			Expect(connection.ValidateConnections()).To(MatchError(ContainSubstring("connection 0: SSL SYSCALL error: EOF detected")))
+		})
+		It("skips connections with a transaction in progress", func() {
+			ExpectBegin(mock)
+			connection.MustBegin()
+			Expect(connection.ValidateConnections()).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("returns an error if the connection is not open", func() {
+			Expect(dbconn.NewDBConn("testdb", "testrole", "testhost", 5432).ValidateConnections()).To(MatchError("Cannot validate connections; the database connection is not open"))
+		})
+		It("panics in the Must variant if a connection cannot be replaced", func() {
+			mock.ExpectQuery("SELECT 1").WillReturnError(errors.New("SSL SYSCALL error: EOF detected"))
+			connection.Driver = &testhelper.TestDriver{ErrToReturn: errors.New("pq: connection refused")}
+			defer testhelper.ShouldPanicWithMessage("1 of 1 database connections are not usable")
+			connection.MustValidateConnections()
+		})
+	})
+})
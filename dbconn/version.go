@@ -1,10 +1,8 @@
 package dbconn
 
 import (
-	"regexp"
-	"strings"
-
 	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/gpversion"
 	"github.com/blang/semver/v4"
 )
 
@@ -17,11 +15,6 @@ const (
 	CBDB           // Apache Cloudberry Database
 )
 
-const (
-	gpdbPattern = `\(Greenplum Database ([0-9]+\.[0-9]+\.[0-9]+)[^)]*\)`
-	cbdbPattern = `\(Apache Cloudberry ([0-9]+\.[0-9]+\.[0-9]+)[^)]*\)`
-)
-
 // String provides string representation of DBType
 func (t DBType) String() string {
 	switch t {
@@ -76,42 +69,35 @@ func InitializeVersion(dbconn *DBConn) (dbversion GPDBVersion, err error) {
 	return
 }
 
+/*
+ * ParseVersionInfo sets the type and semantic version of the database from
+ * versionString using gpversion.Parse, which also understands pre-release
+ * and development builds and vendor builds of each product.  The type is
+ * Unknown and the version 0.0.0 for anything other than Greenplum or
+ * Cloudberry.
+ */
 func (dbversion *GPDBVersion) ParseVersionInfo(versionString string) {
 	dbversion.VersionString = versionString
 	dbversion.Type = Unknown
+	dbversion.SemVer = semver.Version{}
 
-	// Try to match each database type.
-	// We check for Apache Cloudberry first as its string may be a superset of others in the future.
-	if ver, ok := dbversion.extractVersion(cbdbPattern); ok {
-		dbversion.Type = CBDB
-		dbversion.SemVer = ver
-	} else if ver, ok := dbversion.extractVersion(gpdbPattern); ok {
-		dbversion.Type = GPDB
-		dbversion.SemVer = ver
-	}
-}
-
-func (dbversion GPDBVersion) extractVersion(pattern string) (semver.Version, bool) {
-	re := regexp.MustCompile(pattern)
-	matches := re.FindStringSubmatch(dbversion.VersionString)
-	if len(matches) < 2 {
-		return semver.Version{}, false
-	}
-
-	ver, err := semver.Make(matches[1])
+	version, err := gpversion.Parse(versionString)
 	if err != nil {
-		return semver.Version{}, false
+		return
+	}
+	switch version.Flavor {
+	case gpversion.GREENPLUM:
+		dbversion.Type = GPDB
+		dbversion.SemVer = version.SemVer
+	case gpversion.CLOUDBERRY:
+		dbversion.Type = CBDB
+		dbversion.SemVer = version.SemVer
 	}
-	return ver, true
 }
 
+// StringToSemVerRange panics if versionStr is not a valid gpversion.Range, as that is programmer error
 func (dbversion GPDBVersion) StringToSemVerRange(versionStr string) semver.Range {
-	numDigits := len(strings.Split(versionStr, "."))
-	if numDigits < 3 {
-		versionStr += ".x"
-	}
-	validRange := semver.MustParseRange(versionStr)
-	return validRange
+	return gpversion.MustParseRange(versionStr).ContainsSemVer
 }
 
 func (dbversion GPDBVersion) Before(targetVersion string) bool {
@@ -137,6 +123,22 @@ func (dbversion GPDBVersion) IsCBDB() bool {
 	return dbversion.Type == CBDB
 }
 
+// GPVersion returns the version as a gpversion.Version, for use with the functions in that package
+func (dbversion GPDBVersion) GPVersion() gpversion.Version {
+	flavor := gpversion.UNKNOWN
+	switch dbversion.Type {
+	case GPDB:
+		flavor = gpversion.GREENPLUM
+	case CBDB:
+		flavor = gpversion.CLOUDBERRY
+	}
+	return gpversion.Version{VersionString: dbversion.VersionString, Flavor: flavor, SemVer: dbversion.SemVer}
+}
+
+func (dbversion GPDBVersion) Supports(feature gpversion.Feature) bool {
+	return dbversion.GPVersion().Supports(feature)
+}
+
 func (srcVersion GPDBVersion) Equals(destVersion GPDBVersion) bool {
 	if srcVersion.Type != destVersion.Type {
 		return false
@@ -2,6 +2,9 @@ package dbconn_test
 
 import (
 	"github.com/apache/cloudberry-go-libs/dbconn"
+	"github.com/apache/cloudberry-go-libs/gpversion"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
 	"github.com/blang/semver/v4"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -41,6 +44,20 @@ var _ = Describe("dbconn/version tests", func() {
 			Expect(dbVersion.Type).To(Equal(dbconn.Unknown))
 			Expect(dbVersion.SemVer.String()).To(Equal("0.0.0"))
 		})
+		It("keeps the pre-release of a beta version string", func() {
+			versionStr := "PostgreSQL 12.12 (Greenplum Database 7.0.0-beta.1 build commit:bf073b87c0bac9759631746dca1c4c895a304afb) on x86_64-pc-linux-gnu"
+			dbVersion := dbconn.GPDBVersion{}
+			dbVersion.ParseVersionInfo(versionStr)
+			Expect(dbVersion.Type).To(Equal(dbconn.GPDB))
+			Expect(dbVersion.SemVer.String()).To(Equal("7.0.0-beta.1"))
+			Expect(dbVersion.AtLeast("7")).To(BeTrue())
+		})
+		It("treats a PostgreSQL version string as unknown", func() {
+			dbVersion := dbconn.GPDBVersion{}
+			dbVersion.ParseVersionInfo("PostgreSQL 16.2 on x86_64-pc-linux-gnu")
+			Expect(dbVersion.Type).To(Equal(dbconn.Unknown))
+			Expect(dbVersion.SemVer.String()).To(Equal("0.0.0"))
+		})
 	})
 	Describe("StringToSemVerRange", func() {
 		v400 := semver.MustParse("4.0.0")
@@ -135,6 +152,13 @@ var _ = Describe("dbconn/version tests", func() {
 			Expect(result).To(BeFalse())
 		})
 	})
+	Describe("Supports", func() {
+		It("checks features for the type of database", func() {
+			Expect(fakeGPDB5.Supports(gpversion.FILESPACES)).To(BeTrue())
+			Expect(fakeCBDB2.Supports(gpversion.FILESPACES)).To(BeFalse())
+			Expect(dbconn.GPDBVersion{}.Supports(gpversion.RELSTORAGE)).To(BeFalse())
+		})
+	})
 	Describe("Equals", func() {
 		It("returns false if db types are different", func() {
 			Expect(fakeGPDB5.Equals(fakeCBDB2)).To(BeFalse())
@@ -148,3 +172,51 @@ var _ = Describe("dbconn/version tests", func() {
 		})
 	})
 })
+
+var _ = Describe("testhelper.ParseServerTargets", func() {
+	It("parses every part of each server", func() {
+		operating.WithEnv(map[string]string{"PGUSER": "", "PGDATABASE": ""}, func() {
+			targets, err := testhelper.ParseServerTargets("gpdb6=gpadmin@mdw6:6000/testdb, cbdb=cdw")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(targets).To(HaveLen(2))
+			Expect(targets[0]).To(Equal(testhelper.ServerTarget{Name: "gpdb6", Host: "mdw6", Port: 6000, User: "gpadmin", DBName: "testdb"}))
+			Expect(targets[1].Name).To(Equal("cbdb"))
+			Expect(targets[1].Host).To(Equal("cdw"))
+			Expect(targets[1].Port).To(Equal(5432))
+			Expect(targets[1].User).ToNot(BeEmpty())
+			Expect(targets[1].DBName).To(Equal("postgres"))
+		})
+	})
+	It("defaults the user and database from the environment", func() {
+		operating.WithEnv(map[string]string{"PGUSER": "tester", "PGDATABASE": "regression"}, func() {
+			targets, err := testhelper.ParseServerTargets("gpdb7=localhost:7000")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(targets).To(Equal([]testhelper.ServerTarget{{Name: "gpdb7", Host: "localhost", Port: 7000, User: "tester", DBName: "regression"}}))
+		})
+	})
+	It("returns no servers for an empty list", func() {
+		targets, err := testhelper.ParseServerTargets("")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(targets).To(BeEmpty())
+	})
+	DescribeTable("rejects invalid servers", func(targetsStr string, expectedErr string) {
+		_, err := testhelper.ParseServerTargets(targetsStr)
+		Expect(err).To(MatchError(expectedErr))
+	},
+		Entry("no name", "localhost:5432", `Invalid server "localhost:5432"; must be in the form name=[user@]host[:port][/dbname]`),
+		Entry("no host", "gpdb6=gpadmin@:5432", "No host given for server gpdb6"),
+		Entry("invalid port", "gpdb6=localhost:port", `Invalid port "port" for server gpdb6`),
+		Entry("duplicate name", "gpdb6=mdw1,gpdb6=mdw2", "Server gpdb6 is listed more than once"),
+	)
+})
+
+var _ = testhelper.NewServerMatrixFromEnvironment().Describe("dbconn/version against live servers", func(server *testhelper.MatrixServer) {
+	It("identifies the type and version of the server", func() {
+		Expect(server.Version.Type).ToNot(Equal(dbconn.Unknown), server.Version.VersionString)
+		Expect(server.Version.SemVer.Major).To(BeNumerically(">=", 1))
+	})
+	It("reports the same version as a new query does", func() {
+		versionStr := dbconn.MustSelectString(server.Connection, "SELECT pg_catalog.version()")
+		Expect(versionStr).To(Equal(server.Version.VersionString))
+	})
+})
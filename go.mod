@@ -9,9 +9,14 @@ require (
 	github.com/jmoiron/sqlx v1.3.5
 	github.com/onsi/gomega v1.27.10
 	github.com/pkg/errors v0.9.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require github.com/onsi/ginkgo/v2 v2.13.0
+require (
+	github.com/klauspost/compress v1.18.0
+	github.com/onsi/ginkgo/v2 v2.13.0
+	golang.org/x/sys v0.38.0
+)
 
 require (
 	github.com/go-logr/logr v1.2.4 // indirect
@@ -28,8 +33,6 @@ require (
 	github.com/rogpeppe/go-internal v1.14.1 // indirect
 	golang.org/x/net v0.47.0 // indirect
 	golang.org/x/sync v0.18.0 // indirect
-	golang.org/x/sys v0.38.0 // indirect
 	golang.org/x/text v0.31.0 // indirect
 	golang.org/x/tools v0.38.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
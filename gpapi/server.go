@@ -0,0 +1,260 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpapi
+
+/*
+ * This file contains an HTTP server that long-running agents can embed to
+ * expose the same administrative API: their health, their version, the
+ * status of the operation they are running, and their log levels.  The
+ * server is never started unless an agent calls Start or mounts NewHandler.
+ */
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/gpbanner"
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+// The address the server listens on if none is given; it only accepts local connections
+const DEFAULT_ADDRESS = "127.0.0.1:9465"
+
+/*
+ * Options configures the API.  Every endpoint but /health requires the header
+ * "Authorization: Bearer <Token>", so Token must be set.  Status reports the
+ * agent's current operation, and is usually an OperationTracker; if it is nil,
+ * the agent is always reported as idle.
+ */
+type Options struct {
+	Address string
+	Token   string
+	Tool    gpbanner.ToolInfo
+	Status  StatusProvider
+}
+
+type api struct {
+	options Options
+	started time.Duration
+}
+
+type healthJSON struct {
+	Status        string  `json:"status"`
+	UptimeSeconds float64 `json:"uptime_seconds"`
+}
+
+type versionJSON struct {
+	Tool      string `json:"tool"`
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+}
+
+type errorJSON struct {
+	Error string `json:"error"`
+}
+
+/*
+ * LogLevels holds the verbosity of each log destination by name, as accepted
+ * by ParseLogLevel.  In a request to change the levels, destinations that are
+ * left empty are not changed.
+ */
+type LogLevels struct {
+	Stdout string `json:"stdout,omitempty"`
+	Stderr string `json:"stderr,omitempty"`
+	File   string `json:"file,omitempty"`
+}
+
+var logLevelNames = []string{"none", "error", "info", "verbose", "debug"}
+
+// ParseLogLevel returns the gplog verbosity for a level name, from "none" (gplog.LOGNONE) to "debug" (gplog.LOGDEBUG)
+func ParseLogLevel(name string) (int, error) {
+	for i, levelName := range logLevelNames {
+		if strings.EqualFold(name, levelName) {
+			return gplog.LOGNONE + i, nil
+		}
+	}
+	return 0, errors.Errorf("Invalid log level %q; must be one of %s", name, strings.Join(logLevelNames, ", "))
+}
+
+func LogLevelName(verbosity int) string {
+	if verbosity < gplog.LOGNONE || verbosity > gplog.LOGDEBUG {
+		return "unknown"
+	}
+	return logLevelNames[verbosity-gplog.LOGNONE]
+}
+
+func currentLogLevels() LogLevels {
+	return LogLevels{
+		Stdout: LogLevelName(gplog.GetStreamVerbosity(gplog.STREAM_STDOUT)),
+		Stderr: LogLevelName(gplog.GetStreamVerbosity(gplog.STREAM_STDERR)),
+		File:   LogLevelName(gplog.GetLogFileVerbosity()),
+	}
+}
+
+/*
+ * NewHandler returns a handler serving the API, for agents that already run
+ * an HTTP server and want to mount the API on it.  The endpoints are
+ *
+ *   GET /health    whether the agent is up, without authentication
+ *   GET /version   the agent's ToolInfo
+ *   GET /status    the agent's current OperationStatus
+ *   GET /loglevel  the agent's LogLevels
+ *   PUT /loglevel  change the agent's LogLevels
+ *
+ * Responses are JSON, and errors are returned as {"error": "message"}.
+ */
+func NewHandler(options Options) (http.Handler, error) {
+	if options.Token == "" {
+		return nil, errors.New("An API token is required")
+	}
+	api := &api{options: options, started: operating.System.MonotonicNow()}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", api.health)
+	mux.Handle("GET /version", api.authenticated(api.version))
+	mux.Handle("GET /status", api.authenticated(api.status))
+	mux.Handle("GET /loglevel", api.authenticated(api.getLogLevels))
+	mux.Handle("PUT /loglevel", api.authenticated(api.setLogLevels))
+	return mux, nil
+}
+
+func writeResponse(writer http.ResponseWriter, code int, value interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(code)
+	_ = json.NewEncoder(writer).Encode(value)
+}
+
+func writeError(writer http.ResponseWriter, code int, format string, args ...interface{}) {
+	writeResponse(writer, code, errorJSON{Error: errors.Errorf(format, args...).Error()})
+}
+
+func (api *api) authenticated(handler http.HandlerFunc) http.Handler {
+	expected := []byte("Bearer " + api.options.Token)
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(request.Header.Get("Authorization")), expected) != 1 {
+			writer.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(writer, http.StatusUnauthorized, "A valid API token is required")
+			return
+		}
+		handler(writer, request)
+	})
+}
+
+func (api *api) health(writer http.ResponseWriter, _ *http.Request) {
+	uptime := operating.Since(api.started)
+	writeResponse(writer, http.StatusOK, healthJSON{Status: "ok", UptimeSeconds: uptime.Seconds()})
+}
+
+func (api *api) version(writer http.ResponseWriter, _ *http.Request) {
+	tool := api.options.Tool
+	writeResponse(writer, http.StatusOK, versionJSON{Tool: tool.Name, Version: tool.GetVersion(), Commit: tool.Commit, BuildDate: tool.BuildDate})
+}
+
+func (api *api) status(writer http.ResponseWriter, _ *http.Request) {
+	status := OperationStatus{State: OPERATION_IDLE}
+	if api.options.Status != nil {
+		status = api.options.Status.OperationStatus()
+	}
+	writeResponse(writer, http.StatusOK, status)
+}
+
+func (api *api) getLogLevels(writer http.ResponseWriter, _ *http.Request) {
+	writeResponse(writer, http.StatusOK, currentLogLevels())
+}
+
+// setLogLevels checks every requested level before changing any, so that an invalid request changes nothing
+func (api *api) setLogLevels(writer http.ResponseWriter, request *http.Request) {
+	var levels LogLevels
+	decoder := json.NewDecoder(request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&levels); err != nil {
+		writeError(writer, http.StatusBadRequest, "Invalid request body: %s", err)
+		return
+	}
+	changes := make([]func(), 0)
+	for _, level := range []struct {
+		destination string
+		name        string
+		set         func(int)
+	}{
+		{"stdout", levels.Stdout, func(verbosity int) { gplog.SetStreamVerbosity(gplog.STREAM_STDOUT, verbosity) }},
+		{"stderr", levels.Stderr, func(verbosity int) { gplog.SetStreamVerbosity(gplog.STREAM_STDERR, verbosity) }},
+		{"file", levels.File, gplog.SetLogFileVerbosity},
+	} {
+		if level.name == "" {
+			continue
+		}
+		verbosity, err := ParseLogLevel(level.name)
+		if err != nil {
+			writeError(writer, http.StatusBadRequest, "%s", err)
+			return
+		}
+		changes = append(changes, func() {
+			level.set(verbosity)
+			gplog.Info("Set %s log level to %s through the API", level.destination, LogLevelName(verbosity))
+		})
+	}
+	for _, change := range changes {
+		change()
+	}
+	writeResponse(writer, http.StatusOK, currentLogLevels())
+}
+
+type Server struct {
+	listener net.Listener
+	server   *http.Server
+}
+
+/*
+ * Start serves the API on options.Address, or on DEFAULT_ADDRESS if that is
+ * empty, until Close is called.
+ */
+func Start(options Options) (*Server, error) {
+	handler, err := NewHandler(options)
+	if err != nil {
+		return nil, err
+	}
+	address := options.Address
+	if address == "" {
+		address = DEFAULT_ADDRESS
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to start API server on %s", address)
+	}
+	server := &Server{listener: listener, server: &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}}
+	go func() {
+		_ = server.server.Serve(listener)
+	}()
+	return server, nil
+}
+
+// Addr returns the address the server is listening on, which is useful if it was started on port 0
+func (server *Server) Addr() string {
+	return server.listener.Addr().String()
+}
+
+func (server *Server) Close() error {
+	return server.server.Close()
+}
@@ -0,0 +1,184 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpapi_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/gpapi"
+	"github.com/apache/cloudberry-go-libs/gpbanner"
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("gpapi/server tests", func() {
+	var (
+		handler http.Handler
+		tracker *gpapi.OperationTracker
+		logfile *gbytes.Buffer
+		now     time.Time
+		elapsed time.Duration
+	)
+	request := func(method string, path string, token string, body string) (int, string) {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder.Code, recorder.Body.String()
+	}
+
+	BeforeEach(func() {
+		_, _, logfile = testhelper.SetupTestLogger()
+		now = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		testhelper.FreezeSystem(GinkgoT(), testhelper.FreezeOptions{Now: now})
+		operating.System.Now = func() time.Time { return now }
+		elapsed = 0
+		operating.System.MonotonicNow = func() time.Duration { return elapsed }
+		tracker = gpapi.NewOperationTracker()
+		var err error
+		handler, err = gpapi.NewHandler(gpapi.Options{
+			Token:  "secret",
+			Tool:   gpbanner.ToolInfo{Name: "gpagent", Version: "1.2.3", Commit: "abc123"},
+			Status: tracker,
+		})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	Describe("NewHandler", func() {
+		It("requires a token", func() {
+			_, err := gpapi.NewHandler(gpapi.Options{})
+			Expect(err).To(MatchError("An API token is required"))
+		})
+		It("reports health without a token", func() {
+			elapsed += 90 * time.Second
+			now = now.Add(-time.Hour)
+			code, body := request("GET", "/health", "", "")
+			Expect(code).To(Equal(http.StatusOK))
+			Expect(body).To(MatchJSON(`{"status": "ok", "uptime_seconds": 90}`))
+		})
+		It("rejects other requests without the right token", func() {
+			for _, token := range []string{"", "wrong", "secret2"} {
+				code, body := request("GET", "/version", token, "")
+				Expect(code).To(Equal(http.StatusUnauthorized))
+				Expect(body).To(MatchJSON(`{"error": "A valid API token is required"}`))
+			}
+			code, _ := request("PUT", "/loglevel", "wrong", `{"stdout": "debug"}`)
+			Expect(code).To(Equal(http.StatusUnauthorized))
+			Expect(gplog.GetVerbosity()).To(Equal(gplog.LOGINFO))
+		})
+		It("reports the tool version", func() {
+			code, body := request("GET", "/version", "secret", "")
+			Expect(code).To(Equal(http.StatusOK))
+			Expect(body).To(MatchJSON(`{"tool": "gpagent", "version": "1.2.3", "commit": "abc123"}`))
+		})
+		It("reports the current operation", func() {
+			code, body := request("GET", "/status", "secret", "")
+			Expect(code).To(Equal(http.StatusOK))
+			Expect(body).To(MatchJSON(`{"state": "idle", "completed": 0, "total": 0, "remaining_seconds": 0}`))
+
+			tracker.Begin("start segments")
+			_, body = request("GET", "/status", "secret", "")
+			Expect(body).To(MatchJSON(`{"operation": "start segments", "state": "running", "started_at": "2024-01-01T00:00:00Z", "completed": 0, "total": 0, "remaining_seconds": 0}`))
+		})
+		It("reports an idle agent if there is no status provider", func() {
+			handler, _ = gpapi.NewHandler(gpapi.Options{Token: "secret"})
+			_, body := request("GET", "/status", "secret", "")
+			Expect(body).To(MatchJSON(`{"state": "idle", "completed": 0, "total": 0, "remaining_seconds": 0}`))
+		})
+		It("reports and changes log levels", func() {
+			code, body := request("GET", "/loglevel", "secret", "")
+			Expect(code).To(Equal(http.StatusOK))
+			Expect(body).To(MatchJSON(`{"stdout": "info", "stderr": "error", "file": "debug"}`))
+
+			code, body = request("PUT", "/loglevel", "secret", `{"stdout": "Verbose", "file": "info"}`)
+			Expect(code).To(Equal(http.StatusOK))
+			Expect(body).To(MatchJSON(`{"stdout": "verbose", "stderr": "error", "file": "info"}`))
+			Expect(gplog.GetVerbosity()).To(Equal(gplog.LOGVERBOSE))
+			Expect(gplog.GetLogFileVerbosity()).To(Equal(gplog.LOGINFO))
+			Expect(logfile).To(gbytes.Say("Set stdout log level to verbose through the API"))
+			Expect(logfile).To(gbytes.Say("Set file log level to info through the API"))
+		})
+		It("changes no log levels if any requested level is invalid", func() {
+			code, body := request("PUT", "/loglevel", "secret", `{"stdout": "debug", "file": "loud"}`)
+			Expect(code).To(Equal(http.StatusBadRequest))
+			Expect(body).To(MatchJSON(`{"error": "Invalid log level \"loud\"; must be one of none, error, info, verbose, debug"}`))
+			Expect(gplog.GetVerbosity()).To(Equal(gplog.LOGINFO))
+
+			code, body = request("PUT", "/loglevel", "secret", `{"console": "debug"}`)
+			Expect(code).To(Equal(http.StatusBadRequest))
+			Expect(body).To(ContainSubstring(`Invalid request body: json: unknown field \"console\"`))
+		})
+		It("rejects methods an endpoint does not support", func() {
+			code, _ := request("POST", "/status", "secret", "")
+			Expect(code).To(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+	Describe("ParseLogLevel", func() {
+		It("converts between level names and verbosities", func() {
+			for _, name := range []string{"none", "error", "info", "verbose", "debug"} {
+				verbosity, err := gpapi.ParseLogLevel(name)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(gpapi.LogLevelName(verbosity)).To(Equal(name))
+			}
+			Expect(gpapi.ParseLogLevel("DEBUG")).To(Equal(gplog.LOGDEBUG))
+			Expect(gpapi.LogLevelName(7)).To(Equal("unknown"))
+		})
+	})
+	Describe("Start", func() {
+		testhelper.DetectLeaks(testhelper.LeakOptions{})
+
+		It("serves the API over HTTP until it is closed", func() {
+			server, err := gpapi.Start(gpapi.Options{Address: "127.0.0.1:0", Token: "secret", Tool: gpbanner.ToolInfo{Name: "gpagent", Version: "1.2.3"}})
+			Expect(err).ToNot(HaveOccurred())
+			defer server.Close()
+
+			client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+			request, err := http.NewRequest("GET", "http://"+server.Addr()+"/version", nil)
+			Expect(err).ToNot(HaveOccurred())
+			request.Header.Set("Authorization", "Bearer secret")
+			response, err := client.Do(request)
+			Expect(err).ToNot(HaveOccurred())
+			defer response.Body.Close()
+			body, err := io.ReadAll(response.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response.Header.Get("Content-Type")).To(Equal("application/json"))
+			var version map[string]string
+			Expect(json.Unmarshal(body, &version)).To(Succeed())
+			Expect(version).To(Equal(map[string]string{"tool": "gpagent", "version": "1.2.3"}))
+		})
+		It("returns an error if the address is in use", func() {
+			server, err := gpapi.Start(gpapi.Options{Address: "127.0.0.1:0", Token: "secret"})
+			Expect(err).ToNot(HaveOccurred())
+			defer server.Close()
+			_, err = gpapi.Start(gpapi.Options{Address: server.Addr(), Token: "secret"})
+			Expect(err).To(MatchError(ContainSubstring("Unable to start API server on " + server.Addr())))
+		})
+	})
+})
@@ -0,0 +1,168 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpapi
+
+/*
+ * This file contains structs for reporting the operation an agent is running
+ * through the API, and an OperationTracker that records it from the progress
+ * of cluster command batches and the status of remote jobs.
+ */
+
+import (
+	"sync"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/operating"
+)
+
+type OperationState string
+
+const (
+	OPERATION_IDLE      OperationState = "idle"
+	OPERATION_RUNNING   OperationState = "running"
+	OPERATION_SUCCEEDED OperationState = "succeeded"
+	OPERATION_FAILED    OperationState = "failed"
+)
+
+type JobStatus struct {
+	Name     string `json:"name"`
+	Host     string `json:"host"`
+	State    string `json:"state"`
+	PID      int    `json:"pid,omitempty"`
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+}
+
+/*
+ * OperationStatus describes the operation an agent is running, or the last
+ * one it ran.  Completed and Total count the commands of the most recent
+ * batch, and RemainingSeconds is its estimated time to finish.
+ */
+type OperationStatus struct {
+	Operation        string         `json:"operation,omitempty"`
+	State            OperationState `json:"state"`
+	StartedAt        *time.Time     `json:"started_at,omitempty"`
+	EndedAt          *time.Time     `json:"ended_at,omitempty"`
+	Completed        int            `json:"completed"`
+	Total            int            `json:"total"`
+	RemainingSeconds float64        `json:"remaining_seconds"`
+	Jobs             []JobStatus    `json:"jobs,omitempty"`
+	Error            string         `json:"error,omitempty"`
+}
+
+type StatusProvider interface {
+	OperationStatus() OperationStatus
+}
+
+/*
+ * An OperationTracker records the status of an agent's operations for the
+ * API.  It is safe to update from the goroutines running the operation while
+ * the API reads it.
+ */
+type OperationTracker struct {
+	mutex  sync.Mutex
+	status OperationStatus
+}
+
+var _ StatusProvider = &OperationTracker{}
+
+func NewOperationTracker() *OperationTracker {
+	return &OperationTracker{status: OperationStatus{State: OPERATION_IDLE}}
+}
+
+// Begin records the start of a new operation, clearing the status of the previous one
+func (tracker *OperationTracker) Begin(operation string) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	now := operating.System.Now()
+	tracker.status = OperationStatus{Operation: operation, State: OPERATION_RUNNING, StartedAt: &now}
+}
+
+// End records that the current operation has finished, failing if err is not nil
+func (tracker *OperationTracker) End(err error) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	now := operating.System.Now()
+	tracker.status.EndedAt = &now
+	tracker.status.RemainingSeconds = 0
+	if err != nil {
+		tracker.status.State = OPERATION_FAILED
+		tracker.status.Error = err.Error()
+	} else {
+		tracker.status.State = OPERATION_SUCCEEDED
+	}
+}
+
+func (tracker *OperationTracker) UpdateProgress(progress cluster.BatchProgress) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	tracker.status.Completed = progress.Completed
+	tracker.status.Total = progress.Total
+	tracker.status.RemainingSeconds = progress.Remaining.Seconds()
+}
+
+/*
+ * OnProgress returns a function to set as GPDBExecutor.OnProgress, which
+ * records each batch's progress and then calls next, if it is not nil, e.g.
+ *
+ *   executor.OnProgress = tracker.OnProgress(cluster.LogProgress("Starting segments", 10*time.Second))
+ */
+func (tracker *OperationTracker) OnProgress(next func(cluster.BatchProgress)) func(cluster.BatchProgress) {
+	return func(progress cluster.BatchProgress) {
+		tracker.UpdateProgress(progress)
+		if next != nil {
+			next(progress)
+		}
+	}
+}
+
+func jobStateName(state cluster.JobState) string {
+	switch state {
+	case cluster.JOB_RUNNING:
+		return "running"
+	case cluster.JOB_EXITED:
+		return "exited"
+	}
+	return "not running"
+}
+
+// UpdateJobs replaces the job statuses of the current operation, usually with the result of Cluster.PollRemoteJobs
+func (tracker *OperationTracker) UpdateJobs(statuses []cluster.JobStatus) {
+	jobs := make([]JobStatus, len(statuses))
+	for i, status := range statuses {
+		jobs[i] = JobStatus{State: jobStateName(status.State), PID: status.PID, ExitCode: status.ExitCode}
+		if status.Job != nil {
+			jobs[i].Name, jobs[i].Host = status.Job.Name, status.Job.Host
+		}
+		if status.Err != nil {
+			jobs[i].Error = status.Err.Error()
+		}
+	}
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	tracker.status.Jobs = jobs
+}
+
+func (tracker *OperationTracker) OperationStatus() OperationStatus {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	status := tracker.status
+	status.Jobs = append([]JobStatus(nil), tracker.status.Jobs...)
+	return status
+}
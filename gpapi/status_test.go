@@ -0,0 +1,91 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpapi_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/gpapi"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("gpapi/status tests", func() {
+	var (
+		tracker *gpapi.OperationTracker
+		now     time.Time
+	)
+	BeforeEach(func() {
+		now = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		testhelper.FreezeSystem(GinkgoT(), testhelper.FreezeOptions{Now: now})
+		operating.System.Now = func() time.Time { return now }
+		tracker = gpapi.NewOperationTracker()
+	})
+
+	It("starts idle", func() {
+		Expect(tracker.OperationStatus()).To(Equal(gpapi.OperationStatus{State: gpapi.OPERATION_IDLE}))
+	})
+	It("records the progress of an operation until it ends", func() {
+		tracker.Begin("start segments")
+		logged := make([]int, 0)
+		onProgress := tracker.OnProgress(func(progress cluster.BatchProgress) { logged = append(logged, progress.Completed) })
+		onProgress(cluster.BatchProgress{Completed: 2, Total: 8, Remaining: 30 * time.Second})
+
+		started := now
+		status := tracker.OperationStatus()
+		Expect(status).To(Equal(gpapi.OperationStatus{Operation: "start segments", State: gpapi.OPERATION_RUNNING, StartedAt: &started, Completed: 2, Total: 8, RemainingSeconds: 30}))
+		Expect(logged).To(Equal([]int{2}))
+
+		now = now.Add(time.Minute)
+		tracker.End(nil)
+		status = tracker.OperationStatus()
+		Expect(status.State).To(Equal(gpapi.OPERATION_SUCCEEDED))
+		Expect(*status.EndedAt).To(Equal(now))
+		Expect(status.RemainingSeconds).To(BeZero())
+	})
+	It("records failures and clears them when the next operation begins", func() {
+		tracker.Begin("start segments")
+		tracker.End(errors.New("segment 3 failed to start"))
+		Expect(tracker.OperationStatus().State).To(Equal(gpapi.OPERATION_FAILED))
+		Expect(tracker.OperationStatus().Error).To(Equal("segment 3 failed to start"))
+
+		tracker.Begin("stop segments")
+		Expect(tracker.OperationStatus().Error).To(BeEmpty())
+		Expect(tracker.OperationStatus().State).To(Equal(gpapi.OPERATION_RUNNING))
+	})
+	It("records the status of remote jobs", func() {
+		tracker.UpdateJobs([]cluster.JobStatus{
+			{Job: &cluster.RemoteJob{Name: "gpbackup_helper", Host: "sdw1"}, State: cluster.JOB_RUNNING, PID: 123, ExitCode: -1},
+			{Job: &cluster.RemoteJob{Name: "gpbackup_helper", Host: "sdw2"}, State: cluster.JOB_EXITED, ExitCode: 1},
+			{Job: &cluster.RemoteJob{Name: "gpbackup_helper", Host: "sdw3"}, ExitCode: -1, Err: errors.New("ssh failed")},
+		})
+		status := tracker.OperationStatus()
+		Expect(status.Jobs).To(Equal([]gpapi.JobStatus{
+			{Name: "gpbackup_helper", Host: "sdw1", State: "running", PID: 123, ExitCode: -1},
+			{Name: "gpbackup_helper", Host: "sdw2", State: "exited", ExitCode: 1},
+			{Name: "gpbackup_helper", Host: "sdw3", State: "not running", ExitCode: -1, Error: "ssh failed"},
+		}))
+		status.Jobs[0].State = "changed"
+		Expect(tracker.OperationStatus().Jobs[0].State).To(Equal("running"))
+	})
+})
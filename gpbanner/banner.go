@@ -0,0 +1,244 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpbanner
+
+/*
+ * This file contains functions for rendering the startup banner and the
+ * --version and --help output of Cloudberry utilities, so that every utility
+ * prints them the same way.
+ */
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/dbconn"
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+var (
+	Copyright = "Copyright 2024-2026 The Apache Software Foundation"
+	License   = "Licensed under the Apache License, Version 2.0"
+)
+
+type OutputMode int
+
+const (
+	TEXT  OutputMode = iota
+	QUIET            // Print nothing in the banner and only the version number for --version
+	JSON
+)
+
+/*
+ * ToolInfo describes a utility.  Version, Commit, and BuildDate are usually
+ * set at build time with -ldflags "-X ...".  If Version is not set, the
+ * version of the main module from the binary's build info is used instead,
+ * which is set for binaries built with "go install".
+ */
+type ToolInfo struct {
+	Name      string
+	Version   string
+	Commit    string
+	BuildDate string
+}
+
+func (info ToolInfo) GetVersion() string {
+	if info.Version != "" {
+		return info.Version
+	}
+	if buildInfo, ok := debug.ReadBuildInfo(); ok && buildInfo.Main.Version != "" && buildInfo.Main.Version != "(devel)" {
+		return strings.TrimPrefix(buildInfo.Main.Version, "v")
+	}
+	return "dev"
+}
+
+// VersionString returns the standard first line of --version output, e.g. "gpbackup version 1.2.3 (commit abc123)"
+func (info ToolInfo) VersionString() string {
+	details := make([]string, 0)
+	if info.Commit != "" {
+		details = append(details, "commit "+info.Commit)
+	}
+	if info.BuildDate != "" {
+		details = append(details, "built "+info.BuildDate)
+	}
+	versionStr := fmt.Sprintf("%s version %s", info.Name, info.GetVersion())
+	if len(details) > 0 {
+		versionStr += fmt.Sprintf(" (%s)", strings.Join(details, ", "))
+	}
+	return versionStr
+}
+
+type versionJSON struct {
+	Tool      string `json:"tool"`
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	Copyright string `json:"copyright"`
+	License   string `json:"license"`
+	Endpoint  string `json:"endpoint,omitempty"`
+}
+
+func (info ToolInfo) toJSON(endpoint string) versionJSON {
+	return versionJSON{
+		Tool:      info.Name,
+		Version:   info.GetVersion(),
+		Commit:    info.Commit,
+		BuildDate: info.BuildDate,
+		Copyright: Copyright,
+		License:   License,
+		Endpoint:  endpoint,
+	}
+}
+
+func writeJSON(writer io.Writer, value interface{}) error {
+	contents, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(writer, "%s\n", contents)
+	return err
+}
+
+/*
+ * PrintVersion writes the --version output for the utility.  In QUIET mode
+ * only the version number is written, for use in scripts.
+ */
+func PrintVersion(writer io.Writer, info ToolInfo, mode OutputMode) error {
+	var err error
+	switch mode {
+	case QUIET:
+		_, err = fmt.Fprintln(writer, info.GetVersion())
+	case JSON:
+		err = writeJSON(writer, info.toJSON(""))
+	default:
+		_, err = fmt.Fprintf(writer, "%s\n%s\n%s\n", info.VersionString(), Copyright, License)
+	}
+	return err
+}
+
+/*
+ * A Banner is printed when a utility starts.  Endpoint is optional and
+ * describes the cluster the utility is operating on; see EndpointFromConn.
+ */
+type Banner struct {
+	Tool     ToolInfo
+	Endpoint string
+	Mode     OutputMode
+}
+
+// EndpointFromConn returns the endpoint of a connection in the form user@host:port/dbname
+func EndpointFromConn(conn *dbconn.DBConn) string {
+	endpoint := fmt.Sprintf("%s:%d/%s", conn.Host, conn.Port, conn.DBName)
+	if conn.User != "" {
+		endpoint = conn.User + "@" + endpoint
+	}
+	return endpoint
+}
+
+// Lines returns the lines of the text banner, regardless of Mode
+func (banner Banner) Lines() []string {
+	lines := []string{banner.Tool.VersionString(), Copyright}
+	if banner.Endpoint != "" {
+		lines = append(lines, fmt.Sprintf("Cluster endpoint: %s", banner.Endpoint))
+	}
+	return lines
+}
+
+func (banner Banner) Render(writer io.Writer) error {
+	switch banner.Mode {
+	case QUIET:
+		return nil
+	case JSON:
+		return writeJSON(writer, banner.Tool.toJSON(banner.Endpoint))
+	}
+	_, err := fmt.Fprintf(writer, "%s\n", strings.Join(banner.Lines(), "\n"))
+	return err
+}
+
+// Log writes the text banner to the log file, and to stdout unless Mode is QUIET
+func (banner Banner) Log() {
+	for _, line := range banner.Lines() {
+		if banner.Mode == QUIET {
+			gplog.Verbose("%s", line)
+		} else {
+			gplog.Info("%s", line)
+		}
+	}
+}
+
+/*
+ * SetUsage sets the usage function of flags, which is called for --help and
+ * for invalid flags, to print the standard help output:
+ *
+ *   <name> - <description>
+ *
+ *   Usage:
+ *     <synopsis>
+ *     ...
+ *
+ *   Options:
+ *   <flag defaults>
+ *
+ * If no synopses are given, "<name> [options]" is used.
+ */
+func SetUsage(flags *flag.FlagSet, info ToolInfo, description string, synopses ...string) {
+	if len(synopses) == 0 {
+		synopses = []string{info.Name + " [options]"}
+	}
+	flags.Usage = func() {
+		output := flags.Output()
+		fmt.Fprintf(output, "%s - %s\n\nUsage:\n", info.Name, description)
+		for _, synopsis := range synopses {
+			fmt.Fprintf(output, "  %s\n", synopsis)
+		}
+		fmt.Fprintf(output, "\nOptions:\n")
+		flags.PrintDefaults()
+	}
+}
+
+/*
+ * AddVersionFlag adds a --version flag to flags that prints the version to
+ * stdout and exits.  "--version=json" prints it as JSON, and "--version=short"
+ * prints only the version number.
+ */
+func AddVersionFlag(flags *flag.FlagSet, info ToolInfo) {
+	flags.BoolFunc("version", "Print version information and exit (use --version=json for JSON output)", func(value string) error {
+		var mode OutputMode
+		switch value {
+		case "true":
+			mode = TEXT
+		case "short":
+			mode = QUIET
+		case "json":
+			mode = JSON
+		default:
+			return errors.New("must be one of true, short, or json")
+		}
+		if err := PrintVersion(operating.System.Stdout, info, mode); err != nil {
+			return err
+		}
+		operating.System.Exit(0)
+		return nil
+	})
+}
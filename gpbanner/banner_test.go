@@ -0,0 +1,160 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpbanner_test
+
+import (
+	"bytes"
+	"flag"
+	"io"
+
+	"github.com/apache/cloudberry-go-libs/dbconn"
+	"github.com/apache/cloudberry-go-libs/gpbanner"
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gbytes"
+)
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+var _ = Describe("gpbanner/banner tests", func() {
+	info := gpbanner.ToolInfo{Name: "gpbackup", Version: "1.2.3", Commit: "abc123", BuildDate: "2026-01-02"}
+
+	Describe("ToolInfo", func() {
+		It("formats the version with build details", func() {
+			Expect(info.VersionString()).To(Equal("gpbackup version 1.2.3 (commit abc123, built 2026-01-02)"))
+			Expect(gpbanner.ToolInfo{Name: "gpbackup", Version: "1.2.3"}.VersionString()).To(Equal("gpbackup version 1.2.3"))
+		})
+		It("falls back to a development version if none is set", func() {
+			Expect(gpbanner.ToolInfo{Name: "gpbackup"}.GetVersion()).To(Equal("dev"))
+		})
+	})
+	Describe("PrintVersion", func() {
+		var buffer *bytes.Buffer
+		BeforeEach(func() {
+			buffer = &bytes.Buffer{}
+		})
+		It("prints the version, copyright, and license", func() {
+			Expect(gpbanner.PrintVersion(buffer, info, gpbanner.TEXT)).To(Succeed())
+			Expect(buffer.String()).To(Equal("gpbackup version 1.2.3 (commit abc123, built 2026-01-02)\n" + gpbanner.Copyright + "\n" + gpbanner.License + "\n"))
+		})
+		It("prints only the version number in quiet mode", func() {
+			Expect(gpbanner.PrintVersion(buffer, info, gpbanner.QUIET)).To(Succeed())
+			Expect(buffer.String()).To(Equal("1.2.3\n"))
+		})
+		It("prints JSON", func() {
+			Expect(gpbanner.PrintVersion(buffer, info, gpbanner.JSON)).To(Succeed())
+			Expect(buffer.String()).To(MatchJSON(`{"tool": "gpbackup", "version": "1.2.3", "commit": "abc123", "build_date": "2026-01-02", "copyright": "` + gpbanner.Copyright + `", "license": "` + gpbanner.License + `"}`))
+		})
+	})
+	Describe("Banner", func() {
+		var buffer *bytes.Buffer
+		BeforeEach(func() {
+			buffer = &bytes.Buffer{}
+		})
+		It("renders the tool version, copyright, and cluster endpoint", func() {
+			conn := &dbconn.DBConn{User: "gpadmin", Host: "cdw", Port: 5432, DBName: "postgres"}
+			banner := gpbanner.Banner{Tool: info, Endpoint: gpbanner.EndpointFromConn(conn)}
+			Expect(banner.Render(buffer)).To(Succeed())
+			Expect(buffer.String()).To(Equal("gpbackup version 1.2.3 (commit abc123, built 2026-01-02)\n" + gpbanner.Copyright + "\nCluster endpoint: gpadmin@cdw:5432/postgres\n"))
+		})
+		It("renders nothing in quiet mode", func() {
+			Expect(gpbanner.Banner{Tool: info, Mode: gpbanner.QUIET}.Render(buffer)).To(Succeed())
+			Expect(buffer.Len()).To(Equal(0))
+		})
+		It("renders JSON including the endpoint", func() {
+			Expect(gpbanner.Banner{Tool: info, Endpoint: "cdw:5432/postgres", Mode: gpbanner.JSON}.Render(buffer)).To(Succeed())
+			Expect(buffer.String()).To(ContainSubstring(`"endpoint":"cdw:5432/postgres"`))
+		})
+		Describe("Log", func() {
+			var (
+				originalLogger *gplog.GpLogger
+				stdout         *Buffer
+				logfile        *Buffer
+			)
+			BeforeEach(func() {
+				originalLogger = gplog.GetLogger()
+				stdout, _, logfile = testhelper.SetupTestLogger()
+			})
+			AfterEach(func() {
+				gplog.SetLogger(originalLogger)
+			})
+			It("logs the banner to stdout and the log file", func() {
+				gpbanner.Banner{Tool: info}.Log()
+				Expect(stdout).To(Say("gpbackup version 1.2.3"))
+				Expect(logfile).To(Say("gpbackup version 1.2.3"))
+			})
+			It("logs the banner only to the log file in quiet mode", func() {
+				gpbanner.Banner{Tool: info, Mode: gpbanner.QUIET}.Log()
+				Expect(stdout).ToNot(Say("gpbackup version"))
+				Expect(logfile).To(Say("gpbackup version 1.2.3"))
+			})
+		})
+	})
+	Describe("SetUsage and AddVersionFlag", func() {
+		var (
+			flags    *flag.FlagSet
+			output   *bytes.Buffer
+			stdout   *bytes.Buffer
+			exitCode int
+		)
+		BeforeEach(func() {
+			output = &bytes.Buffer{}
+			stdout = &bytes.Buffer{}
+			exitCode = -1
+			flags = flag.NewFlagSet("gpbackup", flag.ContinueOnError)
+			flags.SetOutput(output)
+			flags.String("dbname", "", "The database to back up")
+			gpbanner.SetUsage(flags, info, "Back up a database")
+			gpbanner.AddVersionFlag(flags, info)
+			operating.System = operating.InitializeSystemFunctions()
+			operating.System.Stdout = nopCloser{stdout}
+			operating.System.Exit = func(code int) { exitCode = code }
+		})
+		AfterEach(func() {
+			operating.System = operating.InitializeSystemFunctions()
+		})
+		It("prints the standard help output", func() {
+			Expect(flags.Parse([]string{"--help"})).To(MatchError(flag.ErrHelp))
+			Expect(output.String()).To(HavePrefix("gpbackup - Back up a database\n\nUsage:\n  gpbackup [options]\n\nOptions:\n  -dbname string\n"))
+			Expect(output.String()).To(ContainSubstring("-version"))
+		})
+		It("prints the version and exits", func() {
+			Expect(flags.Parse([]string{"--version"})).To(Succeed())
+			Expect(stdout.String()).To(HavePrefix("gpbackup version 1.2.3"))
+			Expect(exitCode).To(Equal(0))
+		})
+		It("prints the version as JSON", func() {
+			Expect(flags.Parse([]string{"--version=json"})).To(Succeed())
+			Expect(stdout.String()).To(HavePrefix(`{"tool":"gpbackup"`))
+		})
+		It("rejects unknown version formats", func() {
+			Expect(flags.Parse([]string{"--version=xml"})).To(MatchError(ContainSubstring("must be one of true, short, or json")))
+			Expect(exitCode).To(Equal(-1))
+		})
+	})
+})
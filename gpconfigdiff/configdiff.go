@@ -0,0 +1,276 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpconfigdiff
+
+/*
+ * This file contains structs and functions for collecting the same files or
+ * command output from every host or segment in a cluster and reporting where
+ * they differ, for verifying that configuration such as postgresql.conf and
+ * pg_hba.conf is consistent across the cluster.
+ */
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+)
+
+/*
+ * A Source describes one piece of configuration to compare.  Generator is
+ * passed to Cluster.GenerateSSHCommandList with Scope, so it is either a
+ * func(content int) string for per-segment sources or a func(host string)
+ * string for per-host sources, and returns a command whose stdout is the
+ * configuration.  Normalize is applied to the output of each command before
+ * comparison; if it is nil, TrimLines is used.
+ */
+type Source struct {
+	Name      string
+	Scope     cluster.Scope
+	Generator interface{}
+	Normalize Normalizer
+}
+
+func quoteShellArg(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// HostFileSource compares the file at path on every host
+func HostFileSource(name string, path string, normalize Normalizer) Source {
+	return Source{
+		Name:      name,
+		Scope:     cluster.ON_HOSTS | cluster.INCLUDE_COORDINATOR,
+		Generator: func(_ string) string { return "cat " + quoteShellArg(path) },
+		Normalize: normalize,
+	}
+}
+
+// SegmentFileSource compares the file with the given name in the data directory of every primary segment
+func SegmentFileSource(c *cluster.Cluster, name string, filename string, normalize Normalizer) Source {
+	return Source{
+		Name:  name,
+		Scope: cluster.ON_SEGMENTS,
+		Generator: func(content int) string {
+			return "cat " + quoteShellArg(filepath.Join(c.GetDirForContent(content), filename))
+		},
+		Normalize: normalize,
+	}
+}
+
+/*
+ * PostgresqlConfSource compares postgresql.conf across primary segments,
+ * ignoring the settings that are expected to differ between segments as well
+ * as any in ignoreKeys.
+ */
+func PostgresqlConfSource(c *cluster.Cluster, ignoreKeys ...string) Source {
+	keys := append([]string{"port", "gp_contentid", "gp_dbid"}, ignoreKeys...)
+	return SegmentFileSource(c, "postgresql.conf", "postgresql.conf", IgnoreConfKeys(keys...))
+}
+
+// PgHbaConfSource compares pg_hba.conf across primary segments, in which order is significant
+func PgHbaConfSource(c *cluster.Cluster) Source {
+	return SegmentFileSource(c, "pg_hba.conf", "pg_hba.conf", Chain(StripComments, CollapseWhitespace))
+}
+
+/*
+ * A SourceResult holds the normalized output of a Source for each host or
+ * segment, named by hostname for per-host sources and "seg<content>" for
+ * per-segment sources.  The baseline is the output shared by the most hosts
+ * or segments, which is assumed to be correct; ties are broken in favor of
+ * the output seen first.
+ */
+type SourceResult struct {
+	Source        string
+	Names         []string
+	Outputs       map[string]string
+	Errors        map[string]error
+	Baseline      string
+	BaselineNames []string
+}
+
+func newSourceResult(source Source, output *cluster.RemoteOutput) SourceResult {
+	normalize := source.Normalize
+	if normalize == nil {
+		normalize = TrimLines
+	}
+	result := SourceResult{
+		Source:        source.Name,
+		Names:         make([]string, 0, len(output.Commands)),
+		Outputs:       make(map[string]string),
+		Errors:        make(map[string]error),
+		BaselineNames: make([]string, 0),
+	}
+	namesByOutput := make(map[string][]string)
+	outputOrder := make([]string, 0)
+	for _, command := range output.Commands {
+		name := command.Host
+		if source.Scope&cluster.ON_HOSTS == 0 {
+			name = fmt.Sprintf("seg%d", command.Content)
+		}
+		result.Names = append(result.Names, name)
+		if command.Error != nil {
+			result.Errors[name] = commandError(command)
+			continue
+		}
+		normalized := normalize(command.Stdout)
+		result.Outputs[name] = normalized
+		if _, ok := namesByOutput[normalized]; !ok {
+			outputOrder = append(outputOrder, normalized)
+		}
+		namesByOutput[normalized] = append(namesByOutput[normalized], name)
+	}
+	for _, candidate := range outputOrder {
+		if len(namesByOutput[candidate]) > len(result.BaselineNames) {
+			result.Baseline = candidate
+			result.BaselineNames = namesByOutput[candidate]
+		}
+	}
+	return result
+}
+
+func commandError(command cluster.ShellCommand) error {
+	stderr := strings.TrimSpace(command.Stderr)
+	if stderr == "" {
+		return command.Error
+	}
+	return fmt.Errorf("%v: %s", command.Error, stderr)
+}
+
+// Divergent returns the names whose output differs from the baseline, in command order
+func (result SourceResult) Divergent() []string {
+	divergent := make([]string, 0)
+	for _, name := range result.Names {
+		if output, ok := result.Outputs[name]; ok && output != result.Baseline {
+			divergent = append(divergent, name)
+		}
+	}
+	return divergent
+}
+
+// Diff returns a unified diff from the baseline to the output for name, or an empty string if they match
+func (result SourceResult) Diff(name string) string {
+	output, ok := result.Outputs[name]
+	if !ok || len(result.BaselineNames) == 0 {
+		return ""
+	}
+	fromLabel := fmt.Sprintf("%s@%s (baseline, %d of %d)", result.Source, result.BaselineNames[0], len(result.BaselineNames), len(result.Names))
+	toLabel := fmt.Sprintf("%s@%s", result.Source, name)
+	return UnifiedDiff(fromLabel, toLabel, result.Baseline, output)
+}
+
+type Report struct {
+	Results []SourceResult
+}
+
+/*
+ * Compare collects each source from the cluster and compares the output for
+ * each host or segment against the most common output.  The sources are
+ * collected one at a time, and the commands for each source run in parallel.
+ */
+func Compare(c *cluster.Cluster, sources ...Source) *Report {
+	report := &Report{Results: make([]SourceResult, 0, len(sources))}
+	for _, source := range sources {
+		commandList := c.GenerateSSHCommandList(source.Scope, source.Generator)
+		output := c.ExecuteClusterCommand(source.Scope, commandList)
+		report.Results = append(report.Results, newSourceResult(source, output))
+	}
+	return report
+}
+
+// Consistent returns true if every source was collected everywhere and matched everywhere
+func (report *Report) Consistent() bool {
+	for _, result := range report.Results {
+		if len(result.Errors) > 0 || len(result.Divergent()) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+ * Matrix returns a table with a row for each host or segment and a column for
+ * each source, marking each output as "ok" if it matches the baseline,
+ * "DIFFERS" if it does not, "ERROR" if it could not be collected, and "-" if
+ * the source does not apply to that host or segment.
+ */
+func (report *Report) Matrix() string {
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, result := range report.Results {
+		for _, name := range result.Names {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	var builder strings.Builder
+	writer := tabwriter.NewWriter(&builder, 0, 4, 2, ' ', 0)
+	header := []string{"NAME"}
+	for _, result := range report.Results {
+		header = append(header, result.Source)
+	}
+	fmt.Fprintln(writer, strings.Join(header, "\t"))
+	for _, name := range names {
+		row := []string{name}
+		for _, result := range report.Results {
+			row = append(row, result.status(name))
+		}
+		fmt.Fprintln(writer, strings.Join(row, "\t"))
+	}
+	_ = writer.Flush()
+	return builder.String()
+}
+
+func (result SourceResult) status(name string) string {
+	if _, ok := result.Errors[name]; ok {
+		return "ERROR"
+	}
+	output, ok := result.Outputs[name]
+	if !ok {
+		return "-"
+	}
+	if output != result.Baseline {
+		return "DIFFERS"
+	}
+	return "ok"
+}
+
+// String returns the matrix followed by the errors and diffs for each source
+func (report *Report) String() string {
+	var builder strings.Builder
+	builder.WriteString(report.Matrix())
+	for _, result := range report.Results {
+		errorNames := make([]string, 0, len(result.Errors))
+		for name := range result.Errors {
+			errorNames = append(errorNames, name)
+		}
+		sort.Strings(errorNames)
+		for _, name := range errorNames {
+			fmt.Fprintf(&builder, "\n%s@%s: %v\n", result.Source, name, result.Errors[name])
+		}
+		for _, name := range result.Divergent() {
+			fmt.Fprintf(&builder, "\n%s", result.Diff(name))
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,143 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpconfigdiff_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/gpconfigdiff"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("gpconfigdiff/configdiff tests", func() {
+	var (
+		testCluster *cluster.Cluster
+		dataDirs    []string
+	)
+	writeSegmentFile := func(content int, filename string, contents string) {
+		Expect(os.WriteFile(filepath.Join(dataDirs[content+1], filename), []byte(contents), 0644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		tempDir := GinkgoT().TempDir()
+		dataDirs = make([]string, 4)
+		segments := make([]cluster.SegConfig, 0)
+		for i := range dataDirs {
+			dataDirs[i] = filepath.Join(tempDir, fmt.Sprintf("gpseg%d", i-1))
+			Expect(os.Mkdir(dataDirs[i], 0755)).To(Succeed())
+			segments = append(segments, cluster.SegConfig{DbID: i + 1, ContentID: i - 1, Role: "p", Port: 6000 + i, Hostname: "localhost", DataDir: dataDirs[i]})
+		}
+		testCluster = cluster.NewCluster(segments)
+	})
+
+	Describe("Compare", func() {
+		It("reports a consistent cluster", func() {
+			for content := 0; content < 3; content++ {
+				writeSegmentFile(content, "postgresql.conf", fmt.Sprintf("port = %d\nwork_mem = 64MB\n", 6000+content))
+			}
+			report := gpconfigdiff.Compare(testCluster, gpconfigdiff.PostgresqlConfSource(testCluster))
+			Expect(report.Consistent()).To(BeTrue())
+			Expect(report.Results[0].Names).To(Equal([]string{"seg0", "seg1", "seg2"}))
+			Expect(report.Results[0].Divergent()).To(BeEmpty())
+		})
+		It("reports a segment whose configuration differs from the rest", func() {
+			writeSegmentFile(0, "postgresql.conf", "work_mem = 64MB\nfsync = on\n")
+			writeSegmentFile(1, "postgresql.conf", "work_mem = 64MB\nfsync = off\n")
+			writeSegmentFile(2, "postgresql.conf", "# tuned\nwork_mem=64MB\nfsync = on\n")
+			report := gpconfigdiff.Compare(testCluster, gpconfigdiff.PostgresqlConfSource(testCluster))
+
+			Expect(report.Consistent()).To(BeFalse())
+			result := report.Results[0]
+			Expect(result.BaselineNames).To(Equal([]string{"seg0", "seg2"}))
+			Expect(result.Divergent()).To(Equal([]string{"seg1"}))
+			Expect(result.Diff("seg1")).To(Equal(`--- postgresql.conf@seg0 (baseline, 2 of 3)
++++ postgresql.conf@seg1
+@@ -1,2 +1,2 @@
+ work_mem = 64MB
+-fsync = on
++fsync = off
+`))
+			Expect(result.Diff("seg2")).To(Equal(""))
+		})
+		It("reports segments whose configuration could not be collected", func() {
+			writeSegmentFile(0, "pg_hba.conf", "local all all trust\n")
+			writeSegmentFile(1, "pg_hba.conf", "local   all   all   trust\n")
+			report := gpconfigdiff.Compare(testCluster, gpconfigdiff.PgHbaConfSource(testCluster))
+
+			Expect(report.Consistent()).To(BeFalse())
+			result := report.Results[0]
+			Expect(result.Divergent()).To(BeEmpty())
+			Expect(result.Errors).To(HaveKey("seg2"))
+			Expect(result.Errors["seg2"].Error()).To(ContainSubstring("No such file or directory"))
+		})
+		It("compares per-host sources by hostname", func() {
+			hostFile := filepath.Join(dataDirs[0], "limits.conf")
+			Expect(os.WriteFile(hostFile, []byte("nofile 65536\n"), 0644)).To(Succeed())
+			report := gpconfigdiff.Compare(testCluster, gpconfigdiff.HostFileSource("limits.conf", hostFile, nil))
+			Expect(report.Consistent()).To(BeTrue())
+			Expect(report.Results[0].Names).To(Equal([]string{"localhost"}))
+			Expect(report.Results[0].Outputs["localhost"]).To(Equal("nofile 65536\n"))
+		})
+	})
+	Describe("Report", func() {
+		var report *gpconfigdiff.Report
+		BeforeEach(func() {
+			testCluster.Executor = &testhelper.TestExecutor{
+				ClusterOutputs: []*cluster.RemoteOutput{
+					cluster.NewRemoteOutput(cluster.ON_SEGMENTS, 1, []cluster.ShellCommand{
+						{Scope: cluster.ON_SEGMENTS, Content: 0, Stdout: "a = 1\n"},
+						{Scope: cluster.ON_SEGMENTS, Content: 1, Stdout: "a = 2\n"},
+						{Scope: cluster.ON_SEGMENTS, Content: 2, Stdout: "a = 1\n"},
+						{Scope: cluster.ON_SEGMENTS, Content: 3, Error: errors.New("exit status 1"), Stderr: "permission denied"},
+					}),
+					cluster.NewRemoteOutput(cluster.ON_HOSTS, 0, []cluster.ShellCommand{
+						{Scope: cluster.ON_HOSTS, Content: -2, Host: "sdw1", Stdout: "x\n"},
+					}),
+				},
+			}
+			report = gpconfigdiff.Compare(testCluster,
+				gpconfigdiff.Source{Name: "app.conf", Scope: cluster.ON_SEGMENTS, Generator: func(_ int) string { return "true" }},
+				gpconfigdiff.Source{Name: "limits", Scope: cluster.ON_HOSTS, Generator: func(_ string) string { return "true" }},
+			)
+		})
+		It("shows the status of every source for every host or segment", func() {
+			Expect(report.Matrix()).To(Equal(`NAME  app.conf  limits
+seg0  ok        -
+seg1  DIFFERS   -
+seg2  ok        -
+seg3  ERROR     -
+sdw1  -         ok
+`))
+		})
+		It("includes the errors and diffs after the matrix", func() {
+			output := report.String()
+			Expect(output).To(HavePrefix(report.Matrix()))
+			Expect(output).To(ContainSubstring("app.conf@seg3: exit status 1: permission denied\n"))
+			Expect(output).To(ContainSubstring("+++ app.conf@seg1\n@@ -1 +1 @@\n-a = 1\n+a = 2\n"))
+		})
+	})
+})
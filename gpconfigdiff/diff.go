@@ -0,0 +1,150 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpconfigdiff
+
+/*
+ * This file contains a minimal line-based implementation of unified diffs, for
+ * showing how a host's configuration differs from the rest of the cluster.
+ * Configuration files are small, so a quadratic longest-common-subsequence
+ * diff is sufficient.
+ */
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The number of unchanged lines shown around each change
+const diffContextLines = 3
+
+type diffOp struct {
+	kind byte // ' ', '-', or '+'
+	line string
+	// The number of lines of each input before this line
+	fromPos int
+	toPos   int
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+func diffLines(from []string, to []string) []diffOp {
+	// common[i][j] is the length of the longest common subsequence of from[i:] and to[j:]
+	common := make([][]int, len(from)+1)
+	for i := range common {
+		common[i] = make([]int, len(to)+1)
+	}
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			if from[i] == to[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, len(from)+len(to))
+	i, j := 0, 0
+	for i < len(from) || j < len(to) {
+		switch {
+		case i < len(from) && j < len(to) && from[i] == to[j]:
+			ops = append(ops, diffOp{' ', from[i], i, j})
+			i++
+			j++
+		case i < len(from) && (j == len(to) || common[i+1][j] >= common[i][j+1]):
+			ops = append(ops, diffOp{'-', from[i], i, j})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', to[j], i, j})
+			j++
+		}
+	}
+	return ops
+}
+
+/*
+ * UnifiedDiff returns a unified diff of the lines of from and to, labeled
+ * with fromLabel and toLabel, or an empty string if they are identical.
+ */
+func UnifiedDiff(fromLabel string, toLabel string, from string, to string) string {
+	ops := diffLines(splitLines(from), splitLines(to))
+	changes := make([]int, 0)
+	for index, op := range ops {
+		if op.kind != ' ' {
+			changes = append(changes, index)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "--- %s\n+++ %s\n", fromLabel, toLabel)
+	for first := 0; first < len(changes); {
+		// Extend the hunk while the next change is close enough that their context would overlap
+		last := first
+		for last+1 < len(changes) && changes[last+1]-changes[last] <= 2*diffContextLines {
+			last++
+		}
+		start := changes[first] - diffContextLines
+		if start < 0 {
+			start = 0
+		}
+		end := changes[last] + diffContextLines + 1
+		if end > len(ops) {
+			end = len(ops)
+		}
+		writeHunk(&builder, ops[start:end])
+		first = last + 1
+	}
+	return builder.String()
+}
+
+func writeHunk(builder *strings.Builder, ops []diffOp) {
+	fromLen, toLen := 0, 0
+	for _, op := range ops {
+		if op.kind != '+' {
+			fromLen++
+		}
+		if op.kind != '-' {
+			toLen++
+		}
+	}
+	fmt.Fprintf(builder, "@@ -%s +%s @@\n", hunkRange(ops[0].fromPos, fromLen), hunkRange(ops[0].toPos, toLen))
+	for _, op := range ops {
+		fmt.Fprintf(builder, "%c%s\n", op.kind, op.line)
+	}
+}
+
+// hunkRange formats a range as diff -u does, where an empty range is numbered by the line before it
+func hunkRange(start int, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if length == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
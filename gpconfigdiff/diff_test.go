@@ -0,0 +1,74 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpconfigdiff_test
+
+import (
+	"github.com/apache/cloudberry-go-libs/gpconfigdiff"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("gpconfigdiff/diff tests", func() {
+	Describe("UnifiedDiff", func() {
+		It("returns an empty string for identical input", func() {
+			Expect(gpconfigdiff.UnifiedDiff("a", "b", "x\ny\n", "x\ny\n")).To(Equal(""))
+		})
+		It("shows a changed line with surrounding context", func() {
+			from := "1\n2\n3\n4\n5\n6\n7\n8\n9\n"
+			to := "1\n2\n3\n4\nfive\n6\n7\n8\n9\n"
+			Expect(gpconfigdiff.UnifiedDiff("from", "to", from, to)).To(Equal(`--- from
++++ to
+@@ -2,7 +2,7 @@
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+`))
+		})
+		It("splits distant changes into separate hunks", func() {
+			from := "a\n1\n2\n3\n4\n5\n6\n7\n8\nb\n"
+			to := "A\n1\n2\n3\n4\n5\n6\n7\n8\nB\n"
+			Expect(gpconfigdiff.UnifiedDiff("from", "to", from, to)).To(Equal(`--- from
++++ to
+@@ -1,4 +1,4 @@
+-a
++A
+ 1
+ 2
+ 3
+@@ -7,4 +7,4 @@
+ 6
+ 7
+ 8
+-b
++B
+`))
+		})
+		It("numbers an insertion into an empty input as diff does", func() {
+			Expect(gpconfigdiff.UnifiedDiff("from", "to", "", "new\n")).To(Equal("--- from\n+++ to\n@@ -0,0 +1 @@\n+new\n"))
+		})
+		It("shows a removed line", func() {
+			Expect(gpconfigdiff.UnifiedDiff("from", "to", "a\nb\nc\n", "a\nc\n")).To(Equal("--- from\n+++ to\n@@ -1,3 +1,2 @@\n a\n-b\n c\n"))
+		})
+	})
+})
@@ -0,0 +1,139 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpconfigdiff
+
+/*
+ * This file contains functions for normalizing collected configuration before
+ * it is compared, so that differences in comments, whitespace, and settings
+ * that are expected to differ between hosts are not reported.
+ */
+
+import (
+	"sort"
+	"strings"
+)
+
+type Normalizer func(contents string) string
+
+// Chain returns a Normalizer that applies each of normalizers in order
+func Chain(normalizers ...Normalizer) Normalizer {
+	return func(contents string) string {
+		for _, normalize := range normalizers {
+			contents = normalize(contents)
+		}
+		return contents
+	}
+}
+
+func mapLines(contents string, transform func(line string) (string, bool)) string {
+	lines := make([]string, 0)
+	for _, line := range strings.Split(contents, "\n") {
+		if newLine, keep := transform(line); keep {
+			lines = append(lines, newLine)
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// TrimLines removes trailing whitespace from each line and removes blank lines, and is the default Normalizer
+func TrimLines(contents string) string {
+	return mapLines(contents, func(line string) (string, bool) {
+		line = strings.TrimRight(line, " \t\r")
+		return line, line != ""
+	})
+}
+
+// StripComments removes "#" comments outside of single quotes, surrounding whitespace, and blank lines
+func StripComments(contents string) string {
+	return mapLines(contents, func(line string) (string, bool) {
+		inQuotes := false
+		for i, char := range line {
+			if char == '\'' {
+				inQuotes = !inQuotes
+			} else if char == '#' && !inQuotes {
+				line = line[:i]
+				break
+			}
+		}
+		line = strings.TrimSpace(line)
+		return line, line != ""
+	})
+}
+
+// CollapseWhitespace replaces each run of whitespace within a line with a single space
+func CollapseWhitespace(contents string) string {
+	return mapLines(contents, func(line string) (string, bool) {
+		line = strings.Join(strings.Fields(line), " ")
+		return line, line != ""
+	})
+}
+
+// SortLines sorts the lines, for configuration where order is not significant
+func SortLines(contents string) string {
+	lines := splitLines(contents)
+	sort.Strings(lines)
+	return mapLines(strings.Join(lines, "\n"), func(line string) (string, bool) { return line, line != "" })
+}
+
+// parseConfLine splits a postgresql.conf line of the form "key = value" or "key value"
+func parseConfLine(line string) (string, string) {
+	separator := strings.IndexAny(line, "= \t")
+	if separator == -1 {
+		return strings.ToLower(line), ""
+	}
+	key := strings.ToLower(line[:separator])
+	value := strings.TrimLeft(line[separator:], " \t")
+	value = strings.TrimSpace(strings.TrimPrefix(value, "="))
+	return key, value
+}
+
+/*
+ * NormalizeConfFile strips comments from a file in postgresql.conf format and
+ * rewrites each setting as "key = value" with a lowercase key, since the
+ * server ignores both the case of keys and the spacing around "=".
+ */
+func NormalizeConfFile(contents string) string {
+	return mapLines(StripComments(contents), func(line string) (string, bool) {
+		if line == "" {
+			return line, false
+		}
+		key, value := parseConfLine(line)
+		return key + " = " + value, true
+	})
+}
+
+/*
+ * IgnoreConfKeys returns a Normalizer that applies NormalizeConfFile and then
+ * removes the given settings, for settings such as port that are expected to
+ * differ between segments.
+ */
+func IgnoreConfKeys(keys ...string) Normalizer {
+	ignored := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		ignored[strings.ToLower(key)] = true
+	}
+	return func(contents string) string {
+		return mapLines(NormalizeConfFile(contents), func(line string) (string, bool) {
+			key, _ := parseConfLine(line)
+			return line, line != "" && !ignored[key]
+		})
+	}
+}
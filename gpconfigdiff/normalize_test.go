@@ -0,0 +1,69 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpconfigdiff_test
+
+import (
+	"github.com/apache/cloudberry-go-libs/gpconfigdiff"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("gpconfigdiff/normalize tests", func() {
+	Describe("TrimLines", func() {
+		It("removes trailing whitespace and blank lines", func() {
+			Expect(gpconfigdiff.TrimLines("a  \n\n  b\t\r\n\n")).To(Equal("a\n  b\n"))
+		})
+		It("returns an empty string for blank input", func() {
+			Expect(gpconfigdiff.TrimLines("\n \n")).To(Equal(""))
+		})
+	})
+	Describe("StripComments", func() {
+		It("removes comments but not quoted hash signs", func() {
+			Expect(gpconfigdiff.StripComments("# header\nlog_line_prefix = '%m # %p' # comment\n  \n")).To(Equal("log_line_prefix = '%m # %p'\n"))
+		})
+	})
+	Describe("CollapseWhitespace", func() {
+		It("collapses runs of whitespace within lines", func() {
+			Expect(gpconfigdiff.CollapseWhitespace("host   all\tall   10.0.0.0/8   trust\n")).To(Equal("host all all 10.0.0.0/8 trust\n"))
+		})
+	})
+	Describe("SortLines", func() {
+		It("sorts the lines", func() {
+			Expect(gpconfigdiff.SortLines("b\na\nc\n")).To(Equal("a\nb\nc\n"))
+		})
+	})
+	Describe("NormalizeConfFile", func() {
+		It("rewrites settings in a canonical form", func() {
+			contents := "Shared_Buffers=128MB\nwork_mem   64MB\n#fsync = off\nmax_connections = 100 # default\n"
+			Expect(gpconfigdiff.NormalizeConfFile(contents)).To(Equal("shared_buffers = 128MB\nwork_mem = 64MB\nmax_connections = 100\n"))
+		})
+	})
+	Describe("IgnoreConfKeys", func() {
+		It("removes the given settings regardless of case", func() {
+			normalize := gpconfigdiff.IgnoreConfKeys("PORT")
+			Expect(normalize("port=6000\nwork_mem = 64MB\n")).To(Equal("work_mem = 64MB\n"))
+		})
+	})
+	Describe("Chain", func() {
+		It("applies the normalizers in order", func() {
+			normalize := gpconfigdiff.Chain(gpconfigdiff.StripComments, gpconfigdiff.SortLines)
+			Expect(normalize("b # two\na # one\n")).To(Equal("a\nb\n"))
+		})
+	})
+})
@@ -0,0 +1,131 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpdiag
+
+/*
+ * This file contains checks of the common dependencies of a utility, to be
+ * registered by each utility that has them.
+ */
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/dbconn"
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+)
+
+// LogFileCheck verifies that the gplog log file can be opened for writing, warning if there is no log file
+func LogFileCheck() Check {
+	return Check{
+		Name:        "log file",
+		Description: "The log file is writable",
+		Run: func() Result {
+			path := gplog.GetLogFilePath()
+			if path == "" {
+				return Warn("Logging to a file is not set up")
+			}
+			file, err := operating.System.OpenFileWrite(path, os.O_WRONLY|os.O_APPEND, 0)
+			if err != nil {
+				return Fail("Cannot write to log file %s: %v", path, err)
+			}
+			_ = file.Close()
+			return Pass("Log file %s is writable", path)
+		},
+	}
+}
+
+/*
+ * DatabaseCheck verifies that a statement can be run on conn.  If conn is not
+ * connected, the check connects it for the duration of the check and closes it
+ * again afterward.
+ */
+func DatabaseCheck(conn *dbconn.DBConn) Check {
+	return Check{
+		Name:        "database connection",
+		Description: "The database accepts connections",
+		Run: func() Result {
+			if len(conn.ConnPool) == 0 {
+				if err := conn.Connect(1); err != nil {
+					return Fail("%v", err)
+				}
+				defer conn.Close()
+			}
+			if _, err := conn.Exec("SELECT 1"); err != nil {
+				return Fail("Cannot run statements on database %s: %v", conn.DBName, err)
+			}
+			return Pass("Connected to database %s on %s:%d", conn.DBName, conn.Host, conn.Port)
+		},
+	}
+}
+
+// HostsCheck verifies that a command can be run on every host in the cluster over SSH
+func HostsCheck(c *cluster.Cluster) Check {
+	reachable := cluster.HealthCheck{
+		Name:    "ssh",
+		Command: func(_ string) string { return "true" },
+		Evaluate: func(result cluster.ShellCommand) error {
+			if result.Error != nil && strings.TrimSpace(result.Stderr) != "" {
+				return fmt.Errorf("%v: %s", result.Error, strings.TrimSpace(result.Stderr))
+			}
+			return result.Error
+		},
+	}
+	return Check{
+		Name:        "ssh to hosts",
+		Description: "Commands can be run on every host in the cluster",
+		Run: func() Result {
+			failures := c.CheckHosts(reachable).Failures()
+			if len(failures) == 0 {
+				return Pass("Ran a command on all %d hosts", len(c.Hostnames))
+			}
+			problems := make([]string, len(failures))
+			for i, failure := range failures {
+				problems[i] = fmt.Sprintf("%s (%v)", failure.Host, failure.Err)
+			}
+			return Fail("Cannot run commands on %d of %d hosts: %s", len(failures), len(c.Hostnames), strings.Join(problems, ", "))
+		},
+	}
+}
+
+/*
+ * DiskSpaceCheck verifies that the filesystem containing path has space
+ * available to unprivileged users, warning if less than warnBytes is
+ * available and failing if less than failBytes is.
+ */
+func DiskSpaceCheck(path string, warnBytes uint64, failBytes uint64) Check {
+	return Check{
+		Name:        fmt.Sprintf("disk space at %s", path),
+		Description: fmt.Sprintf("There is enough free space on the filesystem containing %s", path),
+		Run: func() Result {
+			usage, err := operating.System.DiskUsage(path)
+			if err != nil {
+				return Fail("%v", err)
+			}
+			if usage.AvailableBytes < failBytes {
+				return Fail("%d bytes available at %s, need at least %d", usage.AvailableBytes, path, failBytes)
+			} else if usage.AvailableBytes < warnBytes {
+				return Warn("%d bytes available at %s, fewer than the recommended %d", usage.AvailableBytes, path, warnBytes)
+			}
+			return Pass("%d bytes available at %s", usage.AvailableBytes, path)
+		},
+	}
+}
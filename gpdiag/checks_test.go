@@ -0,0 +1,167 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpdiag_test
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"path/filepath"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/gpdiag"
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("gpdiag/checks tests", func() {
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("LogFileCheck", func() {
+		var savedLogger *gplog.GpLogger
+		BeforeEach(func() {
+			savedLogger = gplog.GetLogger()
+		})
+		AfterEach(func() {
+			gplog.SetLogger(savedLogger)
+		})
+		useLogFile := func(name string) {
+			buffer := gbytes.NewBuffer()
+			gplog.SetLogger(gplog.NewLogger(buffer, buffer, buffer, name, gplog.LOGINFO, "testProgram"))
+		}
+
+		It("passes if the log file is writable", func() {
+			logFile := filepath.Join(GinkgoT().TempDir(), "test.log")
+			Expect(os.WriteFile(logFile, []byte("existing\n"), 0644)).To(Succeed())
+			useLogFile(logFile)
+			result := gpdiag.LogFileCheck().Run()
+			Expect(result).To(Equal(gpdiag.Pass("Log file %s is writable", logFile)))
+			Expect(os.ReadFile(logFile)).To(Equal([]byte("existing\n")))
+		})
+		It("fails if the log file cannot be opened", func() {
+			logFile := filepath.Join(GinkgoT().TempDir(), "missing", "test.log")
+			useLogFile(logFile)
+			result := gpdiag.LogFileCheck().Run()
+			Expect(result.Status).To(Equal(gpdiag.FAIL))
+			Expect(result.Message).To(HavePrefix("Cannot write to log file " + logFile))
+		})
+		It("warns if there is no log file", func() {
+			useLogFile("")
+			Expect(gpdiag.LogFileCheck().Run()).To(Equal(gpdiag.Warn("Logging to a file is not set up")))
+		})
+	})
+	Describe("DatabaseCheck", func() {
+		It("runs a statement on an existing connection", func() {
+			connection, mock := testhelper.CreateAndConnectMockDB(1)
+			mock.ExpectExec("SELECT 1").WillReturnResult(sqlmock.NewResult(0, 0))
+			result := gpdiag.DatabaseCheck(connection).Run()
+			Expect(result).To(Equal(gpdiag.Pass("Connected to database testdb on testhost:5432")))
+			Expect(connection.ConnPool).To(HaveLen(1))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("connects and disconnects if not already connected", func() {
+			connection, mock := testhelper.CreateMockDBConn()
+			testhelper.ExpectVersionQuery(mock, "6.0.0")
+			mock.ExpectExec("SELECT 1").WillReturnResult(sqlmock.NewResult(0, 0))
+			result := gpdiag.DatabaseCheck(connection).Run()
+			Expect(result.Status).To(Equal(gpdiag.PASS))
+			Expect(connection.ConnPool).To(BeNil())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("fails if the connection fails", func() {
+			connection, _ := testhelper.CreateMockDBConn(errors.New("connection refused"))
+			result := gpdiag.DatabaseCheck(connection).Run()
+			Expect(result.Status).To(Equal(gpdiag.FAIL))
+			Expect(result.Message).To(ContainSubstring("Connection refused"))
+		})
+		It("fails if the statement fails", func() {
+			connection, mock := testhelper.CreateAndConnectMockDB(1)
+			mock.ExpectExec("SELECT 1").WillReturnError(errors.New("server closed the connection"))
+			result := gpdiag.DatabaseCheck(connection).Run()
+			Expect(result).To(Equal(gpdiag.Fail("Cannot run statements on database testdb: server closed the connection")))
+		})
+	})
+	Describe("HostsCheck", func() {
+		coordinatorSeg := cluster.SegConfig{DbID: 1, ContentID: -1, Port: 5432, Hostname: "localhost", DataDir: "/data/gpseg-1", Role: "p"}
+		remoteSegOne := cluster.SegConfig{DbID: 2, ContentID: 0, Port: 20000, Hostname: "remotehost1", DataDir: "/data/gpseg0", Role: "p"}
+		remoteSegTwo := cluster.SegConfig{DbID: 3, ContentID: 1, Port: 20001, Hostname: "remotehost2", DataDir: "/data/gpseg1", Role: "p"}
+		var testCluster *cluster.Cluster
+		var testExecutor *testhelper.TestExecutor
+
+		BeforeEach(func() {
+			operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "testUser", HomeDir: "testDir"}, nil }
+			testExecutor = &testhelper.TestExecutor{}
+			testCluster = cluster.NewCluster([]cluster.SegConfig{coordinatorSeg, remoteSegOne, remoteSegTwo})
+			testCluster.Executor = testExecutor
+		})
+
+		It("passes if a command runs on every host", func() {
+			testExecutor.ClusterOutput = cluster.NewRemoteOutput(cluster.ON_HOSTS, 0, []cluster.ShellCommand{
+				{Host: "localhost"}, {Host: "remotehost1"}, {Host: "remotehost2"},
+			})
+			result := gpdiag.HostsCheck(testCluster).Run()
+			Expect(result).To(Equal(gpdiag.Pass("Ran a command on all 3 hosts")))
+			Expect(testExecutor.ClusterCommands[0][1].CommandString).To(Equal("ssh -o StrictHostKeyChecking=no remotehost1 true"))
+		})
+		It("lists the hosts that cannot be reached", func() {
+			testExecutor.ClusterOutput = cluster.NewRemoteOutput(cluster.ON_HOSTS, 2, []cluster.ShellCommand{
+				{Host: "localhost"},
+				{Host: "remotehost1", Error: errors.New("exit status 255"), Stderr: "Connection refused\n"},
+				{Host: "remotehost2", Error: errors.New("exit status 255")},
+			})
+			result := gpdiag.HostsCheck(testCluster).Run()
+			Expect(result).To(Equal(gpdiag.Fail("Cannot run commands on 2 of 3 hosts: remotehost1 (exit status 255: Connection refused), remotehost2 (exit status 255)")))
+		})
+	})
+	Describe("DiskSpaceCheck", func() {
+		useAvailable := func(available uint64) {
+			operating.System.DiskUsage = func(path string) (operating.FilesystemUsage, error) {
+				return operating.FilesystemUsage{TotalBytes: 10000, AvailableBytes: available}, nil
+			}
+		}
+
+		It("passes if enough space is available", func() {
+			useAvailable(5000)
+			check := gpdiag.DiskSpaceCheck("/data", 1000, 100)
+			Expect(check.Name).To(Equal("disk space at /data"))
+			Expect(check.Run()).To(Equal(gpdiag.Pass("5000 bytes available at /data")))
+		})
+		It("warns if less than the recommended space is available", func() {
+			useAvailable(500)
+			Expect(gpdiag.DiskSpaceCheck("/data", 1000, 100).Run()).To(Equal(gpdiag.Warn("500 bytes available at /data, fewer than the recommended 1000")))
+		})
+		It("fails if less than the required space is available", func() {
+			useAvailable(50)
+			Expect(gpdiag.DiskSpaceCheck("/data", 1000, 100).Run()).To(Equal(gpdiag.Fail("50 bytes available at /data, need at least 100")))
+		})
+		It("fails if disk usage cannot be read", func() {
+			operating.System.DiskUsage = func(path string) (operating.FilesystemUsage, error) {
+				return operating.FilesystemUsage{}, errors.New("Unable to get disk usage for /data: no such file or directory")
+			}
+			Expect(gpdiag.DiskSpaceCheck("/data", 1000, 100).Run()).To(Equal(gpdiag.Fail("Unable to get disk usage for /data: no such file or directory")))
+		})
+	})
+})
@@ -0,0 +1,294 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpdiag
+
+/*
+ * This file contains a registry of named self-checks and a runner that runs
+ * them and reports the results, so that any utility can offer a "doctor"
+ * command that checks its environment by registering the checks it cares
+ * about and printing the report.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+)
+
+type Status string
+
+const (
+	PASS Status = "pass"
+	WARN Status = "warn"
+	FAIL Status = "fail"
+)
+
+// severity orders statuses so that a report's status is that of its worst result
+func (status Status) severity() int {
+	switch status {
+	case PASS:
+		return 0
+	case WARN:
+		return 1
+	}
+	return 2
+}
+
+// A Result is the outcome of running one check
+type Result struct {
+	Status  Status
+	Message string
+}
+
+func Pass(format string, args ...interface{}) Result {
+	return Result{Status: PASS, Message: fmt.Sprintf(format, args...)}
+}
+
+func Warn(format string, args ...interface{}) Result {
+	return Result{Status: WARN, Message: fmt.Sprintf(format, args...)}
+}
+
+func Fail(format string, args ...interface{}) Result {
+	return Result{Status: FAIL, Message: fmt.Sprintf(format, args...)}
+}
+
+/*
+ * A Check is a named self-check.  Name identifies the check, both in the
+ * report and when choosing which checks to run, and Description says what it
+ * checks for a person reading the report.  Run performs the check; it should
+ * return a Fail result describing the problem rather than panicking, but a
+ * panic is reported as a failure of that check alone.
+ */
+type Check struct {
+	Name        string
+	Description string
+	Run         func() Result
+}
+
+/*
+ * A Registry holds checks in the order they were registered, which is the
+ * order in which they are run.  Most utilities use the package-level
+ * functions, which act on DefaultRegistry.
+ */
+type Registry struct {
+	checks []Check
+	mutex  sync.Mutex
+}
+
+var DefaultRegistry = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{checks: make([]Check, 0)}
+}
+
+// Register adds checks to the registry, panicking if a check has no name or the same name as one already registered
+func (registry *Registry) Register(checks ...Check) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	for _, check := range checks {
+		if check.Name == "" || check.Run == nil {
+			panic("A check must have a name and a Run function")
+		}
+		if _, ok := registry.find(check.Name); ok {
+			panic(fmt.Sprintf("A check named %q is already registered", check.Name))
+		}
+		registry.checks = append(registry.checks, check)
+	}
+}
+
+// Checks returns the registered checks in the order they are run
+func (registry *Registry) Checks() []Check {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	return append([]Check{}, registry.checks...)
+}
+
+func (registry *Registry) find(name string) (Check, bool) {
+	for _, check := range registry.checks {
+		if check.Name == name {
+			return check, true
+		}
+	}
+	return Check{}, false
+}
+
+/*
+ * Run runs the checks with the given names, or every registered check if no
+ * names are given, one at a time in registration order, and returns a report
+ * of the results.  A name that matches no registered check is reported as a
+ * failed check, so that a typo on the command line is not mistaken for a
+ * clean bill of health.
+ */
+func (registry *Registry) Run(names ...string) *Report {
+	registry.mutex.Lock()
+	checks := make([]Check, 0, len(registry.checks))
+	if len(names) == 0 {
+		checks = append(checks, registry.checks...)
+	}
+	for _, name := range names {
+		check, ok := registry.find(name)
+		if !ok {
+			check = Check{Name: name, Run: func() Result {
+				return Fail("No check named %q is registered", name)
+			}}
+		}
+		checks = append(checks, check)
+	}
+	registry.mutex.Unlock()
+
+	report := &Report{StartTime: operating.System.Now(), Results: make([]CheckResult, 0, len(checks))}
+	for _, check := range checks {
+		report.Results = append(report.Results, runCheck(check))
+	}
+	report.EndTime = operating.System.Now()
+	return report
+}
+
+func runCheck(check Check) (checkResult CheckResult) {
+	gplog.Verbose("Running check %s", check.Name)
+	start := operating.System.MonotonicNow()
+	checkResult = CheckResult{Name: check.Name, Description: check.Description}
+	defer func() {
+		if r := recover(); r != nil {
+			checkResult.Status, checkResult.Message = FAIL, fmt.Sprintf("Check panicked: %v", r)
+		}
+		checkResult.Duration = operating.Since(start)
+		gplog.Verbose("Check %s: %s: %s", check.Name, strings.ToUpper(string(checkResult.Status)), checkResult.Message)
+	}()
+	result := check.Run()
+	checkResult.Status, checkResult.Message = result.Status, result.Message
+	if checkResult.Status != PASS && checkResult.Status != WARN {
+		checkResult.Status = FAIL
+	}
+	return checkResult
+}
+
+// Register adds checks to DefaultRegistry
+func Register(checks ...Check) {
+	DefaultRegistry.Register(checks...)
+}
+
+// Run runs checks in DefaultRegistry
+func Run(names ...string) *Report {
+	return DefaultRegistry.Run(names...)
+}
+
+type CheckResult struct {
+	Name        string
+	Description string
+	Status      Status
+	Message     string
+	Duration    time.Duration
+}
+
+type Report struct {
+	StartTime time.Time
+	EndTime   time.Time
+	Results   []CheckResult
+}
+
+// Status returns the status of the worst result in the report, or PASS if it is empty
+func (report *Report) Status() Status {
+	status := PASS
+	for _, result := range report.Results {
+		if result.Status.severity() > status.severity() {
+			status = result.Status
+		}
+	}
+	return status
+}
+
+// Count returns the number of results with the given status
+func (report *Report) Count(status Status) int {
+	count := 0
+	for _, result := range report.Results {
+		if result.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+/*
+ * ExitCode returns the conventional exit status for a tool that ran the
+ * checks: 0 if every check passed, 1 if any warned, and 2 if any failed.
+ */
+func (report *Report) ExitCode() int {
+	return report.Status().severity()
+}
+
+// Text renders the report as a table for a person to read, followed by a line of totals
+func (report *Report) Text() string {
+	var builder strings.Builder
+	writer := tabwriter.NewWriter(&builder, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "STATUS\tCHECK\tMESSAGE")
+	for _, result := range report.Results {
+		message := strings.Join(strings.Fields(result.Message), " ")
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", strings.ToUpper(string(result.Status)), result.Name, message)
+	}
+	_ = writer.Flush()
+	fmt.Fprintf(&builder, "\n%d passed, %d warnings, %d failed\n", report.Count(PASS), report.Count(WARN), report.Count(FAIL))
+	return builder.String()
+}
+
+type jsonCheckResult struct {
+	Name            string  `json:"name"`
+	Description     string  `json:"description,omitempty"`
+	Status          Status  `json:"status"`
+	Message         string  `json:"message"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+type jsonReport struct {
+	Status    Status            `json:"status"`
+	StartTime time.Time         `json:"start_time"`
+	EndTime   time.Time         `json:"end_time"`
+	Passed    int               `json:"passed"`
+	Warnings  int               `json:"warnings"`
+	Failed    int               `json:"failed"`
+	Checks    []jsonCheckResult `json:"checks"`
+}
+
+// JSON renders the report for a program to read, with durations given in seconds
+func (report *Report) JSON() ([]byte, error) {
+	output := jsonReport{
+		Status:    report.Status(),
+		StartTime: report.StartTime,
+		EndTime:   report.EndTime,
+		Passed:    report.Count(PASS),
+		Warnings:  report.Count(WARN),
+		Failed:    report.Count(FAIL),
+		Checks:    make([]jsonCheckResult, len(report.Results)),
+	}
+	for i, result := range report.Results {
+		output.Checks[i] = jsonCheckResult{
+			Name:            result.Name,
+			Description:     result.Description,
+			Status:          result.Status,
+			Message:         result.Message,
+			DurationSeconds: result.Duration.Seconds(),
+		}
+	}
+	return json.MarshalIndent(output, "", "  ")
+}
@@ -0,0 +1,153 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpdiag_test
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/gpdiag"
+	"github.com/apache/cloudberry-go-libs/operating"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("gpdiag tests", func() {
+	var registry *gpdiag.Registry
+	passing := gpdiag.Check{Name: "passing", Description: "Always passes", Run: func() gpdiag.Result { return gpdiag.Pass("All good") }}
+	warning := gpdiag.Check{Name: "warning", Run: func() gpdiag.Result { return gpdiag.Warn("Only %d left", 3) }}
+	failing := gpdiag.Check{Name: "failing", Run: func() gpdiag.Result { return gpdiag.Fail("Broken") }}
+
+	BeforeEach(func() {
+		registry = gpdiag.NewRegistry()
+		clock := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		operating.System.Now = func() time.Time {
+			clock = clock.Add(time.Second)
+			return clock
+		}
+		var elapsed time.Duration
+		operating.System.MonotonicNow = func() time.Duration {
+			elapsed += time.Second
+			return elapsed
+		}
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("Register", func() {
+		It("keeps checks in registration order", func() {
+			registry.Register(warning, passing)
+			registry.Register(failing)
+			names := make([]string, 0)
+			for _, check := range registry.Checks() {
+				names = append(names, check.Name)
+			}
+			Expect(names).To(Equal([]string{"warning", "passing", "failing"}))
+		})
+		It("panics if a check with the same name is already registered", func() {
+			registry.Register(passing)
+			Expect(func() { registry.Register(passing) }).To(PanicWith(`A check named "passing" is already registered`))
+		})
+		It("panics if a check has no name or Run function", func() {
+			Expect(func() { registry.Register(gpdiag.Check{Run: passing.Run}) }).To(Panic())
+			Expect(func() { registry.Register(gpdiag.Check{Name: "empty"}) }).To(Panic())
+		})
+	})
+	Describe("Run", func() {
+		It("runs every registered check and reports the worst status", func() {
+			registry.Register(passing, warning, failing)
+			report := registry.Run()
+			Expect(report.Results).To(HaveLen(3))
+			Expect(report.Results[0]).To(Equal(gpdiag.CheckResult{Name: "passing", Description: "Always passes", Status: gpdiag.PASS, Message: "All good", Duration: time.Second}))
+			Expect(report.Results[1].Message).To(Equal("Only 3 left"))
+			Expect(report.Status()).To(Equal(gpdiag.FAIL))
+			Expect(report.ExitCode()).To(Equal(2))
+		})
+		It("measures check durations with the monotonic clock", func() {
+			operating.System.Now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+			stepped := gpdiag.Check{Name: "stepped", Run: func() gpdiag.Result {
+				operating.System.Now = func() time.Time { return time.Date(2024, 1, 2, 2, 4, 5, 0, time.UTC) }
+				return gpdiag.Pass("Clock stepped back")
+			}}
+			registry.Register(stepped)
+			report := registry.Run()
+			Expect(report.Results[0].Duration).To(Equal(time.Second))
+		})
+		It("runs only the named checks, in the order given", func() {
+			registry.Register(passing, warning, failing)
+			report := registry.Run("warning", "passing")
+			Expect(report.Results).To(HaveLen(2))
+			Expect(report.Results[0].Name).To(Equal("warning"))
+			Expect(report.Status()).To(Equal(gpdiag.WARN))
+			Expect(report.ExitCode()).To(Equal(1))
+		})
+		It("fails a name that matches no check", func() {
+			registry.Register(passing)
+			report := registry.Run("pasing")
+			Expect(report.Results).To(HaveLen(1))
+			Expect(report.Results[0].Status).To(Equal(gpdiag.FAIL))
+			Expect(report.Results[0].Message).To(Equal(`No check named "pasing" is registered`))
+		})
+		It("reports a panicking check as failed and runs the rest", func() {
+			registry.Register(gpdiag.Check{Name: "panicking", Run: func() gpdiag.Result { panic("oops") }}, passing)
+			report := registry.Run()
+			Expect(report.Results[0].Status).To(Equal(gpdiag.FAIL))
+			Expect(report.Results[0].Message).To(Equal("Check panicked: oops"))
+			Expect(report.Results[1].Status).To(Equal(gpdiag.PASS))
+		})
+		It("treats an unknown status as a failure", func() {
+			registry.Register(gpdiag.Check{Name: "empty", Run: func() gpdiag.Result { return gpdiag.Result{} }})
+			Expect(registry.Run().Status()).To(Equal(gpdiag.FAIL))
+		})
+		It("passes when there are no checks", func() {
+			report := registry.Run()
+			Expect(report.Status()).To(Equal(gpdiag.PASS))
+			Expect(report.ExitCode()).To(Equal(0))
+		})
+	})
+	Describe("Report", func() {
+		It("renders a table with totals", func() {
+			registry.Register(passing, warning, failing)
+			Expect(registry.Run().Text()).To(Equal(`STATUS  CHECK    MESSAGE
+PASS    passing  All good
+WARN    warning  Only 3 left
+FAIL    failing  Broken
+
+1 passed, 1 warnings, 1 failed
+`))
+		})
+		It("renders JSON", func() {
+			registry.Register(passing, failing)
+			contents, err := registry.Run().JSON()
+			Expect(err).ToNot(HaveOccurred())
+			var output map[string]interface{}
+			Expect(json.Unmarshal(contents, &output)).To(Succeed())
+			Expect(output["status"]).To(Equal("fail"))
+			Expect(output["passed"]).To(Equal(1.0))
+			Expect(output["warnings"]).To(Equal(0.0))
+			Expect(output["failed"]).To(Equal(1.0))
+			Expect(output["start_time"]).To(Equal("2024-01-02T03:04:06Z"))
+			Expect(output["checks"]).To(Equal([]interface{}{
+				map[string]interface{}{"name": "passing", "description": "Always passes", "status": "pass", "message": "All good", "duration_seconds": 1.0},
+				map[string]interface{}{"name": "failing", "status": "fail", "message": "Broken", "duration_seconds": 1.0},
+			}))
+		})
+	})
+})
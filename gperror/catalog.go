@@ -0,0 +1,119 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gperror
+
+/*
+ * This file contains the registry of error codes described by error catalogs,
+ * which code generated by cmd/gperror-catalog fills in when a package that
+ * uses a catalog is initialized.  See internal/cataloggen for the catalog
+ * format.
+ */
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+/*
+ * A CatalogEntry describes one error code.  Template is the format string
+ * for the error's message, Hint tells the user what to do about the error,
+ * and Retryable is whether the operation that failed may succeed if tried
+ * again.
+ */
+type CatalogEntry struct {
+	Code      ErrorCode
+	Name      string
+	Template  string
+	Hint      string
+	Retryable bool
+}
+
+var (
+	catalogMutex sync.RWMutex
+	catalog      = make(map[ErrorCode]CatalogEntry)
+)
+
+/*
+ * RegisterCatalog adds entries to the registry.  Registering the same entry
+ * twice is allowed, but it panics if a code is registered with two different
+ * entries, so that two packages cannot silently claim the same code.
+ */
+func RegisterCatalog(entries ...CatalogEntry) {
+	catalogMutex.Lock()
+	defer catalogMutex.Unlock()
+	for _, entry := range entries {
+		if existing, ok := catalog[entry.Code]; ok && existing != entry {
+			panic(fmt.Sprintf("Error code %04d is registered as both %s and %s", entry.Code, existing.Name, entry.Name))
+		}
+		catalog[entry.Code] = entry
+	}
+}
+
+// Lookup returns the catalog entry for code, if one has been registered
+func Lookup(code ErrorCode) (CatalogEntry, bool) {
+	catalogMutex.RLock()
+	defer catalogMutex.RUnlock()
+	entry, ok := catalog[code]
+	return entry, ok
+}
+
+// Catalog returns every registered entry, in order of code
+func Catalog() []CatalogEntry {
+	catalogMutex.RLock()
+	defer catalogMutex.RUnlock()
+	entries := make([]CatalogEntry, 0, len(catalog))
+	for _, entry := range catalog {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Code < entries[j].Code })
+	return entries
+}
+
+/*
+ * NewFromCatalog returns an error with the given code whose message is the
+ * code's template formatted with args.  If the code is not in the registry,
+ * the args are formatted with %v instead.
+ */
+func NewFromCatalog(code ErrorCode, args ...any) Error {
+	entry, ok := Lookup(code)
+	if !ok {
+		return &GpError{ErrorCode: code, Err: fmt.Errorf("%s", fmt.Sprint(args...))}
+	}
+	return New(code, entry.Template, args...)
+}
+
+// IsRetryable returns whether err contains a gperror.Error whose code is registered as retryable
+func IsRetryable(err error) bool {
+	gpErr, ok := Find(err)
+	if !ok {
+		return false
+	}
+	entry, ok := Lookup(gpErr.GetCode())
+	return ok && entry.Retryable
+}
+
+// HintFor returns the registered hint for the code of the gperror.Error in err, or "" if there is none
+func HintFor(err error) string {
+	gpErr, ok := Find(err)
+	if !ok {
+		return ""
+	}
+	entry, _ := Lookup(gpErr.GetCode())
+	return entry.Hint
+}
@@ -0,0 +1,89 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gperror_test
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/apache/cloudberry-go-libs/gperror"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("gperror/catalog tests", func() {
+	lockHeld := gperror.CatalogEntry{Code: 9901, Name: "TEST_LOCK_HELD", Template: "Unable to acquire lock %s: it is held by %s", Hint: "Wait for the other process to finish", Retryable: true}
+	lockCorrupt := gperror.CatalogEntry{Code: 9902, Name: "TEST_LOCK_CORRUPT", Template: "Lock file %s is corrupt"}
+
+	BeforeEach(func() {
+		gperror.RegisterCatalog(lockHeld, lockCorrupt)
+	})
+
+	Describe("RegisterCatalog", func() {
+		It("allows the same entry to be registered again", func() {
+			gperror.RegisterCatalog(lockHeld)
+			entry, ok := gperror.Lookup(9901)
+			Expect(ok).To(BeTrue())
+			Expect(entry).To(Equal(lockHeld))
+		})
+		It("panics if a code is registered with a different entry", func() {
+			conflicting := lockHeld
+			conflicting.Name = "TEST_OTHER"
+			Expect(func() { gperror.RegisterCatalog(conflicting) }).To(PanicWith("Error code 9901 is registered as both TEST_LOCK_HELD and TEST_OTHER"))
+		})
+	})
+	Describe("Lookup", func() {
+		It("returns false for an unregistered code", func() {
+			_, ok := gperror.Lookup(9999)
+			Expect(ok).To(BeFalse())
+		})
+	})
+	Describe("Catalog", func() {
+		It("returns the registered entries in order of code", func() {
+			Expect(gperror.Catalog()).To(ContainElements(lockHeld, lockCorrupt))
+			entries := gperror.Catalog()
+			for i := 1; i < len(entries); i++ {
+				Expect(entries[i].Code).To(BeNumerically(">", entries[i-1].Code))
+			}
+		})
+	})
+	Describe("NewFromCatalog", func() {
+		It("formats the code's template", func() {
+			err := gperror.NewFromCatalog(9901, "backup.lock", "pid 1234")
+			Expect(err.Error()).To(Equal("ERROR[9901] Unable to acquire lock backup.lock: it is held by pid 1234"))
+		})
+		It("formats the arguments if the code is not registered", func() {
+			err := gperror.NewFromCatalog(9999, "something failed")
+			Expect(err.Error()).To(Equal("ERROR[9999] something failed"))
+		})
+	})
+	Describe("IsRetryable and HintFor", func() {
+		It("describe a wrapped error by its registered code", func() {
+			err := fmt.Errorf("backup failed: %w", gperror.NewFromCatalog(9901, "backup.lock", "pid 1234"))
+			Expect(gperror.IsRetryable(err)).To(BeTrue())
+			Expect(gperror.HintFor(err)).To(Equal("Wait for the other process to finish"))
+		})
+		It("treat errors without a registered code as not retryable and without a hint", func() {
+			for _, err := range []error{errors.New("plain"), gperror.NewFromCatalog(9902, "backup.lock"), gperror.New(9999, "unregistered")} {
+				Expect(gperror.IsRetryable(err)).To(BeFalse())
+				Expect(gperror.HintFor(err)).To(Equal(""))
+			}
+		})
+	})
+})
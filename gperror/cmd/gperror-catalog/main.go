@@ -0,0 +1,75 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+/*
+ * gperror-catalog generates the error code constants and registry entries
+ * for a package from an error catalog, and optionally Markdown documentation
+ * of the codes; see gperror/internal/cataloggen for the catalog format.  It
+ * is meant to be run with go:generate, e.g.
+ *
+ *   //go:generate go run github.com/apache/cloudberry-go-libs/gperror/cmd/gperror-catalog -catalog errors.yaml -output errors_gen.go -doc ../docs/errors.md
+ *
+ * The package name defaults to $GOPACKAGE, which go generate sets.
+ */
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/apache/cloudberry-go-libs/gperror/internal/cataloggen"
+)
+
+func main() {
+	catalogFile := flag.String("catalog", "errors.yaml", "The error catalog to read")
+	outputFile := flag.String("output", "errors_gen.go", "The Go file to write")
+	docFile := flag.String("doc", "", "A Markdown file to write documentation of the error codes to, if set")
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "The package of the Go file to write")
+	flag.Parse()
+
+	if err := generate(*catalogFile, *outputFile, *docFile, *pkg); err != nil {
+		fmt.Fprintf(os.Stderr, "gperror-catalog: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func generate(catalogFile string, outputFile string, docFile string, pkg string) error {
+	if pkg == "" {
+		return fmt.Errorf("No package given; pass -package or run from go generate")
+	}
+	contents, err := os.ReadFile(catalogFile)
+	if err != nil {
+		return err
+	}
+	entries, err := cataloggen.Parse(contents)
+	if err != nil {
+		return err
+	}
+	code, err := cataloggen.GenerateCode(pkg, filepath.Base(catalogFile), entries)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(outputFile, code, 0644); err != nil {
+		return err
+	}
+	if docFile != "" {
+		return os.WriteFile(docFile, cataloggen.GenerateDoc(entries), 0644)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gperror
+
+/*
+ * This file contains a collector for the errors from many work items, which
+ * groups identical errors so that a summary of a large-scale failure lists
+ * each distinct error once with its count rather than thousands of times.
+ */
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/operating"
+)
+
+const ERROR_SUMMARY_TIME_FORMAT = "2006-01-02 15:04:05"
+
+/*
+ * An ErrorSummary describes every occurrence of one distinct error.  Errors
+ * are identical if they are Equal: if they contain a gperror.Error, its code
+ * and message are compared and any errors wrapping it are ignored, and
+ * otherwise their messages are compared.  Code is 0 for errors that are not
+ * gperror.Errors, and Example is the first occurrence of the error.
+ */
+type ErrorSummary struct {
+	Code    ErrorCode
+	Message string
+	Count   int
+	First   time.Time
+	Last    time.Time
+	Example error
+}
+
+/*
+ * String describes the error and its occurrences, e.g.
+ *
+ *   ERROR[5001] could not open file occurred 4,812 times (first at 2024-01-02 15:04:05, last at 2024-01-02 16:00:00)
+ */
+func (summary ErrorSummary) String() string {
+	description := summary.Message
+	if summary.Code != 0 {
+		description = fmt.Sprintf("ERROR[%04d] %s", summary.Code, summary.Message)
+	}
+	if summary.Count == 1 {
+		return fmt.Sprintf("%s occurred once (at %s)", description, summary.First.Format(ERROR_SUMMARY_TIME_FORMAT))
+	}
+	return fmt.Sprintf("%s occurred %s times (first at %s, last at %s)", description, formatCount(summary.Count),
+		summary.First.Format(ERROR_SUMMARY_TIME_FORMAT), summary.Last.Format(ERROR_SUMMARY_TIME_FORMAT))
+}
+
+// formatCount formats count with commas separating each group of thousands
+func formatCount(count int) string {
+	digits := strconv.Itoa(count)
+	sign := ""
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	groups := make([]string, 0)
+	for len(digits) > 3 {
+		groups = append([]string{digits[len(digits)-3:]}, groups...)
+		digits = digits[:len(digits)-3]
+	}
+	return sign + strings.Join(append([]string{digits}, groups...), ",")
+}
+
+type errorKey struct {
+	code    ErrorCode
+	message string
+}
+
+/*
+ * A Collector groups the errors added to it by any number of goroutines.  The
+ * time of each occurrence is taken from operating.System.Now.
+ */
+type Collector struct {
+	mutex     sync.Mutex
+	summaries map[errorKey]*ErrorSummary
+	order     []errorKey
+	total     int
+}
+
+func NewCollector() *Collector {
+	return &Collector{summaries: make(map[errorKey]*ErrorSummary)}
+}
+
+// Add records an occurrence of err; nil errors are ignored
+func (collector *Collector) Add(err error) {
+	if err == nil {
+		return
+	}
+	key := errorKey{message: err.Error()}
+	if gpErr, ok := Find(err); ok {
+		key = errorKey{code: gpErr.GetCode(), message: messageOf(gpErr.GetErr())}
+	}
+	now := operating.System.Now()
+
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+	collector.total++
+	summary, ok := collector.summaries[key]
+	if !ok {
+		summary = &ErrorSummary{Code: key.code, Message: key.message, First: now, Example: err}
+		collector.summaries[key] = summary
+		collector.order = append(collector.order, key)
+	}
+	summary.Count++
+	summary.Last = now
+}
+
+// Total returns the number of errors added, including duplicates
+func (collector *Collector) Total() int {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+	return collector.total
+}
+
+/*
+ * Summaries returns a summary of each distinct error, most frequent first,
+ * with errors that occurred equally often in the order they first occurred.
+ */
+func (collector *Collector) Summaries() []ErrorSummary {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+	summaries := make([]ErrorSummary, len(collector.order))
+	for i, key := range collector.order {
+		summaries[i] = *collector.summaries[key]
+	}
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[i].Count > summaries[j].Count
+	})
+	return summaries
+}
+
+// Report returns the String of each summary, one per line, or "" if no errors were added
+func (collector *Collector) Report() string {
+	lines := make([]string, 0)
+	for _, summary := range collector.Summaries() {
+		lines = append(lines, summary.String())
+	}
+	return strings.Join(lines, "\n")
+}
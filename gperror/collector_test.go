@@ -0,0 +1,138 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gperror_test
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/gperror"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("gperror/collector tests", func() {
+	var (
+		collector *gperror.Collector
+		start     time.Time
+	)
+
+	BeforeEach(func() {
+		start = time.Date(2024, time.January, 2, 15, 4, 5, 0, time.UTC)
+		testhelper.FreezeSystem(GinkgoT(), testhelper.FreezeOptions{Now: start})
+		collector = gperror.NewCollector()
+	})
+	setNow := func(now time.Time) {
+		operating.System.Now = func() time.Time { return now }
+	}
+
+	Describe("Add", func() {
+		It("groups identical errors and tracks their first and last occurrences", func() {
+			collector.Add(gperror.New(5001, "could not open file"))
+			setNow(start.Add(time.Hour))
+			collector.Add(gperror.New(5001, "could not open file"))
+			collector.Add(errors.New("connection refused"))
+
+			summaries := collector.Summaries()
+			Expect(summaries).To(HaveLen(2))
+			Expect(summaries[0].Code).To(Equal(gperror.ErrorCode(5001)))
+			Expect(summaries[0].Message).To(Equal("could not open file"))
+			Expect(summaries[0].Count).To(Equal(2))
+			Expect(summaries[0].First).To(Equal(start))
+			Expect(summaries[0].Last).To(Equal(start.Add(time.Hour)))
+			Expect(summaries[1].Code).To(Equal(gperror.ErrorCode(0)))
+			Expect(summaries[1].Message).To(Equal("connection refused"))
+			Expect(summaries[1].Count).To(Equal(1))
+			Expect(collector.Total()).To(Equal(3))
+		})
+		It("ignores errors wrapping a gperror when grouping", func() {
+			first := errors.Wrap(gperror.New(5001, "could not open file"), "Could not back up table public.foo")
+			collector.Add(first)
+			collector.Add(errors.Wrap(gperror.New(5001, "could not open file"), "Could not back up table public.bar"))
+
+			summaries := collector.Summaries()
+			Expect(summaries).To(HaveLen(1))
+			Expect(summaries[0].Count).To(Equal(2))
+			Expect(summaries[0].Example).To(Equal(first))
+		})
+		It("distinguishes errors with the same message and different codes", func() {
+			collector.Add(gperror.New(5001, "failed"))
+			collector.Add(gperror.New(5002, "failed"))
+			Expect(collector.Summaries()).To(HaveLen(2))
+		})
+		It("ignores nil errors", func() {
+			collector.Add(nil)
+			Expect(collector.Summaries()).To(BeEmpty())
+			Expect(collector.Total()).To(Equal(0))
+		})
+		It("counts errors added concurrently", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 100; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					collector.Add(fmt.Errorf("error %d", i%3))
+				}(i)
+			}
+			wg.Wait()
+			summaries := collector.Summaries()
+			Expect(summaries).To(HaveLen(3))
+			Expect(summaries[0].Count + summaries[1].Count + summaries[2].Count).To(Equal(100))
+		})
+	})
+	Describe("Summaries", func() {
+		It("lists the most frequent errors first, then in order of first occurrence", func() {
+			collector.Add(errors.New("a"))
+			collector.Add(errors.New("b"))
+			collector.Add(errors.New("c"))
+			collector.Add(errors.New("c"))
+			messages := make([]string, 0)
+			for _, summary := range collector.Summaries() {
+				messages = append(messages, summary.Message)
+			}
+			Expect(messages).To(Equal([]string{"c", "a", "b"}))
+		})
+	})
+	Describe("Report", func() {
+		It("describes each distinct error with its count and occurrences", func() {
+			for i := 0; i < 4812; i++ {
+				setNow(start.Add(time.Duration(i) * time.Second))
+				collector.Add(gperror.New(5001, "could not open file"))
+			}
+			setNow(start)
+			collector.Add(errors.New("connection refused"))
+			Expect(collector.Report()).To(Equal(
+				"ERROR[5001] could not open file occurred 4,812 times (first at 2024-01-02 15:04:05, last at 2024-01-02 16:24:16)\n" +
+					"connection refused occurred once (at 2024-01-02 15:04:05)"))
+		})
+		It("returns an empty report if there were no errors", func() {
+			Expect(collector.Report()).To(Equal(""))
+		})
+	})
+	Describe("ErrorSummary", func() {
+		It("formats large counts with separators", func() {
+			summary := gperror.ErrorSummary{Message: "failed", Count: 1234567, First: start, Last: start}
+			Expect(summary.String()).To(HavePrefix("failed occurred 1,234,567 times"))
+		})
+	})
+})
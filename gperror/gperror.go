@@ -27,6 +27,30 @@ func (e *GpError) GetErr() error {
 	return e.Err
 }
 
+func (e *GpError) Unwrap() error {
+	return e.Err
+}
+
+/*
+ * Is reports whether target is a GpError with the same code and message, so
+ * that errors.Is and Gomega's MatchError treat errors as equal even when the
+ * embedded errors were created separately and captured different stacks.
+ */
+func (e *GpError) Is(target error) bool {
+	other, ok := target.(*GpError)
+	if !ok || other == nil {
+		return false
+	}
+	return e.ErrorCode == other.ErrorCode && messageOf(e.Err) == messageOf(other.Err)
+}
+
+func messageOf(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
 func New(errorCode ErrorCode, errorFormat string, args ...any) Error {
 	return &GpError{ErrorCode: errorCode, Err: fmt.Errorf(errorFormat, args...)}
 }
@@ -0,0 +1,151 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cataloggen
+
+/*
+ * This file contains functions for reading an error catalog and generating
+ * Go code and documentation from it, used by gperror/cmd/gperror-catalog, so
+ * that a utility's error codes, messages, and documentation all come from one
+ * file.  They are kept out of package gperror so that utilities using the
+ * generated code do not link in the YAML parser.  A catalog is a YAML file of
+ * the form
+ *
+ *   errors:
+ *     - code: 5101
+ *       name: LOCK_HELD
+ *       template: "Unable to acquire lock %s: it is held by %s"
+ *       hint: "Wait for the other process to finish, or remove the lock file if it is no longer running"
+ *       retryable: true
+ *
+ * where code and name must each be unique, name is the constant generated
+ * for the code, template is a format string, and hint and retryable are
+ * optional.
+ */
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"regexp"
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/gperror"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+var catalogNamePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+type catalogFile struct {
+	Errors []catalogEntry `yaml:"errors"`
+}
+
+type catalogEntry struct {
+	Code      gperror.ErrorCode `yaml:"code"`
+	Name      string            `yaml:"name"`
+	Template  string            `yaml:"template"`
+	Hint      string            `yaml:"hint"`
+	Retryable bool              `yaml:"retryable"`
+}
+
+// Parse parses and validates a catalog, returning its entries in the order they appear
+func Parse(contents []byte) ([]gperror.CatalogEntry, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(contents))
+	decoder.KnownFields(true)
+	var file catalogFile
+	if err := decoder.Decode(&file); err != nil {
+		return nil, errors.Wrap(err, "Unable to parse error catalog")
+	}
+	entries := make([]gperror.CatalogEntry, 0, len(file.Errors))
+	codes := make(map[gperror.ErrorCode]string)
+	names := make(map[string]bool)
+	for i, entry := range file.Errors {
+		if !catalogNamePattern.MatchString(entry.Name) {
+			return nil, errors.Errorf("Invalid name %q for entry %d of error catalog; names must be upper case identifiers", entry.Name, i+1)
+		}
+		if entry.Code == 0 {
+			return nil, errors.Errorf("Error %s in error catalog has no code", entry.Name)
+		}
+		if other, ok := codes[entry.Code]; ok {
+			return nil, errors.Errorf("Errors %s and %s in error catalog both have code %04d", other, entry.Name, entry.Code)
+		}
+		if names[entry.Name] {
+			return nil, errors.Errorf("Error %s appears more than once in error catalog", entry.Name)
+		}
+		if entry.Template == "" {
+			return nil, errors.Errorf("Error %s in error catalog has no template", entry.Name)
+		}
+		codes[entry.Code] = entry.Name
+		names[entry.Name] = true
+		entries = append(entries, gperror.CatalogEntry(entry))
+	}
+	return entries, nil
+}
+
+/*
+ * GenerateCode returns the source of a Go file in package pkg that declares
+ * a constant for each entry and registers the entries with
+ * gperror.RegisterCatalog when the package is initialized.  source is the name of
+ * the catalog file, for the generated file's header.
+ */
+func GenerateCode(pkg string, source string, entries []gperror.CatalogEntry) ([]byte, error) {
+	qualifier := "gperror."
+	var code bytes.Buffer
+	fmt.Fprintf(&code, "// Code generated by gperror-catalog from %s; DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(&code, "package %s\n\n", pkg)
+	if pkg == "gperror" {
+		qualifier = ""
+	} else {
+		fmt.Fprintf(&code, "import \"github.com/apache/cloudberry-go-libs/gperror\"\n\n")
+	}
+
+	fmt.Fprintf(&code, "const (\n")
+	for _, entry := range entries {
+		fmt.Fprintf(&code, "\t%s %sErrorCode = %d\n", entry.Name, qualifier, entry.Code)
+	}
+	fmt.Fprintf(&code, ")\n\n")
+
+	fmt.Fprintf(&code, "func init() {\n\t%sRegisterCatalog(\n", qualifier)
+	for _, entry := range entries {
+		fmt.Fprintf(&code, "\t\t%sCatalogEntry{Code: %s, Name: %q, Template: %q, Hint: %q, Retryable: %t},\n",
+			qualifier, entry.Name, entry.Name, entry.Template, entry.Hint, entry.Retryable)
+	}
+	fmt.Fprintf(&code, "\t)\n}\n")
+
+	formatted, err := format.Source(code.Bytes())
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to format generated error catalog code")
+	}
+	return formatted, nil
+}
+
+// GenerateDoc returns a Markdown table describing each entry, for a utility's documentation
+func GenerateDoc(entries []gperror.CatalogEntry) []byte {
+	var doc bytes.Buffer
+	doc.WriteString("| Code | Name | Message | Hint | Retryable |\n")
+	doc.WriteString("| ---- | ---- | ------- | ---- | --------- |\n")
+	escape := strings.NewReplacer("|", `\|`, "\n", " ")
+	for _, entry := range entries {
+		retryable := "No"
+		if entry.Retryable {
+			retryable = "Yes"
+		}
+		fmt.Fprintf(&doc, "| %04d | %s | %s | %s | %s |\n", entry.Code, entry.Name, escape.Replace(entry.Template), escape.Replace(entry.Hint), retryable)
+	}
+	return doc.Bytes()
+}
@@ -0,0 +1,105 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cataloggen_test
+
+import (
+	"github.com/apache/cloudberry-go-libs/gperror"
+	"github.com/apache/cloudberry-go-libs/gperror/internal/cataloggen"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("cataloggen tests", func() {
+	catalog := `errors:
+  - code: 5101
+    name: LOCK_HELD
+    template: "Unable to acquire lock %s: it is held by %s"
+    hint: "Wait for the other process to finish"
+    retryable: true
+  - code: 5102
+    name: LOCK_TIMEOUT
+    template: "Timed out waiting for lock %s"
+`
+	entries := []gperror.CatalogEntry{
+		{Code: 5101, Name: "LOCK_HELD", Template: "Unable to acquire lock %s: it is held by %s", Hint: "Wait for the other process to finish", Retryable: true},
+		{Code: 5102, Name: "LOCK_TIMEOUT", Template: "Timed out waiting for lock %s"},
+	}
+
+	Describe("Parse", func() {
+		It("returns the entries in order", func() {
+			parsed, err := cataloggen.Parse([]byte(catalog))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parsed).To(Equal(entries))
+		})
+		DescribeTable("rejects invalid catalogs",
+			func(contents string, message string) {
+				_, err := cataloggen.Parse([]byte(contents))
+				Expect(err).To(MatchError(ContainSubstring(message)))
+			},
+			Entry("an unknown field", "errors:\n  - code: 1\n    name: A\n    template: a\n    retry: true\n", "field retry not found"),
+			Entry("a missing code", "errors:\n  - name: A\n    template: a\n", "Error A in error catalog has no code"),
+			Entry("a duplicate code", "errors:\n  - {code: 1, name: A, template: a}\n  - {code: 1, name: B, template: b}\n", "Errors A and B in error catalog both have code 0001"),
+			Entry("a duplicate name", "errors:\n  - {code: 1, name: A, template: a}\n  - {code: 2, name: A, template: b}\n", "Error A appears more than once"),
+			Entry("an invalid name", "errors:\n  - {code: 1, name: lockHeld, template: a}\n", `Invalid name "lockHeld" for entry 1`),
+			Entry("a missing template", "errors:\n  - {code: 1, name: A}\n", "Error A in error catalog has no template"),
+		)
+	})
+	Describe("GenerateCode", func() {
+		It("generates constants and registers the entries", func() {
+			code, err := cataloggen.GenerateCode("lockfile", "errors.yaml", entries)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(code)).To(Equal(`// Code generated by gperror-catalog from errors.yaml; DO NOT EDIT.
+
+package lockfile
+
+import "github.com/apache/cloudberry-go-libs/gperror"
+
+const (
+	LOCK_HELD    gperror.ErrorCode = 5101
+	LOCK_TIMEOUT gperror.ErrorCode = 5102
+)
+
+func init() {
+	gperror.RegisterCatalog(
+		gperror.CatalogEntry{Code: LOCK_HELD, Name: "LOCK_HELD", Template: "Unable to acquire lock %s: it is held by %s", Hint: "Wait for the other process to finish", Retryable: true},
+		gperror.CatalogEntry{Code: LOCK_TIMEOUT, Name: "LOCK_TIMEOUT", Template: "Timed out waiting for lock %s", Hint: "", Retryable: false},
+	)
+}
+`))
+		})
+		It("does not import gperror into itself", func() {
+			code, err := cataloggen.GenerateCode("gperror", "errors.yaml", entries[:1])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(code)).ToNot(ContainSubstring("import"))
+			Expect(string(code)).To(ContainSubstring("LOCK_HELD ErrorCode = 5101"))
+			Expect(string(code)).To(ContainSubstring("\tRegisterCatalog(\n\t\tCatalogEntry{"))
+		})
+	})
+	Describe("GenerateDoc", func() {
+		It("generates a Markdown table of the entries", func() {
+			withPipe := append(entries, gperror.CatalogEntry{Code: 5103, Name: "LOCK_BAD", Template: "Bad lock a|b"})
+			Expect(string(cataloggen.GenerateDoc(withPipe))).To(Equal(`| Code | Name | Message | Hint | Retryable |
+| ---- | ---- | ------- | ---- | --------- |
+| 5101 | LOCK_HELD | Unable to acquire lock %s: it is held by %s | Wait for the other process to finish | Yes |
+| 5102 | LOCK_TIMEOUT | Timed out waiting for lock %s |  | No |
+| 5103 | LOCK_BAD | Bad lock a\|b |  | No |
+`))
+		})
+	})
+})
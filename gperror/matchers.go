@@ -0,0 +1,122 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gperror
+
+/*
+ * This file contains Gomega matchers for asserting on the code and message of
+ * a gperror.Error, including one wrapped by other errors.
+ */
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/onsi/gomega/format"
+	"github.com/onsi/gomega/types"
+)
+
+// Find returns the first gperror.Error in err's chain of wrapped errors
+func Find(err error) (Error, bool) {
+	var gpErr Error
+	if errors.As(err, &gpErr) {
+		return gpErr, true
+	}
+	return nil, false
+}
+
+/*
+ * Equal reports whether two errors are the same for the purposes of a test:
+ * if both contain a gperror.Error, their codes and the messages of their
+ * embedded errors are compared, and otherwise their messages are compared.
+ * Stack traces and any errors wrapping the gperror.Error are ignored.
+ */
+func Equal(a error, b error) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	gpErrA, okA := Find(a)
+	gpErrB, okB := Find(b)
+	if okA && okB {
+		return gpErrA.GetCode() == gpErrB.GetCode() && messageOf(gpErrA.GetErr()) == messageOf(gpErrB.GetErr())
+	}
+	return a.Error() == b.Error()
+}
+
+type errorMatcher struct {
+	description string
+	match       func(err error) bool
+}
+
+func (matcher *errorMatcher) Match(actual interface{}) (bool, error) {
+	if actual == nil {
+		return false, nil
+	}
+	err, ok := actual.(error)
+	if !ok {
+		return false, fmt.Errorf("Expected an error.  Got:\n%s", format.Object(actual, 1))
+	}
+	return matcher.match(err), nil
+}
+
+func (matcher *errorMatcher) FailureMessage(actual interface{}) string {
+	return format.Message(actual, matcher.description)
+}
+
+func (matcher *errorMatcher) NegatedFailureMessage(actual interface{}) string {
+	return format.Message(actual, "not "+matcher.description)
+}
+
+// MatchCode succeeds if the actual error contains a gperror.Error with the given code
+func MatchCode(code ErrorCode) types.GomegaMatcher {
+	return &errorMatcher{
+		description: fmt.Sprintf("to contain a gperror with code %04d", code),
+		match: func(err error) bool {
+			gpErr, ok := Find(err)
+			return ok && gpErr.GetCode() == code
+		},
+	}
+}
+
+/*
+ * MatchMessage succeeds if the message of the actual error matches pattern.
+ * If the error contains a gperror.Error, the message of its embedded error is
+ * matched, without the "ERROR[code]" prefix.
+ */
+func MatchMessage(pattern string) types.GomegaMatcher {
+	regex := regexp.MustCompile(pattern)
+	return &errorMatcher{
+		description: fmt.Sprintf("to have a message matching %q", pattern),
+		match: func(err error) bool {
+			if gpErr, ok := Find(err); ok {
+				return regex.MatchString(messageOf(gpErr.GetErr()))
+			}
+			return regex.MatchString(err.Error())
+		},
+	}
+}
+
+// EqualError succeeds if the actual error is Equal to expected
+func EqualError(expected error) types.GomegaMatcher {
+	return &errorMatcher{
+		description: fmt.Sprintf("to equal error %q", messageOf(expected)),
+		match: func(err error) bool {
+			return Equal(expected, err)
+		},
+	}
+}
@@ -0,0 +1,96 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gperror_test
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/apache/cloudberry-go-libs/gperror"
+	pkgerrors "github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("gperror matchers", func() {
+	var wrappedErr error
+
+	BeforeEach(func() {
+		// pkg/errors captures a stack trace at each of these calls
+		gpErr := &gperror.GpError{ErrorCode: 1234, Err: pkgerrors.New("disk full on host sdw1")}
+		wrappedErr = pkgerrors.Wrap(gpErr, "Unable to write backup file")
+	})
+
+	Describe("Is", func() {
+		It("treats separately created errors with the same code and message as equal", func() {
+			Expect(wrappedErr).To(MatchError(gperror.New(1234, "disk full on host %s", "sdw1")))
+			Expect(errors.Is(wrappedErr, gperror.New(1234, "disk full on host sdw1"))).To(BeTrue())
+		})
+		It("distinguishes errors with different codes or messages", func() {
+			Expect(errors.Is(wrappedErr, gperror.New(1235, "disk full on host sdw1"))).To(BeFalse())
+			Expect(errors.Is(wrappedErr, gperror.New(1234, "disk full on host sdw2"))).To(BeFalse())
+		})
+		It("allows the embedded error to be found", func() {
+			osErr := fmt.Errorf("wrapped: %w", errors.ErrUnsupported)
+			Expect(errors.Is(&gperror.GpError{ErrorCode: 1, Err: osErr}, errors.ErrUnsupported)).To(BeTrue())
+		})
+	})
+	Describe("Equal", func() {
+		It("compares the codes and messages of gperrors, ignoring wrapping", func() {
+			Expect(gperror.Equal(wrappedErr, gperror.New(1234, "disk full on host sdw1"))).To(BeTrue())
+			Expect(gperror.Equal(wrappedErr, gperror.New(1234, "disk full"))).To(BeFalse())
+		})
+		It("compares the messages of other errors", func() {
+			Expect(gperror.Equal(pkgerrors.New("failed"), errors.New("failed"))).To(BeTrue())
+			Expect(gperror.Equal(pkgerrors.New("failed"), nil)).To(BeFalse())
+			Expect(gperror.Equal(nil, nil)).To(BeTrue())
+		})
+	})
+	Describe("MatchCode", func() {
+		It("matches the code of a wrapped gperror", func() {
+			Expect(wrappedErr).To(gperror.MatchCode(1234))
+			Expect(wrappedErr).ToNot(gperror.MatchCode(4321))
+			Expect(errors.New("plain error")).ToNot(gperror.MatchCode(1234))
+		})
+		It("fails for values that are not errors", func() {
+			success, err := gperror.MatchCode(1234).Match("not an error")
+			Expect(success).To(BeFalse())
+			Expect(err).To(HaveOccurred())
+		})
+		It("describes the failure", func() {
+			Expect(gperror.MatchCode(42).FailureMessage(wrappedErr)).To(ContainSubstring("to contain a gperror with code 0042"))
+		})
+	})
+	Describe("MatchMessage", func() {
+		It("matches the message of a wrapped gperror without its code prefix", func() {
+			Expect(wrappedErr).To(gperror.MatchMessage(`^disk full on host sdw\d+$`))
+			Expect(wrappedErr).ToNot(gperror.MatchMessage(`^ERROR`))
+		})
+		It("matches the message of other errors", func() {
+			Expect(errors.New("connection refused")).To(gperror.MatchMessage("refused"))
+		})
+	})
+	Describe("EqualError", func() {
+		It("matches errors that are Equal", func() {
+			Expect(wrappedErr).To(gperror.EqualError(gperror.New(1234, "disk full on host sdw1")))
+			Expect(wrappedErr).ToNot(gperror.EqualError(gperror.New(1234, "other")))
+			Expect(nil).ToNot(gperror.EqualError(gperror.New(1234, "other")))
+		})
+	})
+})
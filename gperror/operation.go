@@ -0,0 +1,109 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gperror
+
+/*
+ * This file contains a wrapper that records the operations an error passed
+ * through on its way up the stack, so that an error such as "file not found"
+ * can be traced to the table being restored when it happened without
+ * changing the error's code or message.
+ */
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type operationError struct {
+	err error
+	// Outermost operation first
+	operations []string
+}
+
+/*
+ * WithOperation records that err occurred while performing the operation
+ * described by format and args, e.g.
+ *
+ *   return gperror.WithOperation(err, "restoring table %s", name)
+ *
+ * The returned error has the same message as err and unwraps to it, so Find,
+ * Equal, and Collector treat it as err, but formatting it with %+v adds the
+ * trail of operations recorded by every WithOperation call it passed through,
+ * outermost first:
+ *
+ *   ERROR[5001] file not found
+ *   while restoring database sales
+ *   while restoring table public.orders
+ *
+ * WithOperation returns nil if err is nil.
+ */
+func WithOperation(err error, format string, args ...any) error {
+	if err == nil {
+		return nil
+	}
+	operation := fmt.Sprintf(format, args...)
+	if opErr, ok := err.(*operationError); ok {
+		return &operationError{err: opErr.err, operations: append([]string{operation}, opErr.operations...)}
+	}
+	return &operationError{err: err, operations: []string{operation}}
+}
+
+// Operations returns the operations recorded in err's chain of wrapped errors by WithOperation, outermost first
+func Operations(err error) []string {
+	operations := make([]string, 0)
+	for err != nil {
+		if opErr, ok := err.(*operationError); ok {
+			operations = append(operations, opErr.operations...)
+		}
+		err = errors.Unwrap(err)
+	}
+	return operations
+}
+
+// OperationTrail returns the operations recorded in err as a single line, e.g. "restoring database sales > restoring table public.orders"
+func OperationTrail(err error) string {
+	return strings.Join(Operations(err), " > ")
+}
+
+func (e *operationError) Error() string {
+	return e.err.Error()
+}
+
+func (e *operationError) Unwrap() error {
+	return e.err
+}
+
+func (e *operationError) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			fmt.Fprintf(s, "%+v", e.err)
+			for _, operation := range e.operations {
+				_, _ = io.WriteString(s, "\nwhile "+operation)
+			}
+			return
+		}
+		_, _ = io.WriteString(s, e.Error())
+	case 's':
+		_, _ = io.WriteString(s, e.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	}
+}
@@ -0,0 +1,84 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gperror_test
+
+import (
+	"fmt"
+
+	"github.com/apache/cloudberry-go-libs/gperror"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("gperror/operation tests", func() {
+	var notFound error
+
+	BeforeEach(func() {
+		notFound = gperror.New(5001, "file not found")
+	})
+
+	Describe("WithOperation", func() {
+		It("returns nil for a nil error", func() {
+			Expect(gperror.WithOperation(nil, "restoring table %s", "public.orders")).To(BeNil())
+		})
+		It("keeps the message and code of the wrapped error", func() {
+			err := gperror.WithOperation(notFound, "restoring table %s", "public.orders")
+			Expect(err.Error()).To(Equal("ERROR[5001] file not found"))
+			Expect(fmt.Sprintf("%v", err)).To(Equal("ERROR[5001] file not found"))
+			Expect(fmt.Sprintf("%s", err)).To(Equal("ERROR[5001] file not found"))
+			Expect(fmt.Sprintf("%q", err)).To(Equal(`"ERROR[5001] file not found"`))
+			Expect(err).To(gperror.MatchCode(5001))
+			Expect(err).To(gperror.MatchMessage("^file not found$"))
+			Expect(errors.Is(err, notFound)).To(BeTrue())
+			Expect(gperror.Equal(err, notFound)).To(BeTrue())
+		})
+		It("adds the trail of operations to %+v output, outermost first", func() {
+			err := gperror.WithOperation(notFound, "restoring table %s", "public.orders")
+			err = gperror.WithOperation(err, "restoring database %s", "sales")
+			Expect(fmt.Sprintf("%+v", err)).To(Equal("ERROR[5001] file not found\nwhile restoring database sales\nwhile restoring table public.orders"))
+		})
+		It("does not change the error it was given", func() {
+			inner := gperror.WithOperation(notFound, "restoring table %s", "public.orders")
+			_ = gperror.WithOperation(inner, "restoring database %s", "sales")
+			Expect(gperror.Operations(inner)).To(Equal([]string{"restoring table public.orders"}))
+		})
+		It("does not split errors grouped by a Collector", func() {
+			collector := gperror.NewCollector()
+			collector.Add(gperror.WithOperation(notFound, "restoring table %s", "public.orders"))
+			collector.Add(gperror.WithOperation(notFound, "restoring table %s", "public.customers"))
+			Expect(collector.Summaries()).To(HaveLen(1))
+			Expect(collector.Summaries()[0].Count).To(Equal(2))
+		})
+	})
+	Describe("Operations", func() {
+		It("returns no operations for an error without any", func() {
+			Expect(gperror.Operations(notFound)).To(BeEmpty())
+			Expect(gperror.Operations(nil)).To(BeEmpty())
+		})
+		It("collects operations through other wrapping errors", func() {
+			err := gperror.WithOperation(notFound, "reading %s", "/data/file1")
+			err = errors.Wrap(err, "restore failed")
+			err = gperror.WithOperation(err, "restoring database %s", "sales")
+			Expect(gperror.Operations(err)).To(Equal([]string{"restoring database sales", "reading /data/file1"}))
+			Expect(gperror.OperationTrail(err)).To(Equal("restoring database sales > reading /data/file1"))
+			Expect(err).To(gperror.MatchCode(5001))
+		})
+	})
+})
@@ -0,0 +1,219 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pathutil
+
+/*
+ * This file contains functions for computing and validating the standard
+ * paths used by Greenplum and Cloudberry utilities, such as segment data
+ * directories and the gpAdminLogs directory, so that every utility builds
+ * them the same way.  All file system and user lookups go through
+ * operating.System so they can be mocked.
+ */
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+const (
+	ADMIN_LOG_DIR_NAME    = "gpAdminLogs"
+	DEFAULT_DATA_DIR_NAME = "gpseg"
+)
+
+/*
+ * AdminLogDir returns the gpAdminLogs directory of the current user.  The home
+ * directory is taken from the user database rather than from HOME, which
+ * may belong to another user when a utility is run with sudo.
+ */
+func AdminLogDir() (string, error) {
+	currentUser, err := operating.System.CurrentUser()
+	if err != nil {
+		return "", errors.Wrap(err, "Unable to determine the gpAdminLogs directory")
+	}
+	if currentUser.HomeDir == "" {
+		return "", errors.Errorf("Unable to determine the gpAdminLogs directory: user %s has no home directory", currentUser.Username)
+	}
+	return filepath.Join(currentUser.HomeDir, ADMIN_LOG_DIR_NAME), nil
+}
+
+/*
+ * SegmentDataDir returns the data directory that gpinitsystem creates for
+ * contentID under parentDir, e.g. /data/primary/gpseg0, or
+ * /data/coordinator/gpseg-1 for the coordinator.  If prefix is empty,
+ * DEFAULT_DATA_DIR_NAME is used.
+ */
+func SegmentDataDir(parentDir string, prefix string, contentID int) string {
+	if prefix == "" {
+		prefix = DEFAULT_DATA_DIR_NAME
+	}
+	return filepath.Join(parentDir, fmt.Sprintf("%s%d", prefix, contentID))
+}
+
+/*
+ * A DataDirLayout describes where the data directories of a new cluster go,
+ * in the way gpinitsystem lays them out: the coordinator and standby go in
+ * CoordinatorDir, and the primaries and mirrors on each host are spread in
+ * turn across PrimaryDirs and MirrorDirs.
+ */
+type DataDirLayout struct {
+	Prefix         string
+	CoordinatorDir string
+	PrimaryDirs    []string
+	MirrorDirs     []string
+}
+
+/*
+ * Assign sets DataDir for each segment that does not already have one.  Each
+ * segment is placed by its preferred role, or by its current role if it has
+ * no preferred role, and the segments of each role on each host are taken in
+ * order of content ID.
+ */
+func (layout DataDirLayout) Assign(segments []cluster.SegConfig) error {
+	type hostRole struct {
+		host string
+		role string
+	}
+	order := make([]int, len(segments))
+	for i := range segments {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return segments[order[i]].ContentID < segments[order[j]].ContentID
+	})
+	counts := make(map[hostRole]int)
+	for _, i := range order {
+		segment := &segments[i]
+		role := segment.PreferredRole
+		if role == "" {
+			role = segment.Role
+		}
+		var parentDirs []string
+		switch {
+		case segment.ContentID == -1:
+			if layout.CoordinatorDir != "" {
+				parentDirs = []string{layout.CoordinatorDir}
+			}
+		case role == "p":
+			parentDirs = layout.PrimaryDirs
+		case role == "m":
+			parentDirs = layout.MirrorDirs
+		default:
+			return errors.Errorf("Unable to assign a data directory to segment with dbid %d: unknown role %q", segment.DbID, role)
+		}
+		key := hostRole{host: segment.Hostname, role: role}
+		index := counts[key]
+		counts[key]++
+		if segment.DataDir != "" {
+			continue
+		}
+		if len(parentDirs) == 0 {
+			return errors.Errorf("Unable to assign a data directory to segment with dbid %d: no directories given for its role", segment.DbID)
+		}
+		segment.DataDir = SegmentDataDir(parentDirs[index%len(parentDirs)], layout.Prefix, segment.ContentID)
+	}
+	return nil
+}
+
+/*
+ * ValidateDataDirs checks that every segment's data directory is an absolute
+ * path and that no two segments on the same host share a data directory or
+ * have one within the other's.  The directories themselves are not checked,
+ * as they are usually on other hosts.
+ */
+func ValidateDataDirs(segments []cluster.SegConfig) error {
+	problems := make([]string, 0)
+	dirsByHost := make(map[string][]cluster.SegConfig)
+	for _, segment := range segments {
+		if !filepath.IsAbs(segment.DataDir) {
+			problems = append(problems, fmt.Sprintf("data directory %q of dbid %d is not an absolute path", segment.DataDir, segment.DbID))
+			continue
+		}
+		for _, other := range dirsByHost[segment.Hostname] {
+			if isWithin(segment.DataDir, other.DataDir) || isWithin(other.DataDir, segment.DataDir) {
+				problems = append(problems, fmt.Sprintf("data directories of dbids %d and %d on host %s overlap: %s and %s",
+					other.DbID, segment.DbID, segment.Hostname, other.DataDir, segment.DataDir))
+			}
+		}
+		dirsByHost[segment.Hostname] = append(dirsByHost[segment.Hostname], segment)
+	}
+	if len(problems) > 0 {
+		return errors.Errorf("Invalid data directories: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// isWithin returns whether path is dir or is inside dir
+func isWithin(path string, dir string) bool {
+	relative, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	return err == nil && relative != ".." && !strings.HasPrefix(relative, "../")
+}
+
+/*
+ * ValidateDataDir checks that dir is an absolute path to a directory
+ * containing the PG_VERSION file that every data directory has.
+ */
+func ValidateDataDir(dir string) error {
+	if !filepath.IsAbs(dir) {
+		return errors.Errorf("Data directory %q is not an absolute path", dir)
+	}
+	info, err := operating.System.Stat(dir)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to use data directory %s", dir)
+	}
+	if !info.IsDir() {
+		return errors.Errorf("Data directory %s is not a directory", dir)
+	}
+	if _, err := operating.System.Stat(filepath.Join(dir, "PG_VERSION")); err != nil {
+		return errors.Errorf("%s is not a data directory: it has no PG_VERSION file", dir)
+	}
+	return nil
+}
+
+/*
+ * TablespaceDir returns the directory in which the segment with the given
+ * dbid stores a tablespace created with LOCATION location; since Greenplum 6,
+ * each segment uses a subdirectory named for its dbid.
+ */
+func TablespaceDir(location string, dbid int) string {
+	return filepath.Join(location, strconv.Itoa(dbid))
+}
+
+// TablespaceLink returns the path of the symbolic link in dataDir to the tablespace with the given OID
+func TablespaceLink(dataDir string, tablespaceOid uint32) string {
+	return filepath.Join(dataDir, "pg_tblspc", strconv.FormatUint(uint64(tablespaceOid), 10))
+}
+
+// TablespaceLocation returns the directory to which the tablespace with the given OID in dataDir links
+func TablespaceLocation(dataDir string, tablespaceOid uint32) (string, error) {
+	link := TablespaceLink(dataDir, tablespaceOid)
+	target, err := operating.System.Readlink(link)
+	if err != nil {
+		return "", errors.Wrapf(err, "Unable to find the location of tablespace %d", tablespaceOid)
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(link), target)
+	}
+	return filepath.Clean(target), nil
+}
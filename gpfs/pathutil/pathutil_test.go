@@ -0,0 +1,148 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pathutil_test
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/gpfs/pathutil"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// makeDataDir creates a directory under parent that looks like a data directory
+func makeDataDir(parent string, name string) string {
+	dir := filepath.Join(parent, name)
+	Expect(os.MkdirAll(dir, 0700)).To(Succeed())
+	Expect(os.WriteFile(filepath.Join(dir, "PG_VERSION"), []byte("12\n"), 0600)).To(Succeed())
+	return dir
+}
+
+var _ = Describe("pathutil/pathutil tests", func() {
+	Describe("AdminLogDir", func() {
+		It("uses the home directory of the current user rather than HOME", func() {
+			testhelper.FreezeSystem(GinkgoT(), testhelper.FreezeOptions{HomeDir: "/home/gpadmin/"})
+			operating.WithEnv(map[string]string{"HOME": "/root"}, func() {
+				Expect(pathutil.AdminLogDir()).To(Equal("/home/gpadmin/gpAdminLogs"))
+			})
+		})
+		It("returns an error if the current user is unknown", func() {
+			testhelper.FreezeSystem(GinkgoT(), testhelper.FreezeOptions{})
+			operating.System.CurrentUser = func() (*user.User, error) { return nil, errors.New("unknown user") }
+			_, err := pathutil.AdminLogDir()
+			Expect(err).To(MatchError("Unable to determine the gpAdminLogs directory: unknown user"))
+		})
+	})
+	Describe("SegmentDataDir", func() {
+		It("joins the parent directory and the directory name without doubled slashes", func() {
+			Expect(pathutil.SegmentDataDir("/data/primary/", "", 0)).To(Equal("/data/primary/gpseg0"))
+			Expect(pathutil.SegmentDataDir("/data//coordinator", "demoDataDir", -1)).To(Equal("/data/coordinator/demoDataDir-1"))
+		})
+	})
+	Describe("DataDirLayout.Assign", func() {
+		layout := pathutil.DataDirLayout{
+			CoordinatorDir: "/data/coordinator",
+			PrimaryDirs:    []string{"/data1/primary", "/data2/primary"},
+			MirrorDirs:     []string{"/data1/mirror"},
+		}
+		It("spreads each host's segments across the directories for their role", func() {
+			segments := []cluster.SegConfig{
+				{DbID: 1, ContentID: -1, Role: "p", Hostname: "cdw"},
+				{DbID: 3, ContentID: 1, Role: "p", Hostname: "sdw1"},
+				{DbID: 2, ContentID: 0, Role: "p", Hostname: "sdw1"},
+				{DbID: 4, ContentID: 2, Role: "p", Hostname: "sdw1"},
+				{DbID: 5, ContentID: 0, Role: "p", PreferredRole: "m", Hostname: "sdw2"},
+				{DbID: 6, ContentID: 1, Role: "m", Hostname: "sdw2", DataDir: "/custom/gpseg1"},
+			}
+			Expect(layout.Assign(segments)).To(Succeed())
+			dirs := make([]string, len(segments))
+			for i, segment := range segments {
+				dirs[i] = segment.DataDir
+			}
+			Expect(dirs).To(Equal([]string{
+				"/data/coordinator/gpseg-1",
+				"/data2/primary/gpseg1",
+				"/data1/primary/gpseg0",
+				"/data1/primary/gpseg2",
+				"/data1/mirror/gpseg0",
+				"/custom/gpseg1",
+			}))
+		})
+		It("returns an error if no directories are given for a role", func() {
+			segments := []cluster.SegConfig{{DbID: 2, ContentID: 0, Role: "m", Hostname: "sdw1"}}
+			Expect(pathutil.DataDirLayout{PrimaryDirs: []string{"/data"}}.Assign(segments)).To(MatchError("Unable to assign a data directory to segment with dbid 2: no directories given for its role"))
+		})
+	})
+	Describe("ValidateDataDirs", func() {
+		It("accepts distinct absolute directories", func() {
+			segments := []cluster.SegConfig{
+				{DbID: 2, Hostname: "sdw1", DataDir: "/data/gpseg0"},
+				{DbID: 3, Hostname: "sdw1", DataDir: "/data/gpseg01"},
+				{DbID: 4, Hostname: "sdw2", DataDir: "/data/gpseg0"},
+			}
+			Expect(pathutil.ValidateDataDirs(segments)).To(Succeed())
+		})
+		It("rejects relative, duplicate, and nested directories", func() {
+			segments := []cluster.SegConfig{
+				{DbID: 2, Hostname: "sdw1", DataDir: "data/gpseg0"},
+				{DbID: 3, Hostname: "sdw1", DataDir: "/data/gpseg1"},
+				{DbID: 4, Hostname: "sdw1", DataDir: "/data/gpseg1/"},
+				{DbID: 5, Hostname: "sdw1", DataDir: "/data/gpseg1/mirror"},
+			}
+			Expect(pathutil.ValidateDataDirs(segments)).To(MatchError(`Invalid data directories: data directory "data/gpseg0" of dbid 2 is not an absolute path; ` +
+				"data directories of dbids 3 and 4 on host sdw1 overlap: /data/gpseg1 and /data/gpseg1/; " +
+				"data directories of dbids 3 and 5 on host sdw1 overlap: /data/gpseg1 and /data/gpseg1/mirror; " +
+				"data directories of dbids 4 and 5 on host sdw1 overlap: /data/gpseg1/ and /data/gpseg1/mirror"))
+		})
+	})
+	Describe("ValidateDataDir", func() {
+		It("accepts a directory containing PG_VERSION", func() {
+			Expect(pathutil.ValidateDataDir(makeDataDir(GinkgoT().TempDir(), "gpseg0"))).To(Succeed())
+		})
+		It("rejects a relative path", func() {
+			Expect(pathutil.ValidateDataDir("gpseg0")).To(MatchError(`Data directory "gpseg0" is not an absolute path`))
+		})
+		It("rejects a directory without PG_VERSION", func() {
+			dir := GinkgoT().TempDir()
+			Expect(pathutil.ValidateDataDir(dir)).To(MatchError(dir + " is not a data directory: it has no PG_VERSION file"))
+		})
+		It("rejects a missing directory", func() {
+			Expect(pathutil.ValidateDataDir("/nonexistent/gpseg0")).To(MatchError(ContainSubstring("Unable to use data directory /nonexistent/gpseg0")))
+		})
+	})
+	Describe("Tablespaces", func() {
+		It("places each segment's tablespace directory in a subdirectory for its dbid", func() {
+			Expect(pathutil.TablespaceDir("/tblspc/fast/", 3)).To(Equal("/tblspc/fast/3"))
+		})
+		It("follows the link in pg_tblspc to find a tablespace's location", func() {
+			dataDir := makeDataDir(GinkgoT().TempDir(), "gpseg0")
+			Expect(os.Mkdir(filepath.Join(dataDir, "pg_tblspc"), 0700)).To(Succeed())
+			Expect(os.Symlink("/tblspc/fast/2", pathutil.TablespaceLink(dataDir, 16385))).To(Succeed())
+			Expect(pathutil.TablespaceLocation(dataDir, 16385)).To(Equal("/tblspc/fast/2"))
+			_, err := pathutil.TablespaceLocation(dataDir, 16386)
+			Expect(err).To(MatchError(ContainSubstring("Unable to find the location of tablespace 16386")))
+		})
+	})
+})
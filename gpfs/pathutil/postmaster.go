@@ -0,0 +1,106 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pathutil
+
+/*
+ * This file contains functions for finding the coordinator data directory
+ * from the environment and for reading the postmaster.pid file that a running
+ * server keeps in its data directory.
+ */
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+/*
+ * The environment variables that may name the coordinator data directory, in
+ * the order they are checked; Greenplum 6 and earlier use
+ * MASTER_DATA_DIRECTORY.
+ */
+var CoordinatorDataDirVariables = []string{"COORDINATOR_DATA_DIRECTORY", "MASTER_DATA_DIRECTORY"}
+
+/*
+ * PostmasterPid holds the contents of postmaster.pid.  Fields the server did
+ * not write, such as Status before PostgreSQL 10, are left empty.
+ */
+type PostmasterPid struct {
+	Pid           int
+	DataDir       string
+	StartTime     time.Time
+	Port          int
+	SocketDir     string
+	ListenAddress string
+	Status        string
+}
+
+func ReadPostmasterPid(dataDir string) (PostmasterPid, error) {
+	path := filepath.Join(dataDir, "postmaster.pid")
+	contents, err := operating.System.ReadFile(path)
+	if err != nil {
+		return PostmasterPid{}, errors.Wrapf(err, "Unable to read %s", path)
+	}
+	lines := strings.Split(string(contents), "\n")
+	for len(lines) < 8 {
+		lines = append(lines, "")
+	}
+	field := func(i int) string {
+		return strings.TrimSpace(lines[i])
+	}
+	pidfile := PostmasterPid{DataDir: field(1), SocketDir: field(4), ListenAddress: field(5), Status: field(7)}
+	if pidfile.Pid, err = strconv.Atoi(field(0)); err != nil || pidfile.Pid <= 0 {
+		return PostmasterPid{}, errors.Errorf("%s does not contain a valid process ID: %q", path, field(0))
+	}
+	if startTime, err := strconv.ParseInt(field(2), 10, 64); err == nil {
+		pidfile.StartTime = time.Unix(startTime, 0)
+	}
+	if port, err := strconv.Atoi(field(3)); err == nil {
+		pidfile.Port = port
+	}
+	return pidfile, nil
+}
+
+/*
+ * CoordinatorDataDir returns the coordinator data directory named by the
+ * first of CoordinatorDataDirVariables that is set, after checking it with
+ * ValidateDataDir.  If a coordinator is running from that directory, the path
+ * recorded in its postmaster.pid is returned instead, so that a path reached
+ * through a symbolic link is replaced by the one the server uses.
+ */
+func CoordinatorDataDir() (string, error) {
+	for _, name := range CoordinatorDataDirVariables {
+		dir := operating.System.Getenv(name)
+		if dir == "" {
+			continue
+		}
+		dir = filepath.Clean(dir)
+		if err := ValidateDataDir(dir); err != nil {
+			return "", errors.Wrapf(err, "Invalid %s", name)
+		}
+		if pidfile, err := ReadPostmasterPid(dir); err == nil && pidfile.DataDir != "" && operating.IsProcessRunning(pidfile.Pid) {
+			return pidfile.DataDir, nil
+		}
+		return dir, nil
+	}
+	return "", errors.Errorf("Unable to find the coordinator data directory; set %s", CoordinatorDataDirVariables[0])
+}
@@ -0,0 +1,105 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pathutil_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/gpfs/pathutil"
+	"github.com/apache/cloudberry-go-libs/operating"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("pathutil/postmaster tests", func() {
+	var parent string
+
+	BeforeEach(func() {
+		parent = GinkgoT().TempDir()
+	})
+	writePidfile := func(dataDir string, contents string) {
+		Expect(os.WriteFile(filepath.Join(dataDir, "postmaster.pid"), []byte(contents), 0600)).To(Succeed())
+	}
+
+	Describe("ReadPostmasterPid", func() {
+		It("reads every field of postmaster.pid", func() {
+			dataDir := makeDataDir(parent, "gpseg-1")
+			writePidfile(dataDir, "4242\n/data/coordinator/gpseg-1\n1704067200\n5432\n/tmp\n*\n  5432001    131072\nready   \n")
+			pidfile, err := pathutil.ReadPostmasterPid(dataDir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pidfile).To(Equal(pathutil.PostmasterPid{
+				Pid:           4242,
+				DataDir:       "/data/coordinator/gpseg-1",
+				StartTime:     time.Unix(1704067200, 0),
+				Port:          5432,
+				SocketDir:     "/tmp",
+				ListenAddress: "*",
+				Status:        "ready",
+			}))
+		})
+		It("leaves out fields that older servers do not write", func() {
+			dataDir := makeDataDir(parent, "gpseg0")
+			writePidfile(dataDir, "4242\n/data/primary/gpseg0\n")
+			pidfile, err := pathutil.ReadPostmasterPid(dataDir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pidfile.Port).To(Equal(0))
+			Expect(pidfile.Status).To(Equal(""))
+		})
+		It("returns an error for an invalid process ID", func() {
+			dataDir := makeDataDir(parent, "gpseg0")
+			writePidfile(dataDir, "-1\n")
+			_, err := pathutil.ReadPostmasterPid(dataDir)
+			Expect(err).To(MatchError(fmt.Sprintf(`%s/postmaster.pid does not contain a valid process ID: "-1"`, dataDir)))
+		})
+	})
+	Describe("CoordinatorDataDir", func() {
+		It("uses COORDINATOR_DATA_DIRECTORY before MASTER_DATA_DIRECTORY", func() {
+			coordinator := makeDataDir(parent, "coordinator")
+			master := makeDataDir(parent, "master")
+			operating.WithEnv(map[string]string{"COORDINATOR_DATA_DIRECTORY": coordinator + "/", "MASTER_DATA_DIRECTORY": master}, func() {
+				Expect(pathutil.CoordinatorDataDir()).To(Equal(coordinator))
+			})
+			operating.WithEnv(map[string]string{"COORDINATOR_DATA_DIRECTORY": "", "MASTER_DATA_DIRECTORY": master}, func() {
+				Expect(pathutil.CoordinatorDataDir()).To(Equal(master))
+			})
+		})
+		It("uses the path in postmaster.pid if the coordinator is running", func() {
+			coordinator := makeDataDir(parent, "coordinator")
+			writePidfile(coordinator, fmt.Sprintf("%d\n/data/coordinator/gpseg-1\n", os.Getpid()))
+			operating.WithEnv(map[string]string{"COORDINATOR_DATA_DIRECTORY": coordinator}, func() {
+				Expect(pathutil.CoordinatorDataDir()).To(Equal("/data/coordinator/gpseg-1"))
+			})
+		})
+		It("returns an error if the directory is not a data directory", func() {
+			operating.WithEnv(map[string]string{"COORDINATOR_DATA_DIRECTORY": parent}, func() {
+				_, err := pathutil.CoordinatorDataDir()
+				Expect(err).To(MatchError(fmt.Sprintf("Invalid COORDINATOR_DATA_DIRECTORY: %s is not a data directory: it has no PG_VERSION file", parent)))
+			})
+		})
+		It("returns an error if no variable is set", func() {
+			operating.WithEnv(map[string]string{"COORDINATOR_DATA_DIRECTORY": "", "MASTER_DATA_DIRECTORY": ""}, func() {
+				_, err := pathutil.CoordinatorDataDir()
+				Expect(err).To(MatchError("Unable to find the coordinator data directory; set COORDINATOR_DATA_DIRECTORY"))
+			})
+		})
+	})
+})
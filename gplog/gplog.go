@@ -12,7 +12,10 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"time"
+	"unicode/utf8"
 
+	"github.com/apache/cloudberry-go-libs/metrics"
 	"github.com/apache/cloudberry-go-libs/operating"
 	"github.com/pkg/errors"
 )
@@ -41,7 +44,15 @@ var (
 	 * A function which can customize log file name
 	 */
 	logFileNameFunc LogFileNameFunc
+	// Whether InitializeLogging also opens an errors-only log file, set with SetErrorLogEnabled
+	errorLogEnabled bool
+	// Whether InitializeLogging expands ~ and environment variables in its logdir, set with SetLogDirExpansion
+	logDirExpansion bool
 	exitFunc        ExitFunc
+	// The number of messages logged at each of these levels since the process started, for metrics.go
+	warningCount  int64
+	errorCount    int64
+	criticalCount int64
 )
 
 const (
@@ -60,6 +71,31 @@ const (
 	LOGDEBUG
 )
 
+/*
+ * Terminal output is split between stdout and stderr, and each stream has its
+ * own verbosity, set with SetStreamVerbosity.  The stdout verbosity is the
+ * same as the one set by SetVerbosity, while the stderr verbosity defaults to
+ * LOGERROR, so that by default only errors are written to stderr.
+ *
+ * Each message written to the terminal goes to exactly one stream: to stderr
+ * if the stderr verbosity is at or above the message's level, and otherwise
+ * to stdout if the stdout verbosity is at or above the message's level.  For
+ * this purpose Warn, Success, and Hint messages have the level LOGINFO,
+ * though Warn messages are still written to stdout at any stdout verbosity
+ * other than LOGNONE.  For example, a tool whose stdout is piped elsewhere
+ * can set the stderr verbosity to LOGINFO to keep progress messages on the
+ * terminal, or set the stdout verbosity to LOGNONE to write nothing but
+ * errors to the terminal.
+ */
+const LOGNONE = -1
+
+type Stream int
+
+const (
+	STREAM_STDOUT Stream = iota
+	STREAM_STDERR
+)
+
 // ESCAPE - ASCII escape character to start color character sequences
 const ESCAPE = "\x1b"
 
@@ -77,7 +113,8 @@ const (
  * Leveled logging output functions using the above log levels are implemented
  * below.  Info(), Verbose(), and Debug() print messages when the log level is
  * set at or above the log level matching their names.  Warn(), Error(), and
- * Fatal() always print their messages regardless of the current log level.
+ * Fatal() always print their messages regardless of the current log level,
+ * unless terminal output is disabled with LOGNONE (see SetStreamVerbosity).
  *
  * The intended usage of these functions is as follows:
  * - Info: Messages that should always be written unless the user explicitly
@@ -95,6 +132,9 @@ const (
  *          cannot be reached.  This function will exit the program after printing
  *          the error message.
  * - FatalWithoutPanic: Same as Fatal, but will not trigger panic. Just exit(1).
+ * - Hint: Suggestions for how the user might resolve a problem reported by a
+ *         preceding Warn or Error call.  Hint is logged at the Info level and
+ *         is prefixed with "HINT: " by default.
  */
 type LogPrefixFunc func(string) string
 type LogFileNameFunc func(string, string) string
@@ -105,12 +145,50 @@ type GpLogger struct {
 	logStderr          *log.Logger
 	logFile            *log.Logger
 	logFileName        string
+	errorLogFile       *log.Logger
+	errorLogFileName   string
 	shellVerbosity     int
+	stderrVerbosity    int
 	fileVerbosity      int
 	header             string
 	logPrefixFunc      LogPrefixFunc
 	shellLogPrefixFunc LogPrefixFunc
 	colorize           bool
+	templates          map[string]LevelTemplate
+}
+
+/*
+ * A LevelTemplate controls how the body of a message is rendered for a given
+ * message level, after the log prefix has been determined.  MessagePrefix is
+ * prepended to the first line of the message, and Continuation determines how
+ * any subsequent lines of a multi-line message are rendered:
+ *
+ * CONTINUATION_NONE:   Lines after the first are written as-is (the default).
+ * CONTINUATION_INDENT: Lines after the first are indented to line up with the
+ *                      start of the message text on the first line.
+ * CONTINUATION_REPEAT: The log prefix and MessagePrefix are repeated on every
+ *                      line, so each line can be grepped for on its own.
+ *
+ * Templates are keyed by the level string used in the log prefix ("INFO",
+ * "WARNING", "ERROR", "CRITICAL", "DEBUG"), plus "HINT" for Hint messages.
+ */
+type ContinuationStyle int
+
+const (
+	CONTINUATION_NONE ContinuationStyle = iota
+	CONTINUATION_INDENT
+	CONTINUATION_REPEAT
+)
+
+type LevelTemplate struct {
+	MessagePrefix string
+	Continuation  ContinuationStyle
+}
+
+func defaultTemplates() map[string]LevelTemplate {
+	return map[string]LevelTemplate{
+		"HINT": {MessagePrefix: "HINT: "},
+	}
 }
 
 /*
@@ -129,6 +207,12 @@ func InitializeLogging(program string, logdir string) {
 	currentUser, _ := operating.System.CurrentUser()
 	if logdir == "" {
 		logdir = fmt.Sprintf("%s/gpAdminLogs", currentUser.HomeDir)
+	} else if logDirExpansion {
+		expanded, err := operating.ExpandPath(logdir)
+		if err != nil {
+			abort(err)
+		}
+		logdir = expanded
 	}
 
 	createLogDirectory(logdir)
@@ -137,6 +221,10 @@ func InitializeLogging(program string, logdir string) {
 	logFileHandle := openLogFile(logfile)
 
 	logger = NewLogger(os.Stdout, os.Stderr, logFileHandle, logfile, LOGINFO, program)
+	if errorLogEnabled {
+		errorLogfile := GenerateErrorLogFileName(program, logdir)
+		SetErrorLogFile(openLogFile(errorLogfile), errorLogfile)
+	}
 	SetExitFunc(defaultExit)
 }
 
@@ -151,6 +239,15 @@ func GenerateLogFileName(program, logdir string) string {
 	return logfile
 }
 
+/*
+ * The errors-only log file is always named program_errors_YYYYMMDD.log, even
+ * if the main log file is named by a function passed to SetLogFileNameFunc.
+ */
+func GenerateErrorLogFileName(program, logdir string) string {
+	timestamp := operating.System.Now().Format("20060102")
+	return fmt.Sprintf("%s/%s_errors_%s.log", logdir, program, timestamp)
+}
+
 func SetLogger(log *GpLogger) {
 	logger = log
 }
@@ -173,11 +270,13 @@ func NewLogger(stdout io.Writer, stderr io.Writer, logFile io.Writer, logFileNam
 		logFile:            log.New(logFile, "", 0),
 		logFileName:        logFileName,
 		shellVerbosity:     shellVerbosity,
+		stderrVerbosity:    LOGERROR,
 		fileVerbosity:      fileVerbosity,
 		header:             GetHeader(program),
 		logPrefixFunc:      nil,
 		shellLogPrefixFunc: nil,
 		colorize:           false,
+		templates:          defaultTemplates(),
 	}
 }
 
@@ -219,14 +318,71 @@ func GetColorize() bool {
 	return logger.colorize
 }
 
+// SetLevelTemplate sets the template used to render messages of the given level, replacing any existing template
+func SetLevelTemplate(level string, template LevelTemplate) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	logger.templates[level] = template
+}
+
+// GetLevelTemplate returns the template used to render messages of the given level
+func GetLevelTemplate(level string) LevelTemplate {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	return logger.templates[level]
+}
+
 func SetLogFileNameFunc(fileNameFunc func(string, string) string) {
 	logFileNameFunc = fileNameFunc
 }
 
+/*
+ * If SetErrorLogEnabled(true) is called before InitializeLogging, WARNING,
+ * ERROR, and CRITICAL messages are also written to a second log file in the
+ * log directory, so that problems during a run can be found without reading
+ * through the whole of a verbose main log file.
+ */
+func SetErrorLogEnabled(enabled bool) {
+	errorLogEnabled = enabled
+}
+
+/*
+ * If SetLogDirExpansion(true) is called before InitializeLogging, a logdir
+ * passed to it is expanded with operating.ExpandPath, so that a directory
+ * such as "~/logs" or "$LOG_ROOT/backups" from a flag or configuration file
+ * works as it would in a shell; an unset variable in it is a fatal error.
+ * Otherwise logdir is used exactly as given.
+ */
+func SetLogDirExpansion(enabled bool) {
+	logDirExpansion = enabled
+}
+
+/*
+ * SetErrorLogFile sets the file that WARNING, ERROR, and CRITICAL messages are
+ * also written to, for loggers created with NewLogger.  Passing a nil writer
+ * stops writing messages to a separate file.
+ */
+func SetErrorLogFile(errorLogFile io.Writer, errorLogFileName string) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	if errorLogFile == nil {
+		logger.errorLogFile = nil
+		logger.errorLogFileName = ""
+		return
+	}
+	logger.errorLogFile = log.New(errorLogFile, "", 0)
+	logger.errorLogFileName = errorLogFileName
+}
+
 func SetExitFunc(pExitFunc func()) {
 	exitFunc = pExitFunc
 }
 
+// This function should only be used for testing purposes
+func GetExitFunc() func() {
+	return exitFunc
+}
+
 func defaultLogPrefixFunc(level string) string {
 	logTimestamp := operating.System.Now().Format("20060102:15:04:05")
 	return fmt.Sprintf("%s %s", logTimestamp, fmt.Sprintf(logger.header, level))
@@ -247,11 +403,12 @@ func DefaultShortLogPrefixFunc(level string) string {
 	return ""
 }
 
+// GetLogPrefix returns the prefix for a message in the log file, ending with the calling goroutine's label if it has one
 func GetLogPrefix(level string) string {
 	if logger.logPrefixFunc != nil {
-		return logger.logPrefixFunc(level)
+		return withWorkerLabel(logger.logPrefixFunc(level))
 	}
-	return defaultLogPrefixFunc(level)
+	return withWorkerLabel(defaultLogPrefixFunc(level))
 }
 
 // GetShellLogPrefix returns a prefix to prepend to the message before sending it to the shell console
@@ -261,28 +418,102 @@ func GetLogPrefix(level string) string {
 // so that the prefixes for the shell console and the log file will be the same.
 func GetShellLogPrefix(level string) string {
 	if logger.shellLogPrefixFunc != nil {
-		return logger.shellLogPrefixFunc(level)
+		return withWorkerLabel(logger.shellLogPrefixFunc(level))
 	}
 	return GetLogPrefix(level)
 }
 
+/*
+ * formatMessage renders a message with the given prefix according to the
+ * template registered for templateKey.  Callers must hold logMutex.
+ */
+func formatMessage(templateKey string, prefix string, s string, v ...interface{}) string {
+	return applyTemplate(templateKey, prefix, fmt.Sprintf(s, v...))
+}
+
+func applyTemplate(templateKey string, prefix string, body string) string {
+	template := logger.templates[templateKey]
+	if template.Continuation == CONTINUATION_NONE || !strings.Contains(body, "\n") {
+		return prefix + template.MessagePrefix + body
+	}
+	lines := strings.Split(body, "\n")
+	linePrefix := prefix + template.MessagePrefix
+	continuationPrefix := linePrefix
+	if template.Continuation == CONTINUATION_INDENT {
+		continuationPrefix = strings.Repeat(" ", utf8.RuneCountInString(linePrefix))
+	}
+	var builder strings.Builder
+	for i, line := range lines {
+		if i == 0 {
+			builder.WriteString(linePrefix)
+		} else {
+			builder.WriteString("\n")
+			if line != "" {
+				builder.WriteString(continuationPrefix)
+			}
+		}
+		builder.WriteString(line)
+	}
+	return builder.String()
+}
+
 func GetLogFilePath() string {
 	return logger.logFileName
 }
 
+// GetErrorLogFilePath returns the path of the errors-only log file, or "" if there is none
+func GetErrorLogFilePath() string {
+	return logger.errorLogFileName
+}
+
+// writeLogFile writes a message to the log file, and to the errors-only log file if it is a warning or error
+func writeLogFile(message string, isError bool) {
+	output(logger.logFile, message)
+	if isError && logger.errorLogFile != nil {
+		output(logger.errorLogFile, message)
+	}
+}
+
 func GetVerbosity() int {
+	logMutex.Lock()
+	defer logMutex.Unlock()
 	return logger.shellVerbosity
 }
 
 func SetVerbosity(verbosity int) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
 	logger.shellVerbosity = verbosity
 }
 
+func GetStreamVerbosity(stream Stream) int {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	if stream == STREAM_STDERR {
+		return logger.stderrVerbosity
+	}
+	return logger.shellVerbosity
+}
+
+func SetStreamVerbosity(stream Stream, verbosity int) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	if stream == STREAM_STDERR {
+		logger.stderrVerbosity = verbosity
+	} else {
+		logger.shellVerbosity = verbosity
+	}
+}
+
 func GetLogFileVerbosity() int {
+	logMutex.Lock()
+	defer logMutex.Unlock()
 	return logger.fileVerbosity
 }
 
 func SetLogFileVerbosity(verbosity int) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
 	logger.fileVerbosity = verbosity
 }
 
@@ -308,6 +539,22 @@ func getVerbosityString(verbosity int) string {
 	return ""
 }
 
+/*
+ * shellLogger returns the logger for the stream that a message of the given
+ * level should be written to, as described above, or nil if it should not be
+ * written to the terminal.  If alwaysShow is true, the message is written to
+ * stdout at any stdout verbosity but LOGNONE.
+ */
+func shellLogger(level int, alwaysShow bool) *log.Logger {
+	if logger.stderrVerbosity >= level {
+		return logger.logStderr
+	}
+	if logger.shellVerbosity >= level || (alwaysShow && logger.shellVerbosity > LOGNONE) {
+		return logger.logStdout
+	}
+	return nil
+}
+
 /*
  * Log output functions, as described above
  */
@@ -316,12 +563,12 @@ func Info(s string, v ...interface{}) {
 	logMutex.Lock()
 	defer logMutex.Unlock()
 	if logger.fileVerbosity >= LOGINFO {
-		message := GetLogPrefix("INFO") + fmt.Sprintf(s, v...)
-		_ = logger.logFile.Output(1, message)
+		message := formatMessage("INFO", GetLogPrefix("INFO"), s, v...)
+		output(logger.logFile, message)
 	}
-	if logger.shellVerbosity >= LOGINFO {
-		message := GetShellLogPrefix("INFO") + fmt.Sprintf(s, v...)
-		_ = logger.logStdout.Output(1, message)
+	if shellLog := shellLogger(LOGINFO, false); shellLog != nil {
+		message := formatMessage("INFO", GetShellLogPrefix("INFO"), s, v...)
+		output(shellLog, message)
 	}
 }
 
@@ -329,34 +576,69 @@ func Success(s string, v ...interface{}) {
 	logMutex.Lock()
 	defer logMutex.Unlock()
 	if logger.fileVerbosity >= LOGINFO {
-		message := GetLogPrefix("INFO") + fmt.Sprintf(s, v...)
-		_ = logger.logFile.Output(1, message)
+		message := formatMessage("INFO", GetLogPrefix("INFO"), s, v...)
+		output(logger.logFile, message)
 	}
-	if logger.shellVerbosity >= LOGINFO {
-		message := GetShellLogPrefix("INFO") + fmt.Sprintf(s, v...)
-		_ = logger.logStdout.Output(1, Colorize(GREEN, message))
+	if shellLog := shellLogger(LOGINFO, false); shellLog != nil {
+		message := formatMessage("INFO", GetShellLogPrefix("INFO"), s, v...)
+		output(shellLog, Colorize(GREEN, message))
 	}
 }
 
+func Hint(s string, v ...interface{}) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	if logger.fileVerbosity >= LOGINFO {
+		message := formatMessage("HINT", GetLogPrefix("INFO"), s, v...)
+		output(logger.logFile, message)
+	}
+	if shellLog := shellLogger(LOGINFO, false); shellLog != nil {
+		message := formatMessage("HINT", GetShellLogPrefix("INFO"), s, v...)
+		output(shellLog, message)
+	}
+}
+
+/*
+ * Progress logs a message at the same level as Info, followed by how much of
+ * an operation is complete and, if remaining is positive, roughly how long
+ * the rest of it should take.
+ */
+func Progress(completed int, total int, remaining time.Duration, s string, v ...interface{}) {
+	body := fmt.Sprintf(s, v...)
+	percent := 100
+	if total > 0 {
+		percent = completed * 100 / total
+	}
+	body = fmt.Sprintf("%s: %d of %d complete (%d%%)", body, completed, total, percent)
+	if remaining > 0 && completed < total {
+		body = fmt.Sprintf("%s, about %s remaining", body, remaining.Round(time.Second))
+	}
+	Info("%s", body)
+}
+
 func Warn(s string, v ...interface{}) {
 	logMutex.Lock()
 	defer logMutex.Unlock()
-	message := GetLogPrefix("WARNING") + fmt.Sprintf(s, v...)
-	_ = logger.logFile.Output(1, message)
-	message = GetShellLogPrefix("WARNING") + fmt.Sprintf(s, v...)
-	_ = logger.logStdout.Output(1, Colorize(YELLOW, message))
+	warningCount++
+	loggedMessages.Inc(metrics.Labels{"level": "warning"})
+	message := formatMessage("WARNING", GetLogPrefix("WARNING"), s, v...)
+	writeLogFile(message, true)
+	if shellLog := shellLogger(LOGINFO, true); shellLog != nil {
+		message = formatMessage("WARNING", GetShellLogPrefix("WARNING"), s, v...)
+		output(shellLog, Colorize(YELLOW, message))
+	}
 }
 
 func Verbose(s string, v ...interface{}) {
 	logMutex.Lock()
 	defer logMutex.Unlock()
 	if logger.fileVerbosity >= LOGVERBOSE {
-		message := GetLogPrefix("DEBUG") + fmt.Sprintf(s, v...)
-		_ = logger.logFile.Output(1, message)
+		message := formatMessage("DEBUG", GetLogPrefix("DEBUG"), s, v...)
+		output(logger.logFile, message)
 	}
-	if logger.shellVerbosity >= LOGVERBOSE {
-		message := GetShellLogPrefix("DEBUG") + fmt.Sprintf(s, v...)
-		_ = logger.logStdout.Output(1, message)
+	if shellLog := shellLogger(LOGVERBOSE, false); shellLog != nil {
+		message := formatMessage("DEBUG", GetShellLogPrefix("DEBUG"), s, v...)
+		output(shellLog, message)
 	}
 }
 
@@ -364,12 +646,12 @@ func Debug(s string, v ...interface{}) {
 	logMutex.Lock()
 	defer logMutex.Unlock()
 	if logger.fileVerbosity >= LOGDEBUG {
-		message := GetLogPrefix("DEBUG") + fmt.Sprintf(s, v...)
-		_ = logger.logFile.Output(1, message)
+		message := formatMessage("DEBUG", GetLogPrefix("DEBUG"), s, v...)
+		output(logger.logFile, message)
 	}
-	if logger.shellVerbosity >= LOGDEBUG {
-		message := GetShellLogPrefix("DEBUG") + fmt.Sprintf(s, v...)
-		_ = logger.logStdout.Output(1, message)
+	if shellLog := shellLogger(LOGDEBUG, false); shellLog != nil {
+		message := formatMessage("DEBUG", GetShellLogPrefix("DEBUG"), s, v...)
+		output(shellLog, message)
 	}
 }
 
@@ -377,16 +659,22 @@ func Error(s string, v ...interface{}) {
 	logMutex.Lock()
 	defer logMutex.Unlock()
 	errorCode = 1
-	message := GetLogPrefix("ERROR") + fmt.Sprintf(s, v...)
-	_ = logger.logFile.Output(1, message)
-	message = GetShellLogPrefix("ERROR") + fmt.Sprintf(s, v...)
-	_ = logger.logStderr.Output(1, Colorize(RED, message))
+	errorCount++
+	loggedMessages.Inc(metrics.Labels{"level": "error"})
+	message := formatMessage("ERROR", GetLogPrefix("ERROR"), s, v...)
+	writeLogFile(message, true)
+	if shellLog := shellLogger(LOGERROR, false); shellLog != nil {
+		message = formatMessage("ERROR", GetShellLogPrefix("ERROR"), s, v...)
+		output(shellLog, Colorize(RED, message))
+	}
 }
 
 func Fatal(err error, s string, v ...interface{}) {
 	logMutex.Lock()
 	defer logMutex.Unlock()
 	errorCode = 2
+	criticalCount++
+	loggedMessages.Inc(metrics.Labels{"level": "critical"})
 	message := ""
 	stackTraceStr := ""
 	if err != nil {
@@ -397,11 +685,12 @@ func Fatal(err error, s string, v ...interface{}) {
 		}
 	}
 	message += strings.TrimSpace(fmt.Sprintf(s, v...))
-	fullMessage := GetLogPrefix("CRITICAL") + message
-	_ = logger.logFile.Output(1, fullMessage+stackTraceStr)
-	fullMessage = GetShellLogPrefix("CRITICAL") + message
+	fullMessage := applyTemplate("CRITICAL", GetLogPrefix("CRITICAL"), message)
+	writeLogFile(fullMessage+stackTraceStr, true)
+	fullMessage = applyTemplate("CRITICAL", GetShellLogPrefix("CRITICAL"), message)
 	// messages for panic are not colorized to allow any recover logic to inspect the actual fullMessage
 	// if the fullMessage needs to be output to the shell console, the caller should colorize it explicitly, if desired
+	waitForOutput()
 	if logger.shellVerbosity >= LOGVERBOSE {
 		abort(fullMessage + stackTraceStr)
 	} else {
@@ -418,16 +707,20 @@ func Custom(customFileVerbosity int, customShellVerbosity int, s string, v ...in
 	defer logMutex.Unlock()
 	var message string
 	if logger.fileVerbosity >= customFileVerbosity {
-		message = GetLogPrefix(getVerbosityString(customFileVerbosity)) + fmt.Sprintf(s, v...)
-		_ = logger.logFile.Output(1, message)
+		fileLevel := getVerbosityString(customFileVerbosity)
+		message = formatMessage(fileLevel, GetLogPrefix(fileLevel), s, v...)
+		writeLogFile(message, customFileVerbosity == LOGERROR)
 	}
+	shellLog := shellLogger(customShellVerbosity, false)
+	if shellLog == nil {
+		return
+	}
+	shellLevel := getVerbosityString(customShellVerbosity)
+	message = formatMessage(shellLevel, GetShellLogPrefix(shellLevel), s, v...)
 	if customShellVerbosity == LOGERROR {
-		message = GetShellLogPrefix("ERROR") + fmt.Sprintf(s, v...)
-		_ = logger.logStderr.Output(1, Colorize(RED, message))
-	} else if logger.shellVerbosity >= customShellVerbosity {
-		message = GetShellLogPrefix(getVerbosityString(customShellVerbosity)) + fmt.Sprintf(s, v...)
-		_ = logger.logStdout.Output(1, message)
+		message = Colorize(RED, message)
 	}
+	output(shellLog, message)
 }
 
 func FatalOnError(err error, output ...string) {
@@ -444,10 +737,15 @@ func FatalWithoutPanic(s string, v ...interface{}) {
 	logMutex.Lock()
 	defer logMutex.Unlock()
 	errorCode = 2
-	message := GetLogPrefix("CRITICAL") + fmt.Sprintf(s, v...)
-	_ = logger.logFile.Output(1, message)
-	message = GetShellLogPrefix("CRITICAL") + fmt.Sprintf(s, v...)
-	_ = logger.logStderr.Output(1, Colorize(RED, message))
+	criticalCount++
+	loggedMessages.Inc(metrics.Labels{"level": "critical"})
+	message := formatMessage("CRITICAL", GetLogPrefix("CRITICAL"), s, v...)
+	writeLogFile(message, true)
+	if shellLog := shellLogger(LOGERROR, false); shellLog != nil {
+		message = formatMessage("CRITICAL", GetShellLogPrefix("CRITICAL"), s, v...)
+		output(shellLog, Colorize(RED, message))
+	}
+	waitForOutput()
 	exitFunc()
 }
 
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"os/user"
 	"strings"
 	"testing"
 	"time"
@@ -39,11 +38,8 @@ var _ = Describe("logger/log tests", func() {
 		fakeInfo, err = os.Stat("/tmp/log_dir")
 		Expect(err).ToNot(HaveOccurred())
 
-		operating.System.CurrentUser = func() (*user.User, error) { return &user.User{Username: "testUser", HomeDir: "testDir"}, nil }
-		operating.System.Getpid = func() int { return 0 }
-		operating.System.Hostname = func() (string, error) { return "testHost", nil }
+		testhelper.FreezeSystem(GinkgoT(), testhelper.FreezeOptions{})
 		operating.System.IsNotExist = func(err error) bool { return false }
-		operating.System.Now = func() time.Time { return time.Date(2017, time.January, 1, 1, 1, 1, 1, time.Local) }
 		operating.System.OpenFileWrite = func(name string, flag int, perm os.FileMode) (io.WriteCloser, error) { return buffer, nil }
 		operating.System.Stat = func(name string) (os.FileInfo, error) { return fakeInfo, nil }
 		stdout, stderr, logfile = testhelper.SetupTestLogger()
@@ -96,6 +92,40 @@ var _ = Describe("logger/log tests", func() {
 				Expect(gplog.GetLogFileVerbosity()).To(Equal(gplog.LOGDEBUG))
 			})
 		})
+		Context("Logger initialized with a log directory containing ~ and environment variables", func() {
+			It("uses the log directory as given by default", func() {
+				operating.WithEnv(map[string]string{"LOG_ROOT": ""}, func() {
+					gplog.InitializeLogging("testProgram", "$LOG_ROOT/logs")
+				})
+				Expect(gplog.GetLogFilePath()).To(Equal("$LOG_ROOT/logs/testProgram_20170101.log"))
+			})
+		})
+		Context("Logger initialized with log directory expansion enabled", func() {
+			BeforeEach(func() {
+				gplog.SetLogDirExpansion(true)
+			})
+			AfterEach(func() {
+				gplog.SetLogDirExpansion(false)
+			})
+			It("expands the log directory", func() {
+				operating.WithEnv(map[string]string{"HOME": "/home/gpadmin", "LOG_SUBDIR": "nightly"}, func() {
+					gplog.InitializeLogging("testProgram", "~/logs/${LOG_SUBDIR}/")
+				})
+				Expect(gplog.GetLogFilePath()).To(Equal("/home/gpadmin/logs/nightly/testProgram_20170101.log"))
+			})
+			It("panics if an environment variable in the log directory is not set", func() {
+				defer testhelper.ShouldPanicWithMessage("Unable to expand path: environment variable LOG_ROOT is not set")
+				operating.WithEnv(map[string]string{"LOG_ROOT": ""}, func() {
+					gplog.InitializeLogging("testProgram", "$LOG_ROOT/logs")
+				})
+			})
+			It("falls back to the current user's home directory if HOME is not set", func() {
+				operating.WithEnv(map[string]string{"HOME": ""}, func() {
+					gplog.InitializeLogging("testProgram", "~")
+				})
+				Expect(gplog.GetLogFilePath()).To(Equal("testDir/testProgram_20170101.log"))
+			})
+		})
 		Context("Directory or log file does not exist or is not writable", func() {
 			It("creates a log directory if given a nonexistent log directory", func() {
 				calledWith := ""
@@ -131,6 +161,27 @@ var _ = Describe("logger/log tests", func() {
 				gplog.InitializeLogging("testProgram", "/tmp/log_dir")
 			})
 		})
+		Context("Errors-only log file enabled", func() {
+			AfterEach(func() {
+				gplog.SetErrorLogEnabled(false)
+			})
+			It("opens an errors-only log file alongside the log file", func() {
+				opened := make([]string, 0)
+				operating.System.OpenFileWrite = func(name string, flag int, perm os.FileMode) (io.WriteCloser, error) {
+					opened = append(opened, name)
+					return buffer, nil
+				}
+				gplog.SetErrorLogEnabled(true)
+				gplog.InitializeLogging("testProgram", "/tmp/log_dir")
+				Expect(opened).To(Equal([]string{"/tmp/log_dir/testProgram_20170101.log", "/tmp/log_dir/testProgram_errors_20170101.log"}))
+				Expect(gplog.GetErrorLogFilePath()).To(Equal("/tmp/log_dir/testProgram_errors_20170101.log"))
+			})
+			It("does not open an errors-only log file by default", func() {
+				gplog.SetErrorLogEnabled(false)
+				gplog.InitializeLogging("testProgram", "/tmp/log_dir")
+				Expect(gplog.GetErrorLogFilePath()).To(Equal(""))
+			})
+		})
 	})
 	Describe("GetLogPrefix", func() {
 		It("returns a prefix for the current time", func() {
@@ -211,6 +262,53 @@ var _ = Describe("logger/log tests", func() {
 				defer testhelper.ShouldPanicWithMessage("this is an error: this is output")
 				gplog.FatalOnError(errors.New("this is an error"), "this is output")
 			})
+			It("Logs a CRITICAL entry to the log file before panicking", func() {
+				defer testhelper.ShouldPanicWithCritical(logfile, "this is an error: this is output")
+				gplog.FatalOnError(errors.New("this is an error"), "this is output")
+			})
+			It("Panics with a message ending in the error and output", func() {
+				defer testhelper.ShouldPanicMatching(`\[CRITICAL\]:-this is an error: \d+ files? missing$`)
+				gplog.FatalOnError(errors.New("this is an error"), "3 files missing")
+			})
+		})
+		Describe("Errors-only log file", func() {
+			var errorLogfile *gbytes.Buffer
+			BeforeEach(func() {
+				errorLogfile = gbytes.NewBuffer()
+				gplog.SetErrorLogFile(errorLogfile, "/tmp/log_dir/testProgram_errors_20170101.log")
+			})
+			It("receives warnings, errors, and critical messages", func() {
+				gplog.Warn("warn message")
+				gplog.Error("error message")
+				testhelper.CaptureExit(func() { gplog.FatalWithoutPanic("fatal message") })
+				testhelper.ExpectRegexp(errorLogfile, warnExpected+"warn message")
+				testhelper.ExpectRegexp(errorLogfile, errorExpected+"error message")
+				testhelper.ExpectRegexp(errorLogfile, fatalExpected+"fatal message")
+				testhelper.ExpectRegexp(logfile, warnExpected+"warn message")
+			})
+			It("receives messages from Fatal before panicking", func() {
+				defer testhelper.ShouldPanicWithCritical(errorLogfile, "fatal error")
+				gplog.Fatal(errors.New("fatal error"), "")
+			})
+			It("receives messages from Custom only if they are logged at error level", func() {
+				gplog.Custom(gplog.LOGERROR, gplog.LOGINFO, "custom error")
+				gplog.Custom(gplog.LOGINFO, gplog.LOGINFO, "custom info")
+				testhelper.ExpectRegexp(errorLogfile, errorExpected+"custom error")
+				testhelper.NotExpectRegexp(errorLogfile, "custom info")
+				testhelper.ExpectRegexp(logfile, infoExpected+"custom info")
+			})
+			It("does not receive info, verbose, or debug messages", func() {
+				gplog.Info("info message")
+				gplog.Verbose("verbose message")
+				gplog.Debug("debug message")
+				Expect(string(errorLogfile.Contents())).To(BeEmpty())
+			})
+			It("stops receiving messages once it is unset", func() {
+				gplog.SetErrorLogFile(nil, "")
+				gplog.Warn("warn message")
+				Expect(string(errorLogfile.Contents())).To(BeEmpty())
+				Expect(gplog.GetErrorLogFilePath()).To(Equal(""))
+			})
 		})
 		Describe("Shell verbosity set to Error", func() {
 			BeforeEach(func() {
@@ -327,6 +425,22 @@ var _ = Describe("logger/log tests", func() {
 					testhelper.ExpectRegexp(logfile, infoExpected+expectedMessage)
 				})
 			})
+			Context("Progress", func() {
+				It("prints the completed count and time remaining to stdout and the log file", func() {
+					gplog.Progress(25, 200, 95*time.Second+400*time.Millisecond, "Copying %s", "files")
+					expectedMessage := "Copying files: 25 of 200 complete (12%), about 1m35s remaining"
+					testhelper.ExpectRegexp(stdout, infoExpected+expectedMessage)
+					testhelper.ExpectRegexp(logfile, infoExpected+expectedMessage)
+				})
+				It("omits the time remaining if it is unknown", func() {
+					gplog.Progress(0, 200, 0, "Copying files")
+					testhelper.ExpectRegexp(stdout, infoExpected+"Copying files: 0 of 200 complete (0%)\n")
+				})
+				It("omits the time remaining once the operation is complete", func() {
+					gplog.Progress(200, 200, time.Second, "Copying files")
+					testhelper.ExpectRegexp(stdout, infoExpected+"Copying files: 200 of 200 complete (100%)\n")
+				})
+			})
 			Context("Success", func() {
 				It("prints to stdout and the log file", func() {
 					expectedMessage := "info info"
@@ -1086,4 +1200,130 @@ var _ = Describe("logger/log tests", func() {
 			})
 		})
 	})
+	Describe("Stream verbosity", func() {
+		patternExpected := "20170101:01:01:01 testProgram:testUser:testHost:000000-[%s]:-"
+		infoExpected := fmt.Sprintf(patternExpected, "INFO")
+		warnExpected := fmt.Sprintf(patternExpected, "WARNING")
+		verboseExpected := fmt.Sprintf(patternExpected, "DEBUG")
+		errorExpected := fmt.Sprintf(patternExpected, "ERROR")
+
+		It("defaults to Error for stderr and the shell verbosity for stdout", func() {
+			gplog.SetVerbosity(gplog.LOGVERBOSE)
+			Expect(gplog.GetStreamVerbosity(gplog.STREAM_STDERR)).To(Equal(gplog.LOGERROR))
+			Expect(gplog.GetStreamVerbosity(gplog.STREAM_STDOUT)).To(Equal(gplog.LOGVERBOSE))
+		})
+		It("sets the stdout verbosity along with the shell verbosity", func() {
+			gplog.SetStreamVerbosity(gplog.STREAM_STDOUT, gplog.LOGDEBUG)
+			Expect(gplog.GetVerbosity()).To(Equal(gplog.LOGDEBUG))
+		})
+		Context("stderr verbosity set to Info", func() {
+			BeforeEach(func() {
+				gplog.SetVerbosity(gplog.LOGVERBOSE)
+				gplog.SetStreamVerbosity(gplog.STREAM_STDERR, gplog.LOGINFO)
+			})
+			It("writes info and warning messages to stderr instead of stdout", func() {
+				gplog.Info("info message")
+				gplog.Warn("warn message")
+				testhelper.ExpectRegexp(stderr, infoExpected+"info message")
+				testhelper.ExpectRegexp(stderr, warnExpected+"warn message")
+				testhelper.NotExpectRegexp(stdout, "info message")
+				testhelper.NotExpectRegexp(stdout, "warn message")
+			})
+			It("writes verbose messages to stdout", func() {
+				gplog.Verbose("verbose message")
+				testhelper.ExpectRegexp(stdout, verboseExpected+"verbose message")
+				testhelper.NotExpectRegexp(stderr, "verbose message")
+			})
+			It("writes custom messages according to their shell verbosity", func() {
+				gplog.Custom(gplog.LOGINFO, gplog.LOGINFO, "custom info")
+				gplog.Custom(gplog.LOGINFO, gplog.LOGVERBOSE, "custom verbose")
+				testhelper.ExpectRegexp(stderr, infoExpected+"custom info")
+				testhelper.ExpectRegexp(stdout, verboseExpected+"custom verbose")
+			})
+		})
+		Context("stdout verbosity set to None", func() {
+			BeforeEach(func() {
+				gplog.SetStreamVerbosity(gplog.STREAM_STDOUT, gplog.LOGNONE)
+			})
+			It("writes only errors to the terminal", func() {
+				gplog.Info("info message")
+				gplog.Warn("warn message")
+				gplog.Error("error message")
+				Expect(stdout.Contents()).To(BeEmpty())
+				testhelper.ExpectRegexp(stderr, errorExpected+"error message")
+				testhelper.ExpectRegexp(logfile, warnExpected+"warn message")
+			})
+		})
+		Context("stderr verbosity set to None", func() {
+			BeforeEach(func() {
+				gplog.SetStreamVerbosity(gplog.STREAM_STDERR, gplog.LOGNONE)
+			})
+			It("writes errors to stdout", func() {
+				gplog.Error("error message")
+				Expect(stderr.Contents()).To(BeEmpty())
+				testhelper.ExpectRegexp(stdout, errorExpected+"error message")
+			})
+		})
+	})
+	Describe("Level templates", func() {
+		patternExpected := "20170101:01:01:01 testProgram:testUser:testHost:000000-[%s]:-"
+		infoExpected := fmt.Sprintf(patternExpected, "INFO")
+		errorExpected := fmt.Sprintf(patternExpected, "ERROR")
+
+		BeforeEach(func() {
+			gplog.SetVerbosity(gplog.LOGINFO)
+			gplog.SetLogFileVerbosity(gplog.LOGDEBUG)
+		})
+		Context("Hint", func() {
+			It("prints to stdout and the log file with a HINT prefix", func() {
+				gplog.Hint("run %s first", "gpstart")
+				testhelper.ExpectRegexp(stdout, infoExpected+"HINT: run gpstart first")
+				testhelper.ExpectRegexp(logfile, infoExpected+"HINT: run gpstart first")
+				testhelper.NotExpectRegexp(stderr, "HINT")
+			})
+			It("does not print to stdout if shell verbosity is Error", func() {
+				gplog.SetVerbosity(gplog.LOGERROR)
+				gplog.Hint("run gpstart first")
+				testhelper.NotExpectRegexp(stdout, "HINT: run gpstart first")
+				testhelper.ExpectRegexp(logfile, infoExpected+"HINT: run gpstart first")
+			})
+		})
+		Context("Multi-line messages", func() {
+			It("writes continuation lines as-is by default", func() {
+				gplog.Error("first line\nsecond line")
+				testhelper.ExpectRegexp(logfile, errorExpected+"first line\nsecond line")
+			})
+			It("indents continuation lines to align with the message text", func() {
+				gplog.SetLevelTemplate("ERROR", gplog.LevelTemplate{Continuation: gplog.CONTINUATION_INDENT})
+				gplog.Error("first line\nsecond line")
+				indent := strings.Repeat(" ", len(errorExpected))
+				testhelper.ExpectRegexp(logfile, errorExpected+"first line\n"+indent+"second line")
+				testhelper.ExpectRegexp(stderr, errorExpected+"first line\n"+indent+"second line")
+			})
+			It("repeats the prefix on each continuation line", func() {
+				gplog.SetLevelTemplate("ERROR", gplog.LevelTemplate{MessagePrefix: "DETAIL: ", Continuation: gplog.CONTINUATION_REPEAT})
+				gplog.Error("first line\nsecond line")
+				testhelper.ExpectRegexp(logfile, errorExpected+"DETAIL: first line\n"+errorExpected+"DETAIL: second line")
+			})
+			It("does not indent empty continuation lines", func() {
+				gplog.SetLevelTemplate("ERROR", gplog.LevelTemplate{Continuation: gplog.CONTINUATION_INDENT})
+				gplog.Error("first line\n\nthird line")
+				indent := strings.Repeat(" ", len(errorExpected))
+				testhelper.ExpectRegexp(logfile, errorExpected+"first line\n\n"+indent+"third line")
+			})
+			It("applies templates to the message of a Fatal call", func() {
+				gplog.SetLevelTemplate("CRITICAL", gplog.LevelTemplate{Continuation: gplog.CONTINUATION_REPEAT})
+				defer testhelper.ShouldPanicWithMessage("first line\n" + fmt.Sprintf(patternExpected, "CRITICAL") + "second line")
+				gplog.Fatal(nil, "first line\nsecond line")
+			})
+		})
+		Context("SetLevelTemplate", func() {
+			It("replaces the default Hint template", func() {
+				gplog.SetLevelTemplate("HINT", gplog.LevelTemplate{MessagePrefix: "TIP: "})
+				Expect(gplog.GetLevelTemplate("HINT")).To(Equal(gplog.LevelTemplate{MessagePrefix: "TIP: "}))
+				gplog.Hint("try again")
+				testhelper.ExpectRegexp(stdout, infoExpected+"TIP: try again")
+			})
+		})
+	})
 })
@@ -0,0 +1,120 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gplog
+
+/*
+ * This file contains functions for exposing counts of logged warnings and
+ * errors and the current logging settings in OpenMetrics format, so that
+ * long-running agents can be monitored without scraping their logs.
+ */
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/metrics"
+	"github.com/pkg/errors"
+)
+
+/*
+ * loggedMessages counts messages for the exporter set with metrics.SetExporter,
+ * if any.  WriteMetrics reports the same counter, and the gauges below, from
+ * gplog's own counts, so that the log metrics can be served without setting
+ * an exporter.
+ */
+var (
+	loggedMessages = metrics.NewCounter("gplog_messages", "Messages logged at each level since the process started.")
+	verbosityGauge = metrics.NewGauge("gplog_verbosity", "Current verbosity of each log destination (-1 none, 0 error, 1 info, 2 verbose, 3 debug).")
+	errorCodeGauge = metrics.NewGauge("gplog_error_code", "Current error code (0 success, 1 non-fatal error logged, 2 fatal error logged).")
+)
+
+// The address the metrics server listens on if none is given; it only accepts local connections
+const DEFAULT_METRICS_ADDRESS = "127.0.0.1:9464"
+
+const OPENMETRICS_CONTENT_TYPE = metrics.OPENMETRICS_CONTENT_TYPE
+
+// WriteMetrics writes the current counters and settings to writer in OpenMetrics text format
+func WriteMetrics(writer io.Writer) error {
+	logMutex.Lock()
+	warnings, errs, criticals, code := warningCount, errorCount, criticalCount, errorCode
+	var shellVerbosity, stderrVerbosity, fileVerbosity int
+	if logger != nil {
+		shellVerbosity, stderrVerbosity, fileVerbosity = logger.shellVerbosity, logger.stderrVerbosity, logger.fileVerbosity
+	}
+	logMutex.Unlock()
+
+	snapshot := metrics.NewPrometheusExporter()
+	snapshot.AddCounter(loggedMessages.Desc(), metrics.Labels{"level": "warning"}, float64(warnings))
+	snapshot.AddCounter(loggedMessages.Desc(), metrics.Labels{"level": "error"}, float64(errs))
+	snapshot.AddCounter(loggedMessages.Desc(), metrics.Labels{"level": "critical"}, float64(criticals))
+	snapshot.SetGauge(verbosityGauge.Desc(), metrics.Labels{"destination": "stdout"}, float64(shellVerbosity))
+	snapshot.SetGauge(verbosityGauge.Desc(), metrics.Labels{"destination": "stderr"}, float64(stderrVerbosity))
+	snapshot.SetGauge(verbosityGauge.Desc(), metrics.Labels{"destination": "file"}, float64(fileVerbosity))
+	snapshot.SetGauge(errorCodeGauge.Desc(), nil, float64(code))
+	if err := snapshot.WriteMetrics(writer); err != nil {
+		return err
+	}
+	_, err := io.WriteString(writer, "# EOF\n")
+	return err
+}
+
+// MetricsHandler returns an http.Handler that serves the output of WriteMetrics
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.Header().Set("Content-Type", OPENMETRICS_CONTENT_TYPE)
+		_ = WriteMetrics(writer)
+	})
+}
+
+type MetricsServer struct {
+	listener net.Listener
+	server   *http.Server
+}
+
+/*
+ * StartMetricsServer serves the metrics at /metrics on address, or on
+ * DEFAULT_METRICS_ADDRESS if address is empty, until Close is called.  The
+ * server is never started unless a utility calls this function.
+ */
+func StartMetricsServer(address string) (*MetricsServer, error) {
+	if address == "" {
+		address = DEFAULT_METRICS_ADDRESS
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to start metrics server on %s", address)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", MetricsHandler())
+	metricsServer := &MetricsServer{listener: listener, server: &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}}
+	go func() {
+		_ = metricsServer.server.Serve(listener)
+	}()
+	return metricsServer, nil
+}
+
+// Addr returns the address the server is listening on, which is useful if it was started on port 0
+func (metricsServer *MetricsServer) Addr() string {
+	return metricsServer.listener.Addr().String()
+}
+
+func (metricsServer *MetricsServer) Close() error {
+	return metricsServer.server.Close()
+}
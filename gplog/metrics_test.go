@@ -0,0 +1,163 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gplog_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/metrics"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("logger/metrics tests", func() {
+	metricValue := func(metrics string, name string) int {
+		match := regexp.MustCompile(regexp.QuoteMeta(name) + ` (-?\d+)\n`).FindStringSubmatch(metrics)
+		Expect(match).ToNot(BeNil(), "metric %s not found", name)
+		value, err := strconv.Atoi(match[1])
+		Expect(err).ToNot(HaveOccurred())
+		return value
+	}
+	currentMetrics := func() string {
+		var buffer bytes.Buffer
+		Expect(gplog.WriteMetrics(&buffer)).To(Succeed())
+		return buffer.String()
+	}
+
+	BeforeEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		testhelper.SetupTestLogger()
+		gplog.SetErrorCode(0)
+	})
+	AfterEach(func() {
+		gplog.SetErrorCode(0)
+	})
+
+	Describe("WriteMetrics", func() {
+		It("counts warnings, errors, and fatal errors", func() {
+			before := currentMetrics()
+			gplog.Warn("warning")
+			gplog.Warn("warning")
+			gplog.Error("error")
+			func() {
+				defer func() { _ = recover() }()
+				gplog.Fatal(nil, "fatal")
+			}()
+			after := currentMetrics()
+			Expect(metricValue(after, `gplog_messages_total{level="warning"}`) - metricValue(before, `gplog_messages_total{level="warning"}`)).To(Equal(2))
+			Expect(metricValue(after, `gplog_messages_total{level="error"}`) - metricValue(before, `gplog_messages_total{level="error"}`)).To(Equal(1))
+			Expect(metricValue(after, `gplog_messages_total{level="critical"}`) - metricValue(before, `gplog_messages_total{level="critical"}`)).To(Equal(1))
+			Expect(metricValue(after, "gplog_error_code")).To(Equal(2))
+		})
+		It("reports the current verbosity of each destination", func() {
+			gplog.SetVerbosity(gplog.LOGVERBOSE)
+			gplog.SetStreamVerbosity(gplog.STREAM_STDERR, gplog.LOGNONE)
+			metrics := currentMetrics()
+			Expect(metricValue(metrics, `gplog_verbosity{destination="stdout"}`)).To(Equal(gplog.LOGVERBOSE))
+			Expect(metricValue(metrics, `gplog_verbosity{destination="stderr"}`)).To(Equal(gplog.LOGNONE))
+			Expect(metricValue(metrics, `gplog_verbosity{destination="file"}`)).To(Equal(gplog.LOGDEBUG))
+		})
+		It("declares each metric family and ends with an EOF marker", func() {
+			metrics := currentMetrics()
+			Expect(metrics).To(ContainSubstring("# TYPE gplog_messages counter\n"))
+			Expect(metrics).To(ContainSubstring("# TYPE gplog_verbosity gauge\n"))
+			Expect(metrics).To(ContainSubstring("# TYPE gplog_error_code gauge\n"))
+			Expect(metrics).To(HaveSuffix("# EOF\n"))
+		})
+	})
+	Describe("exporting with the metrics package", func() {
+		It("counts warnings, errors, and fatal errors", func() {
+			exporter := metrics.NewPrometheusExporter()
+			metrics.SetExporter(exporter)
+			defer metrics.SetExporter(nil)
+			gplog.Warn("warning")
+			gplog.Error("error")
+			gplog.Info("info")
+			var buffer bytes.Buffer
+			Expect(exporter.WriteMetrics(&buffer)).To(Succeed())
+			Expect(buffer.String()).To(Equal(`# TYPE gplog_messages counter
+# HELP gplog_messages Messages logged at each level since the process started.
+gplog_messages_total{level="error"} 1
+gplog_messages_total{level="warning"} 1
+`))
+		})
+		It("describes the messages family the same way as WriteMetrics", func() {
+			exporter := metrics.NewPrometheusExporter()
+			metrics.SetExporter(exporter)
+			defer metrics.SetExporter(nil)
+			gplog.Warn("warning")
+			var buffer bytes.Buffer
+			Expect(exporter.WriteMetrics(&buffer)).To(Succeed())
+			Expect(currentMetrics()).To(ContainSubstring(buffer.String()[:strings.Index(buffer.String(), "gplog_messages_total")]))
+		})
+	})
+	Describe("StartMetricsServer", func() {
+		testhelper.DetectLeaks(testhelper.LeakOptions{})
+
+		It("serves the metrics over HTTP", func() {
+			server, err := gplog.StartMetricsServer("127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+			defer server.Close()
+
+			response, err := http.Get("http://" + server.Addr() + "/metrics")
+			Expect(err).ToNot(HaveOccurred())
+			defer response.Body.Close()
+			body, err := io.ReadAll(response.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response.StatusCode).To(Equal(http.StatusOK))
+			Expect(response.Header.Get("Content-Type")).To(Equal(gplog.OPENMETRICS_CONTENT_TYPE))
+			Expect(string(body)).To(ContainSubstring("gplog_error_code 0\n"))
+		})
+		It("returns an error if the address is in use", func() {
+			server, err := gplog.StartMetricsServer("127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+			defer server.Close()
+			_, err = gplog.StartMetricsServer(server.Addr())
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unable to start metrics server on " + server.Addr()))
+		})
+		It("is reported as leaked until it is closed", func() {
+			snapshot := testhelper.SnapshotResources()
+			server, err := gplog.StartMetricsServer("127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+
+			leaks := snapshot.Leaks(testhelper.LeakOptions{})
+			Expect(leaks).To(ContainElement(MatchRegexp(`^goroutine \d+ \[.+\] in .+, created by github.com/apache/cloudberry-go-libs/gplog.StartMetricsServer$`)))
+			Expect(leaks).To(ContainElement(HavePrefix("file descriptor ")))
+			Expect(snapshot.Leaks(testhelper.LeakOptions{IgnoreGoroutines: []string{"gplog.StartMetricsServer"}})).ToNot(ContainElement(HavePrefix("goroutine ")))
+			messages := InterceptGomegaFailures(func() {
+				snapshot.ExpectNoLeaks(testhelper.LeakOptions{Timeout: 50 * time.Millisecond})
+			})
+			Expect(messages).To(HaveLen(1))
+			Expect(messages[0]).To(ContainSubstring("Goroutines or file descriptors were leaked"))
+
+			Expect(server.Close()).To(Succeed())
+			snapshot.ExpectNoLeaks(testhelper.LeakOptions{})
+		})
+	})
+})
@@ -0,0 +1,117 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gplog
+
+/*
+ * This file contains the path that every message takes to stdout, stderr, and
+ * the log file, which can hand the writes to a single writer goroutine so that
+ * they reach all of those in the order the messages were logged.
+ */
+
+import (
+	"log"
+	"sync"
+)
+
+// The number of writes that can be queued before output functions wait for the writer goroutine
+const SERIALIZED_OUTPUT_QUEUE_SIZE = 1024
+
+type queuedWrite struct {
+	sequence uint64
+	target   *log.Logger
+	message  string
+}
+
+var (
+	/*
+	 * outputMutex guards the sequence numbers below.  It is separate from
+	 * logMutex so that the writer goroutine never waits on an output function,
+	 * which holds logMutex while it waits for room in the queue.
+	 */
+	outputMutex sync.Mutex
+	outputCond  = sync.NewCond(&outputMutex)
+	// The sequence number of the last write queued, and of the last write the writer goroutine has finished
+	queuedSequence  uint64
+	writtenSequence uint64
+	// The queue read by the writer goroutine, or nil if output is not serialized
+	outputQueue chan queuedWrite
+)
+
+/*
+ * SetSerializedOutput controls whether messages are written by a single
+ * writer goroutine.  Output functions are safe to call from any goroutine
+ * either way, but stdout and stderr are written by separate calls, so lines on
+ * the two streams can be reordered by the time they reach a terminal or a CI
+ * log that captures both.  With serialized output, every write is given a
+ * sequence number and queued, and the writer goroutine writes them to every
+ * stream strictly in that order.
+ *
+ * Flush, Fatal, and FatalWithoutPanic wait for queued messages to be written
+ * before returning, panicking, or exiting.  Turning serialized output off
+ * waits for the queue to empty and stops the writer goroutine.
+ */
+func SetSerializedOutput(serialized bool) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	if serialized && outputQueue == nil {
+		outputQueue = make(chan queuedWrite, SERIALIZED_OUTPUT_QUEUE_SIZE)
+		go writeQueuedOutput(outputQueue)
+	} else if !serialized && outputQueue != nil {
+		close(outputQueue)
+		waitForOutput()
+		outputQueue = nil
+	}
+}
+
+func GetSerializedOutput() bool {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	return outputQueue != nil
+}
+
+func writeQueuedOutput(queue chan queuedWrite) {
+	for write := range queue {
+		_ = write.target.Output(1, write.message)
+		outputMutex.Lock()
+		writtenSequence = write.sequence
+		outputCond.Broadcast()
+		outputMutex.Unlock()
+	}
+}
+
+// output writes message to target, or queues it for the writer goroutine if output is serialized; callers must hold logMutex
+func output(target *log.Logger, message string) {
+	if outputQueue == nil {
+		_ = target.Output(1, message)
+		return
+	}
+	outputMutex.Lock()
+	queuedSequence++
+	sequence := queuedSequence
+	outputMutex.Unlock()
+	outputQueue <- queuedWrite{sequence: sequence, target: target, message: message}
+}
+
+// waitForOutput returns once every queued write has been written; callers must hold logMutex so that no more are queued meanwhile
+func waitForOutput() {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	for writtenSequence < queuedSequence {
+		outputCond.Wait()
+	}
+}
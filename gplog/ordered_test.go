@@ -0,0 +1,119 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gplog_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("logger/ordered tests", func() {
+	var (
+		terminal  *gbytes.Buffer
+		logfile   *gbytes.Buffer
+		oldLogger *gplog.GpLogger
+	)
+
+	BeforeEach(func() {
+		oldLogger = gplog.GetLogger()
+		terminal, logfile = gbytes.NewBuffer(), gbytes.NewBuffer()
+		gplog.SetLogger(gplog.NewLogger(terminal, terminal, logfile, "gbytes.Buffer", gplog.LOGINFO, "testProgram"))
+		gplog.SetSerializedOutput(true)
+		gplog.SetErrorCode(0)
+	})
+	AfterEach(func() {
+		gplog.SetSerializedOutput(false)
+		gplog.SetLogger(oldLogger)
+		gplog.SetErrorCode(0)
+	})
+
+	Describe("SetSerializedOutput", func() {
+		It("writes stdout and stderr lines in the order they were logged", func() {
+			for i := 0; i < 100; i++ {
+				if i%3 == 0 {
+					gplog.Error("message %d", i)
+				} else {
+					gplog.Info("message %d", i)
+				}
+			}
+			gplog.Flush()
+
+			lines := strings.Split(strings.TrimSpace(string(terminal.Contents())), "\n")
+			Expect(lines).To(HaveLen(100))
+			for i, line := range lines {
+				Expect(line).To(HaveSuffix(fmt.Sprintf(":-message %d", i)))
+			}
+			Expect(string(logfile.Contents())).To(ContainSubstring("message 99"))
+		})
+		It("keeps each goroutine's messages in order when logging concurrently", func() {
+			var wg sync.WaitGroup
+			for g := 0; g < 4; g++ {
+				wg.Add(1)
+				go func(g int) {
+					defer wg.Done()
+					for i := 0; i < 50; i++ {
+						gplog.Info("goroutine %d message %d", g, i)
+					}
+				}(g)
+			}
+			wg.Wait()
+			gplog.Flush()
+
+			next := make(map[int]int)
+			for _, line := range strings.Split(strings.TrimSpace(string(terminal.Contents())), "\n") {
+				var g, i int
+				_, err := fmt.Sscanf(line[strings.Index(line, ":-")+2:], "goroutine %d message %d", &g, &i)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(i).To(Equal(next[g]))
+				next[g]++
+			}
+			Expect(next).To(Equal(map[int]int{0: 50, 1: 50, 2: 50, 3: 50}))
+		})
+		It("writes queued messages before Fatal panics", func() {
+			gplog.Info("before fatal")
+			defer func() {
+				Expect(string(logfile.Contents())).To(ContainSubstring("before fatal"))
+			}()
+			defer testhelper.ShouldPanicWithCritical(logfile, "fatal error")
+			gplog.Fatal(errors.New("fatal error"), "")
+		})
+		It("writes queued messages before FatalWithoutPanic exits", func() {
+			Expect(testhelper.CaptureExit(func() { gplog.FatalWithoutPanic("fatal message") })).To(Equal(1))
+			Expect(string(terminal.Contents())).To(ContainSubstring("[CRITICAL]:-fatal message"))
+		})
+		It("writes all queued messages when turned off", func() {
+			for i := 0; i < 10; i++ {
+				gplog.Info("message %d", i)
+			}
+			gplog.SetSerializedOutput(false)
+			Expect(gplog.GetSerializedOutput()).To(BeFalse())
+			Expect(strings.Count(string(terminal.Contents()), "\n")).To(Equal(10))
+			gplog.Info("direct message")
+			Expect(string(terminal.Contents())).To(HaveSuffix(":-direct message\n"))
+		})
+	})
+})
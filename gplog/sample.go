@@ -0,0 +1,55 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gplog
+
+/*
+ * This file contains functions for logging only some of the messages from
+ * a call site that is reached too often to log every time, such as logging
+ * for each row in a data path.
+ */
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// The number of times each DebugSampled call site has been reached, keyed by program counter
+var sampleCounts sync.Map
+
+/*
+ * DebugSampled logs a debug message on the first and every Nth subsequent
+ * time that its call site is reached, with " (sampled 1/N)" appended to the
+ * message, and otherwise does nothing.  Occurrences are counted for each call
+ * site separately, across all goroutines.  If every is 1 or less, every
+ * message is logged, as with Debug.
+ */
+func DebugSampled(every int, s string, v ...interface{}) {
+	if every <= 1 {
+		Debug(s, v...)
+		return
+	}
+	pc, _, _, _ := runtime.Caller(1)
+	count, _ := sampleCounts.LoadOrStore(pc, new(uint64))
+	occurrence := atomic.AddUint64(count.(*uint64), 1)
+	if (occurrence-1)%uint64(every) != 0 {
+		return
+	}
+	Debug("%s (sampled 1/%d)", fmt.Sprintf(s, v...), every)
+}
@@ -0,0 +1,78 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gplog_test
+
+import (
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("logger/sample tests", func() {
+	var (
+		logfile   *gbytes.Buffer
+		oldLogger *gplog.GpLogger
+	)
+
+	BeforeEach(func() {
+		oldLogger = gplog.GetLogger()
+		_, _, logfile = testhelper.SetupTestLogger()
+	})
+	AfterEach(func() {
+		gplog.SetLogger(oldLogger)
+	})
+
+	Describe("DebugSampled", func() {
+		It("logs the first and every Nth occurrence with a sampling annotation", func() {
+			for i := 0; i < 10; i++ {
+				gplog.DebugSampled(4, "row %d", i)
+			}
+			lines := strings.Split(strings.TrimSpace(string(logfile.Contents())), "\n")
+			Expect(lines).To(HaveLen(3))
+			Expect(lines[0]).To(HaveSuffix("[DEBUG]:-row 0 (sampled 1/4)"))
+			Expect(lines[1]).To(HaveSuffix("[DEBUG]:-row 4 (sampled 1/4)"))
+			Expect(lines[2]).To(HaveSuffix("[DEBUG]:-row 8 (sampled 1/4)"))
+		})
+		It("counts each call site separately", func() {
+			for i := 0; i < 3; i++ {
+				gplog.DebugSampled(3, "first site %d", i)
+				gplog.DebugSampled(3, "second site %d", i)
+			}
+			Expect(logfile).To(gbytes.Say(`first site 0 \(sampled 1/3\)`))
+			Expect(logfile).To(gbytes.Say(`second site 0 \(sampled 1/3\)`))
+			Expect(string(logfile.Contents())).ToNot(ContainSubstring("site 1"))
+		})
+		It("logs every message without an annotation if every is 1", func() {
+			for i := 0; i < 3; i++ {
+				gplog.DebugSampled(1, "row %d", i)
+			}
+			Expect(strings.Count(string(logfile.Contents()), "[DEBUG]:-row")).To(Equal(3))
+			Expect(string(logfile.Contents())).ToNot(ContainSubstring("sampled"))
+		})
+		It("does not log to the shell below debug verbosity", func() {
+			stdout, _, _ := testhelper.SetupTestLogger()
+			gplog.DebugSampled(2, "row")
+			Expect(string(stdout.Contents())).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,62 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gplog
+
+/*
+ * This file contains functions for flushing the log and logging consistently
+ * when a utility is interrupted by a signal.
+ */
+
+import (
+	"os"
+
+	"github.com/apache/cloudberry-go-libs/operating"
+)
+
+/*
+ * Flush waits for any messages queued by SetSerializedOutput to be written,
+ * then commits the log file to stable storage, if the log file supports it,
+ * so that the last messages before an exit are not lost.
+ */
+func Flush() {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	waitForOutput()
+	if logger == nil || logger.logFile == nil {
+		return
+	}
+	if syncer, ok := logger.logFile.Writer().(interface{ Sync() error }); ok {
+		_ = syncer.Sync()
+	}
+}
+
+/*
+ * InstallSignalCleanup installs an operating.SignalCleanup for the given
+ * signals, or SIGINT and SIGTERM if none are given, that logs a warning naming
+ * the signal and flushes the log file after running the other cleanup steps.
+ * Callers add their own contexts and cleanup functions to the returned chain.
+ */
+func InstallSignalCleanup(signals ...os.Signal) *operating.SignalCleanup {
+	chain := operating.NewSignalCleanup()
+	chain.OnSignal = func(sig os.Signal) {
+		Warn("Received %s signal, cleaning up", sig)
+	}
+	chain.AddFlush(Flush)
+	chain.Install(signals...)
+	return chain
+}
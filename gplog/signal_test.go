@@ -0,0 +1,128 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gplog_test
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("logger/signal tests", func() {
+	var (
+		stdout       *gbytes.Buffer
+		notified     chan<- os.Signal
+		notifiedSigs []os.Signal
+		resetSigs    []os.Signal
+		exitCodes    chan int
+		steps        []string
+		recordStep   func(name string) func()
+	)
+
+	BeforeEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		stdout, _, _ = testhelper.SetupTestLogger()
+		notified = nil
+		notifiedSigs = nil
+		resetSigs = nil
+		exitCodes = make(chan int, 1)
+		operating.System.NotifySignals = func(c chan<- os.Signal, sig ...os.Signal) {
+			notified = c
+			notifiedSigs = sig
+		}
+		operating.System.ResetSignals = func(sig ...os.Signal) { resetSigs = sig }
+		operating.System.Exit = func(code int) { exitCodes <- code }
+		steps = make([]string, 0)
+		recordStep = func(name string) func() {
+			return func() { steps = append(steps, name) }
+		}
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("SignalCleanup", func() {
+		It("cancels contexts, then runs cleanups, then flushes, in the order added", func() {
+			chain := operating.NewSignalCleanup()
+			ctx, cancel := context.WithCancel(context.Background())
+			chain.AddFlush(recordStep("flush"))
+			chain.AddCleanup(recordStep("cleanup 1"))
+			chain.AddCancel(func() {
+				cancel()
+				steps = append(steps, "cancel")
+			})
+			chain.AddCleanup(recordStep("cleanup 2"))
+			chain.Run()
+			Expect(ctx.Err()).To(Equal(context.Canceled))
+			Expect(steps).To(Equal([]string{"cancel", "cleanup 1", "cleanup 2", "flush"}))
+		})
+		It("only runs the chain once", func() {
+			chain := operating.NewSignalCleanup()
+			chain.AddCleanup(recordStep("cleanup"))
+			chain.Run()
+			chain.Run()
+			Expect(steps).To(Equal([]string{"cleanup"}))
+		})
+		It("runs the remaining steps if one panics", func() {
+			chain := operating.NewSignalCleanup()
+			chain.AddCleanup(func() { panic("cleanup failed") })
+			chain.AddFlush(recordStep("flush"))
+			chain.Run()
+			Expect(steps).To(Equal([]string{"flush"}))
+		})
+		It("handles SIGINT and SIGTERM by default", func() {
+			chain := operating.NewSignalCleanup()
+			chain.Install()
+			defer chain.Stop()
+			Expect(notifiedSigs).To(Equal([]os.Signal{syscall.SIGINT, syscall.SIGTERM}))
+		})
+		It("restores default signal handling when stopped", func() {
+			chain := operating.NewSignalCleanup()
+			chain.Install(syscall.SIGHUP)
+			chain.Stop()
+			Expect(resetSigs).To(Equal([]os.Signal{syscall.SIGHUP}))
+		})
+		It("runs the chain and exits when a signal is received", func() {
+			chain := operating.NewSignalCleanup()
+			chain.AddCleanup(recordStep("cleanup"))
+			chain.Install()
+			notified <- syscall.SIGINT
+			Eventually(exitCodes, time.Second).Should(Receive(Equal(130)))
+			Expect(steps).To(Equal([]string{"cleanup"}))
+		})
+	})
+	Describe("InstallSignalCleanup", func() {
+		It("logs the signal before cleaning up", func() {
+			chain := gplog.InstallSignalCleanup()
+			chain.AddCleanup(func() { gplog.Info("Removing temporary files") })
+			notified <- syscall.SIGTERM
+			Eventually(exitCodes, time.Second).Should(Receive(Equal(143)))
+			Expect(stdout).To(gbytes.Say(`\[WARNING\]:-Received terminated signal, cleaning up`))
+			Expect(stdout).To(gbytes.Say(`Removing temporary files`))
+		})
+	})
+})
@@ -0,0 +1,100 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gplog
+
+/*
+ * This file contains functions for labelling the messages logged by a
+ * goroutine, so that the output of parallel workers can be told apart.
+ */
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// Worker labels keyed by goroutine id, and how many there are, so that unlabelled programs need not look up their goroutine id
+	workerLabels     sync.Map
+	workerLabelCount int64
+)
+
+/*
+ * SetWorkerLabel labels every message subsequently logged by the calling
+ * goroutine, e.g. "worker-7" or "content-12", by adding "[label] " to the end
+ * of its log prefix.  Labels belong to the goroutine that set them and are
+ * not inherited by goroutines it starts.  An empty label removes the label;
+ * a worker goroutine should remove its label before it exits, or use
+ * WithWorkerLabel, as goroutine ids may be reused.
+ */
+func SetWorkerLabel(label string) {
+	id := goroutineID()
+	if label == "" {
+		if _, loaded := workerLabels.LoadAndDelete(id); loaded {
+			atomic.AddInt64(&workerLabelCount, -1)
+		}
+		return
+	}
+	if _, loaded := workerLabels.Swap(id, label); !loaded {
+		atomic.AddInt64(&workerLabelCount, 1)
+	}
+}
+
+// GetWorkerLabel returns the label set by the calling goroutine, or "" if there is none
+func GetWorkerLabel() string {
+	if atomic.LoadInt64(&workerLabelCount) == 0 {
+		return ""
+	}
+	if label, ok := workerLabels.Load(goroutineID()); ok {
+		return label.(string)
+	}
+	return ""
+}
+
+// WithWorkerLabel calls function with the calling goroutine labelled, then restores the goroutine's previous label
+func WithWorkerLabel(label string, function func()) {
+	previous := GetWorkerLabel()
+	SetWorkerLabel(label)
+	defer SetWorkerLabel(previous)
+	function()
+}
+
+func withWorkerLabel(prefix string) string {
+	if label := GetWorkerLabel(); label != "" {
+		return prefix + "[" + label + "] "
+	}
+	return prefix
+}
+
+/*
+ * goroutineID returns the id of the calling goroutine.  Go deliberately does
+ * not expose it, so it is read from the first line of the goroutine's stack
+ * trace, which has the form "goroutine 123 [running]:".
+ */
+func goroutineID() uint64 {
+	var buffer [64]byte
+	stack := buffer[:runtime.Stack(buffer[:], false)]
+	stack = bytes.TrimPrefix(stack, []byte("goroutine "))
+	if end := bytes.IndexByte(stack, ' '); end >= 0 {
+		stack = stack[:end]
+	}
+	id, _ := strconv.ParseUint(string(stack), 10, 64)
+	return id
+}
@@ -0,0 +1,107 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gplog_test
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("logger/worker tests", func() {
+	var (
+		stdout    *gbytes.Buffer
+		logfile   *gbytes.Buffer
+		oldLogger *gplog.GpLogger
+	)
+
+	BeforeEach(func() {
+		oldLogger = gplog.GetLogger()
+		stdout, _, logfile = testhelper.SetupTestLogger()
+	})
+	AfterEach(func() {
+		gplog.SetWorkerLabel("")
+		gplog.SetShellLogPrefixFunc(nil)
+		gplog.SetLogger(oldLogger)
+	})
+
+	Describe("SetWorkerLabel", func() {
+		It("adds the label to the end of the log prefix", func() {
+			gplog.SetWorkerLabel("worker-7")
+			gplog.Info("copying table")
+			Expect(logfile).To(gbytes.Say(`\[INFO\]:-\[worker-7\] copying table\n`))
+			Expect(stdout).To(gbytes.Say(`\[INFO\]:-\[worker-7\] copying table\n`))
+		})
+		It("adds the label to a custom shell prefix", func() {
+			gplog.SetShellLogPrefixFunc(gplog.DefaultShortLogPrefixFunc)
+			gplog.SetWorkerLabel("content-12")
+			gplog.Info("copying table")
+			Expect(string(stdout.Contents())).To(Equal("[content-12] copying table\n"))
+		})
+		It("removes the label when given an empty label", func() {
+			gplog.SetWorkerLabel("worker-7")
+			gplog.SetWorkerLabel("")
+			Expect(gplog.GetWorkerLabel()).To(Equal(""))
+			gplog.Info("copying table")
+			Expect(logfile).To(gbytes.Say(`\[INFO\]:-copying table\n`))
+		})
+		It("labels only the goroutine that set the label", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 4; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					gplog.WithWorkerLabel(fmt.Sprintf("worker-%d", i), func() {
+						for j := 0; j < 10; j++ {
+							gplog.Info("worker %d message %d", i, j)
+						}
+					})
+				}(i)
+			}
+			wg.Wait()
+			gplog.Info("main message")
+
+			lines := strings.Split(strings.TrimSpace(string(logfile.Contents())), "\n")
+			Expect(lines).To(HaveLen(41))
+			labelled := regexp.MustCompile(`:-\[worker-(\d)\] worker (\d) message`)
+			for _, line := range lines[:40] {
+				match := labelled.FindStringSubmatch(line)
+				Expect(match).To(HaveLen(3), line)
+				Expect(match[1]).To(Equal(match[2]))
+			}
+			Expect(lines[40]).To(HaveSuffix(":-main message"))
+		})
+	})
+	Describe("WithWorkerLabel", func() {
+		It("restores the previous label afterwards", func() {
+			gplog.SetWorkerLabel("worker-1")
+			gplog.WithWorkerLabel("worker-1 retry", func() {
+				Expect(gplog.GetWorkerLabel()).To(Equal("worker-1 retry"))
+			})
+			Expect(gplog.GetWorkerLabel()).To(Equal("worker-1"))
+		})
+	})
+})
@@ -0,0 +1,135 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gplog
+
+/*
+ * This file contains an io.Writer that logs what is written to it line by
+ * line, for capturing the output of external commands in the log.
+ */
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Longer lines are logged in pieces of this size, so that output with no newlines cannot use unbounded memory
+const MAX_WRITER_LINE_LENGTH = 64 * 1024
+
+/*
+ * A LogWriter logs each line written to it at a fixed level, with its source
+ * in brackets at the start, e.g. "[gpstart stderr] Starting segments".  It
+ * holds on to an incomplete line until the rest of it is written, so lines
+ * are logged whole however the output is split between writes, and is safe
+ * to use as both the Stdout and Stderr of an exec.Cmd.  Close must be called
+ * once the command finishes, to log any final line without a newline.
+ */
+type LogWriter struct {
+	mutex   sync.Mutex
+	level   int
+	source  string
+	partial []byte
+}
+
+/*
+ * NewWriterAt returns a LogWriter that logs at level, which is one of
+ * LOGERROR, LOGINFO, LOGVERBOSE, or LOGDEBUG; lines logged at LOGERROR are
+ * logged with Error, so they set the error code.  source may be empty, in
+ * which case lines are logged without a label.
+ */
+func NewWriterAt(level int, source string) *LogWriter {
+	return &LogWriter{level: level, source: source}
+}
+
+// NewLevelWriter returns a LogWriter that logs each line at level without a source label
+func NewLevelWriter(level int) *LogWriter {
+	return NewWriterAt(level, "")
+}
+
+/*
+ * RouteCommandOutput sets cmd's Stdout and Stderr to LogWriters labelled
+ * "source stdout" and "source stderr", logging at the given levels, so that
+ * the output of a helper process such as pg_dump or rsync goes to the log
+ * like any other message.  It must be called before cmd is started, and the
+ * returned function must be called once cmd.Wait has returned, to log any
+ * final lines without a newline.
+ */
+func RouteCommandOutput(cmd *exec.Cmd, source string, stdoutLevel int, stderrLevel int) func() {
+	stdout := NewWriterAt(stdoutLevel, source+" stdout")
+	stderr := NewWriterAt(stderrLevel, source+" stderr")
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	return func() {
+		_ = stdout.Close()
+		_ = stderr.Close()
+	}
+}
+
+func (writer *LogWriter) Write(p []byte) (int, error) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+	data := p
+	for len(data) > 0 {
+		newline := bytes.IndexByte(data, '\n')
+		if newline < 0 {
+			writer.partial = append(writer.partial, data...)
+			for len(writer.partial) >= MAX_WRITER_LINE_LENGTH {
+				writer.logLine(writer.partial[:MAX_WRITER_LINE_LENGTH])
+				writer.partial = append([]byte{}, writer.partial[MAX_WRITER_LINE_LENGTH:]...)
+			}
+			break
+		}
+		line := data[:newline]
+		if len(writer.partial) > 0 {
+			line = append(writer.partial, line...)
+			writer.partial = nil
+		}
+		writer.logLine(line)
+		data = data[newline+1:]
+	}
+	return len(p), nil
+}
+
+// Close logs any incomplete line that has been written, and always returns nil
+func (writer *LogWriter) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+	if len(writer.partial) > 0 {
+		writer.logLine(writer.partial)
+		writer.partial = nil
+	}
+	return nil
+}
+
+// logLine must be called with the writer's mutex held
+func (writer *LogWriter) logLine(line []byte) {
+	message := strings.TrimSuffix(string(line), "\r")
+	if writer.source != "" {
+		message = "[" + writer.source + "] " + message
+	}
+	switch {
+	case writer.level <= LOGERROR:
+		Error("%s", message)
+	case writer.level == LOGINFO:
+		Info("%s", message)
+	case writer.level == LOGVERBOSE:
+		Verbose("%s", message)
+	default:
+		Debug("%s", message)
+	}
+}
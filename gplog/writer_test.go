@@ -0,0 +1,136 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gplog_test
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("logger/writer tests", func() {
+	var (
+		stdout    *gbytes.Buffer
+		logfile   *gbytes.Buffer
+		oldLogger *gplog.GpLogger
+	)
+
+	BeforeEach(func() {
+		oldLogger = gplog.GetLogger()
+		stdout, _, logfile = testhelper.SetupTestLogger()
+		gplog.SetErrorCode(0)
+	})
+	AfterEach(func() {
+		gplog.SetLogger(oldLogger)
+		gplog.SetErrorCode(0)
+	})
+
+	Describe("NewWriterAt", func() {
+		It("logs each complete line with its source, however the lines are split between writes", func() {
+			writer := gplog.NewWriterAt(gplog.LOGINFO, "gpstart stdout")
+			fmt.Fprint(writer, "first li")
+			Expect(logfile.Contents()).To(BeEmpty())
+			fmt.Fprint(writer, "ne\r\nsecond line\nthi")
+			fmt.Fprint(writer, "rd line")
+			Expect(writer.Close()).To(Succeed())
+
+			Expect(logfile).To(gbytes.Say(`\[INFO\]:-\[gpstart stdout\] first line\n`))
+			Expect(logfile).To(gbytes.Say(`\[INFO\]:-\[gpstart stdout\] second line\n`))
+			Expect(logfile).To(gbytes.Say(`\[INFO\]:-\[gpstart stdout\] third line\n`))
+			Expect(stdout).To(gbytes.Say(`second line`))
+		})
+		It("logs at the given level", func() {
+			verboseWriter := gplog.NewWriterAt(gplog.LOGVERBOSE, "")
+			fmt.Fprintln(verboseWriter, "verbose output")
+			Expect(logfile).To(gbytes.Say(`\[DEBUG\]:-verbose output\n`))
+			Expect(stdout).ToNot(gbytes.Say("verbose output"))
+			Expect(gplog.GetErrorCode()).To(Equal(0))
+
+			errorWriter := gplog.NewWriterAt(gplog.LOGERROR, "psql stderr")
+			fmt.Fprintln(errorWriter, "FATAL: database does not exist")
+			Expect(logfile).To(gbytes.Say(`\[ERROR\]:-\[psql stderr\] FATAL: database does not exist\n`))
+			Expect(gplog.GetErrorCode()).To(Equal(1))
+		})
+		It("splits lines longer than the maximum length", func() {
+			writer := gplog.NewWriterAt(gplog.LOGDEBUG, "")
+			fmt.Fprint(writer, strings.Repeat("x", gplog.MAX_WRITER_LINE_LENGTH+10))
+			Expect(strings.Count(string(logfile.Contents()), "\n")).To(Equal(1))
+			Expect(writer.Close()).To(Succeed())
+			Expect(string(logfile.Contents())).To(HaveSuffix(":-xxxxxxxxxx\n"))
+		})
+		It("captures the output of a command", func() {
+			writer := gplog.NewWriterAt(gplog.LOGINFO, "child")
+			cmd := exec.Command("bash", "-c", "echo out; echo err >&2; printf 'no newline'")
+			cmd.Stdout = writer
+			cmd.Stderr = writer
+			Expect(cmd.Run()).To(Succeed())
+			Expect(writer.Close()).To(Succeed())
+			contents := string(logfile.Contents())
+			Expect(contents).To(ContainSubstring("[child] out\n"))
+			Expect(contents).To(ContainSubstring("[child] err\n"))
+			Expect(contents).To(ContainSubstring("[child] no newline\n"))
+		})
+		It("can be written to by several goroutines at once", func() {
+			writer := gplog.NewWriterAt(gplog.LOGDEBUG, "")
+			var waitGroup sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				waitGroup.Add(1)
+				go func(i int) {
+					defer waitGroup.Done()
+					for j := 0; j < 20; j++ {
+						fmt.Fprintf(writer, "line %d-%d\n", i, j)
+					}
+				}(i)
+			}
+			waitGroup.Wait()
+			Expect(strings.Count(string(logfile.Contents()), "[DEBUG]:-line ")).To(Equal(200))
+		})
+	})
+	Describe("NewLevelWriter", func() {
+		It("logs each line at the given level without a label", func() {
+			writer := gplog.NewLevelWriter(gplog.LOGVERBOSE)
+			fmt.Fprint(writer, "first line\nsecond line\n")
+			Expect(logfile).To(gbytes.Say(`\[DEBUG\]:-first line\n`))
+			Expect(logfile).To(gbytes.Say(`\[DEBUG\]:-second line\n`))
+			Expect(stdout.Contents()).To(BeEmpty())
+		})
+	})
+	Describe("RouteCommandOutput", func() {
+		It("logs a command's stdout and stderr at their own levels", func() {
+			cmd := exec.Command("bash", "-c", "echo out; echo err >&2; printf 'no newline' >&2")
+			closeOutput := gplog.RouteCommandOutput(cmd, "rsync", gplog.LOGVERBOSE, gplog.LOGINFO)
+			Expect(cmd.Run()).To(Succeed())
+			closeOutput()
+
+			contents := string(logfile.Contents())
+			Expect(contents).To(ContainSubstring("[DEBUG]:-[rsync stdout] out\n"))
+			Expect(contents).To(ContainSubstring("[INFO]:-[rsync stderr] err\n"))
+			Expect(contents).To(ContainSubstring("[INFO]:-[rsync stderr] no newline\n"))
+			Expect(string(stdout.Contents())).ToNot(ContainSubstring("rsync stdout"))
+			Expect(string(stdout.Contents())).To(ContainSubstring("[rsync stderr] err"))
+		})
+	})
+})
@@ -0,0 +1,358 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpmigrate
+
+/*
+ * This file contains a runner for versioned SQL migrations of the tables a
+ * utility keeps in the database for its own bookkeeping, such as a history
+ * of its runs, so that upgrading the utility upgrades those tables in a known
+ * order exactly once.
+ */
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/dbconn"
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/pkg/errors"
+)
+
+/*
+ * A Migration changes the utility's tables from the previous version to
+ * Version with the statements in Up, and back with those in Down.  Down may
+ * be empty if the migration cannot be reversed.  Each migration runs in its
+ * own transaction, so statements that cannot run in a transaction, such as
+ * VACUUM, cannot be used.
+ */
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+func (migration Migration) String() string {
+	return fmt.Sprintf("%d (%s)", migration.Version, migration.Name)
+}
+
+// An AppliedMigration is a row of the version table, recording a migration that has been applied
+type AppliedMigration struct {
+	Version   int       `db:"version"`
+	Name      string    `db:"name"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+/*
+ * A Migrator applies Migrations to the database given by Connection, recording
+ * each applied migration in Table.  If DryRun is set, it logs the migrations
+ * it would apply or revert, without changing the database.
+ */
+type Migrator struct {
+	Connection *dbconn.DBConn
+	Table      string
+	Migrations []Migration
+	DryRun     bool
+}
+
+var tableNameRegex = regexp.MustCompile(`^([a-z_][a-z0-9_]*\.)?[a-z_][a-z0-9_]*$`)
+
+/*
+ * New returns a Migrator for migrations, which are sorted by version.  Table
+ * is the name of the version table, optionally qualified with its schema; it
+ * should be unique to the utility, e.g. "gpbackup_migrations", and is limited
+ * to lowercase letters, digits, and underscores so that it never needs
+ * quoting.  Versions must be positive and unique, but need not be contiguous.
+ */
+func New(connection *dbconn.DBConn, table string, migrations []Migration) (*Migrator, error) {
+	if !tableNameRegex.MatchString(table) {
+		return nil, errors.Errorf("Invalid migration table name %q", table)
+	}
+	sorted := append([]Migration{}, migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i, migration := range sorted {
+		if migration.Version <= 0 {
+			return nil, errors.Errorf("Invalid version for migration %s; versions must be positive", migration)
+		}
+		if i > 0 && sorted[i-1].Version == migration.Version {
+			return nil, errors.Errorf("Migrations %s and %s have the same version", sorted[i-1], migration)
+		}
+	}
+	return &Migrator{Connection: connection, Table: table, Migrations: sorted}, nil
+}
+
+var migrationFileRegex = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+/*
+ * LoadMigrations reads migrations from the files in dir within fsys, which is
+ * typically an embed.FS compiled into the utility.  Each migration is a file
+ * named VERSION_NAME.up.sql, with an optional VERSION_NAME.down.sql to revert
+ * it, e.g. 0001_create_history.up.sql; other files are ignored.
+ */
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read migrations from %s", dir)
+	}
+	byVersion := make(map[int]*Migration)
+	versions := make([]int, 0)
+	for _, entry := range entries {
+		matches := migrationFileRegex.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || matches == nil {
+			continue
+		}
+		version, err := strconv.Atoi(matches[1])
+		if err != nil {
+			return nil, errors.Errorf("Invalid version in migration file %s", entry.Name())
+		}
+		contents, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to read migration file %s", entry.Name())
+		}
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = migration
+			versions = append(versions, version)
+		} else if migration.Name != matches[2] {
+			return nil, errors.Errorf("Migration files %s and %s have the same version", entry.Name(), migration.Name)
+		}
+		if matches[3] == "up" {
+			migration.Up = string(contents)
+		} else {
+			migration.Down = string(contents)
+		}
+	}
+	sort.Ints(versions)
+	migrations := make([]Migration, len(versions))
+	for i, version := range versions {
+		migrations[i] = *byVersion[version]
+		if migrations[i].Up == "" {
+			return nil, errors.Errorf("Migration %s has no .up.sql file", migrations[i])
+		}
+	}
+	return migrations, nil
+}
+
+func (migrator *Migrator) tableExists() (bool, error) {
+	schema, table, found := strings.Cut(migrator.Table, ".")
+	if !found {
+		schema, table = "public", migrator.Table
+	}
+	var count int
+	err := migrator.Connection.GetWithArgs(&count, `SELECT count(*) FROM pg_catalog.pg_class c
+	JOIN pg_catalog.pg_namespace n ON c.relnamespace = n.oid
+WHERE n.nspname = $1 AND c.relname = $2`, schema, table)
+	if err != nil {
+		return false, errors.Wrapf(err, "Unable to check for migration table %s", migrator.Table)
+	}
+	return count > 0, nil
+}
+
+// Applied returns the migrations that have been applied, in version order
+func (migrator *Migrator) Applied() ([]AppliedMigration, error) {
+	applied := make([]AppliedMigration, 0)
+	exists, err := migrator.tableExists()
+	if err != nil || !exists {
+		return applied, err
+	}
+	query := fmt.Sprintf("SELECT version, name, applied_at FROM %s ORDER BY version", migrator.Table)
+	if err := migrator.Connection.Select(&applied, query); err != nil {
+		return nil, errors.Wrapf(err, "Unable to read migration table %s", migrator.Table)
+	}
+	return applied, nil
+}
+
+// Version returns the version of the latest applied migration, or 0 if none have been applied
+func (migrator *Migrator) Version() (int, error) {
+	applied, err := migrator.Applied()
+	if err != nil || len(applied) == 0 {
+		return 0, err
+	}
+	return applied[len(applied)-1].Version, nil
+}
+
+func (migrator *Migrator) latest() int {
+	if len(migrator.Migrations) == 0 {
+		return 0
+	}
+	return migrator.Migrations[len(migrator.Migrations)-1].Version
+}
+
+// Pending returns the migrations that Migrate would apply, in the order it would apply them
+func (migrator *Migrator) Pending() ([]Migration, error) {
+	applied, err := migrator.Applied()
+	if err != nil {
+		return nil, err
+	}
+	up, _, err := migrator.plan(applied, migrator.latest())
+	return up, err
+}
+
+/*
+ * plan returns the migrations to apply and those to revert, in order, to
+ * reach target.  A database with a migration applied that is not among
+ * migrator.Migrations was migrated by a newer version of the utility, and
+ * cannot be migrated by this one.
+ */
+func (migrator *Migrator) plan(applied []AppliedMigration, target int) (up []Migration, down []Migration, err error) {
+	known := make(map[int]bool)
+	for _, migration := range migrator.Migrations {
+		known[migration.Version] = true
+	}
+	isApplied := make(map[int]bool)
+	for _, migration := range applied {
+		if !known[migration.Version] {
+			return nil, nil, errors.Errorf("Migration table %s records migration %d (%s), which is not known to this version; it was likely applied by a newer version", migrator.Table, migration.Version, migration.Name)
+		}
+		isApplied[migration.Version] = true
+	}
+	if target != 0 && !known[target] {
+		return nil, nil, errors.Errorf("There is no migration with version %d", target)
+	}
+	up, down = make([]Migration, 0), make([]Migration, 0)
+	for _, migration := range migrator.Migrations {
+		if migration.Version <= target && !isApplied[migration.Version] {
+			up = append(up, migration)
+		}
+	}
+	for i := len(migrator.Migrations) - 1; i >= 0; i-- {
+		migration := migrator.Migrations[i]
+		if migration.Version > target && isApplied[migration.Version] {
+			if migration.Down == "" {
+				return nil, nil, errors.Errorf("Migration %s cannot be reverted", migration)
+			}
+			down = append(down, migration)
+		}
+	}
+	return up, down, nil
+}
+
+/*
+ * Migrate applies every pending migration, and returns the migrations it
+ * applied, or in a dry run, those it would apply.
+ */
+func (migrator *Migrator) Migrate() ([]Migration, error) {
+	return migrator.MigrateTo(migrator.latest())
+}
+
+func (migrator *Migrator) MustMigrate() []Migration {
+	migrations, err := migrator.Migrate()
+	gplog.FatalOnError(err)
+	return migrations
+}
+
+/*
+ * MigrateTo applies each pending migration up to and including version, and
+ * reverts each applied migration after it, newest first, so that version 0
+ * reverts every migration.  It returns the migrations it applied or reverted,
+ * in order, or in a dry run, those it would apply or revert.  If a migration
+ * fails, those before it remain applied or reverted.
+ */
+func (migrator *Migrator) MigrateTo(version int) ([]Migration, error) {
+	applied, err := migrator.Applied()
+	if err != nil {
+		return nil, err
+	}
+	up, down, err := migrator.plan(applied, version)
+	if err != nil {
+		return nil, err
+	}
+	migrated := make([]Migration, 0, len(up)+len(down))
+	if !migrator.DryRun && len(up) > 0 {
+		if err := migrator.createTable(); err != nil {
+			return migrated, err
+		}
+	}
+	for _, migration := range down {
+		record := fmt.Sprintf("DELETE FROM %s WHERE version = %d", migrator.Table, migration.Version)
+		if err := migrator.run("revert", migration, migration.Down, record); err != nil {
+			return migrated, err
+		}
+		migrated = append(migrated, migration)
+	}
+	for _, migration := range up {
+		record := fmt.Sprintf("INSERT INTO %s (version, name) VALUES (%d, '%s')", migrator.Table, migration.Version, strings.ReplaceAll(migration.Name, "'", "''"))
+		if err := migrator.run("apply", migration, migration.Up, record); err != nil {
+			return migrated, err
+		}
+		migrated = append(migrated, migration)
+	}
+	return migrated, nil
+}
+
+func (migrator *Migrator) createTable() error {
+	exists, err := migrator.tableExists()
+	if err != nil || exists {
+		return err
+	}
+	gplog.Verbose("Creating migration table %s", migrator.Table)
+	_, err = migrator.Connection.Exec(fmt.Sprintf(`CREATE TABLE %s (
+	version integer PRIMARY KEY,
+	name text NOT NULL,
+	applied_at timestamp with time zone NOT NULL DEFAULT now()
+)`, migrator.Table))
+	return errors.Wrapf(err, "Unable to create migration table %s", migrator.Table)
+}
+
+/*
+ * run applies or reverts one migration in a transaction, along with the
+ * statement recording it in the version table.  The version table is locked
+ * and the migration recorded first, so that a second migrator running at the
+ * same time waits and then fails to record the same migration, rather than
+ * running it twice.
+ */
+func (migrator *Migrator) run(action string, migration Migration, statements string, record string) error {
+	if migrator.DryRun {
+		gplog.Info("Would %s migration %s", action, migration)
+		gplog.Verbose("%s", statements)
+		return nil
+	}
+	gplog.Info("%s migration %s", map[string]string{"apply": "Applying", "revert": "Reverting"}[action], migration)
+	connection := migrator.Connection
+	fail := func(err error) error {
+		_ = connection.Rollback()
+		return errors.Wrapf(err, "Unable to %s migration %s", action, migration)
+	}
+	if err := connection.Begin(); err != nil {
+		return errors.Wrapf(err, "Unable to %s migration %s", action, migration)
+	}
+	if _, err := connection.Exec(fmt.Sprintf("LOCK TABLE %s IN EXCLUSIVE MODE", migrator.Table)); err != nil {
+		return fail(err)
+	}
+	result, err := connection.Exec(record)
+	if err != nil {
+		return fail(err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows != 1 {
+		return fail(errors.Errorf("migration table %s has changed; another migration may be running", migrator.Table))
+	}
+	if _, err := connection.Exec(statements); err != nil {
+		return fail(err)
+	}
+	if err := connection.Commit(); err != nil {
+		return errors.Wrapf(err, "Unable to %s migration %s", action, migration)
+	}
+	return nil
+}
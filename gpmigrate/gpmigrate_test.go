@@ -0,0 +1,238 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpmigrate_test
+
+import (
+	"errors"
+	"regexp"
+	"testing/fstest"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/apache/cloudberry-go-libs/dbconn"
+	"github.com/apache/cloudberry-go-libs/gpmigrate"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("gpmigrate tests", func() {
+	var (
+		connection *dbconn.DBConn
+		mock       sqlmock.Sqlmock
+		stdout     *Buffer
+		migrations []gpmigrate.Migration
+		migrator   *gpmigrate.Migrator
+	)
+	createHistory := gpmigrate.Migration{Version: 1, Name: "create_history", Up: "CREATE TABLE gpbackup_history (id int)", Down: "DROP TABLE gpbackup_history"}
+	addStatus := gpmigrate.Migration{Version: 2, Name: "add_status", Up: "ALTER TABLE gpbackup_history ADD COLUMN status text", Down: "ALTER TABLE gpbackup_history DROP COLUMN status"}
+	addIndex := gpmigrate.Migration{Version: 5, Name: "add_index", Up: "CREATE INDEX history_idx ON gpbackup_history (id)"}
+
+	expectTableExists := func(exists bool) {
+		count := 0
+		if exists {
+			count = 1
+		}
+		mock.ExpectQuery("SELECT count\\(\\*\\) FROM pg_catalog.pg_class").WithArgs("public", "gpbackup_migrations").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+	}
+	expectApplied := func(versions ...gpmigrate.Migration) {
+		expectTableExists(true)
+		rows := sqlmock.NewRows([]string{"version", "name", "applied_at"})
+		for _, migration := range versions {
+			rows.AddRow(migration.Version, migration.Name, time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+		}
+		mock.ExpectQuery("SELECT version, name, applied_at FROM gpbackup_migrations ORDER BY version").WillReturnRows(rows)
+	}
+	expectRun := func(record string, statements string) {
+		mock.ExpectBegin()
+		testhelper.ExpectExecAffecting(mock, "SET TRANSACTION", 0)
+		testhelper.ExpectExecAffecting(mock, "LOCK TABLE gpbackup_migrations IN EXCLUSIVE MODE", 0)
+		testhelper.ExpectExecAffecting(mock, regexp.QuoteMeta(record), 1)
+		testhelper.ExpectExecAffecting(mock, regexp.QuoteMeta(statements), 0)
+		mock.ExpectCommit()
+	}
+
+	BeforeEach(func() {
+		connection, mock, stdout, _, _ = testhelper.SetupTestEnvironment()
+		migrations = []gpmigrate.Migration{addIndex, createHistory, addStatus}
+		var err error
+		migrator, err = gpmigrate.New(connection, "gpbackup_migrations", migrations)
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		testhelper.AssertAllExpectationsMet(mock)
+	})
+
+	Describe("New", func() {
+		It("sorts migrations by version", func() {
+			Expect(migrator.Migrations).To(Equal([]gpmigrate.Migration{createHistory, addStatus, addIndex}))
+		})
+		It("rejects duplicate and non-positive versions", func() {
+			_, err := gpmigrate.New(connection, "gpbackup_migrations", []gpmigrate.Migration{createHistory, {Version: 1, Name: "other"}})
+			Expect(err).To(MatchError("Migrations 1 (create_history) and 1 (other) have the same version"))
+			_, err = gpmigrate.New(connection, "gpbackup_migrations", []gpmigrate.Migration{{Version: 0, Name: "zero"}})
+			Expect(err).To(MatchError("Invalid version for migration 0 (zero); versions must be positive"))
+		})
+		It("rejects table names that would need quoting", func() {
+			_, err := gpmigrate.New(connection, "gpbackup; DROP TABLE x", migrations)
+			Expect(err).To(MatchError(`Invalid migration table name "gpbackup; DROP TABLE x"`))
+			_, err = gpmigrate.New(connection, "gpbackup.migrations", migrations)
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+	Describe("LoadMigrations", func() {
+		It("pairs up and down files by version", func() {
+			fsys := fstest.MapFS{
+				"migrations/0002_add_status.up.sql":     {Data: []byte(addStatus.Up)},
+				"migrations/0002_add_status.down.sql":   {Data: []byte(addStatus.Down)},
+				"migrations/0001_create_history.up.sql": {Data: []byte(createHistory.Up)},
+				"migrations/README.md":                  {Data: []byte("ignored")},
+			}
+			loaded, err := gpmigrate.LoadMigrations(fsys, "migrations")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(loaded).To(Equal([]gpmigrate.Migration{
+				{Version: 1, Name: "create_history", Up: createHistory.Up},
+				addStatus,
+			}))
+		})
+		It("returns an error for a migration with only a down file", func() {
+			fsys := fstest.MapFS{"migrations/0001_create_history.down.sql": {Data: []byte("DROP TABLE x")}}
+			_, err := gpmigrate.LoadMigrations(fsys, "migrations")
+			Expect(err).To(MatchError("Migration 1 (create_history) has no .up.sql file"))
+		})
+		It("returns an error for two migrations with the same version", func() {
+			fsys := fstest.MapFS{
+				"migrations/0001_create_history.up.sql": {Data: []byte("CREATE TABLE x")},
+				"migrations/0001_create_other.up.sql":   {Data: []byte("CREATE TABLE y")},
+			}
+			_, err := gpmigrate.LoadMigrations(fsys, "migrations")
+			Expect(err).To(MatchError(ContainSubstring("have the same version")))
+		})
+	})
+	Describe("Version and Pending", func() {
+		It("treats a missing migration table as no migrations applied", func() {
+			expectTableExists(false)
+			expectTableExists(false)
+			Expect(migrator.Version()).To(Equal(0))
+			Expect(migrator.Pending()).To(Equal([]gpmigrate.Migration{createHistory, addStatus, addIndex}))
+		})
+		It("returns the latest applied migration and those after it", func() {
+			expectApplied(createHistory, addStatus)
+			expectApplied(createHistory, addStatus)
+			Expect(migrator.Version()).To(Equal(2))
+			Expect(migrator.Pending()).To(Equal([]gpmigrate.Migration{addIndex}))
+		})
+		It("returns an error if a migration unknown to this version was applied", func() {
+			expectApplied(createHistory, gpmigrate.Migration{Version: 7, Name: "future"})
+			_, err := migrator.Pending()
+			Expect(err).To(MatchError(ContainSubstring("records migration 7 (future), which is not known to this version")))
+		})
+	})
+	Describe("Migrate", func() {
+		It("creates the migration table and applies every migration in order", func() {
+			expectTableExists(false)
+			expectTableExists(false)
+			testhelper.ExpectExecAffecting(mock, "CREATE TABLE gpbackup_migrations", 0)
+			expectRun("INSERT INTO gpbackup_migrations (version, name) VALUES (1, 'create_history')", createHistory.Up)
+			expectRun("INSERT INTO gpbackup_migrations (version, name) VALUES (2, 'add_status')", addStatus.Up)
+			expectRun("INSERT INTO gpbackup_migrations (version, name) VALUES (5, 'add_index')", addIndex.Up)
+			Expect(migrator.Migrate()).To(Equal([]gpmigrate.Migration{createHistory, addStatus, addIndex}))
+			Expect(stdout).To(Say(`Applying migration 1 \(create_history\)`))
+			Expect(stdout).To(Say(`Applying migration 5 \(add_index\)`))
+		})
+		It("applies only pending migrations", func() {
+			expectApplied(createHistory, addStatus)
+			expectTableExists(true)
+			expectRun("INSERT INTO gpbackup_migrations (version, name) VALUES (5, 'add_index')", addIndex.Up)
+			Expect(migrator.Migrate()).To(Equal([]gpmigrate.Migration{addIndex}))
+		})
+		It("does nothing if every migration has been applied", func() {
+			expectApplied(createHistory, addStatus, addIndex)
+			Expect(migrator.Migrate()).To(BeEmpty())
+		})
+		It("rolls back and stops at a migration that fails", func() {
+			expectApplied(createHistory)
+			expectTableExists(true)
+			mock.ExpectBegin()
+			testhelper.ExpectExecAffecting(mock, "SET TRANSACTION", 0)
+			testhelper.ExpectExecAffecting(mock, "LOCK TABLE", 0)
+			testhelper.ExpectExecAffecting(mock, "INSERT INTO gpbackup_migrations", 1)
+			mock.ExpectExec("ALTER TABLE").WillReturnError(errors.New("column \"status\" already exists"))
+			mock.ExpectRollback()
+			migrated, err := migrator.Migrate()
+			Expect(err).To(MatchError(`Unable to apply migration 2 (add_status): column "status" already exists`))
+			Expect(migrated).To(BeEmpty())
+		})
+		It("fails without running the migration if another migrator already recorded it", func() {
+			expectApplied(createHistory)
+			expectTableExists(true)
+			mock.ExpectBegin()
+			testhelper.ExpectExecAffecting(mock, "SET TRANSACTION", 0)
+			testhelper.ExpectExecAffecting(mock, "LOCK TABLE", 0)
+			mock.ExpectExec("INSERT INTO gpbackup_migrations").WillReturnError(errors.New("duplicate key value violates unique constraint"))
+			mock.ExpectRollback()
+			_, err := migrator.Migrate()
+			Expect(err).To(MatchError(ContainSubstring("Unable to apply migration 2 (add_status): duplicate key")))
+		})
+		It("only logs the migrations it would apply in a dry run", func() {
+			migrator.DryRun = true
+			expectTableExists(false)
+			Expect(migrator.Migrate()).To(Equal([]gpmigrate.Migration{createHistory, addStatus, addIndex}))
+			Expect(stdout).To(Say(`Would apply migration 1 \(create_history\)`))
+			Expect(stdout).To(Say(`Would apply migration 5 \(add_index\)`))
+		})
+	})
+	Describe("MigrateTo", func() {
+		It("reverts migrations after the target version, newest first", func() {
+			expectApplied(createHistory, addStatus)
+			expectRun("DELETE FROM gpbackup_migrations WHERE version = 2", addStatus.Down)
+			expectRun("DELETE FROM gpbackup_migrations WHERE version = 1", createHistory.Down)
+			Expect(migrator.MigrateTo(0)).To(Equal([]gpmigrate.Migration{addStatus, createHistory}))
+			Expect(stdout).To(Say(`Reverting migration 2 \(add_status\)`))
+		})
+		It("fails without running the down migration if it is no longer recorded", func() {
+			expectApplied(createHistory, addStatus)
+			mock.ExpectBegin()
+			testhelper.ExpectExecAffecting(mock, "SET TRANSACTION", 0)
+			testhelper.ExpectExecAffecting(mock, "LOCK TABLE", 0)
+			testhelper.ExpectExecAffecting(mock, "DELETE FROM gpbackup_migrations WHERE version = 2", 0)
+			mock.ExpectRollback()
+			_, err := migrator.MigrateTo(1)
+			Expect(err).To(MatchError("Unable to revert migration 2 (add_status): migration table gpbackup_migrations has changed; another migration may be running"))
+		})
+		It("refuses to revert a migration without a down migration", func() {
+			expectApplied(createHistory, addStatus, addIndex)
+			_, err := migrator.MigrateTo(2)
+			Expect(err).To(MatchError("Migration 5 (add_index) cannot be reverted"))
+		})
+		It("returns an error for an unknown target version", func() {
+			expectApplied(createHistory)
+			_, err := migrator.MigrateTo(3)
+			Expect(err).To(MatchError("There is no migration with version 3"))
+		})
+		It("applies migrations up to the target version", func() {
+			expectTableExists(false)
+			expectTableExists(false)
+			testhelper.ExpectExecAffecting(mock, "CREATE TABLE gpbackup_migrations", 0)
+			expectRun("INSERT INTO gpbackup_migrations (version, name) VALUES (1, 'create_history')", createHistory.Up)
+			Expect(migrator.MigrateTo(1)).To(Equal([]gpmigrate.Migration{createHistory}))
+		})
+	})
+})
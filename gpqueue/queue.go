@@ -0,0 +1,401 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpqueue
+
+/*
+ * This file contains a durable local work queue, for tools that process many
+ * independent items, such as one per table, and need to pick up where they
+ * left off after being interrupted.
+ *
+ * The queue is stored as an append-only log of records.  Each record is a
+ * 4-byte length and a 4-byte CRC-32C checksum, both big-endian, followed by
+ * the record body: a 1-byte type, an 8-byte item id, and the item payload, if
+ * any.  Enqueuing an item appends an enqueue record and acknowledging it
+ * appends an ack record, so replaying the log recovers the queue.
+ */
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/iohelper"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+const (
+	recordEnqueue byte = iota + 1
+	recordAck
+	// A checkpoint starts a compacted log, recording the next id to assign in its id and the number of completed items in its payload
+	recordCheckpoint
+)
+
+const recordHeaderLength = 8
+
+const recordBodyHeaderLength = 9
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+/*
+ * If Sync is set, each record is synced to disk before the call that wrote it
+ * returns, so that no acknowledged item is processed again after a power
+ * failure; otherwise records are only guaranteed to survive a crash of the
+ * process.  If CompactAfter is positive, the log is compacted automatically
+ * once that many items have been acknowledged since it was last compacted.
+ */
+type Options struct {
+	Sync         bool
+	CompactAfter int
+}
+
+type Item struct {
+	ID      uint64
+	Payload []byte
+}
+
+type Stats struct {
+	Pending    int
+	InProgress int
+	Completed  int
+}
+
+/*
+ * A Queue hands out items in the order they were enqueued.  Dequeue marks an
+ * item as in progress without writing to the log, so items that were in
+ * progress when the process stopped are pending again when the queue is
+ * reopened; each item is processed at least once, and must be acknowledged
+ * with Ack once it is done.  A Queue is safe for use by multiple goroutines,
+ * but only one Queue may have a given file open at a time.
+ *
+ * If a record cannot be written, part of it may have reached the log, and
+ * records appended after it would be lost on replay.  The queue therefore
+ * refuses further writes until Compact rewrites the log from the items in
+ * memory, or the queue is reopened, which discards the partial record.
+ */
+type Queue struct {
+	mutex          sync.Mutex
+	path           string
+	options        Options
+	writer         io.WriteCloser
+	items          map[uint64][]byte
+	pending        []uint64
+	inProgress     map[uint64]bool
+	nextID         uint64
+	completed      int
+	ackedSinceLast int
+	writeErr       error
+}
+
+/*
+ * Open opens the queue stored at path, creating it if it does not exist, and
+ * recovers its items.  A record that was only partly written when the
+ * process stopped is discarded, with a warning, and the log is rewritten
+ * without it.
+ */
+func Open(path string, options Options) (*Queue, error) {
+	queue := &Queue{
+		path:       path,
+		options:    options,
+		items:      make(map[uint64][]byte),
+		pending:    make([]uint64, 0),
+		inProgress: make(map[uint64]bool),
+		nextID:     1,
+	}
+	contents, err := operating.System.ReadFile(path)
+	if err != nil && !operating.System.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "Unable to read queue %s", path)
+	}
+	validLength := queue.replay(contents)
+	if validLength < len(contents) {
+		gplog.Warn("Discarding %d bytes of incomplete or corrupt records at the end of queue %s", len(contents)-validLength, path)
+		if err := queue.rewrite(); err != nil {
+			return nil, err
+		}
+		return queue, nil
+	}
+	queue.writer, err = iohelper.OpenFileForAppending(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to open queue %s", path)
+	}
+	return queue, nil
+}
+
+func MustOpen(path string, options Options) *Queue {
+	queue, err := Open(path, options)
+	gplog.FatalOnError(err)
+	return queue
+}
+
+/*
+ * replay applies every valid record in contents and returns the length of the
+ * valid records.  Acknowledged ids are left in pending, so that each ack does
+ * not have to search it, and are skipped by Dequeue.
+ */
+func (queue *Queue) replay(contents []byte) int {
+	offset := 0
+	for {
+		recordType, id, payload, length := decodeRecord(contents[offset:])
+		if length == 0 {
+			return offset
+		}
+		offset += length
+		switch recordType {
+		case recordEnqueue:
+			queue.items[id] = payload
+			queue.pending = append(queue.pending, id)
+			if id >= queue.nextID {
+				queue.nextID = id + 1
+			}
+		case recordAck:
+			if _, ok := queue.items[id]; ok {
+				delete(queue.items, id)
+				queue.completed++
+			}
+		case recordCheckpoint:
+			queue.nextID = id
+			if len(payload) == 8 {
+				queue.completed = int(binary.BigEndian.Uint64(payload))
+			}
+		}
+	}
+}
+
+/*
+ * decodeRecord returns the first record in data and its total length, or a
+ * length of 0 if data does not start with a complete, valid record.
+ */
+func decodeRecord(data []byte) (byte, uint64, []byte, int) {
+	if len(data) < recordHeaderLength {
+		return 0, 0, nil, 0
+	}
+	bodyLength := int(binary.BigEndian.Uint32(data[0:4]))
+	if bodyLength < recordBodyHeaderLength || len(data)-recordHeaderLength < bodyLength {
+		return 0, 0, nil, 0
+	}
+	body := data[recordHeaderLength : recordHeaderLength+bodyLength]
+	if crc32.Checksum(body, crcTable) != binary.BigEndian.Uint32(data[4:8]) {
+		return 0, 0, nil, 0
+	}
+	payload := append([]byte{}, body[recordBodyHeaderLength:]...)
+	return body[0], binary.BigEndian.Uint64(body[1:9]), payload, recordHeaderLength + bodyLength
+}
+
+func encodeRecord(recordType byte, id uint64, payload []byte) []byte {
+	record := make([]byte, recordHeaderLength+recordBodyHeaderLength+len(payload))
+	body := record[recordHeaderLength:]
+	body[0] = recordType
+	binary.BigEndian.PutUint64(body[1:9], id)
+	copy(body[recordBodyHeaderLength:], payload)
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(body)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.Checksum(body, crcTable))
+	return record
+}
+
+// syncWriter syncs writer to disk if it supports it, as an *os.File does
+func syncWriter(writer io.Writer) error {
+	if syncer, ok := writer.(interface{ Sync() error }); ok {
+		return syncer.Sync()
+	}
+	return nil
+}
+
+// append must be called with the mutex held
+func (queue *Queue) append(record []byte) error {
+	if queue.writer == nil {
+		return errors.Errorf("Queue %s is closed", queue.path)
+	}
+	if queue.writeErr != nil {
+		return errors.Wrapf(queue.writeErr, "Queue %s cannot be written until it is compacted or reopened after an earlier write failed", queue.path)
+	}
+	_, err := queue.writer.Write(record)
+	if err == nil && queue.options.Sync {
+		err = syncWriter(queue.writer)
+	}
+	if err != nil {
+		queue.writeErr = err
+		return errors.Wrapf(err, "Unable to write to queue %s", queue.path)
+	}
+	return nil
+}
+
+// Enqueue adds an item with payload to the end of the queue and returns its id
+func (queue *Queue) Enqueue(payload []byte) (uint64, error) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	id := queue.nextID
+	if err := queue.append(encodeRecord(recordEnqueue, id, payload)); err != nil {
+		return 0, err
+	}
+	queue.nextID++
+	queue.items[id] = append([]byte{}, payload...)
+	queue.pending = append(queue.pending, id)
+	return id, nil
+}
+
+/*
+ * Dequeue returns the oldest pending item and marks it as in progress, or
+ * returns false if no items are pending.  It does not wait for an item to be
+ * enqueued.
+ */
+func (queue *Queue) Dequeue() (Item, bool) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	for len(queue.pending) > 0 {
+		id := queue.pending[0]
+		queue.pending = queue.pending[1:]
+		if payload, ok := queue.items[id]; ok {
+			queue.inProgress[id] = true
+			return Item{ID: id, Payload: payload}, true
+		}
+	}
+	return Item{}, false
+}
+
+// Ack marks an in-progress item as completed, so that it is not returned again
+func (queue *Queue) Ack(id uint64) error {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	if !queue.inProgress[id] {
+		return errors.Errorf("Item %d of queue %s is not in progress", id, queue.path)
+	}
+	if err := queue.append(encodeRecord(recordAck, id, nil)); err != nil {
+		return err
+	}
+	delete(queue.inProgress, id)
+	delete(queue.items, id)
+	queue.completed++
+	queue.ackedSinceLast++
+	if queue.options.CompactAfter > 0 && queue.ackedSinceLast >= queue.options.CompactAfter {
+		return queue.rewrite()
+	}
+	return nil
+}
+
+// Release returns an in-progress item to the queue, in its original place, so that it is processed again
+func (queue *Queue) Release(id uint64) error {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	if !queue.inProgress[id] {
+		return errors.Errorf("Item %d of queue %s is not in progress", id, queue.path)
+	}
+	delete(queue.inProgress, id)
+	index := sort.Search(len(queue.pending), func(i int) bool { return queue.pending[i] > id })
+	queue.pending = append(queue.pending, 0)
+	copy(queue.pending[index+1:], queue.pending[index:])
+	queue.pending[index] = id
+	return nil
+}
+
+func (queue *Queue) Stats() Stats {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	return Stats{
+		Pending:    len(queue.items) - len(queue.inProgress),
+		InProgress: len(queue.inProgress),
+		Completed:  queue.completed,
+	}
+}
+
+/*
+ * Compact rewrites the log with only the items that have not been
+ * acknowledged, so that it does not grow without bound.  The new log is
+ * written to a temporary file and renamed over the old one, so the queue is
+ * intact if the process stops partway through.
+ */
+func (queue *Queue) Compact() error {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	if queue.writer == nil {
+		return errors.Errorf("Queue %s is closed", queue.path)
+	}
+	return queue.rewrite()
+}
+
+// rewrite must be called with the mutex held
+func (queue *Queue) rewrite() error {
+	var buffer bytes.Buffer
+	completed := make([]byte, 8)
+	binary.BigEndian.PutUint64(completed, uint64(queue.completed))
+	buffer.Write(encodeRecord(recordCheckpoint, queue.nextID, completed))
+	ids := make([]uint64, 0, len(queue.items))
+	for id := range queue.items {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		buffer.Write(encodeRecord(recordEnqueue, id, queue.items[id]))
+	}
+
+	tempPath := queue.path + ".tmp"
+	writer, err := iohelper.OpenFileForWriting(tempPath)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to compact queue %s", queue.path)
+	}
+	_, err = writer.Write(buffer.Bytes())
+	if err == nil {
+		err = syncWriter(writer)
+	}
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = operating.System.Rename(tempPath, queue.path)
+	}
+	if err != nil {
+		_ = operating.System.Remove(tempPath)
+		return errors.Wrapf(err, "Unable to compact queue %s", queue.path)
+	}
+
+	if queue.writer != nil {
+		_ = queue.writer.Close()
+		queue.writer = nil
+	}
+	queue.writer, err = iohelper.OpenFileForAppending(queue.path)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to open queue %s", queue.path)
+	}
+	queue.ackedSinceLast = 0
+	queue.writeErr = nil
+	pending := make([]uint64, 0, len(ids))
+	for _, id := range ids {
+		if !queue.inProgress[id] {
+			pending = append(pending, id)
+		}
+	}
+	queue.pending = pending
+	return nil
+}
+
+func (queue *Queue) Close() error {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	if queue.writer == nil {
+		return nil
+	}
+	err := queue.writer.Close()
+	queue.writer = nil
+	if err != nil {
+		return errors.Wrapf(err, "Unable to close queue %s", queue.path)
+	}
+	return nil
+}
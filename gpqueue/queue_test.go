@@ -0,0 +1,282 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpqueue_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/gpqueue"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("gpqueue/queue tests", func() {
+	var (
+		queuePath string
+		queue     *gpqueue.Queue
+		logfile   *gbytes.Buffer
+		oldLogger *gplog.GpLogger
+	)
+
+	enqueueAll := func(payloads ...string) {
+		for _, payload := range payloads {
+			_, err := queue.Enqueue([]byte(payload))
+			Expect(err).ToNot(HaveOccurred())
+		}
+	}
+	dequeue := func() gpqueue.Item {
+		item, ok := queue.Dequeue()
+		Expect(ok).To(BeTrue())
+		return item
+	}
+	reopen := func() {
+		Expect(queue.Close()).To(Succeed())
+		queue = gpqueue.MustOpen(queuePath, gpqueue.Options{})
+	}
+	fileSize := func() int64 {
+		info, err := os.Stat(queuePath)
+		Expect(err).ToNot(HaveOccurred())
+		return info.Size()
+	}
+
+	BeforeEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		oldLogger = gplog.GetLogger()
+		_, _, logfile = testhelper.SetupTestLogger()
+		queuePath = filepath.Join(GinkgoT().TempDir(), "work.queue")
+		var err error
+		queue, err = gpqueue.Open(queuePath, gpqueue.Options{Sync: true})
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		_ = queue.Close()
+		operating.System = operating.InitializeSystemFunctions()
+		gplog.SetLogger(oldLogger)
+	})
+
+	Describe("Enqueue and Dequeue", func() {
+		It("returns items in the order they were enqueued with increasing ids", func() {
+			enqueueAll("public.foo", "public.bar", "public.baz")
+			first, second, third := dequeue(), dequeue(), dequeue()
+			Expect(string(first.Payload)).To(Equal("public.foo"))
+			Expect(string(second.Payload)).To(Equal("public.bar"))
+			Expect(string(third.Payload)).To(Equal("public.baz"))
+			Expect(second.ID).To(BeNumerically(">", first.ID))
+			Expect(third.ID).To(BeNumerically(">", second.ID))
+			_, ok := queue.Dequeue()
+			Expect(ok).To(BeFalse())
+		})
+		It("counts pending, in-progress, and completed items", func() {
+			enqueueAll("a", "b", "c")
+			Expect(queue.Ack(dequeue().ID)).To(Succeed())
+			dequeue()
+			Expect(queue.Stats()).To(Equal(gpqueue.Stats{Pending: 1, InProgress: 1, Completed: 1}))
+		})
+		It("fails to enqueue after the queue is closed", func() {
+			Expect(queue.Close()).To(Succeed())
+			_, err := queue.Enqueue([]byte("a"))
+			Expect(err).To(MatchError(fmt.Sprintf("Queue %s is closed", queuePath)))
+		})
+		It("returns an error if a record cannot be written", func() {
+			Expect(queue.Close()).To(Succeed())
+			testhelper.NewFaultInjector(testhelper.Fault{Op: testhelper.FAULT_WRITE, Path: "work.queue"}).Install()
+			queue = gpqueue.MustOpen(queuePath, gpqueue.Options{})
+			_, err := queue.Enqueue([]byte("a"))
+			Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("Unable to write to queue %s", queuePath))))
+			Expect(queue.Stats().Pending).To(Equal(0))
+		})
+		It("refuses to write after a record is only partly written, until the queue is compacted", func() {
+			Expect(queue.Close()).To(Succeed())
+			testhelper.NewFaultInjector(testhelper.Fault{Op: testhelper.FAULT_WRITE, Path: "work.queue", AfterBytes: 25}).Install()
+			queue = gpqueue.MustOpen(queuePath, gpqueue.Options{})
+			enqueueAll("a")
+			_, err := queue.Enqueue([]byte("b"))
+			Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("Unable to write to queue %s", queuePath))))
+			Expect(fileSize()).To(Equal(int64(25)))
+
+			_, err = queue.Enqueue([]byte("c"))
+			Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("Queue %s cannot be written until it is compacted or reopened", queuePath))))
+			Expect(queue.Ack(dequeue().ID)).To(MatchError(ContainSubstring("cannot be written")))
+			Expect(fileSize()).To(Equal(int64(25)))
+
+			Expect(queue.Compact()).To(Succeed())
+			enqueueAll("d")
+			reopen()
+			Expect(string(dequeue().Payload)).To(Equal("a"))
+			Expect(string(dequeue().Payload)).To(Equal("d"))
+			_, ok := queue.Dequeue()
+			Expect(ok).To(BeFalse())
+		})
+		It("recovers the records before a partly written record when reopened", func() {
+			Expect(queue.Close()).To(Succeed())
+			testhelper.NewFaultInjector(testhelper.Fault{Op: testhelper.FAULT_WRITE, Path: "work.queue", AfterBytes: 25}).Install()
+			queue = gpqueue.MustOpen(queuePath, gpqueue.Options{})
+			enqueueAll("a")
+			_, err := queue.Enqueue([]byte("b"))
+			Expect(err).To(HaveOccurred())
+
+			operating.System = operating.InitializeSystemFunctions()
+			reopen()
+			Expect(string(dequeue().Payload)).To(Equal("a"))
+			_, ok := queue.Dequeue()
+			Expect(ok).To(BeFalse())
+			enqueueAll("c")
+			reopen()
+			Expect(queue.Stats().Pending).To(Equal(2))
+		})
+	})
+
+	Describe("Ack and Release", func() {
+		It("fails to acknowledge an item that is not in progress", func() {
+			enqueueAll("a")
+			Expect(queue.Ack(1)).To(MatchError(fmt.Sprintf("Item 1 of queue %s is not in progress", queuePath)))
+			item := dequeue()
+			Expect(queue.Ack(item.ID)).To(Succeed())
+			Expect(queue.Ack(item.ID)).ToNot(Succeed())
+		})
+		It("returns a released item to its original place in the queue", func() {
+			enqueueAll("a", "b", "c")
+			first, second := dequeue(), dequeue()
+			Expect(queue.Release(second.ID)).To(Succeed())
+			Expect(queue.Release(first.ID)).To(Succeed())
+			Expect(string(dequeue().Payload)).To(Equal("a"))
+			Expect(string(dequeue().Payload)).To(Equal("b"))
+			Expect(string(dequeue().Payload)).To(Equal("c"))
+			Expect(queue.Release(first.ID + 100)).ToNot(Succeed())
+		})
+	})
+
+	Describe("Open", func() {
+		It("recovers pending and in-progress items, and the completed count, after a restart", func() {
+			enqueueAll("a", "b", "c", "d")
+			Expect(queue.Ack(dequeue().ID)).To(Succeed())
+			inProgress := dequeue()
+			reopen()
+
+			Expect(queue.Stats()).To(Equal(gpqueue.Stats{Pending: 3, InProgress: 0, Completed: 1}))
+			Expect(dequeue()).To(Equal(inProgress))
+			Expect(string(dequeue().Payload)).To(Equal("c"))
+			id, err := queue.Enqueue([]byte("e"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(id).To(Equal(uint64(5)))
+		})
+		It("discards a partly written record at the end of the log", func() {
+			enqueueAll("a", "b")
+			Expect(queue.Close()).To(Succeed())
+			contents, err := os.ReadFile(queuePath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(os.WriteFile(queuePath, contents[:len(contents)-3], 0644)).To(Succeed())
+
+			queue = gpqueue.MustOpen(queuePath, gpqueue.Options{})
+			Expect(logfile).To(gbytes.Say("Discarding 15 bytes of incomplete or corrupt records at the end of queue"))
+			Expect(queue.Stats().Pending).To(Equal(1))
+			enqueueAll("c")
+			reopen()
+			Expect(logfile).ToNot(gbytes.Say("Discarding"))
+			Expect(string(dequeue().Payload)).To(Equal("a"))
+			Expect(string(dequeue().Payload)).To(Equal("c"))
+		})
+		It("discards a record with a bad checksum", func() {
+			enqueueAll("a", "b")
+			Expect(queue.Close()).To(Succeed())
+			contents, err := os.ReadFile(queuePath)
+			Expect(err).ToNot(HaveOccurred())
+			contents[len(contents)-1] ^= 0xff
+			Expect(os.WriteFile(queuePath, contents, 0644)).To(Succeed())
+
+			queue = gpqueue.MustOpen(queuePath, gpqueue.Options{})
+			Expect(queue.Stats().Pending).To(Equal(1))
+		})
+		It("returns an error if the log cannot be read", func() {
+			Expect(queue.Close()).To(Succeed())
+			testhelper.NewFaultInjector(testhelper.Fault{Op: testhelper.FAULT_READ_FILE, Path: "work.queue"}).Install()
+			_, err := gpqueue.Open(queuePath, gpqueue.Options{})
+			Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("Unable to read queue %s", queuePath))))
+		})
+	})
+
+	Describe("Compact", func() {
+		It("removes acknowledged items from the log and keeps everything else", func() {
+			for i := 0; i < 100; i++ {
+				enqueueAll(fmt.Sprintf("table_%d", i))
+			}
+			for i := 0; i < 98; i++ {
+				Expect(queue.Ack(dequeue().ID)).To(Succeed())
+			}
+			inProgress := dequeue()
+			sizeBefore := fileSize()
+
+			Expect(queue.Compact()).To(Succeed())
+			Expect(fileSize()).To(BeNumerically("<", sizeBefore/10))
+			Expect(queue.Stats()).To(Equal(gpqueue.Stats{Pending: 1, InProgress: 1, Completed: 98}))
+			Expect(queue.Ack(inProgress.ID)).To(Succeed())
+
+			reopen()
+			Expect(queue.Stats()).To(Equal(gpqueue.Stats{Pending: 1, InProgress: 0, Completed: 99}))
+			Expect(string(dequeue().Payload)).To(Equal("table_99"))
+			id, err := queue.Enqueue([]byte("new"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(id).To(Equal(uint64(101)))
+		})
+		It("compacts automatically after the given number of acknowledgements", func() {
+			Expect(queue.Close()).To(Succeed())
+			queue = gpqueue.MustOpen(queuePath, gpqueue.Options{CompactAfter: 10})
+			for i := 0; i < 20; i++ {
+				enqueueAll(fmt.Sprintf("table_%d", i))
+			}
+			sizeBefore := fileSize()
+			for i := 0; i < 10; i++ {
+				Expect(queue.Ack(dequeue().ID)).To(Succeed())
+			}
+			Expect(fileSize()).To(BeNumerically("<", sizeBefore))
+			_, err := os.Stat(queuePath + ".tmp")
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+
+	It("can be used by several goroutines at once", func() {
+		enqueueAll("seed")
+		var waitGroup sync.WaitGroup
+		for worker := 0; worker < 8; worker++ {
+			waitGroup.Add(1)
+			go func(worker int) {
+				defer GinkgoRecover()
+				defer waitGroup.Done()
+				for i := 0; i < 25; i++ {
+					_, err := queue.Enqueue([]byte(fmt.Sprintf("%d-%d", worker, i)))
+					Expect(err).ToNot(HaveOccurred())
+					if item, ok := queue.Dequeue(); ok {
+						Expect(queue.Ack(item.ID)).To(Succeed())
+					}
+				}
+			}(worker)
+		}
+		waitGroup.Wait()
+		stats := queue.Stats()
+		Expect(stats.Pending + stats.Completed).To(Equal(201))
+		Expect(stats.InProgress).To(Equal(0))
+	})
+})
@@ -0,0 +1,303 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpsysinfo
+
+/*
+ * This file contains structs and functions for assembling a support bundle:
+ * a single compressed archive containing the logs, cluster configuration,
+ * catalog snapshots, and config files that support needs to diagnose a
+ * problem, with secrets redacted and an index of its contents.
+ */
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/dbconn"
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/iohelper"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+/*
+ * Each of the following sources is optional.
+ * - If LogFiles is nil, the current gplog log file is included, if any.
+ * - If Cluster is set, its segment configuration is included.
+ * - If Connection is set, the result of each query in CatalogQueries, or in
+ *   DefaultCatalogQueries if CatalogQueries is nil, is included as a CSV file.
+ * - RedactPatterns are applied in addition to DefaultRedactPatterns.
+ */
+type BundleOptions struct {
+	LogFiles       []string
+	ConfigFiles    []string
+	Cluster        *cluster.Cluster
+	Connection     *dbconn.DBConn
+	CatalogQueries map[string]string
+	RedactPatterns []*regexp.Regexp
+}
+
+var DefaultCatalogQueries = map[string]string{
+	"version":                  "SELECT pg_catalog.version()",
+	"gp_segment_configuration": "SELECT * FROM gp_segment_configuration ORDER BY content, role",
+	"pg_settings":              "SELECT name, setting, unit, source FROM pg_settings ORDER BY name",
+	"pg_stat_activity":         "SELECT datname, pid, usename, application_name, state, backend_start, query_start, wait_event_type, wait_event FROM pg_stat_activity ORDER BY pid",
+	"pg_locks":                 "SELECT locktype, database, relation, pid, mode, granted FROM pg_locks ORDER BY pid",
+}
+
+/*
+ * Each redaction pattern must contain one capture group, which is kept; the
+ * rest of the match is replaced with REDACTED_VALUE.
+ */
+var DefaultRedactPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(password\s*[=:]\s*)('[^']*'|"[^"]*"|\S+)`),
+	regexp.MustCompile(`(postgres(?:ql)?://[^:/@\s]+:)[^@\s]+`),
+	regexp.MustCompile(`(?i)(secret(?:_?key)?\s*[=:]\s*)\S+`),
+}
+
+const REDACTED_VALUE = "<REDACTED>"
+
+type ManifestEntry struct {
+	Name       string `json:"name"`
+	Source     string `json:"source"`
+	Size       int    `json:"size"`
+	Redactions int    `json:"redactions"`
+	Error      string `json:"error,omitempty"`
+}
+
+type Manifest struct {
+	CreatedAt time.Time       `json:"created_at"`
+	Hostname  string          `json:"hostname"`
+	Entries   []ManifestEntry `json:"entries"`
+}
+
+// Redact replaces secrets matching any of the patterns and returns the number replaced
+func Redact(contents []byte, patterns []*regexp.Regexp) ([]byte, int) {
+	count := 0
+	for _, pattern := range patterns {
+		count += len(pattern.FindAllIndex(contents, -1))
+		contents = pattern.ReplaceAll(contents, []byte("${1}"+REDACTED_VALUE))
+	}
+	return contents, count
+}
+
+type bundleWriter struct {
+	tarWriter *tar.Writer
+	manifest  *Manifest
+	patterns  []*regexp.Regexp
+	names     map[string]bool
+}
+
+/*
+ * fileEntryName returns the name in the bundle of a file under dir, built from
+ * the file's full path so that files with the same base name in different
+ * directories, such as the postgresql.conf of each segment, do not collide.
+ * A file given more than once gets a numbered suffix for each repetition.
+ */
+func (writer *bundleWriter) fileEntryName(dir string, filename string) string {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		path = filepath.Clean(filename)
+	}
+	name := filepath.Join(dir, strings.TrimPrefix(filepath.ToSlash(path), "/"))
+	unique := name
+	for i := 2; writer.names[unique]; i++ {
+		unique = fmt.Sprintf("%s.%d", name, i)
+	}
+	writer.names[unique] = true
+	return unique
+}
+
+func (writer *bundleWriter) add(name string, source string, contents []byte, sourceErr error) error {
+	entry := ManifestEntry{Name: name, Source: source}
+	if sourceErr != nil {
+		entry.Error = sourceErr.Error()
+		writer.manifest.Entries = append(writer.manifest.Entries, entry)
+		gplog.Warn("Could not add %s to support bundle: %s", source, sourceErr)
+		return nil
+	}
+	contents, entry.Redactions = Redact(contents, writer.patterns)
+	entry.Size = len(contents)
+	writer.manifest.Entries = append(writer.manifest.Entries, entry)
+	return writer.write(name, contents)
+}
+
+func (writer *bundleWriter) write(name string, contents []byte) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(contents)),
+		ModTime: writer.manifest.CreatedAt,
+	}
+	err := writer.tarWriter.WriteHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = writer.tarWriter.Write(contents)
+	return err
+}
+
+/*
+ * CreateBundle writes a gzipped tar archive to filename containing each of
+ * the sources in options, plus a manifest.json describing the contents.
+ * Sources that cannot be read are recorded in the manifest with an error
+ * rather than causing the bundle to fail, since a partial bundle is still
+ * useful; only errors writing the bundle itself are returned.  The archive is
+ * written to filename + ".tmp" and renamed to filename once it is complete,
+ * so that such an error does not leave a truncated bundle behind.
+ */
+func CreateBundle(filename string, options BundleOptions) (*Manifest, error) {
+	hostname, _ := operating.System.Hostname()
+	manifest := &Manifest{
+		CreatedAt: operating.System.Now(),
+		Hostname:  hostname,
+		Entries:   make([]ManifestEntry, 0),
+	}
+	tempPath := filename + ".tmp"
+	fileHandle, err := iohelper.OpenFileForWriting(tempPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to write support bundle %s", filename)
+	}
+	gzipWriter := gzip.NewWriter(fileHandle)
+	writer := &bundleWriter{
+		tarWriter: tar.NewWriter(gzipWriter),
+		manifest:  manifest,
+		patterns:  append(append([]*regexp.Regexp{}, DefaultRedactPatterns...), options.RedactPatterns...),
+		names:     make(map[string]bool),
+	}
+
+	err = writer.addAll(options)
+	if err == nil {
+		var manifestContents []byte
+		manifestContents, err = json.MarshalIndent(manifest, "", "  ")
+		if err == nil {
+			err = writer.write("manifest.json", manifestContents)
+		}
+	}
+	for _, closer := range []io.Closer{writer.tarWriter, gzipWriter, fileHandle} {
+		closeErr := closer.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}
+	if err == nil {
+		err = operating.System.Rename(tempPath, filename)
+	}
+	if err != nil {
+		_ = operating.System.Remove(tempPath)
+		return nil, errors.Wrapf(err, "Unable to write support bundle %s", filename)
+	}
+	return manifest, nil
+}
+
+func (writer *bundleWriter) addAll(options BundleOptions) error {
+	logFiles := options.LogFiles
+	if logFiles == nil && gplog.GetLogger() != nil && gplog.GetLogFilePath() != "" {
+		logFiles = []string{gplog.GetLogFilePath()}
+	}
+	for _, logFile := range logFiles {
+		contents, readErr := operating.System.ReadFile(logFile)
+		if err := writer.add(writer.fileEntryName("logs", logFile), logFile, contents, readErr); err != nil {
+			return err
+		}
+	}
+	for _, configFile := range options.ConfigFiles {
+		contents, readErr := operating.System.ReadFile(configFile)
+		if err := writer.add(writer.fileEntryName("config", configFile), configFile, contents, readErr); err != nil {
+			return err
+		}
+	}
+	if options.Cluster != nil {
+		contents, readErr := json.MarshalIndent(options.Cluster.Segments, "", "  ")
+		if err := writer.add("cluster/segments.json", "cluster configuration", contents, readErr); err != nil {
+			return err
+		}
+	}
+	if options.Connection != nil {
+		queries := options.CatalogQueries
+		if queries == nil {
+			queries = DefaultCatalogQueries
+		}
+		names := make([]string, 0, len(queries))
+		for name := range queries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			contents, readErr := queryToCSV(options.Connection, queries[name])
+			if err := writer.add(fmt.Sprintf("catalog/%s.csv", name), queries[name], contents, readErr); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func queryToCSV(connection *dbconn.DBConn, query string) ([]byte, error) {
+	rows, err := connection.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	csvWriter := csv.NewWriter(&buffer)
+	_ = csvWriter.Write(columns)
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	record := make([]string, len(columns))
+	for rows.Next() {
+		err = rows.Scan(pointers...)
+		if err != nil {
+			return nil, err
+		}
+		for i, value := range values {
+			switch typedValue := value.(type) {
+			case nil:
+				record[i] = ""
+			case []byte:
+				record[i] = string(typedValue)
+			default:
+				record[i] = fmt.Sprintf("%v", typedValue)
+			}
+		}
+		_ = csvWriter.Write(record)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	csvWriter.Flush()
+	return buffer.Bytes(), csvWriter.Error()
+}
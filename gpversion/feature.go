@@ -0,0 +1,68 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpversion
+
+/*
+ * This file contains structs and functions for gating behavior on the
+ * features that each version of each flavor supports, so that utilities
+ * check for a feature by name rather than repeating version comparisons.
+ */
+
+import (
+	"github.com/pkg/errors"
+)
+
+/*
+ * A Feature is available in the versions of each flavor within that flavor's
+ * range, and not at all in flavors with no range.
+ */
+type Feature struct {
+	Name   string
+	ranges map[Flavor]Range
+}
+
+// NewFeature panics if a range is invalid, as features are declared in code
+func NewFeature(name string, ranges map[Flavor]string) Feature {
+	feature := Feature{Name: name, ranges: make(map[Flavor]Range, len(ranges))}
+	for flavor, rangeStr := range ranges {
+		feature.ranges[flavor] = MustParseRange(rangeStr)
+	}
+	return feature
+}
+
+var (
+	// Filespaces were removed in Greenplum 6 in favor of tablespaces
+	FILESPACES = NewFeature("filespaces", map[Flavor]string{GREENPLUM: "<6"})
+	// Before Greenplum 7 the storage type of a table is in pg_class.relstorage rather than given by its access method
+	RELSTORAGE = NewFeature("pg_class.relstorage", map[Flavor]string{GREENPLUM: "<7"})
+	// lock_timeout was added in PostgreSQL 9.3, which Greenplum 6 is based on
+	LOCK_TIMEOUT = NewFeature("lock_timeout", map[Flavor]string{POSTGRESQL: ">=9.3", GREENPLUM: ">=6", CLOUDBERRY: ">=1"})
+)
+
+func (version Version) Supports(feature Feature) bool {
+	versionRange, ok := feature.ranges[version.Flavor]
+	return ok && versionRange.Contains(version)
+}
+
+// CheckSupports returns an error naming the feature and version if version does not support feature
+func (version Version) CheckSupports(feature Feature) error {
+	if !version.Supports(feature) {
+		return errors.Errorf("%s is not supported in %s", feature.Name, version)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpversion_test
+
+import (
+	"github.com/apache/cloudberry-go-libs/gpversion"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("gpversion/feature tests", func() {
+	partitionedTables := gpversion.NewFeature("declarative partitioning", map[gpversion.Flavor]string{
+		gpversion.GREENPLUM:  ">=7",
+		gpversion.CLOUDBERRY: "*",
+		gpversion.POSTGRESQL: ">=10",
+	})
+	gp6 := gpversion.MustParse("PostgreSQL 9.4.26 (Greenplum Database 6.26.1 build commit:abc) on x86_64-pc-linux-gnu")
+	gp7 := gpversion.MustParse("PostgreSQL 12.12 (Greenplum Database 7.0.0 build commit:abc) on x86_64-pc-linux-gnu")
+	cbdb := gpversion.MustParse("PostgreSQL 14.4 (Apache Cloudberry 1.6.0 build commit:abc) on x86_64-pc-linux-gnu")
+	postgres := gpversion.MustParse("PostgreSQL 9.6.24 on x86_64-pc-linux-gnu")
+
+	It("supports a feature in versions within the range for their flavor", func() {
+		Expect(gp6.Supports(partitionedTables)).To(BeFalse())
+		Expect(gp7.Supports(partitionedTables)).To(BeTrue())
+		Expect(cbdb.Supports(partitionedTables)).To(BeTrue())
+		Expect(postgres.Supports(partitionedTables)).To(BeFalse())
+	})
+	It("does not support a feature in flavors with no range", func() {
+		Expect(gp6.Supports(gpversion.FILESPACES)).To(BeFalse())
+		Expect(gpversion.MustParse("4.3.33").Supports(gpversion.FILESPACES)).To(BeFalse())
+		Expect(cbdb.Supports(gpversion.RELSTORAGE)).To(BeFalse())
+		Expect(gp6.Supports(gpversion.RELSTORAGE)).To(BeTrue())
+	})
+	It("returns an error naming an unsupported feature", func() {
+		Expect(gp7.CheckSupports(partitionedTables)).To(Succeed())
+		Expect(gp6.CheckSupports(partitionedTables)).To(MatchError("declarative partitioning is not supported in Greenplum Database 6.26.1"))
+	})
+	It("panics for an invalid range", func() {
+		Expect(func() { gpversion.NewFeature("bad", map[gpversion.Flavor]string{gpversion.GREENPLUM: ">=seven"}) }).To(Panic())
+	})
+})
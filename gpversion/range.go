@@ -0,0 +1,205 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpversion
+
+/*
+ * This file contains structs and functions for matching versions against
+ * ranges such as ">=6.20 <7 || >=7.1".
+ */
+
+import (
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/pkg/errors"
+)
+
+var rangeOperators = []string{">=", "<=", "!=", "==", ">", "<", "="}
+
+/*
+ * A Range is a set of alternatives separated by "||", each of which is a list
+ * of comparisons separated by spaces, all of which must hold.  A comparison is
+ * one of the operators <, <=, >, >=, =, ==, or != followed by a version, or a
+ * version alone, which is the same as =.
+ *
+ * A partial version such as "7" or "6.20", or one ending in a wildcard such as
+ * "6.x", refers to a whole release series including its pre-releases, so
+ * "<7" does not include 7.0.0-beta.1 and ">6.20" is the same as ">=6.21".  A
+ * full version such as "7.0.0" refers only to itself, so ">=7.0.0" does not
+ * include 7.0.0-beta.1.
+ */
+type Range struct {
+	text         string
+	alternatives [][]comparison
+}
+
+type comparison func(version semver.Version) bool
+
+func ParseRange(rangeStr string) (Range, error) {
+	versionRange := Range{text: rangeStr}
+	for _, alternative := range strings.Split(rangeStr, "||") {
+		tokens := strings.Fields(alternative)
+		if len(tokens) == 0 {
+			return Range{}, errors.Errorf("Invalid version range %q: empty comparison", rangeStr)
+		}
+		comparisons := make([]comparison, 0)
+		for i := 0; i < len(tokens); i++ {
+			token := tokens[i]
+			// Allow a space between an operator and its version, as in ">= 6"
+			if isOperator(token) && i+1 < len(tokens) {
+				i++
+				token += tokens[i]
+			}
+			comparison, err := parseComparison(token)
+			if err != nil {
+				return Range{}, errors.Wrapf(err, "Invalid version range %q", rangeStr)
+			}
+			comparisons = append(comparisons, comparison)
+		}
+		versionRange.alternatives = append(versionRange.alternatives, comparisons)
+	}
+	return versionRange, nil
+}
+
+func MustParseRange(rangeStr string) Range {
+	versionRange, err := ParseRange(rangeStr)
+	if err != nil {
+		panic(err)
+	}
+	return versionRange
+}
+
+func isOperator(token string) bool {
+	for _, operator := range rangeOperators {
+		if token == operator {
+			return true
+		}
+	}
+	return false
+}
+
+func parseComparison(token string) (comparison, error) {
+	operator := "="
+	for _, prefix := range rangeOperators {
+		if strings.HasPrefix(token, prefix) {
+			operator = strings.Replace(prefix, "==", "=", 1)
+			token = strings.TrimPrefix(token, prefix)
+			break
+		}
+	}
+
+	// Wildcards only make sense at the end of a version, so "6.x" is treated like "6"
+	numbers := strings.Split(token, ".")
+	for len(numbers) > 1 && isWildcard(numbers[len(numbers)-1]) {
+		numbers = numbers[:len(numbers)-1]
+	}
+	if len(numbers) == 1 && isWildcard(numbers[0]) {
+		return func(semver.Version) bool { return operator == "=" || operator == ">=" || operator == "<=" }, nil
+	}
+	token = strings.Join(numbers, ".")
+	version, err := ParseSemVer(token)
+	if err != nil {
+		return nil, err
+	}
+
+	numComponents := len(strings.Split(semverPattern.FindStringSubmatch(token)[1], "."))
+	if len(version.Pre) > 0 || numComponents >= 3 {
+		return exactComparison(operator, version), nil
+	}
+	return seriesComparison(operator, version, numComponents), nil
+}
+
+func isWildcard(number string) bool {
+	return number == "x" || number == "X" || number == "*"
+}
+
+func exactComparison(operator string, target semver.Version) comparison {
+	return func(version semver.Version) bool {
+		result := version.Compare(target)
+		switch operator {
+		case ">=":
+			return result >= 0
+		case "<=":
+			return result <= 0
+		case ">":
+			return result > 0
+		case "<":
+			return result < 0
+		case "!=":
+			return result != 0
+		default:
+			return result == 0
+		}
+	}
+}
+
+/*
+ * seriesComparison compares versions with the release series given by the
+ * first numComponents components of target, which runs from the first
+ * pre-release of that series up to but not including the first pre-release
+ * of the next.
+ */
+func seriesComparison(operator string, target semver.Version, numComponents int) comparison {
+	first := semver.Version{Major: target.Major, Pre: []semver.PRVersion{{VersionNum: 0, IsNum: true}}}
+	next := semver.Version{Major: target.Major + 1, Pre: first.Pre}
+	if numComponents == 2 {
+		first.Minor = target.Minor
+		next = semver.Version{Major: target.Major, Minor: target.Minor + 1, Pre: first.Pre}
+	}
+	return func(version semver.Version) bool {
+		switch operator {
+		case ">=":
+			return version.Compare(first) >= 0
+		case "<=":
+			return version.Compare(next) < 0
+		case ">":
+			return version.Compare(next) >= 0
+		case "<":
+			return version.Compare(first) < 0
+		case "!=":
+			return version.Compare(first) < 0 || version.Compare(next) >= 0
+		default:
+			return version.Compare(first) >= 0 && version.Compare(next) < 0
+		}
+	}
+}
+
+// Contains returns whether the range includes version, regardless of its flavor
+func (versionRange Range) Contains(version Version) bool {
+	return versionRange.ContainsSemVer(version.SemVer)
+}
+
+func (versionRange Range) ContainsSemVer(version semver.Version) bool {
+	for _, alternative := range versionRange.alternatives {
+		matched := true
+		for _, comparison := range alternative {
+			if !comparison(version) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (versionRange Range) String() string {
+	return versionRange.text
+}
@@ -0,0 +1,64 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpversion_test
+
+import (
+	"github.com/apache/cloudberry-go-libs/gpversion"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("gpversion/range tests", func() {
+	DescribeTable("matches versions against ranges",
+		func(rangeStr string, version string, expected bool) {
+			versionRange, err := gpversion.ParseRange(rangeStr)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(versionRange.Contains(gpversion.MustParse(version))).To(Equal(expected))
+		},
+		Entry("within a bounded range", ">=1.5 <2.0", "1.6.0", true),
+		Entry("at the lower bound of a range", ">=1.5 <2.0", "1.5.0", true),
+		Entry("at the upper bound of a range", ">=1.5 <2.0", "2.0.0", false),
+		Entry("with a space after an operator", ">= 1.5 < 2.0", "1.9.9", true),
+		Entry("a pre-release of a partial lower bound", ">=7", "7.0.0-beta.1", true),
+		Entry("a pre-release of a full lower bound", ">=7.0.0", "7.0.0-beta.1", false),
+		Entry("after a partial version", ">6.20", "6.20.9", false),
+		Entry("after the series of a partial version", ">6.20", "6.21.0", true),
+		Entry("up to a partial version", "<=6.20", "6.20.9", true),
+		Entry("a series without an operator", "6", "6.26.1", true),
+		Entry("a series with ==", "==6.26", "6.26.1", true),
+		Entry("outside a series", "!=6", "6.26.1", false),
+		Entry("outside a series, after it", "!=6", "7.0.0", true),
+		Entry("a wildcard", "6.x", "6.26.1", true),
+		Entry("a full wildcard", "*", "1.0.0", true),
+		Entry("the first alternative", "<6 || >=7", "5.29.0", true),
+		Entry("the second alternative", "<6 || >=7", "7.1.0", true),
+		Entry("neither alternative", "<6 || >=7", "6.26.1", false),
+		Entry("an exact version", "6.26.1", "6.26.1+dev.3", true),
+		Entry("a different exact version", "!=6.26.1", "6.26.2", true),
+	)
+	It("returns an error for an invalid range", func() {
+		_, err := gpversion.ParseRange(">=1.5 ||")
+		Expect(err).To(MatchError(`Invalid version range ">=1.5 ||": empty comparison`))
+		_, err = gpversion.ParseRange(">=one")
+		Expect(err).To(MatchError(`Invalid version range ">=one": Invalid version "one"`))
+	})
+	It("returns the range as given", func() {
+		Expect(gpversion.MustParseRange(">=1.5 <2.0").String()).To(Equal(">=1.5 <2.0"))
+	})
+})
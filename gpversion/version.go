@@ -0,0 +1,259 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpversion
+
+/*
+ * This file contains structs and functions for parsing the version strings
+ * reported by Greenplum, Cloudberry, and PostgreSQL, whether from "SELECT
+ * version()" or from "postgres --version", into versions that can be compared.
+ */
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/pkg/errors"
+)
+
+type Flavor int
+
+const (
+	UNKNOWN Flavor = iota
+	POSTGRESQL
+	GREENPLUM
+	CLOUDBERRY
+)
+
+func (flavor Flavor) String() string {
+	switch flavor {
+	case POSTGRESQL:
+		return "PostgreSQL"
+	case GREENPLUM:
+		return "Greenplum Database"
+	case CLOUDBERRY:
+		return "Apache Cloudberry"
+	default:
+		return "Unknown Database"
+	}
+}
+
+/*
+ * Vendor builds name the product differently, e.g. "Greenplum Database",
+ * "VMware Greenplum", "Cloudberry Database", or "Apache Cloudberry
+ * (Incubating)", so only the product family is matched.  The product name is
+ * followed by its version in "SELECT version()" output, as in
+ *
+ *   PostgreSQL 12.12 (Greenplum Database 7.0.0 build commit:...) on x86_64...
+ *
+ * and by a closing parenthesis and then its version in "postgres --version"
+ * output, as in
+ *
+ *   postgres (Greenplum Database) 7.0.0 build commit:...
+ */
+var (
+	productPattern  = regexp.MustCompile(`\b(Greenplum|Cloudberry)\b[A-Za-z ]*(?:\(Incubating\))?\)? v?([0-9]+(?:\.[0-9]+)*[-+.~_0-9A-Za-z]*)`)
+	postgresPattern = regexp.MustCompile(`\bPostgreSQL\)? ([0-9]+(?:\.[0-9]+)*[-+.~_0-9A-Za-z]*)`)
+	semverPattern   = regexp.MustCompile(`^v?([0-9]+(?:\.[0-9]+)*)(.*)$`)
+)
+
+/*
+ * A Version is the version of a database product.  SemVer holds the version
+ * of the product itself, such as 7.0.0 for Greenplum 7, and Postgres holds the
+ * version of PostgreSQL on which it is based, if that is known; for
+ * PostgreSQL itself the two are the same.  Build metadata such as the "+dev"
+ * suffix of development builds is kept in SemVer but ignored when comparing.
+ */
+type Version struct {
+	VersionString string
+	Flavor        Flavor
+	SemVer        semver.Version
+	Postgres      semver.Version
+}
+
+/*
+ * Parse parses the output of "SELECT version()" or of "postgres --version"
+ * for Greenplum, Cloudberry, or PostgreSQL.  A string containing only a
+ * version number, such as "6.26.1", is parsed as a version of an UNKNOWN
+ * flavor.
+ */
+func Parse(versionString string) (Version, error) {
+	version := Version{VersionString: versionString}
+	if matches := postgresPattern.FindStringSubmatch(versionString); matches != nil {
+		postgres, err := ParseSemVer(matches[1])
+		if err != nil {
+			return Version{}, errors.Wrapf(err, "Could not parse version string %q", versionString)
+		}
+		version.Flavor = POSTGRESQL
+		version.SemVer = postgres
+		version.Postgres = postgres
+	}
+	if matches := productPattern.FindStringSubmatch(versionString); matches != nil {
+		product, err := ParseSemVer(matches[2])
+		if err != nil {
+			return Version{}, errors.Wrapf(err, "Could not parse version string %q", versionString)
+		}
+		version.Flavor = GREENPLUM
+		if matches[1] == "Cloudberry" {
+			version.Flavor = CLOUDBERRY
+		}
+		version.SemVer = product
+	}
+	if version.Flavor != UNKNOWN {
+		return version, nil
+	}
+	semVer, err := ParseSemVer(strings.TrimSpace(versionString))
+	if err != nil {
+		return Version{}, errors.Errorf("Could not parse version string %q: no Greenplum, Cloudberry, or PostgreSQL version found", versionString)
+	}
+	version.SemVer = semVer
+	return version, nil
+}
+
+func MustParse(versionString string) Version {
+	version, err := Parse(versionString)
+	if err != nil {
+		panic(err)
+	}
+	return version
+}
+
+/*
+ * ParseSemVer parses a version number as it appears in product version
+ * strings, which are not always valid semantic versions:
+ * - Missing minor and patch versions are taken to be 0, so "12" is 12.0.0.
+ * - Pre-release suffixes need not be separated by "-", and numbers within
+ *   them are compared numerically, so "12beta2" is 12.0.0-beta.2 and comes
+ *   before 12.0.0-beta.10.
+ * - Version numbers beyond the patch version, as in Greenplum 4's "4.3.33.5",
+ *   are kept as build metadata.
+ * - Build metadata, as in "7.1.0+dev.12.gabc1234", is kept but has no effect
+ *   on comparisons.
+ */
+func ParseSemVer(versionStr string) (semver.Version, error) {
+	matches := semverPattern.FindStringSubmatch(versionStr)
+	if matches == nil {
+		return semver.Version{}, errors.Errorf("Invalid version %q", versionStr)
+	}
+	version := semver.Version{}
+	numbers := strings.Split(matches[1], ".")
+	for i, number := range numbers {
+		value, err := strconv.ParseUint(number, 10, 64)
+		if err != nil {
+			return semver.Version{}, errors.Errorf("Invalid version %q", versionStr)
+		}
+		switch i {
+		case 0:
+			version.Major = value
+		case 1:
+			version.Minor = value
+		case 2:
+			version.Patch = value
+		default:
+			version.Build = append(version.Build, strconv.FormatUint(value, 10))
+		}
+	}
+	suffix, build, _ := strings.Cut(matches[2], "+")
+	for _, identifier := range identifiers(suffix, true) {
+		prerelease, err := semver.NewPRVersion(identifier)
+		if err != nil {
+			return semver.Version{}, errors.Errorf("Invalid version %q", versionStr)
+		}
+		version.Pre = append(version.Pre, prerelease)
+	}
+	version.Build = append(version.Build, identifiers(build, false)...)
+	return version, nil
+}
+
+/*
+ * identifiers splits a pre-release or build suffix into the identifiers
+ * allowed in semantic versions, dropping any other characters.  Pre-release
+ * identifiers are also split between letters and digits, with leading zeroes
+ * removed from numbers, so that numbered pre-releases compare numerically.
+ */
+func identifiers(suffix string, splitNumbers bool) []string {
+	fields := strings.FieldsFunc(suffix, func(r rune) bool {
+		return !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '-' && !splitNumbers)
+	})
+	if !splitNumbers {
+		return fields
+	}
+	result := make([]string, 0)
+	for _, field := range fields {
+		start := 0
+		for i := 1; i <= len(field); i++ {
+			if i < len(field) && isDigit(field[i]) == isDigit(field[i-1]) {
+				continue
+			}
+			identifier := field[start:i]
+			if isDigit(identifier[0]) {
+				identifier = strings.TrimLeft(identifier, "0")
+				if identifier == "" {
+					identifier = "0"
+				}
+			}
+			result = append(result, identifier)
+			start = i
+		}
+	}
+	return result
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+/*
+ * Compare returns -1, 0, or 1 as version comes before, is the same as, or
+ * comes after other, ignoring build metadata.  The versions are compared as
+ * given, even if their flavors differ.
+ */
+func (version Version) Compare(other Version) int {
+	return version.SemVer.Compare(other.SemVer)
+}
+
+/*
+ * Before, AtLeast, and Is compare the version with a version number, which
+ * refers to a whole release series if it is partial: Before("7") is true for
+ * 6.26.1 but not for 7.0.0-beta.1, and Is("6") is true for any 6.x.y.  They
+ * panic if targetVersion is not a valid version, as that is programmer error.
+ */
+func (version Version) Before(targetVersion string) bool {
+	return version.Satisfies("<" + targetVersion)
+}
+
+func (version Version) AtLeast(targetVersion string) bool {
+	return version.Satisfies(">=" + targetVersion)
+}
+
+func (version Version) Is(targetVersion string) bool {
+	return version.Satisfies("=" + targetVersion)
+}
+
+// Satisfies panics if versionRange is invalid, as that is programmer error
+func (version Version) Satisfies(versionRange string) bool {
+	return MustParseRange(versionRange).Contains(version)
+}
+
+func (version Version) String() string {
+	if version.Flavor == UNKNOWN {
+		return version.SemVer.String()
+	}
+	return version.Flavor.String() + " " + version.SemVer.String()
+}
@@ -0,0 +1,108 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gpversion_test
+
+import (
+	"github.com/apache/cloudberry-go-libs/gpversion"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("gpversion/version tests", func() {
+	Describe("Parse", func() {
+		DescribeTable("parses version strings",
+			func(versionString string, flavor gpversion.Flavor, semVer string, postgres string) {
+				version, err := gpversion.Parse(versionString)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(version.VersionString).To(Equal(versionString))
+				Expect(version.Flavor).To(Equal(flavor))
+				Expect(version.SemVer.String()).To(Equal(semVer))
+				Expect(version.Postgres.String()).To(Equal(postgres))
+			},
+			Entry("Greenplum 7", "PostgreSQL 12.12 (Greenplum Database 7.0.0 build commit:bf073b87c0bac9759631746dca1c4c895a304afb) on x86_64-pc-linux-gnu", gpversion.GREENPLUM, "7.0.0", "12.12.0"),
+			Entry("a Greenplum beta", "PostgreSQL 12beta2 (Greenplum Database 7.0.0-beta.1 build commit:abc) on x86_64-pc-linux-gnu", gpversion.GREENPLUM, "7.0.0-beta.1", "12.0.0-beta.2"),
+			Entry("a Greenplum development build", "PostgreSQL 9.4.26 (Greenplum Database 6.26.1+dev.12.gabc1234 build dev) on x86_64-pc-linux-gnu", gpversion.GREENPLUM, "6.26.1+dev.12.gabc1234", "9.4.26"),
+			Entry("Greenplum 4", "PostgreSQL 8.2.15 (Greenplum Database 4.3.33.5 build 1) on x86_64-unknown-linux-gnu", gpversion.GREENPLUM, "4.3.33+5", "8.2.15"),
+			Entry("a vendor build of Greenplum", "PostgreSQL 9.4.26 (VMware Greenplum 6.25.3 build commit:abc) on x86_64-pc-linux-gnu", gpversion.GREENPLUM, "6.25.3", "9.4.26"),
+			Entry("Apache Cloudberry", "PostgreSQL 14.4 (Apache Cloudberry 2.0.0 build commit:a071e3f8aa638786f01bbd08307b6474a1ba7890) on x86_64-pc-linux-gnu", gpversion.CLOUDBERRY, "2.0.0", "14.4.0"),
+			Entry("an incubating Cloudberry", "PostgreSQL 14.4 (Apache Cloudberry (Incubating) 2.0.0-rc1 build dev) on x86_64-pc-linux-gnu", gpversion.CLOUDBERRY, "2.0.0-rc.1", "14.4.0"),
+			Entry("Cloudberry Database", "PostgreSQL 14.4 (Cloudberry Database 1.5.4 build dev) on x86_64-pc-linux-gnu", gpversion.CLOUDBERRY, "1.5.4", "14.4.0"),
+			Entry("PostgreSQL", "PostgreSQL 16.2 on x86_64-pc-linux-gnu, compiled by gcc (GCC) 11.4.1, 64-bit", gpversion.POSTGRESQL, "16.2.0", "16.2.0"),
+			Entry("a PostgreSQL development build", "PostgreSQL 17devel on x86_64-pc-linux-gnu", gpversion.POSTGRESQL, "17.0.0-devel", "17.0.0-devel"),
+			Entry("postgres --version for Greenplum", "postgres (Greenplum Database) 6.26.1 build commit:abc", gpversion.GREENPLUM, "6.26.1", "0.0.0"),
+			Entry("postgres --version for Cloudberry", "postgres (Apache Cloudberry) 1.6.0 build commit:abc", gpversion.CLOUDBERRY, "1.6.0", "0.0.0"),
+			Entry("postgres --version for PostgreSQL", "postgres (PostgreSQL) 14.4", gpversion.POSTGRESQL, "14.4.0", "14.4.0"),
+			Entry("a bare version", " 6.20 ", gpversion.UNKNOWN, "6.20.0", "0.0.0"),
+		)
+		It("returns an error for a string with no version", func() {
+			_, err := gpversion.Parse("Some Other Database")
+			Expect(err).To(MatchError(`Could not parse version string "Some Other Database": no Greenplum, Cloudberry, or PostgreSQL version found`))
+		})
+		It("panics in MustParse for a string with no version", func() {
+			Expect(func() { gpversion.MustParse("") }).To(Panic())
+		})
+	})
+	Describe("ParseSemVer", func() {
+		It("compares numbered pre-releases numerically", func() {
+			beta2, err := gpversion.ParseSemVer("12beta2")
+			Expect(err).ToNot(HaveOccurred())
+			beta10, err := gpversion.ParseSemVer("12.0.0-beta.010")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(beta10.String()).To(Equal("12.0.0-beta.10"))
+			Expect(beta2.LT(beta10)).To(BeTrue())
+		})
+		It("returns an error for an invalid version", func() {
+			_, err := gpversion.ParseSemVer("beta")
+			Expect(err).To(MatchError(`Invalid version "beta"`))
+		})
+	})
+	Describe("Compare", func() {
+		It("ignores build metadata", func() {
+			Expect(gpversion.MustParse("7.1.0+dev.3").Compare(gpversion.MustParse("7.1.0"))).To(Equal(0))
+			Expect(gpversion.MustParse("7.0.0-beta.1").Compare(gpversion.MustParse("7.0.0"))).To(Equal(-1))
+			Expect(gpversion.MustParse("7.1.0").Compare(gpversion.MustParse("7.0.0"))).To(Equal(1))
+		})
+	})
+	Describe("Before, AtLeast, and Is", func() {
+		gp6 := gpversion.MustParse("PostgreSQL 9.4.26 (Greenplum Database 6.26.1 build commit:abc) on x86_64-pc-linux-gnu")
+		gp7Beta := gpversion.MustParse("PostgreSQL 12.12 (Greenplum Database 7.0.0-beta.1 build commit:abc) on x86_64-pc-linux-gnu")
+		It("treats partial versions as release series", func() {
+			Expect(gp6.Before("7")).To(BeTrue())
+			Expect(gp7Beta.Before("7")).To(BeFalse())
+			Expect(gp7Beta.AtLeast("7")).To(BeTrue())
+			Expect(gp6.Is("6")).To(BeTrue())
+			Expect(gp6.Is("6.26")).To(BeTrue())
+			Expect(gp6.Is("6.2")).To(BeFalse())
+		})
+		It("treats full versions exactly", func() {
+			Expect(gp7Beta.AtLeast("7.0.0")).To(BeFalse())
+			Expect(gp6.Is("6.26.1")).To(BeTrue())
+			Expect(gp6.Before("6.26.1")).To(BeFalse())
+		})
+		It("panics for an invalid version", func() {
+			Expect(func() { gp6.Before("six") }).To(Panic())
+		})
+	})
+	Describe("String", func() {
+		It("includes the flavor if it is known", func() {
+			Expect(gpversion.MustParse("postgres (Apache Cloudberry) 1.6.0 build commit:abc").String()).To(Equal("Apache Cloudberry 1.6.0"))
+			Expect(gpversion.MustParse("6.26.1").String()).To(Equal("6.26.1"))
+		})
+	})
+})
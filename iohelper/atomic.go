@@ -0,0 +1,62 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper
+
+/*
+ * This file contains functions for replacing the contents of a file so that
+ * readers see either the old contents or the new, never a partial write.
+ */
+
+import (
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+/*
+ * WriteFileAtomically writes data to a temporary file next to filename, syncs
+ * it, and renames it over filename, so that a crash or a full disk leaves the
+ * previous file in place.  The temporary file is removed if any step fails.
+ */
+func WriteFileAtomically(filename string, data []byte) error {
+	tempPath := filename + ".tmp"
+	writer, err := OpenFileForWriting(tempPath)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to write %s", filename)
+	}
+	_, err = writer.Write(data)
+	if syncer, ok := writer.(interface{ Sync() error }); ok && err == nil {
+		err = syncer.Sync()
+	}
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = operating.System.Rename(tempPath, filename)
+	}
+	if err != nil {
+		_ = operating.System.Remove(tempPath)
+		return errors.Wrapf(err, "Unable to write %s", filename)
+	}
+	return nil
+}
+
+func MustWriteFileAtomically(filename string, data []byte) {
+	err := WriteFileAtomically(filename, data)
+	gplog.FatalOnError(err)
+}
@@ -0,0 +1,69 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/apache/cloudberry-go-libs/iohelper"
+	"github.com/apache/cloudberry-go-libs/operating"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("iohelper/atomic tests", func() {
+	var filename string
+
+	BeforeEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		filename = filepath.Join(GinkgoT().TempDir(), "gpbackup_20240101_report")
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("WriteFileAtomically", func() {
+		It("creates the file", func() {
+			Expect(iohelper.WriteFileAtomically(filename, []byte("contents"))).To(Succeed())
+			Expect(os.ReadFile(filename)).To(Equal([]byte("contents")))
+			Expect(filename + ".tmp").ToNot(BeAnExistingFile())
+		})
+		It("replaces an existing file", func() {
+			Expect(os.WriteFile(filename, []byte("original contents"), 0644)).To(Succeed())
+			Expect(iohelper.WriteFileAtomically(filename, []byte("new"))).To(Succeed())
+			Expect(os.ReadFile(filename)).To(Equal([]byte("new")))
+		})
+		It("leaves the existing file in place if the rename fails", func() {
+			Expect(os.WriteFile(filename, []byte("original"), 0644)).To(Succeed())
+			operating.System.Rename = func(oldpath string, newpath string) error {
+				return errors.New("rename failed")
+			}
+			err := iohelper.WriteFileAtomically(filename, []byte("new"))
+			Expect(err).To(MatchError(MatchRegexp("^Unable to write .*_report: rename failed$")))
+			Expect(os.ReadFile(filename)).To(Equal([]byte("original")))
+			Expect(filename + ".tmp").ToNot(BeAnExistingFile())
+		})
+		It("returns an error if the directory does not exist", func() {
+			err := iohelper.WriteFileAtomically(filepath.Join(filename, "missing", "report"), []byte("new"))
+			Expect(err).To(MatchError(ContainSubstring("Unable to create or open file for writing")))
+		})
+	})
+})
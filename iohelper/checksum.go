@@ -0,0 +1,334 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper
+
+/*
+ * This file contains readers and writers that compute a checksum of the data
+ * passing through them, and functions for the sidecar .sha256 files used to
+ * verify backup files, so that files do not need to be read a second time
+ * just to checksum them.
+ */
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+type ChecksumAlgorithm uint8
+
+const (
+	SHA256 ChecksumAlgorithm = iota
+	CRC32C
+)
+
+// The extension of sidecar checksum files, which use the same format as sha256sum
+const CHECKSUM_FILE_EXTENSION = ".sha256"
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+func (algorithm ChecksumAlgorithm) String() string {
+	switch algorithm {
+	case SHA256:
+		return "sha256"
+	case CRC32C:
+		return "crc32c"
+	}
+	return fmt.Sprintf("unknown(%d)", algorithm)
+}
+
+func (algorithm ChecksumAlgorithm) newHash() hash.Hash {
+	if algorithm == CRC32C {
+		return crc32.New(crc32cTable)
+	}
+	return sha256.New()
+}
+
+/*
+ * A ChecksumReader computes the checksum of everything read through it.  Sum
+ * returns the hex-encoded checksum of the data read so far, so it should be
+ * called once the underlying reader returns io.EOF.
+ */
+type ChecksumReader struct {
+	reader    io.Reader
+	hash      hash.Hash
+	Algorithm ChecksumAlgorithm
+	BytesRead int64
+}
+
+func NewChecksumReader(reader io.Reader, algorithm ChecksumAlgorithm) *ChecksumReader {
+	return &ChecksumReader{reader: reader, hash: algorithm.newHash(), Algorithm: algorithm}
+}
+
+func (checksummer *ChecksumReader) Read(p []byte) (int, error) {
+	n, err := checksummer.reader.Read(p)
+	checksummer.hash.Write(p[:n])
+	checksummer.BytesRead += int64(n)
+	return n, err
+}
+
+func (checksummer *ChecksumReader) Sum() string {
+	return hex.EncodeToString(checksummer.hash.Sum(nil))
+}
+
+// A ChecksumWriter computes the checksum of everything successfully written through it
+type ChecksumWriter struct {
+	writer       io.Writer
+	hash         hash.Hash
+	Algorithm    ChecksumAlgorithm
+	BytesWritten int64
+}
+
+func NewChecksumWriter(writer io.Writer, algorithm ChecksumAlgorithm) *ChecksumWriter {
+	return &ChecksumWriter{writer: writer, hash: algorithm.newHash(), Algorithm: algorithm}
+}
+
+func (checksummer *ChecksumWriter) Write(p []byte) (int, error) {
+	n, err := checksummer.writer.Write(p)
+	checksummer.hash.Write(p[:n])
+	checksummer.BytesWritten += int64(n)
+	return n, err
+}
+
+func (checksummer *ChecksumWriter) Sum() string {
+	return hex.EncodeToString(checksummer.hash.Sum(nil))
+}
+
+/*
+ * A ChecksumMismatchError is returned when a file does not match its sidecar
+ * checksum file.  Report returns a CorruptionReport covering the whole file,
+ * which can be passed to QuarantineRegion.  If the file was quarantined when
+ * the mismatch was found, QuarantineReport is the path to the JSON report.
+ */
+type ChecksumMismatchError struct {
+	File             string
+	Size             int64
+	Expected         string
+	Actual           string
+	QuarantineReport string
+}
+
+func (mismatch *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("Checksum mismatch for %s: expected %s %s, found %s", mismatch.File, SHA256, mismatch.Expected, mismatch.Actual)
+}
+
+func (mismatch *ChecksumMismatchError) Report() CorruptionReport {
+	return CorruptionReport{
+		File:             mismatch.File,
+		Length:           mismatch.Size,
+		Algorithm:        SHA256.String(),
+		ExpectedChecksum: mismatch.Expected,
+		ActualChecksum:   mismatch.Actual,
+	}
+}
+
+/*
+ * quarantineMismatch quarantines the file described by mismatch if quarantine
+ * options were given.  Failing to quarantine the file is only logged, so that
+ * the caller still sees the mismatch itself.
+ */
+func quarantineMismatch(source io.ReaderAt, mismatch *ChecksumMismatchError, quarantine []QuarantineOptions) {
+	if len(quarantine) == 0 {
+		return
+	}
+	reportFile, err := QuarantineRegion(source, mismatch.Report(), quarantine[0])
+	if err != nil {
+		gplog.Warn("Unable to quarantine %s: %s", mismatch.File, err)
+		return
+	}
+	mismatch.QuarantineReport = reportFile
+}
+
+func ChecksumFileName(filename string) string {
+	return filename + CHECKSUM_FILE_EXTENSION
+}
+
+// WriteChecksumFile writes the sidecar checksum file for filename, given its SHA-256 checksum
+func WriteChecksumFile(filename string, checksum string) error {
+	sidecar, err := OpenFileForWriting(ChecksumFileName(filename))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(sidecar, "%s  %s\n", checksum, filepath.Base(filename))
+	closeErr := sidecar.Close()
+	if err != nil {
+		return errors.Errorf("Unable to write checksum file for %s: %s", filename, err)
+	}
+	return closeErr
+}
+
+// ReadChecksumFile returns the SHA-256 checksum recorded in the sidecar checksum file for filename
+func ReadChecksumFile(filename string) (string, error) {
+	lines, err := ReadLinesFromFile(ChecksumFileName(filename))
+	if err != nil {
+		return "", err
+	}
+	if len(lines) > 0 {
+		fields := strings.Fields(lines[0])
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == filepath.Base(filename) {
+			if _, err := hex.DecodeString(fields[0]); err == nil && len(fields[0]) == 2*sha256.Size {
+				return strings.ToLower(fields[0]), nil
+			}
+		}
+	}
+	return "", errors.Errorf("Checksum file %s does not contain a %s checksum for %s", ChecksumFileName(filename), SHA256, filepath.Base(filename))
+}
+
+/*
+ * checksumFileWriter writes the sidecar checksum file after closing the file,
+ * so that a sidecar file only exists for files that were written completely.
+ */
+type checksumFileWriter struct {
+	*ChecksumWriter
+	file     io.Closer
+	filename string
+	writeErr error
+}
+
+func (writer *checksumFileWriter) Write(p []byte) (int, error) {
+	n, err := writer.ChecksumWriter.Write(p)
+	if err != nil && writer.writeErr == nil {
+		writer.writeErr = err
+	}
+	return n, err
+}
+
+func (writer *checksumFileWriter) Close() error {
+	if err := writer.file.Close(); err != nil {
+		return err
+	}
+	if writer.writeErr != nil {
+		return errors.Errorf("Not writing checksum file for %s after a failed write: %s", writer.filename, writer.writeErr)
+	}
+	return WriteChecksumFile(writer.filename, writer.Sum())
+}
+
+/*
+ * OpenFileForWritingWithChecksum creates or truncates filename and returns a
+ * writer that writes the sidecar checksum file for it when closed.
+ */
+func OpenFileForWritingWithChecksum(filename string) (io.WriteCloser, error) {
+	file, err := OpenFileForWriting(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &checksumFileWriter{ChecksumWriter: NewChecksumWriter(file, SHA256), file: file, filename: filename}, nil
+}
+
+func MustOpenFileForWritingWithChecksum(filename string) io.WriteCloser {
+	writer, err := OpenFileForWritingWithChecksum(filename)
+	gplog.FatalOnError(err)
+	return writer
+}
+
+/*
+ * checksumFileReader compares the checksum of the file with its sidecar
+ * checksum file when the end of the file is reached, and returns a
+ * *ChecksumMismatchError in place of io.EOF if they differ.
+ */
+type checksumFileReader struct {
+	*ChecksumReader
+	file       operating.ReadCloserAt
+	filename   string
+	expected   string
+	quarantine []QuarantineOptions
+	err        error
+}
+
+func (reader *checksumFileReader) Read(p []byte) (int, error) {
+	if reader.err != nil {
+		return 0, reader.err
+	}
+	n, err := reader.ChecksumReader.Read(p)
+	if err == io.EOF {
+		if actual := reader.Sum(); actual != reader.expected {
+			mismatch := &ChecksumMismatchError{File: reader.filename, Size: reader.BytesRead, Expected: reader.expected, Actual: actual}
+			quarantineMismatch(reader.file, mismatch, reader.quarantine)
+			err = mismatch
+		}
+		reader.err = err
+	}
+	return n, err
+}
+
+func (reader *checksumFileReader) Close() error {
+	return reader.file.Close()
+}
+
+/*
+ * OpenFileForReadingWithChecksum opens filename and returns a reader that
+ * verifies it against its sidecar checksum file as it is read, so that a file
+ * can be verified without being read twice.  Once the whole file has been
+ * read, the reader returns a *ChecksumMismatchError instead of io.EOF if the
+ * checksums differ.  If quarantine options are given, a file that does not
+ * match is quarantined as by QuarantineRegion before the error is returned.
+ */
+func OpenFileForReadingWithChecksum(filename string, quarantine ...QuarantineOptions) (io.ReadCloser, error) {
+	expected, err := ReadChecksumFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	file, err := OpenFileForReading(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &checksumFileReader{ChecksumReader: NewChecksumReader(file, SHA256), file: file, filename: filename, expected: expected, quarantine: quarantine}, nil
+}
+
+/*
+ * VerifyChecksumFile checks filename against its sidecar checksum file and
+ * returns a *ChecksumMismatchError if they differ, quarantining the file if
+ * quarantine options are given.  Callers that already read the whole file, as
+ * during a restore, should instead use OpenFileForReadingWithChecksum, to
+ * avoid reading the file twice.
+ */
+func VerifyChecksumFile(filename string, quarantine ...QuarantineOptions) error {
+	expected, err := ReadChecksumFile(filename)
+	if err != nil {
+		return err
+	}
+	file, err := OpenFileForReading(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	checksummer := NewChecksumReader(file, SHA256)
+	if _, err = io.Copy(io.Discard, checksummer); err != nil {
+		return errors.Errorf("Unable to read %s: %s", filename, err)
+	}
+	if actual := checksummer.Sum(); actual != expected {
+		mismatch := &ChecksumMismatchError{File: filename, Size: checksummer.BytesRead, Expected: expected, Actual: actual}
+		quarantineMismatch(file, mismatch, quarantine)
+		return mismatch
+	}
+	return nil
+}
+
+func MustVerifyChecksumFile(filename string, quarantine ...QuarantineOptions) {
+	err := VerifyChecksumFile(filename, quarantine...)
+	gplog.FatalOnError(err)
+}
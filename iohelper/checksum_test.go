@@ -0,0 +1,250 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/iohelper"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// faultyFile accepts limit bytes, or any number if limit is negative, before failing with writeErr
+type faultyFile struct {
+	written  bytes.Buffer
+	limit    int
+	writeErr error
+	closeErr error
+}
+
+func (file *faultyFile) Write(p []byte) (int, error) {
+	if file.limit >= 0 && file.written.Len()+len(p) > file.limit {
+		n, _ := file.written.Write(p[:file.limit-file.written.Len()])
+		return n, file.writeErr
+	}
+	return file.written.Write(p)
+}
+
+func (file *faultyFile) Close() error {
+	return file.closeErr
+}
+
+var _ = Describe("iohelper/checksum tests", func() {
+	const helloSHA256 = "a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447"
+	var (
+		tempDir  string
+		filename string
+	)
+
+	BeforeEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		tempDir = GinkgoT().TempDir()
+		filename = filepath.Join(tempDir, "gpbackup_0_20240101.gz")
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("ChecksumReader and ChecksumWriter", func() {
+		DescribeTable("compute checksums while streaming", func(algorithm iohelper.ChecksumAlgorithm, input string, expected string) {
+			reader := iohelper.NewChecksumReader(strings.NewReader(input), algorithm)
+			var output bytes.Buffer
+			writer := iohelper.NewChecksumWriter(&output, algorithm)
+			n, err := io.Copy(writer, reader)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(Equal(int64(len(input))))
+			Expect(output.String()).To(Equal(input))
+			Expect(reader.Sum()).To(Equal(expected))
+			Expect(writer.Sum()).To(Equal(expected))
+			Expect(reader.BytesRead).To(Equal(int64(len(input))))
+			Expect(writer.BytesWritten).To(Equal(int64(len(input))))
+		},
+			Entry("sha256", iohelper.SHA256, "hello world\n", helloSHA256),
+			Entry("crc32c", iohelper.CRC32C, "123456789", "e3069283"),
+			Entry("crc32c of no data", iohelper.CRC32C, "", "00000000"),
+		)
+	})
+	Describe("OpenFileForWritingWithChecksum and VerifyChecksumFile", func() {
+		It("writes a sidecar checksum file in sha256sum format when closed", func() {
+			writer, err := iohelper.OpenFileForWritingWithChecksum(filename)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = io.WriteString(writer, "hello world\n")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(iohelper.ChecksumFileName(filename)).ToNot(BeAnExistingFile())
+			Expect(writer.Close()).To(Succeed())
+
+			sidecar, err := os.ReadFile(filename + ".sha256")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(sidecar)).To(Equal(helloSHA256 + "  gpbackup_0_20240101.gz\n"))
+			Expect(iohelper.VerifyChecksumFile(filename)).To(Succeed())
+		})
+		It("does not write a sidecar checksum file if the file cannot be written completely", func() {
+			operating.System.OpenFileWrite = func(name string, flag int, perm os.FileMode) (io.WriteCloser, error) {
+				return &faultyFile{limit: 5, writeErr: errors.New("input/output error")}, nil
+			}
+			writer, err := iohelper.OpenFileForWritingWithChecksum(filename)
+			Expect(err).ToNot(HaveOccurred())
+			n, err := io.WriteString(writer, "hello world\n")
+			Expect(n).To(Equal(5))
+			Expect(err).To(MatchError("input/output error"))
+			Expect(writer.Close()).To(MatchError("Not writing checksum file for " + filename + " after a failed write: input/output error"))
+			Expect(iohelper.ChecksumFileName(filename)).ToNot(BeAnExistingFile())
+		})
+		It("does not write a sidecar checksum file if closing the file fails", func() {
+			file := &faultyFile{limit: -1, closeErr: errors.New("input/output error")}
+			operating.System.OpenFileWrite = func(name string, flag int, perm os.FileMode) (io.WriteCloser, error) {
+				return file, nil
+			}
+			writer, err := iohelper.OpenFileForWritingWithChecksum(filename)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = io.WriteString(writer, "hello world\n")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.Close()).To(MatchError("input/output error"))
+			Expect(iohelper.ChecksumFileName(filename)).ToNot(BeAnExistingFile())
+			Expect(file.written.String()).To(Equal("hello world\n"))
+		})
+		It("accepts sidecar files written by sha256sum in binary mode", func() {
+			Expect(os.WriteFile(filename, []byte("hello world\n"), 0644)).To(Succeed())
+			Expect(os.WriteFile(filename+".sha256", []byte(strings.ToUpper(helloSHA256)+" *gpbackup_0_20240101.gz\n"), 0644)).To(Succeed())
+			Expect(iohelper.VerifyChecksumFile(filename)).To(Succeed())
+		})
+		It("returns a mismatch error that can be used to quarantine the file", func() {
+			Expect(os.WriteFile(filename, []byte("hello world\n"), 0644)).To(Succeed())
+			Expect(iohelper.WriteChecksumFile(filename, strings.Repeat("0", 64))).To(Succeed())
+
+			err := iohelper.VerifyChecksumFile(filename)
+			Expect(err).To(MatchError("Checksum mismatch for " + filename + ": expected sha256 " + strings.Repeat("0", 64) + ", found " + helloSHA256))
+			mismatch, ok := err.(*iohelper.ChecksumMismatchError)
+			Expect(ok).To(BeTrue())
+			report := mismatch.Report()
+			Expect(report.File).To(Equal(filename))
+			Expect(report.Offset).To(Equal(int64(0)))
+			Expect(report.Length).To(Equal(int64(12)))
+			Expect(report.ActualChecksum).To(Equal(helloSHA256))
+		})
+		It("quarantines a file that does not match if given quarantine options", func() {
+			quarantineDir := filepath.Join(tempDir, "quarantine")
+			Expect(os.WriteFile(filename, []byte("hello world\n"), 0644)).To(Succeed())
+			Expect(iohelper.WriteChecksumFile(filename, strings.Repeat("0", 64))).To(Succeed())
+
+			err := iohelper.VerifyChecksumFile(filename, iohelper.QuarantineOptions{Dir: quarantineDir})
+			mismatch, ok := err.(*iohelper.ChecksumMismatchError)
+			Expect(ok).To(BeTrue())
+			Expect(filepath.Dir(mismatch.QuarantineReport)).To(Equal(quarantineDir))
+			reportContents, err := os.ReadFile(mismatch.QuarantineReport)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(reportContents)).To(ContainSubstring(`"actual_checksum": "` + helloSHA256 + `"`))
+			Expect(os.ReadFile(strings.TrimSuffix(mismatch.QuarantineReport, ".json") + ".bin")).To(Equal([]byte("hello world\n")))
+		})
+		It("does not quarantine a file that matches", func() {
+			quarantineDir := filepath.Join(tempDir, "quarantine")
+			Expect(os.WriteFile(filename, []byte("hello world\n"), 0644)).To(Succeed())
+			Expect(iohelper.WriteChecksumFile(filename, helloSHA256)).To(Succeed())
+			Expect(iohelper.VerifyChecksumFile(filename, iohelper.QuarantineOptions{Dir: quarantineDir})).To(Succeed())
+			Expect(quarantineDir).ToNot(BeADirectory())
+		})
+		It("still returns the mismatch if the file cannot be quarantined", func() {
+			Expect(os.WriteFile(filename, []byte("hello world\n"), 0644)).To(Succeed())
+			Expect(iohelper.WriteChecksumFile(filename, strings.Repeat("0", 64))).To(Succeed())
+			err := iohelper.VerifyChecksumFile(filename, iohelper.QuarantineOptions{})
+			mismatch, ok := err.(*iohelper.ChecksumMismatchError)
+			Expect(ok).To(BeTrue())
+			Expect(mismatch.QuarantineReport).To(BeEmpty())
+		})
+		It("returns an error if the sidecar file is missing or invalid", func() {
+			Expect(os.WriteFile(filename, []byte("hello world\n"), 0644)).To(Succeed())
+			Expect(iohelper.VerifyChecksumFile(filename)).To(MatchError(ContainSubstring("no such file or directory")))
+
+			Expect(os.WriteFile(filename+".sha256", []byte(helloSHA256+"  other_file\n"), 0644)).To(Succeed())
+			Expect(iohelper.VerifyChecksumFile(filename)).To(MatchError("Checksum file " + filename + ".sha256 does not contain a sha256 checksum for gpbackup_0_20240101.gz"))
+		})
+		It("panics in the Must variant on mismatch", func() {
+			Expect(os.WriteFile(filename, []byte("hello world\n"), 0644)).To(Succeed())
+			Expect(iohelper.WriteChecksumFile(filename, strings.Repeat("0", 64))).To(Succeed())
+			defer testhelper.ShouldPanicWithMessage("Checksum mismatch for " + filename)
+			iohelper.MustVerifyChecksumFile(filename)
+		})
+	})
+	Describe("OpenFileForReadingWithChecksum", func() {
+		It("reads a file that matches its checksum file", func() {
+			Expect(os.WriteFile(filename, []byte("hello world\n"), 0644)).To(Succeed())
+			Expect(iohelper.WriteChecksumFile(filename, helloSHA256)).To(Succeed())
+			reader, err := iohelper.OpenFileForReadingWithChecksum(filename)
+			Expect(err).ToNot(HaveOccurred())
+			contents, err := io.ReadAll(reader)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(Equal("hello world\n"))
+			Expect(reader.Close()).To(Succeed())
+		})
+		It("returns a mismatch error at the end of a file that does not match", func() {
+			Expect(os.WriteFile(filename, []byte("hello world\n"), 0644)).To(Succeed())
+			Expect(iohelper.WriteChecksumFile(filename, strings.Repeat("0", 64))).To(Succeed())
+			reader, err := iohelper.OpenFileForReadingWithChecksum(filename)
+			Expect(err).ToNot(HaveOccurred())
+			defer reader.Close()
+			contents, err := io.ReadAll(reader)
+			Expect(string(contents)).To(Equal("hello world\n"))
+			Expect(err).To(MatchError("Checksum mismatch for " + filename + ": expected sha256 " + strings.Repeat("0", 64) + ", found " + helloSHA256))
+			_, err = reader.Read(make([]byte, 1))
+			Expect(err).To(BeAssignableToTypeOf(&iohelper.ChecksumMismatchError{}))
+		})
+		It("quarantines a file that does not match if given quarantine options", func() {
+			quarantineDir := filepath.Join(tempDir, "quarantine")
+			Expect(os.WriteFile(filename, []byte("hello world\n"), 0644)).To(Succeed())
+			Expect(iohelper.WriteChecksumFile(filename, strings.Repeat("0", 64))).To(Succeed())
+			reader, err := iohelper.OpenFileForReadingWithChecksum(filename, iohelper.QuarantineOptions{Dir: quarantineDir, MaxRegionBytes: 5})
+			Expect(err).ToNot(HaveOccurred())
+			defer reader.Close()
+			_, err = io.ReadAll(reader)
+			mismatch, ok := err.(*iohelper.ChecksumMismatchError)
+			Expect(ok).To(BeTrue())
+			Expect(os.ReadFile(strings.TrimSuffix(mismatch.QuarantineReport, ".json") + ".bin")).To(Equal([]byte("hello")))
+		})
+		It("returns an error if the checksum file is missing", func() {
+			Expect(os.WriteFile(filename, []byte("hello world\n"), 0644)).To(Succeed())
+			_, err := iohelper.OpenFileForReadingWithChecksum(filename)
+			Expect(err).To(MatchError(ContainSubstring("no such file or directory")))
+		})
+	})
+	Describe("ReadChecksumFile", func() {
+		It("allows verifying a file while it is read once", func() {
+			Expect(os.WriteFile(filename, []byte("hello world\n"), 0644)).To(Succeed())
+			Expect(iohelper.WriteChecksumFile(filename, helloSHA256)).To(Succeed())
+
+			file, err := iohelper.OpenFileForReading(filename)
+			Expect(err).ToNot(HaveOccurred())
+			defer file.Close()
+			reader := iohelper.NewChecksumReader(file, iohelper.SHA256)
+			contents, err := io.ReadAll(reader)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(Equal("hello world\n"))
+			expected, err := iohelper.ReadChecksumFile(filename)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Sum()).To(Equal(expected))
+		})
+	})
+})
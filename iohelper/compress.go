@@ -0,0 +1,267 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper
+
+/*
+ * This file contains functions for reading and writing files compressed with
+ * gzip, zstd, or lz4 as streams, without running external compression
+ * programs.
+ */
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+type CompressionCodec uint8
+
+const (
+	NO_COMPRESSION CompressionCodec = iota
+	GZIP
+	ZSTD
+	LZ4
+)
+
+/*
+ * The magic bytes at the start of a stream compressed with each codec.  LZ4
+ * streams may also start with a skippable frame, which is not detected.
+ */
+var compressionMagic = map[CompressionCodec][]byte{
+	GZIP: {0x1f, 0x8b},
+	ZSTD: {0x28, 0xb5, 0x2f, 0xfd},
+	LZ4:  {0x04, 0x22, 0x4d, 0x18},
+}
+
+// The number of bytes needed to detect any codec
+const COMPRESSION_MAGIC_LENGTH = 4
+
+func (codec CompressionCodec) String() string {
+	switch codec {
+	case NO_COMPRESSION:
+		return "none"
+	case GZIP:
+		return "gzip"
+	case ZSTD:
+		return "zstd"
+	case LZ4:
+		return "lz4"
+	}
+	return "unknown"
+}
+
+// Extension returns the usual file extension for the codec, including the dot
+func (codec CompressionCodec) Extension() string {
+	switch codec {
+	case GZIP:
+		return ".gz"
+	case ZSTD:
+		return ".zst"
+	case LZ4:
+		return ".lz4"
+	}
+	return ""
+}
+
+func ParseCompressionCodec(name string) (CompressionCodec, error) {
+	for _, codec := range []CompressionCodec{NO_COMPRESSION, GZIP, ZSTD, LZ4} {
+		if strings.EqualFold(name, codec.String()) {
+			return codec, nil
+		}
+	}
+	return NO_COMPRESSION, errors.Errorf("Unknown compression type %q; must be one of none, gzip, zstd, or lz4", name)
+}
+
+/*
+ * DetectCompression returns the codec whose magic bytes header starts with,
+ * or NO_COMPRESSION if there is none.  header should be at least
+ * COMPRESSION_MAGIC_LENGTH bytes long, unless the stream is shorter.
+ */
+func DetectCompression(header []byte) CompressionCodec {
+	for _, codec := range []CompressionCodec{GZIP, ZSTD, LZ4} {
+		if bytes.HasPrefix(header, compressionMagic[codec]) {
+			return codec
+		}
+	}
+	return NO_COMPRESSION
+}
+
+/*
+ * NewCompressedWriter returns a writer that compresses everything written to
+ * it with codec and writes it to writer.  A level of 0 uses the codec's
+ * default; gzip accepts levels 1 to 9 and zstd accepts 1 to 22, as their
+ * command-line tools do, and lz4 has only one level, so the level must be 0
+ * or 1.  Closing the returned writer finishes the compressed stream but does
+ * not close writer.
+ */
+func NewCompressedWriter(writer io.Writer, codec CompressionCodec, level int) (io.WriteCloser, error) {
+	switch codec {
+	case NO_COMPRESSION:
+		if level != 0 {
+			return nil, errors.Errorf("Invalid compression level %d for no compression", level)
+		}
+		return nopWriteCloser{writer}, nil
+	case GZIP:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		} else if level < gzip.BestSpeed || level > gzip.BestCompression {
+			return nil, errors.Errorf("Invalid gzip compression level %d; must be between 1 and 9", level)
+		}
+		return gzip.NewWriterLevel(writer, level)
+	case ZSTD:
+		options := make([]zstd.EOption, 0)
+		if level != 0 {
+			if level < 1 || level > 22 {
+				return nil, errors.Errorf("Invalid zstd compression level %d; must be between 1 and 22", level)
+			}
+			options = append(options, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		return zstd.NewWriter(writer, options...)
+	case LZ4:
+		if level < 0 || level > 1 {
+			return nil, errors.Errorf("Invalid lz4 compression level %d; must be 1", level)
+		}
+		return newLZ4Writer(writer), nil
+	}
+	return nil, errors.Errorf("Unknown compression codec %d", codec)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+/*
+ * compressedFile closes the compressor and then the file, so that the end of
+ * the compressed stream is written before the file is closed.
+ */
+type compressedFile struct {
+	io.WriteCloser
+	file io.Closer
+}
+
+func (compressed compressedFile) Close() error {
+	err := compressed.WriteCloser.Close()
+	if closeErr := compressed.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+/*
+ * OpenCompressedWriter creates or truncates filename and returns a writer
+ * that compresses everything written to it with codec; see
+ * NewCompressedWriter.  The returned writer must be closed to finish the
+ * compressed stream.
+ */
+func OpenCompressedWriter(filename string, codec CompressionCodec, level int) (io.WriteCloser, error) {
+	file, err := OpenFileForWriting(filename)
+	if err != nil {
+		return nil, err
+	}
+	writer, err := NewCompressedWriter(file, codec, level)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return compressedFile{WriteCloser: writer, file: file}, nil
+}
+
+func MustOpenCompressedWriter(filename string, codec CompressionCodec, level int) io.WriteCloser {
+	writer, err := OpenCompressedWriter(filename, codec, level)
+	gplog.FatalOnError(err)
+	return writer
+}
+
+/*
+ * NewAutoDetectReader returns a reader that decompresses reader with the
+ * codec detected from its first bytes, along with that codec.  Input with no
+ * recognized magic bytes is returned unchanged.  Closing the returned reader
+ * releases the decompressor but does not close reader.
+ */
+func NewAutoDetectReader(reader io.Reader) (io.ReadCloser, CompressionCodec, error) {
+	buffered := bufio.NewReader(reader)
+	header, err := buffered.Peek(COMPRESSION_MAGIC_LENGTH)
+	if err != nil && err != io.EOF {
+		return nil, NO_COMPRESSION, errors.Wrap(err, "Unable to read compression header")
+	}
+	codec := DetectCompression(header)
+	switch codec {
+	case GZIP:
+		gzipReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, codec, errors.Wrap(err, "Unable to read gzip header")
+		}
+		return gzipReader, codec, nil
+	case ZSTD:
+		zstdReader, err := zstd.NewReader(buffered, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, codec, errors.Wrap(err, "Unable to read zstd header")
+		}
+		return zstdReader.IOReadCloser(), codec, nil
+	case LZ4:
+		return io.NopCloser(newLZ4Reader(buffered)), codec, nil
+	}
+	return io.NopCloser(buffered), codec, nil
+}
+
+type decompressedFile struct {
+	io.ReadCloser
+	file io.Closer
+}
+
+func (decompressed decompressedFile) Close() error {
+	err := decompressed.ReadCloser.Close()
+	if closeErr := decompressed.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+/*
+ * OpenAutoDetectReader opens filename and returns a reader that decompresses
+ * it with the codec detected from its contents, or reads it unchanged if it
+ * is not compressed.
+ */
+func OpenAutoDetectReader(filename string) (io.ReadCloser, error) {
+	file, err := OpenFileForReading(filename)
+	if err != nil {
+		return nil, err
+	}
+	reader, _, err := NewAutoDetectReader(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, errors.Wrapf(err, "Unable to read %s", filename)
+	}
+	return decompressedFile{ReadCloser: reader, file: file}, nil
+}
+
+func MustOpenAutoDetectReader(filename string) io.ReadCloser {
+	reader, err := OpenAutoDetectReader(filename)
+	gplog.FatalOnError(err)
+	return reader
+}
@@ -0,0 +1,148 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/iohelper"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("iohelper/compress tests", func() {
+	var (
+		tempDir  string
+		contents []byte
+	)
+
+	BeforeEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		tempDir = GinkgoT().TempDir()
+		// Compressible text followed by incompressible bytes, spanning several LZ4 blocks
+		var buffer bytes.Buffer
+		for i := 0; i < 100000; i++ {
+			fmt.Fprintf(&buffer, "row %d of the table, value %d\n", i, i%37)
+		}
+		random := make([]byte, 1<<20)
+		rand.New(rand.NewSource(1)).Read(random)
+		buffer.Write(random)
+		contents = buffer.Bytes()
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("OpenCompressedWriter and OpenAutoDetectReader", func() {
+		DescribeTable("round-trips a file", func(codec iohelper.CompressionCodec, level int) {
+			filename := filepath.Join(tempDir, "data"+codec.Extension())
+			writer, err := iohelper.OpenCompressedWriter(filename, codec, level)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = writer.Write(contents)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.Close()).To(Succeed())
+
+			compressed, err := os.ReadFile(filename)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(iohelper.DetectCompression(compressed)).To(Equal(codec))
+			if codec != iohelper.NO_COMPRESSION {
+				Expect(len(compressed)).To(BeNumerically("<", len(contents)))
+			}
+
+			reader, err := iohelper.OpenAutoDetectReader(filename)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(io.ReadAll(reader)).To(Equal(contents))
+			Expect(reader.Close()).To(Succeed())
+		},
+			Entry("without compression", iohelper.NO_COMPRESSION, 0),
+			Entry("with gzip", iohelper.GZIP, 0),
+			Entry("with gzip at level 1", iohelper.GZIP, 1),
+			Entry("with zstd", iohelper.ZSTD, 0),
+			Entry("with zstd at level 19", iohelper.ZSTD, 19),
+			Entry("with lz4", iohelper.LZ4, 0),
+		)
+		It("round-trips an empty lz4 file", func() {
+			filename := filepath.Join(tempDir, "empty.lz4")
+			Expect(iohelper.MustOpenCompressedWriter(filename, iohelper.LZ4, 0).Close()).To(Succeed())
+			Expect(io.ReadAll(iohelper.MustOpenAutoDetectReader(filename))).To(BeEmpty())
+		})
+		DescribeTable("rejects an invalid level", func(codec iohelper.CompressionCodec, level int, message string) {
+			_, err := iohelper.OpenCompressedWriter(filepath.Join(tempDir, "data"), codec, level)
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+			Entry("for gzip", iohelper.GZIP, 10, "Invalid gzip compression level 10"),
+			Entry("for zstd", iohelper.ZSTD, 23, "Invalid zstd compression level 23"),
+			Entry("for lz4", iohelper.LZ4, 5, "Invalid lz4 compression level 5"),
+		)
+		It("panics if the file cannot be created", func() {
+			defer testhelper.ShouldPanicWithMessage("Unable to create or open file for writing")
+			iohelper.MustOpenCompressedWriter(filepath.Join(tempDir, "missing", "data.gz"), iohelper.GZIP, 0)
+		})
+		It("reads an empty uncompressed file", func() {
+			filename := filepath.Join(tempDir, "empty")
+			Expect(os.WriteFile(filename, nil, 0644)).To(Succeed())
+			Expect(io.ReadAll(iohelper.MustOpenAutoDetectReader(filename))).To(BeEmpty())
+		})
+	})
+	Describe("NewAutoDetectReader", func() {
+		It("reads lz4 written by the lz4 command-line tool", func() {
+			reader, codec, err := iohelper.NewAutoDetectReader(bytes.NewReader(lz4CLIFrame))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(codec).To(Equal(iohelper.LZ4))
+			Expect(io.ReadAll(reader)).To(Equal([]byte("hello hello hello hello hello, linked lz4 blocks\n")))
+		})
+		It("reads concatenated lz4 frames", func() {
+			reader, _, err := iohelper.NewAutoDetectReader(bytes.NewReader(append(append([]byte{}, lz4CLIFrame...), lz4CLIFrame...)))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(io.ReadAll(reader)).To(Equal([]byte(strings.Repeat("hello hello hello hello hello, linked lz4 blocks\n", 2))))
+		})
+		It("returns an error if the lz4 content checksum does not match", func() {
+			corrupted := append([]byte{}, lz4CLIFrame...)
+			corrupted[len(corrupted)-1] ^= 0xff
+			reader, _, err := iohelper.NewAutoDetectReader(bytes.NewReader(corrupted))
+			Expect(err).ToNot(HaveOccurred())
+			_, err = io.ReadAll(reader)
+			Expect(err).To(MatchError("LZ4 content checksum mismatch"))
+		})
+		It("returns an error if the lz4 stream is truncated", func() {
+			reader, _, err := iohelper.NewAutoDetectReader(bytes.NewReader(lz4CLIFrame[:20]))
+			Expect(err).ToNot(HaveOccurred())
+			_, err = io.ReadAll(reader)
+			Expect(err).To(MatchError(ContainSubstring("unexpected EOF")))
+		})
+	})
+	Describe("ParseCompressionCodec", func() {
+		It("parses codec names regardless of case", func() {
+			Expect(iohelper.ParseCompressionCodec("ZSTD")).To(Equal(iohelper.ZSTD))
+			Expect(iohelper.ParseCompressionCodec("none")).To(Equal(iohelper.NO_COMPRESSION))
+		})
+		It("returns an error for an unknown codec", func() {
+			_, err := iohelper.ParseCompressionCodec("bzip2")
+			Expect(err).To(MatchError(`Unknown compression type "bzip2"; must be one of none, gzip, zstd, or lz4`))
+		})
+	})
+})
@@ -0,0 +1,249 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper
+
+/*
+ * This file contains functions for walking, measuring, and copying directory
+ * trees, using the function pointers in operating.System so that they can be
+ * mocked.
+ */
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+/*
+ * Each pattern in Skip is a filepath.Match pattern that is matched against
+ * both the name of each file and its path relative to the root of the walk,
+ * so "*.tmp" skips temporary files anywhere in the tree, while "pg_log/*"
+ * only skips files in the top-level pg_log directory.  Skipping a directory
+ * skips everything in it.
+ */
+type WalkOptions struct {
+	Skip []string
+}
+
+func (options WalkOptions) skips(relPath string) bool {
+	for _, pattern := range options.Skip {
+		if matched, _ := filepath.Match(pattern, filepath.Base(relPath)); matched {
+			return true
+		}
+		if matched, _ := filepath.Match(pattern, relPath); matched {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * Walk calls walkFn for root and each file and directory under it that is
+ * not skipped, visiting each directory before its contents and the contents
+ * of each directory in lexical order.  Symbolic links are reported rather
+ * than followed.  As with filepath.Walk, if walkFn returns filepath.SkipDir
+ * for a directory its contents are skipped, and if it returns SkipDir for a
+ * file the remaining files in that directory are skipped.
+ */
+func Walk(root string, options WalkOptions, walkFn func(path string, info os.FileInfo) error) error {
+	info, err := operating.System.Lstat(root)
+	if err != nil {
+		return errors.Errorf("Unable to walk directory %s: %s", root, err)
+	}
+	err = walk(root, root, info, options, walkFn)
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+func walk(root string, path string, info os.FileInfo, options WalkOptions, walkFn func(path string, info os.FileInfo) error) error {
+	err := walkFn(path, info)
+	if err != nil {
+		if err == filepath.SkipDir && info.IsDir() {
+			return nil
+		}
+		return err
+	}
+	if !info.IsDir() {
+		return nil
+	}
+	entries, err := operating.System.ReadDir(path)
+	if err != nil {
+		return errors.Errorf("Unable to read directory %s: %s", path, err)
+	}
+	for _, entry := range entries {
+		childPath := filepath.Join(path, entry.Name())
+		relPath, _ := filepath.Rel(root, childPath)
+		if options.skips(relPath) {
+			continue
+		}
+		childInfo, err := operating.System.Lstat(childPath)
+		if err != nil {
+			return errors.Errorf("Unable to stat %s: %s", childPath, err)
+		}
+		err = walk(root, childPath, childInfo, options, walkFn)
+		if err == filepath.SkipDir {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SizeOfDir returns the total size of the regular files under root, not following symbolic links
+func SizeOfDir(root string, options WalkOptions) (int64, error) {
+	var size int64
+	err := Walk(root, options, func(path string, info os.FileInfo) error {
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+func MustSizeOfDir(root string, options WalkOptions) int64 {
+	size, err := SizeOfDir(root, options)
+	gplog.FatalOnError(err)
+	return size
+}
+
+/*
+ * If PreserveOwnership is set, copied files and directories are given the
+ * owner and group of the originals, which usually requires running as root;
+ * symbolic links keep the owner of the process.  If Sync is set, each file is
+ * synced to disk before it is closed, so that the copy is durable once CopyDir
+ * returns.  If SpaceCheck is set, dst is checked for room for the files to be
+ * copied before anything is copied; its ExpectedBytes defaults to the size of
+ * those files.
+ */
+type CopyDirOptions struct {
+	WalkOptions
+	PreserveOwnership bool
+	Sync              bool
+	SpaceCheck        SpaceCheckOptions
+}
+
+/*
+ * CopyDir copies the tree at src to dst, creating dst if needed, preserving
+ * permissions and recreating symbolic links rather than copying their
+ * targets.  Existing files in dst are overwritten.  Files other than regular
+ * files, directories, and symbolic links cannot be copied and cause an error
+ * unless they are skipped.
+ */
+func CopyDir(src string, dst string, options CopyDirOptions) error {
+	if err := checkSpaceForCopy(src, dst, options); err != nil {
+		return err
+	}
+	type dirMode struct {
+		path string
+		mode os.FileMode
+	}
+	// Directories are created writable and given their real permissions once their contents are copied
+	dirModes := make([]dirMode, 0)
+	err := Walk(src, options.WalkOptions, func(path string, info os.FileInfo) error {
+		relPath, _ := filepath.Rel(src, path)
+		target := filepath.Join(dst, relPath)
+		mode := info.Mode()
+		var err error
+		switch {
+		case mode.IsDir():
+			err = operating.System.MkdirAll(target, 0700)
+			dirModes = append(dirModes, dirMode{path: target, mode: mode.Perm()})
+		case mode.IsRegular():
+			err = copyFile(path, target, mode.Perm(), options.Sync)
+		case mode&os.ModeSymlink != 0:
+			err = copySymlink(path, target)
+		default:
+			return errors.Errorf("Unable to copy %s: unsupported file type %s", path, mode.Type())
+		}
+		if err != nil {
+			return err
+		}
+		if options.PreserveOwnership && mode&os.ModeSymlink == 0 {
+			if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+				if err := operating.System.Chown(target, int(stat.Uid), int(stat.Gid)); err != nil {
+					return errors.Errorf("Unable to set ownership of %s: %s", target, err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i := len(dirModes) - 1; i >= 0; i-- {
+		if err := operating.System.Chmod(dirModes[i].path, dirModes[i].mode); err != nil {
+			return errors.Errorf("Unable to set permissions of %s: %s", dirModes[i].path, err)
+		}
+	}
+	return nil
+}
+
+func MustCopyDir(src string, dst string, options CopyDirOptions) {
+	err := CopyDir(src, dst, options)
+	gplog.FatalOnError(err)
+}
+
+func copyFile(src string, dst string, perm os.FileMode, sync bool) error {
+	reader, err := OpenFileForReading(src)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	writer, err := operating.System.OpenFileWrite(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return errors.Errorf("Unable to create or open file for writing: %s", err)
+	}
+	_, err = io.Copy(writer, reader)
+	if err == nil && sync {
+		if syncer, ok := writer.(interface{ Sync() error }); ok {
+			err = syncer.Sync()
+		}
+	}
+	closeErr := writer.Close()
+	if err != nil {
+		return errors.Errorf("Unable to copy %s to %s: %s", src, dst, err)
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	// The file was created subject to the umask, and an existing file keeps its old permissions
+	return operating.System.Chmod(dst, perm)
+}
+
+func copySymlink(src string, dst string) error {
+	linkTarget, err := operating.System.Readlink(src)
+	if err != nil {
+		return errors.Errorf("Unable to read symbolic link %s: %s", src, err)
+	}
+	if err := operating.System.Remove(dst); err != nil && !operating.System.IsNotExist(err) {
+		return errors.Errorf("Unable to replace %s: %s", dst, err)
+	}
+	if err := operating.System.Symlink(linkTarget, dst); err != nil {
+		return errors.Errorf("Unable to create symbolic link %s: %s", dst, err)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/apache/cloudberry-go-libs/iohelper"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("iohelper/dirtree tests", func() {
+	var (
+		tempDir string
+		srcDir  string
+	)
+
+	writeFile := func(relPath string, contents string, perm os.FileMode) {
+		path := filepath.Join(srcDir, relPath)
+		Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+		Expect(os.WriteFile(path, []byte(contents), perm)).To(Succeed())
+		Expect(os.Chmod(path, perm)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		tempDir = GinkgoT().TempDir()
+		srcDir = filepath.Join(tempDir, "src")
+		writeFile("PG_VERSION", "14", 0600)
+		writeFile("base/1/1259", "12345678", 0600)
+		writeFile("base/1/1259.tmp", "xx", 0600)
+		writeFile("pg_log/startup.log", "log contents", 0644)
+		writeFile("scripts/run.sh", "#!/bin/bash", 0755)
+		Expect(os.Symlink("../PG_VERSION", filepath.Join(srcDir, "scripts", "version"))).To(Succeed())
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("Walk", func() {
+		It("visits each directory before its contents, in lexical order, without following links", func() {
+			visited := make([]string, 0)
+			err := iohelper.Walk(srcDir, iohelper.WalkOptions{}, func(path string, info os.FileInfo) error {
+				relPath, _ := filepath.Rel(srcDir, path)
+				visited = append(visited, relPath)
+				return nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(visited).To(Equal([]string{".", "PG_VERSION", "base", "base/1", "base/1/1259", "base/1/1259.tmp",
+				"pg_log", "pg_log/startup.log", "scripts", "scripts/run.sh", "scripts/version"}))
+		})
+		It("skips files and directories matching the skip patterns", func() {
+			visited := make([]string, 0)
+			err := iohelper.Walk(srcDir, iohelper.WalkOptions{Skip: []string{"*.tmp", "pg_log", "scripts/*"}}, func(path string, info os.FileInfo) error {
+				relPath, _ := filepath.Rel(srcDir, path)
+				visited = append(visited, relPath)
+				return nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(visited).To(Equal([]string{".", "PG_VERSION", "base", "base/1", "base/1/1259", "scripts"}))
+		})
+		It("skips the contents of a directory when the walk function returns SkipDir", func() {
+			visited := make([]string, 0)
+			err := iohelper.Walk(srcDir, iohelper.WalkOptions{}, func(path string, info os.FileInfo) error {
+				visited = append(visited, filepath.Base(path))
+				if info.IsDir() && info.Name() == "base" {
+					return filepath.SkipDir
+				}
+				return nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(visited).ToNot(ContainElement("1259"))
+			Expect(visited).To(ContainElement("startup.log"))
+		})
+		It("returns errors from the walk function", func() {
+			err := iohelper.Walk(srcDir, iohelper.WalkOptions{}, func(path string, info os.FileInfo) error {
+				return errors.New("stop")
+			})
+			Expect(err).To(MatchError("stop"))
+		})
+		It("returns an error if a directory cannot be read", func() {
+			operating.System.ReadDir = func(name string) ([]os.DirEntry, error) {
+				return nil, os.ErrPermission
+			}
+			err := iohelper.Walk(srcDir, iohelper.WalkOptions{}, func(path string, info os.FileInfo) error { return nil })
+			Expect(err).To(MatchError("Unable to read directory " + srcDir + ": permission denied"))
+		})
+	})
+	Describe("SizeOfDir", func() {
+		It("adds up the sizes of regular files that are not skipped", func() {
+			Expect(iohelper.SizeOfDir(srcDir, iohelper.WalkOptions{})).To(Equal(int64(2 + 8 + 2 + 12 + 11)))
+			Expect(iohelper.SizeOfDir(srcDir, iohelper.WalkOptions{Skip: []string{"*.tmp"}})).To(Equal(int64(2 + 8 + 12 + 11)))
+		})
+		It("panics in the Must variant if the directory does not exist", func() {
+			defer testhelper.ShouldPanicWithMessage("Unable to walk directory")
+			iohelper.MustSizeOfDir(filepath.Join(tempDir, "missing"), iohelper.WalkOptions{})
+		})
+	})
+	Describe("CopyDir", func() {
+		var dstDir string
+		BeforeEach(func() {
+			dstDir = filepath.Join(tempDir, "dst")
+		})
+		It("copies files, permissions, and symbolic links", func() {
+			Expect(os.Chmod(filepath.Join(srcDir, "base"), 0500)).To(Succeed())
+			defer os.Chmod(filepath.Join(srcDir, "base"), 0755)
+			Expect(iohelper.CopyDir(srcDir, dstDir, iohelper.CopyDirOptions{Sync: true})).To(Succeed())
+			defer os.Chmod(filepath.Join(dstDir, "base"), 0755)
+
+			Expect(os.ReadFile(filepath.Join(dstDir, "base", "1", "1259"))).To(Equal([]byte("12345678")))
+			info, err := os.Stat(filepath.Join(dstDir, "scripts", "run.sh"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0755)))
+			info, err = os.Stat(filepath.Join(dstDir, "PG_VERSION"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+			info, err = os.Stat(filepath.Join(dstDir, "base"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0500)))
+			Expect(os.Readlink(filepath.Join(dstDir, "scripts", "version"))).To(Equal("../PG_VERSION"))
+		})
+		It("skips files matching the skip patterns and overwrites existing files", func() {
+			Expect(os.MkdirAll(filepath.Join(dstDir, "scripts"), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dstDir, "PG_VERSION"), []byte("old contents"), 0644)).To(Succeed())
+			Expect(os.Symlink("elsewhere", filepath.Join(dstDir, "scripts", "version"))).To(Succeed())
+
+			Expect(iohelper.CopyDir(srcDir, dstDir, iohelper.CopyDirOptions{WalkOptions: iohelper.WalkOptions{Skip: []string{"pg_log"}}})).To(Succeed())
+			Expect(os.ReadFile(filepath.Join(dstDir, "PG_VERSION"))).To(Equal([]byte("14")))
+			Expect(os.Readlink(filepath.Join(dstDir, "scripts", "version"))).To(Equal("../PG_VERSION"))
+			Expect(filepath.Join(dstDir, "pg_log")).ToNot(BeAnExistingFile())
+		})
+		It("preserves ownership if requested", func() {
+			chowned := make(map[string]int)
+			operating.System.Chown = func(name string, uid, gid int) error {
+				chowned[name] = uid
+				return nil
+			}
+			Expect(iohelper.CopyDir(srcDir, dstDir, iohelper.CopyDirOptions{PreserveOwnership: true})).To(Succeed())
+			Expect(chowned).To(HaveKeyWithValue(filepath.Join(dstDir, "base", "1", "1259"), os.Getuid()))
+			Expect(chowned).To(HaveKey(dstDir))
+			Expect(chowned).ToNot(HaveKey(filepath.Join(dstDir, "scripts", "version")))
+		})
+		It("returns an error for unsupported file types", func() {
+			Expect(syscall.Mkfifo(filepath.Join(srcDir, "pipe"), 0600)).To(Succeed())
+			err := iohelper.CopyDir(srcDir, dstDir, iohelper.CopyDirOptions{})
+			Expect(err).To(MatchError(ContainSubstring("Unable to copy " + filepath.Join(srcDir, "pipe") + ": unsupported file type")))
+
+			Expect(iohelper.CopyDir(srcDir, dstDir, iohelper.CopyDirOptions{WalkOptions: iohelper.WalkOptions{Skip: []string{"pipe"}}})).To(Succeed())
+		})
+	})
+	Describe("with a fixture filesystem", func() {
+		var tempFS *testhelper.TempFS
+		BeforeEach(func() {
+			tempFS = testhelper.NewTempFS(GinkgoT())
+			tempFS.WriteFile("/data/gpseg0/PG_VERSION", "14", 0600)
+			tempFS.WriteFile("/data/gpseg0/base/1/1259", "12345678", 0600)
+			tempFS.Symlink("/data/gpseg0/PG_VERSION", "/data/gpseg0/version")
+			tempFS.Install()
+		})
+		It("walks and copies absolute paths within the fixture tree", func() {
+			size, err := iohelper.SizeOfDir("/data/gpseg0", iohelper.WalkOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(size).To(Equal(int64(10)))
+
+			Expect(iohelper.CopyDir("/data/gpseg0", "/backup/gpseg0", iohelper.CopyDirOptions{})).To(Succeed())
+			Expect(tempFS.ReadFile("/backup/gpseg0/base/1/1259")).To(Equal("12345678"))
+			Expect(operating.System.Readlink("/backup/gpseg0/version")).To(Equal("/data/gpseg0/PG_VERSION"))
+			Expect(filepath.Join("/backup", "gpseg0")).ToNot(BeADirectory())
+		})
+		It("reports paths in errors as the code under test sees them", func() {
+			err := iohelper.CopyDir("/data/gpseg1", "/backup/gpseg1", iohelper.CopyDirOptions{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("lstat /data/gpseg1: no such file or directory"))
+			Expect(err.Error()).ToNot(ContainSubstring(tempFS.Root()))
+		})
+		It("creates files through operating.System within the tree", func() {
+			Expect(iohelper.WriteChecksumFile("/data/gpseg0/PG_VERSION", "abc")).To(Succeed())
+			Expect(tempFS.Exists("/data/gpseg0/PG_VERSION" + iohelper.CHECKSUM_FILE_EXTENSION)).To(BeTrue())
+			matches, err := operating.System.Glob("/data/gpseg0/*.sha256")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(matches).To(Equal([]string{"/data/gpseg0/PG_VERSION.sha256"}))
+		})
+	})
+})
@@ -0,0 +1,134 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper
+
+/*
+ * This file contains functions for creating, opening, and cleaning up the
+ * named pipes used to stream data between utilities and the database.
+ */
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// How often OpenFifoWriterNonBlocking checks whether a reader has opened the pipe
+var FifoPollInterval = 10 * time.Millisecond
+
+/*
+ * CreateFifo creates a named pipe at path.  If a named pipe already exists at
+ * path it is reused, but any other kind of file there is an error.
+ */
+func CreateFifo(path string, perm os.FileMode) error {
+	var err error
+	for {
+		err = unix.Mkfifo(path, uint32(perm.Perm()))
+		if !errors.Is(err, unix.EINTR) {
+			break
+		}
+	}
+	if errors.Is(err, unix.EEXIST) {
+		info, statErr := operating.System.Stat(path)
+		if statErr == nil && info.Mode()&os.ModeNamedPipe != 0 {
+			return nil
+		}
+		return errors.Errorf("Unable to create named pipe %s: file exists and is not a named pipe", path)
+	}
+	if err != nil {
+		return errors.Errorf("Unable to create named pipe %s: %s", path, err)
+	}
+	return nil
+}
+
+func MustCreateFifo(path string, perm os.FileMode) {
+	err := CreateFifo(path, perm)
+	gplog.FatalOnError(err)
+}
+
+/*
+ * OpenFifoWriterNonBlocking opens a named pipe for writing without blocking
+ * indefinitely when nothing has opened it for reading, as a plain open would,
+ * e.g. if the process that should read from the pipe failed to start.  It
+ * retries every FifoPollInterval until a reader opens the pipe, and returns
+ * an error if none has after timeout.
+ */
+func OpenFifoWriterNonBlocking(path string, timeout time.Duration) (io.WriteCloser, error) {
+	deadline := operating.System.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := operating.System.NewTicker(FifoPollInterval)
+	defer ticker.Stop()
+	for {
+		writer, err := operating.System.OpenFileWrite(path, os.O_WRONLY|unix.O_NONBLOCK, 0)
+		if err == nil {
+			return writer, nil
+		}
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if !errors.Is(err, unix.ENXIO) {
+			return nil, errors.Errorf("Unable to open named pipe %s for writing: %s", path, err)
+		}
+		select {
+		case <-deadline.C():
+			return nil, errors.Errorf("Timed out after %s waiting for a reader to open named pipe %s", timeout, path)
+		case <-ticker.C():
+		}
+	}
+}
+
+func MustOpenFifoWriterNonBlocking(path string, timeout time.Duration) io.WriteCloser {
+	writer, err := OpenFifoWriterNonBlocking(path, timeout)
+	gplog.FatalOnError(err)
+	return writer
+}
+
+/*
+ * CleanupFifos removes the named pipes matching pattern, such as those left
+ * behind by a utility that was killed, and returns the paths removed.  Other
+ * files matching pattern are left alone.  It continues past errors removing
+ * individual pipes and returns the first one.
+ */
+func CleanupFifos(pattern string) ([]string, error) {
+	matches, err := operating.System.Glob(pattern)
+	if err != nil {
+		return nil, errors.Errorf("Invalid named pipe pattern %s: %s", pattern, err)
+	}
+	removed := make([]string, 0)
+	var firstErr error
+	for _, path := range matches {
+		info, err := operating.System.Stat(path)
+		if err != nil || info.Mode()&os.ModeNamedPipe == 0 {
+			continue
+		}
+		err = operating.System.Remove(path)
+		if err != nil && !operating.System.IsNotExist(err) {
+			if firstErr == nil {
+				firstErr = errors.Errorf("Unable to remove named pipe %s: %s", path, err)
+			}
+			continue
+		}
+		removed = append(removed, path)
+	}
+	return removed, firstErr
+}
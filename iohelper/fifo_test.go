@@ -0,0 +1,138 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/iohelper"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("iohelper/fifo tests", func() {
+	var (
+		tempDir  string
+		fifoPath string
+	)
+
+	BeforeEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		tempDir = GinkgoT().TempDir()
+		fifoPath = filepath.Join(tempDir, "gpbackup_pipe_0")
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("CreateFifo", func() {
+		It("creates a named pipe", func() {
+			Expect(iohelper.CreateFifo(fifoPath, 0600)).To(Succeed())
+			info, err := os.Stat(fifoPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.Mode() & os.ModeNamedPipe).ToNot(BeZero())
+		})
+		It("reuses an existing named pipe", func() {
+			Expect(iohelper.CreateFifo(fifoPath, 0600)).To(Succeed())
+			Expect(iohelper.CreateFifo(fifoPath, 0600)).To(Succeed())
+		})
+		It("returns an error if a regular file exists at the path", func() {
+			Expect(os.WriteFile(fifoPath, []byte("data"), 0644)).To(Succeed())
+			Expect(iohelper.CreateFifo(fifoPath, 0600)).To(MatchError("Unable to create named pipe " + fifoPath + ": file exists and is not a named pipe"))
+		})
+		It("panics in the Must variant if the directory does not exist", func() {
+			defer testhelper.ShouldPanicWithMessage("Unable to create named pipe")
+			iohelper.MustCreateFifo(filepath.Join(tempDir, "missing", "pipe"), 0600)
+		})
+	})
+	Describe("OpenFifoWriterNonBlocking", func() {
+		BeforeEach(func() {
+			Expect(iohelper.CreateFifo(fifoPath, 0600)).To(Succeed())
+		})
+		It("opens the pipe once a reader has opened it", func() {
+			reader, err := os.OpenFile(fifoPath, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+			Expect(err).ToNot(HaveOccurred())
+			defer reader.Close()
+
+			writer, err := iohelper.OpenFifoWriterNonBlocking(fifoPath, time.Second)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = io.WriteString(writer, "streamed data")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.Close()).To(Succeed())
+			Expect(io.ReadAll(reader)).To(Equal([]byte("streamed data")))
+		})
+		It("waits for a reader to open the pipe", func() {
+			clock := testhelper.NewFakeClock(time.Now())
+			clock.Install()
+			readerOpened := make(chan *os.File, 1)
+			go func() {
+				defer GinkgoRecover()
+				clock.BlockUntil(2)
+				reader, err := os.OpenFile(fifoPath, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+				Expect(err).ToNot(HaveOccurred())
+				readerOpened <- reader
+				clock.Advance(iohelper.FifoPollInterval)
+			}()
+
+			writer, err := iohelper.OpenFifoWriterNonBlocking(fifoPath, time.Minute)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.Close()).To(Succeed())
+			Expect((<-readerOpened).Close()).To(Succeed())
+		})
+		It("returns an error if no reader opens the pipe before the timeout", func() {
+			clock := testhelper.NewFakeClock(time.Now())
+			clock.Install()
+			go func() {
+				clock.BlockUntil(2)
+				clock.Advance(time.Minute)
+			}()
+			_, err := iohelper.OpenFifoWriterNonBlocking(fifoPath, time.Minute)
+			Expect(err).To(MatchError("Timed out after 1m0s waiting for a reader to open named pipe " + fifoPath))
+		})
+		It("returns an error if the pipe does not exist", func() {
+			_, err := iohelper.OpenFifoWriterNonBlocking(filepath.Join(tempDir, "missing"), time.Second)
+			Expect(err).To(MatchError(ContainSubstring("Unable to open named pipe")))
+		})
+	})
+	Describe("CleanupFifos", func() {
+		It("removes only named pipes matching the pattern", func() {
+			Expect(iohelper.CreateFifo(fifoPath, 0600)).To(Succeed())
+			Expect(iohelper.CreateFifo(filepath.Join(tempDir, "gpbackup_pipe_1"), 0600)).To(Succeed())
+			Expect(iohelper.CreateFifo(filepath.Join(tempDir, "other_pipe"), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(tempDir, "gpbackup_pipe_2"), []byte("data"), 0644)).To(Succeed())
+
+			removed, err := iohelper.CleanupFifos(filepath.Join(tempDir, "gpbackup_pipe_*"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(removed).To(ConsistOf(fifoPath, filepath.Join(tempDir, "gpbackup_pipe_1")))
+			Expect(fifoPath).ToNot(BeAnExistingFile())
+			Expect(filepath.Join(tempDir, "other_pipe")).To(BeAnExistingFile())
+			Expect(filepath.Join(tempDir, "gpbackup_pipe_2")).To(BeAnExistingFile())
+		})
+		It("returns an error for an invalid pattern", func() {
+			_, err := iohelper.CleanupFifos("[")
+			Expect(err).To(MatchError("Invalid named pipe pattern [: syntax error in pattern"))
+		})
+	})
+})
@@ -0,0 +1,73 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper
+
+/*
+ * This file contains functions for checking that a set of files can be
+ * written to a directory under the names given, before any data is written,
+ * so that names that collide or are too long on the target filesystem are
+ * reported up front rather than partway through a write.
+ */
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/gperror"
+	"github.com/apache/cloudberry-go-libs/operating"
+)
+
+const (
+	FILE_NAME_COLLISION gperror.ErrorCode = 5005
+	FILE_NAME_TOO_LONG  gperror.ErrorCode = 5006
+)
+
+/*
+ * CheckFileNames checks the given names, which are relative to dir and may
+ * contain subdirectories, against the limits of the filesystem containing
+ * dir.  It returns a FILE_NAME_TOO_LONG error if a name or one of its
+ * components is longer than the filesystem allows, or a FILE_NAME_COLLISION
+ * error if two names would be the same file because the filesystem is not
+ * case-sensitive.  dir must exist, as the limits are found by probing it.
+ */
+func CheckFileNames(dir string, names []string) error {
+	limits, err := operating.System.PathLimits(dir)
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]string, len(names))
+	for _, name := range names {
+		for _, component := range strings.Split(filepath.ToSlash(name), "/") {
+			if len(component) > limits.MaxNameLength {
+				return gperror.New(FILE_NAME_TOO_LONG, "Cannot write %s to %s: %s is %d bytes long, but the filesystem allows at most %d", name, dir, component, len(component), limits.MaxNameLength)
+			}
+		}
+		if path := filepath.Join(dir, name); len(path) > limits.MaxPathLength {
+			return gperror.New(FILE_NAME_TOO_LONG, "Cannot write %s to %s: the path is %d bytes long, but the filesystem allows at most %d", name, dir, len(path), limits.MaxPathLength)
+		}
+		if limits.CaseSensitive {
+			continue
+		}
+		key := strings.ToLower(name)
+		if other, ok := seen[key]; ok && other != name {
+			return gperror.New(FILE_NAME_COLLISION, "Cannot write both %s and %s to %s: the filesystem is not case-sensitive, so they would be the same file", other, name, dir)
+		}
+		seen[key] = name
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper_test
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/gperror"
+	"github.com/apache/cloudberry-go-libs/iohelper"
+	"github.com/apache/cloudberry-go-libs/operating"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("iohelper/filenames tests", func() {
+	var limits operating.PathLimits
+
+	BeforeEach(func() {
+		limits = operating.PathLimits{CaseSensitive: true, MaxNameLength: 255, MaxPathLength: 4096}
+		operating.System.PathLimits = func(dir string) (operating.PathLimits, error) { return limits, nil }
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("CheckFileNames", func() {
+		It("accepts names that differ only in case on a case-sensitive filesystem", func() {
+			Expect(iohelper.CheckFileNames("/backups", []string{"public/Orders.dat", "public/orders.dat"})).To(Succeed())
+		})
+		It("returns an error for names that differ only in case on a case-insensitive filesystem", func() {
+			limits.CaseSensitive = false
+			err := iohelper.CheckFileNames("/backups", []string{"public/Orders.dat", "public/items.dat", "public/orders.dat"})
+			Expect(err).To(gperror.MatchCode(iohelper.FILE_NAME_COLLISION))
+			Expect(err.Error()).To(ContainSubstring("Cannot write both public/Orders.dat and public/orders.dat to /backups"))
+		})
+		It("accepts a name given more than once on a case-insensitive filesystem", func() {
+			limits.CaseSensitive = false
+			Expect(iohelper.CheckFileNames("/backups", []string{"public/orders.dat", "public/orders.dat"})).To(Succeed())
+		})
+		It("returns an error for a path component longer than the filesystem allows", func() {
+			longName := strings.Repeat("t", 256)
+			err := iohelper.CheckFileNames("/backups", []string{"public/" + longName + ".dat"})
+			Expect(err).To(gperror.MatchCode(iohelper.FILE_NAME_TOO_LONG))
+			Expect(err.Error()).To(ContainSubstring("is 260 bytes long, but the filesystem allows at most 255"))
+		})
+		It("returns an error for a path longer than the filesystem allows", func() {
+			limits.MaxPathLength = 32
+			err := iohelper.CheckFileNames("/backups", []string{"public/orders.dat", "customer_schema/customer_orders.dat"})
+			Expect(err).To(gperror.MatchCode(iohelper.FILE_NAME_TOO_LONG))
+			Expect(err.Error()).To(ContainSubstring("the path is 44 bytes long, but the filesystem allows at most 32"))
+		})
+		It("returns an error if the filesystem cannot be probed", func() {
+			operating.System.PathLimits = func(dir string) (operating.PathLimits, error) {
+				return operating.PathLimits{}, errors.New("permission denied")
+			}
+			Expect(iohelper.CheckFileNames("/backups", []string{"public/orders.dat"})).To(MatchError("permission denied"))
+		})
+	})
+	Describe("operating.ProbePathLimits", func() {
+		It("finds the limits of a writable directory and leaves nothing behind", func() {
+			dir := GinkgoT().TempDir()
+			probed, err := operating.ProbePathLimits(dir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(probed.MaxNameLength).To(BeNumerically(">", 0))
+			Expect(probed.MaxPathLength).To(BeNumerically(">", 0))
+			entries, err := operating.System.ReadDir(dir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+	})
+})
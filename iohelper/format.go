@@ -0,0 +1,185 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper
+
+/*
+ * This file contains functions for identifying the format of a stream from
+ * its contents, for tools that cannot rely on file extensions alone.
+ */
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+type FileFormat uint8
+
+const (
+	FORMAT_UNKNOWN FileFormat = iota
+	FORMAT_GZIP
+	FORMAT_ZSTD
+	FORMAT_LZ4
+	FORMAT_TAR
+	FORMAT_SQL
+	FORMAT_CSV
+	FORMAT_TEXT
+)
+
+/*
+ * The number of bytes DetectFormat examines.  It must be more than 262 to
+ * see the magic bytes in a tar header, and is long enough to hold several
+ * lines of a CSV file.
+ */
+const FORMAT_SNIFF_LENGTH = 4096
+
+// The magic bytes of a POSIX tar archive, at tarMagicOffset in the first header
+var tarMagic = []byte("ustar")
+
+const tarMagicOffset = 257
+
+/*
+ * A plain SQL file, such as one written by pg_dump, usually starts with a
+ * comment, a psql meta-command, or one of these statements.
+ */
+var sqlKeywords = []string{"ALTER", "BEGIN", "COMMENT", "COPY", "CREATE", "DROP", "GRANT", "INSERT", "REVOKE", "SELECT", "SET", "START", "TRUNCATE", "UPDATE"}
+
+// The delimiters DetectFormat tries, in order, when checking for CSV
+var csvDelimiters = []rune{',', '\t', '|'}
+
+func (format FileFormat) String() string {
+	switch format {
+	case FORMAT_UNKNOWN:
+		return "unknown"
+	case FORMAT_GZIP:
+		return "gzip"
+	case FORMAT_ZSTD:
+		return "zstd"
+	case FORMAT_LZ4:
+		return "lz4"
+	case FORMAT_TAR:
+		return "tar"
+	case FORMAT_SQL:
+		return "sql"
+	case FORMAT_CSV:
+		return "csv"
+	case FORMAT_TEXT:
+		return "text"
+	}
+	return "invalid"
+}
+
+// IsCompressed returns true if format is one that NewAutoDetectReader decompresses
+func (format FileFormat) IsCompressed() bool {
+	return format == FORMAT_GZIP || format == FORMAT_ZSTD || format == FORMAT_LZ4
+}
+
+/*
+ * DetectFormat peeks at up to FORMAT_SNIFF_LENGTH bytes of reader to identify
+ * its format, and returns the format along with a reader that returns every
+ * byte of reader, including those peeked at.  reader should not be read
+ * directly afterward.
+ *
+ * Compressed streams are identified by their codec, not by the format of
+ * their contents; to find that, pass the reader returned by
+ * NewAutoDetectReader to DetectFormat again.  Text is identified as SQL or
+ * CSV by looking at its first lines, so a CSV file of a single column, for
+ * example, is only identified as FORMAT_TEXT.  Empty input and input that is
+ * not valid UTF-8 are FORMAT_UNKNOWN.
+ */
+func DetectFormat(reader io.Reader) (FileFormat, io.Reader, error) {
+	buffered := bufio.NewReaderSize(reader, FORMAT_SNIFF_LENGTH)
+	header, err := buffered.Peek(FORMAT_SNIFF_LENGTH)
+	if err != nil && err != io.EOF {
+		return FORMAT_UNKNOWN, nil, errors.Wrap(err, "Unable to read format header")
+	}
+	return detectFormat(header, err == io.EOF), buffered, nil
+}
+
+// complete is true if header holds the whole stream, rather than just its start
+func detectFormat(header []byte, complete bool) FileFormat {
+	switch DetectCompression(header) {
+	case GZIP:
+		return FORMAT_GZIP
+	case ZSTD:
+		return FORMAT_ZSTD
+	case LZ4:
+		return FORMAT_LZ4
+	}
+	if len(header) >= tarMagicOffset+len(tarMagic) && bytes.Equal(header[tarMagicOffset:tarMagicOffset+len(tarMagic)], tarMagic) {
+		return FORMAT_TAR
+	}
+	if !complete {
+		// Only examine complete lines, so that a multi-byte character or a CSV record is not cut off
+		if lastNewline := bytes.LastIndexByte(header, '\n'); lastNewline >= 0 {
+			header = header[:lastNewline+1]
+		} else {
+			for i := 0; i < utf8.UTFMax && len(header) > 0 && !utf8.Valid(header); i++ {
+				header = header[:len(header)-1]
+			}
+		}
+	}
+	if len(header) == 0 || !utf8.Valid(header) || bytes.IndexByte(header, 0) >= 0 {
+		return FORMAT_UNKNOWN
+	}
+	if looksLikeSQL(header) {
+		return FORMAT_SQL
+	} else if looksLikeCSV(header) {
+		return FORMAT_CSV
+	}
+	return FORMAT_TEXT
+}
+
+func looksLikeSQL(text []byte) bool {
+	text = bytes.TrimLeft(text, " \t\r\n")
+	if bytes.HasPrefix(text, []byte("--")) || bytes.HasPrefix(text, []byte("/*")) || bytes.HasPrefix(text, []byte("\\")) {
+		return true
+	}
+	end := bytes.IndexFunc(text, func(r rune) bool { return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') })
+	if end < 0 {
+		end = len(text)
+	}
+	word := text[:end]
+	for _, keyword := range sqlKeywords {
+		if bytes.EqualFold(word, []byte(keyword)) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * looksLikeCSV returns true if every line of text parses as a record with the
+ * same number of fields, and there are at least two, using any one of
+ * csvDelimiters.
+ */
+func looksLikeCSV(text []byte) bool {
+	for _, delimiter := range csvDelimiters {
+		csvReader := csv.NewReader(bytes.NewReader(text))
+		csvReader.Comma = delimiter
+		records, err := csvReader.ReadAll()
+		if err == nil && len(records) > 0 && len(records[0]) > 1 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"strings"
+	"testing/iotest"
+
+	"github.com/apache/cloudberry-go-libs/iohelper"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("iohelper/format tests", func() {
+	compress := func(contents string, codec iohelper.CompressionCodec) []byte {
+		var buffer bytes.Buffer
+		writer, err := iohelper.NewCompressedWriter(&buffer, codec, 0)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = writer.Write([]byte(contents))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(writer.Close()).To(Succeed())
+		return buffer.Bytes()
+	}
+	archive := func() []byte {
+		var buffer bytes.Buffer
+		writer := tar.NewWriter(&buffer)
+		Expect(writer.WriteHeader(&tar.Header{Name: "toc.dat", Mode: 0600, Size: 5})).To(Succeed())
+		_, err := writer.Write([]byte("hello"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(writer.Close()).To(Succeed())
+		return buffer.Bytes()
+	}
+
+	Describe("DetectFormat", func() {
+		DescribeTable("identifies the format and returns every byte of the input",
+			func(contents func() []byte, expected iohelper.FileFormat) {
+				input := contents()
+				format, reader, err := iohelper.DetectFormat(bytes.NewReader(input))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(format).To(Equal(expected))
+				output, err := io.ReadAll(reader)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(output).To(Equal(input))
+			},
+			Entry("gzip", func() []byte { return compress("data", iohelper.GZIP) }, iohelper.FORMAT_GZIP),
+			Entry("zstd", func() []byte { return compress("data", iohelper.ZSTD) }, iohelper.FORMAT_ZSTD),
+			Entry("lz4", func() []byte { return compress("data", iohelper.LZ4) }, iohelper.FORMAT_LZ4),
+			Entry("tar", archive, iohelper.FORMAT_TAR),
+			Entry("pg_dump output", func() []byte {
+				return []byte("--\n-- Greenplum Database database dump\n--\n\nSET statement_timeout = 0;\n")
+			}, iohelper.FORMAT_SQL),
+			Entry("SQL starting with a statement", func() []byte { return []byte("  create table foo (i int);\n") }, iohelper.FORMAT_SQL),
+			Entry("SQL starting with a psql meta-command", func() []byte { return []byte("\\connect postgres\n") }, iohelper.FORMAT_SQL),
+			Entry("comma-separated values", func() []byte { return []byte("id,name\n1,\"Smith, J\"\n2,Jones\n") }, iohelper.FORMAT_CSV),
+			Entry("tab-separated values", func() []byte { return []byte("1\tone\n2\ttwo\n") }, iohelper.FORMAT_CSV),
+			Entry("pipe-separated values", func() []byte { return []byte("1|one\n2|two") }, iohelper.FORMAT_CSV),
+			Entry("lines with different numbers of fields", func() []byte { return []byte("a,b\nc\n") }, iohelper.FORMAT_TEXT),
+			Entry("plain text", func() []byte { return []byte("just some notes\n") }, iohelper.FORMAT_TEXT),
+			Entry("binary data", func() []byte { return []byte{0x00, 0x01, 0x02, 0xff} }, iohelper.FORMAT_UNKNOWN),
+			Entry("invalid UTF-8", func() []byte { return []byte("caf\xe9\n") }, iohelper.FORMAT_UNKNOWN),
+			Entry("empty input", func() []byte { return []byte{} }, iohelper.FORMAT_UNKNOWN),
+		)
+		It("only examines complete lines of a long input", func() {
+			// The sniffed bytes end partway through the multi-byte character and the last record
+			input := strings.Repeat("1,é\n", iohelper.FORMAT_SNIFF_LENGTH)
+			format, reader, err := iohelper.DetectFormat(strings.NewReader(input))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(format).To(Equal(iohelper.FORMAT_CSV))
+			output, err := io.ReadAll(reader)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(output)).To(Equal(input))
+		})
+		It("identifies a single long line cut off partway through a character", func() {
+			input := "x" + strings.Repeat("é", iohelper.FORMAT_SNIFF_LENGTH)
+			format, _, err := iohelper.DetectFormat(strings.NewReader(input))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(format).To(Equal(iohelper.FORMAT_TEXT))
+		})
+		It("identifies the contents of a compressed stream after decompressing it", func() {
+			format, reader, err := iohelper.DetectFormat(bytes.NewReader(compress("1,one\n2,two\n", iohelper.ZSTD)))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(format.IsCompressed()).To(BeTrue())
+			decompressed, _, err := iohelper.NewAutoDetectReader(reader)
+			Expect(err).ToNot(HaveOccurred())
+			defer decompressed.Close()
+			format, _, err = iohelper.DetectFormat(decompressed)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(format).To(Equal(iohelper.FORMAT_CSV))
+		})
+		It("returns an error if the input cannot be read", func() {
+			_, _, err := iohelper.DetectFormat(iotest.ErrReader(errors.New("read failed")))
+			Expect(err).To(MatchError("Unable to read format header: read failed"))
+		})
+	})
+	Describe("FileFormat", func() {
+		It("has a name for each format", func() {
+			Expect(iohelper.FORMAT_TAR.String()).To(Equal("tar"))
+			Expect(iohelper.FORMAT_CSV.String()).To(Equal("csv"))
+			Expect(iohelper.FileFormat(200).String()).To(Equal("invalid"))
+		})
+	})
+})
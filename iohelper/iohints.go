@@ -0,0 +1,310 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper
+
+/*
+ * This file contains functions for opening files for large sequential reads
+ * and writes, such as backups, with hints that keep them from evicting the
+ * database's pages from the operating system's page cache.
+ *
+ * Hints are applied on a best-effort basis: if the platform, the filesystem,
+ * or a mocked operating.System does not support a hint, the file is read or
+ * written normally without it.
+ */
+
+import (
+	"io"
+	"os"
+	"unsafe"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+/*
+ * With Direct set, reads and writes are done through a buffer of
+ * DirectIOBufferSize bytes aligned to DIRECT_IO_ALIGNMENT, as O_DIRECT
+ * requires.  DirectIOBufferSize must be a multiple of DIRECT_IO_ALIGNMENT.
+ *
+ * With DropCache set, the pages already read or written are dropped from the
+ * page cache every DropCacheInterval bytes and when the file is closed.
+ */
+const DIRECT_IO_ALIGNMENT = 4096
+
+var (
+	DirectIOBufferSize       = 1 << 20
+	DropCacheInterval  int64 = 64 << 20
+)
+
+/*
+ * Sequential tells the kernel to read ahead aggressively, DropCache drops the
+ * file's pages from the page cache once they have been used, and Direct
+ * bypasses the page cache entirely with O_DIRECT.
+ */
+type IOHints struct {
+	Sequential bool
+	DropCache  bool
+	Direct     bool
+}
+
+func alignedBuffer(size int) []byte {
+	buffer := make([]byte, size+DIRECT_IO_ALIGNMENT)
+	offset := 0
+	if remainder := int(uintptr(unsafe.Pointer(&buffer[0])) % DIRECT_IO_ALIGNMENT); remainder != 0 {
+		offset = DIRECT_IO_ALIGNMENT - remainder
+	}
+	return buffer[offset : offset+size]
+}
+
+/*
+ * openWithHints opens the file with the O_DIRECT flag if hints asks for it,
+ * falling back to opening it without the flag if the filesystem rejects it,
+ * and applies the remaining hints.  direct reports whether O_DIRECT is in
+ * effect.
+ */
+func openWithHints(open func(flag int) (interface{}, error), flag int, hints IOHints) (handle interface{}, direct bool, err error) {
+	if hints.Direct && directFlag != 0 {
+		handle, err = open(flag | directFlag)
+		if err == nil {
+			direct = true
+		} else if !isUnsupported(err) {
+			return nil, false, err
+		}
+	}
+	if !direct {
+		handle, err = open(flag)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	if file, ok := handle.(*os.File); ok {
+		if err := applyOpenHints(file, hints); err != nil {
+			gplog.Debug("Unable to apply IO hints to %s: %v", file.Name(), err)
+		}
+	} else {
+		direct = false
+	}
+	return handle, direct, nil
+}
+
+type hintedReader struct {
+	file        *os.File
+	reader      io.ReadCloser
+	hints       IOHints
+	direct      bool
+	buffer      []byte
+	pending     []byte
+	position    int64
+	droppedUpTo int64
+}
+
+/*
+ * OpenFileForReadingWithHints opens filename for reading like
+ * OpenFileForReading, applying hints.
+ */
+func OpenFileForReadingWithHints(filename string, hints IOHints) (io.ReadCloser, error) {
+	open := func(flag int) (interface{}, error) {
+		return operating.System.OpenFileRead(filename, flag, 0644)
+	}
+	handle, direct, err := openWithHints(open, os.O_RDONLY, hints)
+	if err != nil {
+		return nil, errors.Errorf("Unable to open file for reading: %s", err)
+	}
+	reader := &hintedReader{reader: handle.(io.ReadCloser), hints: hints, direct: direct}
+	reader.file, _ = handle.(*os.File)
+	if direct {
+		reader.buffer = alignedBuffer(DirectIOBufferSize)
+	}
+	return reader, nil
+}
+
+func MustOpenFileForReadingWithHints(filename string, hints IOHints) io.ReadCloser {
+	reader, err := OpenFileForReadingWithHints(filename, hints)
+	gplog.FatalOnError(err)
+	return reader
+}
+
+func (reader *hintedReader) Read(p []byte) (int, error) {
+	var n int
+	var err error
+	if !reader.direct {
+		n, err = reader.reader.Read(p)
+	} else {
+		if len(reader.pending) == 0 {
+			err = reader.fill()
+		}
+		n = copy(p, reader.pending)
+		reader.pending = reader.pending[n:]
+		if n > 0 && err == io.EOF {
+			err = nil
+		}
+	}
+	reader.position += int64(n)
+	if reader.hints.DropCache && reader.file != nil && reader.position-reader.droppedUpTo >= DropCacheInterval {
+		reader.dropCache()
+	}
+	return n, err
+}
+
+/*
+ * fill reads the next block into the aligned buffer.  A read that comes back
+ * short before the end of the file would leave the offset unaligned, so
+ * O_DIRECT is turned off if the filesystem rejects the next read.
+ */
+func (reader *hintedReader) fill() error {
+	n, err := reader.file.Read(reader.buffer)
+	if err != nil && isUnsupported(err) {
+		if clearErr := clearDirect(reader.file); clearErr != nil {
+			return err
+		}
+		reader.direct = false
+		n, err = reader.file.Read(reader.buffer)
+	}
+	reader.pending = reader.buffer[:n]
+	return err
+}
+
+func (reader *hintedReader) dropCache() {
+	_ = dropCache(reader.file, reader.droppedUpTo, reader.position-reader.droppedUpTo)
+	reader.droppedUpTo = reader.position
+}
+
+func (reader *hintedReader) Close() error {
+	if reader.hints.DropCache && reader.file != nil {
+		reader.dropCache()
+	}
+	return reader.reader.Close()
+}
+
+type hintedWriter struct {
+	file        *os.File
+	writer      io.WriteCloser
+	hints       IOHints
+	direct      bool
+	buffer      []byte
+	buffered    int
+	position    int64
+	droppedUpTo int64
+}
+
+/*
+ * OpenFileForWritingWithHints creates or truncates filename for writing like
+ * OpenFileForWriting, applying hints.  With Direct set, data is only written
+ * once a full aligned block has been buffered, so Close must be called to
+ * write the end of the file.
+ */
+func OpenFileForWritingWithHints(filename string, hints IOHints) (io.WriteCloser, error) {
+	open := func(flag int) (interface{}, error) {
+		return operating.System.OpenFileWrite(filename, flag, 0644)
+	}
+	handle, direct, err := openWithHints(open, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, hints)
+	if err != nil {
+		return nil, errors.Errorf("Unable to create or open file for writing: %s", err)
+	}
+	writer := &hintedWriter{writer: handle.(io.WriteCloser), hints: hints, direct: direct}
+	writer.file, _ = handle.(*os.File)
+	if direct {
+		writer.buffer = alignedBuffer(DirectIOBufferSize)
+	}
+	return writer, nil
+}
+
+func MustOpenFileForWritingWithHints(filename string, hints IOHints) io.WriteCloser {
+	writer, err := OpenFileForWritingWithHints(filename, hints)
+	gplog.FatalOnError(err)
+	return writer
+}
+
+func (writer *hintedWriter) Write(p []byte) (int, error) {
+	if !writer.direct {
+		n, err := writer.writer.Write(p)
+		writer.wrote(n)
+		return n, err
+	}
+	written := 0
+	for written < len(p) {
+		n := copy(writer.buffer[writer.buffered:], p[written:])
+		writer.buffered += n
+		written += n
+		if writer.buffered == len(writer.buffer) {
+			if err := writer.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+/*
+ * flush writes the buffered data.  O_DIRECT is turned off first if the data
+ * is not a whole number of aligned blocks, which only happens at the end of
+ * the file, or if the filesystem rejects the write.
+ */
+func (writer *hintedWriter) flush() error {
+	data := writer.buffer[:writer.buffered]
+	if writer.direct && len(data)%DIRECT_IO_ALIGNMENT != 0 {
+		if err := clearDirect(writer.file); err != nil {
+			return err
+		}
+		writer.direct = false
+	}
+	n, err := writer.file.Write(data)
+	if err != nil && n == 0 && writer.direct && isUnsupported(err) {
+		if clearErr := clearDirect(writer.file); clearErr != nil {
+			return err
+		}
+		writer.direct = false
+		n, err = writer.file.Write(data)
+	}
+	writer.wrote(n)
+	if err != nil {
+		return err
+	}
+	writer.buffered = 0
+	return nil
+}
+
+func (writer *hintedWriter) wrote(n int) {
+	writer.position += int64(n)
+	if writer.hints.DropCache && writer.file != nil && writer.position-writer.droppedUpTo >= DropCacheInterval {
+		writer.dropCache()
+	}
+}
+
+// Dirty pages cannot be dropped, so they are written out first
+func (writer *hintedWriter) dropCache() {
+	if err := writer.file.Sync(); err == nil {
+		_ = dropCache(writer.file, writer.droppedUpTo, writer.position-writer.droppedUpTo)
+		writer.droppedUpTo = writer.position
+	}
+}
+
+func (writer *hintedWriter) Close() error {
+	var err error
+	if writer.buffered > 0 {
+		err = writer.flush()
+	}
+	if err == nil && writer.hints.DropCache && writer.file != nil {
+		writer.dropCache()
+	}
+	if closeErr := writer.writer.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,58 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+/*
+ * macOS has neither O_DIRECT nor posix_fadvise.  F_NOCACHE keeps a file's
+ * pages out of the cache, which covers both Direct and DropCache, and
+ * F_RDAHEAD turns on read-ahead.
+ */
+const directFlag = 0
+
+func isUnsupported(err error) bool {
+	return errors.Is(err, unix.EINVAL) || errors.Is(err, unix.ENOTSUP)
+}
+
+func applyOpenHints(file *os.File, hints IOHints) error {
+	if hints.Direct || hints.DropCache {
+		if _, err := unix.FcntlInt(file.Fd(), unix.F_NOCACHE, 1); err != nil {
+			return err
+		}
+	}
+	if hints.Sequential {
+		if _, err := unix.FcntlInt(file.Fd(), unix.F_RDAHEAD, 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dropCache(_ *os.File, _ int64, _ int64) error {
+	return nil
+}
+
+func clearDirect(_ *os.File) error {
+	return nil
+}
@@ -0,0 +1,51 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+const directFlag = unix.O_DIRECT
+
+func isUnsupported(err error) bool {
+	return errors.Is(err, unix.EINVAL) || errors.Is(err, unix.EOPNOTSUPP)
+}
+
+func applyOpenHints(file *os.File, hints IOHints) error {
+	if hints.Sequential {
+		return unix.Fadvise(int(file.Fd()), 0, 0, unix.FADV_SEQUENTIAL)
+	}
+	return nil
+}
+
+func dropCache(file *os.File, offset int64, length int64) error {
+	return unix.Fadvise(int(file.Fd()), offset, length, unix.FADV_DONTNEED)
+}
+
+func clearDirect(file *os.File) error {
+	flags, err := unix.FcntlInt(file.Fd(), unix.F_GETFL, 0)
+	if err != nil {
+		return err
+	}
+	_, err = unix.FcntlInt(file.Fd(), unix.F_SETFL, flags&^unix.O_DIRECT)
+	return err
+}
@@ -0,0 +1,133 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper_test
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+
+	"github.com/apache/cloudberry-go-libs/iohelper"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("iohelper/iohints tests", func() {
+	var (
+		filename          string
+		contents          []byte
+		savedBufferSize   int
+		savedDropInterval int64
+	)
+	allHints := iohelper.IOHints{Sequential: true, DropCache: true, Direct: true}
+
+	BeforeEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		filename = filepath.Join(GinkgoT().TempDir(), "data")
+		savedBufferSize, savedDropInterval = iohelper.DirectIOBufferSize, iohelper.DropCacheInterval
+		iohelper.DirectIOBufferSize = 2 * iohelper.DIRECT_IO_ALIGNMENT
+		iohelper.DropCacheInterval = 3 * iohelper.DIRECT_IO_ALIGNMENT
+		// Not a multiple of the alignment, so the last block is partial
+		contents = make([]byte, 5*iohelper.DIRECT_IO_ALIGNMENT+123)
+		rand.New(rand.NewSource(1)).Read(contents)
+	})
+	AfterEach(func() {
+		iohelper.DirectIOBufferSize, iohelper.DropCacheInterval = savedBufferSize, savedDropInterval
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("OpenFileForWritingWithHints", func() {
+		It("writes the whole file with every hint set", func() {
+			writer, err := iohelper.OpenFileForWritingWithHints(filename, allHints)
+			Expect(err).ToNot(HaveOccurred())
+			// Write in pieces that do not line up with the buffer
+			for offset := 0; offset < len(contents); offset += 1000 {
+				end := offset + 1000
+				if end > len(contents) {
+					end = len(contents)
+				}
+				_, err = writer.Write(contents[offset:end])
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(writer.Close()).To(Succeed())
+			Expect(os.ReadFile(filename)).To(Equal(contents))
+		})
+		It("writes an empty file", func() {
+			writer := iohelper.MustOpenFileForWritingWithHints(filename, allHints)
+			Expect(writer.Close()).To(Succeed())
+			Expect(os.ReadFile(filename)).To(BeEmpty())
+		})
+		It("writes normally if the opened file is not an *os.File", func() {
+			buffer := &bufferCloser{}
+			operating.System.OpenFileWrite = func(name string, flag int, perm os.FileMode) (io.WriteCloser, error) { return buffer, nil }
+			writer := iohelper.MustOpenFileForWritingWithHints(filename, allHints)
+			_, err := writer.Write(contents)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.Close()).To(Succeed())
+			Expect(buffer.Bytes()).To(Equal(contents))
+		})
+		It("panics if the file cannot be opened", func() {
+			defer testhelper.ShouldPanicWithMessage("Unable to create or open file for writing")
+			iohelper.MustOpenFileForWritingWithHints(filepath.Join(filename, "missing", "data"), allHints)
+		})
+	})
+	Describe("OpenFileForReadingWithHints", func() {
+		BeforeEach(func() {
+			Expect(os.WriteFile(filename, contents, 0644)).To(Succeed())
+		})
+
+		It("reads the whole file with every hint set", func() {
+			reader, err := iohelper.OpenFileForReadingWithHints(filename, allHints)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(io.ReadAll(reader)).To(Equal(contents))
+			Expect(reader.Close()).To(Succeed())
+		})
+		It("reads the whole file in small pieces", func() {
+			reader := iohelper.MustOpenFileForReadingWithHints(filename, iohelper.IOHints{Direct: true})
+			readContents := make([]byte, 0)
+			piece := make([]byte, 1000)
+			for {
+				n, err := reader.Read(piece)
+				readContents = append(readContents, piece[:n]...)
+				if err == io.EOF {
+					break
+				}
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(readContents).To(Equal(contents))
+			Expect(reader.Close()).To(Succeed())
+		})
+		It("returns an error if the file does not exist", func() {
+			_, err := iohelper.OpenFileForReadingWithHints(filename+".missing", allHints)
+			Expect(err).To(MatchError(ContainSubstring("Unable to open file for reading")))
+		})
+	})
+})
+
+type bufferCloser struct {
+	bytes.Buffer
+}
+
+func (buffer *bufferCloser) Close() error {
+	return nil
+}
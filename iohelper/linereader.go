@@ -0,0 +1,117 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper
+
+/*
+ * This file contains a line reader for files such as SQL scripts and TOC
+ * files, where a single line can be far longer than the 64KB that
+ * bufio.Scanner allows by default.
+ */
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+)
+
+// The size of the buffer used to read each chunk of a line
+const LINE_READER_BUFFER_SIZE = 64 * 1024
+
+type LineTooLongError struct {
+	LineNumber  int
+	MaxLineSize int
+}
+
+func (e *LineTooLongError) Error() string {
+	return fmt.Sprintf("Line %d is longer than the maximum of %d bytes", e.LineNumber, e.MaxLineSize)
+}
+
+/*
+ * A LineReader returns lines one at a time without their trailing "\n" or
+ * "\r\n", like bufio.Scanner, but lines are only limited by maxLineSize.
+ *
+ * If a line is longer than maxLineSize, ReadLine returns a *LineTooLongError
+ * and skips the rest of the line, so reading can continue with the next one.
+ * A maxLineSize of 0 means lines are not limited.
+ */
+type LineReader struct {
+	reader      *bufio.Reader
+	maxLineSize int
+	lineNumber  int
+	ctx         context.Context
+}
+
+func NewLineReader(reader io.Reader, maxLineSize int) *LineReader {
+	return &LineReader{
+		reader:      bufio.NewReaderSize(reader, LINE_READER_BUFFER_SIZE),
+		maxLineSize: maxLineSize,
+		ctx:         context.Background(),
+	}
+}
+
+/*
+ * WithContext makes ReadLine return the context's error once it is canceled.
+ * The context is checked between chunks, so canceling it stops the reader
+ * partway through a very long line.
+ */
+func (reader *LineReader) WithContext(ctx context.Context) *LineReader {
+	reader.ctx = ctx
+	return reader
+}
+
+// LineNumber returns the number of the line last returned by ReadLine, starting from 1
+func (reader *LineReader) LineNumber() int {
+	return reader.lineNumber
+}
+
+// ReadLine returns the next line, or io.EOF if there are no more
+func (reader *LineReader) ReadLine() (string, error) {
+	var line []byte
+	tooLong := false
+	for {
+		if err := reader.ctx.Err(); err != nil {
+			return "", err
+		}
+		chunk, err := reader.reader.ReadSlice('\n')
+		if !tooLong {
+			if reader.maxLineSize > 0 && len(line)+len(bytes.TrimRight(chunk, "\r\n")) > reader.maxLineSize {
+				tooLong = true
+				line = nil
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF && len(line) == 0 && !tooLong && len(chunk) == 0 {
+			return "", io.EOF
+		} else if err != nil && err != io.EOF {
+			return "", err
+		}
+		reader.lineNumber++
+		if tooLong {
+			return "", &LineTooLongError{LineNumber: reader.lineNumber, MaxLineSize: reader.maxLineSize}
+		}
+		line = bytes.TrimSuffix(line, []byte("\n"))
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		return string(line), nil
+	}
+}
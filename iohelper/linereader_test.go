@@ -0,0 +1,99 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper_test
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/iohelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func readAllLines(reader *iohelper.LineReader) ([]string, error) {
+	lines := make([]string, 0)
+	for {
+		line, err := reader.ReadLine()
+		if err == io.EOF {
+			return lines, nil
+		} else if err != nil {
+			return lines, err
+		}
+		lines = append(lines, line)
+	}
+}
+
+var _ = Describe("iohelper/linereader tests", func() {
+	longLine := strings.Repeat("x", 3*iohelper.LINE_READER_BUFFER_SIZE+17)
+
+	Describe("LineReader", func() {
+		It("reads lines without their line endings and tracks line numbers", func() {
+			reader := iohelper.NewLineReader(strings.NewReader("first\r\n\nthird\nlast"), 0)
+			line, err := reader.ReadLine()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(line).To(Equal("first"))
+			Expect(reader.LineNumber()).To(Equal(1))
+
+			lines, err := readAllLines(reader)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lines).To(Equal([]string{"", "third", "last"}))
+			Expect(reader.LineNumber()).To(Equal(4))
+		})
+		It("reads lines longer than its buffer", func() {
+			reader := iohelper.NewLineReader(strings.NewReader("short\n"+longLine+"\nshort\n"), 0)
+			lines, err := readAllLines(reader)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lines).To(Equal([]string{"short", longLine, "short"}))
+		})
+		It("returns an error for lines longer than the maximum and skips them", func() {
+			reader := iohelper.NewLineReader(strings.NewReader("short\n"+longLine+"\nafter\n"+longLine), 1000)
+			Expect(reader.ReadLine()).To(Equal("short"))
+
+			_, err := reader.ReadLine()
+			Expect(err).To(MatchError("Line 2 is longer than the maximum of 1000 bytes"))
+			Expect(err).To(BeAssignableToTypeOf(&iohelper.LineTooLongError{}))
+
+			Expect(reader.ReadLine()).To(Equal("after"))
+			_, err = reader.ReadLine()
+			Expect(err).To(MatchError("Line 4 is longer than the maximum of 1000 bytes"))
+			_, err = reader.ReadLine()
+			Expect(err).To(Equal(io.EOF))
+		})
+		It("allows lines of exactly the maximum length", func() {
+			reader := iohelper.NewLineReader(strings.NewReader("12345\r\n12345"), 5)
+			lines, err := readAllLines(reader)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lines).To(Equal([]string{"12345", "12345"}))
+		})
+		It("stops reading once its context is canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			reader := iohelper.NewLineReader(strings.NewReader("first\nsecond\n"), 0).WithContext(ctx)
+			Expect(reader.ReadLine()).To(Equal("first"))
+			cancel()
+			_, err := reader.ReadLine()
+			Expect(err).To(MatchError(context.Canceled))
+		})
+		It("returns io.EOF for empty input", func() {
+			_, err := iohelper.NewLineReader(strings.NewReader(""), 0).ReadLine()
+			Expect(err).To(Equal(io.EOF))
+		})
+	})
+})
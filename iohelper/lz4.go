@@ -0,0 +1,541 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper
+
+/*
+ * This file contains a reader and writer for the LZ4 frame format, as written
+ * by the lz4 command-line tool, for use by the compression functions.
+ *
+ * The writer uses a simple greedy compressor with independent 1MB blocks and
+ * a content checksum, which favors speed over ratio, as LZ4 itself does.  The
+ * reader accepts any frame that does not use a preset dictionary, including
+ * frames with linked blocks, and concatenated and skippable frames.
+ */
+
+import (
+	"encoding/binary"
+	"io"
+	"math/bits"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	lz4FrameMagic         = 0x184D2204
+	lz4SkippableMagicMask = 0xFFFFFFF0
+	lz4SkippableMagic     = 0x184D2A50
+	lz4WriterBlockSize    = 1 << 20
+	lz4WindowSize         = 64 << 10
+	lz4MinMatch           = 4
+	lz4MatchSearchLimit   = 12 // a match may not start within 12 bytes of the end of a block
+	lz4LastLiterals       = 5  // and the last 5 bytes of a block are always literals
+	lz4HashBits           = 16
+	lz4UncompressedFlag   = 1 << 31
+
+	// FLG: version 01, independent blocks, content checksum
+	lz4WriterFlags = 0x64
+	// BD: 1MB maximum block size
+	lz4WriterBlockDescriptor = 0x60
+)
+
+/*
+ * xxHash32, which LZ4 frames use for their header, block, and content
+ * checksums.
+ */
+const (
+	xxh32Prime1 uint32 = 2654435761
+	xxh32Prime2 uint32 = 2246822519
+	xxh32Prime3 uint32 = 3266489917
+	xxh32Prime4 uint32 = 668265263
+	xxh32Prime5 uint32 = 374761393
+)
+
+type xxh32 struct {
+	accumulators [4]uint32
+	buffer       [16]byte
+	buffered     int
+	length       uint64
+}
+
+func newXXH32() *xxh32 {
+	// The seed is always 0; the arithmetic is done on variables so it may wrap
+	prime1, prime2 := xxh32Prime1, xxh32Prime2
+	return &xxh32{accumulators: [4]uint32{prime1 + prime2, prime2, 0, -prime1}}
+}
+
+func xxh32Round(accumulator uint32, input uint32) uint32 {
+	return bits.RotateLeft32(accumulator+input*xxh32Prime2, 13) * xxh32Prime1
+}
+
+func (hash *xxh32) stripe(data []byte) {
+	for i := range hash.accumulators {
+		hash.accumulators[i] = xxh32Round(hash.accumulators[i], binary.LittleEndian.Uint32(data[4*i:]))
+	}
+}
+
+func (hash *xxh32) Write(data []byte) {
+	hash.length += uint64(len(data))
+	if hash.buffered > 0 {
+		n := copy(hash.buffer[hash.buffered:], data)
+		hash.buffered += n
+		data = data[n:]
+		if hash.buffered < len(hash.buffer) {
+			return
+		}
+		hash.stripe(hash.buffer[:])
+		hash.buffered = 0
+	}
+	for len(data) >= 16 {
+		hash.stripe(data)
+		data = data[16:]
+	}
+	hash.buffered = copy(hash.buffer[:], data)
+}
+
+func (hash *xxh32) Sum32() uint32 {
+	var h uint32
+	if hash.length >= 16 {
+		a := hash.accumulators
+		h = bits.RotateLeft32(a[0], 1) + bits.RotateLeft32(a[1], 7) + bits.RotateLeft32(a[2], 12) + bits.RotateLeft32(a[3], 18)
+	} else {
+		h = xxh32Prime5
+	}
+	h += uint32(hash.length)
+	tail := hash.buffer[:hash.buffered]
+	for ; len(tail) >= 4; tail = tail[4:] {
+		h += binary.LittleEndian.Uint32(tail) * xxh32Prime3
+		h = bits.RotateLeft32(h, 17) * xxh32Prime4
+	}
+	for _, b := range tail {
+		h += uint32(b) * xxh32Prime5
+		h = bits.RotateLeft32(h, 11) * xxh32Prime1
+	}
+	h ^= h >> 15
+	h *= xxh32Prime2
+	h ^= h >> 13
+	h *= xxh32Prime3
+	h ^= h >> 16
+	return h
+}
+
+func xxh32Sum(data []byte) uint32 {
+	hash := newXXH32()
+	hash.Write(data)
+	return hash.Sum32()
+}
+
+/*
+ * lz4CompressBlock compresses src into dst, which must be at least
+ * lz4CompressBound(len(src)) bytes, and returns the compressed length.
+ */
+func lz4CompressBlock(src []byte, dst []byte, table []int32) int {
+	for i := range table {
+		table[i] = 0
+	}
+	out := 0
+	anchor := 0
+	emit := func(literals []byte, offset int, matchLength int) {
+		tokenPosition := out
+		out++
+		literalLength := len(literals)
+		token := byte(0)
+		if literalLength >= 15 {
+			token = 15 << 4
+			out = lz4WriteLength(dst, out, literalLength-15)
+		} else {
+			token = byte(literalLength << 4)
+		}
+		out += copy(dst[out:], literals)
+		if matchLength > 0 {
+			binary.LittleEndian.PutUint16(dst[out:], uint16(offset))
+			out += 2
+			if extra := matchLength - lz4MinMatch; extra >= 15 {
+				token |= 15
+				out = lz4WriteLength(dst, out, extra-15)
+			} else {
+				token |= byte(extra)
+			}
+		}
+		dst[tokenPosition] = token
+	}
+	for i := 0; i < len(src)-lz4MatchSearchLimit; {
+		sequence := binary.LittleEndian.Uint32(src[i:])
+		slot := (sequence * xxh32Prime1) >> (32 - lz4HashBits)
+		// Positions are stored plus one, so that zero means an empty slot
+		candidate := int(table[slot]) - 1
+		table[slot] = int32(i + 1)
+		if candidate < 0 || i-candidate > 0xFFFF || binary.LittleEndian.Uint32(src[candidate:]) != sequence {
+			i++
+			continue
+		}
+		matchLength := lz4MinMatch
+		for i+matchLength < len(src)-lz4LastLiterals && src[candidate+matchLength] == src[i+matchLength] {
+			matchLength++
+		}
+		emit(src[anchor:i], i-candidate, matchLength)
+		i += matchLength
+		anchor = i
+	}
+	emit(src[anchor:], 0, 0)
+	return out
+}
+
+func lz4WriteLength(dst []byte, out int, length int) int {
+	for ; length >= 255; length -= 255 {
+		dst[out] = 255
+		out++
+	}
+	dst[out] = byte(length)
+	return out + 1
+}
+
+func lz4CompressBound(length int) int {
+	return length + length/255 + 16
+}
+
+/*
+ * lz4DecompressBlock appends the decompressed contents of src to dst, whose
+ * existing contents are the history that matches may refer back to, and
+ * returns the extended slice.
+ */
+func lz4DecompressBlock(src []byte, dst []byte, maxLength int) ([]byte, error) {
+	corrupt := errors.New("Corrupt LZ4 block")
+	limit := len(dst) + maxLength
+	readLength := func(i int, length int) (int, int, error) {
+		for {
+			if i >= len(src) {
+				return 0, 0, corrupt
+			}
+			b := src[i]
+			i++
+			length += int(b)
+			if b != 255 {
+				return i, length, nil
+			}
+		}
+	}
+	var err error
+	for i := 0; i < len(src); {
+		token := src[i]
+		i++
+		literalLength := int(token >> 4)
+		if literalLength == 15 {
+			if i, literalLength, err = readLength(i, literalLength); err != nil {
+				return nil, err
+			}
+		}
+		if i+literalLength > len(src) || len(dst)+literalLength > limit {
+			return nil, corrupt
+		}
+		dst = append(dst, src[i:i+literalLength]...)
+		i += literalLength
+		if i == len(src) {
+			break
+		}
+		if i+2 > len(src) {
+			return nil, corrupt
+		}
+		offset := int(binary.LittleEndian.Uint16(src[i:]))
+		i += 2
+		matchLength := int(token & 15)
+		if matchLength == 15 {
+			if i, matchLength, err = readLength(i, matchLength); err != nil {
+				return nil, err
+			}
+		}
+		matchLength += lz4MinMatch
+		if offset == 0 || offset > len(dst) || len(dst)+matchLength > limit {
+			return nil, corrupt
+		}
+		// Copy byte by byte, since the match may overlap the bytes it produces
+		start := len(dst) - offset
+		for j := 0; j < matchLength; j++ {
+			dst = append(dst, dst[start+j])
+		}
+	}
+	return dst, nil
+}
+
+type lz4Writer struct {
+	writer     io.Writer
+	buffer     []byte
+	compressed []byte
+	table      []int32
+	checksum   *xxh32
+	wroteStart bool
+	closed     bool
+}
+
+func newLZ4Writer(writer io.Writer) *lz4Writer {
+	return &lz4Writer{
+		writer:     writer,
+		buffer:     make([]byte, 0, lz4WriterBlockSize),
+		compressed: make([]byte, lz4CompressBound(lz4WriterBlockSize)),
+		table:      make([]int32, 1<<lz4HashBits),
+		checksum:   newXXH32(),
+	}
+}
+
+func (writer *lz4Writer) writeHeader() error {
+	header := make([]byte, 7)
+	binary.LittleEndian.PutUint32(header, lz4FrameMagic)
+	header[4] = lz4WriterFlags
+	header[5] = lz4WriterBlockDescriptor
+	header[6] = byte(xxh32Sum(header[4:6]) >> 8)
+	writer.wroteStart = true
+	_, err := writer.writer.Write(header)
+	return err
+}
+
+func (writer *lz4Writer) Write(p []byte) (int, error) {
+	if writer.closed {
+		return 0, errors.New("Write to a closed LZ4 writer")
+	}
+	written := 0
+	for len(p) > 0 {
+		n := copy(writer.buffer[len(writer.buffer):cap(writer.buffer)], p)
+		writer.buffer = writer.buffer[:len(writer.buffer)+n]
+		p = p[n:]
+		written += n
+		if len(writer.buffer) == cap(writer.buffer) {
+			if err := writer.flushBlock(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+func (writer *lz4Writer) flushBlock() error {
+	if !writer.wroteStart {
+		if err := writer.writeHeader(); err != nil {
+			return err
+		}
+	}
+	if len(writer.buffer) == 0 {
+		return nil
+	}
+	writer.checksum.Write(writer.buffer)
+	block := writer.buffer
+	blockSize := uint32(len(block))
+	if n := lz4CompressBlock(writer.buffer, writer.compressed, writer.table); n < len(writer.buffer) {
+		block = writer.compressed[:n]
+		blockSize = uint32(n)
+	} else {
+		blockSize |= lz4UncompressedFlag
+	}
+	sizeBytes := make([]byte, 4)
+	binary.LittleEndian.PutUint32(sizeBytes, blockSize)
+	if _, err := writer.writer.Write(sizeBytes); err != nil {
+		return err
+	}
+	if _, err := writer.writer.Write(block); err != nil {
+		return err
+	}
+	writer.buffer = writer.buffer[:0]
+	return nil
+}
+
+// Close writes the end of the frame; it does not close the underlying writer
+func (writer *lz4Writer) Close() error {
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+	if err := writer.flushBlock(); err != nil {
+		return err
+	}
+	trailer := make([]byte, 8)
+	binary.LittleEndian.PutUint32(trailer[4:], writer.checksum.Sum32())
+	_, err := writer.writer.Write(trailer)
+	return err
+}
+
+type lz4Reader struct {
+	reader          io.Reader
+	started         bool
+	pending         []byte
+	history         []byte
+	inFrame         bool
+	independent     bool
+	blockChecksum   bool
+	contentChecksum *xxh32
+	maxBlockSize    int
+	compressed      []byte
+}
+
+func newLZ4Reader(reader io.Reader) *lz4Reader {
+	return &lz4Reader{reader: reader}
+}
+
+/*
+ * readFrameHeader reads up to and including the next frame header, skipping
+ * any skippable frames.  It returns io.EOF if the input ends cleanly between
+ * frames.
+ */
+func (reader *lz4Reader) readFrameHeader() error {
+	for {
+		magicBytes := make([]byte, 4)
+		if _, err := io.ReadFull(reader.reader, magicBytes); err != nil {
+			if err == io.EOF && reader.started {
+				return io.EOF
+			}
+			return errors.Wrap(io.ErrUnexpectedEOF, "Unable to read LZ4 frame")
+		}
+		magic := binary.LittleEndian.Uint32(magicBytes)
+		if magic&lz4SkippableMagicMask == lz4SkippableMagic {
+			sizeBytes := make([]byte, 4)
+			if _, err := io.ReadFull(reader.reader, sizeBytes); err != nil {
+				return errors.Wrap(io.ErrUnexpectedEOF, "Unable to read LZ4 frame")
+			}
+			if _, err := io.CopyN(io.Discard, reader.reader, int64(binary.LittleEndian.Uint32(sizeBytes))); err != nil {
+				return errors.Wrap(io.ErrUnexpectedEOF, "Unable to read LZ4 frame")
+			}
+			reader.started = true
+			continue
+		}
+		if magic != lz4FrameMagic {
+			return errors.Errorf("Invalid LZ4 frame magic number %#x", magic)
+		}
+		break
+	}
+	descriptor := make([]byte, 2)
+	if _, err := io.ReadFull(reader.reader, descriptor); err != nil {
+		return errors.Wrap(io.ErrUnexpectedEOF, "Unable to read LZ4 frame header")
+	}
+	flags, blockDescriptor := descriptor[0], descriptor[1]
+	if flags>>6 != 1 {
+		return errors.Errorf("Unsupported LZ4 frame version %d", flags>>6)
+	}
+	if flags&0x01 != 0 {
+		return errors.New("LZ4 frames with a preset dictionary are not supported")
+	}
+	optional := 0
+	if flags&0x08 != 0 {
+		optional = 8
+	}
+	rest := make([]byte, optional+1)
+	if _, err := io.ReadFull(reader.reader, rest); err != nil {
+		return errors.Wrap(io.ErrUnexpectedEOF, "Unable to read LZ4 frame header")
+	}
+	if expected := byte(xxh32Sum(append(descriptor, rest[:optional]...)) >> 8); rest[optional] != expected {
+		return errors.New("LZ4 frame header checksum mismatch")
+	}
+	blockSizeID := (blockDescriptor >> 4) & 0x07
+	if blockSizeID < 4 {
+		return errors.Errorf("Invalid LZ4 maximum block size %d", blockSizeID)
+	}
+	reader.maxBlockSize = 1 << (8 + 2*uint(blockSizeID))
+	reader.independent = flags&0x20 != 0
+	reader.blockChecksum = flags&0x10 != 0
+	reader.contentChecksum = nil
+	if flags&0x04 != 0 {
+		reader.contentChecksum = newXXH32()
+	}
+	reader.history = reader.history[:0]
+	reader.inFrame = true
+	reader.started = true
+	return nil
+}
+
+func (reader *lz4Reader) readBlock() error {
+	sizeBytes := make([]byte, 4)
+	if _, err := io.ReadFull(reader.reader, sizeBytes); err != nil {
+		return errors.Wrap(io.ErrUnexpectedEOF, "Unable to read LZ4 block")
+	}
+	blockSize := binary.LittleEndian.Uint32(sizeBytes)
+	reader.pending = reader.pending[:0]
+	if blockSize == 0 {
+		reader.inFrame = false
+		if reader.contentChecksum != nil {
+			checksumBytes := make([]byte, 4)
+			if _, err := io.ReadFull(reader.reader, checksumBytes); err != nil {
+				return errors.Wrap(io.ErrUnexpectedEOF, "Unable to read LZ4 content checksum")
+			}
+			if binary.LittleEndian.Uint32(checksumBytes) != reader.contentChecksum.Sum32() {
+				return errors.New("LZ4 content checksum mismatch")
+			}
+		}
+		return nil
+	}
+	uncompressed := blockSize&lz4UncompressedFlag != 0
+	blockSize &^= lz4UncompressedFlag
+	if int(blockSize) > reader.maxBlockSize {
+		return errors.Errorf("LZ4 block size %d exceeds the maximum of %d", blockSize, reader.maxBlockSize)
+	}
+	if cap(reader.compressed) < int(blockSize) {
+		reader.compressed = make([]byte, blockSize)
+	}
+	block := reader.compressed[:blockSize]
+	if _, err := io.ReadFull(reader.reader, block); err != nil {
+		return errors.Wrap(io.ErrUnexpectedEOF, "Unable to read LZ4 block")
+	}
+	if reader.blockChecksum {
+		checksumBytes := make([]byte, 4)
+		if _, err := io.ReadFull(reader.reader, checksumBytes); err != nil {
+			return errors.Wrap(io.ErrUnexpectedEOF, "Unable to read LZ4 block checksum")
+		}
+		if binary.LittleEndian.Uint32(checksumBytes) != xxh32Sum(block) {
+			return errors.New("LZ4 block checksum mismatch")
+		}
+	}
+
+	// Linked blocks may refer back to the last 64KB of the previous block
+	history := reader.history
+	if reader.independent {
+		history = history[:0]
+	}
+	historyLength := len(history)
+	output := history
+	if uncompressed {
+		output = append(output, block...)
+	} else {
+		var err error
+		if output, err = lz4DecompressBlock(block, output, reader.maxBlockSize); err != nil {
+			return err
+		}
+	}
+	decompressed := output[historyLength:]
+	if reader.contentChecksum != nil {
+		reader.contentChecksum.Write(decompressed)
+	}
+	reader.pending = append(reader.pending[:0], decompressed...)
+	if !reader.independent {
+		if len(output) > lz4WindowSize {
+			output = append(output[:0], output[len(output)-lz4WindowSize:]...)
+		}
+		reader.history = output
+	}
+	return nil
+}
+
+func (reader *lz4Reader) Read(p []byte) (int, error) {
+	for len(reader.pending) == 0 {
+		if !reader.inFrame {
+			if err := reader.readFrameHeader(); err != nil {
+				return 0, err
+			}
+		}
+		if err := reader.readBlock(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, reader.pending)
+	reader.pending = reader.pending[n:]
+	return n, nil
+}
@@ -0,0 +1,169 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/apache/cloudberry-go-libs/iohelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var (
+	// Written by "lz4 -BD -BX", which links blocks and adds block checksums
+	lz4CLIFrame = []byte{
+		0x04, 0x22, 0x4d, 0x18, 0x74, 0x40, 0xbd, 0x20, 0x00, 0x00, 0x00, 0x6f, 0x68, 0x65, 0x6c, 0x6c,
+		0x6f, 0x20, 0x06, 0x00, 0x04, 0xf0, 0x05, 0x2c, 0x20, 0x6c, 0x69, 0x6e, 0x6b, 0x65, 0x64, 0x20,
+		0x6c, 0x7a, 0x34, 0x20, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x73, 0x0a, 0xd9, 0xb4, 0xf2, 0x96, 0x00,
+		0x00, 0x00, 0x00, 0xee, 0xcf, 0x09, 0x71,
+	}
+	// Frame headers with independent blocks, a content checksum, and a 1MB maximum block size
+	lz4Header1MB = []byte{0x04, 0x22, 0x4d, 0x18, 0x64, 0x60, 0x85}
+	// and with linked blocks, no content checksum, and a 64KB maximum block size
+	lz4Header64KB = []byte{0x04, 0x22, 0x4d, 0x18, 0x40, 0x40, 0xc0}
+)
+
+func lz4Frame(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func lz4Compress(data []byte) []byte {
+	var compressed bytes.Buffer
+	writer, _ := iohelper.NewCompressedWriter(&compressed, iohelper.LZ4, 0)
+	_, _ = writer.Write(data)
+	_ = writer.Close()
+	return compressed.Bytes()
+}
+
+func lz4Decompress(data []byte) ([]byte, error) {
+	reader, _, err := iohelper.NewAutoDetectReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(reader)
+}
+
+var _ = Describe("iohelper/lz4 tests", func() {
+	// A match that runs past the 64KB maximum block size: one literal, then a match of 15+255*257+4 bytes
+	overlongMatch := lz4Frame([]byte{0x06, 0x01, 0x00, 0x00, 0x1f, 0x61, 0x01, 0x00}, bytes.Repeat([]byte{0xff}, 257), []byte{0x00})
+
+	DescribeTable("rejects malformed lz4 frames",
+		func(frame []byte, expectedError string) {
+			_, err := lz4Decompress(frame)
+			Expect(err).To(MatchError(expectedError))
+		},
+		Entry("with an invalid magic number in a later frame", lz4Frame(lz4CLIFrame, []byte("junk")), "Invalid LZ4 frame magic number 0x6b6e756a"),
+		Entry("with an unsupported version", lz4Frame([]byte{0x04, 0x22, 0x4d, 0x18, 0x24, 0x60, 0x00}), "Unsupported LZ4 frame version 0"),
+		Entry("with a preset dictionary", lz4Frame([]byte{0x04, 0x22, 0x4d, 0x18, 0x65, 0x60, 0x00}), "LZ4 frames with a preset dictionary are not supported"),
+		Entry("with a bad header checksum", lz4Frame([]byte{0x04, 0x22, 0x4d, 0x18, 0x64, 0x60, 0x00}), "LZ4 frame header checksum mismatch"),
+		Entry("with a truncated header", lz4Header1MB[:6], "Unable to read LZ4 frame header: unexpected EOF"),
+		Entry("with an invalid maximum block size", lz4Frame([]byte{0x04, 0x22, 0x4d, 0x18, 0x64, 0x30, 0x13}), "Invalid LZ4 maximum block size 3"),
+		Entry("with a compressed block larger than the maximum block size", lz4Frame(lz4Header64KB, []byte{0x01, 0x00, 0x01, 0x00}), "LZ4 block size 65537 exceeds the maximum of 65536"),
+		Entry("with an uncompressed block larger than the maximum block size", lz4Frame(lz4Header64KB, []byte{0x01, 0x00, 0x01, 0x80}), "LZ4 block size 65537 exceeds the maximum of 65536"),
+		Entry("with a truncated block", lz4Frame(lz4Header1MB, []byte{0x10, 0x00, 0x00, 0x00, 0x61}), "Unable to read LZ4 block: unexpected EOF"),
+		Entry("with a missing end mark", lz4Header1MB, "Unable to read LZ4 block: unexpected EOF"),
+		Entry("with a match at offset 0", lz4Frame(lz4Header1MB, []byte{0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}), "Corrupt LZ4 block"),
+		Entry("with a match before the start of the output", lz4Frame(lz4Header1MB, []byte{0x04, 0x00, 0x00, 0x00, 0x10, 0x61, 0x05, 0x00}), "Corrupt LZ4 block"),
+		Entry("with a literal length that runs past the block", lz4Frame(lz4Header1MB, []byte{0x02, 0x00, 0x00, 0x00, 0xf0, 0xff}), "Corrupt LZ4 block"),
+		Entry("with literals that run past the block", lz4Frame(lz4Header1MB, []byte{0x02, 0x00, 0x00, 0x00, 0x50, 0x61}), "Corrupt LZ4 block"),
+		Entry("with a truncated match offset", lz4Frame(lz4Header1MB, []byte{0x03, 0x00, 0x00, 0x00, 0x10, 0x61, 0x01}), "Corrupt LZ4 block"),
+		Entry("with a match that runs past the maximum block size", lz4Frame(lz4Header64KB, overlongMatch), "Corrupt LZ4 block"),
+		Entry("with a bad block checksum", lz4Frame(lz4CLIFrame[:43], []byte{0x00}, lz4CLIFrame[44:]), "LZ4 block checksum mismatch"),
+		Entry("with a bad content checksum", lz4Frame(lz4Compress([]byte("hello"))[:20], []byte{0x00, 0x00, 0x00, 0x00}), "LZ4 content checksum mismatch"),
+	)
+	It("skips skippable frames", func() {
+		skippable := []byte{0x5f, 0x2a, 0x4d, 0x18, 0x03, 0x00, 0x00, 0x00, 0x01, 0x02, 0x03}
+		Expect(lz4Decompress(lz4Frame(lz4Compress([]byte("hello")), skippable, lz4Compress([]byte(" world"))))).To(Equal([]byte("hello world")))
+	})
+	It("reads linked blocks that refer back to an earlier block", func() {
+		// "abcd" as literals, then a second block that is a 12-byte match 4 bytes back
+		frame := lz4Frame(lz4Header64KB,
+			[]byte{0x05, 0x00, 0x00, 0x00, 0x40, 0x61, 0x62, 0x63, 0x64},
+			[]byte{0x03, 0x00, 0x00, 0x00, 0x08, 0x04, 0x00},
+			[]byte{0x00, 0x00, 0x00, 0x00})
+		Expect(lz4Decompress(frame)).To(Equal([]byte("abcdabcdabcdabcd")))
+	})
+})
+
+/*
+ * FuzzLZ4Reader checks that the reader returns an error rather than panicking
+ * or looping on malformed input, and that anything it does decompress
+ * round-trips through the writer.
+ */
+func FuzzLZ4Reader(f *testing.F) {
+	f.Add(lz4CLIFrame)
+	f.Add(lz4Compress(nil))
+	f.Add(lz4Compress([]byte("hello hello hello hello hello, independent lz4 blocks\n")))
+	f.Add(lz4Frame(lz4CLIFrame, lz4CLIFrame))
+	f.Add(lz4Frame(lz4CLIFrame[:43], []byte{0x00}, lz4CLIFrame[44:]))
+	f.Add(lz4Frame(lz4Header64KB, []byte{0x01, 0x00, 0x01, 0x00}))
+	f.Add(lz4Frame(lz4Header64KB, []byte{0x05, 0x00, 0x00, 0x00, 0x40, 0x61, 0x62, 0x63, 0x64}, []byte{0x03, 0x00, 0x00, 0x00, 0x08, 0x04, 0x00}, []byte{0x00, 0x00, 0x00, 0x00}))
+	f.Add(lz4Frame(lz4Header1MB, []byte{0x04, 0x00, 0x00, 0x00, 0x10, 0x61, 0x05, 0x00}))
+	f.Add(lz4Frame([]byte{0x5f, 0x2a, 0x4d, 0x18, 0x01, 0x00, 0x00, 0x00, 0x00}, lz4CLIFrame))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		reader, codec, err := iohelper.NewAutoDetectReader(bytes.NewReader(data))
+		if err != nil || codec != iohelper.LZ4 {
+			t.Skip()
+		}
+		decompressed, err := io.ReadAll(reader)
+		if err != nil {
+			return
+		}
+		if roundTripped, err := lz4Decompress(lz4Compress(decompressed)); err != nil || !bytes.Equal(roundTripped, decompressed) {
+			t.Fatalf("Decompressed %d bytes that did not round-trip: %v", len(decompressed), err)
+		}
+	})
+}
+
+/*
+ * FuzzLZ4RoundTrip checks that anything written in chunks of any size reads
+ * back unchanged.
+ */
+func FuzzLZ4RoundTrip(f *testing.F) {
+	f.Add([]byte{}, uint16(1))
+	f.Add([]byte("hello hello hello hello hello"), uint16(3))
+	f.Add(bytes.Repeat([]byte("abcdefgh"), 100), uint16(0))
+	f.Fuzz(func(t *testing.T, data []byte, chunkSize uint16) {
+		var compressed bytes.Buffer
+		writer, err := iohelper.NewCompressedWriter(&compressed, iohelper.LZ4, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for remaining := data; len(remaining) > 0; {
+			n := min(max(int(chunkSize), 1), len(remaining))
+			if _, err := writer.Write(remaining[:n]); err != nil {
+				t.Fatal(err)
+			}
+			remaining = remaining[n:]
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+		decompressed, err := lz4Decompress(compressed.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decompressed, data) {
+			t.Fatalf("Wrote %d bytes but read back %d different bytes", len(data), len(decompressed))
+		}
+	})
+}
@@ -0,0 +1,107 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper
+
+/*
+ * This file contains functions for creating files exclusively and appending
+ * to files that return errors with gperror codes, so that callers can tell
+ * an existing file from a permissions problem, and that can retry transient
+ * errors from NFS-backed directories.
+ */
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/gperror"
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+const (
+	FILE_ALREADY_EXISTS    gperror.ErrorCode = 5001
+	FILE_PERMISSION_DENIED gperror.ErrorCode = 5002
+	FILE_OPEN_FAILED       gperror.ErrorCode = 5003
+)
+
+/*
+ * OpenRetryOptions controls retrying an open that fails with EINTR, or with
+ * ESTALE as happens on NFS when a directory is replaced on the server.  The
+ * open is attempted up to MaxAttempts times, sleeping Delay between attempts;
+ * a MaxAttempts of 0 or 1 means the open is not retried.
+ */
+type OpenRetryOptions struct {
+	MaxAttempts int
+	Delay       time.Duration
+}
+
+func isTransientOpenError(err error) bool {
+	return errors.Is(err, syscall.EINTR) || errors.Is(err, syscall.ESTALE)
+}
+
+func openFileWithRetry(filename string, flags int, options OpenRetryOptions) (io.WriteCloser, error) {
+	var fileHandle io.WriteCloser
+	var err error
+	for attempt := 1; ; attempt++ {
+		fileHandle, err = operating.System.OpenFileWrite(filename, flags, 0644)
+		if err == nil || !isTransientOpenError(err) || attempt >= options.MaxAttempts {
+			break
+		}
+		gplog.Verbose("Retrying open of %s after error: %v", filename, err)
+		operating.System.Sleep(options.Delay)
+	}
+	if err == nil {
+		return fileHandle, nil
+	}
+	if errors.Is(err, fs.ErrExist) {
+		return nil, gperror.New(FILE_ALREADY_EXISTS, "File %s already exists: %w", filename, err)
+	} else if errors.Is(err, fs.ErrPermission) {
+		return nil, gperror.New(FILE_PERMISSION_DENIED, "Permission denied opening %s: %w", filename, err)
+	}
+	return nil, gperror.New(FILE_OPEN_FAILED, "Unable to open %s: %w", filename, err)
+}
+
+/*
+ * CreateFileExclusive creates filename for writing, failing with a
+ * FILE_ALREADY_EXISTS error if it already exists, so that it can be used to
+ * claim a file such as a lock or a backup file that must not be overwritten.
+ */
+func CreateFileExclusive(filename string, options OpenRetryOptions) (io.WriteCloser, error) {
+	return openFileWithRetry(filename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, options)
+}
+
+func MustCreateFileExclusive(filename string, options OpenRetryOptions) io.WriteCloser {
+	fileHandle, err := CreateFileExclusive(filename, options)
+	gplog.FatalOnError(err)
+	return fileHandle
+}
+
+// OpenFileForAppendingWithRetry is OpenFileForAppending, but with gperror codes and retries
+func OpenFileForAppendingWithRetry(filename string, options OpenRetryOptions) (io.WriteCloser, error) {
+	return openFileWithRetry(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, options)
+}
+
+func MustOpenFileForAppendingWithRetry(filename string, options OpenRetryOptions) io.WriteCloser {
+	fileHandle, err := OpenFileForAppendingWithRetry(filename, options)
+	gplog.FatalOnError(err)
+	return fileHandle
+}
@@ -0,0 +1,123 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/gperror"
+	"github.com/apache/cloudberry-go-libs/iohelper"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("iohelper/openexcl tests", func() {
+	var (
+		tempDir  string
+		filename string
+		sleeps   []time.Duration
+	)
+
+	BeforeEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		tempDir = GinkgoT().TempDir()
+		filename = filepath.Join(tempDir, "gpbackup_20240101_toc.yaml")
+		sleeps = make([]time.Duration, 0)
+		operating.System.Sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("CreateFileExclusive", func() {
+		It("creates a new file", func() {
+			writer, err := iohelper.CreateFileExclusive(filename, iohelper.OpenRetryOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = io.WriteString(writer, "contents")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.Close()).To(Succeed())
+			Expect(os.ReadFile(filename)).To(Equal([]byte("contents")))
+		})
+		It("returns a FILE_ALREADY_EXISTS error if the file exists", func() {
+			Expect(os.WriteFile(filename, []byte("original"), 0644)).To(Succeed())
+			_, err := iohelper.CreateFileExclusive(filename, iohelper.OpenRetryOptions{MaxAttempts: 3})
+			Expect(err).To(gperror.MatchCode(iohelper.FILE_ALREADY_EXISTS))
+			Expect(err).To(gperror.MatchMessage("^File .* already exists"))
+			Expect(errors.Is(err, fs.ErrExist)).To(BeTrue())
+			Expect(sleeps).To(BeEmpty())
+			Expect(os.ReadFile(filename)).To(Equal([]byte("original")))
+		})
+		It("returns a FILE_PERMISSION_DENIED error if the directory is not writable", func() {
+			operating.System.OpenFileWrite = func(name string, flag int, perm os.FileMode) (io.WriteCloser, error) {
+				return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EACCES}
+			}
+			_, err := iohelper.CreateFileExclusive(filename, iohelper.OpenRetryOptions{})
+			Expect(err).To(gperror.MatchCode(iohelper.FILE_PERMISSION_DENIED))
+		})
+		It("returns a FILE_OPEN_FAILED error for other errors", func() {
+			_, err := iohelper.CreateFileExclusive(filepath.Join(tempDir, "missing", "file"), iohelper.OpenRetryOptions{})
+			Expect(err).To(gperror.MatchCode(iohelper.FILE_OPEN_FAILED))
+		})
+		It("retries transient NFS errors", func() {
+			injector := testhelper.NewFaultInjector(testhelper.Fault{Op: testhelper.FAULT_OPEN_WRITE, OnCall: 1, Err: syscall.ESTALE})
+			injector.Install()
+			writer, err := iohelper.CreateFileExclusive(filename, iohelper.OpenRetryOptions{MaxAttempts: 3, Delay: time.Second})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.Close()).To(Succeed())
+			Expect(injector.Calls(testhelper.FAULT_OPEN_WRITE)).To(Equal(2))
+			Expect(sleeps).To(Equal([]time.Duration{time.Second}))
+		})
+		It("gives up after the maximum number of attempts", func() {
+			testhelper.NewFaultInjector(testhelper.Fault{Op: testhelper.FAULT_OPEN_WRITE, Err: syscall.EINTR}).Install()
+			_, err := iohelper.CreateFileExclusive(filename, iohelper.OpenRetryOptions{MaxAttempts: 3})
+			Expect(err).To(gperror.MatchCode(iohelper.FILE_OPEN_FAILED))
+			Expect(sleeps).To(HaveLen(2))
+		})
+		It("panics in the Must variant if the file exists", func() {
+			Expect(os.WriteFile(filename, []byte("original"), 0644)).To(Succeed())
+			defer testhelper.ShouldPanicWithMessage("already exists")
+			iohelper.MustCreateFileExclusive(filename, iohelper.OpenRetryOptions{})
+		})
+	})
+	Describe("OpenFileForAppendingWithRetry", func() {
+		It("appends to an existing file", func() {
+			Expect(os.WriteFile(filename, []byte("first\n"), 0644)).To(Succeed())
+			writer, err := iohelper.OpenFileForAppendingWithRetry(filename, iohelper.OpenRetryOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = io.WriteString(writer, "second\n")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.Close()).To(Succeed())
+			Expect(os.ReadFile(filename)).To(Equal([]byte("first\nsecond\n")))
+		})
+		It("retries transient errors", func() {
+			testhelper.NewFaultInjector(testhelper.Fault{Op: testhelper.FAULT_OPEN_WRITE, OnCall: 1, Err: syscall.EINTR}).Install()
+			writer := iohelper.MustOpenFileForAppendingWithRetry(filename, iohelper.OpenRetryOptions{MaxAttempts: 2})
+			Expect(writer.Close()).To(Succeed())
+			Expect(filename).To(BeAnExistingFile())
+		})
+	})
+})
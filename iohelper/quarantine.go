@@ -0,0 +1,117 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper
+
+/*
+ * This file contains functions for preserving corrupted regions of files for
+ * later forensic analysis, rather than only reporting that corruption was
+ * found.  OpenFileForReadingWithChecksum and VerifyChecksumFile quarantine
+ * a file that does not match its checksum when given QuarantineOptions, and
+ * other readers that verify checksums can call QuarantineRegion themselves.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+/*
+ * If MaxRegionBytes is positive, at most that many bytes of the corrupted
+ * region are copied; the report still records the full region length.
+ */
+type QuarantineOptions struct {
+	Dir            string
+	MaxRegionBytes int64
+}
+
+type CorruptionReport struct {
+	File             string    `json:"file"`
+	Offset           int64     `json:"offset"`
+	Length           int64     `json:"length"`
+	Algorithm        string    `json:"algorithm"`
+	ExpectedChecksum string    `json:"expected_checksum"`
+	ActualChecksum   string    `json:"actual_checksum"`
+	DetectedAt       time.Time `json:"detected_at"`
+	RegionFile       string    `json:"region_file"`
+	RegionBytes      int64     `json:"region_bytes"`
+}
+
+/*
+ * QuarantineRegion copies the region of source described by report into
+ * options.Dir, alongside a JSON file containing the report, and returns the
+ * path to the JSON file.  Both files are named after the original file, the
+ * offset of the region, and the time the corruption was detected.
+ */
+func QuarantineRegion(source io.ReaderAt, report CorruptionReport, options QuarantineOptions) (string, error) {
+	if options.Dir == "" {
+		return "", errors.New("No quarantine directory specified")
+	}
+	err := operating.System.MkdirAll(options.Dir, 0755)
+	if err != nil {
+		return "", errors.Errorf("Unable to create quarantine directory %s: %s", options.Dir, err)
+	}
+	if report.DetectedAt.IsZero() {
+		report.DetectedAt = operating.System.Now()
+	}
+	baseName := fmt.Sprintf("%s_%d_%s", filepath.Base(report.File), report.Offset, report.DetectedAt.Format("20060102_150405"))
+
+	regionLength := report.Length
+	if options.MaxRegionBytes > 0 && regionLength > options.MaxRegionBytes {
+		regionLength = options.MaxRegionBytes
+	}
+	report.RegionFile = filepath.Join(options.Dir, baseName+".bin")
+	regionHandle, err := OpenFileForWriting(report.RegionFile)
+	if err != nil {
+		return "", err
+	}
+	report.RegionBytes, err = io.Copy(regionHandle, io.NewSectionReader(source, report.Offset, regionLength))
+	closeErr := regionHandle.Close()
+	if err != nil {
+		return "", errors.Errorf("Unable to copy corrupted region of %s: %s", report.File, err)
+	}
+	if closeErr != nil {
+		return "", closeErr
+	}
+
+	reportContents, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	reportFile := filepath.Join(options.Dir, baseName+".json")
+	reportHandle, err := OpenFileForWriting(reportFile)
+	if err != nil {
+		return "", err
+	}
+	_, err = reportHandle.Write(reportContents)
+	closeErr = reportHandle.Close()
+	if err != nil {
+		return "", errors.Errorf("Unable to write corruption report for %s: %s", report.File, err)
+	}
+	if closeErr != nil {
+		return "", closeErr
+	}
+	gplog.Warn("Corrupted region of %s at offset %d quarantined to %s", report.File, report.Offset, report.RegionFile)
+	return reportFile, nil
+}
@@ -0,0 +1,102 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/iohelper"
+	"github.com/apache/cloudberry-go-libs/operating"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("iohelper/quarantine tests", func() {
+	var (
+		quarantineDir string
+		source        *strings.Reader
+		report        iohelper.CorruptionReport
+	)
+
+	BeforeEach(func() {
+		quarantineDir = filepath.Join(GinkgoT().TempDir(), "quarantine")
+		source = strings.NewReader("0123456789abcdefghij")
+		report = iohelper.CorruptionReport{
+			File:             "/backups/gpbackup_0_20240101.gz",
+			Offset:           10,
+			Length:           6,
+			Algorithm:        "sha256",
+			ExpectedChecksum: "aaaa",
+			ActualChecksum:   "bbbb",
+		}
+		operating.System = operating.InitializeSystemFunctions()
+		operating.System.Now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local) }
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	It("copies the corrupted region and writes a report", func() {
+		reportFile, err := iohelper.QuarantineRegion(source, report, iohelper.QuarantineOptions{Dir: quarantineDir})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reportFile).To(Equal(filepath.Join(quarantineDir, "gpbackup_0_20240101.gz_10_20240102_030405.json")))
+
+		region, err := os.ReadFile(filepath.Join(quarantineDir, "gpbackup_0_20240101.gz_10_20240102_030405.bin"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(region)).To(Equal("abcdef"))
+
+		contents, err := os.ReadFile(reportFile)
+		Expect(err).ToNot(HaveOccurred())
+		var written iohelper.CorruptionReport
+		Expect(json.Unmarshal(contents, &written)).To(Succeed())
+		Expect(written.File).To(Equal(report.File))
+		Expect(written.Offset).To(Equal(int64(10)))
+		Expect(written.ExpectedChecksum).To(Equal("aaaa"))
+		Expect(written.ActualChecksum).To(Equal("bbbb"))
+		Expect(written.RegionBytes).To(Equal(int64(6)))
+		Expect(written.RegionFile).To(HaveSuffix(".bin"))
+	})
+	It("copies at most MaxRegionBytes of the region", func() {
+		reportFile, err := iohelper.QuarantineRegion(source, report, iohelper.QuarantineOptions{Dir: quarantineDir, MaxRegionBytes: 2})
+		Expect(err).ToNot(HaveOccurred())
+		contents, err := os.ReadFile(reportFile)
+		Expect(err).ToNot(HaveOccurred())
+		var written iohelper.CorruptionReport
+		Expect(json.Unmarshal(contents, &written)).To(Succeed())
+		Expect(written.Length).To(Equal(int64(6)))
+		Expect(written.RegionBytes).To(Equal(int64(2)))
+	})
+	It("copies only the bytes available if the region extends past the end of the file", func() {
+		report.Offset = 18
+		reportFile, err := iohelper.QuarantineRegion(source, report, iohelper.QuarantineOptions{Dir: quarantineDir})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reportFile).To(HaveSuffix("_18_20240102_030405.json"))
+		region, err := os.ReadFile(strings.TrimSuffix(reportFile, ".json") + ".bin")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(region)).To(Equal("ij"))
+	})
+	It("returns an error if no quarantine directory is specified", func() {
+		_, err := iohelper.QuarantineRegion(source, report, iohelper.QuarantineOptions{})
+		Expect(err).To(MatchError("No quarantine directory specified"))
+	})
+})
@@ -0,0 +1,264 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper
+
+/*
+ * This file contains a file wrapper that retries reads and writes that fail
+ * with the transient errors NFS mounts report when the server is briefly
+ * unavailable, so that a momentary hiccup does not abort a whole backup.
+ */
+
+import (
+	"context"
+	"io"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/retry"
+	"github.com/pkg/errors"
+)
+
+/*
+ * IsTransientIOError returns true for errors that a retry may absorb: ESTALE,
+ * when an NFS file handle is invalidated by the server, EIO, which NFS clients
+ * report for server errors, and ETIMEDOUT.
+ */
+func IsTransientIOError(err error) bool {
+	return errors.Is(err, syscall.ESTALE) || errors.Is(err, syscall.EIO) || errors.Is(err, syscall.ETIMEDOUT)
+}
+
+/*
+ * NFSRetryPolicy returns the retry.Policy used by the resilient files if none
+ * is given: up to 5 attempts, waiting from 100 milliseconds up to 5 seconds,
+ * retrying only transient errors.
+ */
+func NFSRetryPolicy() retry.Policy {
+	policy := retry.Exponential(5, 100*time.Millisecond, 5*time.Second)
+	policy.Retryable = IsTransientIOError
+	return policy
+}
+
+/*
+ * A ResilientFile reads or writes a file at an offset it tracks itself, so
+ * that after a transient error it can reopen the file by name, as a stale
+ * handle cannot be reused, and retry at the same offset.  Data that was
+ * partly written before the error is simply written again.  Each retry is
+ * logged as a warning.
+ *
+ * Writes are not retried after EIO, even if the policy allows it, since NFS
+ * clients report EIO when writing back data buffered by earlier writes, which
+ * rewriting the current data would not repair.  After a write succeeds on
+ * retry, the file is synced, and the write fails if that does.  Even so, a
+ * retry cannot prove that everything written before it reached the server,
+ * so callers that must be sure of the contents should verify a checksum of
+ * the file after closing it.
+ */
+type ResilientFile struct {
+	filename string
+	reopen   func() (interface{}, error)
+	handle   interface{}
+	offset   int64
+	policy   retry.Policy
+	retries  int
+	closed   bool
+}
+
+type writerAtCloser interface {
+	io.WriterAt
+	io.Closer
+}
+
+func newResilientFile(filename string, policy *retry.Policy, open func(flags int) (interface{}, error), flags int, reopenFlags int) (*ResilientFile, error) {
+	file := &ResilientFile{filename: filename, policy: NFSRetryPolicy()}
+	if policy != nil {
+		file.policy = *policy
+		if file.policy.Retryable == nil {
+			file.policy.Retryable = IsTransientIOError
+		}
+	}
+	file.reopen = func() (interface{}, error) { return open(reopenFlags) }
+	err := file.retry("open", func() error {
+		var err error
+		file.handle, err = open(flags)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+/*
+ * OpenResilientReader opens filename for reading with the given policy, or
+ * with NFSRetryPolicy if it is nil; a policy that does not set Retryable
+ * retries only transient errors.
+ */
+func OpenResilientReader(filename string, policy *retry.Policy) (*ResilientFile, error) {
+	open := func(flags int) (interface{}, error) {
+		return operating.System.OpenFileRead(filename, flags, 0644)
+	}
+	return newResilientFile(filename, policy, open, os.O_RDONLY, os.O_RDONLY)
+}
+
+func MustOpenResilientReader(filename string, policy *retry.Policy) *ResilientFile {
+	file, err := OpenResilientReader(filename, policy)
+	gplog.FatalOnError(err)
+	return file
+}
+
+// OpenResilientWriter creates or truncates filename for writing, as OpenFileForWriting does, with the given policy
+func OpenResilientWriter(filename string, policy *retry.Policy) (*ResilientFile, error) {
+	open := func(flags int) (interface{}, error) {
+		handle, err := operating.System.OpenFileWrite(filename, flags, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := handle.(writerAtCloser); !ok {
+			_ = handle.Close()
+			return nil, errors.Errorf("%s does not support writing at an offset", filename)
+		}
+		return handle, nil
+	}
+	return newResilientFile(filename, policy, open, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.O_WRONLY)
+}
+
+func MustOpenResilientWriter(filename string, policy *retry.Policy) *ResilientFile {
+	file, err := OpenResilientWriter(filename, policy)
+	gplog.FatalOnError(err)
+	return file
+}
+
+/*
+ * retry runs operation with the file's policy, logging each retry, and
+ * reopening the file before retrying if the operation failed with a
+ * transient error.
+ */
+func (file *ResilientFile) retry(operation string, attempt func() error) error {
+	policy := file.policy
+	if operation == "write" {
+		retryable := policy.Retryable
+		policy.Retryable = func(err error) bool {
+			return !errors.Is(err, syscall.EIO) && (retryable == nil || retryable(err))
+		}
+	}
+	userOnRetry := policy.OnRetry
+	policy.OnRetry = func(attemptNum int, err error, delay time.Duration) {
+		file.retries++
+		gplog.Warn("Retrying %s of %s at offset %d in %s after error: %v", operation, file.filename, file.offset, delay, err)
+		if userOnRetry != nil {
+			userOnRetry(attemptNum, err, delay)
+		}
+	}
+	err := retry.Do(context.Background(), policy, func(_ context.Context) error {
+		if file.handle == nil && operation != "open" {
+			handle, err := file.reopen()
+			if err != nil {
+				return err
+			}
+			file.handle = handle
+		}
+		err := attempt()
+		if err != nil && operation != "open" && IsTransientIOError(err) {
+			if closer, ok := file.handle.(io.Closer); ok {
+				_ = closer.Close()
+			}
+			file.handle = nil
+		}
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Unable to %s %s", operation, file.filename)
+	}
+	return nil
+}
+
+func (file *ResilientFile) Read(p []byte) (int, error) {
+	if file.closed {
+		return 0, os.ErrClosed
+	} else if len(p) == 0 {
+		return 0, nil
+	}
+	var n int
+	var readErr error
+	err := file.retry("read", func() error {
+		n, readErr = file.handle.(io.ReaderAt).ReadAt(p, file.offset)
+		if readErr == io.EOF {
+			return nil
+		}
+		return readErr
+	})
+	file.offset += int64(n)
+	if err != nil {
+		return n, err
+	}
+	if readErr == io.EOF && n > 0 {
+		return n, nil
+	}
+	return n, readErr
+}
+
+func (file *ResilientFile) Write(p []byte) (int, error) {
+	if file.closed {
+		return 0, os.ErrClosed
+	}
+	written := 0
+	retries := file.retries
+	err := file.retry("write", func() error {
+		var err error
+		written, err = file.handle.(io.WriterAt).WriteAt(p, file.offset)
+		return err
+	})
+	file.offset += int64(written)
+	if err == nil && file.retries > retries {
+		if syncer, ok := file.handle.(interface{ Sync() error }); ok {
+			if syncErr := syncer.Sync(); syncErr != nil {
+				return written, errors.Wrapf(syncErr, "Unable to sync %s after retrying a write", file.filename)
+			}
+		}
+	}
+	return written, err
+}
+
+// Retries returns how many times an operation on the file has been retried
+func (file *ResilientFile) Retries() int {
+	return file.retries
+}
+
+/*
+ * Close closes the file.  It is not retried, as after an error from close the
+ * state of data not yet flushed to the server is unknown; callers that must be
+ * sure their data was written should check the error.
+ */
+func (file *ResilientFile) Close() error {
+	if file.closed {
+		return nil
+	}
+	file.closed = true
+	if file.handle == nil {
+		return nil
+	}
+	err := file.handle.(io.Closer).Close()
+	file.handle = nil
+	if err != nil {
+		return errors.Wrapf(err, "Unable to close %s", file.filename)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/iohelper"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/retry"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// flakyFile fails each read or write with failErr while *failures is positive, and each sync with syncErr if it is set
+type flakyFile struct {
+	*os.File
+	failures *int
+	failErr  error
+	syncErr  error
+}
+
+func (file flakyFile) Sync() error {
+	if file.syncErr != nil {
+		return file.syncErr
+	}
+	return file.File.Sync()
+}
+
+func (file flakyFile) fail() bool {
+	if *file.failures > 0 {
+		*file.failures--
+		return true
+	}
+	return false
+}
+
+func (file flakyFile) WriteAt(p []byte, offset int64) (int, error) {
+	if file.fail() {
+		n, _ := file.File.WriteAt(p[:len(p)/2], offset)
+		return n, &os.PathError{Op: "write", Path: file.Name(), Err: file.failErr}
+	}
+	return file.File.WriteAt(p, offset)
+}
+
+func (file flakyFile) ReadAt(p []byte, offset int64) (int, error) {
+	if file.fail() {
+		return 0, &os.PathError{Op: "read", Path: file.Name(), Err: file.failErr}
+	}
+	return file.File.ReadAt(p, offset)
+}
+
+var _ = Describe("iohelper/resilient tests", func() {
+	var (
+		filename    string
+		failures    int
+		failErr     error
+		syncErr     error
+		openFlags   []int
+		policy      retry.Policy
+		savedLogger *gplog.GpLogger
+		testLogfile *gbytes.Buffer
+	)
+
+	BeforeEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		savedLogger = gplog.GetLogger()
+		_, _, testLogfile = testhelper.SetupTestLogger()
+		filename = filepath.Join(GinkgoT().TempDir(), "gpbackup_20240101_0_data")
+		failures = 0
+		failErr = syscall.ESTALE
+		syncErr = nil
+		openFlags = make([]int, 0)
+		policy = retry.Constant(3, 0)
+		operating.System.OpenFileWrite = func(name string, flag int, perm os.FileMode) (io.WriteCloser, error) {
+			openFlags = append(openFlags, flag)
+			file, err := os.OpenFile(name, flag, perm)
+			if err != nil {
+				return nil, err
+			}
+			return flakyFile{File: file, failures: &failures, failErr: failErr, syncErr: syncErr}, nil
+		}
+		operating.System.OpenFileRead = func(name string, flag int, perm os.FileMode) (operating.ReadCloserAt, error) {
+			openFlags = append(openFlags, flag)
+			file, err := os.OpenFile(name, flag, perm)
+			if err != nil {
+				return nil, err
+			}
+			return flakyFile{File: file, failures: &failures, failErr: failErr}, nil
+		}
+	})
+	AfterEach(func() {
+		gplog.SetLogger(savedLogger)
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("OpenResilientWriter", func() {
+		It("reopens the file and retries writes that fail with a transient error", func() {
+			writer, err := iohelper.OpenResilientWriter(filename, &policy)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = writer.Write([]byte("first line\n"))
+			Expect(err).ToNot(HaveOccurred())
+			failures = 2
+			n, err := writer.Write([]byte("second line\n"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(Equal(12))
+			Expect(writer.Close()).To(Succeed())
+
+			Expect(os.ReadFile(filename)).To(Equal([]byte("first line\nsecond line\n")))
+			Expect(writer.Retries()).To(Equal(2))
+			Expect(openFlags).To(Equal([]int{os.O_CREATE | os.O_WRONLY | os.O_TRUNC, os.O_WRONLY, os.O_WRONLY}))
+			Expect(testLogfile).To(gbytes.Say(`\[WARNING\]:-Retrying write of .*gpbackup_20240101_0_data at offset 11 in 0s after error: write .*: stale (NFS )?file handle`))
+		})
+		It("returns the error once the policy gives up", func() {
+			writer, err := iohelper.OpenResilientWriter(filename, &policy)
+			Expect(err).ToNot(HaveOccurred())
+			failures = 3
+			_, err = writer.Write([]byte("contents"))
+			Expect(err).To(MatchError(ContainSubstring("Unable to write " + filename + ": 3 attempts failed")))
+			Expect(errors.Is(err, syscall.ESTALE)).To(BeTrue())
+		})
+		It("does not retry errors that are not transient", func() {
+			failErr = syscall.ENOSPC
+			writer, err := iohelper.OpenResilientWriter(filename, &policy)
+			Expect(err).ToNot(HaveOccurred())
+			failures = 1
+			_, err = writer.Write([]byte("contents"))
+			Expect(errors.Is(err, syscall.ENOSPC)).To(BeTrue())
+			Expect(writer.Retries()).To(Equal(0))
+		})
+		It("does not retry writes that fail with EIO", func() {
+			failErr = syscall.EIO
+			writer, err := iohelper.OpenResilientWriter(filename, &policy)
+			Expect(err).ToNot(HaveOccurred())
+			failures = 1
+			_, err = writer.Write([]byte("contents"))
+			Expect(errors.Is(err, syscall.EIO)).To(BeTrue())
+			Expect(writer.Retries()).To(Equal(0))
+		})
+		It("fails a retried write if the file cannot be synced afterward", func() {
+			syncErr = syscall.EIO
+			writer, err := iohelper.OpenResilientWriter(filename, &policy)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = writer.Write([]byte("first line\n"))
+			Expect(err).ToNot(HaveOccurred())
+			failures = 1
+			_, err = writer.Write([]byte("second line\n"))
+			Expect(err).To(MatchError(ContainSubstring("Unable to sync " + filename + " after retrying a write")))
+			Expect(errors.Is(err, syscall.EIO)).To(BeTrue())
+			Expect(writer.Retries()).To(Equal(1))
+		})
+		It("retries opens that fail with a transient error", func() {
+			attempts := 0
+			operating.System.OpenFileWrite = func(name string, flag int, perm os.FileMode) (io.WriteCloser, error) {
+				attempts++
+				if attempts == 1 {
+					return nil, &os.PathError{Op: "open", Path: name, Err: syscall.ETIMEDOUT}
+				}
+				return os.OpenFile(name, flag, perm)
+			}
+			writer, err := iohelper.OpenResilientWriter(filename, &policy)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.Retries()).To(Equal(1))
+			Expect(writer.Close()).To(Succeed())
+		})
+		It("fails writes after the file is closed", func() {
+			writer, err := iohelper.OpenResilientWriter(filename, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.Close()).To(Succeed())
+			_, err = writer.Write([]byte("contents"))
+			Expect(err).To(MatchError(os.ErrClosed))
+			Expect(openFlags).To(HaveLen(1))
+		})
+	})
+	Describe("OpenResilientReader", func() {
+		It("reopens the file and retries reads that fail with a transient error", func() {
+			Expect(os.WriteFile(filename, []byte("first line\nsecond line\n"), 0644)).To(Succeed())
+			reader, err := iohelper.OpenResilientReader(filename, &policy)
+			Expect(err).ToNot(HaveOccurred())
+			buffer := make([]byte, 11)
+			_, err = io.ReadFull(reader, buffer)
+			Expect(err).ToNot(HaveOccurred())
+			failErr = syscall.EIO
+			failures = 1
+
+			rest, err := io.ReadAll(reader)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(rest)).To(Equal("second line\n"))
+			Expect(reader.Retries()).To(Equal(1))
+			Expect(openFlags).To(Equal([]int{os.O_RDONLY, os.O_RDONLY}))
+			Expect(reader.Close()).To(Succeed())
+		})
+	})
+	Describe("NFSRetryPolicy", func() {
+		It("retries only transient errors", func() {
+			policy := iohelper.NFSRetryPolicy()
+			Expect(policy.MaxAttempts).To(Equal(5))
+			Expect(policy.Retryable(&os.PathError{Op: "write", Err: syscall.ESTALE})).To(BeTrue())
+			Expect(policy.Retryable(&os.PathError{Op: "write", Err: syscall.ENOSPC})).To(BeFalse())
+		})
+	})
+})
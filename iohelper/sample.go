@@ -0,0 +1,138 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper
+
+/*
+ * This file contains a writer that keeps a copy of the start of a data
+ * stream while passing the whole stream through, so that an error partway
+ * through a pipeline can be logged with a sample of the data involved.
+ */
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+)
+
+// The number of bytes a SampleTee keeps if SampleOptions.MaxBytes is not set
+const DEFAULT_SAMPLE_BYTES = 4096
+
+/*
+ * SampleOptions controls how much of a stream a SampleTee keeps.  The sample
+ * ends after MaxBytes bytes, or DEFAULT_SAMPLE_BYTES if MaxBytes is 0, or
+ * after MaxRecords records if MaxRecords is nonzero, whichever comes first.
+ * Records end with RecordDelimiter, or a newline if RecordDelimiter is 0.
+ */
+type SampleOptions struct {
+	MaxBytes        int
+	MaxRecords      int
+	RecordDelimiter byte
+}
+
+/*
+ * A SampleTee writes everything written to it to the underlying writer
+ * unchanged, and keeps a copy of the data successfully written until the
+ * sample is full.  Like the other writers in this package, it is not safe
+ * for concurrent use.
+ */
+type SampleTee struct {
+	writer       io.Writer
+	options      SampleOptions
+	sample       []byte
+	records      int
+	full         bool
+	truncated    bool
+	BytesWritten int64
+}
+
+func NewSampleTee(writer io.Writer, options SampleOptions) *SampleTee {
+	if options.MaxBytes <= 0 {
+		options.MaxBytes = DEFAULT_SAMPLE_BYTES
+	}
+	if options.RecordDelimiter == 0 {
+		options.RecordDelimiter = '\n'
+	}
+	return &SampleTee{writer: writer, options: options, sample: make([]byte, 0, options.MaxBytes)}
+}
+
+func (tee *SampleTee) Write(p []byte) (int, error) {
+	n, err := tee.writer.Write(p)
+	tee.keep(p[:n])
+	tee.BytesWritten += int64(n)
+	return n, err
+}
+
+// keep adds as much of data to the sample as the options allow
+func (tee *SampleTee) keep(data []byte) {
+	if tee.full {
+		tee.truncated = tee.truncated || len(data) > 0
+		return
+	}
+	if room := tee.options.MaxBytes - len(tee.sample); len(data) >= room {
+		tee.full = true
+		tee.truncated = len(data) > room
+		data = data[:room]
+	}
+	if tee.options.MaxRecords > 0 {
+		for i, b := range data {
+			if b != tee.options.RecordDelimiter {
+				continue
+			}
+			tee.records++
+			if tee.records == tee.options.MaxRecords {
+				tee.full = true
+				tee.truncated = tee.truncated || i+1 < len(data)
+				data = data[:i+1]
+				break
+			}
+		}
+	}
+	tee.sample = append(tee.sample, data...)
+}
+
+// Sample returns a copy of the data kept so far
+func (tee *SampleTee) Sample() []byte {
+	return append([]byte{}, tee.sample...)
+}
+
+// Truncated returns whether more data has been written than the sample holds
+func (tee *SampleTee) Truncated() bool {
+	return tee.truncated
+}
+
+/*
+ * LogSample logs the sample at debug level, so that it goes to the log file
+ * but not the terminal, after a line beginning with description that says
+ * how much of the stream it covers.  A sample that is not valid UTF-8 is
+ * logged as a hex dump.
+ */
+func (tee *SampleTee) LogSample(description string) {
+	coverage := fmt.Sprintf("all %d bytes", tee.BytesWritten)
+	if tee.truncated {
+		coverage = fmt.Sprintf("the first %d of %d bytes", len(tee.sample), tee.BytesWritten)
+	}
+	sample := string(tee.sample)
+	if !utf8.Valid(tee.sample) {
+		sample = hex.Dump(tee.sample)
+	}
+	gplog.Debug("%s; sample of %s written:\n%s", description, coverage, strings.TrimRight(sample, "\n"))
+}
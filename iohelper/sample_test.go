@@ -0,0 +1,118 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/iohelper"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// shortWriter accepts only the first accept bytes of each write
+type shortWriter struct {
+	accept int
+}
+
+func (writer shortWriter) Write(p []byte) (int, error) {
+	if len(p) > writer.accept {
+		return writer.accept, errors.New("short write")
+	}
+	return len(p), nil
+}
+
+var _ = Describe("iohelper/sample tests", func() {
+	var (
+		output  *bytes.Buffer
+		logfile *gbytes.Buffer
+	)
+
+	BeforeEach(func() {
+		output = &bytes.Buffer{}
+		_, _, logfile = testhelper.SetupTestLogger()
+	})
+
+	Describe("SampleTee", func() {
+		It("passes all data through and keeps the first MaxBytes bytes", func() {
+			tee := iohelper.NewSampleTee(output, iohelper.SampleOptions{MaxBytes: 8})
+			fmt.Fprint(tee, "abc")
+			fmt.Fprint(tee, "defghij")
+			fmt.Fprint(tee, "klm")
+
+			Expect(output.String()).To(Equal("abcdefghijklm"))
+			Expect(tee.BytesWritten).To(Equal(int64(13)))
+			Expect(string(tee.Sample())).To(Equal("abcdefgh"))
+			Expect(tee.Truncated()).To(BeTrue())
+		})
+		It("keeps the first MaxRecords records", func() {
+			tee := iohelper.NewSampleTee(output, iohelper.SampleOptions{MaxRecords: 2})
+			fmt.Fprint(tee, "1|one\n2|tw")
+			fmt.Fprint(tee, "o\n3|three\n")
+
+			Expect(output.String()).To(Equal("1|one\n2|two\n3|three\n"))
+			Expect(string(tee.Sample())).To(Equal("1|one\n2|two\n"))
+			Expect(tee.Truncated()).To(BeTrue())
+		})
+		It("uses a custom record delimiter", func() {
+			tee := iohelper.NewSampleTee(output, iohelper.SampleOptions{MaxRecords: 1, RecordDelimiter: 0x1e})
+			fmt.Fprint(tee, "first\x1esecond\x1e")
+			Expect(string(tee.Sample())).To(Equal("first\x1e"))
+		})
+		It("is not truncated if the whole stream fits in the sample", func() {
+			tee := iohelper.NewSampleTee(output, iohelper.SampleOptions{MaxBytes: 6, MaxRecords: 2})
+			fmt.Fprint(tee, "a\nb\n")
+			Expect(tee.Truncated()).To(BeFalse())
+			fmt.Fprint(tee, "")
+			Expect(tee.Truncated()).To(BeFalse())
+		})
+		It("does not keep data that the underlying writer did not accept", func() {
+			tee := iohelper.NewSampleTee(shortWriter{accept: 3}, iohelper.SampleOptions{})
+			_, err := fmt.Fprint(tee, "abcdef")
+			Expect(err).To(HaveOccurred())
+			Expect(string(tee.Sample())).To(Equal("abc"))
+		})
+		It("returns a copy of the sample", func() {
+			tee := iohelper.NewSampleTee(output, iohelper.SampleOptions{})
+			fmt.Fprint(tee, "abc")
+			tee.Sample()[0] = 'x'
+			Expect(string(tee.Sample())).To(Equal("abc"))
+		})
+	})
+	Describe("SampleTee.LogSample", func() {
+		It("logs the sample to the log file with how much of the stream it covers", func() {
+			tee := iohelper.NewSampleTee(output, iohelper.SampleOptions{MaxRecords: 1})
+			fmt.Fprint(tee, "1|one\n2|two\n")
+			tee.LogSample("Failed to restore public.foo")
+			Expect(logfile).To(gbytes.Say(`\[DEBUG\]:-Failed to restore public.foo; sample of the first 6 of 12 bytes written:\n1\|one\n`))
+		})
+		It("logs binary data as a hex dump", func() {
+			tee := iohelper.NewSampleTee(output, iohelper.SampleOptions{})
+			tee.Write([]byte{0xff, 0x00, 0x41})
+			tee.LogSample("Failed to restore public.foo")
+			Expect(logfile).To(gbytes.Say(`sample of all 3 bytes written:\n00000000  ff 00 41 `))
+			Expect(strings.HasSuffix(string(logfile.Contents()), "\n\n")).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,316 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper
+
+/*
+ * This file contains functions for sorting files of newline-separated records
+ * that may be too large to sort in memory.
+ */
+
+import (
+	"bufio"
+	"container/heap"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+// The approximate memory used by each record beyond its contents
+const recordOverheadBytes = 32
+
+// The most sorted runs that are merged, and so held open, at once
+const maxMergeRuns = 64
+
+/*
+ * SortFile sorts the lines of inputFile according to less and writes them to
+ * outputFile, which may be the same file as inputFile.  Records with equal
+ * keys keep their original order, and every record in the output, including
+ * the last, ends with a newline.
+ *
+ * At most approximately memoryLimit bytes of records are held in memory at
+ * once.  If the input is larger than that, it is split into sorted runs of
+ * that size, which are written to temporary files in the same directory as
+ * outputFile and then merged, at most maxMergeRuns at a time, so that a large
+ * input does not exhaust the open file limit.  The temporary files are
+ * removed before SortFile returns.
+ *
+ * The sorted records are written to outputFile + ".tmp", which is synced and
+ * renamed over outputFile only once every record has been written, so that
+ * if sorting fails, e.g. because the disk is full, outputFile is left as it
+ * was; this is what makes sorting a file in place safe.
+ */
+func SortFile(inputFile string, outputFile string, less func(a, b string) bool, memoryLimit int64) error {
+	if memoryLimit <= 0 {
+		return errors.New("Memory limit for sorting must be positive")
+	}
+	input, err := OpenFileForReading(inputFile)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = input.Close()
+	}()
+
+	runFiles := make([]string, 0)
+	defer func() {
+		for _, runFile := range runFiles {
+			_ = operating.System.Remove(runFile)
+		}
+	}()
+	runDir := filepath.Dir(outputFile)
+	records := make([]string, 0)
+	var size int64
+	reader := bufio.NewReader(input)
+	for {
+		record, readErr := readRecord(reader)
+		if readErr == io.EOF {
+			break
+		} else if readErr != nil {
+			return errors.Wrapf(readErr, "Unable to read %s", inputFile)
+		}
+		records = append(records, record)
+		size += int64(len(record)) + recordOverheadBytes
+		if size >= memoryLimit {
+			runFile, runErr := writeSortedRun(runDir, records, less)
+			if runFile != "" {
+				runFiles = append(runFiles, runFile)
+			}
+			if runErr != nil {
+				return runErr
+			}
+			records = make([]string, 0)
+			size = 0
+		}
+	}
+
+	if len(runFiles) == 0 {
+		// Everything fit in memory, so there is nothing to merge
+		sort.SliceStable(records, func(i, j int) bool { return less(records[i], records[j]) })
+		return writeSortedOutput(outputFile, func(output io.Writer) error {
+			return writeRecords(output, records)
+		})
+	}
+	if len(records) > 0 {
+		runFile, runErr := writeSortedRun(runDir, records, less)
+		if runFile != "" {
+			runFiles = append(runFiles, runFile)
+		}
+		if runErr != nil {
+			return runErr
+		}
+	}
+
+	runs := runFiles
+	for len(runs) > maxMergeRuns {
+		mergedRuns := make([]string, 0, len(runs)/maxMergeRuns+1)
+		for start := 0; start < len(runs); start += maxMergeRuns {
+			end := min(start+maxMergeRuns, len(runs))
+			runFile, runErr := mergeToRunFile(runDir, runs[start:end], less)
+			if runFile != "" {
+				runFiles = append(runFiles, runFile)
+				mergedRuns = append(mergedRuns, runFile)
+			}
+			if runErr != nil {
+				return runErr
+			}
+			for _, merged := range runs[start:end] {
+				_ = operating.System.Remove(merged)
+			}
+		}
+		runs = mergedRuns
+	}
+	return writeSortedOutput(outputFile, func(output io.Writer) error {
+		return mergeSortedRuns(runs, output, less)
+	})
+}
+
+func MustSortFile(inputFile string, outputFile string, less func(a, b string) bool, memoryLimit int64) {
+	err := SortFile(inputFile, outputFile, less, memoryLimit)
+	gplog.FatalOnError(err)
+}
+
+// readRecord returns the next line without its newline, or io.EOF if there are no more
+func readRecord(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err == io.EOF && line != "" {
+		return line, nil
+	} else if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(line, "\n"), nil
+}
+
+func writeRecords(writer io.Writer, records []string) error {
+	bufferedWriter := bufio.NewWriter(writer)
+	for _, record := range records {
+		if _, err := bufferedWriter.WriteString(record + "\n"); err != nil {
+			return err
+		}
+	}
+	return bufferedWriter.Flush()
+}
+
+// writeSortedOutput calls write with a temporary file next to filename and renames it over filename if write succeeds
+func writeSortedOutput(filename string, write func(output io.Writer) error) error {
+	tempPath := filename + ".tmp"
+	output, err := OpenFileForWriting(tempPath)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to write sorted records to %s", filename)
+	}
+	err = write(output)
+	if syncer, ok := output.(interface{ Sync() error }); ok && err == nil {
+		err = syncer.Sync()
+	}
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = operating.System.Rename(tempPath, filename)
+	}
+	if err != nil {
+		_ = operating.System.Remove(tempPath)
+		return errors.Wrapf(err, "Unable to write sorted records to %s", filename)
+	}
+	return nil
+}
+
+/*
+ * writeSortedRun sorts records and writes them to a new temporary file,
+ * returning its name even on error so that the caller can remove it.
+ */
+func writeSortedRun(dir string, records []string, less func(a, b string) bool) (string, error) {
+	sort.SliceStable(records, func(i, j int) bool { return less(records[i], records[j]) })
+	runFile, err := operating.System.TempFile(dir, "sort_run_*")
+	if err != nil {
+		return "", errors.Wrapf(err, "Unable to create temporary file in %s for sorting", dir)
+	}
+	err = writeRecords(runFile, records)
+	closeErr := runFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return runFile.Name(), errors.Wrapf(err, "Unable to write temporary file %s for sorting", runFile.Name())
+	}
+	return runFile.Name(), nil
+}
+
+type runHead struct {
+	record string
+	run    int
+}
+
+/*
+ * runHeap holds the next record from each sorted run.  Records with equal keys
+ * are ordered by run, since earlier runs hold records from earlier in the
+ * input, to keep the sort stable.
+ */
+type runHeap struct {
+	heads []runHead
+	less  func(a, b string) bool
+}
+
+func (h *runHeap) Len() int { return len(h.heads) }
+func (h *runHeap) Less(i, j int) bool {
+	a, b := h.heads[i], h.heads[j]
+	if h.less(a.record, b.record) {
+		return true
+	} else if h.less(b.record, a.record) {
+		return false
+	}
+	return a.run < b.run
+}
+func (h *runHeap) Swap(i, j int)      { h.heads[i], h.heads[j] = h.heads[j], h.heads[i] }
+func (h *runHeap) Push(x interface{}) { h.heads = append(h.heads, x.(runHead)) }
+func (h *runHeap) Pop() interface{} {
+	last := h.heads[len(h.heads)-1]
+	h.heads = h.heads[:len(h.heads)-1]
+	return last
+}
+
+/*
+ * mergeToRunFile merges runFiles into a new temporary file, returning its
+ * name even on error so that the caller can remove it.
+ */
+func mergeToRunFile(dir string, runFiles []string, less func(a, b string) bool) (string, error) {
+	runFile, err := operating.System.TempFile(dir, "sort_run_*")
+	if err != nil {
+		return "", errors.Wrapf(err, "Unable to create temporary file in %s for sorting", dir)
+	}
+	err = mergeSortedRuns(runFiles, runFile, less)
+	closeErr := runFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return runFile.Name(), errors.Wrapf(err, "Unable to write temporary file %s for sorting", runFile.Name())
+	}
+	return runFile.Name(), nil
+}
+
+func mergeSortedRuns(runFiles []string, output io.Writer, less func(a, b string) bool) error {
+	readers := make([]*bufio.Reader, len(runFiles))
+	for i, runFile := range runFiles {
+		handle, err := OpenFileForReading(runFile)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = handle.Close()
+		}()
+		readers[i] = bufio.NewReader(handle)
+	}
+	return mergeInto(output, readers, less)
+}
+
+func mergeInto(output io.Writer, readers []*bufio.Reader, less func(a, b string) bool) error {
+	heads := &runHeap{heads: make([]runHead, 0, len(readers)), less: less}
+	for i, reader := range readers {
+		record, err := readRecord(reader)
+		if err == io.EOF {
+			continue
+		} else if err != nil {
+			return err
+		}
+		heads.heads = append(heads.heads, runHead{record: record, run: i})
+	}
+	heap.Init(heads)
+
+	writer := bufio.NewWriter(output)
+	for heads.Len() > 0 {
+		head := heads.heads[0]
+		if _, err := writer.WriteString(head.record + "\n"); err != nil {
+			return err
+		}
+		record, err := readRecord(readers[head.run])
+		if err == io.EOF {
+			heap.Pop(heads)
+			continue
+		} else if err != nil {
+			return err
+		}
+		heads.heads[0].record = record
+		heap.Fix(heads, 0)
+	}
+	return writer.Flush()
+}
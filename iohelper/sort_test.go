@@ -0,0 +1,184 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper_test
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/apache/cloudberry-go-libs/iohelper"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("iohelper/sort tests", func() {
+	var (
+		tempDir    string
+		inputFile  string
+		outputFile string
+	)
+	lessString := func(a, b string) bool { return a < b }
+	// Compare only the part before the first comma, to check stability
+	lessKey := func(a, b string) bool { return strings.Split(a, ",")[0] < strings.Split(b, ",")[0] }
+	writeInput := func(contents string) {
+		Expect(os.WriteFile(inputFile, []byte(contents), 0644)).To(Succeed())
+	}
+	readOutput := func() string {
+		contents, err := os.ReadFile(outputFile)
+		Expect(err).ToNot(HaveOccurred())
+		return string(contents)
+	}
+	expectNoRunFiles := func() {
+		runFiles, err := filepath.Glob(filepath.Join(tempDir, "sort_run_*"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(runFiles).To(BeEmpty())
+		tempFiles, err := filepath.Glob(filepath.Join(tempDir, "*.tmp"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tempFiles).To(BeEmpty())
+	}
+
+	BeforeEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		tempDir = GinkgoT().TempDir()
+		inputFile = filepath.Join(tempDir, "input")
+		outputFile = filepath.Join(tempDir, "output")
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	It("sorts a file that fits in memory", func() {
+		writeInput("sdw3\nsdw1\nsdw2\n")
+		Expect(iohelper.SortFile(inputFile, outputFile, lessString, 1024)).To(Succeed())
+		Expect(readOutput()).To(Equal("sdw1\nsdw2\nsdw3\n"))
+	})
+	It("adds a newline to a last record without one", func() {
+		writeInput("b\na")
+		Expect(iohelper.SortFile(inputFile, outputFile, lessString, 1024)).To(Succeed())
+		Expect(readOutput()).To(Equal("a\nb\n"))
+	})
+	It("writes an empty file for empty input", func() {
+		writeInput("")
+		Expect(iohelper.SortFile(inputFile, outputFile, lessString, 1024)).To(Succeed())
+		Expect(readOutput()).To(Equal(""))
+	})
+	It("sorts a file larger than the memory limit by merging sorted runs", func() {
+		records := make([]string, 1000)
+		for i := range records {
+			records[i] = fmt.Sprintf("record%06d", i)
+		}
+		shuffled := make([]string, len(records))
+		copy(shuffled, records)
+		rand.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+		writeInput(strings.Join(shuffled, "\n") + "\n")
+
+		Expect(iohelper.SortFile(inputFile, outputFile, lessString, 500)).To(Succeed())
+		Expect(readOutput()).To(Equal(strings.Join(records, "\n") + "\n"))
+		expectNoRunFiles()
+	})
+	It("keeps records with equal keys in their original order across runs", func() {
+		lines := make([]string, 0)
+		for i := 0; i < 200; i++ {
+			lines = append(lines, fmt.Sprintf("key%d,%03d", i%3, i))
+		}
+		writeInput(strings.Join(lines, "\n") + "\n")
+		expected := make([]string, len(lines))
+		copy(expected, lines)
+		sort.SliceStable(expected, func(i, j int) bool { return lessKey(expected[i], expected[j]) })
+
+		Expect(iohelper.SortFile(inputFile, outputFile, lessKey, 300)).To(Succeed())
+		Expect(readOutput()).To(Equal(strings.Join(expected, "\n") + "\n"))
+	})
+	It("sorts a file in place", func() {
+		writeInput(strings.Repeat("b\na\nc\n", 50))
+		outputFile = inputFile
+		Expect(iohelper.SortFile(inputFile, inputFile, lessString, 100)).To(Succeed())
+		Expect(readOutput()).To(Equal(strings.Repeat("a\n", 50) + strings.Repeat("b\n", 50) + strings.Repeat("c\n", 50)))
+		expectNoRunFiles()
+	})
+	It("leaves the file unchanged if sorting it in place fails", func() {
+		contents := strings.Repeat("b\na\nc\n", 50)
+		writeInput(contents)
+		outputFile = inputFile
+		testhelper.NewFaultInjector(testhelper.Fault{Op: testhelper.FAULT_WRITE, Path: "input.tmp", AfterBytes: 10, Err: syscall.ENOSPC}).Install()
+
+		err := iohelper.SortFile(inputFile, inputFile, lessString, 100)
+		Expect(err).To(MatchError(ContainSubstring("Unable to write sorted records to %s", inputFile)))
+		Expect(readOutput()).To(Equal(contents))
+		expectNoRunFiles()
+	})
+	It("merges many runs in several passes without opening them all at once", func() {
+		records := make([]string, 3000)
+		for i := range records {
+			records[i] = fmt.Sprintf("record%06d", i)
+		}
+		shuffled := make([]string, len(records))
+		copy(shuffled, records)
+		rand.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+		writeInput(strings.Join(shuffled, "\n") + "\n")
+		openFiles, maxOpenFiles := 0, 0
+		operating.System.OpenFileRead = func(name string, flag int, perm os.FileMode) (operating.ReadCloserAt, error) {
+			handle, err := operating.OpenFileRead(name, flag, perm)
+			if err == nil {
+				openFiles++
+				maxOpenFiles = max(maxOpenFiles, openFiles)
+				handle = &countedFile{ReadCloserAt: handle, openFiles: &openFiles}
+			}
+			return handle, err
+		}
+
+		Expect(iohelper.SortFile(inputFile, outputFile, lessString, 100)).To(Succeed())
+		Expect(readOutput()).To(Equal(strings.Join(records, "\n") + "\n"))
+		// The input file stays open while the runs are merged
+		Expect(maxOpenFiles).To(BeNumerically("<=", 65))
+		Expect(openFiles).To(Equal(0))
+		expectNoRunFiles()
+	})
+	It("returns an error if the memory limit is not positive", func() {
+		writeInput("a\n")
+		err := iohelper.SortFile(inputFile, outputFile, lessString, 0)
+		Expect(err).To(MatchError("Memory limit for sorting must be positive"))
+	})
+	It("returns an error if the input file cannot be read", func() {
+		err := iohelper.SortFile(filepath.Join(tempDir, "missing"), outputFile, lessString, 1024)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Unable to open file for reading"))
+	})
+	It("panics on error in MustSortFile", func() {
+		defer testhelper.ShouldPanicWithMessage("Memory limit for sorting must be positive")
+		iohelper.MustSortFile(inputFile, outputFile, lessString, -1)
+	})
+})
+
+type countedFile struct {
+	operating.ReadCloserAt
+	openFiles *int
+}
+
+func (file *countedFile) Close() error {
+	*file.openFiles--
+	return file.ReadCloserAt.Close()
+}
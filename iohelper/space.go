@@ -0,0 +1,166 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper
+
+/*
+ * This file contains functions for checking that the filesystem a file will
+ * be written to has room for it before the write begins, so that a long
+ * write fails or warns up front rather than with ENOSPC partway through.
+ */
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/apache/cloudberry-go-libs/gperror"
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+)
+
+const FILE_INSUFFICIENT_SPACE gperror.ErrorCode = 5004
+
+type SpaceCheckMode uint8
+
+const (
+	SPACE_CHECK_OFF SpaceCheckMode = iota
+	SPACE_CHECK_WARN
+	SPACE_CHECK_ERROR
+)
+
+/*
+ * SpaceCheckOptions controls the free space check made before a write.  With
+ * SPACE_CHECK_WARN a shortfall is logged as a warning and the write goes
+ * ahead; with SPACE_CHECK_ERROR it fails with a FILE_INSUFFICIENT_SPACE
+ * error.  ExpectedBytes is the size of the data about to be written, and no
+ * check is made if it is 0, i.e. unknown.  ReserveBytes is the space that
+ * must be left free once the write is done, on top of ExpectedBytes.
+ */
+type SpaceCheckOptions struct {
+	Mode          SpaceCheckMode
+	ExpectedBytes int64
+	ReserveBytes  int64
+}
+
+/*
+ * SpaceCheckResult describes the filesystem a write was checked against.
+ * Path is the directory whose filesystem was checked, which is the nearest
+ * existing ancestor of the file being written.
+ */
+type SpaceCheckResult struct {
+	Path           string
+	FilesystemType string
+	AvailableBytes uint64
+	RequiredBytes  uint64
+}
+
+func (result SpaceCheckResult) Sufficient() bool {
+	return result.AvailableBytes >= result.RequiredBytes
+}
+
+func (result SpaceCheckResult) String() string {
+	return fmt.Sprintf("filesystem at %s (%s) has %d bytes available, but %d bytes are required", result.Path, result.FilesystemType, result.AvailableBytes, result.RequiredBytes)
+}
+
+/*
+ * CheckSpaceForWrite checks whether the filesystem that filename is on has
+ * room for options.ExpectedBytes plus options.ReserveBytes, and warns or
+ * returns an error according to options.Mode if it does not.  If the check
+ * is off or the size is unknown, it returns an empty result and does nothing.
+ * If the free space cannot be determined, a warning is logged and the write
+ * is allowed, since the write itself will report any real problem.
+ */
+func CheckSpaceForWrite(filename string, options SpaceCheckOptions) (SpaceCheckResult, error) {
+	return checkSpaceInDir(filepath.Dir(filename), filename, options)
+}
+
+func checkSpaceInDir(dir string, filename string, options SpaceCheckOptions) (SpaceCheckResult, error) {
+	if options.Mode == SPACE_CHECK_OFF || options.ExpectedBytes <= 0 {
+		return SpaceCheckResult{}, nil
+	}
+	result := SpaceCheckResult{
+		Path:          nearestExistingDir(dir),
+		RequiredBytes: uint64(options.ExpectedBytes),
+	}
+	if options.ReserveBytes > 0 {
+		result.RequiredBytes += uint64(options.ReserveBytes)
+	}
+	usage, err := operating.System.DiskUsage(result.Path)
+	if err != nil {
+		gplog.Warn("Unable to check free space for %s: %v", filename, err)
+		return result, nil
+	}
+	result.AvailableBytes = usage.AvailableBytes
+	result.FilesystemType, err = operating.System.FilesystemType(result.Path)
+	if err != nil {
+		result.FilesystemType = "unknown"
+	}
+	if result.Sufficient() {
+		return result, nil
+	}
+	if options.Mode == SPACE_CHECK_ERROR {
+		return result, gperror.New(FILE_INSUFFICIENT_SPACE, "Not enough space to write %s: %s", filename, result)
+	}
+	gplog.Warn("Writing %s may run out of space: %s", filename, result)
+	return result, nil
+}
+
+// nearestExistingDir returns dir, or its closest ancestor that exists if it has not been created yet
+func nearestExistingDir(dir string) string {
+	for {
+		if _, err := operating.System.Stat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// OpenFileForWritingWithSpaceCheck is OpenFileForWriting, but checks for free space first
+func OpenFileForWritingWithSpaceCheck(filename string, options SpaceCheckOptions) (io.WriteCloser, error) {
+	if _, err := CheckSpaceForWrite(filename, options); err != nil {
+		return nil, err
+	}
+	return OpenFileForWriting(filename)
+}
+
+func MustOpenFileForWritingWithSpaceCheck(filename string, options SpaceCheckOptions) io.WriteCloser {
+	fileHandle, err := OpenFileForWritingWithSpaceCheck(filename, options)
+	gplog.FatalOnError(err)
+	return fileHandle
+}
+
+// checkSpaceForCopy checks dst for room for the files under src that will be copied, if no size was given
+func checkSpaceForCopy(src string, dst string, options CopyDirOptions) error {
+	spaceCheck := options.SpaceCheck
+	if spaceCheck.Mode == SPACE_CHECK_OFF {
+		return nil
+	}
+	if spaceCheck.ExpectedBytes == 0 {
+		size, err := SizeOfDir(src, options.WalkOptions)
+		if err != nil {
+			return err
+		}
+		spaceCheck.ExpectedBytes = size
+	}
+	_, err := checkSpaceInDir(dst, dst, spaceCheck)
+	return err
+}
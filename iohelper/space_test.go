@@ -0,0 +1,150 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package iohelper_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/apache/cloudberry-go-libs/gperror"
+	"github.com/apache/cloudberry-go-libs/iohelper"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("iohelper/space tests", func() {
+	var (
+		tempDir     string
+		checkedPath string
+		logfile     *gbytes.Buffer
+	)
+
+	BeforeEach(func() {
+		_, _, logfile = testhelper.SetupTestLogger()
+		operating.System = operating.InitializeSystemFunctions()
+		tempDir = GinkgoT().TempDir()
+		checkedPath = ""
+		operating.System.DiskUsage = func(path string) (operating.FilesystemUsage, error) {
+			checkedPath = path
+			return operating.FilesystemUsage{TotalBytes: 1000, FreeBytes: 200, AvailableBytes: 100}, nil
+		}
+		operating.System.FilesystemType = func(path string) (string, error) { return "nfs", nil }
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("CheckSpaceForWrite", func() {
+		It("does nothing if the check is off or the size is unknown", func() {
+			for _, options := range []iohelper.SpaceCheckOptions{
+				{Mode: iohelper.SPACE_CHECK_OFF, ExpectedBytes: 500},
+				{Mode: iohelper.SPACE_CHECK_ERROR},
+			} {
+				result, err := iohelper.CheckSpaceForWrite(filepath.Join(tempDir, "file"), options)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(result).To(Equal(iohelper.SpaceCheckResult{}))
+			}
+			Expect(checkedPath).To(Equal(""))
+		})
+		It("succeeds if there is enough space including the reserve", func() {
+			result, err := iohelper.CheckSpaceForWrite(filepath.Join(tempDir, "file"), iohelper.SpaceCheckOptions{Mode: iohelper.SPACE_CHECK_ERROR, ExpectedBytes: 60, ReserveBytes: 40})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(iohelper.SpaceCheckResult{Path: tempDir, FilesystemType: "nfs", AvailableBytes: 100, RequiredBytes: 100}))
+			Expect(result.Sufficient()).To(BeTrue())
+			Expect(string(logfile.Contents())).ToNot(ContainSubstring("WARNING"))
+		})
+		It("returns an error if there is not enough space in error mode", func() {
+			filename := filepath.Join(tempDir, "file")
+			result, err := iohelper.CheckSpaceForWrite(filename, iohelper.SpaceCheckOptions{Mode: iohelper.SPACE_CHECK_ERROR, ExpectedBytes: 60, ReserveBytes: 41})
+			Expect(err).To(gperror.MatchCode(iohelper.FILE_INSUFFICIENT_SPACE))
+			Expect(err).To(gperror.MatchMessage("^Not enough space to write " + filename + ": filesystem at " + tempDir + ` \(nfs\) has 100 bytes available, but 101 bytes are required$`))
+			Expect(result.Sufficient()).To(BeFalse())
+		})
+		It("logs a warning if there is not enough space in warn mode", func() {
+			filename := filepath.Join(tempDir, "file")
+			result, err := iohelper.CheckSpaceForWrite(filename, iohelper.SpaceCheckOptions{Mode: iohelper.SPACE_CHECK_WARN, ExpectedBytes: 150})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.RequiredBytes).To(Equal(uint64(150)))
+			Expect(logfile).To(gbytes.Say(`\[WARNING\]:-Writing ` + filename + ` may run out of space: filesystem at ` + tempDir + ` \(nfs\) has 100 bytes available, but 150 bytes are required`))
+		})
+		It("checks the nearest existing directory if the file's directory does not exist yet", func() {
+			_, err := iohelper.CheckSpaceForWrite(filepath.Join(tempDir, "a", "b", "file"), iohelper.SpaceCheckOptions{Mode: iohelper.SPACE_CHECK_WARN, ExpectedBytes: 1})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(checkedPath).To(Equal(tempDir))
+		})
+		It("warns and allows the write if the free space cannot be determined", func() {
+			operating.System.DiskUsage = func(path string) (operating.FilesystemUsage, error) {
+				return operating.FilesystemUsage{}, errors.New("statfs failed")
+			}
+			_, err := iohelper.CheckSpaceForWrite(filepath.Join(tempDir, "file"), iohelper.SpaceCheckOptions{Mode: iohelper.SPACE_CHECK_ERROR, ExpectedBytes: 1000})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(logfile).To(gbytes.Say("Unable to check free space for .*: statfs failed"))
+		})
+	})
+	Describe("OpenFileForWritingWithSpaceCheck", func() {
+		It("does not create the file if there is not enough space", func() {
+			filename := filepath.Join(tempDir, "file")
+			_, err := iohelper.OpenFileForWritingWithSpaceCheck(filename, iohelper.SpaceCheckOptions{Mode: iohelper.SPACE_CHECK_ERROR, ExpectedBytes: 101})
+			Expect(err).To(gperror.MatchCode(iohelper.FILE_INSUFFICIENT_SPACE))
+			Expect(filename).ToNot(BeAnExistingFile())
+		})
+		It("opens the file if there is enough space", func() {
+			filename := filepath.Join(tempDir, "file")
+			writer, err := iohelper.OpenFileForWritingWithSpaceCheck(filename, iohelper.SpaceCheckOptions{Mode: iohelper.SPACE_CHECK_ERROR, ExpectedBytes: 100})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.Close()).To(Succeed())
+			Expect(filename).To(BeAnExistingFile())
+		})
+		It("panics in the Must variant if there is not enough space", func() {
+			defer testhelper.ShouldPanicWithMessage("Not enough space to write")
+			iohelper.MustOpenFileForWritingWithSpaceCheck(filepath.Join(tempDir, "file"), iohelper.SpaceCheckOptions{Mode: iohelper.SPACE_CHECK_ERROR, ExpectedBytes: 101})
+		})
+	})
+	Describe("CopyDir with a space check", func() {
+		var srcDir string
+		BeforeEach(func() {
+			srcDir = filepath.Join(tempDir, "src")
+			Expect(os.MkdirAll(filepath.Join(srcDir, "base"), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(srcDir, "base", "1259"), make([]byte, 80), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(srcDir, "base", "1259.tmp"), make([]byte, 30), 0600)).To(Succeed())
+		})
+		It("checks the destination for room for the files that will be copied", func() {
+			dstDir := filepath.Join(tempDir, "dst")
+			options := iohelper.CopyDirOptions{WalkOptions: iohelper.WalkOptions{Skip: []string{"*.tmp"}}, SpaceCheck: iohelper.SpaceCheckOptions{Mode: iohelper.SPACE_CHECK_ERROR}}
+			Expect(iohelper.CopyDir(srcDir, dstDir, options)).To(Succeed())
+			Expect(checkedPath).To(Equal(tempDir))
+			Expect(filepath.Join(dstDir, "base", "1259")).To(BeAnExistingFile())
+		})
+		It("copies nothing if there is not enough space", func() {
+			dstDir := filepath.Join(tempDir, "dst")
+			err := iohelper.CopyDir(srcDir, dstDir, iohelper.CopyDirOptions{SpaceCheck: iohelper.SpaceCheckOptions{Mode: iohelper.SPACE_CHECK_ERROR}})
+			Expect(err).To(MatchError(ContainSubstring("has 100 bytes available, but 110 bytes are required")))
+			Expect(dstDir).ToNot(BeADirectory())
+		})
+		It("uses the expected size if one is given", func() {
+			dstDir := filepath.Join(tempDir, "dst")
+			err := iohelper.CopyDir(srcDir, dstDir, iohelper.CopyDirOptions{SpaceCheck: iohelper.SpaceCheckOptions{Mode: iohelper.SPACE_CHECK_ERROR, ExpectedBytes: 10}})
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,359 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package lockfile
+
+/*
+ * This file contains functions for taking a lock that keeps two instances of
+ * a utility, or two utilities that must not run together, from running at the
+ * same time on a host.
+ */
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/gperror"
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/iohelper"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	LOCK_HELD    gperror.ErrorCode = 5101
+	LOCK_TIMEOUT gperror.ErrorCode = 5102
+)
+
+const (
+	DEFAULT_LOCK_DIR      = "/tmp"
+	LOCK_FILE_SUFFIX      = ".lck"
+	LOCK_TIME_FORMAT      = "2006-01-02 15:04:05"
+	DEFAULT_POLL_INTERVAL = time.Second
+)
+
+/*
+ * A lock file whose contents cannot be parsed may be one that another process
+ * has just created and not yet written, so it is only treated as stale once it
+ * is this old.
+ */
+var IncompleteLockAge = time.Minute
+
+/*
+ * Options controls where a lock is created and how long Acquire waits for it.
+ *
+ * Dir:          The directory for the lock file; DEFAULT_LOCK_DIR if empty.
+ * Timeout:      How long to wait for another process to release the lock; if
+ *               0, Acquire fails at once if the lock is held.
+ * PollInterval: How often to check whether the lock has been released while
+ *               waiting; DEFAULT_POLL_INTERVAL if 0.
+ * Description:  What the process holding the lock is doing, e.g. "gpbackup
+ *               of database sales", shown to anyone else who tries to take it.
+ */
+type Options struct {
+	Dir          string
+	Timeout      time.Duration
+	PollInterval time.Duration
+	Description  string
+}
+
+// Holder describes the process holding a lock, as recorded in the lock file
+type Holder struct {
+	Pid         int
+	Hostname    string
+	Started     time.Time
+	Description string
+}
+
+func (holder Holder) String() string {
+	if holder.Pid == 0 {
+		return "an unknown process"
+	}
+	description := fmt.Sprintf("process %d on %s", holder.Pid, holder.Hostname)
+	if holder.Description != "" {
+		description = fmt.Sprintf("%s (%s)", holder.Description, description)
+	}
+	if !holder.Started.IsZero() {
+		description += " since " + holder.Started.Format(LOCK_TIME_FORMAT)
+	}
+	return description
+}
+
+/*
+ * The lock file holds the process ID on its first line, as a pidfile does, so
+ * that it can be read with operating.ReadPidfile and by shell scripts.
+ */
+func (holder Holder) contents() string {
+	return fmt.Sprintf("%d\n%s\n%s\n%s\n", holder.Pid, holder.Hostname, holder.Started.Format(time.RFC3339), holder.Description)
+}
+
+func parseHolder(contents string) (Holder, error) {
+	lines := strings.SplitN(contents, "\n", 4)
+	if len(lines) < 4 {
+		return Holder{}, errors.New("lock file is incomplete")
+	}
+	pid, err := strconv.Atoi(lines[0])
+	if err != nil || pid <= 0 {
+		return Holder{}, errors.Errorf("invalid process ID %q", lines[0])
+	}
+	started, err := time.Parse(time.RFC3339, lines[2])
+	if err != nil {
+		return Holder{}, errors.Errorf("invalid start time %q", lines[2])
+	}
+	return Holder{
+		Pid:         pid,
+		Hostname:    lines[1],
+		Started:     started,
+		Description: strings.TrimSuffix(lines[3], "\n"),
+	}, nil
+}
+
+// ReadHolder returns the process holding the lock in the given lock file
+func ReadHolder(path string) (Holder, error) {
+	contents, err := operating.System.ReadFile(path)
+	if err != nil {
+		return Holder{}, errors.Wrapf(err, "Unable to read lock file %s", path)
+	}
+	holder, err := parseHolder(string(contents))
+	if err != nil {
+		return Holder{}, errors.Wrapf(err, "Unable to read lock file %s", path)
+	}
+	return holder, nil
+}
+
+// LockPath returns the path of the lock file Acquire would use for name
+func LockPath(name string, options Options) string {
+	dir := options.Dir
+	if dir == "" {
+		dir = DEFAULT_LOCK_DIR
+	}
+	return filepath.Join(dir, name+LOCK_FILE_SUFFIX)
+}
+
+type Lock struct {
+	Path     string
+	Holder   Holder
+	released bool
+}
+
+/*
+ * Acquire takes the lock with the given name, such as "gpbackup_sales", by
+ * creating a lock file for it.  The name should include whatever must not be
+ * worked on concurrently, such as a database name, so that unrelated runs of
+ * the same utility do not block each other.
+ *
+ * If the lock is held, Acquire waits up to options.Timeout for it to be
+ * released and then fails with a LOCK_HELD or LOCK_TIMEOUT error naming the
+ * process holding it.  A lock left behind by a process on this host that is
+ * no longer running is stale, and is removed with a warning and taken.  A lock
+ * held by another host, as with a lock directory on shared storage, is never
+ * considered stale, as whether that process is running cannot be checked.
+ *
+ * The lock must be released with Release, which should also be added to the
+ * utility's SignalCleanup so that the lock is released if it is interrupted.
+ */
+func Acquire(name string, options Options) (*Lock, error) {
+	if name == "" || strings.ContainsRune(name, '/') {
+		return nil, errors.Errorf("Invalid lock name %q", name)
+	}
+	path := LockPath(name, options)
+	hostname, err := operating.System.Hostname()
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to determine hostname for lock file")
+	}
+	pollInterval := options.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = DEFAULT_POLL_INTERVAL
+	}
+
+	start := operating.System.MonotonicNow()
+	for {
+		holder := Holder{
+			Pid:         operating.System.Getpid(),
+			Hostname:    hostname,
+			Started:     operating.System.Now(),
+			Description: options.Description,
+		}
+		created, err := createLockFile(path, holder)
+		if err != nil {
+			return nil, err
+		} else if created {
+			return &Lock{Path: path, Holder: holder}, nil
+		}
+
+		current, stale, err := checkLockFile(path, hostname)
+		if err != nil {
+			return nil, err
+		} else if stale {
+			if err := removeStaleLockFile(path, hostname); err != nil {
+				return nil, err
+			}
+			continue
+		} else if current == nil {
+			// The holder released the lock between our attempt to create it and our attempt to read it
+			continue
+		}
+
+		elapsed := operating.Since(start)
+		if options.Timeout <= 0 {
+			return nil, gperror.New(LOCK_HELD, "Unable to acquire lock %s: it is held by %s; if that process is no longer running, remove %s", name, current, path)
+		} else if elapsed >= options.Timeout {
+			return nil, gperror.New(LOCK_TIMEOUT, "Timed out after %s waiting for lock %s: it is held by %s; if that process is no longer running, remove %s", options.Timeout, name, current, path)
+		}
+		gplog.Verbose("Waiting for lock %s, held by %s", name, current)
+		operating.System.Sleep(min(pollInterval, options.Timeout-elapsed))
+	}
+}
+
+func MustAcquire(name string, options Options) *Lock {
+	lock, err := Acquire(name, options)
+	gplog.FatalOnError(err)
+	return lock
+}
+
+// createLockFile returns false if the lock file already exists
+func createLockFile(path string, holder Holder) (bool, error) {
+	lockFile, err := iohelper.CreateFileExclusive(path, iohelper.OpenRetryOptions{})
+	if err != nil {
+		if gpErr, ok := gperror.Find(err); ok && gpErr.GetCode() == iohelper.FILE_ALREADY_EXISTS {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "Unable to create lock file %s", path)
+	}
+	_, err = lockFile.Write([]byte(holder.contents()))
+	closeErr := lockFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = operating.System.Remove(path)
+		return false, errors.Wrapf(err, "Unable to write lock file %s", path)
+	}
+	return true, nil
+}
+
+/*
+ * checkLockFile returns the process holding an existing lock file and whether
+ * the lock is stale.  A nil Holder that is not stale means the file no longer
+ * exists, a nil Holder that is stale means the file was never completed, and a
+ * Holder with a Pid of 0 means the file is still being written.
+ */
+func checkLockFile(path string, hostname string) (*Holder, bool, error) {
+	contents, err := operating.System.ReadFile(path)
+	if operating.System.IsNotExist(err) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, errors.Wrapf(err, "Unable to read lock file %s", path)
+	}
+	holder, err := parseHolder(string(contents))
+	if err != nil {
+		info, statErr := operating.System.Stat(path)
+		if operating.System.IsNotExist(statErr) {
+			return nil, false, nil
+		} else if statErr != nil {
+			return nil, false, errors.Wrapf(statErr, "Unable to read lock file %s", path)
+		}
+		if operating.System.Now().Sub(info.ModTime()) < IncompleteLockAge {
+			return &Holder{}, false, nil
+		}
+		return nil, true, nil
+	}
+	stale := holder.Hostname == hostname && !operating.IsProcessRunning(holder.Pid)
+	return &holder, stale, nil
+}
+
+/*
+ * removeStaleLockFile removes the lock file at path if it is still stale.
+ * Two processes may both find the same file stale, and if one removes it and
+ * creates its own lock file before the other removes the file, the second
+ * would remove a lock that is now held.  Each process therefore takes an
+ * exclusive flock on the lock directory, checks the file again, and only
+ * then removes it; creating the new lock file needs no such care, since
+ * only one process can create it.
+ */
+func removeStaleLockFile(path string, hostname string) error {
+	unlock, err := lockDirectory(filepath.Dir(path))
+	if err != nil {
+		return errors.Wrapf(err, "Unable to remove stale lock file %s", path)
+	}
+	defer unlock()
+	current, stale, err := checkLockFile(path, hostname)
+	if err != nil || !stale {
+		return err
+	}
+	gplog.Warn("Removing stale lock file %s: %s", path, staleReason(current))
+	if err := operating.System.Remove(path); err != nil && !operating.System.IsNotExist(err) {
+		return errors.Wrapf(err, "Unable to remove stale lock file %s", path)
+	}
+	return nil
+}
+
+// lockDirectory waits for an exclusive flock on dir and returns a function to release it
+func lockDirectory(dir string) (func(), error) {
+	dirFile, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Flock(int(dirFile.Fd()), unix.LOCK_EX); err != nil {
+		_ = dirFile.Close()
+		return nil, err
+	}
+	return func() {
+		_ = unix.Flock(int(dirFile.Fd()), unix.LOCK_UN)
+		_ = dirFile.Close()
+	}, nil
+}
+
+func staleReason(holder *Holder) string {
+	if holder == nil {
+		return "the lock file is incomplete"
+	}
+	return fmt.Sprintf("process %d is no longer running", holder.Pid)
+}
+
+/*
+ * Release removes the lock file if it is still held by this lock, and does
+ * nothing if the lock has already been released.  It returns an error, and
+ * leaves the file alone, if another process has taken over the lock, as
+ * happens if this process was wrongly judged to have stopped.
+ */
+func (lock *Lock) Release() error {
+	if lock == nil || lock.released {
+		return nil
+	}
+	contents, err := operating.System.ReadFile(lock.Path)
+	if operating.System.IsNotExist(err) {
+		lock.released = true
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "Unable to release lock file %s", lock.Path)
+	}
+	current, err := parseHolder(string(contents))
+	if err != nil || current.Pid != lock.Holder.Pid || current.Hostname != lock.Holder.Hostname {
+		return errors.Errorf("Unable to release lock file %s: it is no longer held by this process", lock.Path)
+	}
+	if err := operating.System.Remove(lock.Path); err != nil && !operating.System.IsNotExist(err) {
+		return errors.Wrapf(err, "Unable to release lock file %s", lock.Path)
+	}
+	lock.released = true
+	return nil
+}
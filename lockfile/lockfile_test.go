@@ -0,0 +1,263 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package lockfile_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/gperror"
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/lockfile"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("lockfile tests", func() {
+	var (
+		lockDir     string
+		options     lockfile.Options
+		hostname    string
+		savedLogger *gplog.GpLogger
+		testLogfile *gbytes.Buffer
+	)
+	writeLockFile := func(name string, contents string) string {
+		path := lockfile.LockPath(name, options)
+		Expect(os.WriteFile(path, []byte(contents), 0644)).To(Succeed())
+		return path
+	}
+
+	BeforeEach(func() {
+		savedLogger = gplog.GetLogger()
+		_, _, testLogfile = testhelper.SetupTestLogger()
+		var err error
+		lockDir, err = os.MkdirTemp("", "lockfile")
+		Expect(err).ToNot(HaveOccurred())
+		options = lockfile.Options{Dir: lockDir, Description: "gpbackup of database sales"}
+		hostname, err = os.Hostname()
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		gplog.SetLogger(savedLogger)
+		operating.System = operating.InitializeSystemFunctions()
+		os.RemoveAll(lockDir)
+	})
+
+	Describe("LockPath", func() {
+		It("uses the default directory if none is given", func() {
+			Expect(lockfile.LockPath("gpbackup_sales", lockfile.Options{})).To(Equal("/tmp/gpbackup_sales.lck"))
+		})
+	})
+	Describe("Acquire", func() {
+		It("creates a lock file describing this process", func() {
+			lock, err := lockfile.Acquire("gpbackup_sales", options)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lock.Path).To(Equal(filepath.Join(lockDir, "gpbackup_sales.lck")))
+
+			holder, err := lockfile.ReadHolder(lock.Path)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(holder.Pid).To(Equal(os.Getpid()))
+			Expect(holder.Hostname).To(Equal(hostname))
+			Expect(holder.Description).To(Equal("gpbackup of database sales"))
+			Expect(holder.Started.Equal(lock.Holder.Started.Truncate(time.Second))).To(BeTrue())
+
+			pid, err := operating.ReadPidfile(lock.Path)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pid).To(Equal(os.Getpid()))
+		})
+		It("fails with LOCK_HELD if a running process holds the lock", func() {
+			_, err := lockfile.Acquire("gpbackup_sales", options)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = lockfile.Acquire("gpbackup_sales", options)
+			Expect(err).To(gperror.MatchCode(lockfile.LOCK_HELD))
+			Expect(err.Error()).To(ContainSubstring(fmt.Sprintf("Unable to acquire lock gpbackup_sales: it is held by gpbackup of database sales (process %d on %s) since ", os.Getpid(), hostname)))
+			Expect(err.Error()).To(HaveSuffix("if that process is no longer running, remove " + filepath.Join(lockDir, "gpbackup_sales.lck")))
+		})
+		It("does not block locks with other names", func() {
+			_, err := lockfile.Acquire("gpbackup_sales", options)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = lockfile.Acquire("gpbackup_finance", options)
+			Expect(err).ToNot(HaveOccurred())
+		})
+		It("removes a stale lock left by a process on this host that is no longer running", func() {
+			path := writeLockFile("gpbackup_sales", fmt.Sprintf("99999\n%s\n2017-01-01T01:01:01Z\nold backup\n", hostname))
+			operating.System.Kill = func(pid int, sig syscall.Signal) error {
+				if pid == 99999 {
+					return syscall.ESRCH
+				}
+				return nil
+			}
+
+			lock, err := lockfile.Acquire("gpbackup_sales", options)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(testLogfile).To(gbytes.Say(fmt.Sprintf(`\[WARNING\]:-Removing stale lock file %s: process 99999 is no longer running`, path)))
+			holder, err := lockfile.ReadHolder(lock.Path)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(holder.Pid).To(Equal(os.Getpid()))
+		})
+		It("does not remove a lock taken over by another process after both found it stale", func() {
+			path := writeLockFile("gpbackup_sales", fmt.Sprintf("99999\n%s\n2017-01-01T01:01:01Z\nold backup\n", hostname))
+			staleContents, err := os.ReadFile(path)
+			Expect(err).ToNot(HaveOccurred())
+			operating.System.Kill = func(pid int, sig syscall.Signal) error {
+				if pid == 99999 {
+					return syscall.ESRCH
+				}
+				return nil
+			}
+			// The first read of the lock file, by the second acquirer, sees the stale lock but does not return until the first acquirer has taken it over
+			readStale, resume := make(chan struct{}), make(chan struct{})
+			var reads atomic.Int32
+			operating.System.ReadFile = func(filename string) ([]byte, error) {
+				if filename == path && reads.Add(1) == 1 {
+					close(readStale)
+					<-resume
+					return staleContents, nil
+				}
+				return os.ReadFile(filename)
+			}
+
+			secondOptions := options
+			secondOptions.Description = "second acquirer"
+			secondErr := make(chan error, 1)
+			go func() {
+				defer GinkgoRecover()
+				_, err := lockfile.Acquire("gpbackup_sales", secondOptions)
+				secondErr <- err
+			}()
+			Eventually(readStale).Should(BeClosed())
+			firstLock, err := lockfile.Acquire("gpbackup_sales", options)
+			Expect(err).ToNot(HaveOccurred())
+			close(resume)
+
+			Eventually(secondErr).Should(Receive(gperror.MatchCode(lockfile.LOCK_HELD)))
+			holder, err := lockfile.ReadHolder(path)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(holder.Description).To(Equal(firstLock.Holder.Description))
+		})
+		It("does not remove a lock held by another host", func() {
+			writeLockFile("gpbackup_sales", "99999\nsdw1\n2017-01-01T01:01:01Z\n\n")
+			operating.System.Kill = func(pid int, sig syscall.Signal) error { return syscall.ESRCH }
+
+			_, err := lockfile.Acquire("gpbackup_sales", options)
+			Expect(err).To(gperror.MatchCode(lockfile.LOCK_HELD))
+			Expect(err.Error()).To(ContainSubstring("it is held by process 99999 on sdw1 since 2017-01-01 01:01:01"))
+		})
+		It("treats a recently created incomplete lock file as held", func() {
+			writeLockFile("gpbackup_sales", "12345\n")
+
+			_, err := lockfile.Acquire("gpbackup_sales", options)
+			Expect(err).To(gperror.MatchCode(lockfile.LOCK_HELD))
+			Expect(err.Error()).To(ContainSubstring("it is held by an unknown process"))
+		})
+		It("removes an incomplete lock file once it is old enough", func() {
+			path := writeLockFile("gpbackup_sales", "12345\n")
+			oldTime := time.Now().Add(-2 * lockfile.IncompleteLockAge)
+			Expect(os.Chtimes(path, oldTime, oldTime)).To(Succeed())
+
+			_, err := lockfile.Acquire("gpbackup_sales", options)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(testLogfile).To(gbytes.Say("the lock file is incomplete"))
+		})
+		It("rejects names that are not file names", func() {
+			_, err := lockfile.Acquire("../gpbackup", options)
+			Expect(err).To(MatchError(`Invalid lock name "../gpbackup"`))
+		})
+		It("returns an error if the lock directory does not exist", func() {
+			options.Dir = filepath.Join(lockDir, "nonexistent")
+			_, err := lockfile.Acquire("gpbackup_sales", options)
+			Expect(err).To(MatchError(ContainSubstring("Unable to create lock file")))
+		})
+		Context("with a timeout", func() {
+			var clock *testhelper.FakeClock
+
+			BeforeEach(func() {
+				clock = testhelper.NewFakeClock(time.Now())
+				clock.Install()
+				options.Timeout = 5 * time.Second
+				options.PollInterval = 2 * time.Second
+			})
+
+			It("waits for the lock to be released", func() {
+				first, err := lockfile.Acquire("gpbackup_sales", options)
+				Expect(err).ToNot(HaveOccurred())
+
+				acquired := make(chan error, 1)
+				go func() {
+					defer GinkgoRecover()
+					_, err := lockfile.Acquire("gpbackup_sales", options)
+					acquired <- err
+				}()
+				clock.BlockUntil(1)
+				Expect(first.Release()).To(Succeed())
+				clock.Advance(2 * time.Second)
+				Eventually(acquired).Should(Receive(BeNil()))
+			})
+			It("fails with LOCK_TIMEOUT if the lock is not released in time", func() {
+				_, err := lockfile.Acquire("gpbackup_sales", options)
+				Expect(err).ToNot(HaveOccurred())
+
+				acquired := make(chan error, 1)
+				go func() {
+					defer GinkgoRecover()
+					_, err := lockfile.Acquire("gpbackup_sales", options)
+					acquired <- err
+				}()
+				for i := 0; i < 3; i++ {
+					clock.BlockUntil(1)
+					Consistently(acquired, 10*time.Millisecond).ShouldNot(Receive())
+					clock.Advance(2 * time.Second)
+				}
+				var acquireErr error
+				Eventually(acquired).Should(Receive(&acquireErr))
+				Expect(acquireErr).To(gperror.MatchCode(lockfile.LOCK_TIMEOUT))
+				Expect(acquireErr.Error()).To(HavePrefix("ERROR[5102] Timed out after 5s waiting for lock gpbackup_sales: it is held by gpbackup of database sales"))
+			})
+		})
+	})
+	Describe("Release", func() {
+		It("removes the lock file so that the lock can be taken again", func() {
+			lock, err := lockfile.Acquire("gpbackup_sales", options)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lock.Release()).To(Succeed())
+			Expect(lock.Path).ToNot(BeAnExistingFile())
+			Expect(lock.Release()).To(Succeed())
+
+			_, err = lockfile.Acquire("gpbackup_sales", options)
+			Expect(err).ToNot(HaveOccurred())
+		})
+		It("leaves the lock file alone if another process has taken over the lock", func() {
+			lock, err := lockfile.Acquire("gpbackup_sales", options)
+			Expect(err).ToNot(HaveOccurred())
+			writeLockFile("gpbackup_sales", fmt.Sprintf("99999\n%s\n2017-01-01T01:01:01Z\n\n", hostname))
+
+			err = lock.Release()
+			Expect(err).To(MatchError(fmt.Sprintf("Unable to release lock file %s: it is no longer held by this process", lock.Path)))
+			Expect(lock.Path).To(BeAnExistingFile())
+		})
+	})
+})
@@ -0,0 +1,188 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package metrics
+
+/*
+ * This file contains the counters, gauges, and timers that this library and
+ * the utilities built on it record operation counts and latencies with, and
+ * the Exporter interface through which they are published.  Until a utility
+ * installs an exporter with SetExporter, recorded values are discarded, so
+ * instrumented code costs next to nothing in utilities that do not export
+ * metrics.
+ */
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/operating"
+)
+
+// Labels distinguish the values of one metric, e.g. by operation or result
+type Labels map[string]string
+
+type Kind string
+
+const (
+	COUNTER Kind = "counter"
+	GAUGE   Kind = "gauge"
+	TIMER   Kind = "timer"
+)
+
+// A Desc describes a metric to exporters; see NewCounter for naming
+type Desc struct {
+	Name string
+	Help string
+	Kind Kind
+}
+
+/*
+ * An Exporter publishes recorded values, either by aggregating them to be
+ * scraped, as PrometheusExporter does, or by sending each one somewhere, as
+ * StatsdExporter does.  Its methods may be called by many goroutines at once,
+ * and should not block or log, since they are called while operations are
+ * in progress, including from within gplog.
+ */
+type Exporter interface {
+	AddCounter(desc *Desc, labels Labels, delta float64)
+	SetGauge(desc *Desc, labels Labels, value float64)
+	ObserveTimer(desc *Desc, labels Labels, duration time.Duration)
+}
+
+type noopExporter struct{}
+
+func (noopExporter) AddCounter(*Desc, Labels, float64)         {}
+func (noopExporter) SetGauge(*Desc, Labels, float64)           {}
+func (noopExporter) ObserveTimer(*Desc, Labels, time.Duration) {}
+
+var (
+	exporter      Exporter = noopExporter{}
+	exporterMutex sync.RWMutex
+)
+
+// SetExporter sends every value recorded from now on to newExporter, or discards them if it is nil
+func SetExporter(newExporter Exporter) {
+	if newExporter == nil {
+		newExporter = noopExporter{}
+	}
+	exporterMutex.Lock()
+	defer exporterMutex.Unlock()
+	exporter = newExporter
+}
+
+// GetExporter returns the current exporter, which discards values if none has been set
+func GetExporter() Exporter {
+	exporterMutex.RLock()
+	defer exporterMutex.RUnlock()
+	return exporter
+}
+
+// Enabled returns whether an exporter has been set, for skipping work that only produces metrics
+func Enabled() bool {
+	_, isNoop := GetExporter().(noopExporter)
+	return !isNoop
+}
+
+var metricNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+func newDesc(name string, help string, kind Kind) *Desc {
+	if !metricNameRegex.MatchString(name) {
+		panic(fmt.Sprintf("Invalid metric name %q", name))
+	}
+	return &Desc{Name: name, Help: help, Kind: kind}
+}
+
+// A Counter counts events, such as statements run, and only increases
+type Counter struct {
+	desc *Desc
+}
+
+/*
+ * NewCounter returns a counter, usually assigned to a package variable.
+ * Metric names are prefixed with the package or utility recording them, use
+ * underscores between words, and end with the unit if there is one, e.g.
+ * "dbconn_statements" or "gpbackup_bytes_written"; NewCounter panics if name
+ * contains other characters.  Help is a sentence describing the metric.
+ */
+func NewCounter(name string, help string) *Counter {
+	return &Counter{desc: newDesc(name, help, COUNTER)}
+}
+
+func (counter *Counter) Inc(labels Labels) {
+	counter.Add(labels, 1)
+}
+
+func (counter *Counter) Add(labels Labels, delta float64) {
+	GetExporter().AddCounter(counter.desc, labels, delta)
+}
+
+// Desc returns the description of the counter, for passing values to an Exporter directly
+func (counter *Counter) Desc() *Desc {
+	return counter.desc
+}
+
+// A Gauge records a value that can go up or down, such as the number of open connections
+type Gauge struct {
+	desc *Desc
+}
+
+// NewGauge returns a gauge, named as described for NewCounter
+func NewGauge(name string, help string) *Gauge {
+	return &Gauge{desc: newDesc(name, help, GAUGE)}
+}
+
+func (gauge *Gauge) Set(labels Labels, value float64) {
+	GetExporter().SetGauge(gauge.desc, labels, value)
+}
+
+func (gauge *Gauge) Desc() *Desc {
+	return gauge.desc
+}
+
+/*
+ * A Timer records how long each of a kind of operation takes.  Its name
+ * should end with "_seconds", e.g. "dbconn_statement_duration_seconds",
+ * though exporters convert durations to whatever unit their format uses.
+ */
+type Timer struct {
+	desc *Desc
+}
+
+// NewTimer returns a timer, named as described for NewCounter
+func NewTimer(name string, help string) *Timer {
+	return &Timer{desc: newDesc(name, help, TIMER)}
+}
+
+func (timer *Timer) Observe(labels Labels, duration time.Duration) {
+	GetExporter().ObserveTimer(timer.desc, labels, duration)
+}
+
+/*
+ * Start starts timing an operation, and returns a function that records how
+ * long it took when called, e.g.
+ *
+ *   defer restoreTimer.Start(metrics.Labels{"phase": "data"})()
+ */
+func (timer *Timer) Start(labels Labels) func() {
+	start := operating.System.MonotonicNow()
+	return func() {
+		timer.Observe(labels, operating.Since(start))
+	}
+}
@@ -0,0 +1,76 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package metrics_test
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/metrics"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("metrics tests", func() {
+	var exporter *metrics.PrometheusExporter
+
+	currentMetrics := func() string {
+		var buffer bytes.Buffer
+		Expect(exporter.WriteMetrics(&buffer)).To(Succeed())
+		return buffer.String()
+	}
+
+	BeforeEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		exporter = metrics.NewPrometheusExporter()
+		metrics.SetExporter(exporter)
+	})
+	AfterEach(func() {
+		metrics.SetExporter(nil)
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("SetExporter", func() {
+		It("discards values when no exporter is set", func() {
+			metrics.SetExporter(nil)
+			Expect(metrics.Enabled()).To(BeFalse())
+			metrics.NewCounter("test_discarded", "").Inc(nil)
+			metrics.SetExporter(exporter)
+			Expect(metrics.Enabled()).To(BeTrue())
+			Expect(currentMetrics()).To(BeEmpty())
+		})
+	})
+	Describe("NewCounter", func() {
+		It("panics on an invalid name", func() {
+			Expect(func() { metrics.NewCounter("test-counter", "") }).To(PanicWith(`Invalid metric name "test-counter"`))
+		})
+	})
+	Describe("Timer.Start", func() {
+		It("records the time until the returned function is called", func() {
+			clock := testhelper.NewFakeClock(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+			clock.Install()
+			stop := metrics.NewTimer("test_phase_seconds", "").Start(metrics.Labels{"phase": "data"})
+			clock.Advance(1500 * time.Millisecond)
+			stop()
+			Expect(currentMetrics()).To(ContainSubstring("test_phase_seconds_count{phase=\"data\"} 1\ntest_phase_seconds_sum{phase=\"data\"} 1.5\n"))
+		})
+	})
+})
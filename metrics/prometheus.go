@@ -0,0 +1,181 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package metrics
+
+/*
+ * This file contains an exporter that aggregates recorded values in memory
+ * and serves them in the OpenMetrics text format that Prometheus scrapes.
+ */
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const OPENMETRICS_CONTENT_TYPE = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// A sample holds the aggregated value of one metric for one set of labels
+type sample struct {
+	labels string
+	value  float64
+	count  int64
+}
+
+type family struct {
+	desc    *Desc
+	samples map[string]*sample
+}
+
+/*
+ * A PrometheusExporter keeps the total of each counter, the last value of
+ * each gauge, and the count and total duration of each timer, which it
+ * writes as a summary without quantiles.  A utility serves its metrics by
+ * adding Handler to an HTTP server; gplog.StartMetricsServer only serves
+ * gplog's own metrics.
+ */
+type PrometheusExporter struct {
+	families map[string]*family
+	mutex    sync.Mutex
+}
+
+var _ Exporter = &PrometheusExporter{}
+
+func NewPrometheusExporter() *PrometheusExporter {
+	return &PrometheusExporter{families: make(map[string]*family)}
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels formats labels as they appear in a sample, sorted by name so that equal labels are formatted equally
+func formatLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, name, labelValueReplacer.Replace(labels[name]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// sample returns the sample to update for desc and labels, which must be called with the mutex held
+func (exporter *PrometheusExporter) sample(desc *Desc, labels Labels) *sample {
+	metricFamily, ok := exporter.families[desc.Name]
+	if !ok {
+		metricFamily = &family{desc: desc, samples: make(map[string]*sample)}
+		exporter.families[desc.Name] = metricFamily
+	}
+	formatted := formatLabels(labels)
+	metricSample, ok := metricFamily.samples[formatted]
+	if !ok {
+		metricSample = &sample{labels: formatted}
+		metricFamily.samples[formatted] = metricSample
+	}
+	return metricSample
+}
+
+func (exporter *PrometheusExporter) AddCounter(desc *Desc, labels Labels, delta float64) {
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	exporter.sample(desc, labels).value += delta
+}
+
+func (exporter *PrometheusExporter) SetGauge(desc *Desc, labels Labels, value float64) {
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	exporter.sample(desc, labels).value = value
+}
+
+func (exporter *PrometheusExporter) ObserveTimer(desc *Desc, labels Labels, duration time.Duration) {
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	metricSample := exporter.sample(desc, labels)
+	metricSample.value += duration.Seconds()
+	metricSample.count++
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+/*
+ * WriteMetrics writes every metric recorded so far to writer in OpenMetrics
+ * text format, sorted by name and labels, without the final "# EOF" line so
+ * that the output can be combined with other metrics.
+ */
+func (exporter *PrometheusExporter) WriteMetrics(writer io.Writer) error {
+	exporter.mutex.Lock()
+	names := make([]string, 0, len(exporter.families))
+	for name := range exporter.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var builder strings.Builder
+	for _, name := range names {
+		metricFamily := exporter.families[name]
+		kind := string(metricFamily.desc.Kind)
+		if metricFamily.desc.Kind == TIMER {
+			kind = "summary"
+		}
+		fmt.Fprintf(&builder, "# TYPE %s %s\n", name, kind)
+		if metricFamily.desc.Help != "" {
+			fmt.Fprintf(&builder, "# HELP %s %s\n", name, metricFamily.desc.Help)
+		}
+		labels := make([]string, 0, len(metricFamily.samples))
+		for formatted := range metricFamily.samples {
+			labels = append(labels, formatted)
+		}
+		sort.Strings(labels)
+		for _, formatted := range labels {
+			metricSample := metricFamily.samples[formatted]
+			switch metricFamily.desc.Kind {
+			case COUNTER:
+				fmt.Fprintf(&builder, "%s_total%s %s\n", name, formatted, formatValue(metricSample.value))
+			case GAUGE:
+				fmt.Fprintf(&builder, "%s%s %s\n", name, formatted, formatValue(metricSample.value))
+			case TIMER:
+				fmt.Fprintf(&builder, "%s_count%s %d\n", name, formatted, metricSample.count)
+				fmt.Fprintf(&builder, "%s_sum%s %s\n", name, formatted, formatValue(metricSample.value))
+			}
+		}
+	}
+	exporter.mutex.Unlock()
+	_, err := io.WriteString(writer, builder.String())
+	return err
+}
+
+// Handler returns an http.Handler that serves the output of WriteMetrics
+func (exporter *PrometheusExporter) Handler() http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.Header().Set("Content-Type", OPENMETRICS_CONTENT_TYPE)
+		if err := exporter.WriteMetrics(writer); err == nil {
+			_, _ = io.WriteString(writer, "# EOF\n")
+		}
+	})
+}
@@ -0,0 +1,103 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package metrics_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/metrics"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("metrics/prometheus tests", func() {
+	var exporter *metrics.PrometheusExporter
+
+	currentMetrics := func() string {
+		var buffer bytes.Buffer
+		Expect(exporter.WriteMetrics(&buffer)).To(Succeed())
+		return buffer.String()
+	}
+
+	BeforeEach(func() {
+		exporter = metrics.NewPrometheusExporter()
+		metrics.SetExporter(exporter)
+	})
+	AfterEach(func() {
+		metrics.SetExporter(nil)
+	})
+
+	It("writes each kind of metric, sorted by name and labels", func() {
+		statements := metrics.NewCounter("test_statements", "Statements run.")
+		connections := metrics.NewGauge("test_connections", "Open connections.")
+		duration := metrics.NewTimer("test_duration_seconds", "")
+		statements.Inc(metrics.Labels{"command": "select"})
+		statements.Add(metrics.Labels{"command": "select"}, 2)
+		statements.Inc(metrics.Labels{"command": "insert"})
+		connections.Set(nil, 4)
+		connections.Set(nil, 3)
+		duration.Observe(nil, 250*time.Millisecond)
+		duration.Observe(nil, time.Second)
+		Expect(currentMetrics()).To(Equal(`# TYPE test_connections gauge
+# HELP test_connections Open connections.
+test_connections 3
+# TYPE test_duration_seconds summary
+test_duration_seconds_count 2
+test_duration_seconds_sum 1.25
+# TYPE test_statements counter
+# HELP test_statements Statements run.
+test_statements_total{command="insert"} 1
+test_statements_total{command="select"} 3
+`))
+	})
+	It("sorts and escapes labels", func() {
+		metrics.NewCounter("test_labels", "").Inc(metrics.Labels{"table": "public.\"foo\"\n", "action": "copy"})
+		Expect(currentMetrics()).To(ContainSubstring(`test_labels_total{action="copy",table="public.\"foo\"\n"} 1`))
+	})
+	It("can be recorded to by several goroutines at once", func() {
+		counter := metrics.NewCounter("test_concurrent", "")
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				counter.Inc(nil)
+			}()
+		}
+		wg.Wait()
+		Expect(currentMetrics()).To(ContainSubstring("test_concurrent_total 50\n"))
+	})
+	It("serves the metrics over HTTP", func() {
+		metrics.NewCounter("test_served", "").Inc(nil)
+		server := httptest.NewServer(exporter.Handler())
+		defer server.Close()
+		response, err := http.Get(server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		defer response.Body.Close()
+		body, err := io.ReadAll(response.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(response.Header.Get("Content-Type")).To(Equal(metrics.OPENMETRICS_CONTENT_TYPE))
+		Expect(string(body)).To(Equal("# TYPE test_served counter\ntest_served_total 1\n# EOF\n"))
+	})
+})
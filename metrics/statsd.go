@@ -0,0 +1,135 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package metrics
+
+/*
+ * This file contains an exporter that sends each recorded value to a statsd
+ * server over UDP as it is recorded.
+ */
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const DEFAULT_STATSD_ADDRESS = "127.0.0.1:8125"
+
+/*
+ * StatsdOptions controls how values are sent to statsd.
+ *
+ * Address: The host:port of the statsd server; defaults to
+ *          DEFAULT_STATSD_ADDRESS.
+ * Prefix:  Added to the front of each metric name with a ".", e.g. the name
+ *          of the utility.
+ * Tags:    Send labels as DogStatsD tags, as in "name:1|c|#result:success".
+ *          Otherwise each label value, sorted by label name, is added to the
+ *          metric name, as in "name.success:1|c", since plain statsd has no
+ *          labels.
+ */
+type StatsdOptions struct {
+	Address string
+	Prefix  string
+	Tags    bool
+}
+
+/*
+ * A StatsdExporter sends counters with the "c" type, gauges with "g", and
+ * timers in milliseconds with "ms".  Values are sent without waiting for or
+ * checking a reply, and errors sending them are ignored, so that a missing
+ * statsd server never slows down or fails the utility.
+ */
+type StatsdExporter struct {
+	options StatsdOptions
+	conn    net.Conn
+}
+
+var _ Exporter = &StatsdExporter{}
+
+func NewStatsdExporter(options StatsdOptions) (*StatsdExporter, error) {
+	if options.Address == "" {
+		options.Address = DEFAULT_STATSD_ADDRESS
+	}
+	conn, err := net.Dial("udp", options.Address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to send metrics to statsd at %s", options.Address)
+	}
+	return &StatsdExporter{options: options, conn: conn}, nil
+}
+
+var statsdInvalidRegex = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// sanitize replaces characters that statsd treats specially, such as ":" and "|"
+func sanitize(value string) string {
+	return statsdInvalidRegex.ReplaceAllString(value, "_")
+}
+
+func (exporter *StatsdExporter) send(desc *Desc, labels Labels, values ...string) {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	name := desc.Name
+	if exporter.options.Prefix != "" {
+		name = sanitize(exporter.options.Prefix) + "." + name
+	}
+	suffix := ""
+	if exporter.options.Tags && len(names) > 0 {
+		tags := make([]string, len(names))
+		for i, label := range names {
+			tags[i] = sanitize(label) + ":" + sanitize(labels[label])
+		}
+		suffix = "|#" + strings.Join(tags, ",")
+	} else {
+		for _, label := range names {
+			name += "." + sanitize(labels[label])
+		}
+	}
+	lines := make([]string, len(values))
+	for i, value := range values {
+		lines[i] = name + ":" + value + suffix
+	}
+	_, _ = exporter.conn.Write([]byte(strings.Join(lines, "\n")))
+}
+
+func (exporter *StatsdExporter) AddCounter(desc *Desc, labels Labels, delta float64) {
+	exporter.send(desc, labels, formatValue(delta)+"|c")
+}
+
+// SetGauge sends a negative value as 0 followed by the value, since statsd treats a leading "-" as a decrement
+func (exporter *StatsdExporter) SetGauge(desc *Desc, labels Labels, value float64) {
+	if value < 0 {
+		exporter.send(desc, labels, "0|g", formatValue(value)+"|g")
+		return
+	}
+	exporter.send(desc, labels, formatValue(value)+"|g")
+}
+
+func (exporter *StatsdExporter) ObserveTimer(desc *Desc, labels Labels, duration time.Duration) {
+	exporter.send(desc, labels, fmt.Sprintf("%s|ms", formatValue(float64(duration)/float64(time.Millisecond))))
+}
+
+func (exporter *StatsdExporter) Close() error {
+	return exporter.conn.Close()
+}
@@ -0,0 +1,84 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package metrics_test
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/metrics"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("metrics/statsd tests", func() {
+	var (
+		listener net.PacketConn
+		statsd   *metrics.StatsdExporter
+	)
+	received := func() string {
+		buffer := make([]byte, 1024)
+		Expect(listener.SetReadDeadline(time.Now().Add(5 * time.Second))).To(Succeed())
+		n, _, err := listener.ReadFrom(buffer)
+		Expect(err).ToNot(HaveOccurred())
+		return string(buffer[:n])
+	}
+	startStatsd := func(options metrics.StatsdOptions) {
+		options.Address = listener.LocalAddr().String()
+		var err error
+		statsd, err = metrics.NewStatsdExporter(options)
+		Expect(err).ToNot(HaveOccurred())
+		metrics.SetExporter(statsd)
+	}
+
+	BeforeEach(func() {
+		var err error
+		listener, err = net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		metrics.SetExporter(nil)
+		if statsd != nil {
+			Expect(statsd.Close()).To(Succeed())
+			statsd = nil
+		}
+		Expect(listener.Close()).To(Succeed())
+	})
+
+	It("sends each kind of metric with label values in the name", func() {
+		startStatsd(metrics.StatsdOptions{Prefix: "gpbackup"})
+		metrics.NewCounter("test_statements", "").Add(metrics.Labels{"command": "select", "result": "ok"}, 2)
+		Expect(received()).To(Equal("gpbackup.test_statements.select.ok:2|c"))
+		metrics.NewGauge("test_connections", "").Set(nil, 3)
+		Expect(received()).To(Equal("gpbackup.test_connections:3|g"))
+		metrics.NewTimer("test_duration_seconds", "").Observe(nil, 1500*time.Microsecond)
+		Expect(received()).To(Equal("gpbackup.test_duration_seconds:1.5|ms"))
+	})
+	It("sends labels as tags if asked to, replacing characters statsd treats specially", func() {
+		startStatsd(metrics.StatsdOptions{Tags: true})
+		metrics.NewCounter("test_tables", "").Inc(metrics.Labels{"table": "public.foo|bar:baz"})
+		Expect(received()).To(Equal("test_tables:1|c|#table:public.foo_bar_baz"))
+	})
+	It("resets a gauge to zero before setting a negative value", func() {
+		startStatsd(metrics.StatsdOptions{})
+		metrics.NewGauge("test_offset", "").Set(nil, -2.5)
+		Expect(strings.Split(received(), "\n")).To(Equal([]string{"test_offset:0|g", "test_offset:-2.5|g"}))
+	})
+})
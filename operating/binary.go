@@ -0,0 +1,98 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package operating
+
+/*
+ * This file contains functions for locating executables and determining
+ * their versions, so that utilities checking for matching binaries across
+ * hosts do not need to build their own which/awk pipelines.
+ */
+
+import (
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+func CommandOutput(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).CombinedOutput()
+}
+
+/*
+ * FindExecutable returns the path to the named executable, checking each of
+ * extraPaths in order before falling back to the directories in PATH.  If
+ * name contains a path separator, it is checked directly.
+ */
+func FindExecutable(name string, extraPaths []string) (string, error) {
+	if strings.Contains(name, string(filepath.Separator)) {
+		if isExecutable(name) {
+			return name, nil
+		}
+		return "", errors.Errorf("%s is not an executable file", name)
+	}
+	for _, dir := range extraPaths {
+		candidate := filepath.Join(dir, name)
+		if isExecutable(candidate) {
+			return candidate, nil
+		}
+	}
+	path, err := System.LookPath(name)
+	if err != nil {
+		return "", errors.Errorf("Could not find executable %s in %s or PATH", name, strings.Join(extraPaths, ", "))
+	}
+	return path, nil
+}
+
+func isExecutable(path string) bool {
+	info, err := System.Stat(path)
+	if err != nil {
+		return false
+	}
+	return info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0
+}
+
+/*
+ * ProbeVersion runs binary with versionFlag and matches versionRegex against
+ * the output.  If the regex contains a capture group, the first group is
+ * returned; otherwise the whole match is returned.
+ */
+func ProbeVersion(binary string, versionFlag string, versionRegex string) (string, error) {
+	pattern, err := regexp.Compile(versionRegex)
+	if err != nil {
+		return "", errors.Wrapf(err, "Invalid version regex %s", versionRegex)
+	}
+	args := []string{}
+	if versionFlag != "" {
+		args = append(args, versionFlag)
+	}
+	output, err := System.CommandOutput(binary, args...)
+	if err != nil {
+		return "", errors.Wrapf(err, "Could not determine version of %s: %s", binary, strings.TrimSpace(string(output)))
+	}
+	match := pattern.FindStringSubmatch(string(output))
+	if match == nil {
+		return "", errors.Errorf("Could not find version matching %s in output of %s: %s", versionRegex, binary, strings.TrimSpace(string(output)))
+	}
+	if len(match) > 1 {
+		return match[1], nil
+	}
+	return match[0], nil
+}
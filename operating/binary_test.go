@@ -0,0 +1,126 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package operating_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/apache/cloudberry-go-libs/operating"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("operating/binary tests", func() {
+	var binDir string
+
+	BeforeEach(func() {
+		binDir = GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(binDir, "gpbackup"), []byte("#!/bin/sh\n"), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(binDir, "gprestore"), []byte("#!/bin/sh\n"), 0644)).To(Succeed())
+		operating.System.LookPath = func(file string) (string, error) {
+			return "", errors.New("executable file not found in $PATH")
+		}
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("FindExecutable", func() {
+		It("finds an executable in the extra paths before checking PATH", func() {
+			operating.System.LookPath = func(file string) (string, error) { return "/usr/bin/" + file, nil }
+			path, err := operating.FindExecutable("gpbackup", []string{"/nonexistent", binDir})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(path).To(Equal(filepath.Join(binDir, "gpbackup")))
+		})
+		It("falls back to PATH", func() {
+			operating.System.LookPath = func(file string) (string, error) { return "/usr/bin/" + file, nil }
+			path, err := operating.FindExecutable("pg_dump", []string{binDir})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(path).To(Equal("/usr/bin/pg_dump"))
+		})
+		It("skips files in the extra paths that are not executable", func() {
+			operating.System.LookPath = func(file string) (string, error) { return "/usr/bin/" + file, nil }
+			path, err := operating.FindExecutable("gprestore", []string{binDir})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(path).To(Equal("/usr/bin/gprestore"))
+		})
+		It("returns an error if the executable is not found", func() {
+			_, err := operating.FindExecutable("gpbackup", []string{"/opt/a", "/opt/b"})
+			Expect(err).To(MatchError("Could not find executable gpbackup in /opt/a, /opt/b or PATH"))
+		})
+		It("checks a path containing a separator directly", func() {
+			path, err := operating.FindExecutable(filepath.Join(binDir, "gpbackup"), nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(path).To(Equal(filepath.Join(binDir, "gpbackup")))
+		})
+		It("returns an error for a path that is not executable", func() {
+			_, err := operating.FindExecutable(filepath.Join(binDir, "gprestore"), nil)
+			Expect(err).To(MatchError(filepath.Join(binDir, "gprestore") + " is not an executable file"))
+			_, err = operating.FindExecutable(binDir+"/", nil)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Describe("ProbeVersion", func() {
+		var commandArgs []string
+
+		BeforeEach(func() {
+			commandArgs = nil
+		})
+		useOutput := func(output string, err error) {
+			operating.System.CommandOutput = func(name string, args ...string) ([]byte, error) {
+				commandArgs = append([]string{name}, args...)
+				return []byte(output), err
+			}
+		}
+
+		It("returns the first capture group of the version regex", func() {
+			useOutput("postgres (Apache Cloudberry) 14.4\n", nil)
+			version, err := operating.ProbeVersion("/usr/local/bin/postgres", "--version", `\) (\d+\.\d+)`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(version).To(Equal("14.4"))
+			Expect(commandArgs).To(Equal([]string{"/usr/local/bin/postgres", "--version"}))
+		})
+		It("returns the whole match if the regex has no capture group", func() {
+			useOutput("gpbackup version 1.30.5\n", nil)
+			version, err := operating.ProbeVersion("gpbackup", "", `\d+\.\d+\.\d+`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(version).To(Equal("1.30.5"))
+			Expect(commandArgs).To(Equal([]string{"gpbackup"}))
+		})
+		It("returns an error if the output does not contain a version", func() {
+			useOutput("usage: gpbackup [flags]\n", nil)
+			_, err := operating.ProbeVersion("gpbackup", "--version", `\d+\.\d+\.\d+`)
+			Expect(err).To(MatchError(`Could not find version matching \d+\.\d+\.\d+ in output of gpbackup: usage: gpbackup [flags]`))
+		})
+		It("returns an error if the command fails", func() {
+			useOutput("gpbackup: unknown flag --version\n", errors.New("exit status 2"))
+			_, err := operating.ProbeVersion("gpbackup", "--version", `\d+`)
+			Expect(err).To(MatchError("Could not determine version of gpbackup: gpbackup: unknown flag --version: exit status 2"))
+		})
+		It("returns an error for an invalid regex without running the command", func() {
+			useOutput("1.0", nil)
+			_, err := operating.ProbeVersion("gpbackup", "--version", `(\d+`)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix(`Invalid version regex (\d+`))
+			Expect(commandArgs).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,290 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package operating
+
+/*
+ * This file contains functions for reading the CPU and memory limits that
+ * cgroups place on the current process, so that code choosing how many
+ * workers to run inside a container respects its quota instead of using
+ * the number of CPUs on the host.
+ *
+ * Both cgroup v1 and v2 are supported, assuming the standard mount point.
+ * Files are read through System so that they can be mocked.
+ */
+
+import (
+	"math"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+/*
+ * cgroup v1 reports "no limit" as the largest page-aligned int64 rather than
+ * as -1, so treat anything this large as unlimited.
+ */
+const cgroupUnlimitedMemory = int64(1) << 62
+
+/*
+ * CgroupLimits holds the limits on the current process.  Version is 0 if the
+ * process is not in a cgroup hierarchy that could be read, and CPUQuota and
+ * MemoryLimit are 0 if there is no limit.  CPUQuota may be fractional, e.g.
+ * 1.5 for a quota of 150ms of CPU time per 100ms period.
+ */
+type CgroupLimits struct {
+	Version     int
+	CPUQuota    float64
+	MemoryLimit int64
+}
+
+/*
+ * ReadCgroupLimits returns the tightest CPU and memory limits set on the
+ * process's cgroup or any of its ancestors.  Missing limit files are treated
+ * as no limit, since not every controller is enabled on every system.
+ */
+func ReadCgroupLimits() (CgroupLimits, error) {
+	contents, err := System.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		if System.IsNotExist(err) {
+			return CgroupLimits{}, nil
+		}
+		return CgroupLimits{}, errors.Wrap(err, "Unable to read cgroup membership")
+	}
+	memberships := parseCgroupMembership(string(contents))
+	if _, err = System.Stat(path.Join(cgroupRoot, "cgroup.controllers")); err == nil {
+		return readCgroupV2Limits(memberships[""].path)
+	}
+	return readCgroupV1Limits(memberships)
+}
+
+/*
+ * A cgroupMembership is the cgroup the process belongs to for a controller,
+ * and for cgroup v1, the controller list naming the hierarchy holding it.
+ */
+type cgroupMembership struct {
+	path      string
+	hierarchy string
+}
+
+/*
+ * parseCgroupMembership maps each controller in /proc/self/cgroup to the
+ * cgroup the process belongs to.  Lines look like "4:cpu,cpuacct:/user.slice"
+ * for v1 and "0::/user.slice" for v2, which is stored under "".
+ */
+func parseCgroupMembership(contents string) map[string]cgroupMembership {
+	memberships := make(map[string]cgroupMembership)
+	for _, line := range strings.Split(strings.TrimSpace(contents), "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		for _, controller := range strings.Split(fields[1], ",") {
+			memberships[controller] = cgroupMembership{path: fields[2], hierarchy: fields[1]}
+		}
+	}
+	return memberships
+}
+
+/*
+ * cgroupAncestors returns dir and each of its parents under root, deepest
+ * first.  Inside a container with its own cgroup namespace the path is "/",
+ * and only the root is checked.
+ */
+func cgroupAncestors(root string, cgroupPath string) []string {
+	dirs := make([]string, 0)
+	for current := path.Clean("/" + cgroupPath); ; current = path.Dir(current) {
+		dirs = append(dirs, path.Join(root, current))
+		if current == "/" {
+			return dirs
+		}
+	}
+}
+
+func readCgroupFile(dir string, name string) (string, bool, error) {
+	contents, err := System.ReadFile(path.Join(dir, name))
+	if err != nil {
+		if System.IsNotExist(err) {
+			return "", false, nil
+		}
+		return "", false, errors.Wrapf(err, "Unable to read cgroup file %s", path.Join(dir, name))
+	}
+	return strings.TrimSpace(string(contents)), true, nil
+}
+
+func tighterCPUQuota(current float64, quota float64) float64 {
+	if quota > 0 && (current == 0 || quota < current) {
+		return quota
+	}
+	return current
+}
+
+func tighterMemoryLimit(current int64, limit int64) int64 {
+	if limit > 0 && limit < cgroupUnlimitedMemory && (current == 0 || limit < current) {
+		return limit
+	}
+	return current
+}
+
+func readCgroupV2Limits(cgroupPath string) (CgroupLimits, error) {
+	limits := CgroupLimits{Version: 2}
+	for _, dir := range cgroupAncestors(cgroupRoot, cgroupPath) {
+		// cpu.max is "<quota> <period>", with a quota of "max" for no limit
+		cpuMax, found, err := readCgroupFile(dir, "cpu.max")
+		if err != nil {
+			return CgroupLimits{}, err
+		}
+		if fields := strings.Fields(cpuMax); found && len(fields) == 2 && fields[0] != "max" {
+			quota, quotaErr := strconv.ParseFloat(fields[0], 64)
+			period, periodErr := strconv.ParseFloat(fields[1], 64)
+			if quotaErr != nil || periodErr != nil || period <= 0 {
+				return CgroupLimits{}, errors.Errorf("Invalid cgroup CPU limit %q in %s", cpuMax, dir)
+			}
+			limits.CPUQuota = tighterCPUQuota(limits.CPUQuota, quota/period)
+		}
+
+		memoryMax, found, err := readCgroupFile(dir, "memory.max")
+		if err != nil {
+			return CgroupLimits{}, err
+		}
+		if found && memoryMax != "max" {
+			limit, parseErr := strconv.ParseInt(memoryMax, 10, 64)
+			if parseErr != nil {
+				return CgroupLimits{}, errors.Errorf("Invalid cgroup memory limit %q in %s", memoryMax, dir)
+			}
+			limits.MemoryLimit = tighterMemoryLimit(limits.MemoryLimit, limit)
+		}
+	}
+	return limits, nil
+}
+
+/*
+ * In cgroup v1 each controller has its own hierarchy, mounted at a directory
+ * named for the controllers it holds, e.g. "cpu,cpuacct".  Most systems also
+ * provide a symlink named for the controller alone, which is tried first.
+ */
+func cgroupV1Root(membership cgroupMembership, controller string) string {
+	if _, err := System.Stat(path.Join(cgroupRoot, controller)); err == nil {
+		return path.Join(cgroupRoot, controller)
+	}
+	return path.Join(cgroupRoot, membership.hierarchy)
+}
+
+func readCgroupV1Limits(memberships map[string]cgroupMembership) (CgroupLimits, error) {
+	cpu, hasCPU := memberships["cpu"]
+	memory, hasMemory := memberships["memory"]
+	if !hasCPU && !hasMemory {
+		return CgroupLimits{}, nil
+	}
+	limits := CgroupLimits{Version: 1}
+	if hasCPU {
+		for _, dir := range cgroupAncestors(cgroupV1Root(cpu, "cpu"), cpu.path) {
+			quotaStr, foundQuota, err := readCgroupFile(dir, "cpu.cfs_quota_us")
+			if err != nil {
+				return CgroupLimits{}, err
+			}
+			periodStr, foundPeriod, err := readCgroupFile(dir, "cpu.cfs_period_us")
+			if err != nil {
+				return CgroupLimits{}, err
+			}
+			if !foundQuota || !foundPeriod || quotaStr == "-1" {
+				continue
+			}
+			quota, quotaErr := strconv.ParseFloat(quotaStr, 64)
+			period, periodErr := strconv.ParseFloat(periodStr, 64)
+			if quotaErr != nil || periodErr != nil || period <= 0 {
+				return CgroupLimits{}, errors.Errorf("Invalid cgroup CPU limit %q/%q in %s", quotaStr, periodStr, dir)
+			}
+			limits.CPUQuota = tighterCPUQuota(limits.CPUQuota, quota/period)
+		}
+	}
+	if hasMemory {
+		for _, dir := range cgroupAncestors(cgroupV1Root(memory, "memory"), memory.path) {
+			limitStr, found, err := readCgroupFile(dir, "memory.limit_in_bytes")
+			if err != nil {
+				return CgroupLimits{}, err
+			}
+			if !found {
+				continue
+			}
+			limit, parseErr := strconv.ParseInt(limitStr, 10, 64)
+			if parseErr != nil {
+				return CgroupLimits{}, errors.Errorf("Invalid cgroup memory limit %q in %s", limitStr, dir)
+			}
+			limits.MemoryLimit = tighterMemoryLimit(limits.MemoryLimit, limit)
+		}
+	}
+	return limits, nil
+}
+
+/*
+ * EffectiveCPUCount returns the number of CPUs the process can actually use:
+ * runtime.NumCPU, which already accounts for CPU affinity, lowered to the
+ * cgroup CPU quota rounded up.  It is always at least 1, and falls back to
+ * runtime.NumCPU if the limits cannot be read.
+ */
+func EffectiveCPUCount() int {
+	numCPU := runtime.NumCPU()
+	limits, err := ReadCgroupLimits()
+	if err != nil || limits.CPUQuota == 0 {
+		return numCPU
+	}
+	quotaCPUs := int(math.Ceil(limits.CPUQuota))
+	if quotaCPUs < 1 {
+		quotaCPUs = 1
+	}
+	if quotaCPUs < numCPU {
+		return quotaCPUs
+	}
+	return numCPU
+}
+
+/*
+ * NUMANodeCount returns the number of NUMA nodes on the host, or 1 if the
+ * host does not report them.  The kernel lists online nodes as ranges such
+ * as "0-1,3".
+ */
+func NUMANodeCount() int {
+	contents, err := System.ReadFile("/sys/devices/system/node/online")
+	if err != nil {
+		return 1
+	}
+	count := 0
+	for _, nodeRange := range strings.Split(strings.TrimSpace(string(contents)), ",") {
+		bounds := strings.SplitN(nodeRange, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return 1
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil {
+				return 1
+			}
+		}
+		count += last - first + 1
+	}
+	if count < 1 {
+		return 1
+	}
+	return count
+}
@@ -0,0 +1,179 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package operating_test
+
+import (
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/operating"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("operating/cgroup tests", func() {
+	var files map[string]string
+
+	// Mock a filesystem holding files with the given contents and the directories containing them
+	BeforeEach(func() {
+		files = make(map[string]string)
+		operating.System.ReadFile = func(filename string) ([]byte, error) {
+			if contents, ok := files[filename]; ok {
+				return []byte(contents), nil
+			}
+			return nil, os.ErrNotExist
+		}
+		operating.System.Stat = func(name string) (os.FileInfo, error) {
+			for filename := range files {
+				if filename == name || strings.HasPrefix(filename, name+"/") {
+					return nil, nil
+				}
+			}
+			return nil, os.ErrNotExist
+		}
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("ReadCgroupLimits", func() {
+		DescribeTable("reads cgroup v2 limits",
+			func(membership string, limitFiles map[string]string, expected operating.CgroupLimits) {
+				files["/proc/self/cgroup"] = membership
+				files["/sys/fs/cgroup/cgroup.controllers"] = "cpu memory"
+				for filename, contents := range limitFiles {
+					files[filename] = contents
+				}
+				Expect(operating.ReadCgroupLimits()).To(Equal(expected))
+			},
+			Entry("with limits on the process's cgroup", "0::/user.slice/app\n", map[string]string{
+				"/sys/fs/cgroup/user.slice/app/cpu.max":    "150000 100000\n",
+				"/sys/fs/cgroup/user.slice/app/memory.max": "1073741824\n",
+			}, operating.CgroupLimits{Version: 2, CPUQuota: 1.5, MemoryLimit: 1073741824}),
+			Entry("with the tightest limit of the cgroup and its ancestors", "0::/user.slice/app\n", map[string]string{
+				"/sys/fs/cgroup/user.slice/app/cpu.max":    "400000 100000\n",
+				"/sys/fs/cgroup/user.slice/app/memory.max": "1073741824\n",
+				"/sys/fs/cgroup/user.slice/cpu.max":        "200000 100000\n",
+				"/sys/fs/cgroup/user.slice/memory.max":     "2147483648\n",
+			}, operating.CgroupLimits{Version: 2, CPUQuota: 2, MemoryLimit: 1073741824}),
+			Entry("with \"max\" quotas", "0::/user.slice/app\n", map[string]string{
+				"/sys/fs/cgroup/user.slice/app/cpu.max":    "max 100000\n",
+				"/sys/fs/cgroup/user.slice/app/memory.max": "max\n",
+			}, operating.CgroupLimits{Version: 2}),
+			Entry("with the cpu and memory controllers missing", "0::/user.slice/app\n", map[string]string{},
+				operating.CgroupLimits{Version: 2}),
+			Entry("in a container with its own cgroup namespace", "0::/\n", map[string]string{
+				"/sys/fs/cgroup/cpu.max": "50000 100000\n",
+			}, operating.CgroupLimits{Version: 2, CPUQuota: 0.5}),
+		)
+		DescribeTable("reads cgroup v1 limits",
+			func(membership string, limitFiles map[string]string, expected operating.CgroupLimits) {
+				files["/proc/self/cgroup"] = membership
+				for filename, contents := range limitFiles {
+					files[filename] = contents
+				}
+				Expect(operating.ReadCgroupLimits()).To(Equal(expected))
+			},
+			Entry("through the per-controller symlinks", "12:cpu,cpuacct:/docker/abc\n9:memory:/docker/abc\n1:name=systemd:/docker/abc\n", map[string]string{
+				"/sys/fs/cgroup/cpu/docker/abc/cpu.cfs_quota_us":         "50000\n",
+				"/sys/fs/cgroup/cpu/docker/abc/cpu.cfs_period_us":        "100000\n",
+				"/sys/fs/cgroup/memory/docker/abc/memory.limit_in_bytes": "536870912\n",
+			}, operating.CgroupLimits{Version: 1, CPUQuota: 0.5, MemoryLimit: 536870912}),
+			Entry("through the hierarchy named for all of its controllers", "12:cpu,cpuacct:/docker/abc\n", map[string]string{
+				"/sys/fs/cgroup/cpu,cpuacct/docker/abc/cpu.cfs_quota_us":  "300000\n",
+				"/sys/fs/cgroup/cpu,cpuacct/docker/abc/cpu.cfs_period_us": "100000\n",
+			}, operating.CgroupLimits{Version: 1, CPUQuota: 3}),
+			Entry("with the tightest limit of the cgroup and its ancestors", "4:cpu:/docker/abc\n5:memory:/docker/abc\n", map[string]string{
+				"/sys/fs/cgroup/cpu/docker/abc/cpu.cfs_quota_us":         "400000\n",
+				"/sys/fs/cgroup/cpu/docker/abc/cpu.cfs_period_us":        "100000\n",
+				"/sys/fs/cgroup/cpu/docker/cpu.cfs_quota_us":             "100000\n",
+				"/sys/fs/cgroup/cpu/docker/cpu.cfs_period_us":            "100000\n",
+				"/sys/fs/cgroup/memory/docker/abc/memory.limit_in_bytes": "2147483648\n",
+				"/sys/fs/cgroup/memory/memory.limit_in_bytes":            "1073741824\n",
+			}, operating.CgroupLimits{Version: 1, CPUQuota: 1, MemoryLimit: 1073741824}),
+			Entry("with no limits set", "4:cpu:/docker/abc\n5:memory:/docker/abc\n", map[string]string{
+				"/sys/fs/cgroup/cpu/docker/abc/cpu.cfs_quota_us":         "-1\n",
+				"/sys/fs/cgroup/cpu/docker/abc/cpu.cfs_period_us":        "100000\n",
+				"/sys/fs/cgroup/memory/docker/abc/memory.limit_in_bytes": "9223372036854771712\n",
+			}, operating.CgroupLimits{Version: 1}),
+			Entry("ignoring malformed membership lines", "garbage\n4:cpu:/docker/abc\n", map[string]string{
+				"/sys/fs/cgroup/cpu/docker/abc/cpu.cfs_quota_us":  "200000\n",
+				"/sys/fs/cgroup/cpu/docker/abc/cpu.cfs_period_us": "100000\n",
+			}, operating.CgroupLimits{Version: 1, CPUQuota: 2}),
+			Entry("with the cpu and memory controllers missing", "1:name=systemd:/docker/abc\n2:pids:/docker/abc\n", map[string]string{},
+				operating.CgroupLimits{}),
+		)
+		It("returns no limits if the process's cgroups cannot be found", func() {
+			Expect(operating.ReadCgroupLimits()).To(Equal(operating.CgroupLimits{}))
+		})
+		It("returns an error if the process's cgroups cannot be read", func() {
+			operating.System.ReadFile = func(string) ([]byte, error) { return nil, os.ErrPermission }
+			_, err := operating.ReadCgroupLimits()
+			Expect(err).To(MatchError(ContainSubstring("Unable to read cgroup membership")))
+		})
+		It("returns an error if a limit is invalid", func() {
+			files["/proc/self/cgroup"] = "0::/app\n"
+			files["/sys/fs/cgroup/cgroup.controllers"] = "cpu memory"
+			files["/sys/fs/cgroup/app/cpu.max"] = "lots 100000\n"
+			_, err := operating.ReadCgroupLimits()
+			Expect(err).To(MatchError(`Invalid cgroup CPU limit "lots 100000" in /sys/fs/cgroup/app`))
+		})
+	})
+
+	Describe("EffectiveCPUCount", func() {
+		BeforeEach(func() {
+			files["/proc/self/cgroup"] = "0::/app\n"
+			files["/sys/fs/cgroup/cgroup.controllers"] = "cpu memory"
+		})
+
+		It("returns the number of CPUs if there is no quota", func() {
+			Expect(operating.EffectiveCPUCount()).To(Equal(runtime.NumCPU()))
+		})
+		It("rounds a fractional quota up to at least one CPU", func() {
+			files["/sys/fs/cgroup/app/cpu.max"] = "10000 100000\n"
+			Expect(operating.EffectiveCPUCount()).To(Equal(1))
+		})
+		It("returns the number of CPUs if the quota is larger", func() {
+			files["/sys/fs/cgroup/app/cpu.max"] = "100000000 100000\n"
+			Expect(operating.EffectiveCPUCount()).To(Equal(runtime.NumCPU()))
+		})
+		It("returns the number of CPUs if the limits cannot be read", func() {
+			files["/sys/fs/cgroup/app/cpu.max"] = "lots 100000\n"
+			Expect(operating.EffectiveCPUCount()).To(Equal(runtime.NumCPU()))
+		})
+	})
+
+	Describe("NUMANodeCount", func() {
+		DescribeTable("counts the online nodes",
+			func(online string, expected int) {
+				files["/sys/devices/system/node/online"] = online
+				Expect(operating.NUMANodeCount()).To(Equal(expected))
+			},
+			Entry("a single node", "0\n", 1),
+			Entry("a range of nodes", "0-3\n", 4),
+			Entry("ranges and single nodes", "0-1,3,5-6\n", 5),
+			Entry("a malformed node", "zero\n", 1),
+			Entry("a malformed range", "0-x\n", 1),
+		)
+		It("returns 1 if the host does not report its nodes", func() {
+			Expect(operating.NUMANodeCount()).To(Equal(1))
+		})
+	})
+})
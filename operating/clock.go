@@ -0,0 +1,85 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package operating
+
+/*
+ * This file contains a monotonic clock and interfaces over time.Timer and
+ * time.Ticker, so that code that waits or measures durations can be given a
+ * fake clock through System in tests instead of sleeping for real.
+ */
+
+import (
+	"time"
+)
+
+var monotonicStart = time.Now()
+
+/*
+ * MonotonicNow returns the time elapsed since an arbitrary fixed point early
+ * in the life of the process.  Unlike differences between wall-clock times,
+ * differences between its values are unaffected by changes to the system
+ * clock, so it should be used for measuring durations.
+ */
+func MonotonicNow() time.Duration {
+	return time.Since(monotonicStart)
+}
+
+/*
+ * Since returns the time elapsed since start, a value previously returned by
+ * System.MonotonicNow, so that durations measured with it follow a fake clock
+ * installed in System.
+ */
+func Since(start time.Duration) time.Duration {
+	return System.MonotonicNow() - start
+}
+
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+	Reset(d time.Duration)
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (timer realTimer) C() <-chan time.Time {
+	return timer.Timer.C
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (ticker realTicker) C() <-chan time.Time {
+	return ticker.Ticker.C
+}
+
+func NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
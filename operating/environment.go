@@ -0,0 +1,120 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package operating
+
+/*
+ * This file contains a typed snapshot of the environment variables that
+ * Greenplum and Cloudberry utilities read, and a helper for temporarily
+ * overriding environment variables in tests.
+ */
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/*
+ * GPEnvironment holds the values of the environment variables used by the
+ * utilities.  A field is empty, or 0 for PGPort, if its variable is unset or
+ * is not valid.
+ */
+type GPEnvironment struct {
+	GPHome                   string
+	CoordinatorDataDirectory string
+	PGHost                   string
+	PGPort                   int
+	PGUser                   string
+	PGDatabase               string
+	PGSSLMode                string
+	PGKrbSrvName             string
+}
+
+/*
+ * Environment reads the variables in GPEnvironment through System.Getenv.  It
+ * always returns every valid value it found, along with an error describing
+ * all of the invalid ones, so callers that have a fallback for a variable can
+ * ignore the error.
+ */
+func Environment() (GPEnvironment, error) {
+	env := GPEnvironment{
+		PGHost:       System.Getenv("PGHOST"),
+		PGUser:       System.Getenv("PGUSER"),
+		PGDatabase:   System.Getenv("PGDATABASE"),
+		PGSSLMode:    System.Getenv("PGSSLMODE"),
+		PGKrbSrvName: System.Getenv("PGKRBSRVNAME"),
+	}
+	problems := make([]string, 0)
+	absolutePath := func(name string) string {
+		path := System.Getenv(name)
+		if path != "" && !filepath.IsAbs(path) {
+			problems = append(problems, name+" must be an absolute path, got "+strconv.Quote(path))
+			return ""
+		}
+		return path
+	}
+	env.GPHome = absolutePath("GPHOME")
+	env.CoordinatorDataDirectory = absolutePath("COORDINATOR_DATA_DIRECTORY")
+	if portStr := System.Getenv("PGPORT"); portStr != "" {
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port < 1 || port > 65535 {
+			problems = append(problems, "PGPORT must be a port number between 1 and 65535, got "+strconv.Quote(portStr))
+		} else {
+			env.PGPort = port
+		}
+	}
+	if len(problems) > 0 {
+		return env, errors.Errorf("Invalid environment: %s", strings.Join(problems, "; "))
+	}
+	return env, nil
+}
+
+/*
+ * WithEnv sets each variable in vars, runs f, and then restores every
+ * variable to its previous value, even if f panics.  An empty value unsets
+ * the variable.  The process environment is shared, so WithEnv must not be
+ * used while other goroutines read or change it.
+ */
+func WithEnv(vars map[string]string, f func()) {
+	type savedVar struct {
+		value string
+		isSet bool
+	}
+	saved := make(map[string]savedVar, len(vars))
+	defer func() {
+		for name, previous := range saved {
+			if previous.isSet {
+				_ = System.Setenv(name, previous.value)
+			} else {
+				_ = System.Unsetenv(name)
+			}
+		}
+	}()
+	for name, value := range vars {
+		previous, isSet := System.LookupEnv(name)
+		saved[name] = savedVar{value: previous, isSet: isSet}
+		if value == "" {
+			_ = System.Unsetenv(name)
+		} else {
+			_ = System.Setenv(name, value)
+		}
+	}
+	f()
+}
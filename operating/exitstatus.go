@@ -0,0 +1,115 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package operating
+
+/*
+ * This file contains functions for decoding how an executed command exited,
+ * so that callers do not need to match against strings like "exit status 137"
+ * to tell that a command was killed.
+ */
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+/*
+ * ExitStatus describes how a command exited.
+ * - Code is the exit code, or -1 if the command could not be run or did not
+ *   exit (e.g. it could not be started or was never waited on).  If the
+ *   command was killed by a signal, Code is 128 plus the signal number, as a
+ *   shell would report it.
+ * - Signal is the signal that killed the command, or 0 if none did.  Since
+ *   cluster commands run under bash or ssh, a command that was killed by a
+ *   signal usually causes the shell to exit with code 128 plus the signal
+ *   number rather than being killed itself, so such exit codes are decoded
+ *   as signals as well.
+ * - CoreDumped is set if the command dumped core; it can only be detected
+ *   when the command itself was killed, not when a shell reports the signal.
+ */
+type ExitStatus struct {
+	Code       int
+	Signal     syscall.Signal
+	CoreDumped bool
+}
+
+// The highest signal number a shell exit code is decoded as
+const maxShellSignal = 64
+
+/*
+ * Platforms other than Windows and Plan 9 use syscall.WaitStatus for this;
+ * checking for the methods instead of the type keeps this platform-neutral.
+ */
+type signalStatus interface {
+	Signaled() bool
+	Signal() syscall.Signal
+	CoreDump() bool
+}
+
+/*
+ * DecodeExitStatus returns how the command that returned err exited; err is
+ * the error returned by exec.Cmd.Run, Output, or a similar function, and may
+ * wrap an *exec.ExitError.
+ */
+func DecodeExitStatus(err error) ExitStatus {
+	if err == nil {
+		return ExitStatus{Code: 0}
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return ExitStatus{Code: -1}
+	}
+	if status, ok := exitErr.Sys().(signalStatus); ok && status.Signaled() {
+		return ExitStatus{
+			Code:       128 + int(status.Signal()),
+			Signal:     status.Signal(),
+			CoreDumped: status.CoreDump(),
+		}
+	}
+	code := exitErr.ExitCode()
+	result := ExitStatus{Code: code}
+	if code > 128 && code <= 128+maxShellSignal {
+		result.Signal = syscall.Signal(code - 128)
+	}
+	return result
+}
+
+func (status ExitStatus) Exited() bool {
+	return status.Code >= 0
+}
+
+// Killed returns true if the command was killed with SIGKILL, e.g. by the kernel's OOM killer
+func (status ExitStatus) Killed() bool {
+	return status.Signal == syscall.SIGKILL
+}
+
+func (status ExitStatus) String() string {
+	if !status.Exited() {
+		return "did not exit"
+	}
+	if status.Signal == 0 {
+		return fmt.Sprintf("exit code %d", status.Code)
+	}
+	description := fmt.Sprintf("exit code %d, killed by signal %d (%s)", status.Code, int(status.Signal), status.Signal)
+	if status.CoreDumped {
+		description += ", core dumped"
+	}
+	return description
+}
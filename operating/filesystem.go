@@ -0,0 +1,42 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package operating
+
+/*
+ * This file contains functions for checking the space and type of the
+ * filesystem containing a path, so that pre-flight space checks do not need
+ * to parse df output.  The platform-specific statfs calls are in
+ * filesystem_linux.go and filesystem_darwin.go.
+ */
+
+/*
+ * FilesystemUsage holds the size of a filesystem and its free space.
+ * FreeBytes includes space reserved for the superuser, while AvailableBytes
+ * is the space available to unprivileged users, as reported by df.
+ */
+type FilesystemUsage struct {
+	TotalBytes     uint64
+	FreeBytes      uint64
+	AvailableBytes uint64
+	TotalInodes    uint64
+	FreeInodes     uint64
+}
+
+func (usage FilesystemUsage) UsedBytes() uint64 {
+	return usage.TotalBytes - usage.FreeBytes
+}
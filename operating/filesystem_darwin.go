@@ -0,0 +1,67 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package operating
+
+import (
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+func DiskUsage(path string) (FilesystemUsage, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return FilesystemUsage{}, errors.Wrapf(err, "Unable to get disk usage for %s", path)
+	}
+	blockSize := uint64(stat.Bsize)
+	return FilesystemUsage{
+		TotalBytes:     stat.Blocks * blockSize,
+		FreeBytes:      stat.Bfree * blockSize,
+		AvailableBytes: stat.Bavail * blockSize,
+		TotalInodes:    stat.Files,
+		FreeInodes:     stat.Ffree,
+	}, nil
+}
+
+// FilesystemType returns the name of the type of the filesystem containing path
+func FilesystemType(path string) (string, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return "", errors.Wrapf(err, "Unable to get filesystem type for %s", path)
+	}
+	name := make([]byte, 0, len(stat.Fstypename))
+	for _, c := range stat.Fstypename {
+		if c == 0 {
+			break
+		}
+		name = append(name, byte(c))
+	}
+	return string(name), nil
+}
+
+// The longest path that macOS system calls accept, PATH_MAX
+const maxPathLength = 1024
+
+// statfs does not report a name length limit on macOS, but every filesystem it supports allows NAME_MAX bytes
+func maxNameLength(path string) (int, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, errors.Wrapf(err, "Unable to get file name length limit for %s", path)
+	}
+	return 255, nil
+}
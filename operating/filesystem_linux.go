@@ -0,0 +1,80 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package operating
+
+import (
+	"fmt"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+func DiskUsage(path string) (FilesystemUsage, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return FilesystemUsage{}, errors.Wrapf(err, "Unable to get disk usage for %s", path)
+	}
+	blockSize := uint64(stat.Bsize)
+	return FilesystemUsage{
+		TotalBytes:     stat.Blocks * blockSize,
+		FreeBytes:      stat.Bfree * blockSize,
+		AvailableBytes: stat.Bavail * blockSize,
+		TotalInodes:    stat.Files,
+		FreeInodes:     stat.Ffree,
+	}, nil
+}
+
+// Magic numbers from statfs(2) for the filesystems most likely to hold data directories
+var filesystemTypes = map[int64]string{
+	0xEF53:     "ext4", // also ext2 and ext3, which share a magic number
+	0x58465342: "xfs",
+	0x9123683E: "btrfs",
+	0x2FC12FC1: "zfs",
+	0x01021994: "tmpfs",
+	0x6969:     "nfs",
+	0xFF534D42: "cifs",
+	0x794C7630: "overlay",
+	0x858458F6: "ramfs",
+	0x9FA0:     "proc",
+}
+
+/*
+ * FilesystemType returns the name of the type of the filesystem containing
+ * path, or its hexadecimal magic number if the type is not a known one.
+ */
+func FilesystemType(path string) (string, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return "", errors.Wrapf(err, "Unable to get filesystem type for %s", path)
+	}
+	if name, ok := filesystemTypes[int64(stat.Type)]; ok {
+		return name, nil
+	}
+	return fmt.Sprintf("0x%x", stat.Type), nil
+}
+
+// The longest path that Linux system calls accept, PATH_MAX
+const maxPathLength = 4096
+
+func maxNameLength(path string) (int, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, errors.Wrapf(err, "Unable to get file name length limit for %s", path)
+	}
+	return int(stat.Namelen), nil
+}
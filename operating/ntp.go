@@ -0,0 +1,191 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package operating
+
+/*
+ * This file contains functions for determining whether the system clock is
+ * synchronized by NTP, for utilities whose distributed operations depend on
+ * the hosts in a cluster agreeing on the time.
+ */
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+ * The shell command run by CheckNTPSync, for running the same check on other
+ * hosts; its output can be parsed with ParseNTPStatus.
+ */
+const NTP_STATUS_COMMAND = "chronyc tracking 2>/dev/null || ntpq -pn 2>/dev/null || timedatectl status"
+
+/*
+ * NTPStatus describes the state of the local NTP client.  Source is "chrony",
+ * "ntpd", or "timedatectl", depending on which reported the state.  Only
+ * chrony and ntpd report the NTP server and the offset of the system clock
+ * from NTP time, positive if the clock is ahead, so HasOffset is false for
+ * timedatectl.
+ */
+type NTPStatus struct {
+	Synchronized bool
+	Source       string
+	Server       string
+	Offset       time.Duration
+	HasOffset    bool
+}
+
+func (status NTPStatus) String() string {
+	if !status.Synchronized {
+		return fmt.Sprintf("not synchronized (%s)", status.Source)
+	}
+	description := "synchronized"
+	if status.Server != "" {
+		description += " to " + status.Server
+	}
+	if status.HasOffset {
+		description += fmt.Sprintf(", offset %s", status.Offset)
+	}
+	return fmt.Sprintf("%s (%s)", description, status.Source)
+}
+
+/*
+ * CheckNTPSync reports whether the system clock is synchronized, asking
+ * chronyd through chronyc if it is running, ntpd through ntpq if that is
+ * running instead, and systemd through timedatectl otherwise.  All are run
+ * through System.CommandOutput so that they can be mocked.  It returns an
+ * error only if none could report the state, not if the clock is
+ * unsynchronized.
+ */
+func CheckNTPSync() (NTPStatus, error) {
+	output, chronyErr := System.CommandOutput("chronyc", "tracking")
+	if chronyErr == nil {
+		return ParseNTPStatus(string(output))
+	}
+	output, ntpqErr := System.CommandOutput("ntpq", "-pn")
+	if ntpqErr == nil {
+		return ParseNTPStatus(string(output))
+	}
+	output, timedatectlErr := System.CommandOutput("timedatectl", "status")
+	if timedatectlErr == nil {
+		return ParseNTPStatus(string(output))
+	}
+	return NTPStatus{}, errors.Errorf("Unable to determine clock synchronization status: chronyc: %v; ntpq: %v; timedatectl: %v: %s",
+		chronyErr, ntpqErr, timedatectlErr, strings.TrimSpace(string(output)))
+}
+
+// ParseNTPStatus parses the output of "chronyc tracking", "ntpq -pn", or "timedatectl status"
+func ParseNTPStatus(output string) (NTPStatus, error) {
+	if status, ok, err := parseNTPQPeers(output); ok {
+		return status, err
+	}
+	fields := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		key, value, found := strings.Cut(line, ":")
+		if found {
+			fields[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	if leapStatus, ok := fields["Leap status"]; ok {
+		return parseChronyTracking(fields, leapStatus)
+	}
+	for _, key := range []string{"System clock synchronized", "NTP synchronized"} {
+		if synchronized, ok := fields[key]; ok {
+			return NTPStatus{Synchronized: synchronized == "yes", Source: "timedatectl"}, nil
+		}
+	}
+	return NTPStatus{}, errors.Errorf("Unable to parse clock synchronization status from %q", strings.TrimSpace(output))
+}
+
+/*
+ * chronyc reports e.g. "System time : 0.000123456 seconds slow of NTP time"
+ * and "Reference ID : C0A80101 (192.168.1.1)", or a reference ID of 00000000
+ * and a leap status of "Not synchronised" if it has no usable server.
+ */
+func parseChronyTracking(fields map[string]string, leapStatus string) (NTPStatus, error) {
+	status := NTPStatus{Source: "chrony"}
+	referenceID, server, _ := strings.Cut(fields["Reference ID"], " ")
+	status.Server = strings.Trim(strings.TrimSpace(server), "()")
+	status.Synchronized = leapStatus != "Not synchronised" && referenceID != "00000000" && referenceID != ""
+
+	if systemTime, ok := fields["System time"]; ok {
+		words := strings.Fields(systemTime)
+		if len(words) < 3 {
+			return NTPStatus{}, errors.Errorf("Unable to parse chrony system time %q", systemTime)
+		}
+		seconds, err := strconv.ParseFloat(words[0], 64)
+		if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+			return NTPStatus{}, errors.Errorf("Unable to parse chrony system time %q", systemTime)
+		}
+		if words[2] == "slow" {
+			seconds = -seconds
+		}
+		status.Offset = time.Duration(seconds * float64(time.Second))
+		status.HasOffset = true
+	}
+	return status, nil
+}
+
+/*
+ * ntpq lists each of ntpd's peers under a header, e.g.
+ *
+ *        remote           refid      st t when poll reach   delay   offset  jitter
+ *   ==============================================================================
+ *   *192.168.1.1     .GPS.            1 u   33   64  377    0.412   -0.123   0.045
+ *
+ * where "*" marks the peer the clock is synchronized to, and the offset is
+ * that of the peer from the system clock in milliseconds.  The returned bool
+ * is false if output is not from ntpq.
+ */
+func parseNTPQPeers(output string) (NTPStatus, bool, error) {
+	if strings.Contains(output, "No association ID's returned") {
+		return NTPStatus{Source: "ntpd"}, true, nil
+	}
+	lines := strings.Split(output, "\n")
+	header := -1
+	for i, line := range lines {
+		if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "remote" && fields[1] == "refid" {
+			header = i
+			break
+		}
+	}
+	if header < 0 {
+		return NTPStatus{}, false, nil
+	}
+	status := NTPStatus{Source: "ntpd"}
+	for _, line := range lines[header+1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 10 || !strings.HasPrefix(fields[0], "*") {
+			continue
+		}
+		milliseconds, err := strconv.ParseFloat(fields[len(fields)-2], 64)
+		if err != nil || math.IsNaN(milliseconds) || math.IsInf(milliseconds, 0) {
+			return NTPStatus{}, true, errors.Errorf("Unable to parse ntpq offset from %q", strings.TrimSpace(line))
+		}
+		status.Synchronized = true
+		status.Server = strings.TrimPrefix(fields[0], "*")
+		status.Offset = -time.Duration(milliseconds * float64(time.Millisecond))
+		status.HasOffset = true
+		break
+	}
+	return status, true, nil
+}
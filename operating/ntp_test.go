@@ -0,0 +1,138 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package operating_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/operating"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("operating/ntp tests", func() {
+	chronySynced := `Reference ID    : C0A80101 (192.168.1.1)
+Stratum         : 3
+Ref time (UTC)  : Fri Oct 16 12:00:00 2026
+System time     : 0.000123456 seconds slow of NTP time
+Last offset     : -0.000012345 seconds
+RMS offset      : 0.000023456 seconds
+Frequency       : 1.234 ppm fast
+Leap status     : Normal
+`
+	chronyUnsynced := `Reference ID    : 00000000 ()
+Stratum         : 0
+Ref time (UTC)  : Thu Jan 01 00:00:00 1970
+System time     : 0.000000000 seconds fast of NTP time
+Leap status     : Not synchronised
+`
+	ntpqSynced := `     remote           refid      st t when poll reach   delay   offset  jitter
+==============================================================================
++10.0.0.2        192.168.1.1      2 u   12   64  377    0.512    0.456   0.067
+*192.168.1.1     .GPS.            1 u   33   64  377    0.412   -1.500   0.045
+ 10.0.0.3        .INIT.          16 u    -   64    0    0.000    0.000   0.000
+`
+	ntpqUnsynced := `     remote           refid      st t when poll reach   delay   offset  jitter
+==============================================================================
+ 192.168.1.1     .INIT.          16 u    -   64    0    0.000    0.000   0.000
+`
+	timedatectlSynced := `               Local time: Fri 2026-10-16 12:00:00 UTC
+           Universal time: Fri 2026-10-16 12:00:00 UTC
+                 RTC time: Fri 2026-10-16 12:00:00
+                Time zone: Etc/UTC (UTC, +0000)
+System clock synchronized: yes
+              NTP service: active
+          RTC in local TZ: no
+`
+
+	Describe("ParseNTPStatus", func() {
+		DescribeTable("parses the status",
+			func(output string, expected operating.NTPStatus) {
+				Expect(operating.ParseNTPStatus(output)).To(Equal(expected))
+			},
+			Entry("from synchronized chronyc output", chronySynced,
+				operating.NTPStatus{Synchronized: true, Source: "chrony", Server: "192.168.1.1", Offset: -123456 * time.Nanosecond, HasOffset: true}),
+			Entry("from chronyc output with the clock ahead", `Reference ID    : C0A80101 (ntp.example.com)
+System time     : 0.250000000 seconds fast of NTP time
+Leap status     : Normal
+`, operating.NTPStatus{Synchronized: true, Source: "chrony", Server: "ntp.example.com", Offset: 250 * time.Millisecond, HasOffset: true}),
+			Entry("from unsynchronized chronyc output", chronyUnsynced,
+				operating.NTPStatus{Source: "chrony", HasOffset: true}),
+			Entry("from synchronized ntpq output", ntpqSynced,
+				operating.NTPStatus{Synchronized: true, Source: "ntpd", Server: "192.168.1.1", Offset: 1500 * time.Microsecond, HasOffset: true}),
+			Entry("from ntpq output with no selected peer", ntpqUnsynced,
+				operating.NTPStatus{Source: "ntpd"}),
+			Entry("from ntpq output with no peers", "No association ID's returned\n",
+				operating.NTPStatus{Source: "ntpd"}),
+			Entry("from synchronized timedatectl output", timedatectlSynced,
+				operating.NTPStatus{Synchronized: true, Source: "timedatectl"}),
+			Entry("from unsynchronized timedatectl output", "System clock synchronized: no\nNTP service: inactive\n",
+				operating.NTPStatus{Source: "timedatectl"}),
+			Entry("from older timedatectl output", "NTP synchronized: yes\n",
+				operating.NTPStatus{Synchronized: true, Source: "timedatectl"}),
+		)
+		DescribeTable("returns an error for output it cannot parse",
+			func(output string, message string) {
+				_, err := operating.ParseNTPStatus(output)
+				Expect(err).To(MatchError(message))
+			},
+			Entry("unrelated output", "bash: chronyc: command not found\n",
+				`Unable to parse clock synchronization status from "bash: chronyc: command not found"`),
+			Entry("a malformed chrony system time", "System time : slow\nLeap status : Normal\n",
+				`Unable to parse chrony system time "slow"`),
+			Entry("a malformed ntpq offset", "remote refid st t when poll reach delay offset jitter\n*192.168.1.1 .GPS. 1 u 33 64 377 0.412 fast 0.045\n",
+				`Unable to parse ntpq offset from "*192.168.1.1 .GPS. 1 u 33 64 377 0.412 fast 0.045"`),
+		)
+	})
+
+	Describe("CheckNTPSync", func() {
+		var outputs map[string]string
+
+		BeforeEach(func() {
+			outputs = make(map[string]string)
+			operating.System.CommandOutput = func(name string, args ...string) ([]byte, error) {
+				if output, ok := outputs[name]; ok {
+					return []byte(output), nil
+				}
+				return []byte("bash: " + name + ": command not found\n"), errors.New("exit status 127")
+			}
+		})
+		AfterEach(func() {
+			operating.System = operating.InitializeSystemFunctions()
+		})
+
+		It("asks chrony first", func() {
+			outputs["chronyc"], outputs["ntpq"], outputs["timedatectl"] = chronyUnsynced, ntpqSynced, timedatectlSynced
+			Expect(operating.CheckNTPSync()).To(Equal(operating.NTPStatus{Source: "chrony", HasOffset: true}))
+		})
+		It("asks ntpd if chrony is not available", func() {
+			outputs["ntpq"], outputs["timedatectl"] = ntpqUnsynced, timedatectlSynced
+			Expect(operating.CheckNTPSync()).To(Equal(operating.NTPStatus{Source: "ntpd"}))
+		})
+		It("asks systemd if neither chrony nor ntpd is available", func() {
+			outputs["timedatectl"] = timedatectlSynced
+			Expect(operating.CheckNTPSync()).To(Equal(operating.NTPStatus{Synchronized: true, Source: "timedatectl"}))
+		})
+		It("returns an error if none of the commands are available", func() {
+			_, err := operating.CheckNTPSync()
+			Expect(err).To(MatchError("Unable to determine clock synchronization status: chronyc: exit status 127; ntpq: exit status 127; timedatectl: exit status 127: bash: timedatectl: command not found"))
+		})
+	})
+})
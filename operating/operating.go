@@ -8,9 +8,13 @@ package operating
 import (
 	"io"
 	"io/ioutil"
+	"net"
 	"os"
+	"os/exec"
+	"os/signal"
 	"os/user"
 	"path/filepath"
+	"syscall"
 	"time"
 )
 
@@ -51,55 +55,105 @@ func OpenFileWrite(name string, flag int, perm os.FileMode) (io.WriteCloser, err
  * All function pointers in SystemFunctions refer directly to built-in functions
  * except for OpenFileRead and OpenFileWrite, which both refer to os.OpenFile but
  * return either an io.ReadCloser or io.WriteCloser instead of an *os.File, to make
- * mocking file opening in tests easier.
+ * mocking file opening in tests easier; CommandOutput, which runs a command
+ * and returns its combined output; DiskUsage and FilesystemType, which wrap
+ * statfs; PathLimits, which probes a directory's filesystem; and MonotonicNow,
+ * NewTimer, and NewTicker, which wrap the time package behind interfaces so
+ * that tests can substitute a fake clock.
  */
 
 type SystemFunctions struct {
-	Chmod         func(name string, mode os.FileMode) error
-	CurrentUser   func() (*user.User, error)
-	Exit          func(code int)
-	Getenv        func(key string) string
-	Getpid        func() int
-	Glob          func(pattern string) (matches []string, err error)
-	Hostname      func() (string, error)
-	IsNotExist    func(err error) bool
-	LookupEnv     func(key string) (string, bool)
-	MkdirAll      func(path string, perm os.FileMode) error
-	Now           func() time.Time
-	OpenFileRead  func(name string, flag int, perm os.FileMode) (ReadCloserAt, error)
-	OpenFileWrite func(name string, flag int, perm os.FileMode) (io.WriteCloser, error)
-	ReadFile      func(filename string) ([]byte, error)
-	Remove        func(name string) error
-	RemoveAll     func(name string) error
-	Stat          func(name string) (os.FileInfo, error)
-	Stdin         ReadCloserAt
-	Stdout        io.WriteCloser
-	TempFile      func(dir, pattern string) (f *os.File, err error)
-	Local         *time.Location
+	Chmod          func(name string, mode os.FileMode) error
+	Chown          func(name string, uid, gid int) error
+	CommandOutput  func(name string, args ...string) ([]byte, error)
+	CurrentUser    func() (*user.User, error)
+	DiskUsage      func(path string) (FilesystemUsage, error)
+	Exit           func(code int)
+	FilesystemType func(path string) (string, error)
+	Getenv         func(key string) string
+	Getpid         func() int
+	Glob           func(pattern string) (matches []string, err error)
+	Hostname       func() (string, error)
+	InterfaceAddrs func() ([]net.Addr, error)
+	IsNotExist     func(err error) bool
+	Kill           func(pid int, sig syscall.Signal) error
+	LookPath       func(file string) (string, error)
+	LookupEnv      func(key string) (string, bool)
+	LookupHost     func(host string) (addrs []string, err error)
+	Lstat          func(name string) (os.FileInfo, error)
+	MkdirAll       func(path string, perm os.FileMode) error
+	MkdirTemp      func(dir, pattern string) (string, error)
+	MonotonicNow   func() time.Duration
+	NewTicker      func(d time.Duration) Ticker
+	NewTimer       func(d time.Duration) Timer
+	Now            func() time.Time
+	NotifySignals  func(c chan<- os.Signal, sig ...os.Signal)
+	OpenFileRead   func(name string, flag int, perm os.FileMode) (ReadCloserAt, error)
+	OpenFileWrite  func(name string, flag int, perm os.FileMode) (io.WriteCloser, error)
+	PathLimits     func(dir string) (PathLimits, error)
+	ReadDir        func(name string) ([]os.DirEntry, error)
+	ReadFile       func(filename string) ([]byte, error)
+	Readlink       func(name string) (string, error)
+	Remove         func(name string) error
+	RemoveAll      func(name string) error
+	Rename         func(oldpath, newpath string) error
+	ResetSignals   func(sig ...os.Signal)
+	Setenv         func(key, value string) error
+	Sleep          func(d time.Duration)
+	Stat           func(name string) (os.FileInfo, error)
+	Symlink        func(oldname, newname string) error
+	Stdin          ReadCloserAt
+	Stdout         io.WriteCloser
+	TempFile       func(dir, pattern string) (f *os.File, err error)
+	Unsetenv       func(key string) error
+	Local          *time.Location
 }
 
 func InitializeSystemFunctions() *SystemFunctions {
 	return &SystemFunctions{
-		Chmod:         os.Chmod,
-		CurrentUser:   user.Current,
-		Exit:          os.Exit,
-		Getenv:        os.Getenv,
-		Getpid:        os.Getpid,
-		Glob:          filepath.Glob,
-		Hostname:      os.Hostname,
-		IsNotExist:    os.IsNotExist,
-		MkdirAll:      os.MkdirAll,
-		LookupEnv:     os.LookupEnv,
-		Now:           time.Now,
-		OpenFileRead:  OpenFileRead,
-		OpenFileWrite: OpenFileWrite,
-		ReadFile:      ioutil.ReadFile,
-		Remove:        os.Remove,
-		RemoveAll:     os.RemoveAll,
-		Stat:          os.Stat,
-		Stdin:         os.Stdin,
-		Stdout:        os.Stdout,
-		TempFile:      ioutil.TempFile,
-		Local:         time.Local,
+		Chmod:          os.Chmod,
+		Chown:          os.Chown,
+		CommandOutput:  CommandOutput,
+		CurrentUser:    user.Current,
+		DiskUsage:      DiskUsage,
+		Exit:           os.Exit,
+		FilesystemType: FilesystemType,
+		Getenv:         os.Getenv,
+		Getpid:         os.Getpid,
+		Glob:           filepath.Glob,
+		Hostname:       os.Hostname,
+		InterfaceAddrs: net.InterfaceAddrs,
+		IsNotExist:     os.IsNotExist,
+		Kill:           syscall.Kill,
+		MkdirAll:       os.MkdirAll,
+		LookPath:       exec.LookPath,
+		LookupEnv:      os.LookupEnv,
+		LookupHost:     net.LookupHost,
+		Lstat:          os.Lstat,
+		MkdirTemp:      os.MkdirTemp,
+		MonotonicNow:   MonotonicNow,
+		NewTicker:      NewTicker,
+		NewTimer:       NewTimer,
+		Now:            time.Now,
+		NotifySignals:  signal.Notify,
+		OpenFileRead:   OpenFileRead,
+		OpenFileWrite:  OpenFileWrite,
+		PathLimits:     ProbePathLimits,
+		ReadDir:        os.ReadDir,
+		ReadFile:       ioutil.ReadFile,
+		Readlink:       os.Readlink,
+		Remove:         os.Remove,
+		RemoveAll:      os.RemoveAll,
+		Rename:         os.Rename,
+		ResetSignals:   signal.Reset,
+		Setenv:         os.Setenv,
+		Sleep:          time.Sleep,
+		Stat:           os.Stat,
+		Symlink:        os.Symlink,
+		Stdin:          os.Stdin,
+		Stdout:         os.Stdout,
+		TempFile:       ioutil.TempFile,
+		Unsetenv:       os.Unsetenv,
+		Local:          time.Local,
 	}
 }
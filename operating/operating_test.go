@@ -0,0 +1,82 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package operating_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("operating/operating tests", func() {
+	Describe("InitializeSystemFunctions", func() {
+		var (
+			system  *operating.SystemFunctions
+			testDir string
+		)
+
+		BeforeEach(func() {
+			system = operating.InitializeSystemFunctions()
+			testDir = GinkgoT().TempDir()
+		})
+
+		It("uses os.Symlink and os.Readlink for Symlink and Readlink", func() {
+			link := filepath.Join(testDir, "link")
+			Expect(system.Symlink("target", link)).To(Succeed())
+			Expect(os.Readlink(link)).To(Equal("target"))
+			Expect(system.Readlink(link)).To(Equal("target"))
+		})
+		It("uses os.Rename for Rename", func() {
+			oldPath, newPath := filepath.Join(testDir, "old"), filepath.Join(testDir, "new")
+			Expect(os.WriteFile(oldPath, []byte("contents"), 0644)).To(Succeed())
+			Expect(system.Rename(oldPath, newPath)).To(Succeed())
+			Expect(oldPath).ToNot(BeAnExistingFile())
+			Expect(os.ReadFile(newPath)).To(Equal([]byte("contents")))
+		})
+		It("uses os.Chown for Chown", func() {
+			filename := filepath.Join(testDir, "file")
+			Expect(os.WriteFile(filename, []byte{}, 0644)).To(Succeed())
+			Expect(system.Chown(filename, os.Getuid(), os.Getgid())).To(Succeed())
+			Expect(system.Chown(filepath.Join(testDir, "missing"), os.Getuid(), os.Getgid())).To(MatchError(os.ErrNotExist))
+		})
+		It("uses os.MkdirTemp for MkdirTemp", func() {
+			dir, err := system.MkdirTemp(testDir, "prefix-*")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(filepath.Dir(dir)).To(Equal(testDir))
+			Expect(filepath.Base(dir)).To(HavePrefix("prefix-"))
+			Expect(dir).To(BeADirectory())
+		})
+		It("uses os.Setenv and os.Unsetenv for Setenv and Unsetenv", func() {
+			DeferCleanup(os.Unsetenv, "OPERATING_TEST_VARIABLE")
+			Expect(system.Setenv("OPERATING_TEST_VARIABLE", "value")).To(Succeed())
+			Expect(os.Getenv("OPERATING_TEST_VARIABLE")).To(Equal("value"))
+			Expect(system.Unsetenv("OPERATING_TEST_VARIABLE")).To(Succeed())
+			_, ok := os.LookupEnv("OPERATING_TEST_VARIABLE")
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
+
+var _ = testhelper.FSContractTests("InitializeSystemFunctions", func() (*operating.SystemFunctions, string) {
+	return operating.InitializeSystemFunctions(), GinkgoT().TempDir()
+})
@@ -0,0 +1,113 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package operating
+
+/*
+ * This file contains functions for expanding user-provided paths, such as
+ * those from flags and configuration files, the same way in every utility.
+ */
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/*
+ * ExpandPath expands a leading "~" to the current user's home directory and
+ * replaces $VAR and ${VAR} with the values of those environment variables,
+ * as a shell would for an unquoted path, and cleans the result.  The home
+ * directory is taken from HOME, or from System.CurrentUser if HOME is not
+ * set, and all lookups go through System so they can be mocked.
+ *
+ * Unlike a shell, ExpandPath returns an error rather than substituting an
+ * empty string for an unset variable, so that e.g. "$BACKUP_DIR/data" is not
+ * silently treated as "/data".  The "~user" form is not supported.
+ */
+func ExpandPath(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := homeDirectory()
+		if err != nil {
+			return "", errors.Wrapf(err, "Unable to expand path %s", path)
+		}
+		path = home + path[1:]
+	} else if strings.HasPrefix(path, "~") {
+		return "", errors.Errorf("Unable to expand path %s: only ~ for the current user is supported", path)
+	}
+
+	unset := ""
+	path = os.Expand(path, func(name string) string {
+		value, ok := System.LookupEnv(name)
+		if !ok && unset == "" {
+			unset = name
+		}
+		return value
+	})
+	if unset != "" {
+		return "", errors.Errorf("Unable to expand path: environment variable %s is not set", unset)
+	}
+	return filepath.Clean(path), nil
+}
+
+func homeDirectory() (string, error) {
+	if home, ok := System.LookupEnv("HOME"); ok && home != "" {
+		return home, nil
+	}
+	currentUser, err := System.CurrentUser()
+	if err != nil {
+		return "", errors.Wrap(err, "Unable to determine home directory")
+	}
+	return currentUser.HomeDir, nil
+}
+
+// Abs expands path with ExpandPath and then makes it absolute relative to the current directory
+func Abs(path string) (string, error) {
+	expanded, err := ExpandPath(path)
+	if err != nil {
+		return "", err
+	}
+	if expanded == "" {
+		return "", errors.New("Unable to make an empty path absolute")
+	}
+	return filepath.Abs(expanded)
+}
+
+/*
+ * The Must variants panic with the error, as gplog.Fatal would after logging
+ * it; operating cannot use gplog, which depends on it.
+ */
+func MustExpandPath(path string) string {
+	expanded, err := ExpandPath(path)
+	if err != nil {
+		panic(err.Error())
+	}
+	return expanded
+}
+
+func MustAbs(path string) string {
+	abs, err := Abs(path)
+	if err != nil {
+		panic(err.Error())
+	}
+	return abs
+}
@@ -0,0 +1,131 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package operating_test
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"path/filepath"
+
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("operating/path tests", func() {
+	var env map[string]string
+
+	BeforeEach(func() {
+		env = map[string]string{"HOME": "/home/gpadmin", "BACKUP_DIR": "/data/backups", "LABEL": "nightly", "UNSET_DIR": ""}
+		operating.System.CurrentUser = func() (*user.User, error) {
+			return &user.User{Username: "gpadmin", HomeDir: "/home/fromuser"}, nil
+		}
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("ExpandPath", func() {
+		DescribeTable("expands the path",
+			func(path string, expected string) {
+				operating.WithEnv(env, func() {
+					Expect(operating.ExpandPath(path)).To(Equal(expected))
+				})
+			},
+			Entry("an empty path", "", ""),
+			Entry("a path with nothing to expand", "/data/primary", "/data/primary"),
+			Entry("a relative path", "data/../primary/", "primary"),
+			Entry("the home directory", "~", "/home/gpadmin"),
+			Entry("a path in the home directory", "~/gpAdminLogs/", "/home/gpadmin/gpAdminLogs"),
+			Entry("a $VAR variable", "$BACKUP_DIR/db", "/data/backups/db"),
+			Entry("a ${VAR} variable", "${BACKUP_DIR}/${LABEL}-full", "/data/backups/nightly-full"),
+			Entry("a ~ that does not start the path", "/data/~/$LABEL", "/data/~/nightly"),
+		)
+		It("uses the current user's home directory if HOME is not set", func() {
+			env["HOME"] = ""
+			operating.WithEnv(env, func() {
+				Expect(operating.ExpandPath("~/logs")).To(Equal("/home/fromuser/logs"))
+			})
+		})
+		It("returns an error if the home directory cannot be determined", func() {
+			env["HOME"] = ""
+			operating.System.CurrentUser = func() (*user.User, error) { return nil, errors.New("no such user") }
+			operating.WithEnv(env, func() {
+				_, err := operating.ExpandPath("~/logs")
+				Expect(err).To(MatchError("Unable to expand path ~/logs: Unable to determine home directory: no such user"))
+			})
+		})
+		It("returns an error if a variable is not set", func() {
+			operating.WithEnv(env, func() {
+				_, err := operating.ExpandPath("$BACKUP_DIR/${UNSET_DIR}/db")
+				Expect(err).To(MatchError("Unable to expand path: environment variable UNSET_DIR is not set"))
+			})
+		})
+		It("returns an error for another user's home directory", func() {
+			_, err := operating.ExpandPath("~postgres/data")
+			Expect(err).To(MatchError("Unable to expand path ~postgres/data: only ~ for the current user is supported"))
+		})
+	})
+
+	Describe("Abs", func() {
+		It("makes an expanded relative path absolute", func() {
+			cwd, err := os.Getwd()
+			Expect(err).ToNot(HaveOccurred())
+			operating.WithEnv(env, func() {
+				Expect(operating.Abs("$LABEL/db")).To(Equal(filepath.Join(cwd, "nightly/db")))
+			})
+		})
+		It("expands an absolute path", func() {
+			operating.WithEnv(env, func() {
+				Expect(operating.Abs("~/logs")).To(Equal("/home/gpadmin/logs"))
+			})
+		})
+		It("returns an error for an empty path", func() {
+			_, err := operating.Abs("")
+			Expect(err).To(MatchError("Unable to make an empty path absolute"))
+		})
+		It("returns an error if the path cannot be expanded", func() {
+			operating.WithEnv(env, func() {
+				_, err := operating.Abs("$UNSET_DIR/db")
+				Expect(err).To(MatchError("Unable to expand path: environment variable UNSET_DIR is not set"))
+			})
+		})
+	})
+
+	Describe("MustExpandPath and MustAbs", func() {
+		It("return the path", func() {
+			operating.WithEnv(env, func() {
+				Expect(operating.MustExpandPath("$BACKUP_DIR")).To(Equal("/data/backups"))
+				Expect(operating.MustAbs("/tmp/../$LABEL")).To(Equal("/nightly"))
+			})
+		})
+		It("panic if the path cannot be expanded", func() {
+			defer testhelper.ShouldPanicWithMessage("Unable to expand path: environment variable UNSET_DIR is not set")
+			operating.WithEnv(env, func() {
+				operating.MustAbs("$UNSET_DIR/db")
+			})
+		})
+		It("panic for an empty path", func() {
+			defer testhelper.ShouldPanicWithMessage("Unable to make an empty path absolute")
+			operating.MustAbs("")
+		})
+	})
+})
@@ -0,0 +1,75 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package operating
+
+/*
+ * This file contains functions for finding the limits a filesystem places on
+ * file names, for utilities that name files after database objects, whose
+ * names may differ only in case or be very long.  The platform-specific name
+ * length limits are in filesystem_linux.go and filesystem_darwin.go.
+ */
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/*
+ * PathLimits describes the filesystem containing a directory.  MaxNameLength
+ * is the longest a single path component may be, and MaxPathLength the
+ * longest a whole path may be, both in bytes.
+ */
+type PathLimits struct {
+	CaseSensitive bool
+	MaxNameLength int
+	MaxPathLength int
+}
+
+/*
+ * ProbePathLimits returns the limits of the filesystem containing dir, which
+ * must exist and be writable.  Case sensitivity is found by creating an empty
+ * file in dir and checking whether its name in upper case refers to the same
+ * file, so it is correct for case-insensitive filesystems mounted on Linux.
+ */
+func ProbePathLimits(dir string) (PathLimits, error) {
+	maxName, err := maxNameLength(dir)
+	if err != nil {
+		return PathLimits{}, err
+	}
+	probe, err := os.CreateTemp(dir, ".case_probe_")
+	if err != nil {
+		return PathLimits{}, errors.Wrapf(err, "Unable to check case sensitivity of %s", dir)
+	}
+	probeName := probe.Name()
+	_ = probe.Close()
+	defer os.Remove(probeName)
+
+	probeInfo, err := os.Lstat(probeName)
+	if err != nil {
+		return PathLimits{}, errors.Wrapf(err, "Unable to check case sensitivity of %s", dir)
+	}
+	caseSensitive := true
+	upperInfo, err := os.Lstat(filepath.Join(dir, strings.ToUpper(filepath.Base(probeName))))
+	if err == nil && os.SameFile(probeInfo, upperInfo) {
+		caseSensitive = false
+	}
+	return PathLimits{CaseSensitive: caseSensitive, MaxNameLength: maxName, MaxPathLength: maxPathLength}, nil
+}
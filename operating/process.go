@@ -0,0 +1,112 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package operating
+
+/*
+ * This file contains functions for finding, signaling, and waiting for
+ * processes such as helper daemons, using the function pointers in System so
+ * that they can be mocked.
+ */
+
+import (
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// How often WaitWithTimeout checks whether the process has exited
+var ProcessPollInterval = 100 * time.Millisecond
+
+/*
+ * ReadPidfile returns the process ID from the first line of a pidfile, which
+ * is the format used by both postmaster.pid and typical daemon pidfiles.
+ */
+func ReadPidfile(path string) (int, error) {
+	contents, err := System.ReadFile(path)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Unable to read pidfile %s", path)
+	}
+	firstLine := strings.TrimSpace(strings.SplitN(string(contents), "\n", 2)[0])
+	pid, err := strconv.Atoi(firstLine)
+	if err != nil || pid <= 0 {
+		return 0, errors.Errorf("Pidfile %s does not contain a valid process ID: %q", path, firstLine)
+	}
+	return pid, nil
+}
+
+/*
+ * FindProcessByPidfile returns the process ID from the given pidfile if that
+ * process is running.  If the process is not running, as when a daemon was
+ * killed without removing its pidfile, it returns 0 and no error.
+ */
+func FindProcessByPidfile(path string) (int, error) {
+	pid, err := ReadPidfile(path)
+	if err != nil {
+		return 0, err
+	}
+	if !IsProcessRunning(pid) {
+		return 0, nil
+	}
+	return pid, nil
+}
+
+/*
+ * IsProcessRunning returns true if a process with the given ID exists, even
+ * if it belongs to another user.  A process that has exited but has not yet
+ * been waited on by its parent is still considered to be running.
+ */
+func IsProcessRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := System.Kill(pid, syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+func SignalProcess(pid int, signal syscall.Signal) error {
+	if pid <= 0 {
+		return errors.Errorf("Invalid process ID %d", pid)
+	}
+	if err := System.Kill(pid, signal); err != nil {
+		return errors.Wrapf(err, "Unable to send signal %s to process %d", signal, pid)
+	}
+	return nil
+}
+
+/*
+ * WaitWithTimeout waits for the process with the given ID to exit, checking
+ * every ProcessPollInterval, and returns an error if it is still running
+ * after timeout.
+ */
+func WaitWithTimeout(pid int, timeout time.Duration) error {
+	deadline := System.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := System.NewTicker(ProcessPollInterval)
+	defer ticker.Stop()
+	for IsProcessRunning(pid) {
+		select {
+		case <-deadline.C():
+			return errors.Errorf("Timed out after %s waiting for process %d to exit", timeout, pid)
+		case <-ticker.C():
+		}
+	}
+	return nil
+}
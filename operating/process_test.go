@@ -0,0 +1,163 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package operating_test
+
+import (
+	"os"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("operating/process tests", func() {
+	var (
+		running     atomic.Bool
+		killedPid   int
+		killedWith  syscall.Signal
+		pidfileData string
+		pidfileErr  error
+	)
+
+	BeforeEach(func() {
+		running.Store(true)
+		killedPid, killedWith = 0, 0
+		pidfileData, pidfileErr = "1234\n/data/coordinator/gpseg-1\n", nil
+		operating.System.ReadFile = func(string) ([]byte, error) {
+			return []byte(pidfileData), pidfileErr
+		}
+		operating.System.Kill = func(pid int, signal syscall.Signal) error {
+			if signal != 0 {
+				killedPid, killedWith = pid, signal
+			}
+			if running.Load() {
+				return nil
+			}
+			return syscall.ESRCH
+		}
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("FindProcessByPidfile", func() {
+		It("returns the process ID from the first line of the pidfile if the process is running", func() {
+			Expect(operating.FindProcessByPidfile("postmaster.pid")).To(Equal(1234))
+		})
+		It("returns 0 and no error if the process is not running", func() {
+			running.Store(false)
+			Expect(operating.FindProcessByPidfile("postmaster.pid")).To(Equal(0))
+		})
+		It("returns an error if the pidfile is missing", func() {
+			pidfileErr = os.ErrNotExist
+			_, err := operating.FindProcessByPidfile("postmaster.pid")
+			Expect(err).To(MatchError(ContainSubstring("Unable to read pidfile postmaster.pid")))
+		})
+		DescribeTable("returns an error if the pidfile does not start with a process ID",
+			func(contents string) {
+				pidfileData = contents
+				_, err := operating.FindProcessByPidfile("postmaster.pid")
+				Expect(err).To(MatchError(ContainSubstring("Pidfile postmaster.pid does not contain a valid process ID")))
+			},
+			Entry("an empty file", ""),
+			Entry("text", "not a pid\n1234\n"),
+			Entry("a negative number", "-1234\n"),
+			Entry("zero", "0\n"),
+		)
+	})
+
+	Describe("IsProcessRunning", func() {
+		It("returns true if the process can be signaled", func() {
+			Expect(operating.IsProcessRunning(1234)).To(BeTrue())
+		})
+		It("returns true if the process belongs to another user", func() {
+			operating.System.Kill = func(int, syscall.Signal) error { return syscall.EPERM }
+			Expect(operating.IsProcessRunning(1234)).To(BeTrue())
+		})
+		It("returns false if there is no such process", func() {
+			running.Store(false)
+			Expect(operating.IsProcessRunning(1234)).To(BeFalse())
+		})
+		It("returns false for an invalid process ID without signaling anything", func() {
+			operating.System.Kill = func(int, syscall.Signal) error {
+				Fail("Kill should not be called")
+				return nil
+			}
+			Expect(operating.IsProcessRunning(0)).To(BeFalse())
+			Expect(operating.IsProcessRunning(-1)).To(BeFalse())
+		})
+	})
+
+	Describe("SignalProcess", func() {
+		It("sends the signal to the process", func() {
+			Expect(operating.SignalProcess(1234, syscall.SIGTERM)).To(Succeed())
+			Expect(killedPid).To(Equal(1234))
+			Expect(killedWith).To(Equal(syscall.SIGTERM))
+		})
+		It("returns an error if the process cannot be signaled", func() {
+			running.Store(false)
+			err := operating.SignalProcess(1234, syscall.SIGTERM)
+			Expect(err).To(MatchError(ContainSubstring("Unable to send signal terminated to process 1234")))
+		})
+		It("returns an error for an invalid process ID", func() {
+			Expect(operating.SignalProcess(0, syscall.SIGTERM)).To(MatchError("Invalid process ID 0"))
+			Expect(killedPid).To(Equal(0))
+		})
+	})
+
+	Describe("WaitWithTimeout", func() {
+		var clock *testhelper.FakeClock
+
+		BeforeEach(func() {
+			clock = testhelper.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			clock.Install()
+		})
+
+		It("returns immediately if the process is not running", func() {
+			running.Store(false)
+			Expect(operating.WaitWithTimeout(1234, time.Minute)).To(Succeed())
+		})
+		It("returns once the process exits", func() {
+			done := make(chan error, 1)
+			go func() {
+				done <- operating.WaitWithTimeout(1234, time.Minute)
+			}()
+			clock.BlockUntil(2)
+			clock.Advance(operating.ProcessPollInterval)
+			Consistently(done).ShouldNot(Receive())
+
+			running.Store(false)
+			clock.Advance(operating.ProcessPollInterval)
+			Eventually(done).Should(Receive(BeNil()))
+		})
+		It("returns an error if the process is still running after the timeout", func() {
+			done := make(chan error, 1)
+			go func() {
+				done <- operating.WaitWithTimeout(1234, time.Minute)
+			}()
+			clock.BlockUntil(2)
+			clock.Advance(time.Minute)
+			Eventually(done).Should(Receive(MatchError("Timed out after 1m0s waiting for process 1234 to exit")))
+		})
+	})
+})
@@ -0,0 +1,148 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package operating
+
+/*
+ * This file contains structs and functions for cleaning up consistently when
+ * a utility is interrupted, so that each utility does not need to wire up its
+ * own signal handling.
+ */
+
+import (
+	"context"
+	"os"
+	"sync"
+	"syscall"
+)
+
+/*
+ * A SignalCleanup runs a chain of cleanup steps when the process receives one
+ * of the signals it was installed for, and then exits with 128 plus the signal
+ * number, as a shell would report it.  The steps run in this order:
+ * 1. Every registered context is canceled, so that running work stops.
+ * 2. Every cleanup function is run, in the order they were added.
+ * 3. Every flush function is run, in the order they were added, so that
+ *    anything logged by the cleanup functions is written out.
+ * A panic in one step is recovered so that the remaining steps still run.
+ *
+ * Run may also be called directly, e.g. from a deferred function in main, to
+ * run the same chain when exiting normally; the chain only ever runs once.
+ * gplog.InstallSignalCleanup creates a SignalCleanup that logs the signal and
+ * flushes the log file, and should be used by most utilities.
+ */
+type SignalCleanup struct {
+	mutex    sync.Mutex
+	cancels  []context.CancelFunc
+	cleanups []func()
+	flushes  []func()
+	once     sync.Once
+	signals  []os.Signal
+	received chan os.Signal
+	done     chan struct{}
+	// OnSignal, if set, is called with the received signal before the chain runs
+	OnSignal func(sig os.Signal)
+}
+
+func NewSignalCleanup() *SignalCleanup {
+	return &SignalCleanup{
+		cancels:  make([]context.CancelFunc, 0),
+		cleanups: make([]func(), 0),
+		flushes:  make([]func(), 0),
+	}
+}
+
+func (chain *SignalCleanup) AddCancel(cancel context.CancelFunc) {
+	chain.mutex.Lock()
+	defer chain.mutex.Unlock()
+	chain.cancels = append(chain.cancels, cancel)
+}
+
+func (chain *SignalCleanup) AddCleanup(cleanup func()) {
+	chain.mutex.Lock()
+	defer chain.mutex.Unlock()
+	chain.cleanups = append(chain.cleanups, cleanup)
+}
+
+func (chain *SignalCleanup) AddFlush(flush func()) {
+	chain.mutex.Lock()
+	defer chain.mutex.Unlock()
+	chain.flushes = append(chain.flushes, flush)
+}
+
+/*
+ * Install starts handling the given signals, or SIGINT and SIGTERM if none are
+ * given, until Stop is called.
+ */
+func (chain *SignalCleanup) Install(signals ...os.Signal) {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+	chain.signals = signals
+	chain.received = make(chan os.Signal, 1)
+	chain.done = make(chan struct{})
+	System.NotifySignals(chain.received, signals...)
+	go func(received chan os.Signal, done chan struct{}) {
+		select {
+		case sig := <-received:
+			if chain.OnSignal != nil {
+				chain.OnSignal(sig)
+			}
+			chain.Run()
+			exitCode := 1
+			if signum, ok := sig.(syscall.Signal); ok {
+				exitCode = 128 + int(signum)
+			}
+			System.Exit(exitCode)
+		case <-done:
+		}
+	}(chain.received, chain.done)
+}
+
+// Stop restores the default handling of the installed signals
+func (chain *SignalCleanup) Stop() {
+	if chain.done == nil {
+		return
+	}
+	System.ResetSignals(chain.signals...)
+	close(chain.done)
+	chain.done = nil
+}
+
+// Run runs the cleanup chain, if it has not already been run
+func (chain *SignalCleanup) Run() {
+	chain.once.Do(func() {
+		chain.mutex.Lock()
+		steps := make([]func(), 0, len(chain.cancels)+len(chain.cleanups)+len(chain.flushes))
+		for _, cancel := range chain.cancels {
+			steps = append(steps, cancel)
+		}
+		steps = append(steps, chain.cleanups...)
+		steps = append(steps, chain.flushes...)
+		chain.mutex.Unlock()
+		for _, step := range steps {
+			runCleanupStep(step)
+		}
+	})
+}
+
+func runCleanupStep(step func()) {
+	defer func() {
+		_ = recover()
+	}()
+	step()
+}
@@ -0,0 +1,241 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package prompt
+
+/*
+ * This file contains functions for asking the user to confirm an operation,
+ * enter a password, or choose between options, that behave the same way in
+ * every utility and can be answered automatically with --yes or in scripts.
+ *
+ * Prompts are written to operating.System.Stdout and answers are read from
+ * operating.System.Stdin, so tests can supply answers by replacing them.
+ */
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+type Mode int
+
+const (
+	// INTERACTIVE prompts the user and waits for an answer
+	INTERACTIVE Mode = iota
+	// ASSUME_YES answers yes to every confirmation, as for a --yes flag
+	ASSUME_YES
+	// NON_INTERACTIVE never prompts, taking the default answer to every confirmation
+	NON_INTERACTIVE
+)
+
+func (mode Mode) String() string {
+	switch mode {
+	case ASSUME_YES:
+		return "assume yes"
+	case NON_INTERACTIVE:
+		return "non-interactive"
+	default:
+		return "interactive"
+	}
+}
+
+var mode = INTERACTIVE
+
+/*
+ * SetMode sets how every later prompt is answered, and should be called once
+ * after parsing flags, e.g. with ASSUME_YES if --yes was given.  Password and
+ * Choose have no answer to assume, so they return an error in either
+ * automatic mode.
+ */
+func SetMode(newMode Mode) {
+	mode = newMode
+}
+
+func GetMode() Mode {
+	return mode
+}
+
+// How many times Confirm and Choose ask again after an answer they do not understand
+const MAX_INVALID_ANSWERS = 3
+
+/*
+ * Confirm asks the user a yes-or-no question, such as "Delete segment data
+ * directories?", and returns true if they answer yes.  An empty answer takes
+ * the default, which is no if defaultNo is set and yes otherwise; so does
+ * reaching the end of input, or too many answers that are not yes or no.  The
+ * question and the decision are logged.
+ */
+func Confirm(message string, defaultNo bool) bool {
+	choices, defaultAnswer := "[Y/n]", true
+	if defaultNo {
+		choices, defaultAnswer = "[y/N]", false
+	}
+	switch mode {
+	case ASSUME_YES:
+		gplog.Info("%s %s: yes (assumed, as prompts are answered yes)", message, choices)
+		return true
+	case NON_INTERACTIVE:
+		gplog.Info("%s %s: %s (default, as prompts are disabled)", message, choices, yesOrNo(defaultAnswer))
+		return defaultAnswer
+	}
+
+	for attempt := 0; attempt <= MAX_INVALID_ANSWERS; attempt++ {
+		fmt.Fprintf(operating.System.Stdout, "%s %s: ", message, choices)
+		answer, err := readLine()
+		if err != nil {
+			fmt.Fprintln(operating.System.Stdout)
+			gplog.Warn("%s %s: %s (default, as no answer could be read: %v)", message, choices, yesOrNo(defaultAnswer), err)
+			return defaultAnswer
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "":
+			logAnswer("%s %s: %s (default)", message, choices, yesOrNo(defaultAnswer))
+			return defaultAnswer
+		case "y", "yes":
+			logAnswer("%s %s: yes", message, choices)
+			return true
+		case "n", "no":
+			logAnswer("%s %s: no", message, choices)
+			return false
+		}
+		fmt.Fprintln(operating.System.Stdout, "Please answer yes or no.")
+	}
+	gplog.Warn("%s %s: %s (default, as no valid answer was given)", message, choices, yesOrNo(defaultAnswer))
+	return defaultAnswer
+}
+
+/*
+ * Password asks the user for a password, without echoing it if input is from
+ * a terminal.  The password itself is never logged.
+ */
+func Password(message string) (string, error) {
+	if mode != INTERACTIVE {
+		return "", errors.Errorf("Unable to prompt for %s: prompts are disabled (%s mode)", message, mode)
+	}
+	fmt.Fprintf(operating.System.Stdout, "%s: ", message)
+	if stdin, ok := operating.System.Stdin.(*os.File); ok {
+		if restore, err := disableEcho(stdin); err == nil {
+			defer restore()
+			defer fmt.Fprintln(operating.System.Stdout)
+		}
+	}
+	password, err := readLine()
+	if err != nil {
+		return "", errors.Wrapf(err, "Unable to read %s", message)
+	}
+	logAnswer("%s: entered", message)
+	return password, nil
+}
+
+/*
+ * Choose asks the user to choose one of options, listed with numbers starting
+ * from 1, and returns the index of the chosen option.  The user may answer
+ * with either the number or the option itself.
+ */
+func Choose(message string, options []string) (int, error) {
+	if len(options) == 0 {
+		return 0, errors.Errorf("Unable to prompt for %s: there are no options", message)
+	} else if mode != INTERACTIVE {
+		return 0, errors.Errorf("Unable to prompt for %s: prompts are disabled (%s mode)", message, mode)
+	}
+	for attempt := 0; attempt <= MAX_INVALID_ANSWERS; attempt++ {
+		fmt.Fprintln(operating.System.Stdout, message)
+		for i, option := range options {
+			fmt.Fprintf(operating.System.Stdout, "  %d) %s\n", i+1, option)
+		}
+		fmt.Fprintf(operating.System.Stdout, "Enter a number from 1 to %d: ", len(options))
+		answer, err := readLine()
+		if err != nil {
+			fmt.Fprintln(operating.System.Stdout)
+			return 0, errors.Wrapf(err, "Unable to read answer to %s", message)
+		}
+		answer = strings.TrimSpace(answer)
+		if number, err := strconv.Atoi(answer); err == nil && number >= 1 && number <= len(options) {
+			logAnswer("%s: %s", message, options[number-1])
+			return number - 1, nil
+		}
+		for i, option := range options {
+			if answer != "" && answer == option {
+				logAnswer("%s: %s", message, option)
+				return i, nil
+			}
+		}
+		fmt.Fprintf(operating.System.Stdout, "%q is not one of the options.\n", answer)
+	}
+	return 0, errors.Errorf("Unable to prompt for %s: no valid answer was given", message)
+}
+
+func yesOrNo(answer bool) string {
+	if answer {
+		return "yes"
+	}
+	return "no"
+}
+
+// logAnswer logs an answer the user typed, which they have already seen, to the log file only
+func logAnswer(s string, v ...interface{}) {
+	gplog.Custom(gplog.LOGINFO, gplog.LOGDEBUG, s, v...)
+}
+
+/*
+ * readLine reads up to the end of the line a byte at a time, rather than
+ * through a bufio.Reader, so that nothing after the line is consumed from
+ * Stdin before the next prompt or other reader.
+ */
+func readLine() (string, error) {
+	var line []byte
+	buffer := make([]byte, 1)
+	for {
+		n, err := operating.System.Stdin.Read(buffer)
+		if n > 0 {
+			if buffer[0] == '\n' {
+				break
+			}
+			line = append(line, buffer[0])
+		}
+		if err == io.EOF && len(line) > 0 {
+			break
+		} else if err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimSuffix(string(line), "\r"), nil
+}
+
+// disableEcho turns off echo on a terminal, returning an error if the file is not a terminal
+func disableEcho(file *os.File) (func(), error) {
+	fd := int(file.Fd())
+	termios, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return nil, err
+	}
+	noEcho := *termios
+	noEcho.Lflag &^= unix.ECHO
+	noEcho.Lflag |= unix.ICANON | unix.ISIG
+	if err := unix.IoctlSetTermios(fd, ioctlSetTermios, &noEcho); err != nil {
+		return nil, err
+	}
+	return func() { _ = unix.IoctlSetTermios(fd, ioctlSetTermios, termios) }, nil
+}
@@ -0,0 +1,172 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package prompt_test
+
+import (
+	"io"
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/prompt"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type answers struct {
+	*strings.Reader
+}
+
+func (answers) Close() error { return nil }
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+var _ = Describe("prompt tests", func() {
+	var (
+		stdin       *strings.Reader
+		output      *gbytes.Buffer
+		savedLogger *gplog.GpLogger
+		testStdout  *gbytes.Buffer
+		testLogfile *gbytes.Buffer
+	)
+	answer := func(input string) {
+		stdin = strings.NewReader(input)
+		operating.System.Stdin = answers{stdin}
+	}
+
+	BeforeEach(func() {
+		savedLogger = gplog.GetLogger()
+		testStdout, _, testLogfile = testhelper.SetupTestLogger()
+		output = gbytes.NewBuffer()
+		operating.System.Stdout = nopCloser{output}
+		answer("")
+	})
+	AfterEach(func() {
+		gplog.SetLogger(savedLogger)
+		operating.System = operating.InitializeSystemFunctions()
+		prompt.SetMode(prompt.INTERACTIVE)
+	})
+
+	Describe("Confirm", func() {
+		It("returns the user's answer", func() {
+			answer("y\nNO\nYes\n")
+			Expect(prompt.Confirm("Delete segment data directories?", true)).To(BeTrue())
+			Expect(prompt.Confirm("Delete segment data directories?", false)).To(BeFalse())
+			Expect(prompt.Confirm("Delete segment data directories?", true)).To(BeTrue())
+			Expect(output).To(gbytes.Say(`Delete segment data directories\? \[y/N\]: Delete segment data directories\? \[Y/n\]: `))
+			Expect(testLogfile).To(gbytes.Say(`\[INFO\]:-Delete segment data directories\? \[y/N\]: yes`))
+			Expect(testLogfile).To(gbytes.Say(`\[INFO\]:-Delete segment data directories\? \[Y/n\]: no`))
+			Expect(testStdout).ToNot(gbytes.Say("Delete"))
+		})
+		It("takes the default for an empty answer", func() {
+			answer("\n\r\n")
+			Expect(prompt.Confirm("Continue?", true)).To(BeFalse())
+			Expect(prompt.Confirm("Continue?", false)).To(BeTrue())
+			Expect(testLogfile).To(gbytes.Say(`Continue\? \[y/N\]: no \(default\)`))
+		})
+		It("asks again after an answer that is not yes or no", func() {
+			answer("maybe\ny\n")
+			Expect(prompt.Confirm("Continue?", true)).To(BeTrue())
+			Expect(output).To(gbytes.Say(`Continue\? \[y/N\]: Please answer yes or no.\nContinue\? \[y/N\]: `))
+		})
+		It("takes the default after too many invalid answers", func() {
+			answer(strings.Repeat("maybe\n", prompt.MAX_INVALID_ANSWERS+1) + "y\n")
+			Expect(prompt.Confirm("Continue?", true)).To(BeFalse())
+			Expect(testLogfile).To(gbytes.Say(`\[WARNING\]:-Continue\? \[y/N\]: no \(default, as no valid answer was given\)`))
+		})
+		It("takes the default at the end of input", func() {
+			Expect(prompt.Confirm("Continue?", true)).To(BeFalse())
+			Expect(testLogfile).To(gbytes.Say(`\[WARNING\]:-Continue\? \[y/N\]: no \(default, as no answer could be read: EOF\)`))
+		})
+		It("accepts a final answer without a newline", func() {
+			answer("yes")
+			Expect(prompt.Confirm("Continue?", true)).To(BeTrue())
+		})
+		It("answers yes without prompting in ASSUME_YES mode", func() {
+			prompt.SetMode(prompt.ASSUME_YES)
+			Expect(prompt.Confirm("Delete segment data directories?", true)).To(BeTrue())
+			Expect(output.Contents()).To(BeEmpty())
+			Expect(testStdout).To(gbytes.Say(`Delete segment data directories\? \[y/N\]: yes \(assumed, as prompts are answered yes\)`))
+		})
+		It("takes the default without prompting in NON_INTERACTIVE mode", func() {
+			prompt.SetMode(prompt.NON_INTERACTIVE)
+			Expect(prompt.Confirm("Delete segment data directories?", true)).To(BeFalse())
+			Expect(prompt.Confirm("Continue?", false)).To(BeTrue())
+			Expect(output.Contents()).To(BeEmpty())
+			Expect(testStdout).To(gbytes.Say(`Delete segment data directories\? \[y/N\]: no \(default, as prompts are disabled\)`))
+		})
+		It("does not read past the answer", func() {
+			answer("y\nremaining input\n")
+			prompt.Confirm("Continue?", true)
+			Expect(stdin.Len()).To(Equal(len("remaining input\n")))
+		})
+	})
+	Describe("Password", func() {
+		It("reads a password without logging it", func() {
+			answer("hunter2\n")
+			password, err := prompt.Password("Password for gpadmin")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(password).To(Equal("hunter2"))
+			Expect(output).To(gbytes.Say("Password for gpadmin: "))
+			Expect(testLogfile).To(gbytes.Say("Password for gpadmin: entered"))
+			Expect(testLogfile).ToNot(gbytes.Say("hunter2"))
+		})
+		It("returns an error at the end of input", func() {
+			_, err := prompt.Password("Password for gpadmin")
+			Expect(err).To(MatchError("Unable to read Password for gpadmin: EOF"))
+		})
+		It("returns an error if prompts are disabled", func() {
+			prompt.SetMode(prompt.ASSUME_YES)
+			_, err := prompt.Password("Password for gpadmin")
+			Expect(err).To(MatchError("Unable to prompt for Password for gpadmin: prompts are disabled (assume yes mode)"))
+		})
+	})
+	Describe("Choose", func() {
+		options := []string{"sdw1", "sdw2", "sdw3"}
+
+		It("returns the index of the option chosen by number or by name", func() {
+			answer("2\nsdw3\n")
+			Expect(prompt.Choose("Which host should be replaced?", options)).To(Equal(1))
+			Expect(prompt.Choose("Which host should be replaced?", options)).To(Equal(2))
+			Expect(output).To(gbytes.Say("Which host should be replaced\\?\n  1\\) sdw1\n  2\\) sdw2\n  3\\) sdw3\nEnter a number from 1 to 3: "))
+			Expect(testLogfile).To(gbytes.Say(`Which host should be replaced\?: sdw2`))
+		})
+		It("asks again after an invalid answer", func() {
+			answer("4\n1\n")
+			Expect(prompt.Choose("Which host should be replaced?", options)).To(Equal(0))
+			Expect(output).To(gbytes.Say(`"4" is not one of the options.`))
+		})
+		It("returns an error after too many invalid answers", func() {
+			answer(strings.Repeat("sdw4\n", prompt.MAX_INVALID_ANSWERS+1))
+			_, err := prompt.Choose("Which host should be replaced?", options)
+			Expect(err).To(MatchError("Unable to prompt for Which host should be replaced?: no valid answer was given"))
+		})
+		It("returns an error if prompts are disabled", func() {
+			prompt.SetMode(prompt.NON_INTERACTIVE)
+			_, err := prompt.Choose("Which host should be replaced?", options)
+			Expect(err).To(MatchError(ContainSubstring("prompts are disabled (non-interactive mode)")))
+		})
+	})
+})
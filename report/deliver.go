@@ -0,0 +1,199 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package report
+
+/*
+ * This file contains functions for publishing a finished report: writing it
+ * to files and sending it by email or to a webhook.
+ */
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+const DEFAULT_WEBHOOK_TIMEOUT = 30 * time.Second
+
+/*
+ * EmailOptions controls sending a report by email with the local sendmail.
+ * None of the fields may contain a line break, which would let the value add
+ * headers of its own to the message.
+ *
+ * To:      The addresses to send the report to.
+ * From:    The sender's address; if unset, sendmail's default is used.
+ * Subject: The subject line; defaults to naming the utility, the host, and
+ *          the report's status.
+ */
+type EmailOptions struct {
+	To      []string
+	From    string
+	Subject string
+}
+
+/*
+ * WebhookOptions controls posting a report's JSON to a webhook.
+ *
+ * URL:     The address to post to.
+ * Headers: Extra request headers, e.g. for authorization.
+ * Timeout: How long to wait for the webhook to respond; defaults to
+ *          DEFAULT_WEBHOOK_TIMEOUT.
+ */
+type WebhookOptions struct {
+	URL     string
+	Headers map[string]string
+	Timeout time.Duration
+}
+
+/*
+ * PublishOptions controls where Publish sends a report.  Each field is
+ * optional, and a report is only sent where its field is set.
+ */
+type PublishOptions struct {
+	TextFile string
+	JSONFile string
+	Email    *EmailOptions
+	Webhook  *WebhookOptions
+}
+
+// emailMessage formats the report as a message for sendmail -t, returning an error if a header value contains a line break
+func (report *Report) emailMessage(options EmailOptions) (string, error) {
+	subject := options.Subject
+	if subject == "" {
+		hostname, _ := operating.System.Hostname()
+		subject = fmt.Sprintf("%s on %s: %s", report.Utility, hostname, report.Status())
+	}
+	headers := map[string][]string{"sender": {options.From}, "recipient": options.To, "subject": {subject}}
+	for _, name := range []string{"sender", "recipient", "subject"} {
+		for _, value := range headers[name] {
+			if strings.ContainsAny(value, "\r\n") {
+				return "", errors.Errorf("The %s %q contains a line break", name, value)
+			}
+		}
+	}
+	var message strings.Builder
+	if options.From != "" {
+		fmt.Fprintf(&message, "From: %s\n", options.From)
+	}
+	fmt.Fprintf(&message, "To: %s\n", strings.Join(options.To, ", "))
+	fmt.Fprintf(&message, "Subject: %s\n", subject)
+	message.WriteString("Content-Type: text/plain; charset=UTF-8\n\n")
+	message.WriteString(report.Text())
+	return message.String(), nil
+}
+
+/*
+ * Email sends the report's Text to the addresses in options by passing it
+ * to "sendmail -t", which must be installed and configured on this host.
+ */
+func (report *Report) Email(options EmailOptions) error {
+	if len(options.To) == 0 {
+		return errors.New("Unable to email report: no recipients given")
+	}
+	message, err := report.emailMessage(options)
+	if err != nil {
+		return errors.Wrap(err, "Unable to email report")
+	}
+	pattern := strings.ReplaceAll(report.Utility, string(os.PathSeparator), "_") + "_report_*.eml"
+	messageFile, err := operating.System.TempFile("", pattern)
+	if err != nil {
+		return errors.Wrap(err, "Unable to email report")
+	}
+	defer func() { _ = operating.System.Remove(messageFile.Name()) }()
+	_, err = messageFile.WriteString(message)
+	if closeErr := messageFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrap(err, "Unable to email report")
+	}
+	output, err := operating.System.CommandOutput("sh", "-c", `sendmail -t -i < "$1"`, "sh", messageFile.Name())
+	if err != nil {
+		return errors.Wrapf(err, "Unable to email report: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// PostWebhook posts the report's JSON to the webhook in options, which must respond with a 2xx status
+func (report *Report) PostWebhook(options WebhookOptions) error {
+	contents, err := report.JSON()
+	if err != nil {
+		return errors.Wrap(err, "Unable to post report")
+	}
+	request, err := http.NewRequest(http.MethodPost, options.URL, bytes.NewReader(contents))
+	if err != nil {
+		return errors.Wrap(err, "Unable to post report")
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for name, value := range options.Headers {
+		request.Header.Set(name, value)
+	}
+	timeout := options.Timeout
+	if timeout <= 0 {
+		timeout = DEFAULT_WEBHOOK_TIMEOUT
+	}
+	response, err := (&http.Client{Timeout: timeout}).Do(request)
+	if err != nil {
+		return errors.Wrap(err, "Unable to post report")
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return errors.Errorf("Unable to post report to %s: %s: %s", options.URL, response.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+/*
+ * Publish finishes the report and sends it everywhere options says to.  An
+ * error writing either file is returned, as the report is then lost, but
+ * failing to email or post it is only logged as a warning so that a mail or
+ * network problem does not fail an otherwise successful run.
+ */
+func (report *Report) Publish(options PublishOptions) error {
+	report.Finish()
+	if options.TextFile != "" {
+		if err := report.WriteText(options.TextFile); err != nil {
+			return err
+		}
+	}
+	if options.JSONFile != "" {
+		if err := report.WriteJSON(options.JSONFile); err != nil {
+			return err
+		}
+	}
+	if options.Email != nil {
+		if err := report.Email(*options.Email); err != nil {
+			gplog.Warn("%v", err)
+		}
+	}
+	if options.Webhook != nil {
+		if err := report.PostWebhook(*options.Webhook); err != nil {
+			gplog.Warn("%v", err)
+		}
+	}
+	return nil
+}
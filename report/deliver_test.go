@@ -0,0 +1,175 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package report_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/report"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("report/deliver tests", func() {
+	var (
+		run        *report.Report
+		sentMail   string
+		sendmailOK bool
+	)
+
+	BeforeEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		testhelper.NewFakeClock(time.Date(2024, time.January, 1, 10, 0, 0, 0, time.Local)).Install()
+		operating.System.Hostname = func() (string, error) { return "cdw", nil }
+		sentMail, sendmailOK = "", true
+		operating.System.CommandOutput = func(name string, args ...string) ([]byte, error) {
+			Expect(name).To(Equal("sh"))
+			Expect(args[1]).To(ContainSubstring("sendmail -t"))
+			contents, err := os.ReadFile(args[len(args)-1])
+			Expect(err).ToNot(HaveOccurred())
+			sentMail = string(contents)
+			if !sendmailOK {
+				return []byte("sendmail: command not found\n"), errors.New("exit status 127")
+			}
+			return nil, nil
+		}
+		run = report.New("gpbackup", "1.30.0")
+		run.AddWarning("Table public.foo is empty")
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("Email", func() {
+		It("sends the text of the report to each recipient", func() {
+			err := run.Email(report.EmailOptions{To: []string{"dba@example.com", "ops@example.com"}, From: "gpadmin@example.com"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sentMail).To(HavePrefix("From: gpadmin@example.com\nTo: dba@example.com, ops@example.com\nSubject: gpbackup on cdw: Success with warnings\n"))
+			Expect(sentMail).To(HaveSuffix("\n\n" + run.Text()))
+		})
+		It("uses the subject given", func() {
+			Expect(run.Email(report.EmailOptions{To: []string{"dba@example.com"}, Subject: "Nightly backup"})).To(Succeed())
+			Expect(sentMail).To(HavePrefix("To: dba@example.com\nSubject: Nightly backup\n"))
+		})
+		It("returns sendmail's output if it fails", func() {
+			sendmailOK = false
+			err := run.Email(report.EmailOptions{To: []string{"dba@example.com"}})
+			Expect(err).To(MatchError("Unable to email report: sendmail: command not found: exit status 127"))
+		})
+		DescribeTable("refuses header values containing a line break",
+			func(options report.EmailOptions, message string) {
+				Expect(run.Email(options)).To(MatchError("Unable to email report: " + message))
+				Expect(sentMail).To(BeEmpty())
+			},
+			Entry("in a recipient", report.EmailOptions{To: []string{"dba@example.com\nBcc: everyone@example.com"}}, `The recipient "dba@example.com\nBcc: everyone@example.com" contains a line break`),
+			Entry("in the sender", report.EmailOptions{To: []string{"dba@example.com"}, From: "gpadmin@example.com\r\nBcc: everyone@example.com"}, `The sender "gpadmin@example.com\r\nBcc: everyone@example.com" contains a line break`),
+			Entry("in the subject", report.EmailOptions{To: []string{"dba@example.com"}, Subject: "Nightly\nBcc: everyone@example.com"}, `The subject "Nightly\nBcc: everyone@example.com" contains a line break`),
+		)
+		It("creates the message file with operating.System.TempFile even if the utility name contains a slash", func() {
+			var patterns []string
+			operating.System.TempFile = func(dir string, pattern string) (*os.File, error) {
+				patterns = append(patterns, pattern)
+				return os.CreateTemp(dir, pattern)
+			}
+			run.Utility = "tools/gpbackup"
+			Expect(run.Email(report.EmailOptions{To: []string{"dba@example.com"}})).To(Succeed())
+			Expect(patterns).To(Equal([]string{"tools_gpbackup_report_*.eml"}))
+			Expect(sentMail).To(ContainSubstring("Subject: tools/gpbackup on cdw"))
+		})
+		It("returns an error if there are no recipients", func() {
+			Expect(run.Email(report.EmailOptions{})).To(MatchError("Unable to email report: no recipients given"))
+			Expect(sentMail).To(BeEmpty())
+		})
+	})
+	Describe("PostWebhook", func() {
+		It("posts the JSON of the report with the headers given", func() {
+			var body []byte
+			var header http.Header
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				body, _ = io.ReadAll(request.Body)
+				header = request.Header
+			}))
+			defer server.Close()
+			err := run.PostWebhook(report.WebhookOptions{URL: server.URL, Headers: map[string]string{"Authorization": "Bearer token"}})
+			Expect(err).ToNot(HaveOccurred())
+			contents, err := run.JSON()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(body).To(Equal(contents))
+			Expect(header.Get("Content-Type")).To(Equal("application/json"))
+			Expect(header.Get("Authorization")).To(Equal("Bearer token"))
+		})
+		It("returns an error if the webhook does not accept the report", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				http.Error(writer, "bad token", http.StatusUnauthorized)
+			}))
+			defer server.Close()
+			err := run.PostWebhook(report.WebhookOptions{URL: server.URL})
+			Expect(err).To(MatchError(MatchRegexp(`^Unable to post report to http://.*: 401 Unauthorized: bad token$`)))
+		})
+	})
+	Describe("Publish", func() {
+		var (
+			dir    string
+			stdout *Buffer
+			logger *gplog.GpLogger
+		)
+
+		BeforeEach(func() {
+			dir = GinkgoT().TempDir()
+			logger = gplog.GetLogger()
+			stdout, _, _ = testhelper.SetupTestLogger()
+		})
+		AfterEach(func() {
+			gplog.SetLogger(logger)
+		})
+
+		It("finishes the report and sends it everywhere it is asked to", func() {
+			err := run.Publish(report.PublishOptions{
+				TextFile: filepath.Join(dir, "report.txt"),
+				JSONFile: filepath.Join(dir, "report.json"),
+				Email:    &report.EmailOptions{To: []string{"dba@example.com"}},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(run.EndTime).ToNot(BeZero())
+			Expect(filepath.Join(dir, "report.txt")).To(BeAnExistingFile())
+			Expect(filepath.Join(dir, "report.json")).To(BeAnExistingFile())
+			Expect(sentMail).To(ContainSubstring("End time:"))
+		})
+		It("only warns if the report cannot be emailed", func() {
+			sendmailOK = false
+			err := run.Publish(report.PublishOptions{Email: &report.EmailOptions{To: []string{"dba@example.com"}}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stdout).To(Say("Unable to email report"))
+		})
+		It("returns an error if a file cannot be written", func() {
+			err := run.Publish(report.PublishOptions{TextFile: filepath.Join(dir, "missing", "report.txt")})
+			Expect(err).To(MatchError(ContainSubstring("Unable to write")))
+		})
+	})
+})
@@ -0,0 +1,368 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package report
+
+/*
+ * This file contains a builder for the report a utility writes at the end of
+ * a run, so that every utility's report has the same sections and can be
+ * read either by a person or by a program.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/iohelper"
+	"github.com/apache/cloudberry-go-libs/operating"
+)
+
+const REPORT_TIME_FORMAT = "2006-01-02 15:04:05"
+
+type Status string
+
+const (
+	SUCCESS               Status = "Success"
+	SUCCESS_WITH_WARNINGS Status = "Success with warnings"
+	FAILURE               Status = "Failure"
+)
+
+// A Field is one line of a report's summary, e.g. the backup directory
+type Field struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// A Timing records how long one phase of a run took
+type Timing struct {
+	Name     string
+	Duration time.Duration
+}
+
+// A SegmentResult records the outcome of a run's work on one segment
+type SegmentResult struct {
+	Content  int
+	Host     string
+	Failed   bool
+	Message  string
+	Duration time.Duration
+}
+
+/*
+ * A Report collects the sections of an end-of-run report: a summary of named
+ * values, warnings, timings of each phase, and the result on each segment.
+ * Sections are listed in the order their entries were added, except for
+ * segment results, which are listed by content ID.  A Report may be added to
+ * from several goroutines at once.
+ */
+type Report struct {
+	Utility   string
+	Version   string
+	StartTime time.Time
+	EndTime   time.Time
+	Error     string
+	Summary   []Field
+	Warnings  []string
+	Timings   []Timing
+	Segments  []SegmentResult
+	mutex     sync.Mutex
+}
+
+// New starts a report for a run of utility that starts now
+func New(utility string, version string) *Report {
+	return &Report{Utility: utility, Version: version, StartTime: operating.System.Now()}
+}
+
+// AddSummary sets the summary field called name, replacing any earlier value
+func (report *Report) AddSummary(name string, value interface{}) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	field := Field{Name: name, Value: fmt.Sprint(value)}
+	for i := range report.Summary {
+		if report.Summary[i].Name == name {
+			report.Summary[i] = field
+			return
+		}
+	}
+	report.Summary = append(report.Summary, field)
+}
+
+func (report *Report) AddWarning(format string, args ...interface{}) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	report.Warnings = append(report.Warnings, fmt.Sprintf(format, args...))
+}
+
+func (report *Report) AddTiming(name string, duration time.Duration) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	report.Timings = append(report.Timings, Timing{Name: name, Duration: duration})
+}
+
+/*
+ * StartTiming starts timing a phase of the run, and returns a function that
+ * adds its timing when called, e.g.
+ *
+ *   defer report.StartTiming("Metadata")()
+ */
+func (report *Report) StartTiming(name string) func() {
+	start := operating.System.MonotonicNow()
+	return func() {
+		report.AddTiming(name, operating.Since(start))
+	}
+}
+
+func (report *Report) AddSegmentResult(result SegmentResult) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	report.Segments = append(report.Segments, result)
+}
+
+/*
+ * AddClusterResults adds a segment result for each command in output, with
+ * the error and stderr of each failed command as its message.
+ */
+func (report *Report) AddClusterResults(c *cluster.Cluster, output *cluster.RemoteOutput) {
+	for _, command := range output.Commands {
+		host := command.Host
+		if host == "" {
+			host = c.GetHostForContent(command.Content)
+		}
+		result := SegmentResult{Content: command.Content, Host: host, Failed: command.Error != nil}
+		if command.Error != nil {
+			result.Message = command.Error.Error()
+			if stderr := strings.TrimSpace(command.Stderr); stderr != "" {
+				result.Message += ": " + stderr
+			}
+		}
+		report.AddSegmentResult(result)
+	}
+}
+
+// Fail records that the run failed with err
+func (report *Report) Fail(err error) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	report.Error = err.Error()
+}
+
+// Finish records that the run ended now, unless Finish has already been called
+func (report *Report) Finish() {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	if report.EndTime.IsZero() {
+		report.EndTime = operating.System.Now()
+	}
+}
+
+/*
+ * Status returns FAILURE if the run failed or failed on any segment, and
+ * SUCCESS_WITH_WARNINGS if it succeeded but added any warnings.
+ */
+func (report *Report) Status() Status {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	return report.status()
+}
+
+func (report *Report) status() Status {
+	if report.Error != "" {
+		return FAILURE
+	}
+	for _, segment := range report.Segments {
+		if segment.Failed {
+			return FAILURE
+		}
+	}
+	if len(report.Warnings) > 0 {
+		return SUCCESS_WITH_WARNINGS
+	}
+	return SUCCESS
+}
+
+func (report *Report) sortedSegments() []SegmentResult {
+	segments := append([]SegmentResult{}, report.Segments...)
+	sort.SliceStable(segments, func(i, j int) bool { return segments[i].Content < segments[j].Content })
+	return segments
+}
+
+func formatDuration(duration time.Duration) string {
+	return duration.Round(time.Millisecond).String()
+}
+
+// writeTable writes rows with their columns aligned, each indented under a section heading
+func writeTable(builder *strings.Builder, rows [][]string) {
+	var table strings.Builder
+	writer := tabwriter.NewWriter(&table, 0, 4, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintln(writer, "  "+strings.Join(row, "\t"))
+	}
+	_ = writer.Flush()
+	// Rows with empty trailing columns are padded to the width of the table
+	for _, line := range strings.SplitAfter(table.String(), "\n") {
+		if line != "" {
+			builder.WriteString(strings.TrimRight(line, " \n") + "\n")
+		}
+	}
+}
+
+// Text renders the report for a person to read, leaving out empty sections
+func (report *Report) Text() string {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	var builder strings.Builder
+	title := strings.TrimSpace(report.Utility + " " + report.Version)
+	fmt.Fprintf(&builder, "%s report\n\n", title)
+
+	header := [][]string{{"Status:", string(report.status())}}
+	if report.Error != "" {
+		header = append(header, []string{"Error:", report.Error})
+	}
+	header = append(header, []string{"Start time:", report.StartTime.Format(REPORT_TIME_FORMAT)})
+	if !report.EndTime.IsZero() {
+		header = append(header, []string{"End time:", report.EndTime.Format(REPORT_TIME_FORMAT)})
+		header = append(header, []string{"Duration:", formatDuration(report.EndTime.Sub(report.StartTime))})
+	}
+	writeTable(&builder, header)
+
+	if len(report.Summary) > 0 {
+		rows := make([][]string, len(report.Summary))
+		for i, field := range report.Summary {
+			rows[i] = []string{field.Name + ":", field.Value}
+		}
+		builder.WriteString("\nSummary\n")
+		writeTable(&builder, rows)
+	}
+	if len(report.Warnings) > 0 {
+		builder.WriteString("\nWarnings\n")
+		for _, warning := range report.Warnings {
+			fmt.Fprintf(&builder, "  - %s\n", warning)
+		}
+	}
+	if len(report.Timings) > 0 {
+		rows := make([][]string, len(report.Timings))
+		for i, timing := range report.Timings {
+			rows[i] = []string{timing.Name + ":", formatDuration(timing.Duration)}
+		}
+		builder.WriteString("\nTimings\n")
+		writeTable(&builder, rows)
+	}
+	if len(report.Segments) > 0 {
+		rows := [][]string{{"CONTENT", "HOST", "STATUS", "DURATION", "MESSAGE"}}
+		for _, segment := range report.sortedSegments() {
+			status := "Succeeded"
+			if segment.Failed {
+				status = "Failed"
+			}
+			duration := ""
+			if segment.Duration > 0 {
+				duration = formatDuration(segment.Duration)
+			}
+			message := strings.Join(strings.Fields(segment.Message), " ")
+			rows = append(rows, []string{fmt.Sprint(segment.Content), segment.Host, status, duration, message})
+		}
+		builder.WriteString("\nSegment results\n")
+		writeTable(&builder, rows)
+	}
+	return builder.String()
+}
+
+type jsonTiming struct {
+	Name            string  `json:"name"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+type jsonSegmentResult struct {
+	Content         int     `json:"content"`
+	Host            string  `json:"host"`
+	Failed          bool    `json:"failed"`
+	Message         string  `json:"message,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+}
+
+type jsonReport struct {
+	Utility         string              `json:"utility"`
+	Version         string              `json:"version,omitempty"`
+	Status          Status              `json:"status"`
+	Error           string              `json:"error,omitempty"`
+	StartTime       time.Time           `json:"start_time"`
+	EndTime         *time.Time          `json:"end_time,omitempty"`
+	DurationSeconds float64             `json:"duration_seconds,omitempty"`
+	Summary         []Field             `json:"summary"`
+	Warnings        []string            `json:"warnings"`
+	Timings         []jsonTiming        `json:"timings"`
+	Segments        []jsonSegmentResult `json:"segments"`
+}
+
+/*
+ * JSON renders the report for a program to read.  Every section is included,
+ * as an empty list if nothing was added to it, and durations are given in
+ * seconds.
+ */
+func (report *Report) JSON() ([]byte, error) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	output := jsonReport{
+		Utility:   report.Utility,
+		Version:   report.Version,
+		Status:    report.status(),
+		Error:     report.Error,
+		StartTime: report.StartTime,
+		Summary:   append([]Field{}, report.Summary...),
+		Warnings:  append([]string{}, report.Warnings...),
+		Timings:   make([]jsonTiming, len(report.Timings)),
+		Segments:  make([]jsonSegmentResult, 0, len(report.Segments)),
+	}
+	if !report.EndTime.IsZero() {
+		endTime := report.EndTime
+		output.EndTime = &endTime
+		output.DurationSeconds = endTime.Sub(report.StartTime).Seconds()
+	}
+	for i, timing := range report.Timings {
+		output.Timings[i] = jsonTiming{Name: timing.Name, DurationSeconds: timing.Duration.Seconds()}
+	}
+	for _, segment := range report.sortedSegments() {
+		output.Segments = append(output.Segments, jsonSegmentResult{
+			Content:         segment.Content,
+			Host:            segment.Host,
+			Failed:          segment.Failed,
+			Message:         segment.Message,
+			DurationSeconds: segment.Duration.Seconds(),
+		})
+	}
+	return json.MarshalIndent(output, "", "  ")
+}
+
+// WriteText writes the report's Text to filename, replacing it atomically
+func (report *Report) WriteText(filename string) error {
+	return iohelper.WriteFileAtomically(filename, []byte(report.Text()))
+}
+
+// WriteJSON writes the report's JSON to filename, replacing it atomically
+func (report *Report) WriteJSON(filename string) error {
+	contents, err := report.JSON()
+	if err != nil {
+		return err
+	}
+	return iohelper.WriteFileAtomically(filename, append(contents, '\n'))
+}
@@ -0,0 +1,202 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package report_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/report"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("report tests", func() {
+	var (
+		clock *testhelper.FakeClock
+		run   *report.Report
+	)
+
+	BeforeEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		clock = testhelper.NewFakeClock(time.Date(2024, time.January, 1, 10, 0, 0, 0, time.Local))
+		clock.Install()
+		run = report.New("gpbackup", "1.30.0")
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("Status", func() {
+		It("is SUCCESS for a run with nothing to report", func() {
+			Expect(run.Status()).To(Equal(report.SUCCESS))
+		})
+		It("is SUCCESS_WITH_WARNINGS if warnings were added", func() {
+			run.AddWarning("Table %s is empty", "public.foo")
+			Expect(run.Status()).To(Equal(report.SUCCESS_WITH_WARNINGS))
+		})
+		It("is FAILURE if the run failed", func() {
+			run.AddWarning("Table %s is empty", "public.foo")
+			run.Fail(errors.New("out of disk space"))
+			Expect(run.Status()).To(Equal(report.FAILURE))
+		})
+		It("is FAILURE if any segment failed", func() {
+			run.AddSegmentResult(report.SegmentResult{Content: 0, Host: "sdw1"})
+			run.AddSegmentResult(report.SegmentResult{Content: 1, Host: "sdw1", Failed: true})
+			Expect(run.Status()).To(Equal(report.FAILURE))
+		})
+	})
+	Describe("AddSummary", func() {
+		It("keeps fields in the order they were first added", func() {
+			run.AddSummary("Backup directory", "/data/backups")
+			run.AddSummary("Tables", 3)
+			run.AddSummary("Backup directory", "/data/other")
+			Expect(run.Summary).To(Equal([]report.Field{{Name: "Backup directory", Value: "/data/other"}, {Name: "Tables", Value: "3"}}))
+		})
+	})
+	Describe("StartTiming", func() {
+		It("adds how long the phase took when stopped", func() {
+			stop := run.StartTiming("Metadata")
+			clock.Advance(1500 * time.Millisecond)
+			stop()
+			Expect(run.Timings).To(Equal([]report.Timing{{Name: "Metadata", Duration: 1500 * time.Millisecond}}))
+		})
+	})
+	Describe("AddClusterResults", func() {
+		It("adds a result for each command, looking up the host of each segment", func() {
+			testCluster := cluster.NewCluster([]cluster.SegConfig{
+				{DbID: 1, ContentID: -1, Port: 5432, Hostname: "cdw", DataDir: "/data/gpseg-1", Role: "p"},
+				{DbID: 2, ContentID: 0, Port: 20000, Hostname: "sdw1", DataDir: "/data/gpseg0", Role: "p"},
+			})
+			output := cluster.NewRemoteOutput(cluster.ON_SEGMENTS, 1, []cluster.ShellCommand{
+				{Content: 0, Error: errors.New("exit status 1"), Stderr: "permission denied\n"},
+				{Content: -1},
+			})
+			run.AddClusterResults(testCluster, output)
+			Expect(run.Segments).To(Equal([]report.SegmentResult{
+				{Content: 0, Host: "sdw1", Failed: true, Message: "exit status 1: permission denied"},
+				{Content: -1, Host: "cdw"},
+			}))
+		})
+	})
+	Describe("adding from several goroutines", func() {
+		It("keeps every entry", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func(content int) {
+					defer wg.Done()
+					run.AddSegmentResult(report.SegmentResult{Content: content})
+					run.AddWarning("warning %d", content)
+				}(i)
+			}
+			wg.Wait()
+			Expect(run.Segments).To(HaveLen(20))
+			Expect(run.Warnings).To(HaveLen(20))
+		})
+	})
+	Describe("Text", func() {
+		It("lists each section that has entries, with segments in content order", func() {
+			run.AddSummary("Backup directory", "/data/backups")
+			run.AddSummary("Tables", 3)
+			run.AddWarning("Table public.foo is empty")
+			run.AddTiming("Metadata", 1500*time.Millisecond)
+			run.AddSegmentResult(report.SegmentResult{Content: 1, Host: "sdw2", Failed: true, Message: "disk full\non /data", Duration: 2 * time.Second})
+			run.AddSegmentResult(report.SegmentResult{Content: 0, Host: "sdw1", Duration: time.Second})
+			clock.Advance(5 * time.Minute)
+			run.Finish()
+			Expect(run.Text()).To(Equal(`gpbackup 1.30.0 report
+
+  Status:      Failure
+  Start time:  2024-01-01 10:00:00
+  End time:    2024-01-01 10:05:00
+  Duration:    5m0s
+
+Summary
+  Backup directory:  /data/backups
+  Tables:            3
+
+Warnings
+  - Table public.foo is empty
+
+Timings
+  Metadata:  1.5s
+
+Segment results
+  CONTENT  HOST  STATUS     DURATION  MESSAGE
+  0        sdw1  Succeeded  1s
+  1        sdw2  Failed     2s        disk full on /data
+`))
+		})
+		It("includes the error and leaves out empty sections and the end time of an unfinished run", func() {
+			run.Fail(errors.New("out of disk space"))
+			Expect(run.Text()).To(Equal(`gpbackup 1.30.0 report
+
+  Status:      Failure
+  Error:       out of disk space
+  Start time:  2024-01-01 10:00:00
+`))
+		})
+	})
+	Describe("JSON", func() {
+		It("includes every section, with durations in seconds", func() {
+			run.AddWarning("Table public.foo is empty")
+			run.AddTiming("Metadata", 1500*time.Millisecond)
+			run.AddSegmentResult(report.SegmentResult{Content: 0, Host: "sdw1", Duration: time.Second})
+			clock.Advance(time.Minute)
+			run.Finish()
+			contents, err := run.JSON()
+			Expect(err).ToNot(HaveOccurred())
+			var decoded map[string]interface{}
+			Expect(json.Unmarshal(contents, &decoded)).To(Succeed())
+			Expect(decoded).To(HaveKeyWithValue("utility", "gpbackup"))
+			Expect(decoded).To(HaveKeyWithValue("status", "Success with warnings"))
+			Expect(decoded).To(HaveKeyWithValue("duration_seconds", 60.0))
+			Expect(decoded).To(HaveKeyWithValue("summary", BeEmpty()))
+			Expect(decoded).To(HaveKeyWithValue("timings", ConsistOf(map[string]interface{}{"name": "Metadata", "duration_seconds": 1.5})))
+			Expect(decoded).To(HaveKeyWithValue("segments", ConsistOf(map[string]interface{}{"content": 0.0, "host": "sdw1", "failed": false, "duration_seconds": 1.0})))
+			Expect(decoded).ToNot(HaveKey("error"))
+		})
+		It("leaves out the end time of an unfinished run", func() {
+			contents, err := run.JSON()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).ToNot(ContainSubstring("end_time"))
+			Expect(string(contents)).To(ContainSubstring(`"segments": []`))
+		})
+	})
+	Describe("WriteText and WriteJSON", func() {
+		It("write the report to files", func() {
+			dir := GinkgoT().TempDir()
+			run.Finish()
+			Expect(run.WriteText(filepath.Join(dir, "report.txt"))).To(Succeed())
+			Expect(run.WriteJSON(filepath.Join(dir, "report.json"))).To(Succeed())
+			Expect(os.ReadFile(filepath.Join(dir, "report.txt"))).To(Equal([]byte(run.Text())))
+			contents, err := run.JSON()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(os.ReadFile(filepath.Join(dir, "report.json"))).To(Equal(append(contents, '\n')))
+		})
+	})
+})
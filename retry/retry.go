@@ -0,0 +1,187 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package retry
+
+/*
+ * This file contains functions for retrying an operation that can fail
+ * transiently, waiting longer between each attempt, until it succeeds, fails
+ * with an error that retrying cannot fix, or runs out of attempts or time.
+ */
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/operating"
+)
+
+/*
+ * A Policy controls how an operation is retried.  The zero Policy attempts the
+ * operation once.
+ * - The operation is attempted up to MaxAttempts times; a MaxAttempts of 0 or
+ *   1 means it is not retried, and a negative MaxAttempts means it is retried
+ *   until it succeeds, MaxElapsedTime passes, or the context is done.
+ * - The first retry waits InitialDelay, and each later retry waits Multiplier
+ *   times as long as the one before, up to MaxDelay if that is set.  A
+ *   Multiplier of 0 is treated as 1, for a constant delay.
+ * - Each delay is randomly varied by up to Jitter times itself, so that many
+ *   clients retrying at once do not all retry at the same moment; Jitter is
+ *   between 0 and 1.
+ * - If MaxElapsedTime is set, no retry is made that would start after that
+ *   long since the first attempt.
+ * - If Retryable is set, only errors for which it returns true are retried.
+ * - If OnRetry is set, it is called before waiting to retry after each failed
+ *   attempt, e.g. to log the error.
+ */
+type Policy struct {
+	MaxAttempts    int
+	InitialDelay   time.Duration
+	MaxDelay       time.Duration
+	Multiplier     float64
+	Jitter         float64
+	MaxElapsedTime time.Duration
+	Retryable      func(err error) bool
+	OnRetry        func(attempt int, err error, delay time.Duration)
+}
+
+// Constant returns a Policy that makes up to maxAttempts attempts, waiting delay between each
+func Constant(maxAttempts int, delay time.Duration) Policy {
+	return Policy{MaxAttempts: maxAttempts, InitialDelay: delay}
+}
+
+/*
+ * Exponential returns a Policy that makes up to maxAttempts attempts, doubling
+ * the delay between each from initialDelay up to maxDelay, with each delay
+ * varied by up to 20%.
+ */
+func Exponential(maxAttempts int, initialDelay time.Duration, maxDelay time.Duration) Policy {
+	return Policy{MaxAttempts: maxAttempts, InitialDelay: initialDelay, MaxDelay: maxDelay, Multiplier: 2, Jitter: 0.2}
+}
+
+// Delay returns how long to wait after the given failed attempt, numbered from 1, before the next
+func (policy Policy) Delay(attempt int) time.Duration {
+	multiplier := max(policy.Multiplier, 1)
+	delay := float64(policy.InitialDelay)
+	for i := 1; i < attempt && (policy.MaxDelay <= 0 || delay < float64(policy.MaxDelay)); i++ {
+		delay *= multiplier
+	}
+	if policy.MaxDelay > 0 {
+		delay = min(delay, float64(policy.MaxDelay))
+	}
+	jitter := min(max(policy.Jitter, 0), 1)
+	delay += delay * jitter * (2*rand.Float64() - 1)
+	if delay >= math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(delay)
+}
+
+/*
+ * An Error is returned when an operation does not succeed.  Attempts holds the
+ * error returned by each attempt, and Err holds the context's error if the
+ * context was done before the operation succeeded.  Errors.Is and errors.As
+ * look at all of these.
+ */
+type Error struct {
+	Attempts []error
+	Err      error
+}
+
+func (e *Error) Error() string {
+	last := e.Last()
+	if e.Err != nil {
+		if last == nil {
+			return e.Err.Error()
+		}
+		return fmt.Sprintf("%v after %d attempts; last error: %v", e.Err, len(e.Attempts), last)
+	}
+	if len(e.Attempts) == 1 {
+		return last.Error()
+	}
+	return fmt.Sprintf("%d attempts failed; last error: %v", len(e.Attempts), last)
+}
+
+func (e *Error) Unwrap() []error {
+	errs := append([]error{}, e.Attempts...)
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
+
+// Last returns the error returned by the last attempt, or nil if no attempt was made
+func (e *Error) Last() error {
+	if len(e.Attempts) == 0 {
+		return nil
+	}
+	return e.Attempts[len(e.Attempts)-1]
+}
+
+/*
+ * Do calls operation until it succeeds or policy says to stop, waiting on
+ * operating.System's clock between attempts, and returns nil or an *Error.
+ * It stops without waiting for the next attempt if ctx is done.
+ */
+func Do(ctx context.Context, policy Policy, operation func(ctx context.Context) error) error {
+	_, err := DoWithResult(ctx, policy, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, operation(ctx)
+	})
+	return err
+}
+
+// DoWithResult is Do for an operation that returns a value, returning the value from the successful attempt
+func DoWithResult[T any](ctx context.Context, policy Policy, operation func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+	retryErr := &Error{}
+	start := operating.System.MonotonicNow()
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			retryErr.Err = err
+			return zero, retryErr
+		}
+		result, err := operation(ctx)
+		if err == nil {
+			return result, nil
+		}
+		retryErr.Attempts = append(retryErr.Attempts, err)
+		if policy.MaxAttempts >= 0 && attempt >= policy.MaxAttempts {
+			return zero, retryErr
+		}
+		if policy.Retryable != nil && !policy.Retryable(err) {
+			return zero, retryErr
+		}
+		delay := policy.Delay(attempt)
+		if policy.MaxElapsedTime > 0 && operating.Since(start)+delay > policy.MaxElapsedTime {
+			return zero, retryErr
+		}
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempt, err, delay)
+		}
+		if delay > 0 {
+			timer := operating.System.NewTimer(delay)
+			select {
+			case <-timer.C():
+			case <-ctx.Done():
+			}
+			timer.Stop()
+		}
+	}
+}
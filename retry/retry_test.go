@@ -0,0 +1,200 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package retry_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/retry"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("retry/retry tests", func() {
+	var clock *testhelper.FakeClock
+
+	BeforeEach(func() {
+		clock = testhelper.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		clock.Install()
+		DeferCleanup(func() { operating.System = operating.InitializeSystemFunctions() })
+	})
+
+	// failTimes returns an operation that fails the first n times it is called
+	failTimes := func(n int, calls *int) func(context.Context) error {
+		return func(context.Context) error {
+			*calls++
+			if *calls <= n {
+				return errors.Errorf("failure %d", *calls)
+			}
+			return nil
+		}
+	}
+
+	Describe("Policy.Delay", func() {
+		It("multiplies each delay up to the maximum", func() {
+			policy := retry.Policy{InitialDelay: time.Second, MaxDelay: 10 * time.Second, Multiplier: 2}
+			Expect(policy.Delay(1)).To(Equal(time.Second))
+			Expect(policy.Delay(2)).To(Equal(2 * time.Second))
+			Expect(policy.Delay(4)).To(Equal(8 * time.Second))
+			Expect(policy.Delay(5)).To(Equal(10 * time.Second))
+			Expect(policy.Delay(1000)).To(Equal(10 * time.Second))
+		})
+		It("keeps the delay constant with no multiplier", func() {
+			policy := retry.Constant(5, time.Second)
+			Expect(policy.Delay(1)).To(Equal(time.Second))
+			Expect(policy.Delay(4)).To(Equal(time.Second))
+		})
+		It("varies each delay by up to the jitter", func() {
+			policy := retry.Exponential(5, time.Second, time.Minute)
+			for i := 0; i < 100; i++ {
+				Expect(policy.Delay(2)).To(BeNumerically("~", 2*time.Second, 400*time.Millisecond))
+			}
+		})
+		It("does not overflow with no maximum", func() {
+			policy := retry.Policy{InitialDelay: time.Second, Multiplier: 10}
+			Expect(policy.Delay(100)).To(BeNumerically(">", time.Duration(0)))
+		})
+	})
+	Describe("Do", func() {
+		It("returns once the operation succeeds", func() {
+			calls := 0
+			done := make(chan error)
+			go func() {
+				done <- retry.Do(context.Background(), retry.Constant(3, time.Second), failTimes(1, &calls))
+			}()
+			clock.BlockUntil(1)
+			clock.Advance(time.Second)
+			Eventually(done).Should(Receive(BeNil()))
+			Expect(calls).To(Equal(2))
+		})
+		It("waits longer between each attempt", func() {
+			calls := 0
+			done := make(chan error)
+			policy := retry.Policy{MaxAttempts: 3, InitialDelay: time.Second, Multiplier: 2}
+			go func() {
+				done <- retry.Do(context.Background(), policy, failTimes(5, &calls))
+			}()
+			clock.BlockUntil(1)
+			clock.Advance(time.Second)
+			clock.BlockUntil(1)
+			clock.Advance(time.Second)
+			Consistently(done).ShouldNot(Receive())
+			clock.Advance(time.Second)
+			var err error
+			Eventually(done).Should(Receive(&err))
+			Expect(err).To(MatchError("3 attempts failed; last error: failure 3"))
+			Expect(calls).To(Equal(3))
+		})
+		It("returns every attempt's error", func() {
+			first := errors.New("first")
+			calls := 0
+			err := retry.Do(context.Background(), retry.Constant(2, 0), func(context.Context) error {
+				calls++
+				if calls == 1 {
+					return first
+				}
+				return errors.New("second")
+			})
+			var retryErr *retry.Error
+			Expect(errors.As(err, &retryErr)).To(BeTrue())
+			Expect(retryErr.Attempts).To(HaveLen(2))
+			Expect(retryErr.Last()).To(MatchError("second"))
+			Expect(errors.Is(err, first)).To(BeTrue())
+		})
+		It("makes one attempt with the zero policy", func() {
+			calls := 0
+			err := retry.Do(context.Background(), retry.Policy{}, failTimes(1, &calls))
+			Expect(err).To(MatchError("failure 1"))
+			Expect(calls).To(Equal(1))
+		})
+		It("does not retry an error that is not retryable", func() {
+			calls := 0
+			policy := retry.Constant(5, time.Second)
+			policy.Retryable = func(err error) bool { return err.Error() != "failure 2" }
+			done := make(chan error)
+			go func() {
+				done <- retry.Do(context.Background(), policy, failTimes(5, &calls))
+			}()
+			clock.BlockUntil(1)
+			clock.Advance(time.Second)
+			Eventually(done).Should(Receive(MatchError("2 attempts failed; last error: failure 2")))
+			Expect(calls).To(Equal(2))
+		})
+		It("does not retry after the maximum elapsed time", func() {
+			calls := 0
+			policy := retry.Policy{MaxAttempts: -1, InitialDelay: time.Second, MaxElapsedTime: 2500 * time.Millisecond}
+			done := make(chan error)
+			go func() {
+				done <- retry.Do(context.Background(), policy, failTimes(10, &calls))
+			}()
+			clock.BlockUntil(1)
+			clock.Advance(time.Second)
+			clock.BlockUntil(1)
+			clock.Advance(time.Second)
+			Eventually(done).Should(Receive(MatchError("3 attempts failed; last error: failure 3")))
+		})
+		It("stops waiting when the context is canceled", func() {
+			calls := 0
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() {
+				done <- retry.Do(ctx, retry.Constant(5, time.Hour), failTimes(5, &calls))
+			}()
+			clock.BlockUntil(1)
+			cancel()
+			var err error
+			Eventually(done).Should(Receive(&err))
+			Expect(err).To(MatchError("context canceled after 1 attempts; last error: failure 1"))
+			Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+			Expect(calls).To(Equal(1))
+		})
+		It("does not attempt the operation if the context is already done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			calls := 0
+			Expect(retry.Do(ctx, retry.Constant(5, time.Second), failTimes(0, &calls))).To(MatchError(context.Canceled))
+			Expect(calls).To(Equal(0))
+		})
+		It("calls OnRetry before each retry", func() {
+			calls := 0
+			retries := make([]string, 0)
+			policy := retry.Constant(3, 0)
+			policy.OnRetry = func(attempt int, err error, delay time.Duration) {
+				retries = append(retries, err.Error())
+			}
+			Expect(retry.Do(context.Background(), policy, failTimes(5, &calls))).To(HaveOccurred())
+			Expect(retries).To(Equal([]string{"failure 1", "failure 2"}))
+		})
+	})
+	Describe("DoWithResult", func() {
+		It("returns the result of the successful attempt", func() {
+			calls := 0
+			result, err := retry.DoWithResult(context.Background(), retry.Constant(1, 0), func(context.Context) (int, error) {
+				calls++
+				return 42, nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(42))
+		})
+	})
+})
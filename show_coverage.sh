@@ -3,7 +3,7 @@
 DIR="github.com/apache/cloudberry-go-libs"
 RESULTS="/tmp/results.out"
 echo "mode: set" > /tmp/coverage.out # Need this line at the start of the file for the total coverage at the end
-for PACKAGE in "cluster" "conv" "dbconn" "gperror" "gplog" "iohelper" "structmatcher"; do
+for PACKAGE in "cluster" "config" "conv" "dbconn" "gpapi" "gpbanner" "gpconfigdiff" "gpdiag" "gperror" "gperror/internal/cataloggen" "gpfs/pathutil" "gplog" "gpmigrate" "gpqueue" "gpsysinfo" "gpversion" "iohelper" "lockfile" "metrics" "operating" "prompt" "report" "retry" "structmatcher" "testhelper"; do
   # Generate code coverage statistics for all packages, write the coverage statistics to a file, and print the coverage percentage to the shell
   go test -coverpkg "$DIR/$PACKAGE" "$DIR/$PACKAGE" -coverprofile="/tmp/unit_$PACKAGE.out" | awk '{printf("%s unit test coverage|%s", $2, $5)}' | awk -F"/" '{print $4}' >> $RESULTS
   # Filter out the first "mode: set" line from each coverage file and concatenate them all
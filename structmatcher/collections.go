@@ -0,0 +1,238 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package structmatcher
+
+/*
+ * This file contains matchers for slices and maps of structs, which compare
+ * each element as MatchStruct does, so that tests do not depend on the order
+ * in which elements were returned.
+ */
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/onsi/gomega/types"
+)
+
+/*
+ * elementMismatches compares two elements of a slice or map.  Elements that
+ * are not structs, are nil pointers to structs, or have a comparator for their
+ * type are compared as a whole.
+ */
+func elementMismatches(options matchOptions, expected reflect.Value, actual reflect.Value) []mismatch {
+	expectedStruct, actualStruct := reflect.Indirect(expected), reflect.Indirect(actual)
+	if expectedStruct.Kind() == reflect.Struct && actualStruct.Kind() == reflect.Struct && options.comparators.find("", expectedStruct.Type()) == nil {
+		return options.structMismatches(expected, actual)
+	}
+	if differences := diffValues(options.comparators, "", expected, actual); len(differences) > 0 {
+		return []mismatch{{message: strings.Join(differenceStrings(differences), "\n"), expected: expected, actual: actual, comparators: options.comparators}}
+	}
+	return nil
+}
+
+// indentDifferences formats the differences within one element to be listed under a heading for it
+func indentDifferences(heading string, mismatches []mismatch) []string {
+	lines := []string{heading}
+	for _, difference := range mismatchDifferences(mismatches) {
+		lines = append(lines, "  "+difference)
+	}
+	return lines
+}
+
+/*
+ * An ElementsMatcher matches a slice or array of structs against the expected
+ * elements in any order.  Each expected element must match a different actual
+ * element, with fields filtered as for MatchStruct, and there must be no
+ * actual elements left over.
+ */
+type ElementsMatcher struct {
+	matchOptions
+	expected    interface{}
+	differences []string
+}
+
+var _ types.GomegaMatcher = &ElementsMatcher{}
+
+func ElementsMatchStruct(expected interface{}) *ElementsMatcher {
+	return &ElementsMatcher{
+		expected: expected,
+	}
+}
+
+func isSliceOrArray(value reflect.Value) bool {
+	return value.Kind() == reflect.Slice || value.Kind() == reflect.Array
+}
+
+/*
+ * Match pairs each expected element with the first unpaired actual element
+ * that matches it.  When elements are left unpaired, each remaining expected
+ * element is reported against the remaining actual element it differs from
+ * least, so that a single changed field is shown as such rather than as one
+ * missing and one extra element.
+ */
+func (m *ElementsMatcher) Match(actual interface{}) (success bool, err error) {
+	expectedValue, actualValue := reflect.ValueOf(m.expected), reflect.ValueOf(actual)
+	if !isSliceOrArray(expectedValue) {
+		return false, fmt.Errorf("ElementsMatchStruct expects a slice or array of expected elements, got %T", m.expected)
+	}
+	if !isSliceOrArray(actualValue) {
+		return false, fmt.Errorf("ElementsMatchStruct expects a slice or array, got %T", actual)
+	}
+	paired := make([]bool, actualValue.Len())
+	unpairedExpected := make([]int, 0)
+	for i := 0; i < expectedValue.Len(); i++ {
+		found := false
+		for j := 0; j < actualValue.Len() && !found; j++ {
+			if !paired[j] && len(elementMismatches(m.matchOptions, expectedValue.Index(i), actualValue.Index(j))) == 0 {
+				paired[j] = true
+				found = true
+			}
+		}
+		if !found {
+			unpairedExpected = append(unpairedExpected, i)
+		}
+	}
+
+	m.differences = make([]string, 0)
+	for _, i := range unpairedExpected {
+		closest := -1
+		var closestMismatches []mismatch
+		for j := 0; j < actualValue.Len(); j++ {
+			if paired[j] {
+				continue
+			}
+			mismatches := elementMismatches(m.matchOptions, expectedValue.Index(i), actualValue.Index(j))
+			if closest == -1 || len(mismatchDifferences(mismatches)) < len(mismatchDifferences(closestMismatches)) {
+				closest, closestMismatches = j, mismatches
+			}
+		}
+		if closest == -1 {
+			m.differences = append(m.differences, fmt.Sprintf("Missing element [%d]: %s", i, formatValue(expectedValue.Index(i))))
+			continue
+		}
+		paired[closest] = true
+		heading := fmt.Sprintf("Expected element [%d] differs from actual element [%d]:", i, closest)
+		m.differences = append(m.differences, indentDifferences(heading, closestMismatches)...)
+	}
+	for j := 0; j < actualValue.Len(); j++ {
+		if !paired[j] {
+			m.differences = append(m.differences, fmt.Sprintf("Extra element [%d]: %s", j, formatValue(actualValue.Index(j))))
+		}
+	}
+	return len(m.differences) == 0, nil
+}
+
+func (m *ElementsMatcher) FailureMessage(actual interface{}) (message string) {
+	return "Expected elements to match but:\n" + strings.Join(m.differences, "\n")
+}
+
+func (m *ElementsMatcher) NegatedFailureMessage(actual interface{}) (message string) {
+	return "Expected elements not to match, but they did"
+}
+
+func (m *ElementsMatcher) IncludingFields(fields ...string) *ElementsMatcher {
+	m.includingFields = fields
+	return m
+}
+
+func (m *ElementsMatcher) ExcludingFields(fields ...string) *ElementsMatcher {
+	m.excludingFields = fields
+	return m
+}
+
+func (m *ElementsMatcher) WithFieldComparator(path string, comparator Comparator) *ElementsMatcher {
+	m.comparators = m.comparators.addField(path, comparator)
+	return m
+}
+
+func (m *ElementsMatcher) WithTypeComparator(example interface{}, comparator Comparator) *ElementsMatcher {
+	m.comparators = m.comparators.addType(example, comparator)
+	return m
+}
+
+/*
+ * A MapMatcher matches a map of structs against the expected map key by key,
+ * with fields filtered as for MatchStruct, and reports keys that are missing
+ * from or extra in the actual map.
+ */
+type MapMatcher struct {
+	matchOptions
+	expected    interface{}
+	differences []string
+}
+
+var _ types.GomegaMatcher = &MapMatcher{}
+
+func MatchStructMap(expected interface{}) *MapMatcher {
+	return &MapMatcher{
+		expected: expected,
+	}
+}
+
+func (m *MapMatcher) Match(actual interface{}) (success bool, err error) {
+	expectedValue, actualValue := reflect.ValueOf(m.expected), reflect.ValueOf(actual)
+	if expectedValue.Kind() != reflect.Map {
+		return false, fmt.Errorf("MatchStructMap expects a map of expected values, got %T", m.expected)
+	}
+	if actualValue.Kind() != reflect.Map || actualValue.Type().Key() != expectedValue.Type().Key() {
+		return false, fmt.Errorf("MatchStructMap expects a map with %s keys, got %T", expectedValue.Type().Key(), actual)
+	}
+	m.differences = make([]string, 0)
+	for _, key := range mapKeys(expectedValue, actualValue) {
+		expectedElement, actualElement := expectedValue.MapIndex(key), actualValue.MapIndex(key)
+		if !actualElement.IsValid() {
+			m.differences = append(m.differences, fmt.Sprintf("Missing key %s: %s", formatValue(key), formatValue(expectedElement)))
+		} else if !expectedElement.IsValid() {
+			m.differences = append(m.differences, fmt.Sprintf("Extra key %s: %s", formatValue(key), formatValue(actualElement)))
+		} else if mismatches := elementMismatches(m.matchOptions, expectedElement, actualElement); len(mismatches) > 0 {
+			heading := fmt.Sprintf("Value for key %s differs:", formatValue(key))
+			m.differences = append(m.differences, indentDifferences(heading, mismatches)...)
+		}
+	}
+	return len(m.differences) == 0, nil
+}
+
+func (m *MapMatcher) FailureMessage(actual interface{}) (message string) {
+	return "Expected maps to match but:\n" + strings.Join(m.differences, "\n")
+}
+
+func (m *MapMatcher) NegatedFailureMessage(actual interface{}) (message string) {
+	return "Expected maps not to match, but they did"
+}
+
+func (m *MapMatcher) IncludingFields(fields ...string) *MapMatcher {
+	m.includingFields = fields
+	return m
+}
+
+func (m *MapMatcher) ExcludingFields(fields ...string) *MapMatcher {
+	m.excludingFields = fields
+	return m
+}
+
+func (m *MapMatcher) WithFieldComparator(path string, comparator Comparator) *MapMatcher {
+	m.comparators = m.comparators.addField(path, comparator)
+	return m
+}
+
+func (m *MapMatcher) WithTypeComparator(example interface{}, comparator Comparator) *MapMatcher {
+	m.comparators = m.comparators.addType(example, comparator)
+	return m
+}
@@ -0,0 +1,141 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package structmatcher_test
+
+import (
+	"github.com/apache/cloudberry-go-libs/structmatcher"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("structmatcher/collections tests", func() {
+	type ColumnDefinition struct {
+		Num  int
+		Name string
+		Type string
+	}
+	type TableDefinition struct {
+		Oid     uint32
+		Name    string
+		Columns []ColumnDefinition
+	}
+	var tables []TableDefinition
+	BeforeEach(func() {
+		tables = []TableDefinition{
+			{Oid: 1, Name: "public.foo", Columns: []ColumnDefinition{{1, "i", "int"}}},
+			{Oid: 2, Name: "public.bar", Columns: []ColumnDefinition{{1, "j", "text"}}},
+			{Oid: 3, Name: "public.baz"},
+		}
+	})
+
+	Describe("ElementsMatchStruct", func() {
+		It("matches elements in any order", func() {
+			actual := []TableDefinition{tables[2], tables[0], tables[1]}
+			Expect(actual).To(structmatcher.ElementsMatchStruct(tables))
+			Expect([3]TableDefinition{tables[1], tables[2], tables[0]}).To(structmatcher.ElementsMatchStruct(tables))
+		})
+		It("matches pointers to structs", func() {
+			actual := []*TableDefinition{&tables[1], &tables[0]}
+			Expect(actual).To(structmatcher.ElementsMatchStruct([]*TableDefinition{&tables[0], &tables[1]}))
+			Expect([]*TableDefinition{&tables[0], nil}).ToNot(structmatcher.ElementsMatchStruct([]*TableDefinition{&tables[0], &tables[1]}))
+		})
+		It("matches each expected element against a different actual element", func() {
+			actual := []TableDefinition{tables[0], tables[0]}
+			Expect(actual).ToNot(structmatcher.ElementsMatchStruct([]TableDefinition{tables[0], tables[1]}))
+		})
+		It("filters fields within each element", func() {
+			actual := []TableDefinition{tables[1], tables[0]}
+			actual[0].Oid, actual[1].Oid = 10, 20
+			Expect(actual).ToNot(structmatcher.ElementsMatchStruct(tables[:2]))
+			Expect(actual).To(structmatcher.ElementsMatchStruct(tables[:2]).ExcludingFields("Oid"))
+			Expect(actual).To(structmatcher.ElementsMatchStruct(tables[:2]).IncludingFields("Name", "Columns[*].Type"))
+		})
+		It("describes elements that differ, are missing, or are extra", func() {
+			changed := tables[1]
+			changed.Columns = []ColumnDefinition{{1, "j", "varchar"}}
+			actual := []TableDefinition{{Oid: 4, Name: "public.qux"}, changed, tables[0]}
+			messages := InterceptGomegaFailures(func() {
+				Expect(actual[:2]).To(structmatcher.ElementsMatchStruct(tables))
+			})
+			Expect(messages).To(Equal([]string{"Expected elements to match but:\n" +
+				"Expected element [0] differs from actual element [0]:\n" +
+				"  Field .Oid: expected 1, got 4\n" +
+				"  Field .Name: expected 'public.foo', got 'public.qux'\n" +
+				"  Field .Columns[0]: expected {Num:1 Name:i Type:int}, got <missing>\n" +
+				"Expected element [1] differs from actual element [1]:\n" +
+				"  Field .Columns[0].Type: expected 'text', got 'varchar'\n" +
+				"Missing element [2]: {Oid:3 Name:public.baz Columns:[]}"}))
+
+			messages = InterceptGomegaFailures(func() {
+				Expect(actual).To(structmatcher.ElementsMatchStruct(tables[:2]))
+			})
+			Expect(messages).To(Equal([]string{"Expected elements to match but:\n" +
+				"Expected element [1] differs from actual element [1]:\n" +
+				"  Field .Columns[0].Type: expected 'text', got 'varchar'\n" +
+				"Extra element [0]: {Oid:4 Name:public.qux Columns:[]}"}))
+		})
+		It("compares elements that are not structs as a whole", func() {
+			Expect([]string{"b", "a"}).To(structmatcher.ElementsMatchStruct([]string{"a", "b"}))
+			messages := InterceptGomegaFailures(func() {
+				Expect([]string{"b", "c"}).To(structmatcher.ElementsMatchStruct([]string{"a", "b"}))
+			})
+			Expect(messages).To(Equal([]string{"Expected elements to match but:\nExpected element [0] differs from actual element [1]:\n  Value: expected 'a', got 'c'"}))
+		})
+		It("returns an error if either value is not a slice or array", func() {
+			success, err := structmatcher.ElementsMatchStruct(tables).Match(tables[0])
+			Expect(success).To(BeFalse())
+			Expect(err).To(MatchError(ContainSubstring("ElementsMatchStruct expects a slice or array, got structmatcher_test.TableDefinition")))
+		})
+	})
+	Describe("MatchStructMap", func() {
+		var expected map[string]TableDefinition
+		BeforeEach(func() {
+			expected = map[string]TableDefinition{"foo": tables[0], "bar": tables[1]}
+		})
+		It("matches maps key by key", func() {
+			Expect(map[string]TableDefinition{"bar": tables[1], "foo": tables[0]}).To(structmatcher.MatchStructMap(expected))
+		})
+		It("filters fields within each value", func() {
+			actual := map[string]TableDefinition{"foo": tables[0], "bar": tables[1]}
+			changed := actual["bar"]
+			changed.Oid = 20
+			actual["bar"] = changed
+			Expect(actual).ToNot(structmatcher.MatchStructMap(expected))
+			Expect(actual).To(structmatcher.MatchStructMap(expected).ExcludingFields("Oid"))
+			Expect(actual).To(structmatcher.MatchStructMap(expected).IncludingFields("Name"))
+		})
+		It("describes values that differ and keys that are missing or extra", func() {
+			changed := tables[1]
+			changed.Name = "public.bar2"
+			actual := map[string]TableDefinition{"bar": changed, "baz": tables[2]}
+			messages := InterceptGomegaFailures(func() {
+				Expect(actual).To(structmatcher.MatchStructMap(expected))
+			})
+			Expect(messages).To(Equal([]string{"Expected maps to match but:\n" +
+				"Value for key 'bar' differs:\n" +
+				"  Field .Name: expected 'public.bar', got 'public.bar2'\n" +
+				"Extra key 'baz': {Oid:3 Name:public.baz Columns:[]}\n" +
+				"Missing key 'foo': {Oid:1 Name:public.foo Columns:[{Num:1 Name:i Type:int}]}"}))
+		})
+		It("returns an error if the key types differ", func() {
+			_, err := structmatcher.MatchStructMap(expected).Match(map[int]TableDefinition{})
+			Expect(err).To(MatchError("MatchStructMap expects a map with string keys, got map[int]structmatcher_test.TableDefinition"))
+		})
+	})
+})
@@ -0,0 +1,162 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package structmatcher
+
+/*
+ * This file contains comparators that let the matchers treat values as equal
+ * without their being identical, such as timestamps taken a moment apart or
+ * floating point statistics that differ by rounding.
+ */
+
+import (
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+/*
+ * A Comparator reports whether two values should be treated as equal.  It is
+ * only called with two values of the type, or of the field, it was registered
+ * for.
+ */
+type Comparator func(expected interface{}, actual interface{}) bool
+
+/*
+ * A comparatorSet holds the comparators registered with a matcher.  A
+ * comparator registered for a field takes precedence over one registered for
+ * the field's type.
+ */
+type comparatorSet struct {
+	byPath map[string]Comparator
+	byType map[reflect.Type]Comparator
+}
+
+var indexPattern = regexp.MustCompile(`\[[^\]]*\]`)
+
+/*
+ * normalizeFieldPath removes slice indexes and map keys from a path, so that
+ * a comparator registered for "Segments[*].Port" or "Segments.Port" applies
+ * to the field at ".Segments[2].Port".
+ */
+func normalizeFieldPath(path string) string {
+	return strings.TrimPrefix(indexPattern.ReplaceAllString(path, ""), ".")
+}
+
+func (set *comparatorSet) addField(path string, comparator Comparator) *comparatorSet {
+	if set == nil {
+		set = &comparatorSet{}
+	}
+	if set.byPath == nil {
+		set.byPath = make(map[string]Comparator)
+	}
+	set.byPath[normalizeFieldPath(path)] = comparator
+	return set
+}
+
+func (set *comparatorSet) addType(example interface{}, comparator Comparator) *comparatorSet {
+	if set == nil {
+		set = &comparatorSet{}
+	}
+	if set.byType == nil {
+		set.byType = make(map[reflect.Type]Comparator)
+	}
+	set.byType[reflect.TypeOf(example)] = comparator
+	return set
+}
+
+// find returns the comparator for the value at path, or nil if there is none
+func (set *comparatorSet) find(path string, valueType reflect.Type) Comparator {
+	if set == nil {
+		return nil
+	}
+	if comparator, ok := set.byPath[normalizeFieldPath(path)]; ok {
+		return comparator
+	}
+	return set.byType[valueType]
+}
+
+/*
+ * comparesWhole returns whether a comparator applies to the field at path, to
+ * a value it points to, or to its elements, in which case the field must be
+ * compared as a whole rather than field by field.
+ */
+func (set *comparatorSet) comparesWhole(path string, fieldType reflect.Type) bool {
+	for {
+		if set.find(path, fieldType) != nil {
+			return true
+		}
+		switch fieldType.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Array:
+			fieldType = fieldType.Elem()
+		default:
+			return false
+		}
+	}
+}
+
+// WithinDuration compares time.Time values, treating them as equal if they are at most tolerance apart
+func WithinDuration(tolerance time.Duration) Comparator {
+	return func(expected interface{}, actual interface{}) bool {
+		expectedTime, ok1 := expected.(time.Time)
+		actualTime, ok2 := actual.(time.Time)
+		if !ok1 || !ok2 {
+			return reflect.DeepEqual(expected, actual)
+		}
+		difference := expectedTime.Sub(actualTime)
+		return difference <= tolerance && difference >= -tolerance
+	}
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	reflected := reflect.ValueOf(value)
+	switch reflected.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return reflected.Float(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(reflected.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(reflected.Uint()), true
+	}
+	return 0, false
+}
+
+// WithinTolerance compares numbers of any kind, treating them as equal if they differ by at most epsilon
+func WithinTolerance(epsilon float64) Comparator {
+	return func(expected interface{}, actual interface{}) bool {
+		expectedFloat, ok1 := toFloat(expected)
+		actualFloat, ok2 := toFloat(actual)
+		if !ok1 || !ok2 {
+			return reflect.DeepEqual(expected, actual)
+		}
+		return math.Abs(expectedFloat-actualFloat) <= epsilon
+	}
+}
+
+// EqualFold compares strings without regard to case
+func EqualFold() Comparator {
+	return func(expected interface{}, actual interface{}) bool {
+		expectedString, ok1 := expected.(string)
+		actualString, ok2 := actual.(string)
+		if !ok1 || !ok2 {
+			return reflect.DeepEqual(expected, actual)
+		}
+		return strings.EqualFold(expectedString, actualString)
+	}
+}
@@ -0,0 +1,133 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package structmatcher_test
+
+import (
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/structmatcher"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("structmatcher/compare tests", func() {
+	type Statistic struct {
+		Attname   string
+		NullFrac  float64
+		Collected time.Time
+	}
+	type Table struct {
+		Oid        uint32
+		Name       string
+		Owner      string
+		CreatedAt  time.Time
+		AnalyzedAt *time.Time
+		Stats      []Statistic
+		Options    map[string]string
+	}
+	var expected, actual Table
+	BeforeEach(func() {
+		created := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+		analyzed := created.Add(time.Hour)
+		expected = Table{Oid: 16384, Name: "public.foo", Owner: "gpadmin", CreatedAt: created, AnalyzedAt: &analyzed,
+			Stats: []Statistic{{"i", 0.25, analyzed}, {"j", 0, analyzed}}, Options: map[string]string{"appendonly": "true"}}
+		actual = expected
+		laterAnalyzed := analyzed.Add(500 * time.Millisecond)
+		actual.CreatedAt = created.Add(-800 * time.Millisecond)
+		actual.AnalyzedAt = &laterAnalyzed
+		actual.Stats = []Statistic{{"i", 0.2500001, laterAnalyzed}, {"j", 0, laterAnalyzed}}
+	})
+
+	Describe("WithTypeComparator", func() {
+		It("compares every value of the type with the comparator, at any depth", func() {
+			Expect(actual).ToNot(structmatcher.MatchStruct(expected))
+			Expect(actual).To(structmatcher.MatchStruct(expected).
+				WithTypeComparator(time.Time{}, structmatcher.WithinDuration(time.Second)).
+				WithTypeComparator(float64(0), structmatcher.WithinTolerance(1e-6)))
+		})
+		It("reports values the comparator rejects", func() {
+			messages := InterceptGomegaFailures(func() {
+				Expect(actual).To(structmatcher.MatchStruct(expected).
+					WithTypeComparator(time.Time{}, structmatcher.WithinDuration(600*time.Millisecond)).
+					WithTypeComparator(float64(0), structmatcher.WithinTolerance(1e-9)))
+			})
+			Expect(messages).To(HaveLen(1))
+			Expect(messages[0]).To(HavePrefix("Expected structs to match but:\nField .Stats[0].NullFrac: expected 0.25, got 0.2500001\nField .CreatedAt: expected "))
+			Expect(messages[0]).ToNot(ContainSubstring(".AnalyzedAt"))
+			Expect(messages[0]).ToNot(ContainSubstring(".Collected"))
+		})
+	})
+	Describe("WithFieldComparator", func() {
+		It("compares only the given field with the comparator", func() {
+			actual = expected
+			actual.Owner = "GPAdmin"
+			actual.Name = "PUBLIC.foo"
+			messages := InterceptGomegaFailures(func() {
+				Expect(actual).To(structmatcher.MatchStruct(expected).WithFieldComparator("Owner", structmatcher.EqualFold()))
+			})
+			Expect(messages).To(Equal([]string{"Expected structs to match but:\nField .Name: expected 'public.foo', got 'PUBLIC.foo'"}))
+		})
+		It("applies within each element of a slice and takes precedence over type comparators", func() {
+			actual.CreatedAt, actual.AnalyzedAt = expected.CreatedAt, expected.AnalyzedAt
+			actual.Stats[0].NullFrac = expected.Stats[0].NullFrac
+			actual.Stats[1].Collected = expected.Stats[1].Collected.Add(time.Hour)
+			Expect(actual).ToNot(structmatcher.MatchStruct(expected).WithTypeComparator(time.Time{}, structmatcher.WithinDuration(time.Second)))
+			Expect(actual).To(structmatcher.MatchStruct(expected).
+				WithTypeComparator(time.Time{}, structmatcher.WithinDuration(time.Second)).
+				WithFieldComparator("Stats[*].Collected", structmatcher.WithinDuration(2*time.Hour)))
+		})
+		It("treats a field as different if the comparator rejects even equal values", func() {
+			actual = expected
+			never := func(expected interface{}, actual interface{}) bool { return false }
+			messages := InterceptGomegaFailures(func() {
+				Expect(actual).To(structmatcher.MatchStruct(expected).WithFieldComparator("Oid", never))
+			})
+			Expect(messages).To(Equal([]string{"Expected structs to match but:\nField .Oid: expected 16384, got 16384"}))
+			Expect(structmatcher.Diff(1, 1)).To(BeEmpty())
+		})
+	})
+	Describe("with collection matchers", func() {
+		It("applies comparators to elements and map values", func() {
+			Expect(actual.Stats).To(structmatcher.ElementsMatchStruct(expected.Stats).
+				WithTypeComparator(time.Time{}, structmatcher.WithinDuration(time.Second)).
+				WithFieldComparator("NullFrac", structmatcher.WithinTolerance(0.001)))
+			Expect([]time.Time{actual.CreatedAt}).To(structmatcher.ElementsMatchStruct([]time.Time{expected.CreatedAt}).
+				WithTypeComparator(time.Time{}, structmatcher.WithinDuration(time.Second)))
+			Expect(map[string]Table{"foo": actual}).To(structmatcher.MatchStructMap(map[string]Table{"foo": expected}).
+				WithTypeComparator(time.Time{}, structmatcher.WithinDuration(time.Second)).
+				WithTypeComparator(float64(0), structmatcher.WithinTolerance(0.001)))
+		})
+	})
+	Describe("built-in comparators", func() {
+		It("compare values within a tolerance", func() {
+			Expect(structmatcher.WithinTolerance(0.5)(uint32(10), uint32(11))).To(BeFalse())
+			Expect(structmatcher.WithinTolerance(1)(uint32(10), uint32(11))).To(BeTrue())
+			Expect(structmatcher.WithinTolerance(1)(int64(-3), int64(-2))).To(BeTrue())
+			Expect(structmatcher.WithinDuration(time.Second)(time.Unix(10, 0), time.Unix(9, 0))).To(BeTrue())
+			Expect(structmatcher.WithinDuration(time.Second)(time.Unix(10, 0), time.Unix(8, 0))).To(BeFalse())
+			Expect(structmatcher.EqualFold()("Foo", "fOO")).To(BeTrue())
+			Expect(structmatcher.EqualFold()("Foo", "bar")).To(BeFalse())
+		})
+		It("fall back to exact equality for values of other types", func() {
+			Expect(structmatcher.WithinTolerance(1)("a", "a")).To(BeTrue())
+			Expect(structmatcher.WithinDuration(time.Hour)(1, 2)).To(BeFalse())
+			Expect(structmatcher.EqualFold()(1, 1)).To(BeTrue())
+		})
+	})
+})
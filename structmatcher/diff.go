@@ -0,0 +1,267 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package structmatcher
+
+/*
+ * This file contains functions for describing the differences between two
+ * values field by field, so that a mismatch deep within a large struct can
+ * be found without comparing dumps of both structs by eye.
+ */
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+/*
+ * Diff returns one line for each difference between expected and actual, in
+ * the form
+ *
+ *   Field .Options[2].Name: expected 'a', got 'b'
+ *
+ * Structs, pointers, slices, arrays, and maps are compared element by element,
+ * including unexported struct fields but not fields tagged with
+ * `structmatcher:"ignore"`; elements present in only one value are
+ * reported as <missing>.  Strings are quoted with single quotes and other
+ * values are formatted with %+v.  Diff returns no lines if the values are
+ * deeply equal.
+ */
+func Diff(expected interface{}, actual interface{}) []string {
+	return differenceStrings(Differences(expected, actual))
+}
+
+/*
+ * A Difference is one difference found by Differences, for validation tools
+ * that report differences somewhere other than a test failure, e.g. as JSON.
+ * Path is the path of the differing field or element without a leading ".",
+ * or "" for the values themselves.  Expected and Actual are formatted as in
+ * Diff's lines, and Type is the Go type of the expected value, or of the
+ * actual value if the expected one is missing; ActualType is only set if the
+ * actual value has a different type.
+ */
+type Difference struct {
+	Path       string `json:"path"`
+	Expected   string `json:"expected"`
+	Actual     string `json:"actual"`
+	Type       string `json:"type"`
+	ActualType string `json:"actual_type,omitempty"`
+}
+
+// String formats the difference as a line returned by Diff
+func (difference Difference) String() string {
+	if difference.Path == "" {
+		return fmt.Sprintf("Value: expected %s, got %s", difference.Expected, difference.Actual)
+	}
+	path := difference.Path
+	if !strings.HasPrefix(path, "[") {
+		path = "." + path
+	}
+	return fmt.Sprintf("Field %s: expected %s, got %s", path, difference.Expected, difference.Actual)
+}
+
+// Differences is Diff, returning each difference as a Difference rather than as a line
+func Differences(expected interface{}, actual interface{}) []Difference {
+	return diffValues(nil, "", reflect.ValueOf(expected), reflect.ValueOf(actual))
+}
+
+func differenceStrings(differences []Difference) []string {
+	lines := make([]string, len(differences))
+	for i, difference := range differences {
+		lines[i] = difference.String()
+	}
+	return lines
+}
+
+/*
+ * diffValues is Differences for values at path, comparing values with the
+ * comparator registered for their path or type in comparators, if there is
+ * one.
+ */
+func diffValues(comparators *comparatorSet, path string, expected reflect.Value, actual reflect.Value) []Difference {
+	if !expected.IsValid() || !actual.IsValid() {
+		if expected.IsValid() == actual.IsValid() {
+			return nil
+		}
+		return []Difference{difference(path, expected, actual)}
+	}
+	if expected.Type() != actual.Type() {
+		typeDifference := difference(path, expected, actual)
+		typeDifference.Expected = fmt.Sprintf("%s %s", expected.Type(), typeDifference.Expected)
+		typeDifference.Actual = fmt.Sprintf("%s %s", actual.Type(), typeDifference.Actual)
+		return []Difference{typeDifference}
+	}
+	if comparator := comparators.find(path, expected.Type()); comparator != nil && expected.CanInterface() && actual.CanInterface() {
+		if comparator(expected.Interface(), actual.Interface()) {
+			return nil
+		}
+		return []Difference{difference(path, expected, actual)}
+	}
+
+	switch expected.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if expected.IsNil() || actual.IsNil() {
+			if expected.IsNil() == actual.IsNil() {
+				return nil
+			}
+			return []Difference{difference(path, expected, actual)}
+		}
+		return diffValues(comparators, path, expected.Elem(), actual.Elem())
+	case reflect.Struct:
+		differences := make([]Difference, 0)
+		for i := 0; i < expected.NumField(); i++ {
+			if isIgnored(expected.Type().Field(i)) {
+				continue
+			}
+			fieldPath := fmt.Sprintf("%s.%s", path, expected.Type().Field(i).Name)
+			differences = append(differences, diffValues(comparators, fieldPath, expected.Field(i), actual.Field(i))...)
+		}
+		return differences
+	case reflect.Slice, reflect.Array:
+		if expected.Kind() == reflect.Slice && expected.IsNil() != actual.IsNil() && expected.Len() == 0 && actual.Len() == 0 {
+			return []Difference{difference(path, expected, actual)}
+		}
+		differences := make([]Difference, 0)
+		for i := 0; i < expected.Len() || i < actual.Len(); i++ {
+			elementPath := fmt.Sprintf("%s[%d]", path, i)
+			if i >= actual.Len() {
+				differences = append(differences, difference(elementPath, expected.Index(i), reflect.Value{}))
+			} else if i >= expected.Len() {
+				differences = append(differences, difference(elementPath, reflect.Value{}, actual.Index(i)))
+			} else {
+				differences = append(differences, diffValues(comparators, elementPath, expected.Index(i), actual.Index(i))...)
+			}
+		}
+		return differences
+	case reflect.Map:
+		if expected.IsNil() != actual.IsNil() && expected.Len() == 0 && actual.Len() == 0 {
+			return []Difference{difference(path, expected, actual)}
+		}
+		differences := make([]Difference, 0)
+		for _, key := range mapKeys(expected, actual) {
+			elementPath := fmt.Sprintf("%s[%s]", path, formatValue(key))
+			expectedElement, actualElement := expected.MapIndex(key), actual.MapIndex(key)
+			if !actualElement.IsValid() {
+				differences = append(differences, difference(elementPath, expectedElement, reflect.Value{}))
+			} else if !expectedElement.IsValid() {
+				differences = append(differences, difference(elementPath, reflect.Value{}, actualElement))
+			} else {
+				differences = append(differences, diffValues(comparators, elementPath, expectedElement, actualElement)...)
+			}
+		}
+		return differences
+	}
+
+	if !valuesEqual(expected, actual) {
+		return []Difference{difference(path, expected, actual)}
+	}
+	return nil
+}
+
+/*
+ * difference describes the difference between expected and actual at path.
+ * Below the top level, an invalid value is an element missing from a slice,
+ * array, or map; at the top level, it is a nil value given to Diff.
+ */
+func difference(path string, expected reflect.Value, actual reflect.Value) Difference {
+	formatElement := func(value reflect.Value) string {
+		if !value.IsValid() && path != "" {
+			return "<missing>"
+		}
+		return formatValue(value)
+	}
+	result := Difference{Path: strings.TrimPrefix(path, "."), Expected: formatElement(expected), Actual: formatElement(actual)}
+	if expected.IsValid() {
+		result.Type = expected.Type().String()
+		if actual.IsValid() && actual.Type() != expected.Type() {
+			result.ActualType = actual.Type().String()
+		}
+	} else if actual.IsValid() {
+		result.Type = actual.Type().String()
+	}
+	return result
+}
+
+// mapKeys returns the keys of both maps, sorted by their formatted values so that differences are listed in a stable order
+func mapKeys(expected reflect.Value, actual reflect.Value) []reflect.Value {
+	keys := make([]reflect.Value, 0)
+	seen := make(map[string]bool)
+	for _, value := range []reflect.Value{expected, actual} {
+		for _, key := range value.MapKeys() {
+			formatted := fmt.Sprintf("%#v", key)
+			if !seen[formatted] {
+				seen[formatted] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprintf("%v", keys[i]) < fmt.Sprintf("%v", keys[j])
+	})
+	return keys
+}
+
+/*
+ * valuesEqual compares two values of the same type that are not containers.
+ * Values read from unexported fields cannot be converted back to interfaces,
+ * so those are compared by kind instead of with reflect.DeepEqual.
+ */
+func valuesEqual(expected reflect.Value, actual reflect.Value) bool {
+	if expected.CanInterface() && actual.CanInterface() {
+		return reflect.DeepEqual(expected.Interface(), actual.Interface())
+	}
+	switch expected.Kind() {
+	case reflect.Bool:
+		return expected.Bool() == actual.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return expected.Int() == actual.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return expected.Uint() == actual.Uint()
+	case reflect.Float32, reflect.Float64:
+		return expected.Float() == actual.Float()
+	case reflect.Complex64, reflect.Complex128:
+		return expected.Complex() == actual.Complex()
+	case reflect.String:
+		return expected.String() == actual.String()
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return expected.Pointer() == actual.Pointer()
+	}
+	return fmt.Sprintf("%#v", expected) == fmt.Sprintf("%#v", actual)
+}
+
+func formatValue(value reflect.Value) string {
+	if !value.IsValid() {
+		return "nil"
+	}
+	switch value.Kind() {
+	case reflect.String:
+		return fmt.Sprintf("'%s'", value.String())
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+		if value.IsNil() {
+			return "nil"
+		}
+		if value.Kind() == reflect.Interface {
+			return formatValue(value.Elem())
+		}
+		if value.Kind() == reflect.Pointer {
+			return "&" + formatValue(value.Elem())
+		}
+	}
+	return fmt.Sprintf("%+v", value)
+}
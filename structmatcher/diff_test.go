@@ -0,0 +1,156 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package structmatcher_test
+
+import (
+	"github.com/apache/cloudberry-go-libs/structmatcher"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("structmatcher/diff tests", func() {
+	type Option struct {
+		Name  string
+		Value string
+	}
+	type Table struct {
+		Oid     uint32
+		Name    string
+		Options []Option
+		Columns []string
+		Stats   map[string]int
+		Owner   *string
+		Extra   interface{}
+	}
+	owner := "gpadmin"
+
+	Describe("Diff", func() {
+		It("returns nothing for equal values", func() {
+			table := Table{Oid: 1, Options: []Option{{Name: "a"}}, Stats: map[string]int{"rows": 1}, Owner: &owner}
+			copied := table
+			copied.Owner = &[]string{"gpadmin"}[0]
+			Expect(structmatcher.Diff(table, copied)).To(BeEmpty())
+		})
+		It("names each differing field by its full path", func() {
+			expected := Table{Oid: 1, Name: "foo", Options: []Option{{"fillfactor", "90"}, {"appendonly", "true"}, {"a", "x"}}}
+			actual := Table{Oid: 1, Name: "bar", Options: []Option{{"fillfactor", "90"}, {"appendonly", "true"}, {"b", "x"}}}
+			Expect(structmatcher.Diff(expected, actual)).To(Equal([]string{
+				"Field .Name: expected 'foo', got 'bar'",
+				"Field .Options[2].Name: expected 'a', got 'b'",
+			}))
+		})
+		It("reports elements present in only one slice or map", func() {
+			expected := Table{Columns: []string{"a", "b"}, Stats: map[string]int{"rows": 10, "pages": 2}}
+			actual := Table{Columns: []string{"a", "b", "c"}, Stats: map[string]int{"rows": 12, "width": 4}}
+			Expect(structmatcher.Diff(expected, actual)).To(Equal([]string{
+				"Field .Columns[2]: expected <missing>, got 'c'",
+				"Field .Stats['pages']: expected 2, got <missing>",
+				"Field .Stats['rows']: expected 10, got 12",
+				"Field .Stats['width']: expected <missing>, got 4",
+			}))
+		})
+		It("distinguishes nil from empty and reports differing dynamic types", func() {
+			expected := Table{Columns: nil, Owner: &owner, Extra: 1}
+			actual := Table{Columns: []string{}, Owner: nil, Extra: "1"}
+			Expect(structmatcher.Diff(expected, actual)).To(Equal([]string{
+				"Field .Columns: expected nil, got []",
+				"Field .Owner: expected &'gpadmin', got nil",
+				"Field .Extra: expected int 1, got string '1'",
+			}))
+		})
+		It("leaves out fields tagged to be ignored", func() {
+			type Timestamped struct {
+				Name      string
+				UpdatedAt int64 `structmatcher:"ignore"`
+			}
+			Expect(structmatcher.Diff(Timestamped{"a", 1}, Timestamped{"b", 2})).To(Equal([]string{"Field .Name: expected 'a', got 'b'"}))
+		})
+		It("compares values that are not structs", func() {
+			Expect(structmatcher.Diff(3, 4)).To(Equal([]string{"Value: expected 3, got 4"}))
+			Expect(structmatcher.Diff([]int{1, 2}, []int{1, 3})).To(Equal([]string{"Field [1]: expected 2, got 3"}))
+		})
+	})
+	Describe("Differences", func() {
+		It("returns a record for each difference", func() {
+			expected := Table{Name: "foo", Columns: []string{"a"}, Extra: 1}
+			actual := Table{Name: "bar", Columns: []string{"a", "b"}, Extra: "1"}
+			Expect(structmatcher.Differences(expected, actual)).To(Equal([]structmatcher.Difference{
+				{Path: "Name", Expected: "'foo'", Actual: "'bar'", Type: "string"},
+				{Path: "Columns[1]", Expected: "<missing>", Actual: "'b'", Type: "string"},
+				{Path: "Extra", Expected: "int 1", Actual: "string '1'", Type: "int", ActualType: "string"},
+			}))
+		})
+		It("formats each record as the matching line from Diff", func() {
+			expected := Table{Name: "foo", Stats: map[string]int{"rows": 1}}
+			actual := Table{Name: "bar", Stats: map[string]int{"rows": 2}}
+			lines := make([]string, 0)
+			for _, difference := range structmatcher.Differences(expected, actual) {
+				lines = append(lines, difference.String())
+			}
+			Expect(lines).To(Equal(structmatcher.Diff(expected, actual)))
+			Expect(structmatcher.Differences(3, 4)[0].String()).To(Equal("Value: expected 3, got 4"))
+			Expect(structmatcher.Differences([]int{1}, []int{2})[0].String()).To(Equal("Field [0]: expected 1, got 2"))
+		})
+	})
+	Describe("MatchStruct", func() {
+		It("describes differences within slices instead of printing both slices", func() {
+			expected := Table{Columns: []string{"a", "b", "c"}, Extra: 0}
+			actual := Table{Columns: []string{"a", "x", "c"}, Extra: 0}
+			messages := InterceptGomegaFailures(func() {
+				Expect(actual).To(structmatcher.MatchStruct(expected))
+			})
+			Expect(messages).To(Equal([]string{"Expected structs to match but:\nField .Columns[1]: expected 'b', got 'x'"}))
+		})
+		It("lists differences as JSON records if asked to", func() {
+			expected := Table{Name: "foo", Columns: []string{"a"}}
+			actual := Table{Name: "bar", Columns: []string{"a"}}
+			messages := InterceptGomegaFailures(func() {
+				Expect(actual).To(structmatcher.MatchStruct(expected).WithOutputFormat(structmatcher.JSON_OUTPUT))
+			})
+			Expect(messages).To(Equal([]string{`Expected structs to match but:
+{"path":"Name","expected":"'foo'","actual":"'bar'","type":"string"}`}))
+
+			messages = InterceptGomegaFailures(func() {
+				Expect(actual).To(structmatcher.MatchStruct(expected).WithOutputFormat(structmatcher.TEXT_AND_JSON_OUTPUT))
+			})
+			Expect(messages).To(Equal([]string{`Expected structs to match but:
+Field .Name: expected 'foo', got 'bar'
+As JSON:
+{"path":"Name","expected":"'foo'","actual":"'bar'","type":"string"}`}))
+		})
+		It("returns the differences found by the last match", func() {
+			type Secret struct {
+				Name  string
+				value int
+			}
+			matcher := structmatcher.MatchStruct(Secret{"a", 1}).ExcludingFields("Name")
+			success, err := matcher.Match(Secret{"b", 2})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(success).To(BeFalse())
+			Expect(matcher.Differences()).To(Equal([]structmatcher.Difference{
+				{Path: "value", Expected: "1", Actual: "2", Type: "int"},
+			}))
+
+			success, err = matcher.Match(Secret{"b", 1})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(success).To(BeTrue())
+			Expect(matcher.Differences()).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,238 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package structmatcher
+
+/*
+ * This file contains functions for comparing two streams of rows, such as the
+ * contents of a table before a backup and after a restore, without holding
+ * either stream in memory.
+ */
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/*
+ * A RowIterator returns rows one at a time.  Next returns ok=false once there
+ * are no more rows; an error stops the comparison.
+ */
+type RowIterator interface {
+	Next() (row interface{}, ok bool, err error)
+}
+
+type sliceIterator struct {
+	rows  reflect.Value
+	index int
+}
+
+// NewSliceIterator returns a RowIterator over the elements of a slice, for rows that are already in memory
+func NewSliceIterator(rows interface{}) RowIterator {
+	value := reflect.ValueOf(rows)
+	if value.Kind() != reflect.Slice {
+		panic(fmt.Sprintf("NewSliceIterator requires a slice, got %T", rows))
+	}
+	return &sliceIterator{rows: value}
+}
+
+func (iter *sliceIterator) Next() (interface{}, bool, error) {
+	if iter.index >= iter.rows.Len() {
+		return nil, false, nil
+	}
+	row := iter.rows.Index(iter.index).Interface()
+	iter.index++
+	return row, true, nil
+}
+
+/*
+ * StreamOptions controls MatchRowStreams.  IncludingFields and
+ * ExcludingFields behave as they do for the Matcher, and only one of them
+ * should be set.  MaxDifferences limits how many missing, extra, and
+ * differing keys are kept in the result, so that comparing two very different
+ * streams does not use unbounded memory; 0 means no limit.  CompareKeys
+ * defines the order of the streams and defaults to strings.Compare.
+ */
+type StreamOptions struct {
+	IncludingFields []string
+	ExcludingFields []string
+	MaxDifferences  int
+	CompareKeys     func(a string, b string) int
+}
+
+type RowDifference struct {
+	Key        string
+	Mismatches []string
+}
+
+/*
+ * A StreamResult holds the outcome of MatchRowStreams.  The Num* counts cover
+ * every row compared, while Missing, Extra, and Differing only hold the first
+ * keys found, up to StreamOptions.MaxDifferences in total.
+ */
+type StreamResult struct {
+	NumExpected  int
+	NumActual    int
+	NumMissing   int
+	NumExtra     int
+	NumDiffering int
+	Missing      []string
+	Extra        []string
+	Differing    []RowDifference
+}
+
+func (result *StreamResult) Matches() bool {
+	return result.NumMissing == 0 && result.NumExtra == 0 && result.NumDiffering == 0
+}
+
+func (result *StreamResult) Truncated() bool {
+	return len(result.Missing)+len(result.Extra)+len(result.Differing) < result.NumMissing+result.NumExtra+result.NumDiffering
+}
+
+// Report returns a summary of the differences found, one per line, for printing
+func (result *StreamResult) Report() string {
+	lines := []string{fmt.Sprintf("Compared %d expected and %d actual rows: %d missing, %d extra, %d differing",
+		result.NumExpected, result.NumActual, result.NumMissing, result.NumExtra, result.NumDiffering)}
+	for _, key := range result.Missing {
+		lines = append(lines, fmt.Sprintf("Missing row with key %s", key))
+	}
+	for _, key := range result.Extra {
+		lines = append(lines, fmt.Sprintf("Extra row with key %s", key))
+	}
+	for _, difference := range result.Differing {
+		lines = append(lines, fmt.Sprintf("Row with key %s differs:\n%s", difference.Key, strings.Join(difference.Mismatches, "\n")))
+	}
+	if result.Truncated() {
+		lines = append(lines, "Further differences were not recorded")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// orderedIterator checks that keys are strictly increasing as rows are read
+type orderedIterator struct {
+	iter    RowIterator
+	name    string
+	keyFn   func(row interface{}) string
+	compare func(a string, b string) int
+	row     interface{}
+	key     string
+	ok      bool
+	started bool
+	count   int
+}
+
+func (ordered *orderedIterator) advance() error {
+	row, ok, err := ordered.iter.Next()
+	if err != nil {
+		return errors.Wrapf(err, "Unable to read row from %s stream", ordered.name)
+	}
+	if !ok {
+		ordered.row, ordered.ok = nil, false
+		return nil
+	}
+	key := ordered.keyFn(row)
+	if ordered.started && ordered.compare(ordered.key, key) >= 0 {
+		return errors.Errorf("Rows in %s stream are not in increasing key order: key %s follows key %s", ordered.name, key, ordered.key)
+	}
+	ordered.row, ordered.key, ordered.ok, ordered.started = row, key, true, true
+	ordered.count++
+	return nil
+}
+
+/*
+ * MatchRowStreams compares two streams of rows that are both ordered by the
+ * key returned by keyFn, with each key appearing at most once in a stream.
+ * Rows are matched up by key and rows with the same key are compared field by
+ * field as StructMatcher does, so only the current row of each stream is held
+ * in memory.  An error is returned if either stream returns an error or is
+ * not ordered, along with the result for the rows compared up to that point.
+ */
+func MatchRowStreams(expectedIter RowIterator, actualIter RowIterator, keyFn func(row interface{}) string, options StreamOptions) (*StreamResult, error) {
+	compare := options.CompareKeys
+	if compare == nil {
+		compare = strings.Compare
+	}
+	expected := &orderedIterator{iter: expectedIter, name: "expected", keyFn: keyFn, compare: compare}
+	actual := &orderedIterator{iter: actualIter, name: "actual", keyFn: keyFn, compare: compare}
+	result := &StreamResult{Missing: []string{}, Extra: []string{}, Differing: []RowDifference{}}
+	canRecord := func() bool {
+		return options.MaxDifferences <= 0 || len(result.Missing)+len(result.Extra)+len(result.Differing) < options.MaxDifferences
+	}
+	finish := func(err error) (*StreamResult, error) {
+		result.NumExpected, result.NumActual = expected.count, actual.count
+		return result, err
+	}
+
+	if err := expected.advance(); err != nil {
+		return finish(err)
+	}
+	if err := actual.advance(); err != nil {
+		return finish(err)
+	}
+	for expected.ok || actual.ok {
+		var order int
+		switch {
+		case !actual.ok:
+			order = -1
+		case !expected.ok:
+			order = 1
+		default:
+			order = compare(expected.key, actual.key)
+		}
+
+		if order < 0 {
+			result.NumMissing++
+			if canRecord() {
+				result.Missing = append(result.Missing, expected.key)
+			}
+		} else if order > 0 {
+			result.NumExtra++
+			if canRecord() {
+				result.Extra = append(result.Extra, actual.key)
+			}
+		} else if mismatches := matchRows(expected.row, actual.row, options); len(mismatches) > 0 {
+			result.NumDiffering++
+			if canRecord() {
+				result.Differing = append(result.Differing, RowDifference{Key: expected.key, Mismatches: mismatches})
+			}
+		}
+
+		if order <= 0 {
+			if err := expected.advance(); err != nil {
+				return finish(err)
+			}
+		}
+		if order >= 0 {
+			if err := actual.advance(); err != nil {
+				return finish(err)
+			}
+		}
+	}
+	return finish(nil)
+}
+
+func matchRows(expected interface{}, actual interface{}, options StreamOptions) []string {
+	if options.IncludingFields != nil {
+		return StructMatcher(expected, actual, true, true, options.IncludingFields...)
+	} else if options.ExcludingFields != nil {
+		return StructMatcher(expected, actual, true, false, options.ExcludingFields...)
+	}
+	return StructMatcher(expected, actual, false, false)
+}
@@ -0,0 +1,131 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package structmatcher_test
+
+import (
+	"strconv"
+
+	"github.com/apache/cloudberry-go-libs/structmatcher"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type erroringIterator struct {
+	rows []interface{}
+}
+
+func (iter *erroringIterator) Next() (interface{}, bool, error) {
+	if len(iter.rows) == 0 {
+		return nil, false, errors.New("connection lost")
+	}
+	row := iter.rows[0]
+	iter.rows = iter.rows[1:]
+	return row, true, nil
+}
+
+var _ = Describe("structmatcher/stream tests", func() {
+	type Row struct {
+		ID    int
+		Name  string
+		Value string
+	}
+	keyFn := func(row interface{}) string {
+		return strconv.Itoa(row.(Row).ID)
+	}
+
+	Describe("MatchRowStreams", func() {
+		It("matches identical streams", func() {
+			rows := []Row{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}
+			result, err := structmatcher.MatchRowStreams(structmatcher.NewSliceIterator(rows), structmatcher.NewSliceIterator(rows), keyFn, structmatcher.StreamOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Matches()).To(BeTrue())
+			Expect(result.NumExpected).To(Equal(2))
+			Expect(result.NumActual).To(Equal(2))
+		})
+		It("matches empty streams", func() {
+			result, err := structmatcher.MatchRowStreams(structmatcher.NewSliceIterator([]Row{}), structmatcher.NewSliceIterator([]Row{}), keyFn, structmatcher.StreamOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Matches()).To(BeTrue())
+		})
+		It("reports missing, extra, and differing keys", func() {
+			expected := []Row{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}, {ID: 4, Name: "d"}, {ID: 6, Name: "f"}}
+			actual := []Row{{ID: 2, Name: "b"}, {ID: 3, Name: "c"}, {ID: 4, Name: "x"}, {ID: 7, Name: "g"}}
+			result, err := structmatcher.MatchRowStreams(structmatcher.NewSliceIterator(expected), structmatcher.NewSliceIterator(actual), keyFn, structmatcher.StreamOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Matches()).To(BeFalse())
+			Expect(result.Missing).To(Equal([]string{"1", "6"}))
+			Expect(result.Extra).To(Equal([]string{"3", "7"}))
+			Expect(result.Differing).To(HaveLen(1))
+			Expect(result.Differing[0].Key).To(Equal("4"))
+			Expect(structmatcher.MismatchedFields(result.Differing[0].Mismatches)).To(Equal([]string{"Name"}))
+			Expect(result.Truncated()).To(BeFalse())
+			Expect(result.Report()).To(HavePrefix("Compared 4 expected and 4 actual rows: 2 missing, 2 extra, 1 differing\nMissing row with key 1\n"))
+		})
+		It("filters fields when comparing rows", func() {
+			expected := []Row{{ID: 1, Name: "a", Value: "x"}}
+			actual := []Row{{ID: 1, Name: "a", Value: "y"}}
+			result, err := structmatcher.MatchRowStreams(structmatcher.NewSliceIterator(expected), structmatcher.NewSliceIterator(actual), keyFn, structmatcher.StreamOptions{ExcludingFields: []string{"Value"}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Matches()).To(BeTrue())
+
+			result, err = structmatcher.MatchRowStreams(structmatcher.NewSliceIterator(expected), structmatcher.NewSliceIterator(actual), keyFn, structmatcher.StreamOptions{IncludingFields: []string{"Value"}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.NumDiffering).To(Equal(1))
+		})
+		It("limits the number of differences recorded but keeps counting", func() {
+			expected := []Row{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}}
+			result, err := structmatcher.MatchRowStreams(structmatcher.NewSliceIterator(expected), structmatcher.NewSliceIterator([]Row{}), keyFn, structmatcher.StreamOptions{MaxDifferences: 2})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.NumMissing).To(Equal(4))
+			Expect(result.Missing).To(Equal([]string{"1", "2"}))
+			Expect(result.Truncated()).To(BeTrue())
+			Expect(result.Report()).To(HaveSuffix("Further differences were not recorded"))
+		})
+		It("uses a custom key order", func() {
+			expected := []Row{{ID: 9}, {ID: 10}}
+			actual := []Row{{ID: 9}, {ID: 10}}
+			numericOrder := func(a string, b string) int {
+				x, _ := strconv.Atoi(a)
+				y, _ := strconv.Atoi(b)
+				return x - y
+			}
+			result, err := structmatcher.MatchRowStreams(structmatcher.NewSliceIterator(expected), structmatcher.NewSliceIterator(actual), keyFn, structmatcher.StreamOptions{CompareKeys: numericOrder})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Matches()).To(BeTrue())
+		})
+		It("returns an error if a stream is not ordered by key", func() {
+			expected := []Row{{ID: 2}, {ID: 1}}
+			result, err := structmatcher.MatchRowStreams(structmatcher.NewSliceIterator(expected), structmatcher.NewSliceIterator([]Row{{ID: 2}}), keyFn, structmatcher.StreamOptions{})
+			Expect(err).To(MatchError("Rows in expected stream are not in increasing key order: key 1 follows key 2"))
+			Expect(result.NumExpected).To(Equal(1))
+		})
+		It("returns an error if a stream has a duplicate key", func() {
+			actual := []Row{{ID: 1}, {ID: 1}}
+			_, err := structmatcher.MatchRowStreams(structmatcher.NewSliceIterator([]Row{{ID: 1}}), structmatcher.NewSliceIterator(actual), keyFn, structmatcher.StreamOptions{})
+			Expect(err).To(MatchError("Rows in actual stream are not in increasing key order: key 1 follows key 1"))
+		})
+		It("returns an error if an iterator fails", func() {
+			actual := &erroringIterator{rows: []interface{}{Row{ID: 1}}}
+			result, err := structmatcher.MatchRowStreams(structmatcher.NewSliceIterator([]Row{{ID: 1}, {ID: 2}}), actual, keyFn, structmatcher.StreamOptions{})
+			Expect(err).To(MatchError("Unable to read row from actual stream: connection lost"))
+			Expect(result.NumActual).To(Equal(1))
+		})
+	})
+})
@@ -5,6 +5,7 @@ package structmatcher
  */
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"strings"
@@ -17,90 +18,181 @@ import (
  * If fields are to be filtered in or out, set shouldFilter to true; filterInclude is true to
  * include fields or false to exclude fields, and filterFields contains the field names to filter on.
  * To filter on a field "fieldname" in struct "structname", pass in "fieldname".
- * To filter on a field "fieldname" in a nested struct under field "structfield", pass in "structfield.fieldname".
- * This function assumes structs will only ever be nested one level deep.
+ * To filter on a field "fieldname" in a nested struct under field "structfield", pass in "structfield.fieldname",
+ * and so on for structs nested more deeply.  A field holding a slice of structs is filtered in each element, which
+ * may be written either as "slicefield.fieldname" or as "slicefield[*].fieldname".
+ * Fields tagged with `structmatcher:"ignore"` are never compared.
  */
 func StructMatcher(expected, actual interface{}, shouldFilter bool, filterInclude bool, filterFields ...string) []string {
-	return structMatcher(reflect.ValueOf(expected), reflect.ValueOf(actual), "", shouldFilter, filterInclude, filterFields...)
-}
-
-func structMatcher(expected, actual reflect.Value, fieldPath string, shouldFilter bool, filterInclude bool, filterFields ...string) []string {
-	// Add field names for the top-level struct to a filter map, and split off nested field names to pass down to nested structs
-	filterMap := make(map[string]bool)
-	nestedFilterFields := make([]string, 0)
-	for i := 0; i < len(filterFields); i++ {
-		fieldNames := strings.Split(filterFields[i], ".")
-		if len(fieldNames) == 2 {
-			nestedFilterFields = append(nestedFilterFields, fieldNames[1])
-			// If we include a nested struct field, we also need to include the nested struct
-			if filterInclude {
-				filterMap[fieldNames[0]] = true
-			}
+	return mismatchMessages(structMatcher(reflect.ValueOf(expected), reflect.ValueOf(actual), "", nil, shouldFilter, filterInclude, filterFields...))
+}
+
+/*
+ * A mismatch holds the message for one failed comparison along with the path
+ * and values that were compared, so that MatchStruct can describe exactly
+ * where within those values they differ.  If fields is set, the values are
+ * structs of which only those fields were compared.
+ */
+type mismatch struct {
+	message     string
+	path        string
+	expected    reflect.Value
+	actual      reflect.Value
+	fields      []int
+	comparators *comparatorSet
+}
+
+func mismatchMessages(mismatches []mismatch) []string {
+	messages := make([]string, len(mismatches))
+	for i, mismatch := range mismatches {
+		messages[i] = mismatch.message
+	}
+	return messages
+}
+
+// isIgnored returns whether field is tagged to be left out of comparisons
+func isIgnored(field reflect.StructField) bool {
+	return field.Tag.Get("structmatcher") == "ignore"
+}
+
+/*
+ * A fieldFilter holds the filter paths that apply to one struct: the fields
+ * named in full, and the paths that continue into each field.
+ */
+type fieldFilter struct {
+	fields map[string]bool
+	nested map[string][]string
+}
+
+func newFieldFilter(filterFields []string) fieldFilter {
+	filter := fieldFilter{fields: make(map[string]bool), nested: make(map[string][]string)}
+	for _, filterField := range filterFields {
+		fieldName, rest, isNested := strings.Cut(filterField, ".")
+		fieldName = strings.TrimSuffix(fieldName, "[*]")
+		if isNested {
+			filter.nested[fieldName] = append(filter.nested[fieldName], rest)
 		} else {
-			filterMap[filterFields[i]] = true
+			filter.fields[fieldName] = true
 		}
 	}
+	return filter
+}
+
+func structMatcher(expected, actual reflect.Value, fieldPath string, comparators *comparatorSet, shouldFilter bool, filterInclude bool, filterFields ...string) []mismatch {
+	filter := newFieldFilter(filterFields)
 	expectedStruct := reflect.Indirect(expected)
 	actualStruct := reflect.Indirect(actual)
-	mismatches := []string{}
-	mismatches = append(mismatches, InterceptGomegaFailures(func() {
-		structCanInterface := true
-		for i := 0; i < expectedStruct.NumField(); i++ {
-			expectedField := reflect.Indirect(expectedStruct.Field(i))
-			actualField := reflect.Indirect(actualStruct.Field(i))
-			fieldName := actualStruct.Type().Field(i).Name
-			// If we're including, skip this field if the name doesn't match; if we're excluding, skip if it does match
-			if shouldFilter && ((filterInclude && !filterMap[fieldName]) || (!filterInclude && filterMap[fieldName])) {
+	mismatches := []mismatch{}
+	// Mismatches in nested structs are listed before those in this struct
+	directMismatches := []mismatch{}
+	compare := func(path string, expectedValue reflect.Value, actualValue reflect.Value, fields []int, description ...interface{}) {
+		// Gomega does not know about ignored fields or comparators, so only use it to describe values that really differ
+		differences := fieldDifferences(comparators, path, expectedValue, actualValue, fields)
+		if len(differences) == 0 {
+			return
+		}
+		failures := InterceptGomegaFailures(func() {
+			Expect(actualValue.Interface()).To(Equal(expectedValue.Interface()), description...)
+		})
+		if len(failures) == 0 {
+			// A comparator can be stricter than gomega.Equal
+			failures = []string{strings.Join(differenceStrings(differences), "\n")}
+		}
+		for _, failure := range failures {
+			directMismatches = append(directMismatches, mismatch{message: failure, path: path, expected: expectedValue, actual: actualValue, fields: fields, comparators: comparators})
+		}
+	}
+	unexportedFields := make([]int, 0)
+	for i := 0; i < expectedStruct.NumField(); i++ {
+		expectedField := reflect.Indirect(expectedStruct.Field(i))
+		actualField := reflect.Indirect(actualStruct.Field(i))
+		structField := actualStruct.Type().Field(i)
+		fieldName := structField.Name
+		if isIgnored(structField) {
+			continue
+		}
+		/*
+		 * If we're including, skip this field unless it or a path within it is included, and compare all of it
+		 * if it is included as a whole; if we're excluding, skip it only if it is excluded as a whole.
+		 */
+		nestedShouldFilter := shouldFilter
+		nestedFilterFields := filter.nested[fieldName]
+		if shouldFilter && filterInclude {
+			if filter.fields[fieldName] {
+				nestedShouldFilter = false
+			} else if len(nestedFilterFields) == 0 {
 				continue
 			}
-			actualFieldIsNonemptySlice := actualField.Kind() == reflect.Slice && !actualField.IsNil() && actualField.Len() > 0
-			expectedFieldIsNonemptySlice := expectedField.Kind() == reflect.Slice && !expectedField.IsNil() && expectedField.Len() > 0
-			fieldIsStructSlice := actualFieldIsNonemptySlice && expectedFieldIsNonemptySlice && actualField.Len() == expectedField.Len() && actualField.Index(0).Kind() == reflect.Struct
-
-			expectedFieldIsNilPtr := expectedStruct.Field(i).Kind() == reflect.Pointer && expectedStruct.Field(i).IsNil()
-			actualFieldIsNilPtr := actualStruct.Field(i).Kind() == reflect.Pointer && actualStruct.Field(i).IsNil()
-
-			if fieldIsStructSlice {
-				for j := 0; j < actualField.Len(); j++ {
-					expectedStructField := expectedStruct.Field(i).Index(j)
-					actualStructField := actualStruct.Field(i).Index(j)
-					subFieldPath := fmt.Sprintf("%s%s[%d].", fieldPath, fieldName, j)
-					mismatches = append(mismatches, structMatcher(expectedStructField, actualStructField, subFieldPath, shouldFilter, filterInclude, nestedFilterFields...)...)
-				}
-			} else if actualFieldIsNilPtr != expectedFieldIsNilPtr {
-				expectedValue := expectedStruct.Field(i).Interface()
-				actualValue := actualStruct.Field(i).Interface()
-				Expect(actualValue).To(Equal(expectedValue), "Mismatch on field %s%s", fieldPath, fieldName)
-			} else if expectedStruct.Field(i).CanInterface() {
-				if actualField.Kind() == reflect.Struct {
-					expectedStructField := expectedStruct.Field(i)
-					actualStructField := actualStruct.Field(i)
-					subFieldPath := fmt.Sprintf("%s%s.", fieldPath, fieldName)
-					mismatches = append(mismatches, structMatcher(expectedStructField, actualStructField, subFieldPath, shouldFilter, filterInclude, nestedFilterFields...)...)
-				} else {
-					expectedValue := expectedStruct.Field(i).Interface()
-					actualValue := actualStruct.Field(i).Interface()
-					Expect(actualValue).To(Equal(expectedValue), "Mismatch on field %s%s", fieldPath, fieldName)
-				}
+		} else if shouldFilter && filter.fields[fieldName] {
+			continue
+		}
+		actualFieldIsNonemptySlice := actualField.Kind() == reflect.Slice && !actualField.IsNil() && actualField.Len() > 0
+		expectedFieldIsNonemptySlice := expectedField.Kind() == reflect.Slice && !expectedField.IsNil() && expectedField.Len() > 0
+		fieldIsStructSlice := actualFieldIsNonemptySlice && expectedFieldIsNonemptySlice && actualField.Len() == expectedField.Len() && actualField.Index(0).Kind() == reflect.Struct
+
+		expectedFieldIsNilPtr := expectedStruct.Field(i).Kind() == reflect.Pointer && expectedStruct.Field(i).IsNil()
+		actualFieldIsNilPtr := actualStruct.Field(i).Kind() == reflect.Pointer && actualStruct.Field(i).IsNil()
+
+		// A comparator for a struct or its elements compares it as a whole, so the struct is not compared field by field
+		comparesWhole := comparators.comparesWhole(fieldPath+fieldName, structField.Type)
+
+		if fieldIsStructSlice && !comparesWhole {
+			for j := 0; j < actualField.Len(); j++ {
+				expectedStructField := expectedStruct.Field(i).Index(j)
+				actualStructField := actualStruct.Field(i).Index(j)
+				subFieldPath := fmt.Sprintf("%s%s[%d].", fieldPath, fieldName, j)
+				mismatches = append(mismatches, structMatcher(expectedStructField, actualStructField, subFieldPath, comparators, nestedShouldFilter, filterInclude, nestedFilterFields...)...)
+			}
+		} else if actualFieldIsNilPtr != expectedFieldIsNilPtr {
+			compare(fieldPath+fieldName, expectedStruct.Field(i), actualStruct.Field(i), nil, "Mismatch on field %s%s", fieldPath, fieldName)
+		} else if expectedStruct.Field(i).CanInterface() {
+			if actualField.Kind() == reflect.Struct && !comparesWhole {
+				expectedStructField := expectedStruct.Field(i)
+				actualStructField := actualStruct.Field(i)
+				subFieldPath := fmt.Sprintf("%s%s.", fieldPath, fieldName)
+				mismatches = append(mismatches, structMatcher(expectedStructField, actualStructField, subFieldPath, comparators, nestedShouldFilter, filterInclude, nestedFilterFields...)...)
 			} else {
-				structCanInterface = false
+				compare(fieldPath+fieldName, expectedStruct.Field(i), actualStruct.Field(i), nil, "Mismatch on field %s%s", fieldPath, fieldName)
 			}
+		} else {
+			unexportedFields = append(unexportedFields, i)
+		}
 
+	}
+	// Unexported fields cannot be compared individually with Gomega, so the struct is compared as a whole if they differ
+	if len(unexportedFields) > 0 {
+		extra := []interface{}{
+			"Mismatch on unexported field within top level struct",
 		}
-		if !structCanInterface {
-			extra := []interface{}{
-				"Mismatch on unexported field within top level struct",
+		structName := strings.TrimSuffix(fieldPath, ".")
+		if fieldPath != "" {
+			extra = []interface{}{
+				"Mismatch on unexported field within %s", structName,
 			}
-			if fieldPath != "" {
-				structName := fieldPath[0 : len(fieldPath)-1] // remove trailing dot.
-				extra = []interface{}{
-					"Mismatch on unexported field within %s", structName,
-				}
-			}
-			Expect(actualStruct.Interface()).To(Equal(expectedStruct.Interface()), extra...)
 		}
-	})...)
-	return mismatches
+		compare(structName, expectedStruct, actualStruct, unexportedFields, extra...)
+	}
+	return append(mismatches, directMismatches...)
+}
+
+/*
+ * fieldDifferences describes the differences between expected and actual as
+ * Diff does, limited to the given fields of structs if any are given.  The
+ * path is relative to the top level struct, without a leading ".".
+ */
+func fieldDifferences(comparators *comparatorSet, path string, expected reflect.Value, actual reflect.Value, fields []int) []Difference {
+	if path != "" {
+		path = "." + path
+	}
+	if fields == nil {
+		return diffValues(comparators, path, expected, actual)
+	}
+	differences := make([]Difference, 0)
+	for _, i := range fields {
+		fieldPath := fmt.Sprintf("%s.%s", path, expected.Type().Field(i).Name)
+		differences = append(differences, diffValues(comparators, fieldPath, expected.Field(i), actual.Field(i))...)
+	}
+	return differences
 }
 
 // Deprecated: Use structmatcher.MatchStruct() GomegaMatcher
@@ -118,11 +210,75 @@ func ExpectStructsToMatchIncluding(expected interface{}, actual interface{}, inc
 	Expect(actual).To(MatchStruct(expected).IncludingFields(includeFields...))
 }
 
-type Matcher struct {
-	expected        interface{}
+// matchOptions holds the fields and comparators given to a matcher, shared by each of the matchers
+type matchOptions struct {
 	includingFields []string
 	excludingFields []string
-	mismatches      []string
+	comparators     *comparatorSet
+}
+
+func (options matchOptions) structMismatches(expected reflect.Value, actual reflect.Value) []mismatch {
+	if options.includingFields != nil {
+		return structMatcher(expected, actual, "", options.comparators, true, true, options.includingFields...)
+	} else if options.excludingFields != nil {
+		return structMatcher(expected, actual, "", options.comparators, true, false, options.excludingFields...)
+	}
+	return structMatcher(expected, actual, "", options.comparators, false, false)
+}
+
+/*
+ * mismatchDifferences describes mismatches field by field.  Exported fields
+ * of a struct with unexported fields have already been compared individually,
+ * so only the unexported fields are listed for the comparison of the struct
+ * as a whole.
+ */
+func mismatchDifferences(mismatches []mismatch) []string {
+	differences := make([]string, 0)
+	for _, mismatch := range mismatches {
+		records := fieldDifferences(mismatch.comparators, mismatch.path, mismatch.expected, mismatch.actual, mismatch.fields)
+		if len(records) == 0 {
+			differences = append(differences, mismatch.message)
+		}
+		differences = append(differences, differenceStrings(records)...)
+	}
+	return differences
+}
+
+/*
+ * mismatchRecords is mismatchDifferences, returning a Difference for each
+ * line.  A mismatch that cannot be described field by field, which should not
+ * happen, is returned with its message as both values.
+ */
+func mismatchRecords(mismatches []mismatch) []Difference {
+	records := make([]Difference, 0)
+	for _, mismatch := range mismatches {
+		fieldRecords := fieldDifferences(mismatch.comparators, mismatch.path, mismatch.expected, mismatch.actual, mismatch.fields)
+		if len(fieldRecords) == 0 {
+			fieldRecords = []Difference{{Path: mismatch.path, Expected: mismatch.message, Actual: mismatch.message}}
+		}
+		records = append(records, fieldRecords...)
+	}
+	return records
+}
+
+/*
+ * An OutputFormat selects how a Matcher's failure message lists differences:
+ * as the lines returned by Diff, as one JSON Difference record per line for
+ * tools that parse test output, or as both.
+ */
+type OutputFormat int
+
+const (
+	TEXT_OUTPUT OutputFormat = iota
+	JSON_OUTPUT
+	TEXT_AND_JSON_OUTPUT
+)
+
+type Matcher struct {
+	matchOptions
+	expected   interface{}
+	mismatches []mismatch
+	format     OutputFormat
 }
 
 var _ types.GomegaMatcher = &Matcher{}
@@ -134,24 +290,50 @@ func MatchStruct(expected interface{}) *Matcher {
 }
 
 func (m *Matcher) Match(actual interface{}) (success bool, err error) {
-	if m.includingFields != nil {
-		m.mismatches = StructMatcher(m.expected, actual, true, true, m.includingFields...)
-	} else if m.excludingFields != nil {
-		m.mismatches = StructMatcher(m.expected, actual, true, false, m.excludingFields...)
-	} else {
-		m.mismatches = StructMatcher(m.expected, actual, false, false)
-	}
+	m.mismatches = m.structMismatches(reflect.ValueOf(m.expected), reflect.ValueOf(actual))
 	return len(m.mismatches) == 0, nil
 }
 
+/*
+ * FailureMessage lists each differing field by its full path, as described
+ * for Diff, or as JSON records if another OutputFormat was chosen.
+ */
 func (m *Matcher) FailureMessage(actual interface{}) (message string) {
-	return "Expected structs to match but:\n" + strings.Join(m.mismatches, "\n")
+	lines := make([]string, 0)
+	if m.format != JSON_OUTPUT {
+		lines = append(lines, mismatchDifferences(m.mismatches)...)
+	}
+	if m.format != TEXT_OUTPUT {
+		if m.format == TEXT_AND_JSON_OUTPUT {
+			lines = append(lines, "As JSON:")
+		}
+		for _, record := range mismatchRecords(m.mismatches) {
+			// A Difference holds only strings, so it always marshals
+			line, _ := json.Marshal(record)
+			lines = append(lines, string(line))
+		}
+	}
+	return "Expected structs to match but:\n" + strings.Join(lines, "\n")
+}
+
+/*
+ * Differences returns the differences found by the last call to Match, for
+ * reporting them somewhere other than a test failure.
+ */
+func (m *Matcher) Differences() []Difference {
+	return mismatchRecords(m.mismatches)
 }
 
 func (m *Matcher) NegatedFailureMessage(actual interface{}) (message string) {
 	return "Expected structs not to match, but they did"
 }
 
+// WithOutputFormat sets how FailureMessage lists differences
+func (m *Matcher) WithOutputFormat(format OutputFormat) *Matcher {
+	m.format = format
+	return m
+}
+
 func (m *Matcher) IncludingFields(fields ...string) *Matcher {
 	m.includingFields = fields
 	return m
@@ -161,3 +343,23 @@ func (m *Matcher) ExcludingFields(fields ...string) *Matcher {
 	m.excludingFields = fields
 	return m
 }
+
+/*
+ * WithFieldComparator compares the field at path, in the form accepted by
+ * ExcludingFields, with comparator instead of requiring it to be equal.
+ */
+func (m *Matcher) WithFieldComparator(path string, comparator Comparator) *Matcher {
+	m.comparators = m.comparators.addField(path, comparator)
+	return m
+}
+
+/*
+ * WithTypeComparator compares every value with the same type as example with
+ * comparator instead of requiring it to be equal, e.g.
+ *
+ *   MatchStruct(expected).WithTypeComparator(time.Time{}, structmatcher.WithinDuration(time.Second))
+ */
+func (m *Matcher) WithTypeComparator(example interface{}, comparator Comparator) *Matcher {
+	m.comparators = m.comparators.addType(example, comparator)
+	return m
+}
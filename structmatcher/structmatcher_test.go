@@ -4,6 +4,7 @@ import (
 	"github.com/apache/cloudberry-go-libs/structmatcher"
 
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -113,7 +114,7 @@ var _ = Describe("structmatcher.StructMatcher", func() {
 			messages := InterceptGomegaFailures(func() {
 				Expect(struct2).To(structmatcher.MatchStruct(struct1))
 			})
-			Expect(messages).To(Equal([]string{"Expected structs to match but:\nMismatch on field Field2\nExpected\n    <string>: message2\nto equal\n    <string>: message1"}))
+			Expect(messages).To(Equal([]string{"Expected structs to match but:\nField .Field2: expected 'message1', got 'message2'"}))
 		})
 		It("returns mismatches in nested struct slices", func() {
 			struct1 := NestedStruct{Field1: 0, Field2: "message1", NestedSlice: []SimpleStruct{{Field1: 3}}}
@@ -121,7 +122,7 @@ var _ = Describe("structmatcher.StructMatcher", func() {
 			messages := InterceptGomegaFailures(func() {
 				Expect(struct2).To(structmatcher.MatchStruct(struct1))
 			})
-			Expect(messages).To(Equal([]string{"Expected structs to match but:\nMismatch on field NestedSlice[0].Field1\nExpected\n    <int>: 4\nto equal\n    <int>: 3"}))
+			Expect(messages).To(Equal([]string{"Expected structs to match but:\nField .NestedSlice[0].Field1: expected 3, got 4"}))
 		})
 		It("returns mismatches including struct fields", func() {
 			struct1 := NestedStruct{Field1: 0, Field2: "message1", NestedSlice: []SimpleStruct{{Field1: 3}}}
@@ -129,7 +130,7 @@ var _ = Describe("structmatcher.StructMatcher", func() {
 			messages := InterceptGomegaFailures(func() {
 				Expect(struct2).To(structmatcher.MatchStruct(struct1).IncludingFields("Field2"))
 			})
-			Expect(messages).To(Equal([]string{"Expected structs to match but:\nMismatch on field Field2\nExpected\n    <string>: teststruct2\nto equal\n    <string>: message1"}))
+			Expect(messages).To(Equal([]string{"Expected structs to match but:\nField .Field2: expected 'message1', got 'teststruct2'"}))
 		})
 		It("returns mismatches including nested struct slice fields", func() {
 			struct1 := NestedStruct{Field1: 0, Field2: "message1", NestedSlice: []SimpleStruct{{Field1: 3}}}
@@ -137,7 +138,7 @@ var _ = Describe("structmatcher.StructMatcher", func() {
 			messages := InterceptGomegaFailures(func() {
 				Expect(struct2).To(structmatcher.MatchStruct(struct1).IncludingFields("NestedSlice.Field1"))
 			})
-			Expect(messages).To(Equal([]string{"Expected structs to match but:\nMismatch on field NestedSlice[0].Field1\nExpected\n    <int>: 4\nto equal\n    <int>: 3"}))
+			Expect(messages).To(Equal([]string{"Expected structs to match but:\nField .NestedSlice[0].Field1: expected 3, got 4"}))
 		})
 		It("returns mismatches excluding struct fields", func() {
 			struct1 := NestedStruct{Field1: 0, Field2: "message1", NestedSlice: []SimpleStruct{{Field1: 3}}}
@@ -145,7 +146,7 @@ var _ = Describe("structmatcher.StructMatcher", func() {
 			messages := InterceptGomegaFailures(func() {
 				Expect(struct2).To(structmatcher.MatchStruct(struct1).ExcludingFields("Field2"))
 			})
-			Expect(messages).To(Equal([]string{"Expected structs to match but:\nMismatch on field NestedSlice[0].Field1\nExpected\n    <int>: 4\nto equal\n    <int>: 3"}))
+			Expect(messages).To(Equal([]string{"Expected structs to match but:\nField .NestedSlice[0].Field1: expected 3, got 4"}))
 		})
 		It("returns mismatches excluding nested struct slice fields", func() {
 			struct1 := NestedStruct{Field1: 0, Field2: "message1", NestedSlice: []SimpleStruct{{Field1: 3}}}
@@ -153,7 +154,7 @@ var _ = Describe("structmatcher.StructMatcher", func() {
 			messages := InterceptGomegaFailures(func() {
 				Expect(struct2).To(structmatcher.MatchStruct(struct1).ExcludingFields("NestedSlice.Field1"))
 			})
-			Expect(messages).To(Equal([]string{"Expected structs to match but:\nMismatch on field Field2\nExpected\n    <string>: teststruct2\nto equal\n    <string>: message1"}))
+			Expect(messages).To(Equal([]string{"Expected structs to match but:\nField .Field2: expected 'message1', got 'teststruct2'"}))
 		})
 		It("returns mismatches in nested structs", func() {
 			struct1 := NestedStruct{Struct: SimpleStruct{Field1: 7}}
@@ -161,7 +162,7 @@ var _ = Describe("structmatcher.StructMatcher", func() {
 			messages := InterceptGomegaFailures(func() {
 				Expect(struct2).To(structmatcher.MatchStruct(struct1))
 			})
-			Expect(messages).To(Equal([]string{"Expected structs to match but:\nMismatch on field Struct.Field1\nExpected\n    <int>: 8\nto equal\n    <int>: 7"}))
+			Expect(messages).To(Equal([]string{"Expected structs to match but:\nField .Struct.Field1: expected 7, got 8"}))
 		})
 		It("returns mismatches in nested pointers to structs", func() {
 			struct1 := NestedStruct{PtrStruct: &SimpleStruct{Field1: 7}}
@@ -169,7 +170,7 @@ var _ = Describe("structmatcher.StructMatcher", func() {
 			messages := InterceptGomegaFailures(func() {
 				Expect(struct2).To(structmatcher.MatchStruct(struct1))
 			})
-			Expect(messages).To(Equal([]string{"Expected structs to match but:\nMismatch on field PtrStruct.Field1\nExpected\n    <int>: 8\nto equal\n    <int>: 7"}))
+			Expect(messages).To(Equal([]string{"Expected structs to match but:\nField .PtrStruct.Field1: expected 7, got 8"}))
 		})
 		It("can compare nil pointers", func() {
 			struct1 := NestedStruct{PtrStruct: nil}
@@ -178,7 +179,7 @@ var _ = Describe("structmatcher.StructMatcher", func() {
 				Expect(struct2).To(structmatcher.MatchStruct(struct1))
 			})
 			Expect(messages).To(HaveLen(1))
-			Expect(messages[0]).To(MatchRegexp(`Expected structs to match but:\nMismatch on field PtrStruct\nExpected\n    <\*structmatcher_test\.SimpleStruct \| 0x[0-9a-f]+>: \{Field1: 8, Field2: ""}\nto equal\n    <\*structmatcher_test\.SimpleStruct \| 0x0>: nil`))
+			Expect(messages[0]).To(Equal("Expected structs to match but:\nField .PtrStruct: expected nil, got &{Field1:8 Field2:}"))
 		})
 		It("can compare nil pointers", func() {
 			struct1 := NestedStruct{PtrStruct: &SimpleStruct{Field1: 7}}
@@ -187,7 +188,7 @@ var _ = Describe("structmatcher.StructMatcher", func() {
 				Expect(struct2).To(structmatcher.MatchStruct(struct1))
 			})
 			Expect(messages).To(HaveLen(1))
-			Expect(messages[0]).To(MatchRegexp(`Expected structs to match but:\nMismatch on field PtrStruct\nExpected\n    <\*structmatcher_test\.SimpleStruct \| 0x0>: nil\nto equal\n    <\*structmatcher_test\.SimpleStruct \| 0x[0-9a-f]+>: \{Field1: 7, Field2: ""}`))
+			Expect(messages[0]).To(Equal("Expected structs to match but:\nField .PtrStruct: expected &{Field1:7 Field2:}, got nil"))
 		})
 
 		It("gives a negated failure message", func() {
@@ -251,17 +252,7 @@ var _ = Describe("structmatcher.StructMatcher", func() {
 			})
 			Expect(messages).To(HaveLen(1))
 			Expect(messages[0]).To(Equal("Expected structs to match but:\n" +
-				"Mismatch on unexported field within OpaqueField\n" +
-				"Expected\n" +
-				"    <structmatcher_test.OpaqueStruct>: {\n" +
-				"        privateField: \"you can't see me either!\",\n" +
-				"        privateStructField: {Field1: 0, Field2: \"\"},\n" +
-				"    }\n" +
-				"to equal\n" +
-				"    <structmatcher_test.OpaqueStruct>: {\n" +
-				"        privateField: \"you can't see me!\",\n" +
-				"        privateStructField: {Field1: 0, Field2: \"\"},\n" +
-				"    }"))
+				"Field .OpaqueField.privateField: expected 'you can't see me!', got 'you can't see me either!'"))
 		})
 		It("still works when the top structs are opaque", func() {
 			struct1 := OpaqueStruct{privateField: "foo"}
@@ -271,17 +262,7 @@ var _ = Describe("structmatcher.StructMatcher", func() {
 			})
 			Expect(messages).To(HaveLen(1))
 			Expect(messages[0]).To(Equal("Expected structs to match but:\n" +
-				"Mismatch on unexported field within top level struct\n" +
-				"Expected\n" +
-				"    <structmatcher_test.OpaqueStruct>: {\n" +
-				"        privateField: \"bar\",\n" +
-				"        privateStructField: {Field1: 0, Field2: \"\"},\n" +
-				"    }\n" +
-				"to equal\n" +
-				"    <structmatcher_test.OpaqueStruct>: {\n" +
-				"        privateField: \"foo\",\n" +
-				"        privateStructField: {Field1: 0, Field2: \"\"},\n" +
-				"    }"))
+				"Field .privateField: expected 'foo', got 'bar'"))
 		})
 		It("works when public fields are also unequal", func() {
 			struct1 := SemiOpaqueStruct{
@@ -299,31 +280,98 @@ var _ = Describe("structmatcher.StructMatcher", func() {
 			})
 			Expect(messages).To(HaveLen(1))
 			Expect(messages[0]).To(Equal("Expected structs to match but:\n" +
-				"Mismatch on field PublicField.Field1\n" +
-				"Expected\n" +
-				"    <int>: 10\n" +
-				"to equal\n" +
-				"    <int>: 1\n" +
-				"Mismatch on field PublicField2.Field1\n" +
-				"Expected\n" +
-				"    <int>: 20\n" +
-				"to equal\n" +
-				"    <int>: 2\n" +
-				"Mismatch on unexported field within top level struct\n" +
-				"Expected\n" +
-				"    <structmatcher_test.SemiOpaqueStruct>: {\n" +
-				"        PublicField: {Field1: 10, Field2: \"2\"},\n" +
-				"        privateField: \"bar\",\n" +
-				"        privateStructField: {Field1: 0, Field2: \"\"},\n" +
-				"        PublicField2: {Field1: 20, Field2: \"2\"},\n" +
-				"    }\n" +
-				"to equal\n" +
-				"    <structmatcher_test.SemiOpaqueStruct>: {\n" +
-				"        PublicField: {Field1: 1, Field2: \"2\"},\n" +
-				"        privateField: \"foo\",\n" +
-				"        privateStructField: {Field1: 0, Field2: \"\"},\n" +
-				"        PublicField2: {Field1: 2, Field2: \"2\"},\n" +
-				"    }"))
+				"Field .PublicField.Field1: expected 1, got 10\n" +
+				"Field .PublicField2.Field1: expected 2, got 20\n" +
+				"Field .privateField: expected 'foo', got 'bar'"))
+		})
+		It("does not compare excluded fields of a struct with unexported fields", func() {
+			struct1 := SemiOpaqueStruct{PublicField: SimpleStruct{Field1: 1}, privateField: "foo"}
+			struct2 := SemiOpaqueStruct{PublicField: SimpleStruct{Field1: 10}, privateField: "foo"}
+			Expect(struct2).To(structmatcher.MatchStruct(struct1).ExcludingFields("PublicField"))
+
+			struct2.privateField = "bar"
+			messages := InterceptGomegaFailures(func() {
+				Expect(struct2).To(structmatcher.MatchStruct(struct1).ExcludingFields("PublicField"))
+			})
+			Expect(messages).To(Equal([]string{"Expected structs to match but:\nField .privateField: expected 'foo', got 'bar'"}))
+		})
+	})
+
+	Describe("Nested paths and ignored fields", func() {
+		type Segment struct {
+			ContentID int
+			Hostname  string
+			Port      int
+		}
+		type Spec struct {
+			Name        string
+			Segments    []Segment
+			Coordinator Segment
+		}
+		type Status struct {
+			State     string
+			UpdatedAt time.Time `structmatcher:"ignore"`
+		}
+		type Cluster struct {
+			Spec      Spec
+			Status    Status
+			CreatedAt time.Time `structmatcher:"ignore"`
+			Extra     interface{}
+		}
+		var cluster1, cluster2 Cluster
+		BeforeEach(func() {
+			cluster1 = Cluster{
+				Spec: Spec{
+					Name:        "demo",
+					Segments:    []Segment{{0, "sdw1", 6000}, {1, "sdw2", 6000}},
+					Coordinator: Segment{-1, "cdw", 5432},
+				},
+				Status:    Status{State: "up", UpdatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+				CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			}
+			cluster2 = cluster1
+			cluster2.Spec.Segments = []Segment{{0, "sdw1", 7000}, {1, "sdw3", 7001}}
+			cluster2.Status.UpdatedAt = time.Now()
+			cluster2.CreatedAt = time.Now()
+		})
+		It("never compares fields tagged to be ignored", func() {
+			cluster2.Spec = cluster1.Spec
+			Expect(cluster2).To(structmatcher.MatchStruct(cluster1))
+			Expect(structmatcher.StructMatcher(&cluster1, &cluster2, false, false)).To(BeEmpty())
+		})
+		It("excludes a field within each element of a nested slice of structs", func() {
+			messages := InterceptGomegaFailures(func() {
+				Expect(cluster2).To(structmatcher.MatchStruct(cluster1).ExcludingFields("Spec.Segments[*].Port"))
+			})
+			Expect(messages).To(Equal([]string{"Expected structs to match but:\nField .Spec.Segments[1].Hostname: expected 'sdw2', got 'sdw3'"}))
+
+			Expect(cluster2).To(structmatcher.MatchStruct(cluster1).ExcludingFields("Spec.Segments.Port", "Spec.Segments[*].Hostname"))
+		})
+		It("excludes nested paths only within the field they are under", func() {
+			cluster2.Spec.Coordinator.Port = 7000
+			messages := InterceptGomegaFailures(func() {
+				Expect(cluster2).To(structmatcher.MatchStruct(cluster1).ExcludingFields("Spec.Segments"))
+			})
+			Expect(messages).To(Equal([]string{"Expected structs to match but:\nField .Spec.Coordinator.Port: expected 5432, got 7000"}))
+
+			Expect(cluster2).ToNot(structmatcher.MatchStruct(cluster1).ExcludingFields("Spec.Segments", "Spec.Segments[*].Port"))
+			Expect(cluster2).To(structmatcher.MatchStruct(cluster1).ExcludingFields("Spec.Segments", "Spec.Coordinator.Port"))
+		})
+		It("includes only the given nested paths", func() {
+			cluster2.Status.State = "down"
+			messages := InterceptGomegaFailures(func() {
+				Expect(cluster2).To(structmatcher.MatchStruct(cluster1).IncludingFields("Spec.Segments[*].Hostname"))
+			})
+			Expect(messages).To(Equal([]string{"Expected structs to match but:\nField .Spec.Segments[1].Hostname: expected 'sdw2', got 'sdw3'"}))
+
+			Expect(cluster2).To(structmatcher.MatchStruct(cluster1).IncludingFields("Spec.Name", "Spec.Segments[*].ContentID", "Spec.Coordinator"))
+		})
+		It("compares every field of an included nested struct", func() {
+			cluster2.Status.State = "down"
+			messages := InterceptGomegaFailures(func() {
+				Expect(cluster2).To(structmatcher.MatchStruct(cluster1).IncludingFields("Status"))
+			})
+			Expect(messages).To(Equal([]string{"Expected structs to match but:\nField .Status.State: expected 'up', got 'down'"}))
 		})
 	})
 })
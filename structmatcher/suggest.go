@@ -0,0 +1,132 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package structmatcher
+
+/*
+ * This file contains functions for suggesting which fields to exclude when
+ * matching structs, based on the mismatches observed across a dataset.
+ */
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	mismatchFieldRegex      = regexp.MustCompile(`^Mismatch on field (\S+)`)
+	mismatchUnexportedRegex = regexp.MustCompile(`^Mismatch on unexported field within (\S+)(\n|$)`)
+	sliceIndexRegex         = regexp.MustCompile(`\[\d+\]`)
+)
+
+/*
+ * MismatchedFields returns the field paths named in the mismatches returned by
+ * StructMatcher, in a form that can be passed to ExcludingFields: slice
+ * indexes are removed, and paths nested more than one level deep are cut off
+ * after the first nested field, since filters only apply one level deep.
+ * Mismatches on unexported fields of the top-level struct cannot be excluded
+ * and are skipped.  Each path appears only once in the result.
+ */
+func MismatchedFields(mismatches []string) []string {
+	fields := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, mismatch := range mismatches {
+		match := mismatchFieldRegex.FindStringSubmatch(mismatch)
+		if match == nil {
+			match = mismatchUnexportedRegex.FindStringSubmatch(mismatch)
+		}
+		if match == nil {
+			continue
+		}
+		fieldNames := strings.Split(sliceIndexRegex.ReplaceAllString(match[1], ""), ".")
+		if len(fieldNames) > 2 {
+			fieldNames = fieldNames[:2]
+		}
+		field := strings.Join(fieldNames, ".")
+		if !seen[field] {
+			seen[field] = true
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+/*
+ * An ExclusionSuggester aggregates the fields that differ across many struct
+ * comparisons.  Fields that differ in most comparisons are usually ones that
+ * are expected to differ (e.g. OIDs or timestamps) and are good candidates for
+ * an exclusion list when onboarding a new struct type.
+ */
+type ExclusionSuggester struct {
+	NumComparisons int
+	FieldCounts    map[string]int
+}
+
+func NewExclusionSuggester() *ExclusionSuggester {
+	return &ExclusionSuggester{FieldCounts: make(map[string]int)}
+}
+
+// Observe compares expected and actual, records any differing fields, and returns the mismatches
+func (suggester *ExclusionSuggester) Observe(expected interface{}, actual interface{}) []string {
+	mismatches := StructMatcher(expected, actual, false, false)
+	suggester.AddMismatches(mismatches)
+	return mismatches
+}
+
+// AddMismatches records the result of one comparison made elsewhere
+func (suggester *ExclusionSuggester) AddMismatches(mismatches []string) {
+	suggester.NumComparisons++
+	for _, field := range MismatchedFields(mismatches) {
+		suggester.FieldCounts[field]++
+	}
+}
+
+/*
+ * Suggest returns the fields that differed in at least minFraction (between 0
+ * and 1) of the comparisons observed, most frequent first and then sorted by
+ * name.
+ */
+func (suggester *ExclusionSuggester) Suggest(minFraction float64) []string {
+	fields := make([]string, 0)
+	if suggester.NumComparisons == 0 {
+		return fields
+	}
+	for field, count := range suggester.FieldCounts {
+		if float64(count)/float64(suggester.NumComparisons) >= minFraction {
+			fields = append(fields, field)
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		countI, countJ := suggester.FieldCounts[fields[i]], suggester.FieldCounts[fields[j]]
+		if countI != countJ {
+			return countI > countJ
+		}
+		return fields[i] < fields[j]
+	})
+	return fields
+}
+
+// Report returns the suggested fields with their counts, one per line, for printing
+func (suggester *ExclusionSuggester) Report(minFraction float64) string {
+	lines := make([]string, 0)
+	for _, field := range suggester.Suggest(minFraction) {
+		lines = append(lines, fmt.Sprintf("%s (differed in %d of %d comparisons)", field, suggester.FieldCounts[field], suggester.NumComparisons))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,83 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package structmatcher_test
+
+import (
+	"github.com/apache/cloudberry-go-libs/structmatcher"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("structmatcher/suggest tests", func() {
+	type SimpleStruct struct {
+		Field1 int
+		Field2 string
+	}
+	type NestedStruct struct {
+		Oid         uint32
+		Name        string
+		NestedSlice []SimpleStruct
+		Struct      SimpleStruct
+	}
+
+	Describe("MismatchedFields", func() {
+		It("returns the field paths from mismatches without slice indexes or duplicates", func() {
+			struct1 := NestedStruct{Oid: 1, Name: "a", NestedSlice: []SimpleStruct{{Field1: 1}, {Field1: 2}}, Struct: SimpleStruct{Field2: "x"}}
+			struct2 := NestedStruct{Oid: 2, Name: "a", NestedSlice: []SimpleStruct{{Field1: 3}, {Field1: 4}}, Struct: SimpleStruct{Field2: "y"}}
+			mismatches := structmatcher.StructMatcher(&struct1, &struct2, false, false)
+			Expect(structmatcher.MismatchedFields(mismatches)).To(ConsistOf("Oid", "NestedSlice.Field1", "Struct.Field2"))
+		})
+		It("truncates deeply nested paths and skips unrecognized messages", func() {
+			mismatches := []string{
+				"Mismatch on field A.B.C\nExpected...",
+				"Mismatch on unexported field within D\nExpected...",
+				"Mismatch on unexported field within top level struct\nExpected...",
+				"something else",
+			}
+			Expect(structmatcher.MismatchedFields(mismatches)).To(Equal([]string{"A.B", "D"}))
+		})
+	})
+	Describe("ExclusionSuggester", func() {
+		var suggester *structmatcher.ExclusionSuggester
+		BeforeEach(func() {
+			suggester = structmatcher.NewExclusionSuggester()
+			suggester.Observe(&NestedStruct{Oid: 1, Name: "a"}, &NestedStruct{Oid: 2, Name: "a"})
+			suggester.Observe(&NestedStruct{Oid: 3, Name: "b"}, &NestedStruct{Oid: 4, Name: "c"})
+			suggester.Observe(&NestedStruct{Oid: 5, Struct: SimpleStruct{Field1: 1}}, &NestedStruct{Oid: 6, Struct: SimpleStruct{Field1: 2}})
+			suggester.Observe(&NestedStruct{Oid: 7}, &NestedStruct{Oid: 7})
+		})
+		It("counts the comparisons and differing fields", func() {
+			Expect(suggester.NumComparisons).To(Equal(4))
+			Expect(suggester.FieldCounts).To(Equal(map[string]int{"Oid": 3, "Name": 1, "Struct.Field1": 1}))
+		})
+		It("suggests fields that differ in at least the given fraction of comparisons", func() {
+			Expect(suggester.Suggest(0.5)).To(Equal([]string{"Oid"}))
+			Expect(suggester.Suggest(0)).To(Equal([]string{"Oid", "Name", "Struct.Field1"}))
+		})
+		It("suggests fields that make the observed structs match when excluded", func() {
+			Expect(&NestedStruct{Oid: 3, Name: "b"}).To(structmatcher.MatchStruct(&NestedStruct{Oid: 4, Name: "b"}).ExcludingFields(suggester.Suggest(0.5)...))
+		})
+		It("returns a printable report", func() {
+			Expect(suggester.Report(0.25)).To(Equal("Oid (differed in 3 of 4 comparisons)\nName (differed in 1 of 4 comparisons)\nStruct.Field1 (differed in 1 of 4 comparisons)"))
+		})
+		It("suggests nothing if no comparisons were observed", func() {
+			Expect(structmatcher.NewExclusionSuggester().Suggest(0)).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,350 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package testhelper
+
+/*
+ * This file contains a fault injector for testing how code behaves when file
+ * operations made through operating.System fail or are slow, without writing
+ * a one-off fake for each test.
+ */
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/operating"
+)
+
+type FaultOp string
+
+const (
+	FAULT_OPEN_READ  FaultOp = "open"
+	FAULT_OPEN_WRITE FaultOp = "create"
+	FAULT_READ       FaultOp = "read"
+	FAULT_WRITE      FaultOp = "write"
+	FAULT_CLOSE      FaultOp = "close"
+	FAULT_STAT       FaultOp = "stat"
+	FAULT_READ_FILE  FaultOp = "readfile"
+	FAULT_MKDIR      FaultOp = "mkdir"
+	FAULT_REMOVE     FaultOp = "remove"
+	FAULT_RENAME     FaultOp = "rename"
+)
+
+/*
+ * A Fault describes when an operation should fail or be delayed.  Path is a
+ * filepath.Match pattern that is matched against both the full path and the
+ * base name of the file, and an empty Path matches every file.  Matching calls are counted separately for each Fault.
+ *
+ * If OnCall is positive, only that matching call (counting from 1) is
+ * affected; otherwise every matching call is.  For FAULT_READ and FAULT_WRITE,
+ * a positive AfterBytes instead lets that many bytes through on each file
+ * handle and fails every call after that, after transferring as much as
+ * possible, to simulate e.g. a disk filling up partway through a file.
+ *
+ * An affected call first sleeps for Delay using operating.System.Sleep, so
+ * that it works with a FakeClock, and then returns Err.  If Err is nil and
+ * Delay is set, the call continues normally after the delay; if neither is
+ * set, Err defaults to EIO.
+ */
+type Fault struct {
+	Op         FaultOp
+	Path       string
+	OnCall     int
+	AfterBytes int64
+	Delay      time.Duration
+	Err        error
+}
+
+/*
+ * A FaultInjector wraps the file functions in operating.System to apply a
+ * schedule of Faults.  Install must be called after any other functions in
+ * operating.System have been replaced, since it wraps the current ones.
+ */
+type FaultInjector struct {
+	mutex  sync.Mutex
+	faults []*Fault
+	calls  map[*Fault]int
+	counts map[FaultOp]int
+	fired  map[FaultOp]int
+}
+
+func NewFaultInjector(faults ...Fault) *FaultInjector {
+	injector := &FaultInjector{calls: make(map[*Fault]int), counts: make(map[FaultOp]int), fired: make(map[FaultOp]int)}
+	for _, fault := range faults {
+		injector.Add(fault)
+	}
+	return injector
+}
+
+func (injector *FaultInjector) Add(fault Fault) *FaultInjector {
+	injector.mutex.Lock()
+	defer injector.mutex.Unlock()
+	injector.faults = append(injector.faults, &fault)
+	return injector
+}
+
+// Calls returns the number of calls made for op, whether or not a fault was injected
+func (injector *FaultInjector) Calls(op FaultOp) int {
+	injector.mutex.Lock()
+	defer injector.mutex.Unlock()
+	return injector.counts[op]
+}
+
+// Injected returns the number of calls for op that were failed or delayed
+func (injector *FaultInjector) Injected(op FaultOp) int {
+	injector.mutex.Lock()
+	defer injector.mutex.Unlock()
+	return injector.fired[op]
+}
+
+func (injector *FaultInjector) Install() {
+	openFileRead := operating.System.OpenFileRead
+	operating.System.OpenFileRead = func(name string, flag int, perm os.FileMode) (operating.ReadCloserAt, error) {
+		if err := injector.inject(FAULT_OPEN_READ, name); err != nil {
+			return nil, err
+		}
+		file, err := openFileRead(name, flag, perm)
+		if err != nil {
+			return nil, err
+		}
+		return &faultyReader{ReadCloserAt: file, injector: injector, name: name}, nil
+	}
+	openFileWrite := operating.System.OpenFileWrite
+	operating.System.OpenFileWrite = func(name string, flag int, perm os.FileMode) (io.WriteCloser, error) {
+		if err := injector.inject(FAULT_OPEN_WRITE, name); err != nil {
+			return nil, err
+		}
+		file, err := openFileWrite(name, flag, perm)
+		if err != nil {
+			return nil, err
+		}
+		return &faultyWriter{WriteCloser: file, injector: injector, name: name}, nil
+	}
+	stat := operating.System.Stat
+	operating.System.Stat = func(name string) (os.FileInfo, error) {
+		if err := injector.inject(FAULT_STAT, name); err != nil {
+			return nil, err
+		}
+		return stat(name)
+	}
+	readFile := operating.System.ReadFile
+	operating.System.ReadFile = func(name string) ([]byte, error) {
+		if err := injector.inject(FAULT_READ_FILE, name); err != nil {
+			return nil, err
+		}
+		return readFile(name)
+	}
+	mkdirAll := operating.System.MkdirAll
+	operating.System.MkdirAll = func(path string, perm os.FileMode) error {
+		if err := injector.inject(FAULT_MKDIR, path); err != nil {
+			return err
+		}
+		return mkdirAll(path, perm)
+	}
+	remove := operating.System.Remove
+	operating.System.Remove = func(name string) error {
+		if err := injector.inject(FAULT_REMOVE, name); err != nil {
+			return err
+		}
+		return remove(name)
+	}
+	rename := operating.System.Rename
+	operating.System.Rename = func(oldpath, newpath string) error {
+		if err := injector.inject(FAULT_RENAME, oldpath); err != nil {
+			return err
+		}
+		return rename(oldpath, newpath)
+	}
+}
+
+func (fault *Fault) matches(op FaultOp, name string) bool {
+	if fault.Op != op {
+		return false
+	}
+	if fault.Path == "" {
+		return true
+	}
+	matched, _ := filepath.Match(fault.Path, name)
+	if !matched {
+		matched, _ = filepath.Match(fault.Path, filepath.Base(name))
+	}
+	return matched
+}
+
+// schedule counts a call and returns the first fault that applies to it, if any
+func (injector *FaultInjector) schedule(op FaultOp, name string, transferred int64) *Fault {
+	injector.mutex.Lock()
+	defer injector.mutex.Unlock()
+	injector.counts[op]++
+	var applied *Fault
+	for _, fault := range injector.faults {
+		if !fault.matches(op, name) {
+			continue
+		}
+		injector.calls[fault]++
+		if applied != nil {
+			continue
+		}
+		if fault.AfterBytes > 0 {
+			if transferred > fault.AfterBytes {
+				applied = fault
+			}
+		} else if fault.OnCall <= 0 || injector.calls[fault] == fault.OnCall {
+			applied = fault
+		}
+	}
+	if applied != nil {
+		injector.fired[op]++
+	}
+	return applied
+}
+
+func (fault *Fault) apply(op FaultOp, name string) error {
+	if fault.Delay > 0 {
+		operating.System.Sleep(fault.Delay)
+	}
+	err := fault.Err
+	if err == nil {
+		if fault.Delay > 0 {
+			return nil
+		}
+		err = syscall.EIO
+	}
+	return &os.PathError{Op: string(op), Path: name, Err: err}
+}
+
+func (injector *FaultInjector) inject(op FaultOp, name string) error {
+	if fault := injector.schedule(op, name, 0); fault != nil {
+		return fault.apply(op, name)
+	}
+	return nil
+}
+
+/*
+ * limitTransfer returns how many of the n bytes requested may be transferred
+ * before an AfterBytes fault for op applies, and the fault to apply after
+ * them.  A fault without AfterBytes applies before any bytes are transferred.
+ */
+func (injector *FaultInjector) limitTransfer(op FaultOp, name string, transferred int64, n int) (int, *Fault) {
+	fault := injector.schedule(op, name, transferred+int64(n))
+	if fault == nil {
+		return n, nil
+	}
+	if fault.AfterBytes > 0 && transferred < fault.AfterBytes {
+		return int(fault.AfterBytes - transferred), fault
+	}
+	return 0, fault
+}
+
+type faultyReader struct {
+	operating.ReadCloserAt
+	injector *FaultInjector
+	name     string
+	read     int64
+}
+
+func (reader *faultyReader) Read(p []byte) (int, error) {
+	allowed, fault := reader.injector.limitTransfer(FAULT_READ, reader.name, reader.read, len(p))
+	if fault == nil {
+		n, err := reader.ReadCloserAt.Read(p)
+		reader.read += int64(n)
+		return n, err
+	}
+	n := 0
+	if allowed > 0 {
+		var err error
+		n, err = reader.ReadCloserAt.Read(p[:allowed])
+		reader.read += int64(n)
+		if err != nil || n < allowed {
+			return n, err
+		}
+	}
+	if err := fault.apply(FAULT_READ, reader.name); err != nil {
+		return n, err
+	}
+	m, err := reader.ReadCloserAt.Read(p[n:])
+	reader.read += int64(m)
+	return n + m, err
+}
+
+func (reader *faultyReader) ReadAt(p []byte, off int64) (int, error) {
+	allowed, fault := reader.injector.limitTransfer(FAULT_READ, reader.name, off, len(p))
+	if fault == nil {
+		return reader.ReadCloserAt.ReadAt(p, off)
+	}
+	n, err := reader.ReadCloserAt.ReadAt(p[:allowed], off)
+	if err != nil || n < allowed {
+		return n, err
+	}
+	if err := fault.apply(FAULT_READ, reader.name); err != nil {
+		return n, err
+	}
+	m, err := reader.ReadCloserAt.ReadAt(p[n:], off+int64(n))
+	return n + m, err
+}
+
+func (reader *faultyReader) Close() error {
+	if err := reader.injector.inject(FAULT_CLOSE, reader.name); err != nil {
+		_ = reader.ReadCloserAt.Close()
+		return err
+	}
+	return reader.ReadCloserAt.Close()
+}
+
+type faultyWriter struct {
+	io.WriteCloser
+	injector *FaultInjector
+	name     string
+	written  int64
+}
+
+func (writer *faultyWriter) Write(p []byte) (int, error) {
+	allowed, fault := writer.injector.limitTransfer(FAULT_WRITE, writer.name, writer.written, len(p))
+	if fault == nil {
+		n, err := writer.WriteCloser.Write(p)
+		writer.written += int64(n)
+		return n, err
+	}
+	n := 0
+	if allowed > 0 {
+		var err error
+		n, err = writer.WriteCloser.Write(p[:allowed])
+		writer.written += int64(n)
+		if err != nil {
+			return n, err
+		}
+	}
+	if err := fault.apply(FAULT_WRITE, writer.name); err != nil {
+		return n, err
+	}
+	m, err := writer.WriteCloser.Write(p[n:])
+	writer.written += int64(m)
+	return n + m, err
+}
+
+func (writer *faultyWriter) Close() error {
+	if err := writer.injector.inject(FAULT_CLOSE, writer.name); err != nil {
+		_ = writer.WriteCloser.Close()
+		return err
+	}
+	return writer.WriteCloser.Close()
+}
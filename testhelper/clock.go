@@ -0,0 +1,206 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package testhelper
+
+/*
+ * This file contains a fake clock for testing code that waits or measures
+ * durations through operating.System without actually sleeping.
+ */
+
+import (
+	"sync"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/operating"
+)
+
+/*
+ * A FakeClock only moves when Advance is called.  Install points the clock
+ * functions in operating.System at it; timers, tickers, and calls to Sleep
+ * fire once the clock has been advanced past their deadline.
+ *
+ * Code under test usually waits in another goroutine, so tests should call
+ * BlockUntil before Advance to be sure the code has started waiting.
+ */
+type FakeClock struct {
+	mutex   sync.Mutex
+	wall    time.Time
+	elapsed time.Duration
+	waiters []*fakeWaiter
+	changed chan struct{}
+}
+
+type fakeWaiter struct {
+	deadline time.Duration
+	period   time.Duration
+	channel  chan time.Time
+}
+
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{wall: start, changed: make(chan struct{})}
+}
+
+func (clock *FakeClock) Install() {
+	operating.System.Now = clock.Now
+	operating.System.MonotonicNow = clock.MonotonicNow
+	operating.System.NewTimer = clock.NewTimer
+	operating.System.NewTicker = clock.NewTicker
+	operating.System.Sleep = clock.Sleep
+}
+
+func (clock *FakeClock) Now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	return clock.wall.Add(clock.elapsed)
+}
+
+func (clock *FakeClock) MonotonicNow() time.Duration {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	return clock.elapsed
+}
+
+// notifyChanged must be called with the mutex held
+func (clock *FakeClock) notifyChanged() {
+	close(clock.changed)
+	clock.changed = make(chan struct{})
+}
+
+func (clock *FakeClock) addWaiter(d time.Duration, period time.Duration, channel chan time.Time) *fakeWaiter {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	waiter := &fakeWaiter{deadline: clock.elapsed + d, period: period, channel: channel}
+	clock.waiters = append(clock.waiters, waiter)
+	clock.notifyChanged()
+	return waiter
+}
+
+func (clock *FakeClock) removeWaiter(waiter *fakeWaiter) bool {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	for i, pending := range clock.waiters {
+		if pending == waiter {
+			clock.waiters = append(clock.waiters[:i], clock.waiters[i+1:]...)
+			clock.notifyChanged()
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * Advance moves the clock forward by d and fires every timer, ticker, and
+ * Sleep whose deadline has been reached.  A ticker fires at most once per
+ * Advance, as a real ticker drops ticks that are not received.
+ */
+func (clock *FakeClock) Advance(d time.Duration) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	clock.elapsed += d
+	now := clock.wall.Add(clock.elapsed)
+	remaining := make([]*fakeWaiter, 0, len(clock.waiters))
+	for _, waiter := range clock.waiters {
+		if waiter.deadline > clock.elapsed {
+			remaining = append(remaining, waiter)
+			continue
+		}
+		select {
+		case waiter.channel <- now:
+		default:
+		}
+		if waiter.period > 0 {
+			for waiter.deadline <= clock.elapsed {
+				waiter.deadline += waiter.period
+			}
+			remaining = append(remaining, waiter)
+		}
+	}
+	clock.waiters = remaining
+	clock.notifyChanged()
+}
+
+/*
+ * BlockUntil waits until at least numWaiters timers, tickers, and calls to
+ * Sleep are waiting on the clock.
+ */
+func (clock *FakeClock) BlockUntil(numWaiters int) {
+	for {
+		clock.mutex.Lock()
+		count, changed := len(clock.waiters), clock.changed
+		clock.mutex.Unlock()
+		if count >= numWaiters {
+			return
+		}
+		<-changed
+	}
+}
+
+func (clock *FakeClock) Sleep(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	<-clock.addWaiter(d, 0, make(chan time.Time, 1)).channel
+}
+
+type fakeTimer struct {
+	clock  *FakeClock
+	waiter *fakeWaiter
+}
+
+func (clock *FakeClock) NewTimer(d time.Duration) operating.Timer {
+	return &fakeTimer{clock: clock, waiter: clock.addWaiter(d, 0, make(chan time.Time, 1))}
+}
+
+func (timer *fakeTimer) C() <-chan time.Time {
+	return timer.waiter.channel
+}
+
+func (timer *fakeTimer) Stop() bool {
+	return timer.clock.removeWaiter(timer.waiter)
+}
+
+func (timer *fakeTimer) Reset(d time.Duration) bool {
+	wasActive := timer.clock.removeWaiter(timer.waiter)
+	timer.waiter = timer.clock.addWaiter(d, 0, timer.waiter.channel)
+	return wasActive
+}
+
+type fakeTicker struct {
+	clock  *FakeClock
+	waiter *fakeWaiter
+}
+
+func (clock *FakeClock) NewTicker(d time.Duration) operating.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	return &fakeTicker{clock: clock, waiter: clock.addWaiter(d, d, make(chan time.Time, 1))}
+}
+
+func (ticker *fakeTicker) C() <-chan time.Time {
+	return ticker.waiter.channel
+}
+
+func (ticker *fakeTicker) Stop() {
+	ticker.clock.removeWaiter(ticker.waiter)
+}
+
+func (ticker *fakeTicker) Reset(d time.Duration) {
+	ticker.clock.removeWaiter(ticker.waiter)
+	ticker.waiter = ticker.clock.addWaiter(d, d, ticker.waiter.channel)
+}
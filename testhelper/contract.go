@@ -0,0 +1,378 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package testhelper
+
+/*
+ * This file contains reusable conformance tests that any implementation of an
+ * interface from this library, or any replacement for the filesystem
+ * functions in operating.System, can run to check that it behaves as the rest
+ * of the library expects.
+ */
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/operating"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+/*
+ * ExecutorContractTests registers specs checking that the executors returned
+ * by newExecutor implement cluster.Executor with the same semantics as
+ * cluster.GPDBExecutor.  It should be called at the top level of a test file
+ * in a Ginkgo suite, e.g.
+ *
+ *   var _ = testhelper.ExecutorContractTests("SSHExecutor", "sdw1", func() cluster.Executor {
+ *       return NewSSHExecutor()
+ *   })
+ *
+ * The specs run short bash commands built with cluster.NewShellCommand for
+ * the given host, so bash must be available wherever the executor runs them.
+ */
+func ExecutorContractTests(name string, host string, newExecutor func() cluster.Executor) bool {
+	return Describe(fmt.Sprintf("%s cluster.Executor contract", name), func() {
+		var executor cluster.Executor
+		hostCommand := func(script string) cluster.ShellCommand {
+			return cluster.NewShellCommand(cluster.ON_HOSTS, -2, host, []string{"bash", "-c", script})
+		}
+
+		BeforeEach(func() {
+			executor = newExecutor()
+		})
+
+		Describe("ExecuteLocalCommand", func() {
+			It("returns the combined output of a successful command", func() {
+				output, err := executor.ExecuteLocalCommand("echo out; echo err >&2")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(output).To(ContainSubstring("out\n"))
+				Expect(output).To(ContainSubstring("err\n"))
+			})
+			It("returns an error that decodes to the exit code of a failed command", func() {
+				_, err := executor.ExecuteLocalCommand("exit 3")
+				Expect(err).To(HaveOccurred())
+				Expect(operating.DecodeExitStatus(err).Code).To(Equal(3))
+			})
+			It("returns an error if the context is canceled", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				_, err := executor.ExecuteLocalCommandWithContext("sleep 10", ctx)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
+		Describe("ExecuteClusterCommand", func() {
+			It("runs every command and records its output and errors in order", func() {
+				commandList := []cluster.ShellCommand{
+					hostCommand("echo first"),
+					hostCommand("echo second; echo failed >&2; exit 2"),
+					hostCommand("echo third"),
+				}
+				output := executor.ExecuteClusterCommand(cluster.ON_HOSTS, commandList)
+
+				Expect(output.Scope).To(Equal(cluster.ON_HOSTS))
+				Expect(output.Commands).To(HaveLen(3))
+				Expect(output.NumErrors).To(Equal(1))
+				Expect(output.FailedCommands).To(HaveLen(1))
+				Expect(output.Commands[0].Stdout).To(Equal("first\n"))
+				Expect(output.Commands[0].Error).ToNot(HaveOccurred())
+				Expect(output.Commands[1].Stdout).To(Equal("second\n"))
+				Expect(output.Commands[1].Stderr).To(Equal("failed\n"))
+				Expect(output.Commands[1].ExitStatus().Code).To(Equal(2))
+				Expect(output.Commands[2].Stdout).To(Equal("third\n"))
+				for _, command := range output.Commands {
+					Expect(command.Completed).To(BeTrue())
+					Expect(command.Canceled).To(BeFalse())
+				}
+			})
+			It("runs the commands in parallel", func() {
+				commandList := []cluster.ShellCommand{hostCommand("sleep 1"), hostCommand("sleep 1"), hostCommand("sleep 1")}
+				start := time.Now()
+				output := executor.ExecuteClusterCommand(cluster.ON_HOSTS, commandList)
+				Expect(output.NumErrors).To(Equal(0))
+				Expect(time.Since(start)).To(BeNumerically("<", 2500*time.Millisecond))
+			})
+		})
+
+		Describe("ExecuteClusterCommandWithRetries", func() {
+			It("records an error for each failed attempt", func() {
+				output := executor.ExecuteClusterCommandWithRetries(cluster.ON_HOSTS, []cluster.ShellCommand{hostCommand("exit 1")}, 2, 10*time.Millisecond)
+				Expect(output.NumErrors).To(Equal(1))
+				retryErr := output.Commands[0].RetryError.Error()
+				Expect(retryErr).To(ContainSubstring("attempt 1"))
+				Expect(retryErr).To(ContainSubstring("attempt 2"))
+			})
+			It("does not retry a successful command", func() {
+				output := executor.ExecuteClusterCommandWithRetries(cluster.ON_HOSTS, []cluster.ShellCommand{hostCommand("true")}, 3, 10*time.Millisecond)
+				Expect(output.NumErrors).To(Equal(0))
+				Expect(output.Commands[0].RetryError).ToNot(HaveOccurred())
+			})
+		})
+
+		Describe("ExecuteClusterCommandWithContext", func() {
+			var contextExecutor cluster.ContextExecutor
+
+			BeforeEach(func() {
+				var ok bool
+				if contextExecutor, ok = executor.(cluster.ContextExecutor); !ok {
+					Skip(fmt.Sprintf("%s does not implement cluster.ContextExecutor", name))
+				}
+			})
+
+			It("does not run commands if the context is already canceled", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				output := contextExecutor.ExecuteClusterCommandWithContext(cluster.ON_HOSTS, []cluster.ShellCommand{hostCommand("echo ran")}, ctx)
+				Expect(output.NumErrors).To(Equal(1))
+				Expect(output.NumCanceled).To(Equal(1))
+				Expect(output.Commands[0].Canceled).To(BeTrue())
+				Expect(output.Commands[0].Completed).To(BeFalse())
+				Expect(output.Commands[0].Error).To(MatchError(context.Canceled))
+				Expect(strings.TrimSpace(output.Commands[0].Stdout)).To(BeEmpty())
+			})
+			It("stops running commands when the context is canceled", func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+				defer cancel()
+				start := time.Now()
+				output := contextExecutor.ExecuteClusterCommandWithRetriesAndContext(cluster.ON_HOSTS, []cluster.ShellCommand{hostCommand("sleep 10")}, 3, time.Second, ctx)
+				Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+				Expect(output.NumCanceled).To(Equal(1))
+				Expect(output.Commands[0].Error).To(MatchError(context.DeadlineExceeded))
+			})
+		})
+	})
+}
+
+/*
+ * FSContractTests registers specs checking that the filesystem functions in
+ * the operating.SystemFunctions returned by newSystem behave as the os
+ * package's do, so that the rest of the library can use them through
+ * operating.System.  newSystem is called before each spec and also returns
+ * an empty directory, as the functions see it, in which the specs may create
+ * files.  It should be called at the top level of a test file in a Ginkgo
+ * suite, e.g.
+ *
+ *   var _ = testhelper.FSContractTests("TempFS", func() (*operating.SystemFunctions, string) {
+ *       tempFS := testhelper.NewTempFS(GinkgoT())
+ *       tempFS.Install()
+ *       tempFS.MkdirAll("/work", 0755)
+ *       return operating.System, "/work"
+ *   })
+ */
+func FSContractTests(name string, newSystem func() (*operating.SystemFunctions, string)) bool {
+	return Describe(fmt.Sprintf("%s filesystem contract", name), func() {
+		var (
+			system *operating.SystemFunctions
+			dir    string
+		)
+		writeFile := func(name string, contents string) {
+			file, err := system.OpenFileWrite(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = io.WriteString(file, contents)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(file.Close()).To(Succeed())
+		}
+		expectNotExist := func(err error, name string) {
+			Expect(system.IsNotExist(err)).To(BeTrue(), "expected a not-exist error, got %v", err)
+			Expect(errors.Is(err, fs.ErrNotExist)).To(BeTrue())
+			var pathErr *os.PathError
+			Expect(errors.As(err, &pathErr)).To(BeTrue())
+			Expect(pathErr.Path).To(Equal(name))
+		}
+
+		BeforeEach(func() {
+			system, dir = newSystem()
+		})
+
+		Describe("OpenFileWrite and OpenFileRead", func() {
+			It("read back what was written", func() {
+				name := filepath.Join(dir, "file.txt")
+				writeFile(name, "hello world")
+				Expect(system.ReadFile(name)).To(Equal([]byte("hello world")))
+
+				file, err := system.OpenFileRead(name, os.O_RDONLY, 0)
+				Expect(err).ToNot(HaveOccurred())
+				defer file.Close()
+				buffer := make([]byte, 5)
+				_, err = file.ReadAt(buffer, 6)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(buffer)).To(Equal("world"))
+			})
+			It("truncates an existing file", func() {
+				name := filepath.Join(dir, "file.txt")
+				writeFile(name, "a longer first version")
+				writeFile(name, "second")
+				Expect(system.ReadFile(name)).To(Equal([]byte("second")))
+			})
+			It("refuses to create an existing file exclusively", func() {
+				name := filepath.Join(dir, "file.txt")
+				writeFile(name, "first")
+				_, err := system.OpenFileWrite(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+				Expect(errors.Is(err, fs.ErrExist)).To(BeTrue(), "expected an exists error, got %v", err)
+				Expect(system.ReadFile(name)).To(Equal([]byte("first")))
+			})
+			It("returns a not-exist error naming a missing file", func() {
+				name := filepath.Join(dir, "missing.txt")
+				_, err := system.OpenFileRead(name, os.O_RDONLY, 0)
+				expectNotExist(err, name)
+				_, err = system.ReadFile(name)
+				expectNotExist(err, name)
+			})
+		})
+
+		Describe("Stat and Lstat", func() {
+			It("describe files and directories", func() {
+				writeFile(filepath.Join(dir, "file.txt"), "hello")
+				info, err := system.Stat(filepath.Join(dir, "file.txt"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(info.Name()).To(Equal("file.txt"))
+				Expect(info.Size()).To(Equal(int64(5)))
+				Expect(info.Mode().IsRegular()).To(BeTrue())
+				info, err = system.Stat(dir)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(info.IsDir()).To(BeTrue())
+			})
+			It("return a not-exist error naming a missing file", func() {
+				name := filepath.Join(dir, "missing.txt")
+				_, err := system.Stat(name)
+				expectNotExist(err, name)
+				_, err = system.Lstat(name)
+				expectNotExist(err, name)
+			})
+			It("follow symbolic links only for Stat", func() {
+				writeFile(filepath.Join(dir, "file.txt"), "hello")
+				link := filepath.Join(dir, "link")
+				Expect(system.Symlink("file.txt", link)).To(Succeed())
+				Expect(system.Readlink(link)).To(Equal("file.txt"))
+				info, err := system.Lstat(link)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(info.Mode() & os.ModeSymlink).ToNot(BeZero())
+				info, err = system.Stat(link)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(info.Mode().IsRegular()).To(BeTrue())
+				Expect(system.ReadFile(link)).To(Equal([]byte("hello")))
+			})
+		})
+
+		Describe("Chmod", func() {
+			It("changes the permissions of a file", func() {
+				name := filepath.Join(dir, "file.txt")
+				writeFile(name, "hello")
+				Expect(system.Chmod(name, 0600)).To(Succeed())
+				info, err := system.Stat(name)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+			})
+		})
+
+		Describe("MkdirAll, ReadDir, and Glob", func() {
+			It("create nested directories and list them", func() {
+				nested := filepath.Join(dir, "a", "b")
+				Expect(system.MkdirAll(nested, 0755)).To(Succeed())
+				Expect(system.MkdirAll(nested, 0755)).To(Succeed())
+				writeFile(filepath.Join(nested, "2.txt"), "two")
+				writeFile(filepath.Join(nested, "1.txt"), "one")
+				writeFile(filepath.Join(nested, "other.dat"), "other")
+
+				entries, err := system.ReadDir(nested)
+				Expect(err).ToNot(HaveOccurred())
+				names := make([]string, 0)
+				for _, entry := range entries {
+					names = append(names, entry.Name())
+				}
+				Expect(names).To(Equal([]string{"1.txt", "2.txt", "other.dat"}))
+
+				matches, err := system.Glob(filepath.Join(nested, "*.txt"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(matches).To(Equal([]string{filepath.Join(nested, "1.txt"), filepath.Join(nested, "2.txt")}))
+				Expect(system.ReadFile(matches[0])).To(Equal([]byte("one")))
+			})
+			It("return a not-exist error when reading a missing directory", func() {
+				name := filepath.Join(dir, "missing")
+				_, err := system.ReadDir(name)
+				expectNotExist(err, name)
+			})
+		})
+
+		Describe("Rename", func() {
+			It("replaces an existing file", func() {
+				oldName, newName := filepath.Join(dir, "file.txt.tmp"), filepath.Join(dir, "file.txt")
+				writeFile(newName, "old")
+				writeFile(oldName, "new")
+				Expect(system.Rename(oldName, newName)).To(Succeed())
+				Expect(system.ReadFile(newName)).To(Equal([]byte("new")))
+				_, err := system.Stat(oldName)
+				expectNotExist(err, oldName)
+			})
+		})
+
+		Describe("Remove and RemoveAll", func() {
+			It("remove a file, or a whole tree", func() {
+				name := filepath.Join(dir, "file.txt")
+				writeFile(name, "hello")
+				Expect(system.Remove(name)).To(Succeed())
+				_, err := system.Stat(name)
+				expectNotExist(err, name)
+				err = system.Remove(name)
+				expectNotExist(err, name)
+
+				tree := filepath.Join(dir, "tree")
+				Expect(system.MkdirAll(filepath.Join(tree, "a", "b"), 0755)).To(Succeed())
+				writeFile(filepath.Join(tree, "a", "b", "file.txt"), "hello")
+				Expect(system.RemoveAll(tree)).To(Succeed())
+				_, err = system.Stat(tree)
+				expectNotExist(err, tree)
+				Expect(system.RemoveAll(tree)).To(Succeed())
+			})
+		})
+
+		Describe("TempFile and MkdirTemp", func() {
+			It("create uniquely named files and directories", func() {
+				first, err := system.TempFile(dir, "report_*.txt")
+				Expect(err).ToNot(HaveOccurred())
+				defer first.Close()
+				second, err := system.TempFile(dir, "report_*.txt")
+				Expect(err).ToNot(HaveOccurred())
+				defer second.Close()
+				Expect(first.Name()).ToNot(Equal(second.Name()))
+				Expect(filepath.Base(first.Name())).To(MatchRegexp(`^report_.+\.txt$`))
+				_, err = io.WriteString(first, "hello")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(system.ReadFile(first.Name())).To(Equal([]byte("hello")))
+
+				tempDir, err := system.MkdirTemp(dir, "work_")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(filepath.Dir(tempDir)).To(Equal(dir))
+				Expect(filepath.Base(tempDir)).To(HavePrefix("work_"))
+				info, err := system.Stat(tempDir)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(info.IsDir()).To(BeTrue())
+			})
+		})
+	})
+}
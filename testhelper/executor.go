@@ -0,0 +1,299 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package testhelper
+
+/*
+ * This file contains a fake cluster.Executor for testing code that runs
+ * commands on the cluster without running anything, where the output of each
+ * command needs to depend on which host or segment it was sent to.
+ */
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/operating"
+)
+
+/*
+ * A FakeResponse describes what a FakeExecutor returns for the commands it
+ * matches.  Command is a regular expression matched against the command
+ * string, and an empty Command matches every command.  If Host is set, only
+ * commands sent to that host match; if Contents is set, only commands for one
+ * of those content ids match.  Local commands only match responses that set
+ * neither.
+ *
+ * If Times is positive, the response is used for that many matching commands
+ * and then ignored; otherwise it is used for every matching command.  Each
+ * retry of a cluster command is matched again, so a response with Times set
+ * to 1 and an Err followed by a plain response simulates a command that
+ * succeeds on its second attempt.
+ *
+ * A matching command first waits for Latency using operating.System timers,
+ * so that it works with a FakeClock, and then returns Stdout and Stderr, and
+ * fails with Err if it is set.
+ */
+type FakeResponse struct {
+	Command  string
+	Host     string
+	Contents []int
+	Stdout   string
+	Stderr   string
+	Err      error
+	Latency  time.Duration
+	Times    int
+}
+
+type fakeResponse struct {
+	FakeResponse
+	pattern *regexp.Regexp
+	used    int
+}
+
+// A FakeCall records one attempt to run a command through a FakeExecutor
+type FakeCall struct {
+	Scope   cluster.Scope
+	Content int
+	Host    string
+	Command string
+	Attempt int
+	Local   bool
+}
+
+/*
+ * A FakeExecutor implements cluster.Executor by looking up each command in a
+ * list of FakeResponses, checked in the order they were added; commands that
+ * match none of them succeed with no output.  Cluster commands run in
+ * parallel, as they do with a GPDBExecutor, so tests using latency should not
+ * depend on the order of calls.
+ *
+ * Per-segment commands usually have no Host set, so CommandHost can be set to
+ * return the host for such commands, as for GPDBExecutor, to match them
+ * against a Host.
+ */
+type FakeExecutor struct {
+	CommandHost func(command cluster.ShellCommand) string
+
+	mutex     sync.Mutex
+	responses []*fakeResponse
+	calls     []FakeCall
+}
+
+func NewFakeExecutor(responses ...FakeResponse) *FakeExecutor {
+	executor := &FakeExecutor{}
+	for _, response := range responses {
+		executor.Add(response)
+	}
+	return executor
+}
+
+// Add panics if response.Command is not a valid regular expression
+func (executor *FakeExecutor) Add(response FakeResponse) *FakeExecutor {
+	executor.mutex.Lock()
+	defer executor.mutex.Unlock()
+	executor.responses = append(executor.responses, &fakeResponse{FakeResponse: response, pattern: regexp.MustCompile(response.Command)})
+	return executor
+}
+
+// FailHost makes every command sent to host fail with err
+func (executor *FakeExecutor) FailHost(host string, err error) *FakeExecutor {
+	return executor.Add(FakeResponse{Host: host, Err: err})
+}
+
+// FailContent makes every command for the segment with content id content fail with err
+func (executor *FakeExecutor) FailContent(content int, err error) *FakeExecutor {
+	return executor.Add(FakeResponse{Contents: []int{content}, Err: err})
+}
+
+// Calls returns every command attempt made so far, in the order they were made
+func (executor *FakeExecutor) Calls() []FakeCall {
+	executor.mutex.Lock()
+	defer executor.mutex.Unlock()
+	return append([]FakeCall{}, executor.calls...)
+}
+
+func (executor *FakeExecutor) CallsForHost(host string) []FakeCall {
+	return executor.filterCalls(func(call FakeCall) bool { return !call.Local && call.Host == host })
+}
+
+func (executor *FakeExecutor) CallsForContent(content int) []FakeCall {
+	return executor.filterCalls(func(call FakeCall) bool { return !call.Local && call.Content == content })
+}
+
+func (executor *FakeExecutor) LocalCalls() []FakeCall {
+	return executor.filterCalls(func(call FakeCall) bool { return call.Local })
+}
+
+func (executor *FakeExecutor) filterCalls(keep func(call FakeCall) bool) []FakeCall {
+	calls := make([]FakeCall, 0)
+	for _, call := range executor.Calls() {
+		if keep(call) {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+func (executor *FakeExecutor) commandHost(command cluster.ShellCommand) string {
+	if executor.CommandHost != nil {
+		return executor.CommandHost(command)
+	}
+	return command.Host
+}
+
+// respond records call and returns the response it matches, or an empty response if there is none
+func (executor *FakeExecutor) respond(call FakeCall) FakeResponse {
+	executor.mutex.Lock()
+	defer executor.mutex.Unlock()
+	executor.calls = append(executor.calls, call)
+	for _, response := range executor.responses {
+		if response.Times > 0 && response.used >= response.Times {
+			continue
+		} else if !response.pattern.MatchString(call.Command) || !response.matchesTarget(call) {
+			continue
+		}
+		response.used++
+		return response.FakeResponse
+	}
+	return FakeResponse{}
+}
+
+func (response *fakeResponse) matchesTarget(call FakeCall) bool {
+	if call.Local {
+		return response.Host == "" && len(response.Contents) == 0
+	}
+	if response.Host != "" && response.Host != call.Host {
+		return false
+	}
+	if len(response.Contents) == 0 {
+		return true
+	}
+	for _, content := range response.Contents {
+		if content == call.Content {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * FakeExitError returns the error a command exiting with code returns, so
+ * that a FakeResponse can fail with an error that operating.DecodeExitStatus
+ * and ShellCommand.ExitStatus understand.  There is no way to build an
+ * *exec.ExitError directly, so it runs a shell that exits with code.
+ */
+func FakeExitError(code int) error {
+	return exec.Command("sh", "-c", fmt.Sprintf("exit %d", code)).Run()
+}
+
+// waitOrCancel returns ctx.Err() if ctx is canceled before d has passed
+func waitOrCancel(ctx context.Context, d time.Duration) error {
+	if d > 0 {
+		timer := operating.System.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+		}
+	}
+	return ctx.Err()
+}
+
+func (executor *FakeExecutor) ExecuteLocalCommand(commandStr string) (string, error) {
+	return executor.ExecuteLocalCommandWithContext(commandStr, context.Background())
+}
+
+// Like GPDBExecutor, this returns the combined Stdout and Stderr of the response
+func (executor *FakeExecutor) ExecuteLocalCommandWithContext(commandStr string, ctx context.Context) (string, error) {
+	response := executor.respond(FakeCall{Command: commandStr, Attempt: 1, Local: true})
+	if err := waitOrCancel(ctx, response.Latency); err != nil {
+		return "", err
+	}
+	return response.Stdout + response.Stderr, response.Err
+}
+
+func (executor *FakeExecutor) ExecuteClusterCommand(scope cluster.Scope, commandList []cluster.ShellCommand) *cluster.RemoteOutput {
+	return executor.ExecuteClusterCommandWithRetriesAndContext(scope, commandList, 1, 0, context.Background())
+}
+
+func (executor *FakeExecutor) ExecuteClusterCommandWithRetries(scope cluster.Scope, commandList []cluster.ShellCommand, maxAttempts int, retrySleep time.Duration) *cluster.RemoteOutput {
+	return executor.ExecuteClusterCommandWithRetriesAndContext(scope, commandList, maxAttempts, retrySleep, context.Background())
+}
+
+func (executor *FakeExecutor) ExecuteClusterCommandWithContext(scope cluster.Scope, commandList []cluster.ShellCommand, ctx context.Context) *cluster.RemoteOutput {
+	return executor.ExecuteClusterCommandWithRetriesAndContext(scope, commandList, 1, 0, ctx)
+}
+
+/*
+ * Commands are run as GPDBExecutor runs them, including waiting retrySleep
+ * between attempts, and their results are reported in the same fields.
+ */
+func (executor *FakeExecutor) ExecuteClusterCommandWithRetriesAndContext(scope cluster.Scope, commandList []cluster.ShellCommand, maxAttempts int, retrySleep time.Duration, ctx context.Context) *cluster.RemoteOutput {
+	var waitGroup sync.WaitGroup
+	for i := range commandList {
+		waitGroup.Add(1)
+		go func(index int) {
+			defer waitGroup.Done()
+			commandList[index] = executor.runCommand(commandList[index], maxAttempts, retrySleep, ctx)
+		}(i)
+	}
+	waitGroup.Wait()
+	numErrors := 0
+	for _, command := range commandList {
+		if command.Error != nil {
+			numErrors++
+		}
+	}
+	return cluster.NewRemoteOutput(scope, numErrors, commandList)
+}
+
+func (executor *FakeExecutor) runCommand(command cluster.ShellCommand, maxAttempts int, retrySleep time.Duration, ctx context.Context) cluster.ShellCommand {
+	host := executor.commandHost(command)
+	var response FakeResponse
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		response = executor.respond(FakeCall{Scope: command.Scope, Content: command.Content, Host: host, Command: command.CommandString, Attempt: attempt})
+		if err = waitOrCancel(ctx, response.Latency); err != nil {
+			response = FakeResponse{}
+			break
+		}
+		err = response.Err
+		if err == nil {
+			break
+		}
+		command.RetryError = errors.Join(command.RetryError, fmt.Errorf("attempt %d: error was %w: %s", attempt, err, response.Stderr))
+		if attempt != maxAttempts {
+			if err = waitOrCancel(ctx, retrySleep); err != nil {
+				break
+			}
+			err = response.Err
+		}
+	}
+	command.Stdout = response.Stdout
+	command.Stderr = response.Stderr
+	command.Error = err
+	command.Canceled = err != nil && err == ctx.Err()
+	command.Completed = !command.Canceled
+	return command
+}
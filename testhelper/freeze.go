@@ -0,0 +1,85 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package testhelper
+
+/*
+ * This file contains a helper for replacing the functions in operating.System
+ * that identify when, where, and as whom a program is running, which appear
+ * in log prefixes, file names, and ssh commands, with fixed values.
+ */
+
+import (
+	"os/user"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/operating"
+)
+
+// FreezeTestingT is the part of testing.T that FreezeSystem needs, so that both a *testing.T and GinkgoT() can be passed
+type FreezeTestingT interface {
+	Cleanup(func())
+}
+
+/*
+ * The values FreezeSystem installs.  Any that are not set default to the
+ * values most tests in this library already use: 2017-01-01 01:01:01 local
+ * time, host testHost, and user testUser with home directory testDir.  Pid
+ * defaults to 0.
+ */
+type FreezeOptions struct {
+	Now      time.Time
+	Pid      int
+	Hostname string
+	Username string
+	HomeDir  string
+}
+
+/*
+ * FreezeSystem replaces Now, Getpid, Hostname, and CurrentUser in
+ * operating.System with functions returning the values in options, and
+ * restores operating.System to its previous state when the test ends.  Now
+ * always returns the same time; tests of code that waits or measures
+ * durations should use a FakeClock as well.
+ */
+func FreezeSystem(t FreezeTestingT, options FreezeOptions) {
+	if options.Now.IsZero() {
+		options.Now = time.Date(2017, time.January, 1, 1, 1, 1, 1, time.Local)
+	}
+	if options.Hostname == "" {
+		options.Hostname = "testHost"
+	}
+	if options.Username == "" {
+		options.Username = "testUser"
+	}
+	if options.HomeDir == "" {
+		options.HomeDir = "testDir"
+	}
+
+	previous := operating.System
+	saved := *previous
+	t.Cleanup(func() {
+		*previous = saved
+		operating.System = previous
+	})
+	operating.System.Now = func() time.Time { return options.Now }
+	operating.System.Getpid = func() int { return options.Pid }
+	operating.System.Hostname = func() (string, error) { return options.Hostname, nil }
+	operating.System.CurrentUser = func() (*user.User, error) {
+		return &user.User{Username: options.Username, HomeDir: options.HomeDir}, nil
+	}
+}
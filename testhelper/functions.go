@@ -5,6 +5,7 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"time"
 
 	sqlmock "github.com/DATA-DOG/go-sqlmock"
 	"github.com/apache/cloudberry-go-libs/dbconn"
@@ -86,12 +87,116 @@ func NotExpectRegexp(buffer *gbytes.Buffer, testStr string) {
 	Expect(buffer).ShouldNot(gbytes.Say(regexp.QuoteMeta(testStr)))
 }
 
+/*
+ * The following WaitFor... functions poll until a condition holds, failing the
+ * test if it does not hold before the timeout, so that tests of asynchronous
+ * code do not need to sleep for a fixed time.  They wait and measure the
+ * timeout with operating.System.Sleep and MonotonicNow, so with a FakeClock
+ * installed they only poll again, or time out, when the clock is advanced.
+ */
+
+var WaitPollInterval = 10 * time.Millisecond
+
+// waitFor polls condition until it returns true, returning false if timeout elapses first
+func waitFor(condition func() bool, timeout time.Duration) bool {
+	start := operating.System.MonotonicNow()
+	for {
+		if condition() {
+			return true
+		}
+		if operating.Since(start) >= timeout {
+			return false
+		}
+		operating.System.Sleep(WaitPollInterval)
+	}
+}
+
+func WaitForCondition(condition func() bool, timeout time.Duration, description string) {
+	Expect(waitFor(condition, timeout)).To(BeTrue(), "Timed out after %s waiting for %s", timeout, description)
+}
+
+// pattern is a regular expression; like ExpectRegexp, this consumes the buffer up to the match
+func WaitForLog(buffer *gbytes.Buffer, pattern string, timeout time.Duration) {
+	matcher := gbytes.Say(pattern)
+	matched := waitFor(func() bool {
+		success, _ := matcher.Match(buffer)
+		return success
+	}, timeout)
+	Expect(matched).To(BeTrue(), "Timed out after %s waiting for log output matching %s", timeout, pattern)
+}
+
+func WaitForFile(path string, timeout time.Duration) {
+	WaitForCondition(func() bool {
+		_, err := operating.System.Stat(path)
+		return err == nil
+	}, timeout, fmt.Sprintf("file %s to exist", path))
+}
+
+/*
+ * The ShouldPanic... functions must be deferred directly, as in
+ * "defer testhelper.ShouldPanicWithMessage(message)", so that they can
+ * recover the panic.
+ */
 func ShouldPanicWithMessage(message string) {
-	r := recover()
-	Expect(r).NotTo(BeNil(), "Function did not panic as expected")
+	errorMessage := panicMessage(recover())
+	Expect(errorMessage).Should(ContainSubstring(message))
+}
+
+// pattern is a regular expression that must match some part of the panic message
+func ShouldPanicMatching(pattern string) {
+	errorMessage := panicMessage(recover())
+	Expect(errorMessage).Should(MatchRegexp(pattern))
+}
 
-	errorMessage := strings.TrimSpace(fmt.Sprintf("%v", r))
+/*
+ * ShouldPanicWithCritical checks both that the function panicked with a
+ * message containing message, as gplog.Fatal does, and that a CRITICAL entry
+ * containing message was written to logfile, which should be the log file
+ * buffer returned by SetupTestLogger.
+ */
+func ShouldPanicWithCritical(logfile *gbytes.Buffer, message string) {
+	errorMessage := panicMessage(recover())
 	Expect(errorMessage).Should(ContainSubstring(message))
+	ExpectRegexp(logfile, "[CRITICAL]:-"+message)
+}
+
+func panicMessage(r interface{}) string {
+	Expect(r).NotTo(BeNil(), "Function did not panic as expected")
+	return strings.TrimSpace(fmt.Sprintf("%v", r))
+}
+
+type capturedExit struct {
+	code int
+}
+
+/*
+ * CaptureExit calls f and returns the code it exits with, whether through
+ * operating.System.Exit or through gplog's exit function, as called by
+ * gplog.FatalWithoutPanic; the latter is reported as 1, the code with which
+ * gplog exits by default.  It returns -1 if f returns without exiting.
+ *
+ * Exiting is simulated with a panic that unwinds f, so any deferred calls in
+ * f still run, and it must happen on the goroutine calling CaptureExit.  Both
+ * exit functions are restored before CaptureExit returns.
+ */
+func CaptureExit(f func()) (code int) {
+	systemExit := operating.System.Exit
+	gplogExit := gplog.GetExitFunc()
+	defer func() {
+		operating.System.Exit = systemExit
+		gplog.SetExitFunc(gplogExit)
+		if r := recover(); r != nil {
+			exit, ok := r.(capturedExit)
+			if !ok {
+				panic(r)
+			}
+			code = exit.code
+		}
+	}()
+	operating.System.Exit = func(code int) { panic(capturedExit{code: code}) }
+	gplog.SetExitFunc(func() { panic(capturedExit{code: 1}) })
+	f()
+	return -1
 }
 
 func AssertQueryRuns(connection *dbconn.DBConn, query string) {
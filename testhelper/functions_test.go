@@ -0,0 +1,168 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package testhelper_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/gplog"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("testhelper/functions tests", func() {
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("WaitForCondition", func() {
+		It("returns once the condition holds", func() {
+			var ready atomic.Bool
+			go func() {
+				time.Sleep(20 * time.Millisecond)
+				ready.Store(true)
+			}()
+			testhelper.WaitForCondition(ready.Load, 5*time.Second, "ready")
+			Expect(ready.Load()).To(BeTrue())
+		})
+		It("fails if the condition does not hold before the timeout", func() {
+			failure := InterceptGomegaFailure(func() {
+				testhelper.WaitForCondition(func() bool { return false }, 30*time.Millisecond, "nothing")
+			})
+			Expect(failure).To(MatchError(ContainSubstring("Timed out after 30ms waiting for nothing")))
+		})
+		It("polls and times out as a fake clock is advanced", func() {
+			clock := testhelper.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			clock.Install()
+			var polls atomic.Int32
+			result := make(chan error, 1)
+			go func() {
+				result <- InterceptGomegaFailure(func() {
+					testhelper.WaitForCondition(func() bool {
+						polls.Add(1)
+						return false
+					}, time.Minute, "nothing")
+				})
+			}()
+			// Gomega is not used here until the wait is over, since the failure is intercepted in another goroutine
+			clock.BlockUntil(1)
+			pollsBeforeAdvance := polls.Load()
+			clock.Advance(30 * time.Second)
+			clock.BlockUntil(1)
+			pollsAfterFirstAdvance := polls.Load()
+			clock.Advance(30 * time.Second)
+			var failure error
+			select {
+			case failure = <-result:
+			case <-time.After(5 * time.Second):
+			}
+
+			Expect(pollsBeforeAdvance).To(Equal(int32(1)))
+			Expect(pollsAfterFirstAdvance).To(Equal(int32(2)))
+			Expect(polls.Load()).To(Equal(int32(3)))
+			Expect(failure).To(MatchError(ContainSubstring("Timed out after 1m0s waiting for nothing")))
+		})
+	})
+	Describe("WaitForLog", func() {
+		It("waits for matching output and consumes the buffer up to it", func() {
+			buffer := gbytes.NewBuffer()
+			go func() {
+				time.Sleep(20 * time.Millisecond)
+				_, _ = buffer.Write([]byte("20240101:00:00:00 [INFO]:-Backup completed\nafter\n"))
+			}()
+			testhelper.WaitForLog(buffer, `\[INFO\]:-Backup completed`, 5*time.Second)
+			Expect(buffer).To(gbytes.Say("after"))
+		})
+		It("fails if no matching output appears before the timeout", func() {
+			buffer := gbytes.NewBuffer()
+			_, _ = buffer.Write([]byte("something else\n"))
+			failure := InterceptGomegaFailure(func() {
+				testhelper.WaitForLog(buffer, "Backup completed", 30*time.Millisecond)
+			})
+			Expect(failure).To(MatchError(ContainSubstring("Timed out after 30ms waiting for log output matching Backup completed")))
+		})
+	})
+	Describe("WaitForFile", func() {
+		It("waits for the file to be created", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "done")
+			go func() {
+				time.Sleep(20 * time.Millisecond)
+				_ = os.WriteFile(path, nil, 0644)
+			}()
+			testhelper.WaitForFile(path, 5*time.Second)
+			Expect(path).To(BeAnExistingFile())
+		})
+		It("fails if the file does not exist before the timeout", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "missing")
+			failure := InterceptGomegaFailure(func() {
+				testhelper.WaitForFile(path, 30*time.Millisecond)
+			})
+			Expect(failure).To(MatchError(ContainSubstring("waiting for file " + path + " to exist")))
+		})
+	})
+	Describe("CaptureExit", func() {
+		It("returns the code passed to operating.System.Exit, after running deferred calls", func() {
+			cleanedUp := false
+			code := testhelper.CaptureExit(func() {
+				defer func() { cleanedUp = true }()
+				operating.System.Exit(3)
+				Fail("Function did not stop at exit")
+			})
+			Expect(code).To(Equal(3))
+			Expect(cleanedUp).To(BeTrue())
+		})
+		It("returns 1 if the function exits through gplog", func() {
+			_, _, logfile := testhelper.SetupTestLogger()
+			code := testhelper.CaptureExit(func() {
+				gplog.FatalWithoutPanic("cannot continue")
+				Fail("Function did not stop at exit")
+			})
+			Expect(code).To(Equal(1))
+			testhelper.ExpectRegexp(logfile, "cannot continue")
+		})
+		It("returns -1 if the function does not exit, and restores the exit functions", func() {
+			exitFunc := gplog.GetExitFunc()
+			Expect(testhelper.CaptureExit(func() {})).To(Equal(-1))
+			Expect(fmt.Sprintf("%p", gplog.GetExitFunc())).To(Equal(fmt.Sprintf("%p", exitFunc)))
+		})
+		It("passes on any other panic", func() {
+			Expect(func() {
+				testhelper.CaptureExit(func() { panic("unrelated") })
+			}).To(PanicWith("unrelated"))
+		})
+	})
+})
+
+var _ = testhelper.FSContractTests("TempFS", func() (*operating.SystemFunctions, string) {
+	operating.System = operating.InitializeSystemFunctions()
+	DeferCleanup(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+	tempFS := testhelper.NewTempFS(GinkgoT())
+	tempFS.Install()
+	tempFS.MkdirAll("/work", 0755)
+	return operating.System, "/work"
+})
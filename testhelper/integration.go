@@ -0,0 +1,273 @@
+//go:build integration
+
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package testhelper
+
+/*
+ * This file contains a harness for integration tests that need a real
+ * single-node cluster, either one that is already running or one started in
+ * a Docker container for the duration of the tests.  It is only built with
+ * the "integration" build tag, as in "make integration".
+ */
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/dbconn"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+/*
+ * If INTEGRATION_IMAGE_ENV is set, the harness starts a container from that
+ * image, which must run a demo cluster listening on IntegrationOptions.Port
+ * inside the container.  Otherwise it connects to an already-running cluster
+ * using the usual PGHOST, PGPORT, PGUSER, and PGDATABASE variables.
+ */
+const INTEGRATION_IMAGE_ENV = "GP_TEST_IMAGE"
+
+/*
+ * IntegrationOptions control how an integration cluster is located or
+ * started.  Image defaults to the value of INTEGRATION_IMAGE_ENV; Port and
+ * User, which only apply to a container, default to 7000 and gpadmin, as in
+ * the Cloudberry demo cluster; DBName, used if PGDATABASE is not set,
+ * defaults to postgres; and StartTimeout, how long to wait for a container to
+ * accept connections, defaults to 5 minutes.
+ */
+type IntegrationOptions struct {
+	Image        string
+	Port         int
+	User         string
+	DBName       string
+	StartTimeout time.Duration
+}
+
+/*
+ * An IntegrationCluster holds a connection to the coordinator and a Cluster
+ * built from its segment configuration.  If the cluster is running in a
+ * container, the Cluster's executor runs all commands inside the container,
+ * so that commands generated for segment hosts work as they would on a real
+ * cluster.
+ */
+type IntegrationCluster struct {
+	Connection  *dbconn.DBConn
+	Cluster     *cluster.Cluster
+	ContainerID string
+}
+
+func (options IntegrationOptions) withDefaults() IntegrationOptions {
+	if options.Image == "" {
+		options.Image = operating.System.Getenv(INTEGRATION_IMAGE_ENV)
+	}
+	if options.Port == 0 {
+		options.Port = 7000
+	}
+	if options.User == "" {
+		options.User = "gpadmin"
+	}
+	if options.DBName == "" {
+		options.DBName = "postgres"
+	}
+	if options.StartTimeout == 0 {
+		options.StartTimeout = 5 * time.Minute
+	}
+	return options
+}
+
+/*
+ * StartIntegrationCluster locates or starts a cluster as described for
+ * INTEGRATION_IMAGE_ENV and returns it once it accepts connections.  The
+ * caller must call Teardown when done with it.
+ */
+func StartIntegrationCluster(options IntegrationOptions) (*IntegrationCluster, error) {
+	options = options.withDefaults()
+	if options.Image == "" {
+		dbname := operating.System.Getenv("PGDATABASE")
+		if dbname == "" {
+			dbname = options.DBName
+		}
+		connection := dbconn.NewDBConnFromEnvironment(dbname)
+		if err := connection.Connect(1); err != nil {
+			return nil, errors.Wrapf(err, "Unable to connect to the cluster given by PGHOST and PGPORT; set %s to start one in Docker instead", INTEGRATION_IMAGE_ENV)
+		}
+		return newIntegrationCluster(connection, "", "")
+	}
+
+	output, err := operating.System.CommandOutput("docker", "run", "--detach", "--rm",
+		"--publish", fmt.Sprintf("127.0.0.1::%d", options.Port), options.Image)
+	if err != nil {
+		return nil, dockerError(err, output, "Unable to start container from image %s", options.Image)
+	}
+	containerID := strings.TrimSpace(string(output))
+	integration := &IntegrationCluster{ContainerID: containerID}
+
+	connection, err := waitForContainer(containerID, options)
+	if err != nil {
+		_ = integration.Teardown()
+		return nil, err
+	}
+	result, err := newIntegrationCluster(connection, containerID, options.User)
+	if err != nil {
+		connection.Close()
+		_ = integration.Teardown()
+		return nil, err
+	}
+	return result, nil
+}
+
+func waitForContainer(containerID string, options IntegrationOptions) (*dbconn.DBConn, error) {
+	output, err := operating.System.CommandOutput("docker", "port", containerID, fmt.Sprintf("%d/tcp", options.Port))
+	if err != nil {
+		return nil, dockerError(err, output, "Unable to find published port of container %s", containerID)
+	}
+	address := strings.TrimSpace(strings.Split(string(output), "\n")[0])
+	separator := strings.LastIndex(address, ":")
+	var hostPort int
+	if _, err = fmt.Sscanf(address[separator+1:], "%d", &hostPort); separator < 0 || err != nil {
+		return nil, errors.Errorf("Unable to parse published port of container %s: %q", containerID, address)
+	}
+
+	deadline := operating.System.Now().Add(options.StartTimeout)
+	for {
+		connection := dbconn.NewDBConn(options.DBName, options.User, address[:separator], hostPort)
+		err = connection.Connect(1)
+		if err == nil {
+			return connection, nil
+		}
+		if operating.System.Now().After(deadline) {
+			return nil, errors.Wrapf(err, "Cluster in container %s did not accept connections within %s", containerID, options.StartTimeout)
+		}
+		operating.System.Sleep(2 * time.Second)
+	}
+}
+
+func newIntegrationCluster(connection *dbconn.DBConn, containerID string, user string) (*IntegrationCluster, error) {
+	segments, err := cluster.GetSegmentConfiguration(connection, true)
+	if err != nil {
+		connection.Close()
+		return nil, errors.Wrap(err, "Unable to get segment configuration of integration cluster")
+	}
+	integration := &IntegrationCluster{
+		Connection:  connection,
+		Cluster:     cluster.NewCluster(segments),
+		ContainerID: containerID,
+	}
+	if containerID != "" {
+		integration.Cluster.Executor = &containerExecutor{
+			Executor: integration.Cluster.Executor,
+			prefix:   []string{"exec", "--user", user, containerID},
+		}
+	}
+	return integration, nil
+}
+
+// Teardown closes the connection and, if the harness started a container, removes it
+func (integration *IntegrationCluster) Teardown() error {
+	if integration.Connection != nil {
+		integration.Connection.Close()
+	}
+	if integration.ContainerID == "" {
+		return nil
+	}
+	output, err := operating.System.CommandOutput("docker", "rm", "--force", integration.ContainerID)
+	if err != nil {
+		return dockerError(err, output, "Unable to remove container %s", integration.ContainerID)
+	}
+	return nil
+}
+
+// dockerError wraps err with message and any output from docker explaining the failure
+func dockerError(err error, output []byte, message string, args ...interface{}) error {
+	message = fmt.Sprintf(message, args...)
+	if details := strings.TrimSpace(string(output)); details != "" {
+		message += ": " + details
+	}
+	return errors.Wrap(err, message)
+}
+
+/*
+ * SetupIntegrationCluster is for use in a BeforeSuite or BeforeAll node.  It
+ * calls StartIntegrationCluster, fails the current node if that fails, and
+ * tears the cluster down when the suite or container finishes.
+ */
+func SetupIntegrationCluster(options IntegrationOptions) *IntegrationCluster {
+	integration, err := StartIntegrationCluster(options)
+	Expect(err).ToNot(HaveOccurred())
+	DeferCleanup(func() {
+		Expect(integration.Teardown()).To(Succeed())
+	})
+	return integration
+}
+
+/*
+ * A containerExecutor runs every command with "docker exec" in the container
+ * holding the cluster, since the segment hosts it names are only reachable
+ * from inside the container.
+ */
+type containerExecutor struct {
+	cluster.Executor
+	prefix []string
+}
+
+func (executor *containerExecutor) ExecuteLocalCommand(commandStr string) (string, error) {
+	return executor.ExecuteLocalCommandWithContext(commandStr, context.Background())
+}
+
+func (executor *containerExecutor) ExecuteLocalCommandWithContext(commandStr string, ctx context.Context) (string, error) {
+	args := append(append([]string{}, executor.prefix...), "bash", "-c", commandStr)
+	output, err := exec.CommandContext(ctx, "docker", args...).CombinedOutput()
+	return string(output), err
+}
+
+func (executor *containerExecutor) wrapCommands(commandList []cluster.ShellCommand) []cluster.ShellCommand {
+	wrapped := make([]cluster.ShellCommand, len(commandList))
+	for i, command := range commandList {
+		args := append(append([]string{}, executor.prefix...), command.Command.Args...)
+		command.Command = exec.Command("docker", args...)
+		wrapped[i] = command
+	}
+	return wrapped
+}
+
+func (executor *containerExecutor) ExecuteClusterCommand(scope cluster.Scope, commandList []cluster.ShellCommand) *cluster.RemoteOutput {
+	return executor.Executor.ExecuteClusterCommand(scope, executor.wrapCommands(commandList))
+}
+
+func (executor *containerExecutor) ExecuteClusterCommandWithRetries(scope cluster.Scope, commandList []cluster.ShellCommand, maxAttempts int, retrySleep time.Duration) *cluster.RemoteOutput {
+	return executor.Executor.ExecuteClusterCommandWithRetries(scope, executor.wrapCommands(commandList), maxAttempts, retrySleep)
+}
+
+func (executor *containerExecutor) ExecuteClusterCommandWithContext(scope cluster.Scope, commandList []cluster.ShellCommand, ctx context.Context) *cluster.RemoteOutput {
+	return executor.ExecuteClusterCommandWithRetriesAndContext(scope, commandList, 1, 0, ctx)
+}
+
+func (executor *containerExecutor) ExecuteClusterCommandWithRetriesAndContext(scope cluster.Scope, commandList []cluster.ShellCommand, maxAttempts int, retrySleep time.Duration, ctx context.Context) *cluster.RemoteOutput {
+	if inner, ok := executor.Executor.(cluster.ContextExecutor); ok {
+		return inner.ExecuteClusterCommandWithRetriesAndContext(scope, executor.wrapCommands(commandList), maxAttempts, retrySleep, ctx)
+	}
+	return executor.Executor.ExecuteClusterCommandWithRetries(scope, executor.wrapCommands(commandList), maxAttempts, retrySleep)
+}
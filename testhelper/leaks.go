@@ -0,0 +1,286 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package testhelper
+
+/*
+ * This file contains functions for checking that a spec cleans up the
+ * goroutines and file descriptors it starts, so that code with background
+ * workers or long-lived connections can be tested for leaks.
+ */
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+/*
+ * Goroutines whose stacks contain any of these strings are never reported as
+ * leaks: those Ginkgo starts to run each node, and those the standard library
+ * starts once and keeps for the life of the process.
+ */
+var DefaultIgnoredGoroutines = []string{
+	"created by github.com/onsi/ginkgo/v2/",
+	"os/signal.",
+	"created by testing.",
+}
+
+/*
+ * File descriptors whose targets contain any of these strings are never
+ * reported as leaks, since the Go runtime opens them the first time they are
+ * needed and keeps them open.
+ */
+var DefaultIgnoredFiles = []string{
+	"anon_inode:[eventpoll]",
+	"anon_inode:[eventfd]",
+}
+
+/*
+ * LeakOptions controls a leak check.  Goroutines often exit shortly after
+ * whatever stopped them returns, so the check is retried until Timeout
+ * passes, defaulting to one second.  IgnoreGoroutines and IgnoreFiles are
+ * checked in addition to the defaults above.
+ */
+type LeakOptions struct {
+	Timeout          time.Duration
+	IgnoreGoroutines []string
+	IgnoreFiles      []string
+}
+
+/*
+ * A ResourceSnapshot records the goroutines and file descriptors that exist
+ * when it is taken, so that those started afterward can be reported.
+ */
+type ResourceSnapshot struct {
+	goroutines map[int]bool
+	files      map[string]bool
+}
+
+type goroutineInfo struct {
+	id        int
+	state     string
+	function  string
+	createdBy string
+	stack     string
+}
+
+func (goroutine goroutineInfo) String() string {
+	description := fmt.Sprintf("goroutine %d [%s] in %s", goroutine.id, goroutine.state, goroutine.function)
+	if goroutine.createdBy != "" {
+		description += ", created by " + goroutine.createdBy
+	}
+	return description
+}
+
+type fileInfo struct {
+	fd     int
+	target string
+}
+
+func (file fileInfo) key() string {
+	return fmt.Sprintf("%d %s", file.fd, file.target)
+}
+
+func (file fileInfo) String() string {
+	return fmt.Sprintf("file descriptor %d (%s)", file.fd, file.target)
+}
+
+func SnapshotResources() *ResourceSnapshot {
+	snapshot := &ResourceSnapshot{goroutines: make(map[int]bool), files: make(map[string]bool)}
+	for _, goroutine := range currentGoroutines() {
+		snapshot.goroutines[goroutine.id] = true
+	}
+	for _, file := range openFiles() {
+		snapshot.files[file.key()] = true
+	}
+	return snapshot
+}
+
+/*
+ * Leaks describes each goroutine and file descriptor that exists now but did
+ * not when the snapshot was taken, other than ignored ones and the calling
+ * goroutine.  A file descriptor number that was reused for a different file
+ * is reported as well.
+ */
+func (snapshot *ResourceSnapshot) Leaks(options LeakOptions) []string {
+	leaks := make([]string, 0)
+	ignoredGoroutines := append(append([]string{}, DefaultIgnoredGoroutines...), options.IgnoreGoroutines...)
+	self := currentGoroutineID()
+	for _, goroutine := range currentGoroutines() {
+		if !snapshot.goroutines[goroutine.id] && goroutine.id != self && !containsAny(goroutine.stack, ignoredGoroutines) {
+			leaks = append(leaks, goroutine.String())
+		}
+	}
+	ignoredFiles := append(append([]string{}, DefaultIgnoredFiles...), options.IgnoreFiles...)
+	for _, file := range openFiles() {
+		if !snapshot.files[file.key()] && !containsAny(file.target, ignoredFiles) {
+			leaks = append(leaks, file.String())
+		}
+	}
+	return leaks
+}
+
+// ExpectNoLeaks fails the current spec if anything started since the snapshot is still running or open after the timeout
+func (snapshot *ResourceSnapshot) ExpectNoLeaks(options LeakOptions) {
+	timeout := options.Timeout
+	if timeout == 0 {
+		timeout = time.Second
+	}
+	EventuallyWithOffset(1, func() []string {
+		return snapshot.Leaks(options)
+	}, timeout, WaitPollInterval).Should(BeEmpty(), "Goroutines or file descriptors were leaked")
+}
+
+/*
+ * DetectLeaks checks each spec in the container it is called in for leaks,
+ * by taking a snapshot before each spec and checking it once the spec and
+ * all of its AfterEach and DeferCleanup cleanup have run, e.g.
+ *
+ *   Describe("StartMetricsServer", func() {
+ *       testhelper.DetectLeaks(testhelper.LeakOptions{})
+ *       ...
+ *   })
+ */
+func DetectLeaks(options LeakOptions) {
+	BeforeEach(func() {
+		snapshot := SnapshotResources()
+		DeferCleanup(func() {
+			snapshot.ExpectNoLeaks(options)
+		})
+	})
+}
+
+func containsAny(s string, substrings []string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			return true
+		}
+	}
+	return false
+}
+
+func goroutineStacks(all bool) string {
+	buffer := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buffer, all)
+		if n < len(buffer) {
+			return string(buffer[:n])
+		}
+		buffer = make([]byte, 2*len(buffer))
+	}
+}
+
+func currentGoroutineID() int {
+	goroutines := parseGoroutines(goroutineStacks(false))
+	if len(goroutines) == 0 {
+		return -1
+	}
+	return goroutines[0].id
+}
+
+func currentGoroutines() []goroutineInfo {
+	return parseGoroutines(goroutineStacks(true))
+}
+
+/*
+ * parseGoroutines parses the output of runtime.Stack, which has a block for
+ * each goroutine of the form
+ *
+ *   goroutine 42 [chan receive]:
+ *   main.worker(0xc000010000)
+ *           /path/to/main.go:10 +0x25
+ *   created by main.start in goroutine 1
+ *           /path/to/main.go:5 +0x3c
+ */
+func parseGoroutines(stacks string) []goroutineInfo {
+	goroutines := make([]goroutineInfo, 0)
+	for _, block := range strings.Split(strings.TrimSpace(stacks), "\n\n") {
+		lines := strings.Split(block, "\n")
+		header, found := strings.CutPrefix(lines[0], "goroutine ")
+		if !found {
+			continue
+		}
+		idStr, state, _ := strings.Cut(header, " ")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			continue
+		}
+		goroutine := goroutineInfo{id: id, state: strings.Trim(state, "[]:"), stack: block}
+		if len(lines) > 1 {
+			goroutine.function = lines[1]
+			if i := strings.LastIndex(goroutine.function, "("); i > 0 {
+				goroutine.function = goroutine.function[:i]
+			}
+		}
+		for _, line := range lines {
+			if createdBy, found := strings.CutPrefix(line, "created by "); found {
+				goroutine.createdBy, _, _ = strings.Cut(createdBy, " in goroutine ")
+			}
+		}
+		goroutines = append(goroutines, goroutine)
+	}
+	return goroutines
+}
+
+/*
+ * openFiles lists the file descriptors open in this process, with the files
+ * they refer to where the platform allows reading them.  It reads the real
+ * filesystem rather than going through operating.System, since specs often
+ * replace its file functions.  The descriptor used to read the list itself
+ * is left out.
+ */
+func openFiles() []fileInfo {
+	fdDir := "/proc/self/fd"
+	if _, err := os.Stat(fdDir); err != nil {
+		fdDir = "/dev/fd"
+	}
+	listDir, _ := filepath.EvalSymlinks(fdDir)
+	entries, err := os.ReadDir(fdDir)
+	if err != nil {
+		return nil
+	}
+	files := make([]fileInfo, 0, len(entries))
+	for _, entry := range entries {
+		fd, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		target, err := os.Readlink(filepath.Join(fdDir, entry.Name()))
+		if err != nil {
+			// The descriptor was closed after the directory was read, or the platform does not support reading it
+			if _, statErr := os.Stat(filepath.Join(fdDir, entry.Name())); statErr != nil {
+				continue
+			}
+			target = "unknown"
+		}
+		if target == listDir || target == fdDir {
+			continue
+		}
+		files = append(files, fileInfo{fd: fd, target: target})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].fd < files[j].fd })
+	return files
+}
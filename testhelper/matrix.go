@@ -0,0 +1,233 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package testhelper
+
+/*
+ * This file contains a harness for running the same integration specs against
+ * several live servers, such as one each of GPDB 6, GPDB 7, and Cloudberry, to
+ * check code whose behavior depends on the server version.
+ */
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/dbconn"
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/pkg/errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+/*
+ * The environment variable listing the servers to run matrix specs against,
+ * as a comma-separated list of name=[user@]host[:port][/dbname] entries, e.g.
+ *
+ *   GP_TEST_SERVERS="gpdb6=gpadmin@mdw6:5432/postgres,cbdb=localhost:7000"
+ */
+const SERVER_MATRIX_ENV = "GP_TEST_SERVERS"
+
+/*
+ * A ServerTarget is one server to run matrix specs against.  Name identifies
+ * the server in spec descriptions and is added to each spec as a Ginkgo
+ * label, so that e.g. "ginkgo --label-filter=gpdb7" runs the specs against
+ * only that server.
+ */
+type ServerTarget struct {
+	Name   string
+	Host   string
+	Port   int
+	User   string
+	DBName string
+}
+
+func (target ServerTarget) String() string {
+	return fmt.Sprintf("%s (%s@%s:%d/%s)", target.Name, target.User, target.Host, target.Port, target.DBName)
+}
+
+/*
+ * ParseServerTargets parses targets in the format of SERVER_MATRIX_ENV.  The
+ * user and database default to PGUSER and PGDATABASE, or to the current user
+ * and "postgres" if those are not set, and the port defaults to 5432.
+ */
+func ParseServerTargets(targetsStr string) ([]ServerTarget, error) {
+	targets := make([]ServerTarget, 0)
+	names := make(map[string]bool)
+	for _, entry := range strings.Split(targetsStr, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		target, err := parseServerTarget(entry)
+		if err != nil {
+			return nil, err
+		}
+		if names[target.Name] {
+			return nil, errors.Errorf("Server %s is listed more than once", target.Name)
+		}
+		names[target.Name] = true
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+func parseServerTarget(entry string) (ServerTarget, error) {
+	name, address, found := strings.Cut(entry, "=")
+	name, address = strings.TrimSpace(name), strings.TrimSpace(address)
+	if !found || name == "" || address == "" {
+		return ServerTarget{}, errors.Errorf("Invalid server %q; must be in the form name=[user@]host[:port][/dbname]", entry)
+	}
+	env, _ := operating.Environment()
+	target := ServerTarget{Name: name, Port: 5432, User: env.PGUser, DBName: env.PGDatabase}
+	if user, rest, hasUser := strings.Cut(address, "@"); hasUser {
+		target.User, address = user, rest
+	}
+	if rest, dbname, hasDBName := strings.Cut(address, "/"); hasDBName {
+		target.DBName, address = dbname, rest
+	}
+	if host, portStr, hasPort := strings.Cut(address, ":"); hasPort {
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port <= 0 || port > 65535 {
+			return ServerTarget{}, errors.Errorf("Invalid port %q for server %s", portStr, name)
+		}
+		target.Port, address = port, host
+	}
+	if address == "" {
+		return ServerTarget{}, errors.Errorf("No host given for server %s", name)
+	}
+	target.Host = address
+	if target.User == "" {
+		currentUser, err := operating.System.CurrentUser()
+		if err != nil {
+			return ServerTarget{}, errors.Wrapf(err, "Unable to determine user for server %s", name)
+		}
+		target.User = currentUser.Username
+	}
+	if target.DBName == "" {
+		target.DBName = "postgres"
+	}
+	return target, nil
+}
+
+// ServerTargetsFromEnvironment returns the servers listed in SERVER_MATRIX_ENV, or none if it is not set
+func ServerTargetsFromEnvironment() ([]ServerTarget, error) {
+	return ParseServerTargets(operating.System.Getenv(SERVER_MATRIX_ENV))
+}
+
+/*
+ * A MatrixServer is passed to the body of each matrix spec.  Its fields are
+ * only set once the specs start running, so they must be used inside Its and
+ * setup nodes, not in the body itself.
+ */
+type MatrixServer struct {
+	Target     ServerTarget
+	Connection *dbconn.DBConn
+	Version    dbconn.GPDBVersion
+}
+
+// SkipBefore skips the current spec if the server is older than version
+func (server *MatrixServer) SkipBefore(version string) {
+	if server.Version.Before(version) {
+		Skip(fmt.Sprintf("Requires version %s or later, but %s is version %s", version, server.Target.Name, server.Version.SemVer))
+	}
+}
+
+// SkipUnlessType skips the current spec if the server is not of dbType
+func (server *MatrixServer) SkipUnlessType(dbType dbconn.DBType) {
+	if server.Version.Type != dbType {
+		Skip(fmt.Sprintf("Requires %s, but %s is %s", dbType, server.Target.Name, server.Version.Type))
+	}
+}
+
+/*
+ * A ServerMatrix runs each set of specs registered with Describe against
+ * every one of its targets, connecting once per target for each set.  If
+ * there are no targets, the specs are reported as skipped rather than
+ * failing, so that suites can include matrix specs that run only where test
+ * servers are available, e.g.
+ *
+ *   var matrix = testhelper.NewServerMatrixFromEnvironment()
+ *
+ *   var _ = matrix.Describe("GetSegmentConfiguration", func(server *testhelper.MatrixServer) {
+ *       It("returns the coordinator", func() {
+ *           segments := cluster.MustGetSegmentConfiguration(server.Connection)
+ *           ...
+ *       })
+ *   })
+ */
+type ServerMatrix struct {
+	Targets []ServerTarget
+	err     error
+}
+
+func NewServerMatrix(targets ...ServerTarget) *ServerMatrix {
+	return &ServerMatrix{Targets: targets}
+}
+
+/*
+ * NewServerMatrixFromEnvironment returns a matrix of the servers listed in
+ * SERVER_MATRIX_ENV.  If that is invalid, every spec registered with the
+ * matrix fails with the parsing error, rather than the whole suite failing to
+ * start.
+ */
+func NewServerMatrixFromEnvironment() *ServerMatrix {
+	targets, err := ServerTargetsFromEnvironment()
+	return &ServerMatrix{Targets: targets, err: err}
+}
+
+/*
+ * Describe registers a container for each target holding the specs defined
+ * by body, labeled with the target's name and with the name in the container
+ * description, so that each failure shows which server it was against.  The
+ * server's full version string is added to each spec's report.
+ */
+func (matrix *ServerMatrix) Describe(description string, body func(server *MatrixServer)) bool {
+	if matrix.err != nil {
+		err := matrix.err
+		return Describe(description, func() {
+			It("runs against each configured server", func() {
+				Fail(fmt.Sprintf("Invalid %s: %s", SERVER_MATRIX_ENV, err))
+			})
+		})
+	} else if len(matrix.Targets) == 0 {
+		return Describe(description, func() {
+			It("runs against each configured server", func() {
+				Skip(fmt.Sprintf("No servers to run against; set %s to run these specs", SERVER_MATRIX_ENV))
+			})
+		})
+	}
+	for _, target := range matrix.Targets {
+		server := &MatrixServer{Target: target}
+		Describe(fmt.Sprintf("[%s] %s", target.Name, description), Ordered, Label(target.Name), func() {
+			BeforeAll(func() {
+				server.Connection = dbconn.NewDBConn(target.DBName, target.User, target.Host, target.Port)
+				err := server.Connection.Connect(1)
+				Expect(err).ToNot(HaveOccurred(), "Unable to connect to server %s", target)
+				server.Version = server.Connection.Version
+				DeferCleanup(server.Connection.Close)
+			})
+			BeforeEach(func() {
+				AddReportEntry("server", fmt.Sprintf("%s: %s", target, server.Version.VersionString))
+			})
+			body(server)
+		})
+	}
+	return true
+}
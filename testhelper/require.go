@@ -0,0 +1,132 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package testhelper
+
+/*
+ * This file contains guards for tests that need something from the
+ * environment, such as a database or SSH access, so that every suite skips
+ * such tests the same way when it is missing.
+ */
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/dbconn"
+	"github.com/apache/cloudberry-go-libs/operating"
+)
+
+/*
+ * If REQUIRE_ALL_ENV is set to any non-empty value, a missing prerequisite
+ * fails the test instead of skipping it, so that CI, where every prerequisite
+ * should be present, cannot pass by silently skipping tests.
+ */
+const REQUIRE_ALL_ENV = "GP_TEST_REQUIRE_ALL"
+
+/*
+ * A Skipper is a test that can be skipped or failed, which is satisfied by
+ * *testing.T and *testing.B, and by GinkgoT() in a Ginkgo spec or setup node,
+ * e.g.
+ *
+ *   testhelper.RequireEnv(GinkgoT(), "GPHOME")
+ */
+type Skipper interface {
+	Helper()
+	Skip(args ...interface{})
+	Fatal(args ...interface{})
+}
+
+// skipOrFail skips the test with reason, or fails it if REQUIRE_ALL_ENV is set
+func skipOrFail(skipper Skipper, reason string) {
+	skipper.Helper()
+	if operating.System.Getenv(REQUIRE_ALL_ENV) != "" {
+		skipper.Fatal(fmt.Sprintf("%s (failing instead of skipping because %s is set)", reason, REQUIRE_ALL_ENV))
+		return
+	}
+	skipper.Skip(reason)
+}
+
+// RequireEnv skips the test unless each of the named environment variables is set to a non-empty value
+func RequireEnv(skipper Skipper, names ...string) {
+	skipper.Helper()
+	missing := make([]string, 0)
+	for _, name := range names {
+		if operating.System.Getenv(name) == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		skipOrFail(skipper, fmt.Sprintf("Requires %s to be set", strings.Join(missing, ", ")))
+	}
+}
+
+/*
+ * RequireDatabase skips the test unless it can connect to the database given
+ * by PGHOST, PGPORT, and PGUSER, as for dbconn.NewDBConnFromEnvironment, and
+ * returns the connection, which the caller must close.  The database is
+ * dbname, or PGDATABASE if dbname is empty, or postgres if neither is set.
+ */
+func RequireDatabase(skipper Skipper, dbname string) *dbconn.DBConn {
+	skipper.Helper()
+	if dbname == "" {
+		dbname = operating.System.Getenv("PGDATABASE")
+	}
+	if dbname == "" {
+		dbname = "postgres"
+	}
+	connection := dbconn.NewDBConnFromEnvironment(dbname)
+	if err := connection.Connect(1); err != nil {
+		skipOrFail(skipper, fmt.Sprintf("Requires a database to connect to, set with PGHOST and PGPORT; unable to connect to database %s: %s", dbname, err))
+		return nil
+	}
+	return connection
+}
+
+/*
+ * RequireSSH skips the test unless each host, or localhost if none are given,
+ * can be reached with ssh without a password, as cluster commands need.
+ */
+func RequireSSH(skipper Skipper, hosts ...string) {
+	skipper.Helper()
+	if len(hosts) == 0 {
+		hosts = []string{"localhost"}
+	}
+	for _, host := range hosts {
+		output, err := operating.System.CommandOutput("ssh", "-o", "BatchMode=yes", "-o", "ConnectTimeout=5", "-o", "StrictHostKeyChecking=no", host, "true")
+		if err != nil {
+			reason := fmt.Sprintf("Requires passwordless ssh to %s: %s", host, err)
+			if details := strings.TrimSpace(string(output)); details != "" {
+				reason += ": " + details
+			}
+			skipOrFail(skipper, reason)
+			return
+		}
+	}
+}
+
+/*
+ * RequireVersion skips the test unless the database connection is to version
+ * minVersion or later, e.g. "7" or "6.20.0", for tests of features that older
+ * versions lack.
+ */
+func RequireVersion(skipper Skipper, connection *dbconn.DBConn, minVersion string) {
+	skipper.Helper()
+	if !connection.Version.AtLeast(minVersion) {
+		skipOrFail(skipper, fmt.Sprintf("Requires version %s or later, but the database is %s %s", minVersion, connection.Version.Type, connection.Version.SemVer))
+	}
+}
@@ -0,0 +1,145 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package testhelper_test
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/apache/cloudberry-go-libs/operating"
+	"github.com/apache/cloudberry-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// A fakeSkipper records whether a guard skipped or failed the test instead of stopping it
+type fakeSkipper struct {
+	skipped string
+	failed  string
+}
+
+func (skipper *fakeSkipper) Helper() {}
+
+func (skipper *fakeSkipper) Skip(args ...interface{}) {
+	skipper.skipped = fmt.Sprint(args...)
+}
+
+func (skipper *fakeSkipper) Fatal(args ...interface{}) {
+	skipper.failed = fmt.Sprint(args...)
+}
+
+var _ = Describe("testhelper/require tests", func() {
+	var (
+		skipper *fakeSkipper
+		env     map[string]string
+	)
+
+	BeforeEach(func() {
+		skipper = &fakeSkipper{}
+		env = map[string]string{"GPHOME": "/usr/local/cloudberry-db"}
+		operating.System.Getenv = func(key string) string {
+			return env[key]
+		}
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("RequireEnv", func() {
+		It("does nothing if every variable is set", func() {
+			testhelper.RequireEnv(skipper, "GPHOME")
+			Expect(*skipper).To(Equal(fakeSkipper{}))
+		})
+		It("skips the test, naming every variable that is not set", func() {
+			env["COORDINATOR_DATA_DIRECTORY"] = ""
+			testhelper.RequireEnv(skipper, "GPHOME", "COORDINATOR_DATA_DIRECTORY", "PGPORT")
+			Expect(skipper.skipped).To(Equal("Requires COORDINATOR_DATA_DIRECTORY, PGPORT to be set"))
+			Expect(skipper.failed).To(BeEmpty())
+		})
+		It("fails the test instead if GP_TEST_REQUIRE_ALL is set", func() {
+			env[testhelper.REQUIRE_ALL_ENV] = "1"
+			testhelper.RequireEnv(skipper, "PGPORT")
+			Expect(skipper.skipped).To(BeEmpty())
+			Expect(skipper.failed).To(Equal("Requires PGPORT to be set (failing instead of skipping because GP_TEST_REQUIRE_ALL is set)"))
+		})
+	})
+
+	Describe("RequireVersion", func() {
+		It("does nothing if the database is the required version or later", func() {
+			connection, _ := testhelper.CreateMockDBConn()
+			testhelper.SetDBVersion(connection, "7.1.0")
+			testhelper.RequireVersion(skipper, connection, "7")
+			testhelper.RequireVersion(skipper, connection, "6.20.0")
+			Expect(*skipper).To(Equal(fakeSkipper{}))
+		})
+		It("skips the test if the database is an earlier version", func() {
+			connection, _ := testhelper.CreateMockDBConn()
+			testhelper.SetDBVersion(connection, "6.26.1")
+			testhelper.RequireVersion(skipper, connection, "7")
+			Expect(skipper.skipped).To(Equal("Requires version 7 or later, but the database is Greenplum Database 6.26.1"))
+		})
+		It("fails the test instead if GP_TEST_REQUIRE_ALL is set", func() {
+			env[testhelper.REQUIRE_ALL_ENV] = "true"
+			connection, _ := testhelper.CreateMockDBConn()
+			testhelper.SetDBVersion(connection, "6.26.1")
+			testhelper.RequireVersion(skipper, connection, "7")
+			Expect(skipper.failed).To(HavePrefix("Requires version 7 or later"))
+		})
+	})
+
+	Describe("RequireDatabase", func() {
+		It("skips the test if it cannot connect to the database", func() {
+			env["PGHOST"], env["PGPORT"], env["PGUSER"] = "127.0.0.1", "1", "gpadmin"
+			Expect(testhelper.RequireDatabase(skipper, "")).To(BeNil())
+			Expect(skipper.skipped).To(HavePrefix("Requires a database to connect to, set with PGHOST and PGPORT; unable to connect to database postgres: "))
+		})
+		It("connects to PGDATABASE if no database is given", func() {
+			env["PGHOST"], env["PGPORT"], env["PGUSER"], env["PGDATABASE"] = "127.0.0.1", "1", "gpadmin", "sales"
+			Expect(testhelper.RequireDatabase(skipper, "")).To(BeNil())
+			Expect(skipper.skipped).To(ContainSubstring("unable to connect to database sales: "))
+		})
+	})
+
+	Describe("RequireSSH", func() {
+		var sshHosts []string
+
+		BeforeEach(func() {
+			sshHosts = nil
+			operating.System.CommandOutput = func(name string, args ...string) ([]byte, error) {
+				host := args[len(args)-2]
+				sshHosts = append(sshHosts, host)
+				if host == "sdw2" {
+					return []byte("ssh: connect to host sdw2 port 22: Connection refused\n"), errors.New("exit status 255")
+				}
+				return nil, nil
+			}
+		})
+
+		It("checks localhost if no hosts are given", func() {
+			testhelper.RequireSSH(skipper)
+			Expect(sshHosts).To(Equal([]string{"localhost"}))
+			Expect(*skipper).To(Equal(fakeSkipper{}))
+		})
+		It("skips the test at the first host that cannot be reached", func() {
+			testhelper.RequireSSH(skipper, "sdw1", "sdw2", "sdw3")
+			Expect(sshHosts).To(Equal([]string{"sdw1", "sdw2"}))
+			Expect(skipper.skipped).To(Equal("Requires passwordless ssh to sdw2: exit status 255: ssh: connect to host sdw2 port 22: Connection refused"))
+		})
+	})
+})
@@ -0,0 +1,216 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package testhelper
+
+/*
+ * This file contains a fake resolver for testing code that looks up host
+ * names and this machine's addresses through operating.System, without
+ * depending on the DNS or network configuration of the machine running the
+ * tests.
+ */
+
+import (
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/apache/cloudberry-go-libs/operating"
+)
+
+/*
+ * A FakeResolver answers Hostname, LookupHost, and InterfaceAddrs from the
+ * answers it has been given.  Install points those functions in
+ * operating.System at it.  Names are matched case-insensitively and without
+ * any trailing dot, as in DNS, and looking up a name with no answer fails
+ * as a nonexistent domain (NXDOMAIN) would.  IP addresses resolve to
+ * themselves, as with net.LookupHost.
+ *
+ * The setters return the resolver, so that a resolver can be set up in one
+ * expression, and may be called while code under test is using it.
+ */
+type FakeResolver struct {
+	mutex          sync.Mutex
+	hostname       string
+	hostnameErr    error
+	hosts          map[string][]string
+	failures       map[string]error
+	interfaceAddrs []net.Addr
+	interfaceErr   error
+	lookups        map[string]int
+}
+
+func NewFakeResolver() *FakeResolver {
+	return &FakeResolver{
+		hostname: "testHost",
+		hosts:    make(map[string][]string),
+		failures: make(map[string]error),
+		lookups:  make(map[string]int),
+	}
+}
+
+func (resolver *FakeResolver) Install() {
+	operating.System.Hostname = resolver.Hostname
+	operating.System.LookupHost = resolver.LookupHost
+	operating.System.InterfaceAddrs = resolver.InterfaceAddrs
+}
+
+func canonicalHost(host string) string {
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// SetHostname sets the name Hostname returns, and clears any error set with FailHostname
+func (resolver *FakeResolver) SetHostname(hostname string) *FakeResolver {
+	resolver.mutex.Lock()
+	defer resolver.mutex.Unlock()
+	resolver.hostname, resolver.hostnameErr = hostname, nil
+	return resolver
+}
+
+func (resolver *FakeResolver) FailHostname(err error) *FakeResolver {
+	resolver.mutex.Lock()
+	defer resolver.mutex.Unlock()
+	resolver.hostnameErr = err
+	return resolver
+}
+
+// AddHost sets the addresses host resolves to, replacing any earlier answer or failure for it
+func (resolver *FakeResolver) AddHost(host string, addrs ...string) *FakeResolver {
+	resolver.mutex.Lock()
+	defer resolver.mutex.Unlock()
+	resolver.hosts[canonicalHost(host)] = addrs
+	delete(resolver.failures, canonicalHost(host))
+	return resolver
+}
+
+/*
+ * AddHostsFile adds the answers in contents, which is in the format of
+ * /etc/hosts: an address followed by the names that resolve to it on each
+ * line, with comments starting with "#".  A name on several lines resolves
+ * to all of their addresses, in order.
+ */
+func (resolver *FakeResolver) AddHostsFile(contents string) *FakeResolver {
+	resolver.mutex.Lock()
+	defer resolver.mutex.Unlock()
+	added := make(map[string]bool)
+	for _, line := range strings.Split(contents, "\n") {
+		if comment := strings.Index(line, "#"); comment >= 0 {
+			line = line[:comment]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		for _, host := range fields[1:] {
+			host = canonicalHost(host)
+			if !added[host] {
+				resolver.hosts[host] = nil
+				delete(resolver.failures, host)
+				added[host] = true
+			}
+			resolver.hosts[host] = append(resolver.hosts[host], fields[0])
+		}
+	}
+	return resolver
+}
+
+// FailHost makes looking up host return err, e.g. one returned by DNSTimeout
+func (resolver *FakeResolver) FailHost(host string, err error) *FakeResolver {
+	resolver.mutex.Lock()
+	defer resolver.mutex.Unlock()
+	resolver.failures[canonicalHost(host)] = err
+	return resolver
+}
+
+/*
+ * SetInterfaceAddrs sets the addresses of this machine's network interfaces,
+ * each in CIDR notation such as "10.0.0.5/24" or "fe80::1/64", and clears
+ * any error set with FailInterfaceAddrs.  It panics if an address is not
+ * valid CIDR notation, since that is a mistake in the test.
+ */
+func (resolver *FakeResolver) SetInterfaceAddrs(cidrs ...string) *FakeResolver {
+	addrs := make([]net.Addr, len(cidrs))
+	for i, cidr := range cidrs {
+		ip, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		addrs[i] = &net.IPNet{IP: ip, Mask: network.Mask}
+	}
+	resolver.mutex.Lock()
+	defer resolver.mutex.Unlock()
+	resolver.interfaceAddrs, resolver.interfaceErr = addrs, nil
+	return resolver
+}
+
+func (resolver *FakeResolver) FailInterfaceAddrs(err error) *FakeResolver {
+	resolver.mutex.Lock()
+	defer resolver.mutex.Unlock()
+	resolver.interfaceErr = err
+	return resolver
+}
+
+func (resolver *FakeResolver) Hostname() (string, error) {
+	resolver.mutex.Lock()
+	defer resolver.mutex.Unlock()
+	if resolver.hostnameErr != nil {
+		return "", resolver.hostnameErr
+	}
+	return resolver.hostname, nil
+}
+
+func (resolver *FakeResolver) LookupHost(host string) ([]string, error) {
+	resolver.mutex.Lock()
+	defer resolver.mutex.Unlock()
+	resolver.lookups[canonicalHost(host)]++
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+	if err, ok := resolver.failures[canonicalHost(host)]; ok {
+		return nil, err
+	}
+	if addrs, ok := resolver.hosts[canonicalHost(host)]; ok && len(addrs) > 0 {
+		return append([]string{}, addrs...), nil
+	}
+	return nil, DNSNotFound(host)
+}
+
+func (resolver *FakeResolver) InterfaceAddrs() ([]net.Addr, error) {
+	resolver.mutex.Lock()
+	defer resolver.mutex.Unlock()
+	if resolver.interfaceErr != nil {
+		return nil, resolver.interfaceErr
+	}
+	return append([]net.Addr{}, resolver.interfaceAddrs...), nil
+}
+
+// Lookups returns the number of times host has been looked up
+func (resolver *FakeResolver) Lookups(host string) int {
+	resolver.mutex.Lock()
+	defer resolver.mutex.Unlock()
+	return resolver.lookups[canonicalHost(host)]
+}
+
+// DNSNotFound returns the error net.LookupHost returns for a nonexistent domain
+func DNSNotFound(host string) error {
+	return &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+// DNSTimeout returns the error net.LookupHost returns when the DNS server does not answer in time
+func DNSTimeout(host string) error {
+	return &net.DNSError{Err: "i/o timeout", Name: host, IsTimeout: true}
+}
@@ -0,0 +1,165 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package testhelper
+
+/*
+ * This file contains helpers for setting up sqlmock expectations without
+ * building each result set by hand, including canned results for catalog
+ * queries that most programs using this library run.
+ */
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/apache/cloudberry-go-libs/cluster"
+	"github.com/apache/cloudberry-go-libs/dbconn"
+	. "github.com/onsi/gomega"
+)
+
+/*
+ * StructRows returns sqlmock rows holding the fields of each row, which must
+ * all be structs (or pointers to structs) of the same type, under the column
+ * names that sqlx scans them by: the field's db tag if it has one, or else
+ * its lowercased name.  Each row may instead be a single value, such as an
+ * int or string, for queries that are scanned with Get into a scalar.
+ *
+ * This means that a test can describe the results of a query with the same
+ * structs the code under test scans them into, e.g.
+ *
+ *   mock.ExpectQuery("SELECT (.*)").WillReturnRows(testhelper.StructRows(expectedTables...))
+ */
+func StructRows(rows ...interface{}) *sqlmock.Rows {
+	if len(rows) == 0 {
+		return sqlmock.NewRows([]string{})
+	}
+	rowType := reflect.Indirect(reflect.ValueOf(rows[0])).Type()
+	if rowType.Kind() != reflect.Struct {
+		result := sqlmock.NewRows([]string{"?column?"})
+		for _, row := range rows {
+			result.AddRow(row)
+		}
+		return result
+	}
+	columns, fieldIndexes := structColumns(rowType, nil)
+	result := sqlmock.NewRows(columns)
+	for _, row := range rows {
+		value := reflect.Indirect(reflect.ValueOf(row))
+		Expect(value.Type()).To(Equal(rowType), "All rows passed to StructRows must have the same type")
+		values := make([]driver.Value, len(fieldIndexes))
+		for i, index := range fieldIndexes {
+			values[i] = value.FieldByIndex(index).Interface()
+		}
+		result.AddRow(values...)
+	}
+	return result
+}
+
+// structColumns follows sqlx in treating the fields of embedded structs as fields of the outer struct
+func structColumns(structType reflect.Type, parentIndex []int) ([]string, [][]int) {
+	columns := make([]string, 0)
+	fieldIndexes := make([][]int, 0)
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		index := append(append([]int{}, parentIndex...), i)
+		tag := field.Tag.Get("db")
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && tag == "" {
+			embeddedColumns, embeddedIndexes := structColumns(field.Type, index)
+			columns = append(columns, embeddedColumns...)
+			fieldIndexes = append(fieldIndexes, embeddedIndexes...)
+			continue
+		} else if !field.IsExported() || tag == "-" {
+			continue
+		}
+		if tag == "" {
+			tag = strings.ToLower(field.Name)
+		}
+		columns = append(columns, tag)
+		fieldIndexes = append(fieldIndexes, index)
+	}
+	return columns, fieldIndexes
+}
+
+/*
+ * ExpectQueryReturning expects a query matching pattern, a regular expression
+ * as for sqlmock, and returns rows for it as described for StructRows.  The
+ * returned expectation can be used to add e.g. WithArgs.
+ */
+func ExpectQueryReturning(mock sqlmock.Sqlmock, pattern string, rows ...interface{}) *sqlmock.ExpectedQuery {
+	return mock.ExpectQuery(pattern).WillReturnRows(StructRows(rows...))
+}
+
+func ExpectQueryFailing(mock sqlmock.Sqlmock, pattern string, err error) *sqlmock.ExpectedQuery {
+	return mock.ExpectQuery(pattern).WillReturnError(err)
+}
+
+func ExpectExecAffecting(mock sqlmock.Sqlmock, pattern string, rowsAffected int64) *sqlmock.ExpectedExec {
+	return mock.ExpectExec(pattern).WillReturnResult(sqlmock.NewResult(0, rowsAffected))
+}
+
+// AssertAllExpectationsMet fails the test if any expected statement was not executed
+func AssertAllExpectationsMet(mock sqlmock.Sqlmock) {
+	Expect(mock.ExpectationsWereMet()).To(Succeed(), "Not all expected database statements were executed")
+}
+
+/*
+ * VersionString returns a string like the one "SELECT version()" returns for
+ * a server of dbType with the given X.Y.Z version, for tests that check how
+ * the full string is parsed or reported.
+ */
+func VersionString(dbType dbconn.DBType, versionStr string) string {
+	postgresVersion := "14.4"
+	if dbType != dbconn.CBDB {
+		switch {
+		case strings.HasPrefix(versionStr, "5."):
+			postgresVersion = "8.3.23"
+		case strings.HasPrefix(versionStr, "6."):
+			postgresVersion = "9.4.26"
+		default:
+			postgresVersion = "12.12"
+		}
+	}
+	return fmt.Sprintf("PostgreSQL %s (%s %s build commit:0000000000000000000000000000000000000000) on x86_64-pc-linux-gnu", postgresVersion, dbType, versionStr)
+}
+
+// ExpectVersionQueryForType is like ExpectVersionQuery, but for a server of any type
+func ExpectVersionQueryForType(mock sqlmock.Sqlmock, dbType dbconn.DBType, versionStr string) {
+	versionRow := sqlmock.NewRows([]string{"versionstring"}).AddRow(VersionString(dbType, versionStr))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_catalog.version() AS versionstring")).WillReturnRows(versionRow)
+}
+
+/*
+ * ExpectSegmentConfigurationQuery expects the query that
+ * cluster.GetSegmentConfiguration runs, for any version and choice of
+ * mirrors, and returns segments from it.
+ */
+func ExpectSegmentConfigurationQuery(mock sqlmock.Sqlmock, segments ...cluster.SegConfig) *sqlmock.ExpectedQuery {
+	if len(segments) == 0 {
+		columns, _ := structColumns(reflect.TypeOf(cluster.SegConfig{}), nil)
+		return mock.ExpectQuery("FROM gp_segment_configuration").WillReturnRows(sqlmock.NewRows(columns))
+	}
+	rows := make([]interface{}, len(segments))
+	for i, segment := range segments {
+		rows[i] = segment
+	}
+	return ExpectQueryReturning(mock, "FROM gp_segment_configuration", rows...)
+}
@@ -66,6 +66,7 @@ type TestExecutor struct {
 	ClusterOutput   *cluster.RemoteOutput
 	ClusterOutputs  []*cluster.RemoteOutput
 	ClusterCommands [][]cluster.ShellCommand
+	ClusterContexts []context.Context
 
 	ErrorOnExecNum       int // Return LocalError after this many calls of ExecuteLocalCommand (0 means always return error); has no effect for ExecuteClusterCommand
 	NumExecutions        int // Total of NumLocalExecutions and NumClusterExecutions, for convenience and backwards compatibility
@@ -157,3 +158,13 @@ func (executor *TestExecutor) ExecuteClusterCommandWithRetries(scope cluster.Sco
 	}
 	return executor.ClusterOutput
 }
+
+func (executor *TestExecutor) ExecuteClusterCommandWithContext(scope cluster.Scope, commandList []cluster.ShellCommand, ctx context.Context) *cluster.RemoteOutput {
+	executor.ClusterContexts = append(executor.ClusterContexts, ctx)
+	return executor.ExecuteClusterCommand(scope, commandList)
+}
+
+func (executor *TestExecutor) ExecuteClusterCommandWithRetriesAndContext(scope cluster.Scope, commandList []cluster.ShellCommand, maxAttempts int, retrySleep time.Duration, ctx context.Context) *cluster.RemoteOutput {
+	executor.ClusterContexts = append(executor.ClusterContexts, ctx)
+	return executor.ExecuteClusterCommandWithRetries(scope, commandList, maxAttempts, retrySleep)
+}
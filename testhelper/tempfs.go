@@ -0,0 +1,235 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package testhelper
+
+/*
+ * This file contains a harness for building a tree of fixture files in a
+ * temporary directory, and optionally making code under test see that tree
+ * as the whole filesystem.
+ */
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apache/cloudberry-go-libs/operating"
+)
+
+/*
+ * TempFSTestingT is the part of testing.T that a TempFS needs, so that both a
+ * *testing.T and GinkgoT() can be passed to NewTempFS.
+ */
+type TempFSTestingT interface {
+	TempDir() string
+	Fatalf(format string, args ...interface{})
+}
+
+/*
+ * A TempFS is a temporary directory that holds a tree of fixture files.  Its
+ * helpers take paths relative to the root of the tree, and fail the test if
+ * they cannot set up the fixture.  The directory is removed when the test
+ * ends, as for testing.T.TempDir.
+ */
+type TempFS struct {
+	t    TempFSTestingT
+	root string
+}
+
+func NewTempFS(t TempFSTestingT) *TempFS {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("Unable to resolve temporary directory: %v", err)
+	}
+	return &TempFS{t: t, root: root}
+}
+
+func (tempFS *TempFS) Root() string {
+	return tempFS.root
+}
+
+// Path returns the real path of name, which may be absolute or relative, within the tree
+func (tempFS *TempFS) Path(name string) string {
+	if name == tempFS.root || strings.HasPrefix(name, tempFS.root+string(filepath.Separator)) {
+		return name
+	}
+	return filepath.Join(tempFS.root, filepath.Clean(string(filepath.Separator)+name))
+}
+
+// virtualPath undoes Path, returning the path of name as code using Install sees it
+func (tempFS *TempFS) virtualPath(name string) string {
+	if name == tempFS.root {
+		return string(filepath.Separator)
+	} else if strings.HasPrefix(name, tempFS.root+string(filepath.Separator)) {
+		return strings.TrimPrefix(name, tempFS.root)
+	}
+	return name
+}
+
+func (tempFS *TempFS) check(err error, action string, name string) {
+	if err != nil {
+		tempFS.t.Fatalf("Unable to %s %s in temporary filesystem: %v", action, name, err)
+	}
+}
+
+// WriteFile writes contents to name, creating any missing parent directories, and returns its real path
+func (tempFS *TempFS) WriteFile(name string, contents string, perm os.FileMode) string {
+	path := tempFS.Path(name)
+	tempFS.check(os.MkdirAll(filepath.Dir(path), 0755), "create parent directories of", name)
+	tempFS.check(os.WriteFile(path, []byte(contents), perm), "write", name)
+	// WriteFile does not change the permissions of an existing file, and the umask may have removed some
+	tempFS.check(os.Chmod(path, perm), "change permissions of", name)
+	return path
+}
+
+func (tempFS *TempFS) ReadFile(name string) string {
+	contents, err := os.ReadFile(tempFS.Path(name))
+	tempFS.check(err, "read", name)
+	return string(contents)
+}
+
+func (tempFS *TempFS) MkdirAll(name string, perm os.FileMode) string {
+	path := tempFS.Path(name)
+	tempFS.check(os.MkdirAll(path, perm), "create directory", name)
+	return path
+}
+
+func (tempFS *TempFS) Chmod(name string, perm os.FileMode) {
+	tempFS.check(os.Chmod(tempFS.Path(name), perm), "change permissions of", name)
+}
+
+/*
+ * Symlink creates a symbolic link at name pointing to target, which is
+ * written as given, so an absolute target refers to the real filesystem.
+ */
+func (tempFS *TempFS) Symlink(target string, name string) {
+	path := tempFS.Path(name)
+	tempFS.check(os.MkdirAll(filepath.Dir(path), 0755), "create parent directories of", name)
+	tempFS.check(os.Symlink(target, path), "create symbolic link", name)
+}
+
+func (tempFS *TempFS) Exists(name string) bool {
+	_, err := os.Lstat(tempFS.Path(name))
+	return err == nil
+}
+
+// unmapError reports paths in err as code using Install sees them
+func (tempFS *TempFS) unmapError(err error) error {
+	var pathErr *os.PathError
+	var linkErr *os.LinkError
+	if errors.As(err, &pathErr) {
+		return &os.PathError{Op: pathErr.Op, Path: tempFS.virtualPath(pathErr.Path), Err: pathErr.Err}
+	} else if errors.As(err, &linkErr) {
+		return &os.LinkError{Op: linkErr.Op, Old: tempFS.virtualPath(linkErr.Old), New: tempFS.virtualPath(linkErr.New), Err: linkErr.Err}
+	}
+	return err
+}
+
+/*
+ * Install wraps the filesystem functions in operating.System so that every
+ * path, absolute or relative, is treated as a path within the tree; for
+ * example, code under test that reads /data/gpseg0/postgresql.conf reads
+ * Path("/data/gpseg0/postgresql.conf") instead.  Paths returned by Glob,
+ * MkdirTemp, and Readlink, and paths in errors, are translated back, so the
+ * code sees only the fixture tree.  Files created with TempFile keep their
+ * real names, which are also accepted.
+ *
+ * As with the other fakes in this package, operating.System should be reset
+ * with InitializeSystemFunctions once the test is done.
+ */
+func (tempFS *TempFS) Install() {
+	system := *operating.System
+	operating.System.Chmod = func(name string, mode os.FileMode) error {
+		return tempFS.unmapError(system.Chmod(tempFS.Path(name), mode))
+	}
+	operating.System.Chown = func(name string, uid, gid int) error {
+		return tempFS.unmapError(system.Chown(tempFS.Path(name), uid, gid))
+	}
+	operating.System.DiskUsage = func(path string) (operating.FilesystemUsage, error) {
+		usage, err := system.DiskUsage(tempFS.Path(path))
+		return usage, tempFS.unmapError(err)
+	}
+	operating.System.FilesystemType = func(path string) (string, error) {
+		fsType, err := system.FilesystemType(tempFS.Path(path))
+		return fsType, tempFS.unmapError(err)
+	}
+	operating.System.Glob = func(pattern string) ([]string, error) {
+		matches, err := system.Glob(tempFS.Path(pattern))
+		for i, match := range matches {
+			matches[i] = tempFS.virtualPath(match)
+		}
+		return matches, err
+	}
+	operating.System.Lstat = func(name string) (os.FileInfo, error) {
+		info, err := system.Lstat(tempFS.Path(name))
+		return info, tempFS.unmapError(err)
+	}
+	operating.System.MkdirAll = func(path string, perm os.FileMode) error {
+		return tempFS.unmapError(system.MkdirAll(tempFS.Path(path), perm))
+	}
+	operating.System.MkdirTemp = func(dir, pattern string) (string, error) {
+		name, err := system.MkdirTemp(tempFS.Path(dir), pattern)
+		return tempFS.virtualPath(name), tempFS.unmapError(err)
+	}
+	operating.System.OpenFileRead = func(name string, flag int, perm os.FileMode) (operating.ReadCloserAt, error) {
+		file, err := system.OpenFileRead(tempFS.Path(name), flag, perm)
+		return file, tempFS.unmapError(err)
+	}
+	operating.System.OpenFileWrite = func(name string, flag int, perm os.FileMode) (io.WriteCloser, error) {
+		file, err := system.OpenFileWrite(tempFS.Path(name), flag, perm)
+		return file, tempFS.unmapError(err)
+	}
+	operating.System.ReadDir = func(name string) ([]os.DirEntry, error) {
+		entries, err := system.ReadDir(tempFS.Path(name))
+		return entries, tempFS.unmapError(err)
+	}
+	operating.System.ReadFile = func(filename string) ([]byte, error) {
+		contents, err := system.ReadFile(tempFS.Path(filename))
+		return contents, tempFS.unmapError(err)
+	}
+	operating.System.Readlink = func(name string) (string, error) {
+		target, err := system.Readlink(tempFS.Path(name))
+		return tempFS.virtualPath(target), tempFS.unmapError(err)
+	}
+	operating.System.Remove = func(name string) error {
+		return tempFS.unmapError(system.Remove(tempFS.Path(name)))
+	}
+	operating.System.RemoveAll = func(name string) error {
+		return tempFS.unmapError(system.RemoveAll(tempFS.Path(name)))
+	}
+	operating.System.Rename = func(oldpath, newpath string) error {
+		return tempFS.unmapError(system.Rename(tempFS.Path(oldpath), tempFS.Path(newpath)))
+	}
+	operating.System.Stat = func(name string) (os.FileInfo, error) {
+		info, err := system.Stat(tempFS.Path(name))
+		return info, tempFS.unmapError(err)
+	}
+	operating.System.Symlink = func(oldname, newname string) error {
+		// Relative targets are resolved from the link's directory, which is already within the tree
+		if filepath.IsAbs(oldname) {
+			oldname = tempFS.Path(oldname)
+		}
+		return tempFS.unmapError(system.Symlink(oldname, tempFS.Path(newname)))
+	}
+	operating.System.TempFile = func(dir, pattern string) (*os.File, error) {
+		file, err := system.TempFile(tempFS.Path(dir), pattern)
+		return file, tempFS.unmapError(err)
+	}
+}